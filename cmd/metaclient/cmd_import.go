@@ -0,0 +1,135 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zeebo/clingy"
+
+	"storj.io/storj/cmd/uplink/ulloc"
+)
+
+type cmdImport struct {
+	access   *AccessOptions
+	location string
+
+	inputfile string
+	format    string
+	keyColumn string
+	schema    string
+	columns   string
+	merge     bool
+
+	bucket string
+}
+
+func newCmdImport() *cmdImport {
+	return &cmdImport{
+		access: newAccessOptions(),
+	}
+}
+
+func (c *cmdImport) Setup(params clingy.Parameters) {
+	c.access.Setup(params)
+	c.inputfile = params.Flag("input-file", "CSV manifest or S3 inventory report to import (- for stdin, .gz files are decompressed)", "", clingy.Short('i')).(string)
+	c.format = params.Flag("format", "Format of the input file (csv or s3-inventory)", "csv").(string)
+	c.keyColumn = params.Flag("key-column", "Column containing the object key (default: key for csv, Key for s3-inventory)", "").(string)
+	c.schema = params.Flag("schema", "Comma separated column names, if the file has no header row (fileSchema of the S3 inventory manifest)", "").(string)
+	c.columns = params.Flag("columns", "Comma separated column:metadataKey mappings of the columns to import (default: all columns)", "").(string)
+	c.merge = params.Flag("merge", "Merge imported values into the existing metadata", false,
+		clingy.Transform(strconv.ParseBool), clingy.Boolean,
+	).(bool)
+
+	c.location = params.Arg("location", "Bucket to import metadata into (sj://BUCKET)").(string)
+}
+
+func (c *cmdImport) Validate() (err error) {
+	err = c.access.Validate()
+	if err != nil {
+		return err
+	}
+
+	loc, err := ulloc.Parse(c.location)
+	if err != nil {
+		return fmt.Errorf("invalid location '%s': %w", c.location, err)
+	}
+
+	var ok bool
+	c.bucket, _, ok = loc.RemoteParts()
+	if !ok || c.bucket == "" {
+		return fmt.Errorf("invalid location '%s': must be a remote bucket", c.location)
+	}
+
+	if c.inputfile == "" {
+		return fmt.Errorf("--input-file must be provided")
+	}
+
+	return nil
+}
+
+func (c *cmdImport) Execute(ctx context.Context) (err error) {
+	err = c.Validate()
+	if err != nil {
+		return err
+	}
+
+	var input io.Reader
+	if c.inputfile == "-" {
+		input = os.Stdin
+	} else {
+		f, err := os.Open(c.inputfile)
+		if err != nil {
+			return fmt.Errorf("error reading input file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		input = f
+	}
+
+	if strings.HasSuffix(c.inputfile, ".gz") {
+		gz, err := gzip.NewReader(input)
+		if err != nil {
+			return fmt.Errorf("error decompressing input file: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		input = gz
+	}
+
+	options := url.Values{}
+	options.Set("format", c.format)
+	if c.keyColumn != "" {
+		options.Set("keyColumn", c.keyColumn)
+	}
+	if c.schema != "" {
+		options.Set("schema", c.schema)
+	}
+	if c.columns != "" {
+		options.Set("columns", c.columns)
+	}
+	if c.merge {
+		options.Set("merge", "true")
+	}
+
+	client := newMetaSearchClient(c.access)
+	result, err := client.ImportMetadata(ctx, c.bucket, options, input)
+	if err != nil {
+		return fmt.Errorf("cannot import metadata: %w", err)
+	}
+
+	formatted, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot format import result: %w", err)
+	}
+	fmt.Println(string(formatted))
+
+	return nil
+}
@@ -23,6 +23,7 @@ type cmdSearch struct {
 	rawPrefix  string
 	matchFile  string
 	rawMatch   string
+	query      string
 	filter     string
 	projection string
 
@@ -42,6 +43,7 @@ func (c *cmdSearch) Setup(params clingy.Parameters) {
 
 	c.rawMatch = params.Flag("match", "JSON metadata object to match", "", clingy.Short('m')).(string)
 	c.matchFile = params.Flag("match-file", "File containing JSON metadata to match", "", clingy.Short('M')).(string)
+	c.query = params.Flag("query", "SQL-like text query, e.g. \"department = 'hr' AND size > 100\"", "", clingy.Short('q')).(string)
 	c.filter = params.Flag("filter", "JMESPath filter expression", "", clingy.Short('f')).(string)
 	c.projection = params.Flag("projection", "JMESPath projection expression", "", clingy.Short('p')).(string)
 	c.rawPrefix = params.Arg("prefix", "Object key prefix (sj://BUCKET[/PREFIX])").(string)
@@ -96,7 +98,7 @@ func (c *cmdSearch) Execute(ctx context.Context) (err error) {
 	pageToken := ""
 	n := 0
 	for i := 0; i == 0 || pageToken != ""; i++ {
-		page, err := client.SearchMetadata(ctx, c.bucket, c.prefix, c.match, c.filter, c.projection, pageToken)
+		page, err := client.SearchMetadata(ctx, c.bucket, c.prefix, c.match, c.query, c.filter, c.projection, pageToken)
 		if err != nil {
 			return fmt.Errorf("error performing metadata search: %w", err)
 		}
@@ -9,10 +9,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/zeebo/clingy"
 
 	"storj.io/storj/cmd/uplink/ulloc"
+	"storj.io/uplink"
 )
 
 type cmdSet struct {
@@ -25,6 +28,9 @@ type cmdSet struct {
 	inputfile string
 	inputdata string
 
+	encrypt   bool
+	clearKeys string
+
 	metadata map[string]interface{}
 }
 
@@ -38,6 +44,10 @@ func (c *cmdSet) Setup(params clingy.Parameters) {
 	c.access.Setup(params)
 	c.inputfile = params.Flag("input-file", "File containing metadata to set", "", clingy.Short('i')).(string)
 	c.inputdata = params.Flag("data", "Metadata to set", "", clingy.Short('d')).(string)
+	c.encrypt = params.Flag("encrypt", "Encrypt metadata locally, only the values of --clear-keys are sent in clear text", false,
+		clingy.Transform(strconv.ParseBool), clingy.Boolean,
+	).(bool)
+	c.clearKeys = params.Flag("clear-keys", "Comma separated metadata keys to send in clear for searching, with --encrypt", "").(string)
 
 	c.location = params.Arg("location", "Location of object (sj://BUCKET/KEY)").(string)
 }
@@ -67,6 +77,10 @@ func (c *cmdSet) Validate() (err error) {
 		return fmt.Errorf("either --input-file or --data must be provided")
 	}
 
+	if c.clearKeys != "" && !c.encrypt {
+		return fmt.Errorf("--clear-keys requires --encrypt")
+	}
+
 	return nil
 }
 
@@ -82,7 +96,11 @@ func (c *cmdSet) Execute(ctx context.Context) (err error) {
 	}
 
 	client := newMetaSearchClient(c.access)
-	err = client.SetObjectMetadata(ctx, c.bucket, c.key, c.metadata)
+	if c.encrypt {
+		err = c.setEncryptedMetadata(ctx, client)
+	} else {
+		err = client.SetObjectMetadata(ctx, c.bucket, c.key, c.metadata)
+	}
 	if err != nil {
 		return fmt.Errorf("cannot set metadata: %w", err)
 	}
@@ -90,6 +108,19 @@ func (c *cmdSet) Execute(ctx context.Context) (err error) {
 	return nil
 }
 
+func (c *cmdSet) setEncryptedMetadata(ctx context.Context, client *MetaSearchClient) error {
+	access, err := uplink.ParseAccess(c.access.Access)
+	if err != nil {
+		return fmt.Errorf("invalid access: %w", err)
+	}
+
+	var clearKeys []string
+	if c.clearKeys != "" {
+		clearKeys = strings.Split(c.clearKeys, ",")
+	}
+	return client.SetEncryptedObjectMetadata(ctx, access, c.bucket, c.key, c.metadata, clearKeys)
+}
+
 func (c *cmdSet) setMetadata() (err error) {
 	var inputdata []byte
 
@@ -33,4 +33,5 @@ func commands(cmds clingy.Commands) {
 	cmds.New("set", "Set metadata for an existing object", newCmdSet())
 	cmds.New("rm", "Remove metadata for an existing object", newCmdDelete())
 	cmds.New("search", "Search metadata", newCmdSearch())
+	cmds.New("import", "Import metadata from a CSV manifest or an S3 inventory report", newCmdImport())
 }
@@ -8,13 +8,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/go-oauth2/oauth2/v4/errors"
 
 	"storj.io/metasearch/internal/metasearch"
+	"storj.io/uplink"
 )
 
+// scopedAccessTTL is the lifetime of the restricted access grants sent with
+// pre-encrypted metadata updates.
+const scopedAccessTTL = 5 * time.Minute
+
 // MetaSearchClient proides a client for the metasearch REST service.
 type MetaSearchClient struct {
 	access *AccessOptions
@@ -31,7 +39,7 @@ func newMetaSearchClient(access *AccessOptions) *MetaSearchClient {
 
 // GetObjectMetadata retrieves the metadata for an object.
 func (c *MetaSearchClient) GetObjectMetadata(ctx context.Context, bucket string, key string) (meta map[string]interface{}, err error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.access.Server+"/metadata/"+bucket+"/"+key, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.access.Server+"/v1/metadata/"+bucket+"/"+key, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +71,7 @@ func (c *MetaSearchClient) SetObjectMetadata(ctx context.Context, bucket string,
 		return fmt.Errorf("cannot encode metadata: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.access.Server+"/metadata/"+bucket+"/"+key, bytes.NewReader(buf))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.access.Server+"/v1/metadata/"+bucket+"/"+key, bytes.NewReader(buf))
 	if err != nil {
 		return err
 	}
@@ -84,9 +92,57 @@ func (c *MetaSearchClient) SetObjectMetadata(ctx context.Context, bucket string,
 	return nil
 }
 
+// SetEncryptedObjectMetadata encrypts the metadata of an object locally with
+// the access grant, and sets it with only the values of clearKeys in clear.
+// The request is authenticated with an access grant restricted to writing the
+// object for a few minutes, instead of the access grant of the client.
+func (c *MetaSearchClient) SetEncryptedObjectMetadata(ctx context.Context, access *uplink.Access, bucket string, key string, metadata map[string]interface{}, clearKeys []string) error {
+	request, err := metasearch.NewEncryptedUpdateRequest(access, bucket, key, metadata, clearKeys)
+	if err != nil {
+		return err
+	}
+
+	scoped, err := access.Share(uplink.Permission{
+		AllowUpload: true,
+		NotAfter:    time.Now().Add(scopedAccessTTL),
+	}, uplink.SharePrefix{Bucket: bucket, Prefix: key})
+	if err != nil {
+		return fmt.Errorf("cannot restrict access: %w", err)
+	}
+	serialized, err := scoped.Serialize()
+	if err != nil {
+		return fmt.Errorf("cannot restrict access: %w", err)
+	}
+
+	buf, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("cannot encode metadata: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.access.Server+"/v1/encrypted-metadata/"+bucket+"/"+key, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+serialized)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return httpError(resp)
+	}
+
+	return nil
+}
+
 // DeleteObjectMetadata deletes the metadata for an object.
 func (c *MetaSearchClient) DeleteObjectMetadata(ctx context.Context, bucket string, key string) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.access.Server+"/metadata/"+bucket+"/"+key, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.access.Server+"/v1/metadata/"+bucket+"/"+key, nil)
 	if err != nil {
 		return err
 	}
@@ -106,10 +162,11 @@ func (c *MetaSearchClient) DeleteObjectMetadata(ctx context.Context, bucket stri
 	return nil
 }
 
-func (c *MetaSearchClient) SearchMetadata(ctx context.Context, bucket string, prefix string, match map[string]interface{}, filter string, projection string, pageToken string) (result metasearch.SearchResponse, err error) {
+func (c *MetaSearchClient) SearchMetadata(ctx context.Context, bucket string, prefix string, match map[string]interface{}, query string, filter string, projection string, pageToken string) (result metasearch.SearchResponse, err error) {
 	body := metasearch.SearchRequest{
 		KeyPrefix:  prefix,
 		Match:      match,
+		Query:      query,
 		Filter:     filter,
 		Projection: projection,
 		PageToken:  pageToken,
@@ -120,7 +177,7 @@ func (c *MetaSearchClient) SearchMetadata(ctx context.Context, bucket string, pr
 		return result, fmt.Errorf("cannot encode search request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.access.Server+"/metasearch/"+bucket, bytes.NewReader(buf))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.access.Server+"/v1/metasearch/"+bucket, bytes.NewReader(buf))
 	if err != nil {
 		return result, fmt.Errorf("cannot create search request: %w", err)
 	}
@@ -146,6 +203,34 @@ func (c *MetaSearchClient) SearchMetadata(ctx context.Context, bucket string, pr
 	return result, nil
 }
 
+// ImportMetadata imports metadata from a CSV manifest or an S3 inventory report.
+func (c *MetaSearchClient) ImportMetadata(ctx context.Context, bucket string, options url.Values, manifest io.Reader) (result metasearch.ImportResponse, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.access.Server+"/v1/import/"+bucket+"?"+options.Encode(), manifest)
+	if err != nil {
+		return result, fmt.Errorf("cannot create import request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.access.Access)
+	req.Header.Set("Content-Type", "text/csv")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return result, httpError(resp)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return result, fmt.Errorf("cannot decode import response: %w", err)
+	}
+
+	return result, nil
+}
+
 type errorResponse struct {
 	Error string `json:"error"`
 }
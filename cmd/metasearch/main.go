@@ -4,27 +4,37 @@
 package main
 
 import (
+	"context"
+	"embed"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
-
-	_ "embed"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/zeebo/errs"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 
 	_ "github.com/jackc/pgx/v5"        // registers pgx as a tagsql driver.
 	_ "github.com/jackc/pgx/v5/stdlib" // registers pgx as a tagsql driver.
 
 	"storj.io/metasearch/internal/metasearch"
 
+	"storj.io/storj/satellite"
 	"storj.io/storj/satellite/satellitedb"
 	"storj.io/storj/shared/tagsql"
 
 	"storj.io/common/cfgstruct"
 	"storj.io/common/fpath"
 	"storj.io/common/process"
+	"storj.io/common/uuid"
+	"storj.io/uplink"
 )
 
 var (
@@ -48,16 +58,144 @@ var (
 		Short: "Run the metasearch server",
 		RunE:  cmdRun,
 	}
+	seedCmd = &cobra.Command{
+		Use:   "seed",
+		Short: "Generate test objects with metadata in the metabase",
+		RunE:  cmdSeed,
+	}
+	syncCmd = &cobra.Command{
+		Use:   "sync",
+		Short: "Mirror metadata changes into an external search index",
+		RunE:  cmdSync,
+	}
 	confDir string
 
 	runCfg   MetaSearchConf
 	setupCfg MetaSearchConf
+	seedCfg  SeedConf
+	syncCfg  SyncConf
 )
 
 type MetaSearchConf struct {
-	SatelliteDatabaseURL string `help:"URL to connect to the database" default:""`
-	MetabaseURL          string `help:"URL to connect to the metabase" default:""`
-	Endpoint             string `help:"Server endpoint (IP + port)" default:"localhost:9998"`
+	SatelliteDatabaseURL string        `help:"URL to connect to the database" default:""`
+	MetabaseURL          string        `help:"URL to connect to the metabase" default:""`
+	DBMaxOpenConns       int           `help:"Maximum number of open connections to the satellite database (unlimited if 0)" default:"16"`
+	DBMaxIdleConns       int           `help:"Maximum number of idle connections to the satellite database kept open" default:"8"`
+	DBConnLifetime       time.Duration `help:"Duration after which connections to the satellite database are closed (unlimited if 0)" default:"1h"`
+	DBDialTimeout        time.Duration `help:"Timeout of opening a connection to the satellite database (unlimited if 0)" default:"10s"`
+	MetabaseMaxOpenConns int           `help:"Maximum number of open connections to each metabase, including the replica and shadow metabases (unlimited if 0)" default:"128"`
+	MetabaseMaxIdleConns int           `help:"Maximum number of idle connections to each metabase kept open" default:"32"`
+	MetabaseConnLifetime time.Duration `help:"Duration after which connections to the metabases are closed (unlimited if 0)" default:"1h"`
+	MetabaseDialTimeout  time.Duration `help:"Timeout of opening a connection to the metabases (unlimited if 0)" default:"10s"`
+	Endpoint             string        `help:"Server endpoint (IP + port)" default:"localhost:9998"`
+	DRPCEndpoint         string        `help:"DRPC server endpoint (IP + port, the DRPC service is disabled if empty)" default:""`
+	TLSCertFile          string        `help:"PEM file of the TLS certificate, to serve HTTPS (optional)" default:""`
+	TLSKeyFile           string        `help:"PEM file of the TLS key, to serve HTTPS (optional)" default:""`
+	ACMEDomains          string        `help:"Comma separated list of domains to obtain TLS certificates for from an ACME certificate authority (ACME is disabled if empty)" default:""`
+	ACMECacheDir         string        `help:"Directory where the certificates obtained with ACME are stored" default:""`
+	ACMEEmail            string        `help:"Contact email address of the ACME account (optional)" default:""`
+	H2C                  bool          `help:"Accept HTTP/2 over cleartext connections (h2c), for internal meshes without TLS" default:"false"`
+	MaxStreams           uint          `help:"Maximum number of concurrent streams of an HTTP/2 connection" default:"250"`
+	ReadHeaderTimeout    time.Duration `help:"Maximum duration for reading the headers of requests (unlimited if 0)" default:"10s"`
+	ReadTimeout          time.Duration `help:"Maximum duration for reading requests, including their body (unlimited if 0)" default:"5m"`
+	WriteTimeout         time.Duration `help:"Maximum duration for writing responses, extended to the request timeout for streamed responses (unlimited if 0)" default:"5m"`
+	IdleTimeout          time.Duration `help:"Maximum duration idle keep-alive connections are kept open (unlimited if 0)" default:"2m"`
+	RequestTimeout       time.Duration `help:"Deadline of requests, except WebSocket connections, after which searches are canceled (unlimited if 0)" default:"10m"`
+	StatementTimeout     time.Duration `help:"Maximum duration of a metabase call, e.g. a batch of a search, after which it fails with 503 (unlimited if 0)" default:"1m"`
+	SlowQueryThreshold   time.Duration `help:"Duration above which metabase queries are logged with their redacted parameters (disabled if 0)" default:"10s"`
+	SlowQueryExplain     bool          `help:"Capture the plan of slow searches with EXPLAIN ANALYZE, which runs them again" default:"false"`
+	AccessLogSampleRate  float64       `help:"Fraction of the requests logged to the access log, between 0 and 1 (server errors are always logged, disabled if 0)" default:"1"`
+	TrustedProxies       string        `help:"Comma separated list of IP addresses and CIDR ranges of trusted proxies, whose X-Forwarded-For and X-Real-IP headers determine the client IP" default:""`
+	MaxBodySize          int64         `help:"Maximum size of request bodies in bytes, except import manifests (unlimited if 0)" default:"1048576"`
+	ReplicaMetabaseURL   string        `help:"URL of a read-only replica of the metabase serving searches and metadata reads (optional)" default:""`
+	ShadowMetabaseURL    string        `help:"URL of an alternative metabase to run shadow queries against (optional)" default:""`
+	ShadowWrites         bool          `help:"Also write metadata changes to the shadow metabase, to migrate to it without downtime" default:"false"`
+	ObjectsTable         string        `help:"Name of the objects table of the metabase, optionally qualified with its schema (objects if empty)" default:""`
+	PrimaryKeyIndex      string        `help:"Name of the primary key index of the objects table (objects_pkey if empty)" default:""`
+	MetadataIndex        string        `help:"Name of the GIN index of the clear metadata (objects_clear_metadata_idx if empty)" default:""`
+	MetadataLowerIndex   string        `help:"Name of the GIN index of the lower-cased clear metadata (objects_clear_metadata_lower_idx if empty)" default:""`
+	QueuedAtIndex        string        `help:"Name of the index of the objects queued for migration (objects_metasearch_queued_at_idx if empty)" default:""`
+	ReadOnly             bool          `help:"Start in read-only maintenance mode, rejecting mutating requests with 503 (can be switched with the admin API)" default:"false"`
+	AdminToken           string        `help:"Bearer token of the admin API (the admin API is disabled if empty)" default:""`
+	RequireIfMatch       bool          `help:"Reject metadata updates and deletes without an If-Match header" default:"false"`
+	SoftDeleteRetention  time.Duration `help:"Keep deleted metadata for this duration, so that it can be restored (disabled if 0)" default:"0"`
+	PageTokenRetention   time.Duration `help:"Duration search page tokens remain valid, reading the snapshot of the first page (must not exceed the gc.ttlseconds of the objects table, disabled if 0)" default:"1h"`
+	Stateless            bool          `help:"Keep no state in memory across requests, so that requests can be balanced across instances without sticky sessions" default:"false"`
+	SLO                  string        `help:"Comma separated list of endpoint:latency:objective service level objectives, e.g. search:500ms:99.9" default:""`
+	FeatureRollout       string        `help:"Comma separated list of flag:percent pairs, the percentage of projects feature flags are enabled for, e.g. index-fallback:10" default:""`
+	FeatureOverrides     string        `help:"Comma separated list of project-id:flag:on|off feature flag overrides" default:""`
+	FeatureHeader        bool          `help:"Allow overriding feature flags per request with the X-Metasearch-Features header, for internal testing" default:"false"`
+	MaxQueryCost         int           `help:"Reject searches whose estimated cost is higher (unlimited if 0)" default:"1000000"`
+	ExpensiveQueryCost   int           `help:"Estimated cost from which searches are throttled as expensive" default:"100000"`
+	MaxExpensiveQueries  int           `help:"Maximum number of concurrent expensive searches, rejecting the others with 429 (unlimited if 0)" default:"8"`
+	SearchCacheTTL       time.Duration `help:"Duration search responses are cached, invalidated by metadata changes in the bucket (disabled if 0)" default:"0s"`
+	SearchCacheSize      int           `help:"Maximum number of cached search responses" default:"10000"`
+	MetadataCacheTTL     time.Duration `help:"Duration the metadata of objects is cached, invalidated by the changes made by this instance (disabled if 0)" default:"0s"`
+	MetadataCacheSize    int           `help:"Maximum number of objects whose metadata is cached" default:"100000"`
+	MaxRunningRequests   int           `help:"Maximum number of requests served concurrently, queueing the others (unlimited if 0)" default:"512"`
+	MaxQueuedRequests    int           `help:"Maximum number of requests waiting to be served, rejecting the others with 429" default:"1024"`
+	MaxCRUDRequests      int           `help:"Maximum number of concurrent metadata get, update and delete requests, rejecting the others with 429 (unlimited if 0)" default:"256"`
+	MaxSearchRequests    int           `help:"Maximum number of concurrent search requests, rejecting the others with 429 (unlimited if 0)" default:"64"`
+	MaxMigrations        int           `help:"Maximum number of concurrent requests migrating their project in stateless mode, rejecting the others with 429 (unlimited if 0)" default:"8"`
+	WatchInterval        time.Duration `help:"Interval the change feed of buckets watched over WebSocket is polled at" default:"1s"`
+	MaxWatchers          int           `help:"Maximum number of open WebSocket watch connections (unlimited if 0)" default:"1000"`
+	Compression          string        `help:"Comma separated list of content encodings of compressed responses, in order of preference (zstd, gzip, disabled if empty)" default:"zstd,gzip"`
+	JobRetention         time.Duration `help:"Duration search jobs and their results are kept after they are submitted (search jobs are disabled if 0)" default:"24h"`
+	MaxJobs              int           `help:"Maximum number of search jobs running concurrently on a server instance (unlimited if 0)" default:"4"`
+	ScheduleSecret       string        `help:"Secret key encrypting the access grants of scheduled searches (scheduled searches are disabled if empty)" default:""`
+	AllowPrivateWebhooks bool          `help:"Allow the webhooks of scheduled searches on loopback, private and link-local addresses" default:"false"`
+	SearchLinkSecret     string        `help:"Secret key sealing the access grants of search links (search links are disabled if empty)" default:""`
+	SearchLinkMaxTTL     time.Duration `help:"Maximum lifetime of search links" default:"168h"`
+
+	ExtractorURL          string        `help:"URL of a webhook that extracts metadata from the content of objects when they are indexed (disabled if empty)" default:""`
+	ExtractorToken        string        `help:"Bearer token sent to the extractor webhook" default:""`
+	ExtractorSecret       string        `help:"Secret signing the extractor webhook requests, shared with the webhook and never sent (unsigned if empty)" default:""`
+	ExtractorContentTypes string        `help:"Comma separated list of content types sent to the extractor webhook, e.g. image/*" default:"image/*,video/*,audio/*"`
+	ExtractorTimeout      time.Duration `help:"Timeout of extractor webhook requests" default:"10s"`
+
+	PublicBuckets   string  `help:"Comma separated list of bucket:access pairs, whose metadata can be read and searched without authentication" default:""`
+	PublicRateLimit float64 `help:"Maximum number of anonymous requests per second per client for public buckets" default:"5"`
+	PublicRateBurst int     `help:"Maximum burst of anonymous requests per client for public buckets" default:"20"`
+
+	ConsoleAuthTokenSecret string `help:"Auth token secret of the satellite console, to authenticate requests from the web app with console sessions (disabled if empty)" default:""`
+	ConsoleReadOnlyMembers bool   `help:"Only allow project admins to change metadata with console sessions" default:"false"`
+	ConsoleOrigin          string `help:"Origin of the satellite web app allowed to send cross-origin requests, e.g. https://us1.storj.io (disabled if empty)" default:""`
+
+	Warmup            bool          `help:"Open metabase connections and preload recently used API keys before serving requests" default:"false"`
+	WarmupConnections int           `help:"Number of metabase connections opened by the warm-up, at most --metabase-max-idle-conns" default:"10"`
+	WarmupTimeout     time.Duration `help:"Maximum duration of the warm-up" default:"30s"`
+	APIKeysFile       string        `help:"File where the heads of recently used API keys are saved, to preload them on the next warm-up (optional)" default:""`
+
+	Dev bool `help:"Run a development server keeping metadata in memory, without satellite database and metabase, authenticating all requests (never use in production)" default:"false"`
+}
+
+type SeedConf struct {
+	MetabaseURL   string `help:"URL to connect to the metabase" default:""`
+	ProjectID     string `help:"Project ID of the generated objects" default:""`
+	Bucket        string `help:"Bucket name of the generated objects" default:"seed"`
+	Prefix        string `help:"Object key prefix of the generated objects" default:""`
+	Access        string `help:"Access grant to encrypt paths and metadata with (optional)" default:""`
+	Objects       int    `help:"Number of objects to generate" default:"10000"`
+	Directories   int    `help:"Number of subdirectories to spread the objects in" default:"10"`
+	Keys          int    `help:"Number of distinct metadata keys" default:"20"`
+	KeysPerObject int    `help:"Number of metadata keys per object" default:"5"`
+	Values        int    `help:"Number of distinct values per metadata key" default:"100"`
+	Depth         int    `help:"Nesting depth of metadata values" default:"1"`
+	Distribution  string `help:"Distribution of keys and values (uniform or zipf)" default:"zipf"`
+	Seed          int64  `help:"Seed of the random generator" default:"1"`
+}
+
+type SyncConf struct {
+	MetabaseURL string        `help:"URL to connect to the metabase" default:""`
+	Index       string        `help:"Kind of the external search index (elasticsearch or meilisearch)" default:"elasticsearch"`
+	IndexURL    string        `help:"URL of the external search index" default:""`
+	IndexName   string        `help:"Name of the index the metadata is mirrored into" default:"metasearch"`
+	IndexToken  string        `help:"API key of the external search index (optional)" default:""`
+	Name        string        `help:"Name of the sync, whose position in the change feed is saved in the metabase (defaults to the index name)" default:""`
+	Interval    time.Duration `help:"Interval the change feed is polled at" default:"5s"`
+	BatchSize   int           `help:"Number of changes mirrored per index request" default:"500"`
+	Buckets     int           `help:"Number of changed buckets synced per poll" default:"100"`
+	Timeout     time.Duration `help:"Timeout of index requests" default:"30s"`
 }
 
 func cmdSetup(cmd *cobra.Command, args []string) (err error) {
@@ -91,17 +229,299 @@ func cmdRun(cmd *cobra.Command, args []string) (err error) {
 	ctx, _ := process.Ctx(cmd)
 	log := zap.L()
 
-	db, err := satellitedb.Open(ctx, log.Named("db"), runCfg.SatelliteDatabaseURL, satellitedb.Options{
-		ApplicationName: "metadata-api",
+	var (
+		db         satellite.DB
+		metadb     tagsql.DB
+		metabase   *metasearch.MetabaseSearchRepository
+		headerAuth *metasearch.HeaderAuth
+		repo       metasearch.MetaSearchRepo
+		auth       metasearch.Authenticator
+	)
+	if runCfg.Dev {
+		if runCfg.ConsoleAuthTokenSecret != "" {
+			return errs.New("console sessions cannot be authenticated in development mode")
+		}
+		log.Warn("running in development mode: metadata is kept in memory and requests are not authenticated")
+		repo = metasearch.NewMemoryRepository()
+		auth = metasearch.NewDevAuth()
+	} else {
+		dbPool := metasearch.PoolConfig{
+			MaxOpenConns:    runCfg.DBMaxOpenConns,
+			MaxIdleConns:    runCfg.DBMaxIdleConns,
+			ConnMaxLifetime: runCfg.DBConnLifetime,
+			DialTimeout:     runCfg.DBDialTimeout,
+		}
+		if err = configureSatellitePool(dbPool); err != nil {
+			return errs.New("invalid satellite database pool: %+v", err)
+		}
+		var dbURL string
+		dbURL, err = dbPool.DialURL(runCfg.SatelliteDatabaseURL)
+		if err != nil {
+			return errs.New("invalid satellite database URL: %+v", err)
+		}
+		db, err = satellitedb.Open(ctx, log.Named("db"), dbURL, satellitedb.Options{
+			ApplicationName: "metadata-api",
+		})
+		if err != nil {
+			return errs.New("Error creating satellite database connection: %+v", err)
+		}
+		defer func() {
+			err = errs.Combine(err, db.Close())
+		}()
+
+		metabasePool := metasearch.PoolConfig{
+			MaxOpenConns:    runCfg.MetabaseMaxOpenConns,
+			MaxIdleConns:    runCfg.MetabaseMaxIdleConns,
+			ConnMaxLifetime: runCfg.MetabaseConnLifetime,
+			DialTimeout:     runCfg.MetabaseDialTimeout,
+		}
+		slowQueries := metasearch.SlowQueryConfig{
+			Threshold: runCfg.SlowQueryThreshold,
+			Explain:   runCfg.SlowQueryExplain,
+		}
+		var dialect metasearch.Dialect
+		metadb, dialect, err = openMetabase(ctx, runCfg.MetabaseURL, metabasePool, slowQueries, log)
+		if err != nil {
+			return errs.New("failed to connect to metabase db: %+v", err)
+		}
+		defer func() {
+			err = errs.Combine(err, metadb.Close())
+		}()
+
+		tables := metasearch.MetabaseTables{
+			Objects:            runCfg.ObjectsTable,
+			PrimaryKey:         runCfg.PrimaryKeyIndex,
+			MetadataIndex:      runCfg.MetadataIndex,
+			MetadataLowerIndex: runCfg.MetadataLowerIndex,
+			QueuedAtIndex:      runCfg.QueuedAtIndex,
+		}
+		if err = tables.Validate(); err != nil {
+			return errs.New("invalid metabase tables: %+v", err)
+		}
+
+		metabase = metasearch.NewMetabaseSearchRepository(metadb, dialect, tables, log)
+		repo = metabase
+		if runCfg.ReplicaMetabaseURL != "" {
+			var replicadb tagsql.DB
+			var replicaDialect metasearch.Dialect
+			replicadb, replicaDialect, err = openMetabase(ctx, runCfg.ReplicaMetabaseURL, metabasePool, slowQueries, log.Named("replica"))
+			if err != nil {
+				return errs.New("failed to connect to replica metabase db: %+v", err)
+			}
+			defer func() {
+				err = errs.Combine(err, replicadb.Close())
+			}()
+
+			log.Info("serving reads from the replica metabase")
+			replica := metasearch.NewMetabaseSearchRepository(replicadb, replicaDialect, tables, log.Named("replica"))
+			repo = metasearch.NewReplicaSearchRepository(repo, replica)
+		}
+		if runCfg.ShadowMetabaseURL != "" {
+			var shadowdb tagsql.DB
+			var shadowDialect metasearch.Dialect
+			shadowdb, shadowDialect, err = openMetabase(ctx, runCfg.ShadowMetabaseURL, metabasePool, slowQueries, log.Named("shadow"))
+			if err != nil {
+				return errs.New("failed to connect to shadow metabase db: %+v", err)
+			}
+			defer func() {
+				err = errs.Combine(err, shadowdb.Close())
+			}()
+
+			shadow := metasearch.NewMetabaseSearchRepository(shadowdb, shadowDialect, tables, log.Named("shadow"))
+			if runCfg.ShadowWrites {
+				log.Info("writing to the shadow metabase and running shadow queries")
+				repo = metasearch.NewDualWriteRepository(repo, shadow, log)
+			} else {
+				log.Info("running shadow queries")
+				repo = metasearch.NewShadowSearchRepository(repo, shadow, log)
+			}
+		}
+
+		headerAuth = metasearch.NewHeaderAuth(db)
+		auth = headerAuth
+	}
+
+	repo = metasearch.NewMetricsRepository(repo)
+
+	if runCfg.PublicBuckets != "" {
+		publicBuckets, err := metasearch.ParsePublicBuckets(runCfg.PublicBuckets)
+		if err != nil {
+			return errs.New("invalid public buckets: %+v", err)
+		}
+		auth = metasearch.NewPublicBucketAuth(auth, publicBuckets, rate.Limit(runCfg.PublicRateLimit), runCfg.PublicRateBurst)
+	}
+	if runCfg.ConsoleAuthTokenSecret != "" {
+		auth = metasearch.NewConsoleSessionAuth(auth, db, runCfg.ConsoleAuthTokenSecret, runCfg.ConsoleReadOnlyMembers)
+	}
+
+	slos, err := metasearch.ParseSLOs(runCfg.SLO)
+	if err != nil {
+		return errs.New("invalid SLOs: %+v", err)
+	}
+
+	featureRollout, err := metasearch.ParseFeatureRollout(runCfg.FeatureRollout)
+	if err != nil {
+		return errs.New("invalid feature rollout: %+v", err)
+	}
+	featureOverrides, err := metasearch.ParseFeatureOverrides(runCfg.FeatureOverrides)
+	if err != nil {
+		return errs.New("invalid feature overrides: %+v", err)
+	}
+
+	compression, err := metasearch.ParseCompression(runCfg.Compression)
+	if err != nil {
+		return errs.New("invalid compression: %+v", err)
+	}
+
+	var acmeDomains []string
+	for _, domain := range strings.Split(runCfg.ACMEDomains, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			acmeDomains = append(acmeDomains, domain)
+		}
+	}
+
+	var trustedProxies []string
+	for _, proxy := range strings.Split(runCfg.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+
+	metadataAPI, err := metasearch.NewServer(log, repo, auth, metasearch.ServerConfig{
+		Endpoint:            runCfg.Endpoint,
+		DRPCEndpoint:        runCfg.DRPCEndpoint,
+		TLSCertFile:         runCfg.TLSCertFile,
+		TLSKeyFile:          runCfg.TLSKeyFile,
+		ACMEDomains:         acmeDomains,
+		ACMECacheDir:        runCfg.ACMECacheDir,
+		ACMEEmail:           runCfg.ACMEEmail,
+		H2C:                 runCfg.H2C,
+		MaxStreams:          uint32(runCfg.MaxStreams),
+		ReadHeaderTimeout:   runCfg.ReadHeaderTimeout,
+		ReadTimeout:         runCfg.ReadTimeout,
+		WriteTimeout:        runCfg.WriteTimeout,
+		IdleTimeout:         runCfg.IdleTimeout,
+		RequestTimeout:      runCfg.RequestTimeout,
+		StatementTimeout:    runCfg.StatementTimeout,
+		AccessLogSampleRate: runCfg.AccessLogSampleRate,
+		TrustedProxies:      trustedProxies,
+		MaxBodySize:         runCfg.MaxBodySize,
+		ReadOnly:            runCfg.ReadOnly,
+		AdminToken:          runCfg.AdminToken,
+		RequireIfMatch:      runCfg.RequireIfMatch,
+		SoftDeleteRetention: runCfg.SoftDeleteRetention,
+		PageTokenRetention:  runCfg.PageTokenRetention,
+		Stateless:           runCfg.Stateless,
+		SLOs:                slos,
+		FeatureRollout:      featureRollout,
+		FeatureOverrides:    featureOverrides,
+		FeatureHeader:       runCfg.FeatureHeader,
+		MaxQueryCost:        runCfg.MaxQueryCost,
+		ExpensiveQueryCost:  runCfg.ExpensiveQueryCost,
+		MaxExpensiveQueries: runCfg.MaxExpensiveQueries,
+		SearchCacheTTL:      runCfg.SearchCacheTTL,
+		SearchCacheSize:     runCfg.SearchCacheSize,
+		MetadataCacheTTL:    runCfg.MetadataCacheTTL,
+		MetadataCacheSize:   runCfg.MetadataCacheSize,
+		MaxRunningRequests:  runCfg.MaxRunningRequests,
+		MaxQueuedRequests:   runCfg.MaxQueuedRequests,
+		MaxCRUDRequests:     runCfg.MaxCRUDRequests,
+		MaxSearchRequests:   runCfg.MaxSearchRequests,
+		MaxMigrations:       runCfg.MaxMigrations,
+		WatchInterval:       runCfg.WatchInterval,
+		MaxWatchers:         runCfg.MaxWatchers,
+		Compression:         compression,
+		JobRetention:        runCfg.JobRetention,
+		MaxJobs:             runCfg.MaxJobs,
+		ScheduleSecret:      runCfg.ScheduleSecret,
+		PrivateWebhooks:     runCfg.AllowPrivateWebhooks,
+		SearchLinkSecret:    runCfg.SearchLinkSecret,
+		SearchLinkMaxTTL:    runCfg.SearchLinkMaxTTL,
+		ConsoleOrigin:       runCfg.ConsoleOrigin,
 	})
 	if err != nil {
-		return errs.New("Error creating satellite database connection: %+v", err)
+		return errs.New("Error creating metasearch server: %+v", err)
 	}
-	defer func() {
-		err = errs.Combine(err, db.Close())
-	}()
 
-	metadb, err := tagsql.Open(ctx, "cockroach", runCfg.MetabaseURL)
+	if runCfg.ExtractorURL != "" {
+		contentTypes := strings.Split(runCfg.ExtractorContentTypes, ",")
+		for i := range contentTypes {
+			contentTypes[i] = strings.TrimSpace(contentTypes[i])
+		}
+		metadataAPI.Migrator.SetExtractor(metasearch.NewWebhookExtractor(runCfg.ExtractorURL, runCfg.ExtractorToken, runCfg.ExtractorSecret, contentTypes, runCfg.ExtractorTimeout))
+	}
+
+	if runCfg.Warmup && !runCfg.Dev {
+		warmup(ctx, log, metabase, headerAuth)
+	}
+	if runCfg.APIKeysFile != "" && !runCfg.Dev {
+		go headerAuth.SaveRecentAPIKeys(ctx, log, runCfg.APIKeysFile, time.Minute)
+	}
+
+	return metadataAPI.Run()
+}
+
+// warmup prepares the metabase connections and the API key cache for the
+// first requests. Failures are logged, the server starts anyway.
+func warmup(ctx context.Context, log *zap.Logger, metabase *metasearch.MetabaseSearchRepository, headerAuth *metasearch.HeaderAuth) {
+	ctx, cancel := context.WithTimeout(ctx, runCfg.WarmupTimeout)
+	defer cancel()
+	start := time.Now()
+
+	// Connections beyond the idle limit of the pool would be closed again
+	connections := min(runCfg.WarmupConnections, runCfg.MetabaseMaxIdleConns)
+	if err := metabase.Warmup(ctx, connections); err != nil {
+		log.Warn("metabase warm-up failed", zap.Error(err))
+	}
+
+	if runCfg.APIKeysFile != "" {
+		loaded, err := headerAuth.PreloadAPIKeys(ctx, runCfg.APIKeysFile)
+		if err != nil {
+			log.Warn("cannot preload all API keys", zap.Error(err))
+		}
+		log.Info("preloaded API keys", zap.Int("Keys", loaded))
+	}
+
+	log.Info("warm-up finished", zap.Duration("Duration", time.Since(start)))
+}
+
+// openMetabase opens the metabase at url with the given connection pool, and
+// logs its slow queries.
+func openMetabase(ctx context.Context, url string, pool metasearch.PoolConfig, slowQueries metasearch.SlowQueryConfig, log *zap.Logger) (tagsql.DB, metasearch.Dialect, error) {
+	url, err := pool.DialURL(url)
+	if err != nil {
+		return nil, 0, err
+	}
+	metadb, dialect, err := metasearch.OpenMetabase(ctx, url)
+	if err != nil {
+		return nil, 0, err
+	}
+	pool.Configure(metadb)
+	return metasearch.LogSlowQueries(metadb, log.Named("slowquery"), slowQueries), dialect, nil
+}
+
+// configureSatellitePool sets the connection pool of the satellite database.
+// satellitedb.Open configures its pool with the global db.* flags of dbutil,
+// so they are set before it is opened.
+func configureSatellitePool(pool metasearch.PoolConfig) error {
+	return errors.Join(
+		flag.Set("db.max_open_conns", strconv.Itoa(pool.MaxOpenConns)),
+		flag.Set("db.max_idle_conns", strconv.Itoa(pool.MaxIdleConns)),
+		flag.Set("db.conn_max_lifetime", pool.ConnMaxLifetime.String()),
+	)
+}
+
+// Migrations of CockroachDB metabases are in migration/, and migrations of
+// PostgreSQL metabases in migration/postgres/.
+//
+//go:embed migration/*.sql migration/postgres/*.sql
+var migrations embed.FS
+
+func cmdMigrate(cmd *cobra.Command, args []string) (err error) {
+	ctx, _ := process.Ctx(cmd)
+	log := zap.L()
+
+	metadb, dialect, err := metasearch.OpenMetabase(ctx, runCfg.MetabaseURL)
 	if err != nil {
 		return errs.New("failed to connect to metabase db: %+v", err)
 	}
@@ -109,24 +529,52 @@ func cmdRun(cmd *cobra.Command, args []string) (err error) {
 		err = errs.Combine(err, metadb.Close())
 	}()
 
-	repo := metasearch.NewMetabaseSearchRepository(metadb, log)
-	auth := metasearch.NewHeaderAuth(db)
-	metadataAPI, err := metasearch.NewServer(log, repo, auth, runCfg.Endpoint)
+	dir := "migration"
+	if dialect == metasearch.PostgreSQL {
+		dir = "migration/postgres"
+	}
+
+	// Migration files are idempotent, they are applied in lexical order.
+	files, err := fs.Glob(migrations, dir+"/*.sql")
 	if err != nil {
-		return errs.New("Error creating metasearch server: %+v", err)
+		return errs.New("cannot list migrations: %+v", err)
 	}
 
-	return metadataAPI.Run()
-}
+	for _, file := range files {
+		migrateSql, err := fs.ReadFile(migrations, file)
+		if err != nil {
+			return errs.New("cannot read migration %s: %+v", file, err)
+		}
 
-//go:embed migration/001.objects.clear_metadata_metasearch_queued_at.sql
-var migrateSql string
+		log.Info("running database migration", zap.String("Migration", file))
+		_, err = metadb.ExecContext(ctx, string(migrateSql))
+		if err != nil {
+			log.Error("database migration failed", zap.String("Migration", file), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
 
-func cmdMigrate(cmd *cobra.Command, args []string) (err error) {
+func cmdSeed(cmd *cobra.Command, args []string) (err error) {
 	ctx, _ := process.Ctx(cmd)
 	log := zap.L()
 
-	metadb, err := tagsql.Open(ctx, "cockroach", runCfg.MetabaseURL)
+	projectID, err := uuid.FromString(seedCfg.ProjectID)
+	if err != nil {
+		return errs.New("invalid project ID: %+v", err)
+	}
+
+	var encryptor metasearch.Encryptor
+	if seedCfg.Access != "" {
+		access, err := uplink.ParseAccess(seedCfg.Access)
+		if err != nil {
+			return errs.New("invalid access grant: %+v", err)
+		}
+		encryptor = metasearch.NewUplinkEncryptor(access)
+	}
+
+	metadb, _, err := metasearch.OpenMetabase(ctx, seedCfg.MetabaseURL)
 	if err != nil {
 		return errs.New("failed to connect to metabase db: %+v", err)
 	}
@@ -134,12 +582,56 @@ func cmdMigrate(cmd *cobra.Command, args []string) (err error) {
 		err = errs.Combine(err, metadb.Close())
 	}()
 
-	log.Info("running database migrations")
-	_, err = metadb.ExecContext(ctx, migrateSql)
+	config := metasearch.SeedConfig{
+		ProjectID:     projectID,
+		Bucket:        seedCfg.Bucket,
+		Prefix:        seedCfg.Prefix,
+		Objects:       seedCfg.Objects,
+		Directories:   seedCfg.Directories,
+		Keys:          seedCfg.Keys,
+		KeysPerObject: seedCfg.KeysPerObject,
+		Values:        seedCfg.Values,
+		Depth:         seedCfg.Depth,
+		Distribution:  seedCfg.Distribution,
+		Seed:          seedCfg.Seed,
+	}
+
+	log.Info("generating objects", zap.Stringer("Project", projectID), zap.String("Bucket", config.Bucket), zap.Int("Objects", config.Objects))
+	inserted, err := metasearch.NewSeeder(metadb, log).Seed(ctx, config, encryptor)
+	log.Info("inserted objects", zap.Int("Inserted", inserted))
+	return err
+}
+
+func cmdSync(cmd *cobra.Command, args []string) (err error) {
+	ctx, _ := process.Ctx(cmd)
+	log := zap.L()
+
+	index, err := metasearch.NewSearchIndex(syncCfg.Index, syncCfg.IndexURL, syncCfg.IndexName, syncCfg.IndexToken, syncCfg.Timeout)
+	if err != nil {
+		return errs.New("invalid search index: %+v", err)
+	}
+
+	metadb, dialect, err := metasearch.OpenMetabase(ctx, syncCfg.MetabaseURL)
 	if err != nil {
-		log.Error("database migration failed", zap.Error(err))
+		return errs.New("failed to connect to metabase db: %+v", err)
 	}
-	return
+	defer func() {
+		err = errs.Combine(err, metadb.Close())
+	}()
+
+	name := syncCfg.Name
+	if name == "" {
+		name = syncCfg.IndexName
+	}
+
+	log.Info("syncing search index", zap.String("Index", syncCfg.Index), zap.String("Name", name))
+	repo := metasearch.NewMetabaseSearchRepository(metadb, dialect, metasearch.MetabaseTables{}, log)
+	return metasearch.NewIndexSyncer(repo, index, log, metasearch.IndexSyncConfig{
+		Name:      name,
+		Interval:  syncCfg.Interval,
+		BatchSize: syncCfg.BatchSize,
+		Buckets:   syncCfg.Buckets,
+	}).Run(ctx)
 }
 
 func init() {
@@ -149,8 +641,12 @@ func init() {
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(setupCmd)
 	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(seedCmd)
+	rootCmd.AddCommand(syncCmd)
 	process.Bind(runCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir))
 	process.Bind(migrateCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir))
+	process.Bind(seedCmd, &seedCfg, defaults, cfgstruct.ConfDir(confDir))
+	process.Bind(syncCmd, &syncCfg, defaults, cfgstruct.ConfDir(confDir))
 	process.Bind(setupCmd, &setupCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.SetupMode())
 }
 
@@ -0,0 +1,7 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+ALTER TABLE objects ADD COLUMN IF NOT EXISTS metasearch_updated_at TIMESTAMP;
+COMMENT ON COLUMN objects.metasearch_updated_at is 'metasearch_updated_at is the time of the last metadata change made by metasearch.';
+
+COMMIT;
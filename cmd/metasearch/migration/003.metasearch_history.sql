@@ -0,0 +1,18 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+CREATE TABLE IF NOT EXISTS metasearch_history (
+    project_id BYTEA NOT NULL,
+    bucket_name BYTEA NOT NULL,
+    object_key BYTEA NOT NULL,
+    revision INT8 NOT NULL DEFAULT unique_rowid(),
+    version INT8 NOT NULL,
+    actor TEXT NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT now(),
+    old_clear_metadata JSONB,
+    new_clear_metadata JSONB,
+    PRIMARY KEY (project_id, bucket_name, object_key, revision)
+);
+COMMENT ON TABLE metasearch_history is 'metasearch_history contains the changes of clear metadata made by metasearch.';
+
+COMMIT;
@@ -0,0 +1,13 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+CREATE TABLE IF NOT EXISTS metasearch_watermarks (
+    project_id BYTEA NOT NULL,
+    bucket_name BYTEA NOT NULL,
+    watermark INT8 NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (project_id, bucket_name)
+);
+COMMENT ON TABLE metasearch_watermarks is 'metasearch_watermarks contains a counter of the metadata changes per bucket.';
+
+COMMIT;
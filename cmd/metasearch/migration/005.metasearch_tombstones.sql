@@ -0,0 +1,22 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+CREATE TABLE IF NOT EXISTS metasearch_tombstones (
+    project_id BYTEA NOT NULL,
+    bucket_name BYTEA NOT NULL,
+    object_key BYTEA NOT NULL,
+    version INT8 NOT NULL,
+    encrypted_metadata_nonce BYTEA,
+    encrypted_metadata BYTEA,
+    encrypted_metadata_encrypted_key BYTEA,
+    clear_metadata JSONB,
+    deleted_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (project_id, bucket_name, object_key)
+);
+COMMENT ON TABLE metasearch_tombstones is 'metasearch_tombstones contains soft deleted metadata, which can be restored until the retention period expires.';
+
+COMMIT;
+
+CREATE INDEX IF NOT EXISTS metasearch_tombstones_deleted_at_idx ON metasearch_tombstones (deleted_at);
+
+COMMIT;
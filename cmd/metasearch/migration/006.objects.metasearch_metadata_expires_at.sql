@@ -0,0 +1,13 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+ALTER TABLE objects ADD COLUMN IF NOT EXISTS metasearch_metadata_expires_at TIMESTAMP;
+COMMENT ON COLUMN objects.metasearch_metadata_expires_at is 'metasearch_metadata_expires_at is the time after which metasearch deletes the metadata of the object.';
+
+COMMIT;
+
+CREATE INDEX IF NOT EXISTS objects_metasearch_metadata_expires_at_idx ON objects (
+    metasearch_metadata_expires_at
+) WHERE metasearch_metadata_expires_at IS NOT NULL;
+
+COMMIT;
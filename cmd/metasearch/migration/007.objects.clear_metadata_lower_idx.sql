@@ -0,0 +1,6 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+CREATE INDEX IF NOT EXISTS objects_clear_metadata_lower_idx ON objects USING GIN ((lower(clear_metadata::STRING)::JSONB));
+
+COMMIT;
@@ -0,0 +1,13 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+CREATE TABLE IF NOT EXISTS metasearch_vocabularies (
+    project_id BYTEA NOT NULL,
+    metadata_key STRING NOT NULL,
+    vocabulary JSONB NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (project_id, metadata_key)
+);
+COMMENT ON TABLE metasearch_vocabularies is 'metasearch_vocabularies contains the allowed values of clear metadata keys per project.';
+
+COMMIT;
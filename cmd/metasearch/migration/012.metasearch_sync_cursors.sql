@@ -0,0 +1,15 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+CREATE TABLE IF NOT EXISTS metasearch_sync_cursors (
+    sync_name STRING NOT NULL,
+    project_id BYTEA NOT NULL,
+    bucket_name BYTEA NOT NULL,
+    watermark INT8 NOT NULL,
+    revision INT8 NOT NULL,
+    synced_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (sync_name, project_id, bucket_name)
+);
+COMMENT ON TABLE metasearch_sync_cursors is 'metasearch_sync_cursors contains the position of external index syncs in the change feed of buckets.';
+
+COMMIT;
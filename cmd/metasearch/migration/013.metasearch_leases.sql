@@ -0,0 +1,12 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+CREATE TABLE IF NOT EXISTS metasearch_leases (
+    name STRING NOT NULL,
+    holder BYTEA NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (name)
+);
+COMMENT ON TABLE metasearch_leases is 'metasearch_leases contains the leases of tasks run by a single server instance at a time, like index rebuilds.';
+
+COMMIT;
@@ -0,0 +1,22 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+ALTER TABLE objects ADD COLUMN IF NOT EXISTS metasearch_updated_at TIMESTAMP;
+COMMENT ON COLUMN objects.metasearch_updated_at is 'metasearch_updated_at is the time of the last metadata change made by metasearch.';
+
+-- PostgreSQL has no ON UPDATE columns: objects updated by the satellite are
+-- queued for migration by a trigger. Updates made by metasearch set
+-- metasearch_updated_at, and are not queued.
+CREATE OR REPLACE FUNCTION metasearch_queue_object() RETURNS trigger AS $$
+BEGIN
+    IF NEW.metasearch_updated_at IS NOT DISTINCT FROM OLD.metasearch_updated_at THEN
+        NEW.metasearch_queued_at = current_timestamp;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS objects_metasearch_queued_at ON objects;
+CREATE TRIGGER objects_metasearch_queued_at
+    BEFORE UPDATE ON objects
+    FOR EACH ROW EXECUTE FUNCTION metasearch_queue_object();
@@ -0,0 +1,4 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+CREATE INDEX IF NOT EXISTS objects_clear_metadata_lower_idx ON objects USING GIN ((lower(clear_metadata::TEXT)::JSONB));
@@ -0,0 +1,9 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+ALTER TABLE metasearch_history ADD COLUMN IF NOT EXISTS watermark INT8;
+COMMENT ON COLUMN metasearch_history.watermark is 'watermark is the change watermark of the bucket after the change.';
+
+CREATE INDEX IF NOT EXISTS metasearch_history_watermark_idx ON metasearch_history (
+    project_id, bucket_name, watermark, revision
+) WHERE watermark IS NOT NULL;
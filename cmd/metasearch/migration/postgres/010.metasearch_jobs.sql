@@ -0,0 +1,32 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+CREATE TABLE IF NOT EXISTS metasearch_jobs (
+    id BYTEA NOT NULL,
+    project_id BYTEA NOT NULL,
+    bucket_name BYTEA NOT NULL,
+    owner TEXT NOT NULL,
+    request JSONB NOT NULL,
+    state TEXT NOT NULL,
+    page_token TEXT NOT NULL DEFAULT '',
+    chunks INT8 NOT NULL DEFAULT 0,
+    results INT8 NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    attempt INT8 NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    lease_expires_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (id)
+);
+COMMENT ON TABLE metasearch_jobs is 'metasearch_jobs contains asynchronous search jobs and their progress, so that they survive server restarts.';
+
+CREATE TABLE IF NOT EXISTS metasearch_job_results (
+    job_id BYTEA NOT NULL,
+    chunk INT8 NOT NULL,
+    results BYTEA NOT NULL,
+    PRIMARY KEY (job_id, chunk)
+);
+COMMENT ON TABLE metasearch_job_results is 'metasearch_job_results contains the results of asynchronous search jobs as chunks of newline delimited JSON.';
+
+CREATE INDEX IF NOT EXISTS metasearch_jobs_expires_at_idx ON metasearch_jobs (expires_at);
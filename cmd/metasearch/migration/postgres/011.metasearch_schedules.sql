@@ -0,0 +1,25 @@
+-- Copyright (C) 2025 Storj Labs, Inc.
+-- See LICENSE for copying information.
+
+CREATE TABLE IF NOT EXISTS metasearch_schedules (
+    id BYTEA NOT NULL,
+    project_id BYTEA NOT NULL,
+    bucket_name BYTEA NOT NULL,
+    owner TEXT NOT NULL,
+    name TEXT NOT NULL,
+    cron TEXT NOT NULL,
+    request JSONB NOT NULL,
+    url TEXT NOT NULL,
+    credentials BYTEA NOT NULL,
+    secret TEXT NOT NULL,
+    next_run_at TIMESTAMP NOT NULL,
+    last_run_at TIMESTAMP,
+    last_status TEXT NOT NULL DEFAULT '',
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (id)
+);
+COMMENT ON TABLE metasearch_schedules is 'metasearch_schedules contains recurring searches whose results are posted to a webhook.';
+
+CREATE INDEX IF NOT EXISTS metasearch_schedules_next_run_at_idx ON metasearch_schedules (next_run_at);
+CREATE INDEX IF NOT EXISTS metasearch_schedules_project_id_bucket_name_idx ON metasearch_schedules (project_id, bucket_name);
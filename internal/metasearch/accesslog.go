@@ -0,0 +1,88 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+)
+
+type accessKey struct{}
+
+// accessRecord holds the fields of the access log line of a request that are
+// known once the request is authenticated or its search has run.
+type accessRecord struct {
+	projectID uuid.UUID
+	results   atomic.Int64
+	searched  atomic.Bool
+}
+
+// logAccess emits one structured access log line per request, for the
+// fraction AccessLogSampleRate of the requests. Server errors are always
+// logged.
+func (s *Server) logAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Config.AccessLogSampleRate <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		record := &accessRecord{}
+		rec := &statusResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessKey{}, record)))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		if rec.status < http.StatusInternalServerError && rand.Float64() >= s.Config.AccessLogSampleRate {
+			return
+		}
+
+		fields := []zap.Field{
+			zap.String("Method", r.Method),
+			zap.String("Path", r.URL.Path),
+			zap.Int("Status", rec.status),
+			zap.Duration("Latency", time.Since(start)),
+			zap.Int64("Bytes", rec.size),
+			zap.String("ClientIP", clientIP(r)),
+		}
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() != "" {
+			fields = append(fields, zap.String("Route", route.GetName()))
+		}
+		if !record.projectID.IsZero() {
+			fields = append(fields, zap.Stringer("Project", record.projectID))
+		}
+		if record.searched.Load() {
+			fields = append(fields, zap.Int64("Results", record.results.Load()))
+		}
+		s.Logger.Named("access").Info("request", fields...)
+	})
+}
+
+// recordAccessProject records the project of an authenticated request in its
+// access log line.
+func recordAccessProject(ctx context.Context, projectID uuid.UUID) {
+	if record, ok := ctx.Value(accessKey{}).(*accessRecord); ok {
+		record.projectID = projectID
+	}
+}
+
+// recordAccessResults adds the number of results of a search to the access
+// log line of its request. Searches fetching several pages add the results
+// of each page.
+func recordAccessResults(ctx context.Context, results int) {
+	if record, ok := ctx.Value(accessKey{}).(*accessRecord); ok {
+		record.searched.Store(true)
+		record.results.Add(int64(results))
+	}
+}
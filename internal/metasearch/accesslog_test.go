@@ -0,0 +1,62 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	server, err := NewServer(zap.New(core), newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		AccessLogSampleRate: 1,
+	})
+	require.NoError(t, err)
+
+	accessLogs := func() []observer.LoggedEntry {
+		return logs.FilterLoggerName("access").TakeAll()
+	}
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	entries := accessLogs()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	require.Equal(t, http.MethodPut, fields["Method"])
+	require.Equal(t, "/v1/metadata/testbucket/foo.txt", fields["Path"])
+	require.Equal(t, "update", fields["Route"])
+	require.Equal(t, int64(http.StatusNoContent), fields["Status"])
+	require.Equal(t, testProjectID, fields["Project"])
+	require.Contains(t, fields, "Latency")
+	require.NotContains(t, fields, "Results")
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	entries = accessLogs()
+	require.Len(t, entries, 1)
+	fields = entries[0].ContextMap()
+	require.Equal(t, int64(1), fields["Results"])
+	require.Equal(t, int64(rr.Body.Len()), fields["Bytes"])
+
+	// Unsampled requests are not logged, except server errors
+	server.Config.AccessLogSampleRate = 1e-9
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, accessLogs())
+
+	testRepo(server).queryDelay = time.Hour
+	server.Config.RequestTimeout = 50 * time.Millisecond
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{}`)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.Len(t, accessLogs(), 1)
+}
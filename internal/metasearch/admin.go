@@ -0,0 +1,55 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"storj.io/common/uuid"
+)
+
+// adminAuth authenticates requests to the admin API with the configured admin
+// token. The admin API is disabled if no admin token is configured.
+func (s *Server) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Config.AdminToken == "" {
+			s.errorResponse(w, fmt.Errorf("%w: admin API is disabled", ErrNotFound))
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.AdminToken)) != 1 {
+			s.errorResponse(w, fmt.Errorf("%w: invalid admin token", ErrAuthorizationFailed))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleAdminGrants lists the fingerprints of the access grants used per
+// project. The list can be filtered with the projectId query parameter.
+func (s *Server) HandleAdminGrants(w http.ResponseWriter, r *http.Request) {
+	if s.Config.Stateless {
+		s.errorResponse(w, fmt.Errorf("%w: grant tracking is not available in stateless mode", ErrNotFound))
+		return
+	}
+
+	var projectID uuid.UUID
+	if id := r.URL.Query().Get("projectId"); id != "" {
+		var err error
+		projectID, err = uuid.FromString(id)
+		if err != nil {
+			s.errorResponse(w, fmt.Errorf("%w: invalid projectId", ErrBadRequest))
+			return
+		}
+	}
+
+	s.jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"projects": s.Grants.Grants(projectID),
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// admissionController bounds the number of requests served concurrently.
+// Requests over the limit wait in a bounded queue, and are rejected when the
+// queue is full, instead of piling up until they time out against the
+// metabase.
+type admissionController struct {
+	running chan struct{}
+	queued  chan struct{}
+}
+
+// newAdmissionController creates an admission controller serving up to
+// maxRunning requests concurrently, with up to maxQueued waiting requests. It
+// returns nil if maxRunning is not positive.
+func newAdmissionController(maxRunning, maxQueued int) *admissionController {
+	if maxRunning <= 0 {
+		return nil
+	}
+	return &admissionController{
+		running: make(chan struct{}, maxRunning),
+		queued:  make(chan struct{}, max(maxQueued, 0)),
+	}
+}
+
+// admit waits until a request can be served, while there is room in the
+// queue and ctx is not done. The returned function releases the slot of the
+// request.
+func (a *admissionController) admit(ctx context.Context) (release func(), err error) {
+	release = func() {
+		<-a.running
+		mon.Counter("admission_running").Dec(1)
+	}
+
+	select {
+	case a.running <- struct{}{}:
+		mon.Counter("admission_running").Inc(1)
+		return release, nil
+	default:
+	}
+
+	select {
+	case a.queued <- struct{}{}:
+	default:
+		mon.Counter("admission_rejected").Inc(1)
+		return nil, fmt.Errorf("%w: server is overloaded, retry later", ErrTooManyRequests)
+	}
+	mon.Counter("admission_queued").Inc(1)
+	defer func() {
+		<-a.queued
+		mon.Counter("admission_queued").Dec(1)
+	}()
+
+	select {
+	case a.running <- struct{}{}:
+		mon.Counter("admission_running").Inc(1)
+		return release, nil
+	case <-ctx.Done():
+		mon.Counter("admission_timeouts").Inc(1)
+		return nil, fmt.Errorf("%w: request timed out in the admission queue", ErrServiceUnavailable)
+	}
+}
+
+// admitRequests queues requests when the server is at capacity, and sheds
+// them with 429 Too Many Requests when the queue is full. WebSocket
+// connections, the SLO and the admin API are always admitted.
+func (s *Server) admitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.admission == nil || r.Header.Get("Upgrade") != "" || unversionedPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		release, err := s.admission.admit(r.Context())
+		if err != nil {
+			w.Header().Set("Retry-After", "1")
+			s.errorResponse(w, err)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
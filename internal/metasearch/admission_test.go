@@ -0,0 +1,64 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+	"go.uber.org/zap"
+)
+
+func TestAdmissionQueue(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		AdminToken:         testAdminToken,
+		MaxRunningRequests: 1,
+		MaxQueuedRequests:  1,
+	})
+	require.NoError(t, err)
+
+	release, err := server.admission.admit(context.Background())
+	require.NoError(t, err)
+
+	// Requests over the capacity wait in the queue
+	queued := make(chan *httptest.ResponseRecorder)
+	go func() {
+		queued <- handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	}()
+	require.Eventually(t, func() bool {
+		return len(server.admission.queued) == 1
+	}, 10*time.Second, time.Millisecond)
+
+	// Requests are shed when the queue is full
+	rr := handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusTooManyRequests, `{"error": "too many requests"}`)
+	assert.Equal(t, rr.Header().Get("Retry-After"), "1")
+
+	// The SLO and admin API are always admitted
+	rr = handleRequest(server, http.MethodGet, "/slo", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	release()
+	rr = <-queued
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "bar"}`)
+
+	// Queued requests fail when they time out
+	server.Config.RequestTimeout = 50 * time.Millisecond
+	release, err = server.admission.admit(context.Background())
+	require.NoError(t, err)
+	defer release()
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, len(server.admission.queued), 0)
+}
@@ -0,0 +1,238 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxAggregateKeys is the maximum number of metadata keys of the min, max and
+// sum aggregates of a request.
+const maxAggregateKeys = 10
+
+// AggregateRequest contains fields for an aggregate request. The objects are
+// selected like in a search.
+type AggregateRequest struct {
+	SearchRequest
+
+	// GroupBy is the metadata key whose values group the objects. If it is
+	// empty, all objects are aggregated in a single group.
+	GroupBy string `json:"groupBy,omitempty"`
+
+	// Min, Max and Sum list the metadata keys whose numeric values are
+	// aggregated in each group. Other values are ignored.
+	Min []string `json:"min,omitempty"`
+	Max []string `json:"max,omitempty"`
+	Sum []string `json:"sum,omitempty"`
+
+	// Limit is the maximum number of groups, the largest groups first.
+	Limit int `json:"limit,omitempty"`
+}
+
+// AggregateResponse contains fields for an aggregate response.
+type AggregateResponse struct {
+	Groups []AggregateGroup `json:"groups"`
+
+	// Truncated is true if there are more groups than the limit.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// AggregateGroup contains the aggregates of a group of objects. Value is the
+// metadata value of the group, and is null for the objects without it.
+type AggregateGroup struct {
+	Value interface{}        `json:"value"`
+	Count int64              `json:"count"`
+	Min   map[string]float64 `json:"min,omitempty"`
+	Max   map[string]float64 `json:"max,omitempty"`
+	Sum   map[string]float64 `json:"sum,omitempty"`
+}
+
+// MetadataAggregation describes the aggregates computed by
+// AggregateMetadata.
+type MetadataAggregation struct {
+	GroupBy string
+	Min     []string
+	Max     []string
+	Sum     []string
+	Limit   int
+}
+
+func (r *MetabaseSearchRepository) AggregateMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, aggregation MetadataAggregation) ([]AggregateGroup, error) {
+	match, err := parseMatch(containsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &matchQueryBuilder{loc: loc, dialect: r.dialect, tables: r.tables}
+	columns := []string{"NULL::TEXT", "count(*)"}
+	if aggregation.GroupBy != "" {
+		columns[0] = fmt.Sprintf("(clear_metadata -> %s)::TEXT", b.arg(aggregation.GroupBy))
+	}
+
+	var aggregates int
+	for _, f := range []struct {
+		name string
+		keys []string
+	}{{"min", aggregation.Min}, {"max", aggregation.Max}, {"sum", aggregation.Sum}} {
+		for _, key := range f.keys {
+			k := b.arg(key)
+			columns = append(columns, fmt.Sprintf("%s(CASE WHEN jsonb_typeof(clear_metadata -> %s) = 'number' THEN (clear_metadata ->> %s)::FLOAT8 END)", f.name, k, k))
+			aggregates++
+		}
+	}
+
+	filter, err := b.searchFilter(match)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT " + strings.Join(columns, ", ") + "\nFROM " + b.table(defaultPrimaryKey) + " WHERE " + filter + b.pageRange(loc, ObjectLocation{})
+	if aggregation.GroupBy != "" {
+		query += fmt.Sprintf("\nGROUP BY 1 ORDER BY 2 DESC, 1 LIMIT %s", b.arg(aggregation.Limit))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, b.args...)
+	if isMissingIndexError(err) {
+		// Aggregating without the index would scan the whole bucket
+		return nil, fmt.Errorf("%w: aggregates are unavailable while the metadata index is rebuilt", ErrServiceUnavailable)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	var groups []AggregateGroup
+	for rows.Next() {
+		var group AggregateGroup
+		var value *string
+		values := make([]*float64, aggregates)
+		dest := []interface{}{&value, &group.Count}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+
+		if value != nil {
+			if err := unmarshalJSON([]byte(*value), &group.Value); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+			}
+		}
+
+		// The values are in the order of the columns: min, max, then sum
+		i := 0
+		for _, f := range []struct {
+			keys   []string
+			result *map[string]float64
+		}{{aggregation.Min, &group.Min}, {aggregation.Max, &group.Max}, {aggregation.Sum, &group.Sum}} {
+			for _, key := range f.keys {
+				if values[i] != nil {
+					if *f.result == nil {
+						*f.result = make(map[string]float64)
+					}
+					(*f.result)[key] = *values[i]
+				}
+				i++
+			}
+		}
+
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return groups, nil
+}
+
+// HandleAggregate groups the objects matched by a search by a metadata value,
+// and returns the number of objects and the aggregates of numeric values of
+// each group.
+func (s *Server) HandleAggregate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request AggregateRequest
+
+	err := s.validateRequest(ctx, r, &request.BaseRequest, &request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = s.validateAggregateRequest(&request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	if request.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, request.timeout)
+		defer cancel()
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	// One more group is requested to detect truncated results
+	groups, err := s.Repo.AggregateMetadata(ctx, request.EncryptedLocation, request.Match, MetadataAggregation{
+		GroupBy: request.GroupBy,
+		Min:     request.Min,
+		Max:     request.Max,
+		Sum:     request.Sum,
+		Limit:   request.Limit + 1,
+	})
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		s.errorResponse(w, fmt.Errorf("%w: aggregation timed out", ErrServiceUnavailable))
+		return
+	}
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	response := AggregateResponse{Groups: groups}
+	if response.Groups == nil {
+		response.Groups = make([]AggregateGroup, 0)
+	}
+	if len(response.Groups) > request.Limit {
+		response.Groups = response.Groups[:request.Limit]
+		response.Truncated = true
+	}
+
+	s.jsonResponse(w, http.StatusOK, response)
+}
+
+func (s *Server) validateAggregateRequest(request *AggregateRequest) error {
+	err := s.validateSearchRequest(&request.SearchRequest)
+	if err != nil {
+		return err
+	}
+	err = validateWithoutObjects(&request.SearchRequest, "aggregate")
+	if err != nil {
+		return err
+	}
+
+	if len(request.Min)+len(request.Max)+len(request.Sum) > maxAggregateKeys {
+		return fmt.Errorf("%w: at most %d keys can be aggregated", ErrBadRequest, maxAggregateKeys)
+	}
+	for _, keys := range [][]string{request.Min, request.Max, request.Sum} {
+		for _, key := range keys {
+			if key == "" {
+				return fmt.Errorf("%w: aggregated keys must not be empty", ErrBadRequest)
+			}
+		}
+	}
+
+	if request.Limit <= 0 || request.Limit > maxBatchSize {
+		request.Limit = defaultBatchSize
+	}
+	return nil
+}
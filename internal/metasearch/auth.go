@@ -27,12 +27,16 @@ type Authenticator interface {
 
 // HeaderAuth authenticates metasearch HTTP requests based on the Authorization header
 type HeaderAuth struct {
-	db satellite.DB
+	db   satellite.DB
+	keys *apiKeyCache
 }
 
 func NewHeaderAuth(db satellite.DB) *HeaderAuth {
 	return &HeaderAuth{
 		db: db,
+		keys: newAPIKeyCache(func(ctx context.Context, head []byte) (*console.APIKeyInfo, error) {
+			return db.Console().APIKeys().GetByHead(ctx, head)
+		}),
 	}
 }
 
@@ -71,7 +75,7 @@ func (a *HeaderAuth) Authenticate(ctx context.Context, r *http.Request) (project
 
 	var keyInfo *console.APIKeyInfo
 	apiKey := accessGetAPIKey(access)
-	keyInfo, err = a.db.Console().APIKeys().GetByHead(ctx, apiKey.Head())
+	keyInfo, err = a.keys.Get(ctx, apiKey.Head())
 	if err != nil {
 		err = fmt.Errorf("%w: cannot find project by API key", ErrAuthorizationFailed)
 		return
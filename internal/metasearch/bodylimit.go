@@ -0,0 +1,51 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// limitRequestBody bounds the size of request bodies by the maximum body size
+// of the server. Reads past the limit fail, and the handlers decoding the body
+// respond with 413 Request Entity Too Large. Import manifests are streamed and
+// not bounded.
+func (s *Server) limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Config.MaxBodySize <= 0 || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() == "import" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, s.Config.MaxBodySize)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bodyTooLarge returns the error response of a request body larger than the
+// maximum body size, or nil if err is not caused by it.
+func bodyTooLarge(err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return fmt.Errorf("%w: request body cannot be larger than %d bytes", ErrRequestTooLarge, maxBytesError.Limit)
+	}
+	return nil
+}
+
+// bodyError returns the error response of an error reading the request body
+// with the given description.
+func bodyError(err error, description string) error {
+	if tooLarge := bodyTooLarge(err); tooLarge != nil {
+		return tooLarge
+	}
+	return fmt.Errorf("%w: %s: %w", ErrBadRequest, description, err)
+}
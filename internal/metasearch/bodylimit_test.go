@@ -0,0 +1,58 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	server := testServer()
+	server.Config.MaxBodySize = 64
+
+	large := `{"foo": "` + strings.Repeat("a", 64) + `"}`
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", large)
+	assertResponse(t, rr, http.StatusRequestEntityTooLarge, `{"error": "request entity too large"}`)
+
+	// Handlers decoding the body reject it when the limit is reached
+	for _, path := range []string{
+		"/vocabularies/color",
+		"/s3/testbucket/foo.txt?tagging",
+	} {
+		rr = httptest.NewRecorder()
+		server.Handler.ServeHTTP(rr, testRequest(http.MethodPut, path, large))
+		assert.Equal(t, rr.Code, http.StatusRequestEntityTooLarge)
+	}
+	require.Contains(t, rr.Body.String(), "<Code>EntityTooLarge</Code>")
+
+	// Idempotent requests read the body before the handler
+	rr = httptest.NewRecorder()
+	r := testRequest(http.MethodPut, "/metadata/testbucket/foo.txt", large)
+	r.Header.Set("Idempotency-Key", "abc")
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusRequestEntityTooLarge)
+
+	// Import manifests are not bounded
+	manifest := "key,color\nfoo.txt," + strings.Repeat("a", 64) + "\n"
+	rr = handleRequest(server, http.MethodPost, "/import/testbucket?merge=true", manifest)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "bar", "color": "`+strings.Repeat("a", 64)+`"}`)
+
+	// The limit is disabled if it is zero
+	server.Config.MaxBodySize = 0
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", large)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+}
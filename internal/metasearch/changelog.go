@@ -0,0 +1,190 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"storj.io/common/uuid"
+)
+
+// MetadataChange is a change of the clear metadata of an object in a bucket.
+type MetadataChange struct {
+	ObjectKey string
+	MetadataRevision
+}
+
+// ChangeEntry is a metadata change in a changes response. Metadata is the
+// clear metadata after the change, and is null if it was deleted.
+type ChangeEntry struct {
+	Path      string                 `json:"path"`
+	Version   int64                  `json:"version"`
+	Watermark int64                  `json:"watermark"`
+	Actor     string                 `json:"actor"`
+	ChangedAt time.Time              `json:"changedAt"`
+	Deleted   bool                   `json:"deleted,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata"`
+}
+
+// ChangesResponse contains the metadata changes of a bucket after a
+// watermark. Once all pages are read, Watermark is the watermark to request
+// the next changes with.
+type ChangesResponse struct {
+	Changes   []ChangeEntry `json:"changes"`
+	Watermark int64         `json:"watermark"`
+	PageToken string        `json:"pageToken,omitempty"`
+}
+
+func (r *MetabaseSearchRepository) GetChanges(ctx context.Context, projectID uuid.UUID, bucket string, afterWatermark int64, afterRevision int64, limit int) ([]MetadataChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT
+			object_key, revision, version, actor, changed_at, watermark,
+			old_clear_metadata, new_clear_metadata
+		FROM `+r.dialect.table("metasearch_history", "metasearch_history_watermark_idx")+`
+		WHERE
+			(project_id, bucket_name) = ($1, $2) AND
+			watermark IS NOT NULL AND
+			(watermark, revision) > ($3, $4)
+		ORDER BY watermark, revision
+		LIMIT $5
+		`,
+		projectID, []byte(bucket), afterWatermark, afterRevision, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	changes := make([]MetadataChange, 0, limit)
+	for rows.Next() {
+		var change MetadataChange
+		var oldMetadata, newMetadata *string
+		err = rows.Scan(
+			&change.ObjectKey, &change.Revision, &change.Version, &change.Actor, &change.ChangedAt, &change.Watermark,
+			&oldMetadata, &newMetadata,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+
+		change.OldMetadata, err = parseJSON(oldMetadata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		change.NewMetadata, err = parseJSON(newMetadata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return changes, nil
+}
+
+// HandleChanges lists the metadata changes of a bucket after the watermark
+// in the since parameter, oldest first. Downstream indexes use it to stay in
+// sync without exporting the whole bucket again.
+func (s *Server) HandleChanges(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request BaseRequest
+
+	err := s.validateRequest(ctx, r, &request, nil)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := defaultBatchSize
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxBatchSize {
+			s.errorResponse(w, fmt.Errorf("%w: invalid limit", ErrBadRequest))
+			return
+		}
+	}
+
+	// The first page starts after all changes of the since watermark
+	var afterWatermark, afterRevision int64 = 0, math.MaxInt64
+	if v := q.Get("since"); v != "" {
+		afterWatermark, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || afterWatermark < 0 {
+			s.errorResponse(w, fmt.Errorf("%w: invalid since", ErrBadRequest))
+			return
+		}
+	}
+	if v := q.Get("pageToken"); v != "" {
+		afterWatermark, afterRevision, err = parseChangesPageToken(v)
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+	}
+
+	changes, err := s.Repo.GetChanges(ctx, request.EncryptedLocation.ProjectID, request.EncryptedLocation.BucketName, afterWatermark, afterRevision, limit)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	response := ChangesResponse{
+		Changes:   make([]ChangeEntry, 0, len(changes)),
+		Watermark: afterWatermark,
+	}
+	for _, change := range changes {
+		response.Watermark = change.Watermark
+
+		// Skip the objects whose path cannot be decrypted with the grant
+		path, err := request.Encryptor.DecryptPath(request.Location.BucketName, change.ObjectKey)
+		if err != nil {
+			continue
+		}
+
+		response.Changes = append(response.Changes, ChangeEntry{
+			Path:      fmt.Sprintf("sj://%s/%s", request.Location.BucketName, path),
+			Version:   change.Version,
+			Watermark: change.Watermark,
+			Actor:     change.Actor,
+			ChangedAt: change.ChangedAt,
+			Deleted:   change.NewMetadata == nil,
+			Metadata:  change.NewMetadata,
+		})
+	}
+	if len(changes) >= limit {
+		last := changes[len(changes)-1]
+		response.PageToken = fmt.Sprintf("%d.%d", last.Watermark, last.Revision)
+	}
+
+	s.jsonResponse(w, http.StatusOK, response)
+}
+
+func parseChangesPageToken(s string) (watermark int64, revision int64, err error) {
+	w, rev, ok := strings.Cut(s, ".")
+	if ok {
+		watermark, err = strconv.ParseInt(w, 10, 64)
+	}
+	if ok && err == nil {
+		revision, err = strconv.ParseInt(rev, 10, 64)
+	}
+	if !ok || err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid pageToken", ErrBadRequest)
+	}
+	return watermark, revision, nil
+}
@@ -0,0 +1,71 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"storj.io/common/uuid"
+)
+
+// searchETag returns the entity tag of a validated search request. It changes
+// whenever the metadata in the bucket changes, so that clients polling the
+// same query can skip unchanged results. Requests made with different access
+// grants have different entity tags, as they may decrypt paths differently.
+// It returns an empty string if the changes of the bucket are unknown, or for
+// stale searches, as their older snapshot may lag behind the watermark.
+func (s *Server) searchETag(ctx context.Context, request *SearchRequest, fingerprint string) string {
+	if request.Staleness != "" {
+		return ""
+	}
+	loc := request.EncryptedLocation
+
+	version, err := s.bucketVersion(ctx, loc.ProjectID, loc.BucketName)
+	if err != nil {
+		return ""
+	}
+
+	query, err := json.Marshal(struct {
+		Prefix       string                 `json:"prefix"`
+		Match        map[string]interface{} `json:"match"`
+		Filter       string                 `json:"filter"`
+		Projection   string                 `json:"projection"`
+		KeyPattern   string                 `json:"keyPattern"`
+		KeyRegex     string                 `json:"keyRegex"`
+		Sort         *SearchSort            `json:"sort"`
+		CountOnly    bool                   `json:"countOnly"`
+		BatchSize    int                    `json:"batchSize"`
+		PageToken    string                 `json:"pageToken"`
+		DecryptPaths *bool                  `json:"decryptPaths"`
+		System       bool                   `json:"includeSystemMetadata"`
+		Delimiter    string                 `json:"delimiter"`
+		Highlight    bool                   `json:"highlight"`
+		SimilarTo    *SimilarTo             `json:"similarTo"`
+		Timeout      string                 `json:"timeout"`
+		Partial      bool                   `json:"partialResults"`
+	}{loc.ObjectKey, request.Match, request.Filter, request.Projection, request.KeyPattern, request.KeyRegex, request.Sort, request.CountOnly, request.BatchSize, request.PageToken, request.DecryptPaths, request.IncludeSystemMetadata, request.Delimiter, request.Highlight, request.SimilarTo, request.Timeout, request.PartialResults})
+	if err != nil {
+		return ""
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n", version, loc.BucketName, fingerprint, query)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// bucketVersion returns a version of the metadata of a bucket, which changes
+// with every metadata change. It is the watermark of the bucket in the
+// database, which also counts the changes made by other server instances and
+// the objects of uplinks once they are indexed.
+func (s *Server) bucketVersion(ctx context.Context, projectID uuid.UUID, bucket string) (string, error) {
+	watermark, err := s.Repo.GetWatermark(ctx, projectID, bucket)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("watermark:%d", watermark.Watermark), nil
+}
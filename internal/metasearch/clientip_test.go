@@ -0,0 +1,71 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestClientIP(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		TrustedProxies: []string{"10.0.0.0/8", "192.168.1.10", "::1"},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		expectedIP   string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.1:1234", expectedIP: "203.0.113.1"},
+		{name: "untrusted peer", remoteAddr: "203.0.113.1:1234", forwardedFor: []string{"198.51.100.1"}, realIP: "198.51.100.2", expectedIP: "203.0.113.1"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"198.51.100.1"}, expectedIP: "198.51.100.1"},
+		{name: "IPv6 proxy", remoteAddr: "[::1]:1234", forwardedFor: []string{"2001:db8::1"}, expectedIP: "2001:db8::1"},
+		{name: "proxy chain", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"198.51.100.1, 192.168.1.10"}, expectedIP: "198.51.100.1"},
+		{name: "spoofed hops", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"1.2.3.4, 198.51.100.1", "10.0.0.1"}, expectedIP: "198.51.100.1"},
+		{name: "invalid hop", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"unknown, 10.0.0.1"}, expectedIP: "10.0.0.1"},
+		{name: "only proxies", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"10.0.0.2"}, expectedIP: "10.0.0.2"},
+		{name: "real IP", remoteAddr: "10.1.2.3:1234", realIP: "198.51.100.2", expectedIP: "198.51.100.2"},
+		{name: "invalid real IP", remoteAddr: "10.1.2.3:1234", realIP: "unknown", expectedIP: "10.1.2.3"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, header := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			var ip string
+			server.resolveClientIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ip = clientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), r)
+			require.Equal(t, tt.expectedIP, ip)
+		})
+	}
+
+	// Without trusted proxies, forwarding headers are ignored
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.1.2.3:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	r.Header.Set("X-Real-IP", "198.51.100.1")
+	testServer().resolveClientIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "10.1.2.3", clientIP(r))
+	})).ServeHTTP(httptest.NewRecorder(), r)
+
+	_, err = NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		TrustedProxies: []string{"10.0.0.0/33"},
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,215 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// minCompressSize is the minimum size of responses that are compressed,
+// unless they are flushed before.
+const minCompressSize = 1024
+
+// compressEncoder is a compressing writer that can be flushed and reused.
+type compressEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressEncoders are the supported content encodings, with pools of their
+// encoders.
+var compressEncoders = map[string]*sync.Pool{
+	"gzip": {New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}},
+	"zstd": {New: func() interface{} {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+}
+
+// ParseCompression parses a comma separated list of content encodings of
+// responses, in order of preference.
+func ParseCompression(s string) ([]string, error) {
+	var encodings []string
+	for _, encoding := range strings.Split(s, ",") {
+		encoding = strings.TrimSpace(encoding)
+		if encoding == "" {
+			continue
+		}
+		if compressEncoders[encoding] == nil {
+			return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+		}
+		encodings = append(encodings, encoding)
+	}
+	return encodings, nil
+}
+
+// acceptedEncoding returns the first encoding of the configuration that the
+// Accept-Encoding header allows, or an empty string.
+func acceptedEncoding(header string, encodings []string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q > 0
+	}
+	for _, encoding := range encodings {
+		if allowed, ok := accepted[encoding]; ok && allowed || !ok && accepted["*"] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressible reports whether responses of a content type are compressed.
+// Parquet files are already compressed.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == ndjsonContentType || strings.HasPrefix(mediaType, "text/")
+}
+
+// compressResponses compresses JSON, NDJSON and CSV responses with the
+// preferred encoding the client accepts.
+func (s *Server) compressResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.Config.Compression) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"), s.Config.Compression)
+		// WebSocket connections are not compressed by the middleware
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressResponseWriter buffers the start of a response until it is large
+// enough to be worth compressing, or flushed.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     compressEncoder
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < minCompressSize {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressResponseWriter) Flush() {
+	if !w.decided {
+		// Streamed responses are compressed regardless of their size
+		_ = w.decide(true)
+	} else if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// decide sends the header of the response, compressed if it is large enough
+// and compressible, and the buffered start of the response.
+func (w *compressResponseWriter) decide(large bool) error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	h := w.Header()
+	if large && w.status != http.StatusNoContent && w.status != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		// The compressed representation is only semantically equivalent
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.enc = compressEncoders[w.encoding].Get().(compressEncoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// close sends the rest of the response and returns the encoder to its pool.
+func (w *compressResponseWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// Nothing was written, e.g. the handler panicked
+			return
+		}
+		_ = w.decide(false)
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		compressEncoders[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	encodings := []string{"zstd", "gzip"}
+	for header, expected := range map[string]string{
+		"":                        "",
+		"gzip":                    "gzip",
+		"gzip, deflate, br, zstd": "zstd",
+		"zstd;q=0, gzip;q=0.5":    "gzip",
+		"*":                       "zstd",
+		"*, zstd;q=0":             "gzip",
+		"identity":                "",
+		"GZIP":                    "gzip",
+	} {
+		require.Equal(t, expected, acceptedEncoding(header, encodings), header)
+	}
+}
+
+func TestCompressResponses(t *testing.T) {
+	server := testServer()
+	server.Config.Compression = []string{"zstd", "gzip"}
+
+	description := strings.Repeat("compressible ", 200)
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"description": "`+description+`"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	search := func(acceptEncoding string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(http.MethodPost, "/metasearch/testbucket", `{}`)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		server.Handler.ServeHTTP(rr, r)
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.Equal(t, rr.Header().Get("Vary"), "Accept-Encoding")
+		return rr
+	}
+	expected := `{"results": [{"path": "sj://testbucket/foo.txt", "metadata": {"description": "` + description + `"}}]}`
+
+	// gzip
+	rr = search("gzip")
+	assert.Equal(t, rr.Header().Get("Content-Encoding"), "gzip")
+	gz, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.JSONEq(t, expected, string(body))
+
+	// zstd is preferred
+	rr = search("gzip, zstd")
+	assert.Equal(t, rr.Header().Get("Content-Encoding"), "zstd")
+	zr, err := zstd.NewReader(rr.Body)
+	require.NoError(t, err)
+	body, err = io.ReadAll(zr)
+	zr.Close()
+	require.NoError(t, err)
+	require.JSONEq(t, expected, string(body))
+
+	// Not accepted
+	rr = search("identity")
+	assert.Equal(t, rr.Header().Get("Content-Encoding"), "")
+	require.JSONEq(t, expected, rr.Body.String())
+
+	// Small responses are not compressed
+	rr = httptest.NewRecorder()
+	r := testRequest(http.MethodPost, "/metasearch/otherbucket", `{}`)
+	r.Header.Set("Accept-Encoding", "gzip")
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Encoding"), "")
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/spacemonkeygo/monkit/v3"
+)
+
+// Handler classes with separate concurrency limits.
+const (
+	crudClass      = "crud"
+	searchClass    = "search"
+	migrationClass = "migration"
+)
+
+// routeClasses are the handler classes of the routes with a concurrency
+// limit, by route name. Watch connections, search jobs and imports have
+// their own limits.
+var routeClasses = map[string]string{
+	"get":                   crudClass,
+	"head":                  crudClass,
+	"update":                crudClass,
+	"delete":                crudClass,
+	"update-encrypted":      crudClass,
+	"history":               crudClass,
+	"rollback":              crudClass,
+	"restore":               crudClass,
+	"get-object-tagging":    crudClass,
+	"put-object-tagging":    crudClass,
+	"delete-object-tagging": crudClass,
+
+	"list":      searchClass,
+	"search":    searchClass,
+	"changes":   searchClass,
+	"aggregate": searchClass,
+	"rollup":    searchClass,
+	"distinct":  searchClass,
+	"suggest":   searchClass,
+	"top":       searchClass,
+	"keys":      searchClass,
+}
+
+// concurrencyLimiter limits the number of concurrent requests of each
+// handler class, so that a burst of expensive requests of one class cannot
+// starve the others.
+type concurrencyLimiter struct {
+	slots map[string]chan struct{}
+}
+
+// newConcurrencyLimiter creates a limiter with the maximum number of
+// concurrent requests of each handler class. Classes without a positive
+// limit are not limited.
+func newConcurrencyLimiter(limits map[string]int) *concurrencyLimiter {
+	l := &concurrencyLimiter{slots: make(map[string]chan struct{})}
+	for class, limit := range limits {
+		if limit > 0 {
+			l.slots[class] = make(chan struct{}, limit)
+		}
+	}
+	return l
+}
+
+// acquire takes a slot of the handler class without waiting. The returned
+// function releases the slot.
+func (l *concurrencyLimiter) acquire(class string) (release func(), err error) {
+	slots := l.slots[class]
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	tag := monkit.NewSeriesTag("class", class)
+	select {
+	case slots <- struct{}{}:
+		mon.Counter("concurrent_requests", tag).Inc(1)
+		return func() {
+			<-slots
+			mon.Counter("concurrent_requests", tag).Dec(1)
+		}, nil
+	default:
+		mon.Counter("concurrent_requests_rejected", tag).Inc(1)
+		return nil, fmt.Errorf("%w: too many concurrent %s requests, retry later", ErrTooManyRequests, class)
+	}
+}
+
+// limitConcurrency rejects requests with 429 Too Many Requests when the
+// concurrency limit of their handler class is reached.
+func (s *Server) limitConcurrency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil || routeClasses[route.GetName()] == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		release, err := s.concurrency.acquire(routeClasses[route.GetName()])
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,74 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+	"go.uber.org/zap"
+)
+
+func TestConcurrencyLimits(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		MaxCRUDRequests:   1,
+		MaxSearchRequests: 1,
+	})
+	require.NoError(t, err)
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Searches in progress do not block metadata operations
+	release, err := server.concurrency.acquire(searchClass)
+	require.NoError(t, err)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{}`)
+	assertResponse(t, rr, http.StatusTooManyRequests, `{"error": "too many requests"}`)
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/distinct?key=foo", "")
+	assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	release()
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// Metadata operations in progress do not block searches
+	release, err = server.concurrency.acquire(crudClass)
+	require.NoError(t, err)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	release()
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+}
+
+func TestConcurrentMigrationsLimit(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		Stateless:     true,
+		MaxMigrations: 1,
+	})
+	require.NoError(t, err)
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	release, err := server.concurrency.acquire(migrationClass)
+	require.NoError(t, err)
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+
+	release()
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+}
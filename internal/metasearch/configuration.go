@@ -0,0 +1,255 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// configurationVersion is the version of the format of configuration
+// bundles.
+const configurationVersion = 1
+
+// ConfigurationBundle is the search configuration of a project, exported and
+// imported as a single document, e.g. to promote the configuration of a
+// staging project to production.
+type ConfigurationBundle struct {
+	Version int `json:"version"`
+
+	// Vocabularies are the vocabularies of the project, by metadata key.
+	Vocabularies map[string]Vocabulary `json:"vocabularies"`
+
+	// Schedules are the scheduled searches of the access grant, in all
+	// buckets of the project. Their credentials and webhook secrets are not
+	// exported.
+	Schedules []ConfigurationSchedule `json:"schedules"`
+}
+
+// ConfigurationSchedule is a scheduled search of a configuration bundle.
+type ConfigurationSchedule struct {
+	Bucket string `json:"bucket"`
+	ScheduleRequest
+}
+
+// ConfigurationImportResponse is the response to the import of a
+// configuration bundle. It contains the imported schedules, with the secrets
+// of their webhook requests.
+type ConfigurationImportResponse struct {
+	Vocabularies int                      `json:"vocabularies"`
+	Schedules    []ImportedScheduleResult `json:"schedules"`
+}
+
+// ImportedScheduleResult is a schedule created by the import of a
+// configuration bundle.
+type ImportedScheduleResult struct {
+	Bucket string `json:"bucket"`
+	ScheduleResponse
+}
+
+// HandleExportConfiguration exports the search configuration of the project
+// of the access grant.
+func (s *Server) HandleExportConfiguration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID, _, _, err := s.authenticateProject(ctx, r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	vocabularies, err := s.Repo.GetVocabularies(ctx, projectID)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+	schedules, err := s.Repo.ListProjectSchedules(ctx, projectID)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	bundle := ConfigurationBundle{
+		Version:      configurationVersion,
+		Vocabularies: vocabularies,
+		Schedules:    []ConfigurationSchedule{},
+	}
+	if bundle.Vocabularies == nil {
+		bundle.Vocabularies = map[string]Vocabulary{}
+	}
+	owner := grantFingerprint(r)
+	for _, schedule := range schedules {
+		if schedule.Owner != owner {
+			continue
+		}
+		bundle.Schedules = append(bundle.Schedules, ConfigurationSchedule{
+			Bucket: schedule.BucketName,
+			ScheduleRequest: ScheduleRequest{
+				Name:   schedule.Name,
+				Cron:   schedule.Cron,
+				URL:    schedule.URL,
+				Search: schedule.Request,
+			},
+		})
+	}
+
+	s.jsonResponse(w, http.StatusOK, bundle)
+}
+
+// HandleImportConfiguration imports a configuration bundle into the project
+// of the access grant. Vocabularies replace the vocabularies of the same
+// keys, and schedules replace the schedules of the access grant with the
+// same bucket and name. Other vocabularies and schedules are kept. The whole
+// bundle is validated before anything is changed.
+func (s *Server) HandleImportConfiguration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID, encryptor, authorizer, err := s.authenticateProject(ctx, r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	var bundle ConfigurationBundle
+	if err := newJSONDecoder(r.Body).Decode(&bundle); err != nil {
+		s.errorResponse(w, bodyError(err, "error decoding request body"))
+		return
+	}
+	if bundle.Version != configurationVersion {
+		s.errorResponse(w, fmt.Errorf("%w: unsupported configuration version %d", ErrBadRequest, bundle.Version))
+		return
+	}
+
+	// Validate vocabularies
+	if len(bundle.Vocabularies) > 0 {
+		err = authorizer.Authorize(ctx, ObjectLocation{ProjectID: projectID}, ActionWriteMetadata)
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+	}
+	keys := make([]string, 0, len(bundle.Vocabularies))
+	for key, vocabulary := range bundle.Vocabularies {
+		if err := vocabulary.validate(); err != nil {
+			s.errorResponse(w, fmt.Errorf("vocabulary '%s': %w", key, err))
+			return
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Validate schedules, which run with the access grant of the request
+	owner := grantFingerprint(r)
+	credentials := r.Header.Get("Authorization")
+	if len(bundle.Schedules) > 0 {
+		if s.Config.ScheduleSecret == "" {
+			s.errorResponse(w, fmt.Errorf("%w: scheduled searches are disabled", ErrNotFound))
+			return
+		}
+		if !strings.HasPrefix(credentials, "Bearer ") {
+			s.errorResponse(w, fmt.Errorf("%w: scheduled searches require an access grant in the Authorization header", ErrBadRequest))
+			return
+		}
+	}
+
+	type scheduleKey struct{ bucket, name string }
+	imported := make(map[scheduleKey]bool, len(bundle.Schedules))
+	bucketSchedules := make(map[string]int)
+	schedules := make([]Schedule, 0, len(bundle.Schedules))
+	for _, item := range bundle.Schedules {
+		if item.Bucket == "" {
+			s.errorResponse(w, fmt.Errorf("%w: schedule '%s' has no bucket", ErrBadRequest, item.Name))
+			return
+		}
+		key := scheduleKey{item.Bucket, item.Name}
+		if imported[key] {
+			s.errorResponse(w, fmt.Errorf("%w: duplicate schedule '%s' in bucket '%s'", ErrBadRequest, item.Name, item.Bucket))
+			return
+		}
+		imported[key] = true
+		bucketSchedules[item.Bucket]++
+
+		var base BaseRequest
+		base.Authorizer = authorizer
+		if err = setLocation(&base, projectID, encryptor, item.Bucket, ""); err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+		err = authorizer.Authorize(ctx, base.EncryptedLocation, ActionQueryMetadata)
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+
+		schedule, err := s.newSchedule(base, item.ScheduleRequest, owner, credentials)
+		if err != nil {
+			s.errorResponse(w, fmt.Errorf("schedule '%s' in bucket '%s': %w", item.Name, item.Bucket, err))
+			return
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	// Find the schedules replaced by the import, and check the number of
+	// schedules of each bucket after the import
+	var replaced []Schedule
+	if len(schedules) > 0 {
+		existing, err := s.Repo.ListProjectSchedules(ctx, projectID)
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+		for _, schedule := range existing {
+			if schedule.Owner == owner && imported[scheduleKey{schedule.BucketName, schedule.Name}] {
+				replaced = append(replaced, schedule)
+				continue
+			}
+			if _, ok := bucketSchedules[schedule.BucketName]; ok {
+				bucketSchedules[schedule.BucketName]++
+			}
+		}
+	}
+	for bucket, count := range bucketSchedules {
+		if count > maxSchedules {
+			s.errorResponse(w, fmt.Errorf("%w: bucket '%s' would have more than %d schedules", ErrUnprocessableEntity, bucket, maxSchedules))
+			return
+		}
+	}
+
+	// Apply the bundle
+	for _, key := range keys {
+		err = s.Repo.SetVocabulary(ctx, projectID, key, bundle.Vocabularies[key])
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+	}
+	for _, schedule := range replaced {
+		err = s.Repo.DeleteSchedule(ctx, schedule.ID)
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+	}
+	response := ConfigurationImportResponse{
+		Vocabularies: len(keys),
+		Schedules:    []ImportedScheduleResult{},
+	}
+	for _, schedule := range schedules {
+		err = s.Repo.CreateSchedule(ctx, schedule)
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+		result := ImportedScheduleResult{
+			Bucket:           schedule.BucketName,
+			ScheduleResponse: scheduleResponse(schedule),
+		}
+		result.Secret = schedule.Secret
+		response.Schedules = append(response.Schedules, result)
+	}
+
+	s.jsonResponse(w, http.StatusOK, response)
+}
@@ -0,0 +1,91 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestConfigurationBundle(t *testing.T) {
+	server := testServer()
+	server.Config.ScheduleSecret = "testsecret"
+
+	rr := handleRequest(server, http.MethodPut, "/vocabularies/region", `{"values": ["eu/de", "us"]}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/schedules", `{
+		"name": "missing retention",
+		"cron": "0 2 * * *",
+		"url": "https://example.com/hook",
+		"search": {"filter": "retention == null"}
+	}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var created ScheduleResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+
+	// Export
+	rr = handleRequest(server, http.MethodGet, "/configuration", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	exported := rr.Body.String()
+	var bundle ConfigurationBundle
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bundle))
+	require.Equal(t, configurationVersion, bundle.Version)
+	require.Equal(t, map[string]Vocabulary{"region": {Values: []string{"eu/de", "us"}}}, bundle.Vocabularies)
+	require.Len(t, bundle.Schedules, 1)
+	require.Equal(t, "testbucket", bundle.Schedules[0].Bucket)
+	require.Equal(t, "missing retention", bundle.Schedules[0].Name)
+	require.JSONEq(t, `{"filter": "retention == null"}`, string(bundle.Schedules[0].Search))
+
+	// Secrets and credentials are not exported
+	require.NotContains(t, exported, created.Secret)
+	require.NotContains(t, exported, "testtoken")
+
+	// Import replaces the vocabularies and schedules of the bundle
+	rr = handleRequest(server, http.MethodDelete, "/vocabularies/region", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPut, "/configuration", exported)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var imported ConfigurationImportResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &imported))
+	require.Equal(t, 1, imported.Vocabularies)
+	require.Len(t, imported.Schedules, 1)
+	require.Equal(t, "testbucket", imported.Schedules[0].Bucket)
+	require.NotEmpty(t, imported.Schedules[0].Secret)
+	require.NotEqual(t, created.ID, imported.Schedules[0].ID)
+
+	rr = handleRequest(server, http.MethodGet, "/vocabularies", "")
+	assertResponse(t, rr, http.StatusOK, `{"vocabularies": {"region": {"values": ["eu/de", "us"]}}}`)
+	schedules := testRepo(server).schedules
+	require.Len(t, schedules, 1)
+	_, ok := schedules[mustParseUUID(t, imported.Schedules[0].ID)]
+	require.True(t, ok)
+
+	// Invalid bundles change nothing
+	for _, invalid := range []string{
+		`{"version": 2}`,
+		`{"version": 1, "vocabularies": {"region": {"values": []}}}`,
+		`{"version": 1, "schedules": [{"name": "no bucket", "cron": "@daily", "url": "https://example.com/hook"}]}`,
+		`{"version": 1, "schedules": [{"bucket": "testbucket", "name": "invalid", "cron": "@daily", "url": "ftp://example.com"}]}`,
+		`{"version": 1, "vocabularies": {"size": {"values": ["s"]}}, "schedules": [
+			{"bucket": "testbucket", "name": "twice", "cron": "@daily", "url": "https://example.com/hook"},
+			{"bucket": "testbucket", "name": "twice", "cron": "@daily", "url": "https://example.com/hook"}
+		]}`,
+	} {
+		rr = handleRequest(server, http.MethodPut, "/configuration", invalid)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+	}
+	rr = handleRequest(server, http.MethodGet, "/vocabularies", "")
+	assertResponse(t, rr, http.StatusOK, `{"vocabularies": {"region": {"values": ["eu/de", "us"]}}}`)
+	require.Len(t, testRepo(server).schedules, 1)
+
+	// Schedules cannot be imported while scheduled searches are disabled
+	server.Config.ScheduleSecret = ""
+	rr = handleRequest(server, http.MethodPut, "/configuration", exported)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
@@ -0,0 +1,235 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"storj.io/common/uuid"
+	"storj.io/storj/satellite"
+	"storj.io/storj/satellite/console"
+	"storj.io/storj/satellite/console/consoleauth"
+
+	"storj.io/uplink"
+)
+
+const (
+	// consoleSessionCookie is the cookie in which the satellite web app stores
+	// the session token.
+	consoleSessionCookie = "_tokenKey"
+
+	consoleSessionHeader = "X-Console-Session"
+	consoleProjectHeader = "X-Project-ID"
+	consoleAccessHeader  = "X-Metasearch-Access"
+
+	// consolePreflightMaxAge is the duration, in seconds, browsers cache the
+	// response to preflight requests of the web app.
+	consolePreflightMaxAge = "600"
+)
+
+var (
+	// consoleAllowedMethods and consoleAllowedHeaders are the methods and
+	// request headers the web app can use in cross-origin requests.
+	consoleAllowedMethods = []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete,
+	}
+	consoleAllowedHeaders = []string{
+		"Authorization", "Content-Type", "If-Match", "If-None-Match", "Idempotency-Key",
+		consoleSessionHeader, consoleProjectHeader, consoleAccessHeader,
+		featuresHeader, apiVersionHeader,
+	}
+
+	// consoleExposedHeaders are the response headers readable by the web
+	// app in cross-origin requests.
+	consoleExposedHeaders = []string{
+		"ETag", "Last-Modified", "Location", "Metadata-Expires", "Retry-After",
+		"Idempotent-Replayed", "X-Metasearch-Error", "X-Metasearch-Query-Cost",
+		searchCacheHeader, featuresHeader, apiVersionHeader,
+	}
+)
+
+// ConsoleSessionAuth authenticates requests from the satellite web app with
+// the session token of the logged in user, sent in the session cookie or the
+// X-Console-Session header. The project is passed in the X-Project-ID header,
+// with the public ID shown by the web app. Session tokens carry no encryption
+// keys, so paths and metadata are encrypted with the access grant in the
+// X-Metasearch-Access header, which must belong to the project. Permissions
+// are derived from the project role of the user, not from the API key of the
+// access grant. Requests without a session token are authenticated by the
+// wrapped Authenticator.
+type ConsoleSessionAuth struct {
+	auth            Authenticator
+	tokens          *consoleauth.Service
+	readOnlyMembers bool
+
+	getSession func(ctx context.Context, sessionID uuid.UUID) (consoleauth.WebappSession, error)
+	getProject func(ctx context.Context, publicID uuid.UUID) (*console.Project, error)
+	getMember  func(ctx context.Context, memberID, projectID uuid.UUID) (*console.ProjectMember, error)
+	openAccess func(ctx context.Context, access string) (encryptor Encryptor, projectID uuid.UUID, err error)
+}
+
+// NewConsoleSessionAuth creates a new ConsoleSessionAuth. The secret is the
+// auth token secret of the satellite console. If readOnlyMembers is true,
+// only project admins can change metadata.
+func NewConsoleSessionAuth(auth Authenticator, db satellite.DB, secret string, readOnlyMembers bool) *ConsoleSessionAuth {
+	keys := newAPIKeyCache(func(ctx context.Context, head []byte) (*console.APIKeyInfo, error) {
+		return db.Console().APIKeys().GetByHead(ctx, head)
+	})
+
+	return &ConsoleSessionAuth{
+		auth:            auth,
+		tokens:          consoleauth.NewService(consoleauth.Config{}, &consoleauth.Hmac{Secret: []byte(secret)}),
+		readOnlyMembers: readOnlyMembers,
+		getSession:      db.Console().WebappSessions().GetBySessionID,
+		getProject:      db.Console().Projects().GetByPublicID,
+		getMember:       db.Console().ProjectMembers().GetByMemberIDAndProjectID,
+		openAccess: func(ctx context.Context, rawAccess string) (Encryptor, uuid.UUID, error) {
+			access, err := uplink.ParseAccess(rawAccess)
+			if err != nil {
+				return nil, uuid.UUID{}, err
+			}
+			keyInfo, err := keys.Get(ctx, accessGetAPIKey(access).Head())
+			if err != nil {
+				return nil, uuid.UUID{}, fmt.Errorf("cannot find project by API key: %w", err)
+			}
+			return NewUplinkEncryptor(access), keyInfo.ProjectID, nil
+		},
+	}
+}
+
+// sessionToken returns the console session token of a request, if any.
+func sessionToken(r *http.Request) string {
+	if token := r.Header.Get(consoleSessionHeader); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie(consoleSessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+func (a *ConsoleSessionAuth) Authenticate(ctx context.Context, r *http.Request) (projectID uuid.UUID, encryptor Encryptor, authorizer Authorizer, err error) {
+	rawToken := sessionToken(r)
+	if rawToken == "" || r.Header.Get("Authorization") != "" {
+		return a.auth.Authenticate(ctx, r)
+	}
+
+	// Validate session token
+	token, err := consoleauth.FromBase64URLString(rawToken)
+	if err != nil {
+		err = fmt.Errorf("%w: invalid session token", ErrAuthorizationFailed)
+		return
+	}
+	valid, err := a.tokens.ValidateToken(token)
+	if err != nil || !valid {
+		err = fmt.Errorf("%w: invalid session token", ErrAuthorizationFailed)
+		return
+	}
+	sessionID, err := uuid.FromBytes(token.Payload)
+	if err != nil {
+		err = fmt.Errorf("%w: invalid session token", ErrAuthorizationFailed)
+		return
+	}
+	session, err := a.getSession(ctx, sessionID)
+	if err != nil || !session.ExpiresAt.After(time.Now()) {
+		err = fmt.Errorf("%w: session expired", ErrAuthorizationFailed)
+		return
+	}
+
+	// Check project membership. The web app only knows the public ID of the
+	// project, members are stored with its internal ID.
+	publicID, err := uuid.FromString(r.Header.Get(consoleProjectHeader))
+	if err != nil {
+		err = fmt.Errorf("%w: missing or invalid %s header", ErrBadRequest, consoleProjectHeader)
+		return
+	}
+	project, err := a.getProject(ctx, publicID)
+	if err != nil {
+		err = fmt.Errorf("%w: not a member of the project", ErrForbidden)
+		return
+	}
+	projectID = project.ID
+	member, err := a.getMember(ctx, session.UserID, projectID)
+	if err != nil {
+		err = fmt.Errorf("%w: not a member of the project", ErrForbidden)
+		return
+	}
+
+	// The access grant only encrypts paths and metadata, but it must belong
+	// to the project, so that members cannot use the keys of other projects
+	rawAccess := r.Header.Get(consoleAccessHeader)
+	if rawAccess == "" {
+		err = fmt.Errorf("%w: missing %s header", ErrBadRequest, consoleAccessHeader)
+		return
+	}
+	encryptor, accessProjectID, err := a.openAccess(ctx, rawAccess)
+	if err != nil {
+		err = fmt.Errorf("%w: cannot parse access grant: %v", ErrBadRequest, err)
+		return
+	}
+	if accessProjectID != projectID {
+		err = fmt.Errorf("%w: the access grant belongs to another project", ErrForbidden)
+		return
+	}
+
+	switch {
+	case member.Role == console.RoleAdmin:
+		authorizer = &memberAuthorizer{}
+	case member.Role == console.RoleMember:
+		authorizer = &memberAuthorizer{readOnly: a.readOnlyMembers}
+	default:
+		err = fmt.Errorf("%w: unknown project role", ErrForbidden)
+	}
+	return
+}
+
+// memberAuthorizer authorizes the requests of a member of the project.
+type memberAuthorizer struct {
+	readOnly bool
+}
+
+func (a *memberAuthorizer) Authorize(ctx context.Context, encryptedLocation ObjectLocation, action Action) error {
+	if a.readOnly && action != ActionReadMetadata && action != ActionQueryMetadata {
+		return fmt.Errorf("%w: project members have read-only access", ErrForbidden)
+	}
+	return nil
+}
+
+// allowConsoleOrigin allows cross-origin requests from the satellite web app,
+// whose origin is configured with ConsoleOrigin, and answers their preflight
+// requests. Requests from other origins are served without CORS headers, so
+// browsers do not expose the responses.
+func (s *Server) allowConsoleOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Config.ConsoleOrigin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if r.Header.Get("Origin") != s.Config.ConsoleOrigin {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Session cookies are sent with credentialed requests
+		w.Header().Set("Access-Control-Allow-Origin", s.Config.ConsoleOrigin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(consoleAllowedMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(consoleAllowedHeaders, ", "))
+			w.Header().Set("Access-Control-Max-Age", consolePreflightMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(consoleExposedHeaders, ", "))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,175 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+
+	"storj.io/common/uuid"
+	"storj.io/storj/satellite/console"
+	"storj.io/storj/satellite/console/consoleauth"
+)
+
+func TestConsoleSessionAuth(t *testing.T) {
+	server := testServer()
+	tokens := consoleauth.NewService(consoleauth.Config{}, &consoleauth.Hmac{Secret: []byte("secret")})
+
+	// The web app sends the public ID of the project
+	publicID, err := uuid.FromString(testProjectID)
+	require.NoError(t, err)
+	projectID := testrandUUID(t)
+	adminID, memberID := testrandUUID(t), testrandUUID(t)
+	sessions := map[uuid.UUID]consoleauth.WebappSession{}
+	newSession := func(userID uuid.UUID, expiresAt time.Time) string {
+		session := consoleauth.WebappSession{ID: testrandUUID(t), UserID: userID, ExpiresAt: expiresAt}
+		sessions[session.ID] = session
+
+		token := consoleauth.Token{Payload: session.ID.Bytes()}
+		token.Signature, err = tokens.SignToken(token)
+		require.NoError(t, err)
+		return token.String()
+	}
+
+	server.Auth = &ConsoleSessionAuth{
+		auth:            server.Auth,
+		tokens:          tokens,
+		readOnlyMembers: true,
+		getSession: func(ctx context.Context, sessionID uuid.UUID) (consoleauth.WebappSession, error) {
+			session, ok := sessions[sessionID]
+			if !ok {
+				return session, errors.New("session not found")
+			}
+			return session, nil
+		},
+		getProject: func(ctx context.Context, id uuid.UUID) (*console.Project, error) {
+			if id != publicID {
+				return nil, errors.New("project not found")
+			}
+			return &console.Project{ID: projectID, PublicID: publicID}, nil
+		},
+		getMember: func(ctx context.Context, userID, id uuid.UUID) (*console.ProjectMember, error) {
+			switch {
+			case id != projectID:
+				return nil, errors.New("member not found")
+			case userID == adminID:
+				return &console.ProjectMember{MemberID: userID, ProjectID: id, Role: console.RoleAdmin}, nil
+			case userID == memberID:
+				return &console.ProjectMember{MemberID: userID, ProjectID: id, Role: console.RoleMember}, nil
+			}
+			return nil, errors.New("member not found")
+		},
+		openAccess: func(ctx context.Context, access string) (Encryptor, uuid.UUID, error) {
+			if access == "otheraccess" {
+				return &mockEncryptor{}, testrandUUID(t), nil
+			}
+			return &mockEncryptor{}, projectID, nil
+		},
+	}
+
+	sessionRequestWith := func(method, path, body, token, project, access string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(method, path, body)
+		r.Header.Del("Authorization")
+		r.Header.Set("X-Project-ID", project)
+		r.Header.Set("X-Metasearch-Access", access)
+		r.AddCookie(&http.Cookie{Name: "_tokenKey", Value: token})
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+	sessionRequest := func(method, path, body, token string) *httptest.ResponseRecorder {
+		return sessionRequestWith(method, path, body, token, testProjectID, "access")
+	}
+
+	// Admins can change metadata
+	admin := newSession(adminID, time.Now().Add(time.Hour))
+	rr := sessionRequest(http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`, admin)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Members are read-only
+	member := newSession(memberID, time.Now().Add(time.Hour))
+	rr = sessionRequest(http.MethodGet, "/metadata/testbucket/foo.txt", "", member)
+	assertResponse(t, rr, http.StatusOK, `{"foo": "bar"}`)
+
+	rr = sessionRequest(http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "baz"}`, member)
+	assert.Equal(t, rr.Code, http.StatusForbidden)
+
+	// Non-members, expired and forged sessions
+	rr = sessionRequest(http.MethodGet, "/metadata/testbucket/foo.txt", "", newSession(testrandUUID(t), time.Now().Add(time.Hour)))
+	assert.Equal(t, rr.Code, http.StatusForbidden)
+
+	rr = sessionRequest(http.MethodGet, "/metadata/testbucket/foo.txt", "", newSession(adminID, time.Now().Add(-time.Hour)))
+	assert.Equal(t, rr.Code, http.StatusUnauthorized)
+
+	forged := consoleauth.Token{Payload: testrandUUID(t).Bytes(), Signature: []byte("signature")}
+	rr = sessionRequest(http.MethodGet, "/metadata/testbucket/foo.txt", "", forged.String())
+	assert.Equal(t, rr.Code, http.StatusUnauthorized)
+
+	// Projects are identified by their public ID
+	rr = sessionRequestWith(http.MethodGet, "/metadata/testbucket/foo.txt", "", admin, projectID.String(), "access")
+	assert.Equal(t, rr.Code, http.StatusForbidden)
+
+	// Access grants of other projects are rejected
+	rr = sessionRequestWith(http.MethodGet, "/metadata/testbucket/foo.txt", "", admin, testProjectID, "otheraccess")
+	assert.Equal(t, rr.Code, http.StatusForbidden)
+
+	// Requests with an Authorization header use the wrapped authenticator
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "baz"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+}
+
+func TestConsoleOrigin(t *testing.T) {
+	server := testServer()
+
+	originRequest := func(method, origin string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(method, "/metadata/testbucket/foo.txt", "")
+		r.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+			r.Header.Set("Access-Control-Request-Headers", "X-Project-ID, X-Metasearch-Access")
+		}
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	// Cross-origin requests are not allowed by default
+	rr := originRequest(http.MethodGet, "https://console.example.com")
+	assert.Equal(t, rr.Header().Get("Access-Control-Allow-Origin"), "")
+
+	server.Config.ConsoleOrigin = "https://console.example.com"
+
+	// Preflight requests of the web app
+	rr = originRequest(http.MethodOptions, "https://console.example.com")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	assert.Equal(t, rr.Header().Get("Access-Control-Allow-Origin"), "https://console.example.com")
+	assert.Equal(t, rr.Header().Get("Access-Control-Allow-Credentials"), "true")
+	require.Contains(t, rr.Header().Get("Access-Control-Allow-Methods"), http.MethodPut)
+	require.Contains(t, rr.Header().Get("Access-Control-Allow-Headers"), "X-Metasearch-Access")
+
+	// Requests of the web app
+	rr = originRequest(http.MethodGet, "https://console.example.com")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+	assert.Equal(t, rr.Header().Get("Access-Control-Allow-Origin"), "https://console.example.com")
+	require.Contains(t, rr.Header().Get("Access-Control-Expose-Headers"), "ETag")
+
+	// Other origins
+	rr = originRequest(http.MethodOptions, "https://evil.example.com")
+	assert.Equal(t, rr.Header().Get("Access-Control-Allow-Origin"), "")
+	assert.NotEqual(t, rr.Code, http.StatusNoContent)
+	require.Contains(t, rr.Header().Values("Vary"), "Origin")
+}
+
+func testrandUUID(t *testing.T) uuid.UUID {
+	id, err := uuid.New()
+	require.NoError(t, err)
+	return id
+}
@@ -0,0 +1,149 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import "fmt"
+
+// Weights of the components of the estimated cost of a search, in units of
+// rows examined by the metabase.
+const (
+	// leafCost is the cost of an indexed containment leaf, which adds a
+	// lookup in the inverted index.
+	leafCost = 10
+	// subqueryCost is the cost of an anyOf, allOf or not subquery.
+	subqueryCost = 10
+	// conditionCost is the cost per scanned row of a condition that cannot
+	// use the index, such as an operator or a system condition.
+	conditionCost = 1
+	// filterCharsPerCost is the number of characters of JMESPath filter and
+	// projection expressions that cost one unit per scanned row.
+	filterCharsPerCost = 100
+	// unindexedScanFactor is the number of rows expected to be scanned per
+	// result if the search is not narrowed down by the index or a key prefix.
+	unindexedScanFactor = 10
+)
+
+// QueryCost is the estimated cost of a search, computed before it is sent to
+// the metabase.
+type QueryCost struct {
+	// Leaves is the number of containment leaves of the match query.
+	Leaves int `json:"leaves"`
+	// Conditions is the number of conditions that cannot use the index.
+	Conditions int `json:"conditions"`
+	// Subqueries is the number of anyOf, allOf and not subqueries.
+	Subqueries int `json:"subqueries"`
+	// FilterLength is the length of the filter and projection expressions.
+	FilterLength int `json:"filterLength"`
+	// Indexed is true if the search is narrowed down by the index or a key
+	// prefix.
+	Indexed bool `json:"indexed"`
+	// ScannedRows is the expected number of rows scanned by the metabase.
+	ScannedRows int `json:"scannedRows"`
+
+	Total int `json:"total"`
+}
+
+// String returns the components of the cost, for error messages.
+func (c QueryCost) String() string {
+	return fmt.Sprintf("%d containment leaves, %d unindexed conditions, %d subqueries, %d filter characters, %d expected scanned rows",
+		c.Leaves, c.Conditions, c.Subqueries, c.FilterLength, c.ScannedRows)
+}
+
+// estimateQueryCost estimates the cost of a validated search request.
+func estimateQueryCost(request *SearchRequest, match matchQuery) QueryCost {
+	var cost QueryCost
+	cost.addMatch(match)
+	cost.FilterLength = len(request.Filter) + len(request.Projection)
+	cost.Indexed = match.indexed() || request.KeyPrefix != ""
+
+	// Pages filtered by key pattern or grouped by delimiter read up to
+	// maxRefillBatches batches, and similarity searches rank up to
+	// maxSimilarityCandidates objects.
+	batches := 1
+	switch {
+	case request.SimilarTo != nil:
+		batches = (maxSimilarityCandidates + request.BatchSize - 1) / request.BatchSize
+	case request.KeyPattern != "" || request.KeyRegex != "" || request.Delimiter != "":
+		batches = maxRefillBatches
+	}
+	cost.ScannedRows = request.BatchSize * batches
+	if !cost.Indexed && (cost.Conditions > 0 || cost.FilterLength > 0) {
+		cost.ScannedRows *= unindexedScanFactor
+	}
+
+	perRow := 1 + cost.Conditions*conditionCost + cost.FilterLength/filterCharsPerCost
+	cost.Total = cost.Leaves*leafCost + cost.Subqueries*subqueryCost + cost.ScannedRows*perRow
+	return cost
+}
+
+// addMatch adds the leaves, conditions and subqueries of a match query.
+func (c *QueryCost) addMatch(q matchQuery) {
+	splitToLeafValues(q.contains, func(interface{}) { c.Leaves++ })
+	c.Conditions += len(q.conditions) + len(q.exists) + len(q.missing) + len(q.system)
+	for _, subquery := range q.allOf {
+		c.Subqueries++
+		c.addMatch(subquery)
+	}
+	for _, alternative := range q.anyOf {
+		c.Subqueries++
+		c.addMatch(alternative)
+	}
+	if q.not != nil {
+		c.Subqueries++
+		c.addMatch(*q.not)
+	}
+}
+
+// indexed returns true if the matching objects can be found with the
+// inverted index, without scanning the other objects.
+func (q matchQuery) indexed() bool {
+	leaves := 0
+	splitToLeafValues(q.contains, func(interface{}) { leaves++ })
+	if leaves > 0 {
+		return true
+	}
+	for _, subquery := range q.allOf {
+		if subquery.indexed() {
+			return true
+		}
+	}
+	if len(q.anyOf) == 0 {
+		return false
+	}
+	for _, alternative := range q.anyOf {
+		if !alternative.indexed() {
+			return false
+		}
+	}
+	return true
+}
+
+// checkQueryCost rejects searches whose estimated cost exceeds the limit.
+func (s *Server) checkQueryCost(cost QueryCost) error {
+	if s.Config.MaxQueryCost > 0 && cost.Total > s.Config.MaxQueryCost {
+		return fmt.Errorf("%w: estimated query cost %d exceeds the limit of %d (%s), narrow down the search with indexed values or a key prefix, or reduce the batch size",
+			ErrUnprocessableEntity, cost.Total, s.Config.MaxQueryCost, cost)
+	}
+	return nil
+}
+
+// throttleQuery limits the number of concurrent searches whose estimated
+// cost is at least ExpensiveQueryCost. The returned function releases the
+// slot of the search.
+func (s *Server) throttleQuery(cost QueryCost) (release func(), err error) {
+	if s.expensiveQueries == nil || cost.Total < s.Config.ExpensiveQueryCost {
+		return func() {}, nil
+	}
+	select {
+	case s.expensiveQueries <- struct{}{}:
+		mon.Counter("expensive_queries").Inc(1)
+		return func() {
+			<-s.expensiveQueries
+			mon.Counter("expensive_queries").Dec(1)
+		}, nil
+	default:
+		mon.Counter("expensive_queries_throttled").Inc(1)
+		return nil, fmt.Errorf("%w: too many expensive searches, retry later or narrow down the search", ErrTooManyRequests)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestEstimateQueryCost(t *testing.T) {
+	server := testServer()
+	estimate := func(request SearchRequest) QueryCost {
+		require.NoError(t, server.validateSearchRequest(&request))
+		return request.cost
+	}
+
+	// Indexed containment leaves
+	cost := estimate(SearchRequest{Match: map[string]interface{}{
+		"a": float64(1),
+		"b": map[string]interface{}{"c": "x"},
+	}})
+	require.Equal(t, QueryCost{Leaves: 2, Indexed: true, ScannedRows: 100, Total: 120}, cost)
+
+	// Conditions that cannot use the index scan more rows
+	cost = estimate(SearchRequest{
+		Match:     map[string]interface{}{"size": map[string]interface{}{"$gt": json.Number("100")}},
+		BatchSize: 1000,
+	})
+	require.Equal(t, QueryCost{Conditions: 1, ScannedRows: 10000, Total: 20000}, cost)
+
+	// Subqueries, whose alternatives are all indexed
+	cost = estimate(SearchRequest{
+		AnyOf:      []map[string]interface{}{{"a": float64(1)}, {"b": float64(2)}},
+		KeyPattern: "*.jpg",
+	})
+	require.Equal(t, QueryCost{Leaves: 2, Subqueries: 2, Indexed: true, ScannedRows: 1000, Total: 1040}, cost)
+
+	// Similarity searches rank all candidates
+	cost = estimate(SearchRequest{SimilarTo: &SimilarTo{Key: "embedding", Vector: []float64{1, 0}}})
+	require.Equal(t, maxSimilarityCandidates, cost.ScannedRows)
+}
+
+func TestQueryCostLimits(t *testing.T) {
+	server := testServer()
+	server.Config.MaxQueryCost = 1000
+
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"foo": "bar"}}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("X-Metasearch-Query-Cost"), "110")
+
+	// Too expensive
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"size": {"$gt": 100}}}`)
+	assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+	require.Contains(t, rr.Body.String(), "estimated query cost 2000 exceeds the limit of 1000")
+
+	// Expensive searches are throttled
+	server.Config.MaxQueryCost = 0
+	server.Config.ExpensiveQueryCost = 1000
+	server.expensiveQueries = make(chan struct{}, 1)
+	server.expensiveQueries <- struct{}{}
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"size": {"$gt": 100}}}`)
+	assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"foo": "bar"}}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	<-server.expensiveQueries
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"size": {"$gt": 100}}}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, len(server.expensiveQueries), 0)
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CountResponse is the response of a search with countOnly.
+type CountResponse struct {
+	Count int64 `json:"count"`
+}
+
+func (r *MetabaseSearchRepository) CountMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}) (int64, error) {
+	match, err := parseMatch(containsQuery)
+	if err != nil {
+		return 0, err
+	}
+
+	b := &matchQueryBuilder{loc: loc, dialect: r.dialect, tables: r.tables}
+	filter, err := b.searchFilter(match)
+	if err != nil {
+		return 0, err
+	}
+	query := "SELECT count(*) FROM " + b.table(defaultPrimaryKey) + " WHERE " + filter + b.pageRange(loc, ObjectLocation{})
+
+	var count int64
+	err = r.db.QueryRowContext(ctx, query, b.args...).Scan(&count)
+	if isMissingIndexError(err) {
+		// Counting without the index would scan the whole bucket
+		return 0, fmt.Errorf("%w: counts are unavailable while the metadata index is rebuilt", ErrServiceUnavailable)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	return count, nil
+}
+
+// validateWithoutObjects checks that a search computed in the database, such
+// as a count, has no options that require fetching the objects.
+func validateWithoutObjects(request *SearchRequest, option string) error {
+	if request.Filter != "" || request.KeyPattern != "" || request.KeyRegex != "" {
+		return fmt.Errorf("%w: %s cannot be used with filter, keyPattern or keyRegex", ErrBadRequest, option)
+	}
+	if request.PageToken != "" {
+		return fmt.Errorf("%w: %s cannot be used with pageToken", ErrBadRequest, option)
+	}
+	return nil
+}
+
+// countMetadata counts the objects matched by a search.
+func (s *Server) countMetadata(ctx context.Context, request *SearchRequest) (CountResponse, error) {
+	count, err := s.Repo.CountMetadata(ctx, request.EncryptedLocation, request.Match)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return CountResponse{}, fmt.Errorf("%w: search timed out", ErrServiceUnavailable)
+	}
+	if err != nil {
+		return CountResponse{}, err
+	}
+	return CountResponse{Count: count}, nil
+}
@@ -0,0 +1,165 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros are the supported shorthands of cron expressions.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// maxCronSearch is how far ahead the next time of a cron schedule is
+// searched, so that expressions that never match, like February 30, end.
+const maxCronSearch = 5 * 365 * 24 * time.Hour
+
+// cronSchedule is a parsed cron expression with the standard fields minute,
+// hour, day of month, month and day of week, in UTC. Each field is a bit set
+// of the allowed values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// If both the day of month and the day of week are restricted, either
+	// of them matches, like in crontab.
+	domRestricted, dowRestricted bool
+}
+
+// parseCron parses a cron expression such as "30 2 * * 1-5", or a macro such
+// as "@daily". Fields support lists, ranges and steps, e.g. "0,30" or
+// "*/15".
+func parseCron(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: cron expression must have 5 fields", ErrBadRequest)
+	}
+
+	var c cronSchedule
+	var err error
+	for _, f := range []struct {
+		name     string
+		field    string
+		min, max int
+		bits     *uint64
+	}{
+		{"minute", fields[0], 0, 59, &c.minute},
+		{"hour", fields[1], 0, 23, &c.hour},
+		{"day of month", fields[2], 1, 31, &c.dom},
+		{"month", fields[3], 1, 12, &c.month},
+		{"day of week", fields[4], 0, 7, &c.dow},
+	} {
+		*f.bits, err = parseCronField(f.field, f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s in cron expression: %v", ErrBadRequest, f.name, err)
+		}
+	}
+
+	// Sunday is both 0 and 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domRestricted = !strings.HasPrefix(fields[2], "*")
+	c.dowRestricted = !strings.HasPrefix(fields[4], "*")
+
+	if c.next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, fmt.Errorf("%w: cron expression never matches", ErrBadRequest)
+	}
+	return &c, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps
+// between min and max.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step '%s'", stepPart)
+			}
+		}
+
+		start, end := min, max
+		if rangePart != "*" {
+			lo, hi, isRange := strings.Cut(rangePart, "-")
+			var err error
+			start, err = strconv.Atoi(lo)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value '%s'", lo)
+			}
+			end = start
+			if isRange {
+				end, err = strconv.Atoi(hi)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value '%s'", hi)
+				}
+			} else if hasStep {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("'%s' is out of range %d-%d", rangePart, min, max)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time of the schedule after t, or the zero time if
+// there is none in the next years.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxCronSearch)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
@@ -0,0 +1,52 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	// Wednesday
+	now := time.Date(2025, 1, 15, 10, 30, 20, 0, time.UTC)
+
+	for expr, expected := range map[string]time.Time{
+		"* * * * *":       time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC),
+		"*/15 * * * *":    time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC),
+		"0 2 * * *":       time.Date(2025, 1, 16, 2, 0, 0, 0, time.UTC),
+		"@daily":          time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC),
+		"@hourly":         time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC),
+		"0 9 * * 1-5":     time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC),
+		"0 0 * * 7":       time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC),
+		"30 10 1 * *":     time.Date(2025, 2, 1, 10, 30, 0, 0, time.UTC),
+		"0 0 1 * 1":       time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC),
+		"0,30 12 * 3 *":   time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":      time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		"15-20/5 * * * *": time.Date(2025, 1, 15, 11, 15, 0, 0, time.UTC),
+	} {
+		c, err := parseCron(expr)
+		require.NoError(t, err, expr)
+		require.Equal(t, expected, c.next(now), expr)
+	}
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"0 0 30 2 *",
+	} {
+		_, err := parseCron(expr)
+		require.Error(t, err, expr)
+	}
+}
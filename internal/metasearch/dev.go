@@ -0,0 +1,86 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"storj.io/common/uuid"
+)
+
+// DevProjectID is the project of requests authenticated by DevAuth without
+// an X-Project-ID header.
+var DevProjectID = uuid.UUID{0xde, 0x7e, 0x10, 0x9e}
+
+// DevAuth authenticates all requests, for local development with a
+// MemoryRepository. Requests are in the project of the X-Project-ID header,
+// or in DevProjectID. Nothing is encrypted: paths are stored as is, and
+// metadata as plain JSON. It must never be used with a metabase.
+type DevAuth struct{}
+
+// NewDevAuth creates a new DevAuth.
+func NewDevAuth() *DevAuth {
+	return &DevAuth{}
+}
+
+func (a *DevAuth) Authenticate(ctx context.Context, r *http.Request) (projectID uuid.UUID, encryptor Encryptor, authorizer Authorizer, err error) {
+	projectID = DevProjectID
+	if header := r.Header.Get(consoleProjectHeader); header != "" {
+		projectID, err = uuid.FromString(header)
+		if err != nil {
+			return uuid.UUID{}, nil, nil, fmt.Errorf("%w: invalid project ID: %v", ErrAuthorizationFailed, err)
+		}
+	}
+	return projectID, devEncryptor{}, devAuthorizer{}, nil
+}
+
+// devAuthorizer allows all actions.
+type devAuthorizer struct{}
+
+func (devAuthorizer) Authorize(ctx context.Context, encryptedLocation ObjectLocation, action Action) error {
+	return nil
+}
+
+// devEncryptor leaves paths unchanged and stores metadata as plain JSON.
+type devEncryptor struct{}
+
+func (devEncryptor) EncryptPath(bucket string, path string) (string, error) {
+	return path, nil
+}
+
+func (devEncryptor) DecryptPath(bucket string, path string) (string, error) {
+	return path, nil
+}
+
+func (devEncryptor) EncryptMetadata(bucket string, path string, meta *ObjectMetadata) error {
+	buf, err := json.Marshal(meta.ClearMetadata)
+	if err != nil {
+		return err
+	}
+	meta.EncryptedMetadata = buf
+	return nil
+}
+
+func (devEncryptor) DecryptMetadata(bucket string, path string, meta *ObjectMetadata) error {
+	if len(meta.EncryptedMetadata) == 0 {
+		meta.ClearMetadata = nil
+		return nil
+	}
+	var metadata map[string]interface{}
+	if err := unmarshalJSON(meta.EncryptedMetadata, &metadata); err != nil {
+		return err
+	}
+	meta.ClearMetadata = metadata
+	return nil
+}
+
+func (devEncryptor) Compare(other Encryptor) EncryptorComparisonResult {
+	if _, ok := other.(devEncryptor); ok {
+		return EncryptorComparisonIdentical
+	}
+	return EncryptorComparisonDifferent
+}
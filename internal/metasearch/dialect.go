@@ -0,0 +1,123 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"storj.io/storj/shared/dbutil"
+	"storj.io/storj/shared/tagsql"
+)
+
+// Dialect is the SQL dialect of the metabase database.
+type Dialect int
+
+const (
+	// CockroachDB is the dialect of CockroachDB metabases.
+	CockroachDB Dialect = iota
+	// PostgreSQL is the dialect of plain PostgreSQL metabases.
+	PostgreSQL
+)
+
+// String returns the name of the dialect.
+func (d Dialect) String() string {
+	switch d {
+	case CockroachDB:
+		return "cockroach"
+	case PostgreSQL:
+		return "postgres"
+	default:
+		return "unknown"
+	}
+}
+
+// OpenMetabase opens the metabase database at url. The dialect is chosen by
+// the scheme of the URL, like in the satellite: cockroach:// for CockroachDB,
+// and postgres:// or postgresql:// for PostgreSQL.
+func OpenMetabase(ctx context.Context, url string) (tagsql.DB, Dialect, error) {
+	_, _, impl, err := dbutil.SplitConnStr(url)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch impl {
+	case dbutil.Cockroach:
+		db, err := tagsql.Open(ctx, "cockroach", url)
+		return db, CockroachDB, err
+	case dbutil.Postgres:
+		db, err := tagsql.Open(ctx, "pgx", url)
+		return db, PostgreSQL, err
+	default:
+		return nil, 0, fmt.Errorf("unsupported metabase database: %s", impl)
+	}
+}
+
+// table returns the table reference of a query reading table with index.
+// CockroachDB is forced to use the index, which it does not always pick by
+// itself, while PostgreSQL has no index hints and chooses on its own.
+func (d Dialect) table(table, index string) string {
+	if d == CockroachDB {
+		return table + "@" + index
+	}
+	return table
+}
+
+// index returns the reference to an index of table in DDL statements.
+// PostgreSQL indexes are in the schema of their table.
+func (d Dialect) index(table, index string) string {
+	if d == CockroachDB {
+		return table + "@" + index
+	}
+	if schema, _, ok := strings.Cut(table, "."); ok {
+		return schema + "." + index
+	}
+	return index
+}
+
+// asOf returns the clause that reads a query at the snapshot of asOf, or an
+// empty string if asOf is zero. PostgreSQL has no historical reads, so its
+// queries always read the latest data.
+func (d Dialect) asOf(asOf time.Time) string {
+	if d != CockroachDB || asOf.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("AS OF SYSTEM TIME %d\n", asOf.UnixNano())
+}
+
+// createIndex returns the statement that creates a GIN index. PostgreSQL
+// blocks writes to the table while an index is created, unless it is created
+// concurrently, which CockroachDB always does.
+func (d Dialect) createIndex(table, index, columns string) string {
+	if d == CockroachDB {
+		return fmt.Sprintf("CREATE INDEX %s ON %s USING GIN (%s)", index, table, columns)
+	}
+	return fmt.Sprintf("CREATE INDEX CONCURRENTLY %s ON %s USING GIN (%s)", index, table, columns)
+}
+
+// indexProgressQuery returns the query of the completed fraction of an
+// index being created, whose name is the first argument.
+func (d Dialect) indexProgressQuery() string {
+	if d == CockroachDB {
+		return `
+			SELECT fraction_completed
+			FROM crdb_internal.jobs
+			WHERE
+				job_type IN ('SCHEMA CHANGE', 'NEW SCHEMA CHANGE') AND
+				status = 'running' AND
+				description LIKE '%' || $1 || '%'
+			ORDER BY created DESC
+			LIMIT 1
+			`
+	}
+	return `
+		SELECT p.blocks_done::FLOAT8 / NULLIF(p.blocks_total, 0)
+		FROM pg_stat_progress_create_index p
+		JOIN pg_class c ON c.oid = p.index_relid
+		WHERE c.relname = $1
+		LIMIT 1
+		`
+}
@@ -0,0 +1,71 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestDialect(t *testing.T) {
+	asOf := time.Unix(0, 1620721781789035200)
+
+	// CockroachDB forces indexes and reads snapshots
+	assert.Equal(t, CockroachDB.table("objects", "objects_pkey"), "objects@objects_pkey")
+	assert.Equal(t, CockroachDB.index("objects", "objects_clear_metadata_idx"), "objects@objects_clear_metadata_idx")
+	assert.Equal(t, CockroachDB.asOf(asOf), "AS OF SYSTEM TIME 1620721781789035200\n")
+	assert.Equal(t, CockroachDB.asOf(time.Time{}), "")
+	assert.Equal(t, CockroachDB.createIndex("objects", "idx", "clear_metadata"), "CREATE INDEX idx ON objects USING GIN (clear_metadata)")
+
+	// PostgreSQL has neither index hints nor historical reads
+	assert.Equal(t, PostgreSQL.table("objects", "objects_pkey"), "objects")
+	assert.Equal(t, PostgreSQL.index("objects", "objects_clear_metadata_idx"), "objects_clear_metadata_idx")
+	assert.Equal(t, PostgreSQL.asOf(asOf), "")
+	assert.Equal(t, PostgreSQL.createIndex("objects", "idx", "clear_metadata"), "CREATE INDEX CONCURRENTLY idx ON objects USING GIN (clear_metadata)")
+}
+
+func TestDialectSubqueries(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{"camera": "x100"})
+	require.NoError(t, err)
+
+	b := &matchQueryBuilder{}
+	subqueries, err := b.containsSubqueries(query)
+	require.NoError(t, err)
+	require.Len(t, subqueries, 1)
+	require.Contains(t, subqueries[0], "FROM objects@objects_clear_metadata_idx WHERE clear_metadata @> $1")
+
+	b = &matchQueryBuilder{dialect: PostgreSQL}
+	subqueries, err = b.containsSubqueries(query)
+	require.NoError(t, err)
+	require.Len(t, subqueries, 1)
+	require.Contains(t, subqueries[0], "FROM objects WHERE clear_metadata @> $1")
+}
+
+func TestMetabaseTables(t *testing.T) {
+	tables := MetabaseTables{
+		Objects:       "metabase.objects",
+		MetadataIndex: "metadata_idx",
+	}
+	require.NoError(t, tables.Validate())
+
+	query, err := parseMatch(map[string]interface{}{"camera": "x100"})
+	require.NoError(t, err)
+
+	b := &matchQueryBuilder{tables: tables}
+	subqueries, err := b.containsSubqueries(query)
+	require.NoError(t, err)
+	require.Len(t, subqueries, 1)
+	require.Contains(t, subqueries[0], "FROM metabase.objects@metadata_idx WHERE clear_metadata @> $1")
+	assert.Equal(t, b.table(defaultPrimaryKey), "metabase.objects@objects_pkey")
+
+	// PostgreSQL indexes are in the schema of their table
+	assert.Equal(t, PostgreSQL.index(tables.objects(), "metadata_idx"), "metabase.metadata_idx")
+
+	// Names are inserted in queries as is
+	require.Error(t, MetabaseTables{Objects: "objects; DROP TABLE objects"}.Validate())
+	require.Error(t, MetabaseTables{PrimaryKey: "metabase.objects_pkey"}.Validate())
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// DistinctResponse contains the distinct values of a metadata key, the most
+// frequent values first.
+type DistinctResponse struct {
+	Values []interface{} `json:"values"`
+
+	// Truncated is true if there are more values than the limit.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// HandleDistinct returns the distinct values of a clear metadata key in a
+// bucket, optionally under a key prefix, e.g. to populate filter dropdowns.
+// Parameters are passed in the URL query string.
+func (s *Server) HandleDistinct(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request SearchRequest
+
+	err := s.validateRequest(ctx, r, &request.BaseRequest, nil)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	key := q.Get("key")
+	if key == "" {
+		s.errorResponse(w, fmt.Errorf("%w: key is missing", ErrBadRequest))
+		return
+	}
+	limit := defaultBatchSize
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxBatchSize {
+			s.errorResponse(w, fmt.Errorf("%w: invalid limit", ErrBadRequest))
+			return
+		}
+	}
+
+	// Only objects with the key are grouped
+	request.KeyPrefix = q.Get("prefix")
+	request.Exists = []string{key}
+	err = s.validateSearchRequest(&request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	// One more group is requested to detect truncated results
+	groups, err := s.Repo.AggregateMetadata(ctx, request.EncryptedLocation, request.Match, MetadataAggregation{
+		GroupBy: key,
+		Limit:   limit + 1,
+	})
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	response := DistinctResponse{
+		Values: make([]interface{}, 0, len(groups)),
+	}
+	for _, group := range groups {
+		response.Values = append(response.Values, group.Value)
+	}
+	if len(response.Values) > limit {
+		response.Values = response.Values[:limit]
+		response.Truncated = true
+	}
+
+	s.jsonResponse(w, http.StatusOK, response)
+}
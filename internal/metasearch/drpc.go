@@ -0,0 +1,324 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"go.uber.org/zap"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcerr"
+	"storj.io/drpc/drpcmux"
+	"storj.io/drpc/drpcserver"
+)
+
+// RPC names of the metasearch DRPC service.
+const (
+	rpcGetMetadata    = "/metasearch.MetaSearch/GetMetadata"
+	rpcUpdateMetadata = "/metasearch.MetaSearch/UpdateMetadata"
+	rpcDeleteMetadata = "/metasearch.MetaSearch/DeleteMetadata"
+	rpcSearchMetadata = "/metasearch.MetaSearch/SearchMetadata"
+)
+
+// GetMetadataRequest is the request of the GetMetadata RPC.
+type GetMetadataRequest struct {
+	// Authorization is the Authorization header of the equivalent HTTP
+	// request, e.g. "Bearer <access grant>".
+	Authorization string `json:"authorization"`
+	Bucket        string `json:"bucket"`
+	Key           string `json:"key"`
+	VersionID     string `json:"versionId,omitempty"`
+}
+
+// GetMetadataResponse is the response of the GetMetadata RPC.
+type GetMetadataResponse struct {
+	Metadata map[string]interface{} `json:"metadata"`
+	ETag     string                 `json:"etag"`
+}
+
+// UpdateMetadataRequest is the request of the UpdateMetadata RPC.
+type UpdateMetadataRequest struct {
+	Authorization string                 `json:"authorization"`
+	Bucket        string                 `json:"bucket"`
+	Key           string                 `json:"key"`
+	Metadata      map[string]interface{} `json:"metadata"`
+	// IfMatch only updates the metadata if its entity tag matches.
+	IfMatch string `json:"ifMatch,omitempty"`
+}
+
+// UpdateMetadataResponse is the response of the UpdateMetadata RPC.
+type UpdateMetadataResponse struct {
+	ETag string `json:"etag"`
+}
+
+// DeleteMetadataRequest is the request of the DeleteMetadata RPC.
+type DeleteMetadataRequest struct {
+	Authorization string `json:"authorization"`
+	Bucket        string `json:"bucket"`
+	Key           string `json:"key"`
+	IfMatch       string `json:"ifMatch,omitempty"`
+}
+
+// DeleteMetadataResponse is the response of the DeleteMetadata RPC.
+type DeleteMetadataResponse struct{}
+
+// SearchMetadataRequest is the request of the SearchMetadata RPC.
+type SearchMetadataRequest struct {
+	Authorization string `json:"authorization"`
+	Bucket        string `json:"bucket"`
+	// Search is the body of the equivalent HTTP search request.
+	Search json.RawMessage `json:"search"`
+}
+
+// drpcJSON encodes DRPC messages as JSON, so that the messages of the DRPC
+// service are the same as the ones of the HTTP API.
+type drpcJSON struct{}
+
+func (drpcJSON) Marshal(msg drpc.Message) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (drpcJSON) Unmarshal(buf []byte, msg drpc.Message) error {
+	return json.Unmarshal(buf, msg)
+}
+
+// DRPCEndpoint implements the metasearch operations over DRPC, for storj
+// services calling metasearch without HTTP. Calls are dispatched to the HTTP
+// handlers in process, so that they are authenticated, authorized and
+// validated the same way as HTTP requests.
+type DRPCEndpoint struct {
+	server *Server
+}
+
+// NewDRPCEndpoint creates the DRPC endpoint of a server.
+func NewDRPCEndpoint(server *Server) *DRPCEndpoint {
+	return &DRPCEndpoint{server: server}
+}
+
+// GetMetadata returns the metadata of an object.
+func (e *DRPCEndpoint) GetMetadata(ctx context.Context, req *GetMetadataRequest) (*GetMetadataResponse, error) {
+	query := url.Values{}
+	if req.VersionID != "" {
+		query.Set("versionId", req.VersionID)
+	}
+
+	var resp GetMetadataResponse
+	header, err := e.call(ctx, req.Authorization, http.MethodGet, "/v1/metadata/"+req.Bucket+"/"+req.Key, query, nil, nil, &resp.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	resp.ETag = header.Get("ETag")
+	return &resp, nil
+}
+
+// UpdateMetadata sets the metadata of an object.
+func (e *DRPCEndpoint) UpdateMetadata(ctx context.Context, req *UpdateMetadataRequest) (*UpdateMetadataResponse, error) {
+	body, err := json.Marshal(req.Metadata)
+	if err != nil {
+		return nil, drpcerr.WithCode(err, http.StatusBadRequest)
+	}
+
+	header, err := e.call(ctx, req.Authorization, http.MethodPut, "/v1/metadata/"+req.Bucket+"/"+req.Key, nil, ifMatchHeader(req.IfMatch), body, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &UpdateMetadataResponse{ETag: header.Get("ETag")}, nil
+}
+
+// DeleteMetadata deletes the metadata of an object.
+func (e *DRPCEndpoint) DeleteMetadata(ctx context.Context, req *DeleteMetadataRequest) (*DeleteMetadataResponse, error) {
+	_, err := e.call(ctx, req.Authorization, http.MethodDelete, "/v1/metadata/"+req.Bucket+"/"+req.Key, nil, ifMatchHeader(req.IfMatch), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteMetadataResponse{}, nil
+}
+
+// SearchMetadata returns a page of search results.
+func (e *DRPCEndpoint) SearchMetadata(ctx context.Context, req *SearchMetadataRequest) (*SearchResponse, error) {
+	body := []byte(req.Search)
+	if len(body) == 0 {
+		body = []byte("{}")
+	}
+
+	var resp SearchResponse
+	_, err := e.call(ctx, req.Authorization, http.MethodPost, "/v1/metasearch/"+req.Bucket, nil, nil, body, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func ifMatchHeader(etag string) http.Header {
+	if etag == "" {
+		return nil
+	}
+	return http.Header{"If-Match": []string{etag}}
+}
+
+// call serves an HTTP request with the handler of the server, and decodes
+// the JSON response into out. Error responses are returned as errors with
+// the HTTP status as DRPC error code.
+func (e *DRPCEndpoint) call(ctx context.Context, authorization string, method string, path string, query url.Values, header http.Header, body []byte, out interface{}) (http.Header, error) {
+	r, err := http.NewRequestWithContext(ctx, method, "/", bytes.NewReader(body))
+	if err != nil {
+		return nil, drpcerr.WithCode(err, http.StatusBadRequest)
+	}
+	r.URL.Path = path
+	r.URL.RawQuery = query.Encode()
+	r.RemoteAddr = "drpc"
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	r.Header.Set("Authorization", authorization)
+	r.Header.Set("Content-Type", "application/json")
+
+	w := &drpcResponseWriter{header: http.Header{}}
+	e.server.Handler.ServeHTTP(w, r)
+
+	if w.status >= http.StatusBadRequest {
+		var resp ErrorResponse
+		if err := json.Unmarshal(w.body.Bytes(), &resp); err != nil || resp.Message == "" {
+			resp.Message = http.StatusText(w.status)
+		}
+		return nil, drpcerr.WithCode(errors.New(resp.Message), uint64(w.status))
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.body.Bytes(), out); err != nil {
+			return nil, drpcerr.WithCode(err, http.StatusInternalServerError)
+		}
+	}
+	return w.header, nil
+}
+
+// drpcResponseWriter records the response of an HTTP handler.
+type drpcResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *drpcResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *drpcResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *drpcResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(p)
+}
+
+// drpcDescription describes the methods of DRPCEndpoint to DRPC.
+type drpcDescription struct{}
+
+func (drpcDescription) NumMethods() int { return 4 }
+
+func (drpcDescription) Method(n int) (string, drpc.Encoding, drpc.Receiver, interface{}, bool) {
+	switch n {
+	case 0:
+		return rpcGetMetadata, drpcJSON{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(*DRPCEndpoint).GetMetadata(ctx, in1.(*GetMetadataRequest))
+			}, (*DRPCEndpoint).GetMetadata, true
+	case 1:
+		return rpcUpdateMetadata, drpcJSON{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(*DRPCEndpoint).UpdateMetadata(ctx, in1.(*UpdateMetadataRequest))
+			}, (*DRPCEndpoint).UpdateMetadata, true
+	case 2:
+		return rpcDeleteMetadata, drpcJSON{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(*DRPCEndpoint).DeleteMetadata(ctx, in1.(*DeleteMetadataRequest))
+			}, (*DRPCEndpoint).DeleteMetadata, true
+	case 3:
+		return rpcSearchMetadata, drpcJSON{},
+			func(srv interface{}, ctx context.Context, in1, in2 interface{}) (drpc.Message, error) {
+				return srv.(*DRPCEndpoint).SearchMetadata(ctx, in1.(*SearchMetadataRequest))
+			}, (*DRPCEndpoint).SearchMetadata, true
+	default:
+		return "", nil, nil, nil, false
+	}
+}
+
+// newDRPCServer creates a DRPC server with the metasearch service.
+func (s *Server) newDRPCServer() (*drpcserver.Server, error) {
+	mux := drpcmux.New()
+	if err := mux.Register(NewDRPCEndpoint(s), drpcDescription{}); err != nil {
+		return nil, err
+	}
+	return drpcserver.New(mux), nil
+}
+
+// serveDRPC serves the DRPC service on the DRPC endpoint of the server, over
+// TLS if tlsConfig is not nil.
+func (s *Server) serveDRPC(ctx context.Context, tlsConfig *tls.Config) error {
+	server, err := s.newDRPCServer()
+	if err != nil {
+		return err
+	}
+
+	lis, err := net.Listen("tcp", s.Config.DRPCEndpoint)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		lis = tls.NewListener(lis, tlsConfig)
+	}
+
+	s.Logger.Info("serving DRPC", zap.String("endpoint", s.Config.DRPCEndpoint))
+	return server.Serve(ctx, lis)
+}
+
+// DRPCClient calls the metasearch DRPC service.
+type DRPCClient struct {
+	conn drpc.Conn
+}
+
+// NewDRPCClient creates a client of the metasearch DRPC service.
+func NewDRPCClient(conn drpc.Conn) *DRPCClient {
+	return &DRPCClient{conn: conn}
+}
+
+// GetMetadata returns the metadata of an object.
+func (c *DRPCClient) GetMetadata(ctx context.Context, req *GetMetadataRequest) (*GetMetadataResponse, error) {
+	var resp GetMetadataResponse
+	err := c.conn.Invoke(ctx, rpcGetMetadata, drpcJSON{}, req, &resp)
+	return &resp, err
+}
+
+// UpdateMetadata sets the metadata of an object.
+func (c *DRPCClient) UpdateMetadata(ctx context.Context, req *UpdateMetadataRequest) (*UpdateMetadataResponse, error) {
+	var resp UpdateMetadataResponse
+	err := c.conn.Invoke(ctx, rpcUpdateMetadata, drpcJSON{}, req, &resp)
+	return &resp, err
+}
+
+// DeleteMetadata deletes the metadata of an object.
+func (c *DRPCClient) DeleteMetadata(ctx context.Context, req *DeleteMetadataRequest) (*DeleteMetadataResponse, error) {
+	var resp DeleteMetadataResponse
+	err := c.conn.Invoke(ctx, rpcDeleteMetadata, drpcJSON{}, req, &resp)
+	return &resp, err
+}
+
+// SearchMetadata returns a page of search results.
+func (c *DRPCClient) SearchMetadata(ctx context.Context, req *SearchMetadataRequest) (*SearchResponse, error) {
+	var resp SearchResponse
+	err := c.conn.Invoke(ctx, rpcSearchMetadata, drpcJSON{}, req, &resp)
+	return &resp, err
+}
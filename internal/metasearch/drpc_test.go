@@ -0,0 +1,97 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+
+	"storj.io/drpc/drpcconn"
+	"storj.io/drpc/drpcerr"
+)
+
+func TestDRPC(t *testing.T) {
+	server := testServer()
+	drpcServer, err := server.newDRPCServer()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	serverConn, clientConn := net.Pipe()
+	go func() { _ = drpcServer.ServeOne(ctx, serverConn) }()
+	conn := drpcconn.New(clientConn)
+	defer func() { _ = conn.Close() }()
+	client := NewDRPCClient(conn)
+
+	// Update
+	updated, err := client.UpdateMetadata(ctx, &UpdateMetadataRequest{
+		Authorization: "Bearer testtoken",
+		Bucket:        "testbucket",
+		Key:           "foo.txt",
+		Metadata:      map[string]interface{}{"type": "photo"},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, updated.ETag)
+
+	// Get
+	got, err := client.GetMetadata(ctx, &GetMetadataRequest{
+		Authorization: "Bearer testtoken",
+		Bucket:        "testbucket",
+		Key:           "foo.txt",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"type": "photo"}, got.Metadata)
+	assert.Equal(t, got.ETag, updated.ETag)
+
+	// Search
+	found, err := client.SearchMetadata(ctx, &SearchMetadataRequest{
+		Authorization: "Bearer testtoken",
+		Bucket:        "testbucket",
+		Search:        []byte(`{"filter": "type == 'photo'"}`),
+	})
+	require.NoError(t, err)
+	require.Len(t, found.Results, 1)
+	assert.Equal(t, found.Results[0].Path, "sj://testbucket/foo.txt")
+
+	// Conditional delete
+	_, err = client.DeleteMetadata(ctx, &DeleteMetadataRequest{
+		Authorization: "Bearer testtoken",
+		Bucket:        "testbucket",
+		Key:           "foo.txt",
+		IfMatch:       `"stale"`,
+	})
+	require.Error(t, err)
+	assert.Equal(t, drpcerr.Code(err), uint64(http.StatusPreconditionFailed))
+
+	_, err = client.DeleteMetadata(ctx, &DeleteMetadataRequest{
+		Authorization: "Bearer testtoken",
+		Bucket:        "testbucket",
+		Key:           "foo.txt",
+		IfMatch:       updated.ETag,
+	})
+	require.NoError(t, err)
+
+	// Errors have the HTTP status as code
+	_, err = client.GetMetadata(ctx, &GetMetadataRequest{
+		Authorization: "Bearer testtoken",
+		Bucket:        "testbucket",
+		Key:           "foo.txt",
+	})
+	require.Error(t, err)
+	assert.Equal(t, drpcerr.Code(err), uint64(http.StatusNotFound))
+
+	_, err = client.SearchMetadata(ctx, &SearchMetadataRequest{
+		Authorization: "Bearer testtoken",
+		Bucket:        "testbucket",
+		Search:        []byte(`{"filter": "["}`),
+	})
+	require.Error(t, err)
+	assert.Equal(t, drpcerr.Code(err), uint64(http.StatusBadRequest))
+}
@@ -0,0 +1,168 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"go.uber.org/zap"
+)
+
+const secondaryWriteTimeout = 30 * time.Second
+
+// DualWriteRepository writes metadata changes to both a primary and a
+// secondary repository, to migrate to the secondary backend without
+// downtime. Reads are served from the primary and compared with the
+// secondary like in a ShadowSearchRepository. The primary is the source of
+// truth: changes are applied to the secondary after they succeeded on the
+// primary, with the resulting metadata, and failures on the secondary are
+// logged and reported as metrics without failing the request. Concurrent
+// changes of the same object may be applied to the secondary out of order,
+// which is reported by read mismatches.
+type DualWriteRepository struct {
+	*ShadowSearchRepository
+
+	secondary MetaSearchRepo
+	log       *zap.Logger
+}
+
+// NewDualWriteRepository creates a new DualWriteRepository.
+func NewDualWriteRepository(primary MetaSearchRepo, secondary MetaSearchRepo, log *zap.Logger) *DualWriteRepository {
+	return &DualWriteRepository{
+		ShadowSearchRepository: NewShadowSearchRepository(primary, secondary, log),
+		secondary:              secondary,
+		log:                    log,
+	}
+}
+
+func (r *DualWriteRepository) UpdateMetadata(ctx context.Context, loc ObjectLocation, meta ObjectMetadata) error {
+	if err := r.MetaSearchRepo.UpdateMetadata(ctx, loc, meta); err != nil {
+		return err
+	}
+	r.write(ctx, "UpdateMetadata", func(ctx context.Context) error {
+		return r.secondary.UpdateMetadata(ctx, loc, meta)
+	})
+	return nil
+}
+
+// UpdateMetadataIfMatch only checks the precondition on the primary, the
+// secondary is updated unconditionally.
+func (r *DualWriteRepository) UpdateMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}, meta ObjectMetadata) error {
+	if err := r.MetaSearchRepo.UpdateMetadataIfMatch(ctx, loc, expected, meta); err != nil {
+		return err
+	}
+	r.write(ctx, "UpdateMetadataIfMatch", func(ctx context.Context) error {
+		return r.secondary.UpdateMetadata(ctx, loc, meta)
+	})
+	return nil
+}
+
+func (r *DualWriteRepository) DeleteMetadata(ctx context.Context, loc ObjectLocation) error {
+	if err := r.MetaSearchRepo.DeleteMetadata(ctx, loc); err != nil {
+		return err
+	}
+	r.write(ctx, "DeleteMetadata", func(ctx context.Context) error {
+		return r.secondary.DeleteMetadata(ctx, loc)
+	})
+	return nil
+}
+
+func (r *DualWriteRepository) SoftDeleteMetadata(ctx context.Context, loc ObjectLocation) error {
+	if err := r.MetaSearchRepo.SoftDeleteMetadata(ctx, loc); err != nil {
+		return err
+	}
+	r.write(ctx, "SoftDeleteMetadata", func(ctx context.Context) error {
+		return r.secondary.SoftDeleteMetadata(ctx, loc)
+	})
+	return nil
+}
+
+// SoftDeleteMetadataIfMatch only checks the precondition on the primary, the
+// secondary is soft deleted unconditionally.
+func (r *DualWriteRepository) SoftDeleteMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}) error {
+	if err := r.MetaSearchRepo.SoftDeleteMetadataIfMatch(ctx, loc, expected); err != nil {
+		return err
+	}
+	r.write(ctx, "SoftDeleteMetadataIfMatch", func(ctx context.Context) error {
+		return r.secondary.SoftDeleteMetadata(ctx, loc)
+	})
+	return nil
+}
+
+// RestoreMetadata copies the restored metadata of the primary to the
+// secondary, whose tombstone may be missing or different.
+func (r *DualWriteRepository) RestoreMetadata(ctx context.Context, loc ObjectLocation, deletedAfter time.Time) error {
+	if err := r.MetaSearchRepo.RestoreMetadata(ctx, loc, deletedAfter); err != nil {
+		return err
+	}
+	r.write(ctx, "RestoreMetadata", func(ctx context.Context) error {
+		obj, err := r.MetaSearchRepo.GetMetadata(ctx, loc)
+		if err != nil {
+			return err
+		}
+		return r.secondary.UpdateMetadata(ctx, loc, obj.Metadata)
+	})
+	return nil
+}
+
+func (r *DualWriteRepository) PurgeTombstones(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	purged, err := r.MetaSearchRepo.PurgeTombstones(ctx, deletedBefore)
+	if err != nil {
+		return purged, err
+	}
+	r.write(ctx, "PurgeTombstones", func(ctx context.Context) error {
+		_, err := r.secondary.PurgeTombstones(ctx, deletedBefore)
+		return err
+	})
+	return purged, nil
+}
+
+// DeleteExpiredMetadata deletes the metadata that expired on the primary
+// from the secondary too.
+func (r *DualWriteRepository) DeleteExpiredMetadata(ctx context.Context, limit int) ([]ObjectLocation, error) {
+	deleted, err := r.MetaSearchRepo.DeleteExpiredMetadata(ctx, limit)
+	if len(deleted) > 0 {
+		r.write(ctx, "DeleteExpiredMetadata", func(ctx context.Context) error {
+			ctx = WithActor(ctx, expirationActor)
+			var errs []error
+			for _, loc := range deleted {
+				errs = append(errs, r.secondary.DeleteMetadata(ctx, loc))
+			}
+			return errors.Join(errs...)
+		})
+	}
+	return deleted, err
+}
+
+// MigrateMetadata writes the migrated metadata to the secondary, whose
+// migration queue is ignored.
+func (r *DualWriteRepository) MigrateMetadata(ctx context.Context, obj ObjectInfo) error {
+	if err := r.MetaSearchRepo.MigrateMetadata(ctx, obj); err != nil {
+		return err
+	}
+	r.write(ctx, "MigrateMetadata", func(ctx context.Context) error {
+		return r.secondary.UpdateMetadata(ctx, obj.ObjectLocation, obj.Metadata)
+	})
+	return nil
+}
+
+// write applies a change to the secondary. It waits for the change, so that
+// the changes of a request are applied in order, but it is not canceled with
+// the request.
+func (r *DualWriteRepository) write(ctx context.Context, method string, apply func(ctx context.Context) error) {
+	tag := monkit.NewSeriesTag("method", method)
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), secondaryWriteTimeout)
+	defer cancel()
+
+	if err := apply(ctx); err != nil {
+		mon.Counter("dual_write_failed", tag).Inc(1)
+		r.log.Warn("secondary write failed", zap.String("Method", method), zap.Error(err))
+		return
+	}
+	mon.Counter("dual_write_succeeded", tag).Inc(1)
+}
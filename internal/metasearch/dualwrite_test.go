@@ -0,0 +1,55 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDualWriteRepository(t *testing.T) {
+	ctx := context.Background()
+	primary := newMockRepo()
+	secondary := newMockRepo()
+	repo := NewDualWriteRepository(primary, secondary, zap.NewNop())
+
+	loc := ObjectLocation{BucketName: "testbucket", ObjectKey: "foo.txt"}
+	meta := ObjectMetadata{ClearMetadata: map[string]interface{}{"foo": "bar"}}
+
+	// Writes go to both repositories
+	require.NoError(t, repo.UpdateMetadata(ctx, loc, meta))
+	require.Len(t, primary.objects, 1)
+	require.Len(t, secondary.objects, 1)
+
+	// Preconditions are only checked on the primary
+	secondary.objects["sj://testbucket/foo.txt"] = ObjectInfo{Metadata: ObjectMetadata{ClearMetadata: map[string]interface{}{"foo": "diverged"}}}
+	updated := ObjectMetadata{ClearMetadata: map[string]interface{}{"foo": "baz"}}
+	require.NoError(t, repo.UpdateMetadataIfMatch(ctx, loc, meta.ClearMetadata, updated))
+	require.Equal(t, "baz", secondary.objects["sj://testbucket/foo.txt"].Metadata.ClearMetadata["foo"])
+
+	// Failed primary writes are not applied to the secondary
+	err := repo.UpdateMetadataIfMatch(ctx, loc, meta.ClearMetadata, ObjectMetadata{})
+	require.ErrorIs(t, err, ErrPreconditionFailed)
+	require.Equal(t, "baz", secondary.objects["sj://testbucket/foo.txt"].Metadata.ClearMetadata["foo"])
+
+	// Restored metadata is copied from the primary
+	require.NoError(t, repo.SoftDeleteMetadata(ctx, loc))
+	delete(secondary.tombstones, "sj://testbucket/foo.txt")
+	require.NoError(t, repo.RestoreMetadata(ctx, loc, time.Now().Add(-time.Minute)))
+	require.Equal(t, "baz", secondary.objects["sj://testbucket/foo.txt"].Metadata.ClearMetadata["foo"])
+
+	require.NoError(t, repo.DeleteMetadata(ctx, loc))
+	require.Len(t, primary.objects, 0)
+	require.Len(t, secondary.objects, 0)
+
+	// Failed secondary writes do not fail the request
+	require.NoError(t, primary.UpdateMetadata(ctx, loc, meta))
+	require.NoError(t, repo.SoftDeleteMetadata(ctx, loc))
+	require.Len(t, primary.tombstones, 1)
+	require.Len(t, secondary.tombstones, 0)
+}
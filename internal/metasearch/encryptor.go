@@ -10,6 +10,7 @@ import (
 	"slices"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"storj.io/common/encryption"
 	"storj.io/common/memory"
@@ -58,6 +59,7 @@ var defaultEncryptionParameters = storj.EncryptionParameters{
 
 // UplinkEncryptor encrypts/decrypts paths using the uplink library.
 type UplinkEncryptor struct {
+	access       *uplink.Access
 	store        *encryption.Store
 	storeEntries map[UplinkEncryptorStoreEntry]bool
 }
@@ -100,11 +102,25 @@ func NewUplinkEncryptor(access *uplink.Access) *UplinkEncryptor {
 	})
 
 	return &UplinkEncryptor{
+		access:       access,
 		store:        encAccess.Store,
 		storeEntries: storeEntries,
 	}
 }
 
+// ShareDownload returns an access grant restricted to downloading an object
+// until ttl elapses.
+func (e *UplinkEncryptor) ShareDownload(bucket string, key string, ttl time.Duration) (string, error) {
+	shared, err := e.access.Share(uplink.Permission{
+		AllowDownload: true,
+		NotAfter:      time.Now().Add(ttl),
+	}, uplink.SharePrefix{Bucket: bucket, Prefix: key})
+	if err != nil {
+		return "", err
+	}
+	return shared.Serialize()
+}
+
 func (e *UplinkEncryptor) EncryptPath(bucket string, path string) (string, error) {
 	p := paths.NewUnencrypted(path)
 	encPath, err := encryption.EncryptPath(bucket, p, e.store.GetDefaultPathCipher(), e.store)
@@ -186,6 +202,40 @@ func (e *UplinkEncryptor) EncryptMetadata(bucket string, path string, meta *Obje
 	return nil
 }
 
+// NewEncryptedUpdateRequest encrypts the metadata of an object locally with
+// the encryption keys of an access grant, as uplink would. Only the values of
+// clearKeys are sent in clear, so that the server can index them, and the
+// other values are neither sent nor stored in clear. The request must still be
+// authenticated with an access grant that can encrypt the object key, which
+// also lets the server decrypt the metadata of the object, so it should be
+// restricted to the object, as the metaclient does.
+func NewEncryptedUpdateRequest(access *uplink.Access, bucket string, path string, metadata map[string]interface{}, clearKeys []string) (EncryptedUpdateRequest, error) {
+	meta := ObjectMetadata{
+		ClearMetadata: metadata,
+	}
+	err := NewUplinkEncryptor(access).EncryptMetadata(bucket, path, &meta)
+	if err != nil {
+		return EncryptedUpdateRequest{}, fmt.Errorf("cannot encrypt metadata: %w", err)
+	}
+
+	request := EncryptedUpdateRequest{
+		EncryptedMetadataNonce: meta.EncryptedMetadataNonce,
+		EncryptedMetadata:      meta.EncryptedMetadata,
+		EncryptedMetadataKey:   meta.EncryptedMetadataKey,
+	}
+	for _, k := range clearKeys {
+		v, ok := metadata[k]
+		if !ok {
+			continue
+		}
+		if request.ClearMetadata == nil {
+			request.ClearMetadata = make(map[string]interface{})
+		}
+		request.ClearMetadata[k] = v
+	}
+	return request, nil
+}
+
 func (e *UplinkEncryptor) DecryptMetadata(bucket string, path string, meta *ObjectMetadata) error {
 	if len(meta.EncryptedMetadataKey) == 0 || len(meta.EncryptedMetadataNonce) == 0 {
 		return nil
@@ -328,8 +378,9 @@ func (r *EncryptorRepository) AddEncryptor(newEncryptor Encryptor) bool {
 
 // DecryptObjectDetails tries to decode object key and metadata by all
 // available encryptors. Returns the object key (decrypted on success,
-// encrypted on error) and an error if none of the encryptors succeeded.
-func (r *EncryptorRepository) DecryptMetadata(obj *ObjectInfo) (clearObjectKey string, meta ObjectMetadata, err error) {
+// encrypted on error), the encryptor that decrypted them and an error if none
+// of the encryptors succeeded.
+func (r *EncryptorRepository) DecryptMetadata(obj *ObjectInfo) (clearObjectKey string, meta ObjectMetadata, encryptor Encryptor, err error) {
 	meta = obj.Metadata
 
 	for i := range len(r.encryptors) {
@@ -350,10 +401,10 @@ func (r *EncryptorRepository) DecryptMetadata(obj *ObjectInfo) (clearObjectKey s
 
 		// If both path and metadata can be encrypted, return with success
 		atomic.AddInt64(&e.success, 1)
-		return
+		return clearObjectKey, meta, e.encryptor, nil
 	}
 
-	return obj.ObjectKey, meta, errors.New("cannot find decryption key for object")
+	return obj.ObjectKey, meta, nil, errors.New("cannot find decryption key for object")
 }
 
 // CheckEncryptors removes unused encryptors if needed.
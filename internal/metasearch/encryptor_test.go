@@ -66,6 +66,25 @@ func TestMetadataEncryption(t *testing.T) {
 	require.Equal(t, true, clearMeta2["foo"])
 }
 
+func TestNewEncryptedUpdateRequest(t *testing.T) {
+	access, err := uplink.ParseAccess(accessEncrypted)
+	require.NoError(t, err)
+
+	clearMeta := map[string]interface{}{"foo": "bar", "secret": "baz"}
+	request, err := NewEncryptedUpdateRequest(access, testBucket, testPath, clearMeta, []string{"foo", "missing"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"foo": "bar"}, request.ClearMetadata)
+
+	meta := ObjectMetadata{
+		EncryptedMetadataNonce: request.EncryptedMetadataNonce,
+		EncryptedMetadata:      request.EncryptedMetadata,
+		EncryptedMetadataKey:   request.EncryptedMetadataKey,
+	}
+	err = NewUplinkEncryptor(access).DecryptMetadata(testBucket, testPath, &meta)
+	require.NoError(t, err)
+	require.Equal(t, clearMeta, meta.ClearMetadata)
+}
+
 func TestUplinkAccessComparison(t *testing.T) {
 	accUnencrypted, err := uplink.ParseAccess(accessUnencrypted)
 	require.NoError(t, err)
@@ -141,7 +160,9 @@ func TestEncryptorRepository(t *testing.T) {
 
 	// Decrypt metadata with repository
 	obj.Metadata.ClearMetadata = nil
-	clearPath, meta, err := r.DecryptMetadata(obj)
+	clearPath, meta, encryptor, err := r.DecryptMetadata(obj)
+	require.NoError(t, err)
+	require.Same(t, e2superset, encryptor)
 
 	require.Equal(t, clearPath, "2/foo.txt")
 	metaJSON, _ := json.Marshal(meta.ClearMetadata)
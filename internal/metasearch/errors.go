@@ -23,6 +23,30 @@ var (
 	// ErrAuthorizationFailed is returned when the request is not authorized.
 	ErrAuthorizationFailed = &ErrorResponse{StatusCode: 401, Message: "authorization failed"}
 
+	// ErrForbidden is returned when the request is authenticated, but the operation is not allowed.
+	ErrForbidden = &ErrorResponse{StatusCode: 403, Message: "forbidden"}
+
+	// ErrConflict is returned when the request conflicts with another request in progress.
+	ErrConflict = &ErrorResponse{StatusCode: 409, Message: "conflict"}
+
+	// ErrPageTokenExpired is returned when the snapshot of a page token is older than the retention period.
+	ErrPageTokenExpired = &ErrorResponse{StatusCode: 410, Message: "page token expired"}
+
+	// ErrPreconditionFailed is returned when the metadata has been modified since the client read it.
+	ErrPreconditionFailed = &ErrorResponse{StatusCode: 412, Message: "precondition failed"}
+
+	// ErrRequestTooLarge is returned when the request body is larger than the maximum body size.
+	ErrRequestTooLarge = &ErrorResponse{StatusCode: 413, Message: "request entity too large"}
+
+	// ErrUnprocessableEntity is returned when the request is well-formed, but cannot be processed.
+	ErrUnprocessableEntity = &ErrorResponse{StatusCode: 422, Message: "unprocessable entity"}
+
+	// ErrPreconditionRequired is returned when a conditional request is required, but the request is not conditional.
+	ErrPreconditionRequired = &ErrorResponse{StatusCode: 428, Message: "precondition required"}
+
+	// ErrTooManyRequests is returned when the client exceeded its rate limit.
+	ErrTooManyRequests = &ErrorResponse{StatusCode: 429, Message: "too many requests"}
+
 	// ErrInternalError is returned when an internal error occurs.
 	ErrInternalError = &ErrorResponse{StatusCode: 500, Message: "internal error"}
 
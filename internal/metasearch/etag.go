@@ -0,0 +1,92 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// metadataETag returns the entity tag of the clear metadata of an object.
+// Missing metadata has the same entity tag as empty metadata.
+func metadataETag(metadata map[string]interface{}) string {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+
+	// Map keys are sorted by json.Marshal, so equal metadata always has the
+	// same entity tag.
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header value
+// matches the entity tag.
+func etagMatches(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether the If-None-Match header of a read request
+// matches the entity tag, so that the client can reuse its cached copy.
+func notModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	return header != "" && etagMatches(header, etag)
+}
+
+// checkIfMatch evaluates the If-Match header of a write request against the
+// current metadata of the object. If the header is set, it returns the
+// current clear metadata, which must be passed to UpdateMetadataIfMatch, so
+// that concurrent modifications are detected.
+func (s *Server) checkIfMatch(ctx context.Context, r *http.Request, loc ObjectLocation, encryptor Encryptor) (expected map[string]interface{}, conditional bool, err error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if s.Config.RequireIfMatch {
+			return nil, false, fmt.Errorf("%w: If-Match header is required", ErrPreconditionRequired)
+		}
+		return nil, false, nil
+	}
+
+	obj, err := s.Repo.GetMetadata(ctx, loc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.migrateObject(ctx, &obj, encryptor)
+
+	if !etagMatches(header, metadataETag(obj.Metadata.ClearMetadata)) {
+		return nil, false, fmt.Errorf("%w: metadata has been modified", ErrPreconditionFailed)
+	}
+
+	return obj.Metadata.ClearMetadata, true, nil
+}
+
+// updateMetadata updates the metadata of an object, conditionally if the
+// request has an If-Match header.
+func (s *Server) updateMetadata(ctx context.Context, r *http.Request, loc ObjectLocation, encryptor Encryptor, meta ObjectMetadata) error {
+	expected, conditional, err := s.checkIfMatch(ctx, r, loc, encryptor)
+	if err != nil {
+		return err
+	}
+
+	if conditional {
+		return s.Repo.UpdateMetadataIfMatch(ctx, loc, expected, meta)
+	}
+	return s.Repo.UpdateMetadata(ctx, loc, meta)
+}
@@ -0,0 +1,141 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	expirationInterval  = 1 * time.Minute
+	expirationBatchSize = 1000
+
+	// expirationActor is the actor of metadata deletions in the history.
+	expirationActor = "expiration"
+)
+
+func (r *MetabaseSearchRepository) DeleteExpiredMetadata(ctx context.Context, limit int) ([]ObjectLocation, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT project_id, bucket_name, object_key, version
+		FROM `+r.tables.objects()+`
+		WHERE metasearch_metadata_expires_at <= now()
+		LIMIT $1
+		`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	var expired []ObjectLocation
+	for rows.Next() {
+		var loc ObjectLocation
+		if err := rows.Scan(&loc.ProjectID, &loc.BucketName, &loc.ObjectKey, &loc.Version); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		expired = append(expired, loc)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	ctx = WithActor(ctx, expirationActor)
+	deleted := make([]ObjectLocation, 0, len(expired))
+	for _, loc := range expired {
+		err := r.updateMetadata(ctx, loc, ObjectMetadata{}, false, "version = $10 AND metasearch_metadata_expires_at <= now()", loc.Version)
+		if err == nil {
+			deleted = append(deleted, loc)
+			continue
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return deleted, err
+		}
+
+		// The version is not the latest anymore: its metadata is deleted
+		// without recording the change in the history. Like updateMetadata,
+		// the version is not queued for migration by the update.
+		_, err = r.db.ExecContext(ctx, `
+			UPDATE `+r.tables.objects()+`
+			SET
+				encrypted_metadata_nonce = NULL, encrypted_metadata = NULL, encrypted_metadata_encrypted_key = NULL,
+				clear_metadata = NULL,
+				metasearch_metadata_expires_at = NULL,
+				metasearch_queued_at = NULL,
+				metasearch_updated_at = now()
+			WHERE
+				(project_id, bucket_name, object_key, version) = ($1, $2, $3, $4) AND
+				metasearch_metadata_expires_at <= now()
+			`,
+			loc.ProjectID, []byte(loc.BucketName), []byte(loc.ObjectKey), loc.Version,
+		)
+		if err != nil {
+			return deleted, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+	}
+
+	return deleted, nil
+}
+
+// parseMetadataExpiration parses the expiration time of the metadata from the
+// ttl or expiresAt query parameters of an update request.
+func parseMetadataExpiration(r *http.Request) (*time.Time, error) {
+	q := r.URL.Query()
+	ttl, expiresAt := q.Get("ttl"), q.Get("expiresAt")
+
+	switch {
+	case ttl != "" && expiresAt != "":
+		return nil, fmt.Errorf("%w: ttl and expiresAt cannot be set together", ErrBadRequest)
+	case ttl != "":
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: invalid ttl", ErrBadRequest)
+		}
+		t := time.Now().Add(d)
+		return &t, nil
+	case expiresAt != "":
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid expiresAt", ErrBadRequest)
+		}
+		if !t.After(time.Now()) {
+			return nil, fmt.Errorf("%w: expiresAt must be in the future", ErrBadRequest)
+		}
+		return &t, nil
+	default:
+		return nil, nil
+	}
+}
+
+// expireMetadata periodically deletes expired metadata.
+func (s *Server) expireMetadata() {
+	ticker := time.NewTicker(expirationInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if s.ReadOnly() {
+			continue
+		}
+		ctx := context.Background()
+		for {
+			deleted, err := s.Repo.DeleteExpiredMetadata(ctx, expirationBatchSize)
+			if err != nil {
+				s.Logger.Error("cannot delete expired metadata", zap.Error(err))
+				break
+			}
+			if len(deleted) > 0 {
+				s.Logger.Debug("deleted expired metadata", zap.Int("Deleted", len(deleted)))
+			}
+			if len(deleted) < expirationBatchSize {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const csvContentType = "text/csv"
+
+// exportErrorTrailer is the HTTP trailer reporting errors that occur after an
+// export has started.
+const exportErrorTrailer = "X-Metasearch-Error"
+
+// maxExportColumns is the maximum number of metadata columns of an export.
+const maxExportColumns = 100
+
+// exportWriter writes the results of an export in a file format.
+type exportWriter interface {
+	// write writes a page of results.
+	write(results []SearchResult) error
+	// close completes the file after the last page.
+	close() error
+}
+
+// exportFormat is a file format search results can be exported as.
+type exportFormat struct {
+	// mediaType is the media type the client accepts.
+	mediaType   string
+	contentType string
+	extension   string
+
+	newWriter func(w io.Writer, columns []string) (exportWriter, error)
+}
+
+var exportFormats = []exportFormat{
+	{mediaType: csvContentType, contentType: csvContentType + "; charset=utf-8", extension: "csv", newWriter: newCSVWriter},
+	{mediaType: parquetContentType, contentType: parquetContentType, extension: "parquet", newWriter: newParquetWriter},
+}
+
+// ndjsonExportFormat writes complete search results as newline delimited
+// JSON, ignoring the columns. Searches stream NDJSON without it, but the
+// results of search jobs are downloaded through an export writer.
+var ndjsonExportFormat = exportFormat{
+	mediaType: ndjsonContentType, contentType: ndjsonContentType, extension: "ndjson", newWriter: newNDJSONWriter,
+}
+
+// wantsExport returns the export format the client accepts, or nil if the
+// client accepts JSON.
+func wantsExport(r *http.Request) *exportFormat {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Accept"))
+	if err != nil {
+		return nil
+	}
+	for i := range exportFormats {
+		if exportFormats[i].mediaType == mediaType {
+			return &exportFormats[i]
+		}
+	}
+	return nil
+}
+
+// validateExport validates a search request whose results are exported.
+func validateExport(request *SearchRequest) error {
+	if len(request.Columns) == 0 || len(request.Columns) > maxExportColumns {
+		return fmt.Errorf("%w: exports require between 1 and %d columns", ErrBadRequest, maxExportColumns)
+	}
+	for _, column := range request.Columns {
+		if column == "" || strings.HasPrefix(column, ".") || strings.HasSuffix(column, ".") || strings.Contains(column, "..") {
+			return fmt.Errorf("%w: invalid column '%s'", ErrBadRequest, column)
+		}
+	}
+	if request.CountOnly || request.Delimiter != "" || request.SimilarTo != nil {
+		return fmt.Errorf("%w: exports cannot be used with countOnly, delimiter or similarTo", ErrBadRequest)
+	}
+	return nil
+}
+
+// exportSearch writes all matching objects in an export format, with the
+// path of the objects in the first column and the metadata values at the dot
+// separated paths of the requested columns in the others. Pages are fetched
+// internally and flushed to the client one by one. Errors after the response
+// has started are reported in the X-Metasearch-Error trailer.
+func (s *Server) exportSearch(w http.ResponseWriter, r *http.Request, request *SearchRequest, format *exportFormat) {
+	ctx := r.Context()
+	flusher, _ := w.(http.Flusher)
+	s.extendWriteDeadline(w, r)
+
+	w.Header().Set("Content-Type", format.contentType)
+	setExportFilename(w, request.Location.BucketName, format)
+	w.Header().Set("Trailer", exportErrorTrailer)
+	w.WriteHeader(http.StatusOK)
+
+	fail := func(err error) {
+		s.Logger.Warn("error during export", zap.String("format", format.extension), zap.Error(err))
+
+		// The status is already sent, so report the error in the trailer.
+		var e *ErrorResponse
+		if !errors.As(err, &e) {
+			e = ErrInternalError
+		}
+		w.Header().Set(exportErrorTrailer, e.Message)
+	}
+
+	ew, err := format.newWriter(w, request.Columns)
+	if err != nil {
+		fail(err)
+		return
+	}
+	for {
+		result, err := s.searchMetadata(ctx, request)
+		if err != nil {
+			if ctx.Err() == nil {
+				fail(err)
+			}
+			return
+		}
+
+		if err := ew.write(result.Results); err != nil {
+			// The client has disconnected.
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if result.Truncated {
+			w.Header().Set(exportErrorTrailer, "search timed out, resume with pageToken "+result.PageToken)
+			return
+		}
+		if result.PageToken == "" || ctx.Err() != nil {
+			break
+		}
+
+		request.startAfter, request.asOf, request.sort, err = parseSortedPageToken(result.PageToken)
+		if err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	if err := ew.close(); err != nil {
+		fail(err)
+	}
+}
+
+// setExportFilename sets the file name of an export the client saves.
+func setExportFilename(w http.ResponseWriter, name string, format *exportFormat) {
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": name + "." + format.extension,
+	}))
+}
+
+// columnValues returns the metadata values of a search result at the paths
+// of the export columns, and whether they were found.
+func columnValues(res SearchResult, paths [][]string) ([]interface{}, []bool) {
+	values := make([]interface{}, len(paths))
+	found := make([]bool, len(paths))
+
+	metadata, _ := res.Metadata.(map[string]interface{})
+	for i, path := range paths {
+		_, values[i], found[i] = lookupPath(metadata, path, false)
+	}
+	return values, found
+}
+
+// columnPaths splits the dot separated paths of export columns.
+func columnPaths(columns []string) [][]string {
+	paths := make([][]string, 0, len(columns))
+	for _, column := range columns {
+		paths = append(paths, strings.Split(column, "."))
+	}
+	return paths
+}
+
+// csvWriter exports search results as CSV.
+type csvWriter struct {
+	cw    *csv.Writer
+	paths [][]string
+}
+
+func newCSVWriter(w io.Writer, columns []string) (exportWriter, error) {
+	cw := csv.NewWriter(w)
+	err := cw.Write(append([]string{"path"}, columns...))
+	return &csvWriter{cw: cw, paths: columnPaths(columns)}, err
+}
+
+func (c *csvWriter) write(results []SearchResult) error {
+	for _, res := range results {
+		record := make([]string, 0, len(c.paths)+1)
+		record = append(record, res.Path)
+
+		values, found := columnValues(res, c.paths)
+		for i, value := range values {
+			if !found[i] {
+				record = append(record, "")
+				continue
+			}
+			record = append(record, csvValue(value))
+		}
+		if err := c.cw.Write(record); err != nil {
+			return err
+		}
+	}
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+func (c *csvWriter) close() error { return nil }
+
+// csvValue formats a metadata value as a CSV field. Strings are written as
+// is, null as an empty field, and other values as JSON. Strings that
+// spreadsheets would evaluate as formulas are prefixed with a quote.
+func csvValue(value interface{}) string {
+	if s, ok := value.(string); ok && s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	s, _ := exportValue(value)
+	return s
+}
+
+// exportValue formats a metadata value as a string, returning false for
+// null. Strings are returned as is, and other values as JSON.
+func exportValue(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return value, true
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// ndjsonWriter exports search results as newline delimited JSON.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer, columns []string) (exportWriter, error) {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+}
+
+func (n *ndjsonWriter) write(results []SearchResult) error {
+	for _, res := range results {
+		if err := n.enc.Encode(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) close() error { return nil }
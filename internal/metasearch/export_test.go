@@ -0,0 +1,86 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestExportCSV(t *testing.T) {
+	server := testServer()
+	testRepo(server).paginate = true
+
+	for key, metadata := range map[string]string{
+		"a.txt": `{"name": "a", "size": 12345678901234567890, "exif": {"iso": 400}}`,
+		"b.txt": `{"name": "=SUM(A1:A2)", "tags": ["x", "y"]}`,
+		"c.txt": `{"name": "c, \"quoted\"", "size": null}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	// All pages are exported
+	rr := httptest.NewRecorder()
+	r := testRequest(http.MethodPost, "/metasearch/testbucket", `{"columns": ["name", "size", "exif.iso", "tags"], "batchSize": 1}`)
+	r.Header.Set("Accept", "text/csv")
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Type"), "text/csv; charset=utf-8")
+	assert.Equal(t, rr.Header().Get("Content-Disposition"), `attachment; filename=testbucket.csv`)
+	require.Equal(t, "path,name,size,exif.iso,tags\n"+
+		"sj://testbucket/a.txt,a,12345678901234567890,400,\n"+
+		"sj://testbucket/b.txt,'=SUM(A1:A2),,,\"[\"\"x\"\",\"\"y\"\"]\"\n"+
+		"sj://testbucket/c.txt,\"c, \"\"quoted\"\"\",,,\n", rr.Body.String())
+
+	// Columns of listings
+	rr = httptest.NewRecorder()
+	r = testRequest(http.MethodGet, "/metasearch/testbucket?columns=name,size", "")
+	r.Header.Set("Accept", "text/csv")
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// Invalid exports
+	for _, body := range []string{
+		`{}`,
+		`{"columns": ["name."]}`,
+		`{"columns": ["name"], "countOnly": true}`,
+		`{"columns": ["name"], "delimiter": "/"}`,
+	} {
+		rr = httptest.NewRecorder()
+		r = testRequest(http.MethodPost, "/metasearch/testbucket", body)
+		r.Header.Set("Accept", "text/csv")
+		server.Handler.ServeHTTP(rr, r)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestExportParquet(t *testing.T) {
+	server := testServer()
+	testRepo(server).paginate = true
+
+	for _, key := range []string{"a.txt", "b.txt", "c.txt"} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, `{"name": "`+key+`", "size": 1}`)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	rr := httptest.NewRecorder()
+	r := testRequest(http.MethodPost, "/metasearch/testbucket", `{"columns": ["name", "size", "missing"], "batchSize": 2}`)
+	r.Header.Set("Accept", "application/vnd.apache.parquet")
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Type"), "application/vnd.apache.parquet")
+	assert.Equal(t, rr.Header().Get("Content-Disposition"), `attachment; filename=testbucket.parquet`)
+	assert.Equal(t, rr.Header().Get("X-Metasearch-Error"), "")
+
+	// Parquet files start and end with a magic number
+	body := rr.Body.Bytes()
+	require.True(t, bytes.HasPrefix(body, []byte("PAR1")))
+	require.True(t, bytes.HasSuffix(body, []byte("PAR1")))
+}
@@ -0,0 +1,327 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+	"storj.io/metasearch/signature"
+)
+
+// contentTypeKey is the metadata key in which uplink stores the content type
+// of an object.
+const contentTypeKey = "content-type"
+
+const maxExtractorResponseSize = 1 << 20
+
+const (
+	// extractionQueueSize is the maximum number of objects waiting for
+	// metadata extraction.
+	extractionQueueSize = 1000
+
+	// extractionWorkers is the number of concurrent extractions.
+	extractionWorkers = 4
+
+	// maxExtractionRetries is the number of times extracted metadata is
+	// written again after a concurrent change of the metadata of the object.
+	maxExtractionRetries = 3
+
+	// extractorAccessTTL is the lifetime of the access grants sent to the
+	// extractor to download objects.
+	extractorAccessTTL = time.Hour
+)
+
+// extractorActor is the actor of extracted metadata in the history.
+const extractorActor = "extractor"
+
+// ExtractRequest describes an object whose metadata is extracted.
+type ExtractRequest struct {
+	ProjectID          uuid.UUID              `json:"projectId"`
+	Bucket             string                 `json:"bucket"`
+	ObjectKey          string                 `json:"key"`
+	EncryptedObjectKey string                 `json:"encryptedKey"`
+	Version            int64                  `json:"version"`
+	ContentType        string                 `json:"contentType"`
+	Metadata           map[string]interface{} `json:"metadata"`
+
+	// Access is a serialized access grant restricted to downloading the
+	// object, which expires after an hour. It is empty if the access grant
+	// of the object cannot be shared.
+	Access string `json:"access,omitempty"`
+}
+
+// DownloadSharer is implemented by encryptors that can share an access grant
+// restricted to downloading an object, e.g. UplinkEncryptor.
+type DownloadSharer interface {
+	ShareDownload(bucket string, key string, ttl time.Duration) (string, error)
+}
+
+// MetadataExtractor derives extra metadata from the content of objects, e.g.
+// EXIF tags of images or the duration and codec of videos.
+type MetadataExtractor interface {
+	// Matches returns true if objects with the content type are extracted.
+	Matches(contentType string) bool
+
+	// Extract returns the metadata derived from an object.
+	Extract(ctx context.Context, request ExtractRequest) (map[string]interface{}, error)
+}
+
+// WebhookExtractor extracts metadata with a sidecar service. The object is
+// sent to the webhook URL as a JSON ExtractRequest, with an access grant to
+// download its content, and the webhook responds with a JSON object of the
+// extracted metadata.
+type WebhookExtractor struct {
+	url          string
+	token        string
+	secret       string
+	contentTypes []string
+	client       *http.Client
+}
+
+// NewWebhookExtractor creates a new WebhookExtractor. Content types can be
+// patterns such as "image/*". The token is sent to the webhook as a bearer
+// token. If secret is not empty, requests are signed with it, so that the
+// webhook can authenticate them and reject replayed requests with a
+// signature.Verifier. The secret is never sent.
+func NewWebhookExtractor(url string, token string, secret string, contentTypes []string, timeout time.Duration) *WebhookExtractor {
+	return &WebhookExtractor{
+		url:          url,
+		token:        token,
+		secret:       secret,
+		contentTypes: contentTypes,
+		client:       &http.Client{Timeout: timeout},
+	}
+}
+
+func (e *WebhookExtractor) Matches(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range e.contentTypes {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *WebhookExtractor) Extract(ctx context.Context, request ExtractRequest) (map[string]interface{}, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.token)
+	}
+	if e.secret != "" {
+		if err = signature.Sign(req, e.secret, body, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("extractor returned status %d", resp.StatusCode)
+	}
+
+	var metadata map[string]interface{}
+	err = newJSONDecoder(io.LimitReader(resp.Body, maxExtractorResponseSize)).Decode(&metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extractor response: %w", err)
+	}
+	return metadata, nil
+}
+
+// objectContentType returns the content type stored in the metadata of an
+// object by uplink.
+func objectContentType(metadata map[string]interface{}) string {
+	for k, v := range metadata {
+		if s, ok := v.(string); ok && strings.EqualFold(k, contentTypeKey) {
+			return s
+		}
+	}
+	return ""
+}
+
+// extractionJob is the extraction of the metadata of a migrated object.
+type extractionJob struct {
+	obj            ObjectInfo
+	clearObjectKey string
+	contentType    string
+	access         string
+}
+
+// extractionQueue extracts the metadata of migrated objects in the
+// background, so that slow extractors do not stall the migration queue, and
+// writes the extracted metadata back to the objects. Queued extractions are
+// lost when the server stops.
+type extractionQueue struct {
+	log       *zap.Logger
+	repo      MetaSearchRepo
+	extractor MetadataExtractor
+
+	jobs    chan extractionJob
+	pending sync.WaitGroup
+}
+
+// newExtractionQueue creates an extractionQueue, and starts its workers.
+func newExtractionQueue(log *zap.Logger, repo MetaSearchRepo, extractor MetadataExtractor) *extractionQueue {
+	q := &extractionQueue{
+		log:       log,
+		repo:      repo,
+		extractor: extractor,
+		jobs:      make(chan extractionJob, extractionQueueSize),
+	}
+	for i := 0; i < extractionWorkers; i++ {
+		go q.run()
+	}
+	return q
+}
+
+// enqueue queues the extraction of the metadata of a migrated object, if its
+// content type matches the extractor. If the encryptor that decrypted the
+// object can share it, the extractor receives an access grant restricted to
+// downloading the object. Objects are not queued while the queue is full.
+func (q *extractionQueue) enqueue(obj ObjectInfo, clearObjectKey string, encryptor Encryptor) {
+	contentType := objectContentType(obj.Metadata.ClearMetadata)
+	if contentType == "" || !q.extractor.Matches(contentType) {
+		return
+	}
+
+	job := extractionJob{
+		obj:            obj,
+		clearObjectKey: clearObjectKey,
+		contentType:    contentType,
+	}
+	if sharer, ok := encryptor.(DownloadSharer); ok {
+		access, err := sharer.ShareDownload(obj.BucketName, clearObjectKey, extractorAccessTTL)
+		if err != nil {
+			q.log.Warn("cannot share object with the extractor",
+				zap.Stringer("Project", obj.ProjectID),
+				zap.String("Bucket", obj.BucketName),
+				zap.String("ObjectKey", clearObjectKey),
+				zap.Error(err),
+			)
+		}
+		job.access = access
+	}
+
+	q.pending.Add(1)
+	select {
+	case q.jobs <- job:
+	default:
+		q.pending.Done()
+		mon.Counter("extractions_dropped").Inc(1)
+		q.log.Warn("extraction queue is full, object indexed without extracted metadata",
+			zap.Stringer("Project", obj.ProjectID),
+			zap.String("Bucket", obj.BucketName),
+			zap.String("ObjectKey", clearObjectKey),
+		)
+	}
+}
+
+// run runs the queued extractions.
+func (q *extractionQueue) run() {
+	for job := range q.jobs {
+		err := q.extract(context.Background(), job)
+		if err != nil {
+			q.log.Warn("cannot extract metadata",
+				zap.Stringer("Project", job.obj.ProjectID),
+				zap.String("Bucket", job.obj.BucketName),
+				zap.String("ObjectKey", job.clearObjectKey),
+				zap.Error(err),
+			)
+		}
+		q.pending.Done()
+	}
+}
+
+// wait waits until the queued extractions are done.
+func (q *extractionQueue) wait() {
+	q.pending.Wait()
+}
+
+// extract calls the extractor for an object, and merges the extracted
+// metadata into its clear metadata. Keys set by the user take precedence
+// over extracted ones, and the encrypted metadata of the object is left
+// unchanged. Nothing is written if a new version of the object was committed
+// or its metadata was updated by uplink in the meantime, and the write is
+// retried if the metadata of the object changed concurrently.
+func (q *extractionQueue) extract(ctx context.Context, job extractionJob) error {
+	extracted, err := q.extractor.Extract(ctx, ExtractRequest{
+		ProjectID:          job.obj.ProjectID,
+		Bucket:             job.obj.BucketName,
+		ObjectKey:          job.clearObjectKey,
+		EncryptedObjectKey: job.obj.ObjectKey,
+		Version:            job.obj.Version,
+		ContentType:        job.contentType,
+		Metadata:           job.obj.Metadata.ClearMetadata,
+		Access:             job.access,
+	})
+	if err != nil || len(extracted) == 0 {
+		return err
+	}
+
+	ctx = WithActor(ctx, extractorActor)
+	loc := job.obj.ObjectLocation
+	loc.Version = 0
+	for retries := 0; ; retries++ {
+		obj, err := q.repo.GetMetadata(ctx, loc)
+		if err != nil {
+			return err
+		}
+		if obj.Version != job.obj.Version {
+			return nil
+		}
+		if obj.MetaSearchQueuedAt != nil {
+			// The metadata was updated by uplink, and the object is extracted
+			// again when it is migrated
+			return nil
+		}
+
+		meta := obj.Metadata
+		meta.ClearMetadata = make(map[string]interface{}, len(extracted)+len(obj.Metadata.ClearMetadata))
+		for k, v := range extracted {
+			meta.ClearMetadata[k] = v
+		}
+		for k, v := range obj.Metadata.ClearMetadata {
+			meta.ClearMetadata[k] = v
+		}
+
+		err = q.repo.UpdateMetadataIfMatch(ctx, obj.ObjectLocation, obj.Metadata.ClearMetadata, meta)
+		if !errors.Is(err, ErrPreconditionFailed) || retries >= maxExtractionRetries {
+			return err
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fallbackScanLimit is the maximum number of objects scanned per page when
+// the metadata index is unavailable.
+const fallbackScanLimit = 10000
+
+// fallbackWarning is returned with the results of searches that fell back to
+// a sequential scan.
+var fallbackWarning = fmt.Sprintf("the metadata index is unavailable: results are limited to a scan of %d objects per page, use a keyPrefix to narrow down the search", fallbackScanLimit)
+
+// isMissingIndexError returns true if a query failed because an index it
+// requires does not exist, e.g. while the index is dropped and rebuilt.
+func isMissingIndexError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "42704" && strings.Contains(pgErr.Message, "index")
+}
+
+// scanMetadata searches metadata without the GIN indexes. It scans up to
+// fallbackScanLimit objects in key order, so searches remain functional in
+// small buckets or with a narrow key prefix while the indexes are
+// unavailable.
+func (r *MetabaseSearchRepository) scanMetadata(ctx context.Context, loc ObjectLocation, match matchQuery, startAfter ObjectLocation, asOf time.Time, batchSize int, fn ObjectFunc) (QueryMetadataResult, error) {
+	b := &matchQueryBuilder{loc: loc, dialect: r.dialect, tables: r.tables}
+	predicate, err := b.predicate(match)
+	if err != nil {
+		return QueryMetadataResult{}, err
+	}
+
+	query := `
+		SELECT
+			project_id, bucket_name, object_key, version, status,
+			encrypted_metadata_nonce, encrypted_metadata, encrypted_metadata_encrypted_key,
+			clear_metadata,
+			metasearch_queued_at,
+			total_plain_size, created_at, expires_at,
+			now(),
+			COALESCE(status <> ` + b.arg(statusPending) + ` AND (expires_at IS NULL OR expires_at > now()) AND ` + predicate + `, false)
+		FROM ` + b.table(defaultPrimaryKey) + `
+	`
+	query += b.dialect.asOf(asOf)
+	query += fmt.Sprintf("WHERE project_id = %s AND bucket_name = %s", b.arg(loc.ProjectID), b.arg([]byte(loc.BucketName)))
+	query += b.pageRange(loc, startAfter)
+	query += fmt.Sprintf("\nORDER BY project_id, bucket_name, object_key, version LIMIT %s", b.arg(fallbackScanLimit))
+
+	result := QueryMetadataResult{
+		AsOf:     asOf,
+		Warnings: []string{fallbackWarning},
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return QueryMetadataResult{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	var scanned, found int
+	var last ObjectLocation
+	for rows.Next() {
+		var obj ObjectInfo
+		var clearMetadata *string
+		var readTime time.Time
+		var matched bool
+		err = rows.Scan(
+			&obj.ProjectID, &obj.BucketName, &obj.ObjectKey, &obj.Version, &obj.Status,
+			&obj.Metadata.EncryptedMetadataNonce, &obj.Metadata.EncryptedMetadata, &obj.Metadata.EncryptedMetadataKey,
+			&clearMetadata,
+			&obj.MetaSearchQueuedAt,
+			&obj.Size, &obj.CreatedAt, &obj.ExpiresAt,
+			&readTime,
+			&matched,
+		)
+		if err != nil {
+			return QueryMetadataResult{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		if result.AsOf.IsZero() {
+			result.AsOf = readTime
+		}
+		scanned++
+		last = obj.ObjectLocation
+
+		if !matched {
+			continue
+		}
+
+		obj.Metadata.ClearMetadata, err = parseJSON(clearMetadata)
+		if err != nil {
+			return QueryMetadataResult{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+
+		if err := fn(obj); err != nil {
+			return result, err
+		}
+		found++
+		if found >= batchSize {
+			return result, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return QueryMetadataResult{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	// The scan limit was reached before finding a full page
+	if scanned >= fallbackScanLimit {
+		result.ScannedUntil = &last
+	}
+
+	return result, nil
+}
@@ -0,0 +1,258 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spacemonkeygo/monkit/v3"
+
+	"storj.io/common/uuid"
+)
+
+// featuresHeader is the request header that overrides feature flags for
+// internal testing, e.g. "index-fallback=off", and the response header that
+// lists the enabled feature flags.
+const featuresHeader = "X-Metasearch-Features"
+
+// FeatureIndexFallback falls back to a bounded sequential scan when the
+// metadata index is unavailable, instead of failing searches.
+const FeatureIndexFallback = "index-fallback"
+
+// defaultFeatureRollout contains the known feature flags with the percentage
+// of projects they are enabled for by default.
+var defaultFeatureRollout = map[string]float64{
+	FeatureIndexFallback: 100,
+}
+
+// FeatureFlags gates new or risky behaviors, so that they can be rolled out
+// gradually. A flag is enabled for a stable percentage of projects, unless it
+// is overridden for the project. If allowHeader is true, flags can also be
+// overridden per request with the X-Metasearch-Features header.
+type FeatureFlags struct {
+	rollout     map[string]float64
+	overrides   map[uuid.UUID]map[string]bool
+	allowHeader bool
+}
+
+// NewFeatureFlags creates a new FeatureFlags. The rollout percentages replace
+// the defaults of the given flags.
+func NewFeatureFlags(rollout map[string]float64, overrides map[uuid.UUID]map[string]bool, allowHeader bool) (*FeatureFlags, error) {
+	f := &FeatureFlags{
+		rollout:     make(map[string]float64, len(defaultFeatureRollout)),
+		overrides:   overrides,
+		allowHeader: allowHeader,
+	}
+	for flag, percent := range defaultFeatureRollout {
+		f.rollout[flag] = percent
+	}
+	for flag, percent := range rollout {
+		if _, ok := defaultFeatureRollout[flag]; !ok {
+			return nil, fmt.Errorf("unknown feature flag '%s'", flag)
+		}
+		f.rollout[flag] = percent
+	}
+	for _, flags := range overrides {
+		for flag := range flags {
+			if _, ok := defaultFeatureRollout[flag]; !ok {
+				return nil, fmt.Errorf("unknown feature flag '%s'", flag)
+			}
+		}
+	}
+	return f, nil
+}
+
+// ParseFeatureRollout parses a comma separated list of flag:percent pairs,
+// e.g. "index-fallback:10".
+func ParseFeatureRollout(s string) (map[string]float64, error) {
+	rollout := make(map[string]float64)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		flag, value, ok := strings.Cut(item, ":")
+		if !ok || flag == "" {
+			return nil, fmt.Errorf("invalid feature rollout '%s': must be flag:percent", item)
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid feature rollout '%s': percent must be between 0 and 100", item)
+		}
+		rollout[flag] = percent
+	}
+	return rollout, nil
+}
+
+// ParseFeatureOverrides parses a comma separated list of
+// project-id:flag:on|off triples.
+func ParseFeatureOverrides(s string) (map[uuid.UUID]map[string]bool, error) {
+	overrides := make(map[uuid.UUID]map[string]bool)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid feature override '%s': must be project-id:flag:on|off", item)
+		}
+		projectID, err := uuid.FromString(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid feature override '%s': invalid project ID", item)
+		}
+		enabled, ok := parseFeatureState(parts[2])
+		if !ok {
+			return nil, fmt.Errorf("invalid feature override '%s': state must be on or off", item)
+		}
+
+		if overrides[projectID] == nil {
+			overrides[projectID] = make(map[string]bool)
+		}
+		overrides[projectID][parts[1]] = enabled
+	}
+	return overrides, nil
+}
+
+func parseFeatureState(s string) (enabled bool, ok bool) {
+	switch s {
+	case "on":
+		return true, true
+	case "off":
+		return false, true
+	}
+	return false, false
+}
+
+// Resolve returns the state of all feature flags for a request of a project.
+func (f *FeatureFlags) Resolve(projectID uuid.UUID, r *http.Request) map[string]bool {
+	features := make(map[string]bool, len(f.rollout))
+	for flag, percent := range f.rollout {
+		features[flag] = featureBucket(flag, projectID) < percent
+	}
+	for flag, enabled := range f.overrides[projectID] {
+		features[flag] = enabled
+	}
+
+	if f.allowHeader {
+		for _, item := range strings.Split(r.Header.Get(featuresHeader), ",") {
+			flag, state, _ := strings.Cut(strings.TrimSpace(item), "=")
+			enabled, ok := parseFeatureState(state)
+			if _, known := features[flag]; known && ok {
+				features[flag] = enabled
+			}
+		}
+	}
+	return features
+}
+
+// featureBucket maps a project to a stable value in [0, 100) for a flag, so
+// that each flag is rolled out to a different sample of projects.
+func featureBucket(flag string, projectID uuid.UUID) float64 {
+	hash := sha256.Sum256(append([]byte(flag+":"), projectID.Bytes()...))
+	return float64(binary.BigEndian.Uint64(hash[:8])%10000) / 100
+}
+
+type featuresKey struct{}
+
+// requestFeatures holds the feature flags of a request. They are resolved once
+// the project of the request is authenticated.
+type requestFeatures struct {
+	enabled map[string]bool
+}
+
+// withFeatures adds a holder for the feature flags of the request to its
+// context, and reports the enabled flags in the response.
+func (s *Server) withFeatures(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		features := &requestFeatures{}
+		next.ServeHTTP(&featuresResponseWriter{ResponseWriter: w, features: features}, r.WithContext(context.WithValue(r.Context(), featuresKey{}, features)))
+	})
+}
+
+// resolveFeatures resolves the feature flags of an authenticated request.
+func (s *Server) resolveFeatures(ctx context.Context, projectID uuid.UUID, r *http.Request) {
+	features, ok := ctx.Value(featuresKey{}).(*requestFeatures)
+	if !ok {
+		return
+	}
+	features.enabled = s.Features.Resolve(projectID, r)
+
+	for flag, enabled := range features.enabled {
+		mon.Counter("feature_requests", monkit.NewSeriesTag("feature", flag), monkit.NewSeriesTag("enabled", strconv.FormatBool(enabled))).Inc(1)
+	}
+}
+
+// featureEnabled returns true if a feature flag is enabled for the request of
+// ctx. Outside of requests, or before the project is authenticated, flags
+// are only enabled if they are rolled out to all projects by default.
+func featureEnabled(ctx context.Context, flag string) bool {
+	if features, ok := ctx.Value(featuresKey{}).(*requestFeatures); ok && features.enabled != nil {
+		return features.enabled[flag]
+	}
+	return defaultFeatureRollout[flag] >= 100
+}
+
+// featuresResponseWriter lists the enabled feature flags in the response
+// headers.
+type featuresResponseWriter struct {
+	http.ResponseWriter
+	features    *requestFeatures
+	wroteHeader bool
+}
+
+func (w *featuresResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		enabled := make([]string, 0, len(w.features.enabled))
+		for flag, on := range w.features.enabled {
+			if on {
+				enabled = append(enabled, flag)
+			}
+		}
+		if len(enabled) > 0 {
+			sort.Strings(enabled)
+			w.Header().Set(featuresHeader, strings.Join(enabled, ","))
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *featuresResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *featuresResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *featuresResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hijacks the connection of WebSocket upgrades.
+func (w *featuresResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+)
+
+func TestParseFeatureFlags(t *testing.T) {
+	rollout, err := ParseFeatureRollout("index-fallback:10, other:50%")
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"index-fallback": 10, "other": 50}, rollout)
+
+	for _, invalid := range []string{"index-fallback", "index-fallback:many", "index-fallback:101"} {
+		_, err = ParseFeatureRollout(invalid)
+		require.Error(t, err, invalid)
+	}
+
+	overrides, err := ParseFeatureOverrides(testProjectID + ":index-fallback:off")
+	require.NoError(t, err)
+	projectID, err := uuid.FromString(testProjectID)
+	require.NoError(t, err)
+	require.Equal(t, map[uuid.UUID]map[string]bool{projectID: {"index-fallback": false}}, overrides)
+
+	for _, invalid := range []string{"index-fallback:off", "project:index-fallback:off", testProjectID + ":index-fallback:maybe"} {
+		_, err = ParseFeatureOverrides(invalid)
+		require.Error(t, err, invalid)
+	}
+
+	// Unknown flags are rejected
+	_, err = NewFeatureFlags(map[string]float64{"other": 50}, nil, false)
+	require.Error(t, err)
+}
+
+func TestFeatureFlagsResolve(t *testing.T) {
+	override, err := uuid.New()
+	require.NoError(t, err)
+	flags, err := NewFeatureFlags(map[string]float64{FeatureIndexFallback: 50}, map[uuid.UUID]map[string]bool{
+		override: {FeatureIndexFallback: true},
+	}, false)
+	require.NoError(t, err)
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	require.NoError(t, err)
+
+	// Flags are enabled for a stable fraction of projects
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		projectID, err := uuid.New()
+		require.NoError(t, err)
+		first := flags.Resolve(projectID, r)[FeatureIndexFallback]
+		require.Equal(t, first, flags.Resolve(projectID, r)[FeatureIndexFallback])
+		if first {
+			enabled++
+		}
+	}
+	require.InDelta(t, 500, enabled, 100)
+
+	// Project overrides take precedence
+	require.True(t, flags.Resolve(override, r)[FeatureIndexFallback])
+
+	// The header is ignored unless allowed
+	r.Header.Set(featuresHeader, "index-fallback=off")
+	require.True(t, flags.Resolve(override, r)[FeatureIndexFallback])
+
+	flags.allowHeader = true
+	require.False(t, flags.Resolve(override, r)[FeatureIndexFallback])
+}
+
+func TestFeatureFlagsServer(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{FeatureHeader: true})
+	require.NoError(t, err)
+	testRepo(server).indexUnavailable = true
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Enabled flags are reported in the response
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"foo": "123"}}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, FeatureIndexFallback, rr.Header().Get(featuresHeader))
+
+	// Without the fallback, searches fail while the index is unavailable
+	r := testRequest(http.MethodPost, "/metasearch/testbucket", `{"match": {"foo": "123"}}`)
+	r.Header.Set(featuresHeader, "index-fallback=off")
+	rr = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rr, r)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.Empty(t, rr.Header().Get(featuresHeader))
+}
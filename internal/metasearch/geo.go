@@ -0,0 +1,175 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"fmt"
+	"math"
+)
+
+// geoWithinOperator matches locations stored as {"lat": ..., "lon": ...}
+// objects that are within a bounding box or a radius, e.g.
+// {"location": {"$geoWithin": {"box": [minLon, minLat, maxLon, maxLat]}}} or
+// {"location": {"$geoWithin": {"center": [lon, lat], "radius": meters}}}.
+const geoWithinOperator = "$geoWithin"
+
+// earthRadius is the mean radius of the earth in meters.
+const earthRadius = 6371008.8
+
+// geoArea is the operand of a $geoWithin condition: a bounding box, or a
+// circle if radius is set.
+type geoArea struct {
+	minLat, minLon, maxLat, maxLon float64
+
+	centerLat, centerLon float64
+	radius               float64
+}
+
+// parseGeoArea parses the operand of a $geoWithin condition.
+func parseGeoArea(operand interface{}) (geoArea, error) {
+	var area geoArea
+	obj, ok := operand.(map[string]interface{})
+	if !ok {
+		return area, fmt.Errorf("%w: operand of '%s' must be an object with a box, or a center and a radius", ErrBadRequest, geoWithinOperator)
+	}
+
+	switch {
+	case obj["box"] != nil && len(obj) == 1:
+		box, ok := floatArray(obj["box"], 4)
+		if !ok {
+			return area, fmt.Errorf("%w: box of '%s' must be [minLon, minLat, maxLon, maxLat]", ErrBadRequest, geoWithinOperator)
+		}
+		area.minLon, area.minLat, area.maxLon, area.maxLat = box[0], box[1], box[2], box[3]
+		if !validLon(area.minLon) || !validLon(area.maxLon) || !validLat(area.minLat) || !validLat(area.maxLat) || area.minLat > area.maxLat {
+			return area, fmt.Errorf("%w: invalid box coordinates in '%s'", ErrBadRequest, geoWithinOperator)
+		}
+		return area, nil
+
+	case obj["center"] != nil && obj["radius"] != nil && len(obj) == 2:
+		center, ok := floatArray(obj["center"], 2)
+		if !ok || !validLon(center[0]) || !validLat(center[1]) {
+			return area, fmt.Errorf("%w: center of '%s' must be [lon, lat]", ErrBadRequest, geoWithinOperator)
+		}
+		radius, ok := floatArray([]interface{}{obj["radius"]}, 1)
+		if !ok || radius[0] <= 0 {
+			return area, fmt.Errorf("%w: radius of '%s' must be a positive number of meters", ErrBadRequest, geoWithinOperator)
+		}
+		area.centerLon, area.centerLat, area.radius = center[0], center[1], radius[0]
+
+		// The bounding box of the circle narrows down the candidates before
+		// the distance is computed. If it includes a pole, it spans all
+		// longitudes, and so does a circle too large for asin near the poles.
+		distance := radius[0] / earthRadius
+		delta := distance * 180 / math.Pi
+		area.minLat, area.maxLat = area.centerLat-delta, area.centerLat+delta
+		area.minLon, area.maxLon = -180, 180
+		if area.minLat > -90 && area.maxLat < 90 && distance < math.Pi/2 {
+			ratio := math.Sin(distance) / math.Cos(area.centerLat*math.Pi/180)
+			if ratio < 1 {
+				lonDelta := math.Asin(ratio) * 180 / math.Pi
+				area.minLon, area.maxLon = wrapLon(area.centerLon-lonDelta), wrapLon(area.centerLon+lonDelta)
+			}
+		}
+		area.minLat, area.maxLat = math.Max(area.minLat, -90), math.Min(area.maxLat, 90)
+		return area, nil
+	}
+	return area, fmt.Errorf("%w: operand of '%s' must be an object with a box, or a center and a radius", ErrBadRequest, geoWithinOperator)
+}
+
+// floatArray returns the numbers of a JSON array of n numbers.
+func floatArray(v interface{}, n int) ([]float64, bool) {
+	values, ok := v.([]interface{})
+	if !ok || len(values) != n {
+		return nil, false
+	}
+
+	result := make([]float64, 0, n)
+	for _, value := range values {
+		number, ok := numberValue(value)
+		if !ok {
+			return nil, false
+		}
+		f, _ := number.Float64()
+		result = append(result, f)
+	}
+	return result, true
+}
+
+func validLat(lat float64) bool { return lat >= -90 && lat <= 90 }
+
+func validLon(lon float64) bool { return lon >= -180 && lon <= 180 }
+
+// wrapLon wraps a longitude around the antimeridian.
+func wrapLon(lon float64) float64 {
+	switch {
+	case lon < -180:
+		return lon + 360
+	case lon > 180:
+		return lon - 360
+	}
+	return lon
+}
+
+// sql returns the SQL expression of a $geoWithin condition on the location
+// at path. Locations without numeric lat and lon values do not match.
+func (a geoArea) sql(b *matchQueryBuilder, column string, path []string) string {
+	latPath := b.arg(append(append([]string(nil), path...), "lat"))
+	lonPath := b.arg(append(append([]string(nil), path...), "lon"))
+	lat := fmt.Sprintf("(%s #>> %s::TEXT[])::FLOAT8", column, latPath)
+	lon := fmt.Sprintf("(%s #>> %s::TEXT[])::FLOAT8", column, lonPath)
+
+	condition := fmt.Sprintf("%s BETWEEN %s::FLOAT8 AND %s::FLOAT8", lat, b.arg(a.minLat), b.arg(a.maxLat))
+	if a.minLon <= a.maxLon {
+		condition += fmt.Sprintf(" AND %s BETWEEN %s::FLOAT8 AND %s::FLOAT8", lon, b.arg(a.minLon), b.arg(a.maxLon))
+	} else {
+		// The box crosses the antimeridian
+		condition += fmt.Sprintf(" AND (%s >= %s::FLOAT8 OR %s <= %s::FLOAT8)", lon, b.arg(a.minLon), lon, b.arg(a.maxLon))
+	}
+	if a.radius > 0 {
+		// Haversine distance
+		centerLat, centerLon := b.arg(a.centerLat), b.arg(a.centerLon)
+		condition += fmt.Sprintf(" AND 2 * %.1f * asin(sqrt(power(sin(radians(%s - %s::FLOAT8) / 2), 2) + cos(radians(%s::FLOAT8)) * cos(radians(%s)) * power(sin(radians(%s - %s::FLOAT8) / 2), 2))) <= %s::FLOAT8",
+			earthRadius, lat, centerLat, centerLat, lat, lon, centerLon, b.arg(a.radius))
+	}
+
+	// CASE makes sure that only numbers are cast
+	return fmt.Sprintf("CASE WHEN jsonb_typeof(%s #> %s::TEXT[]) = 'number' AND jsonb_typeof(%s #> %s::TEXT[]) = 'number' THEN %s ELSE false END",
+		column, latPath, column, lonPath, condition)
+}
+
+// contains returns true if a decoded location value is within the area.
+func (a geoArea) contains(value interface{}) bool {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	coordinates, ok := floatArray([]interface{}{obj["lat"], obj["lon"]}, 2)
+	if !ok {
+		return false
+	}
+	lat, lon := coordinates[0], coordinates[1]
+
+	if lat < a.minLat || lat > a.maxLat {
+		return false
+	}
+	if a.minLon <= a.maxLon && (lon < a.minLon || lon > a.maxLon) {
+		return false
+	}
+	if a.minLon > a.maxLon && lon < a.minLon && lon > a.maxLon {
+		return false
+	}
+	if a.radius > 0 {
+		return haversine(lat, lon, a.centerLat, a.centerLon) <= a.radius
+	}
+	return true
+}
+
+// haversine returns the distance between two locations in meters.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRadians(lat1 - lat2)
+	dLon := toRadians(lon1 - lon2)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(toRadians(lat2))*math.Cos(toRadians(lat1))*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,103 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMatchGeoWithin(t *testing.T) {
+	location := func(lat, lon float64) map[string]interface{} {
+		return map[string]interface{}{"lat": lat, "lon": lon}
+	}
+	parse := func(operand interface{}) matchCondition {
+		query, err := parseMatch(map[string]interface{}{
+			"location": map[string]interface{}{"$geoWithin": operand},
+		})
+		require.NoError(t, err)
+		require.Empty(t, query.contains)
+		require.Len(t, query.conditions, 1)
+		return query.conditions[0]
+	}
+
+	// Bounding box
+	box := parse(map[string]interface{}{"box": []interface{}{float64(8), float64(47), float64(9), json.Number("48")}})
+	require.Equal(t, "GEO", box.operator)
+	require.Equal(t, "object", box.jsonType())
+	require.True(t, box.matches(location(47.5, 8.5), false))
+	require.False(t, box.matches(location(46, 8.5), false))
+	require.False(t, box.matches(location(47.5, 9.5), false))
+	require.False(t, box.matches(map[string]interface{}{"lat": "47.5", "lon": "8.5"}, false))
+	require.False(t, box.matches("47.5,8.5", false))
+
+	b := &matchQueryBuilder{}
+	condition, err := b.condition(box, false)
+	require.NoError(t, err)
+	require.Equal(t, "CASE WHEN jsonb_typeof(clear_metadata #> $1::TEXT[]) = 'number' AND jsonb_typeof(clear_metadata #> $2::TEXT[]) = 'number' THEN "+
+		"(clear_metadata #>> $1::TEXT[])::FLOAT8 BETWEEN $3::FLOAT8 AND $4::FLOAT8 AND "+
+		"(clear_metadata #>> $2::TEXT[])::FLOAT8 BETWEEN $5::FLOAT8 AND $6::FLOAT8 ELSE false END", condition)
+	require.Equal(t, []interface{}{[]string{"location", "lat"}, []string{"location", "lon"}, float64(47), float64(48), float64(8), float64(9)}, b.args)
+
+	// Boxes across the antimeridian
+	pacific := parse(map[string]interface{}{"box": []interface{}{float64(170), float64(-20), float64(-170), float64(-10)}})
+	require.True(t, pacific.matches(location(-15, 175), false))
+	require.True(t, pacific.matches(location(-15, -175), false))
+	require.False(t, pacific.matches(location(-15, 0), false))
+
+	// Radius
+	zurich := parse(map[string]interface{}{"center": []interface{}{8.5417, 47.3769}, "radius": float64(10000)})
+	require.True(t, zurich.matches(location(47.4582, 8.5492), false))
+	require.False(t, zurich.matches(location(47.4988, 8.7241), false))
+
+	b = &matchQueryBuilder{}
+	condition, err = b.condition(zurich, false)
+	require.NoError(t, err)
+	require.Contains(t, condition, "2 * 6371008.8 * asin(sqrt(")
+	require.Len(t, b.args, 9)
+
+	// Circles around a pole span all longitudes
+	pole := parse(map[string]interface{}{"center": []interface{}{float64(0), 89.99}, "radius": float64(100000)})
+	require.True(t, pole.matches(location(89.5, 179), false))
+	require.False(t, pole.matches(location(88, 0), false))
+
+	// Circles reaching up to the poles, or around the earth, have valid
+	// bounding boxes
+	for _, lat := range []float64{-90, -89.999, -45, 0, 45, 60, 89.999, 90} {
+		edge := (90 - math.Abs(lat) - 1e-9) * math.Pi / 180 * earthRadius
+		for _, radius := range []float64{1, 1000, 1e6, 5e6, 1.5e7, 2.1e7, edge} {
+			if radius <= 0 {
+				continue
+			}
+			area, err := parseGeoArea(map[string]interface{}{"center": []interface{}{float64(0), lat}, "radius": radius})
+			require.NoError(t, err)
+			require.True(t, validLat(area.minLat) && validLat(area.maxLat), "lat %v radius %v", lat, radius)
+			require.True(t, validLon(area.minLon) && validLon(area.maxLon), "lat %v radius %v", lat, radius)
+		}
+	}
+	huge := parse(map[string]interface{}{"center": []interface{}{float64(0), float64(0)}, "radius": float64(15000000)})
+	require.True(t, huge.matches(location(0, 120), false))
+	require.False(t, huge.matches(location(0, 180), false))
+
+	// Invalid operands
+	for _, operand := range []interface{}{
+		"box",
+		map[string]interface{}{},
+		map[string]interface{}{"box": []interface{}{float64(1), float64(2), float64(3)}},
+		map[string]interface{}{"box": []interface{}{float64(0), float64(91), float64(1), float64(92)}},
+		map[string]interface{}{"box": []interface{}{float64(0), float64(10), float64(1), float64(5)}},
+		map[string]interface{}{"center": []interface{}{float64(0), float64(0)}},
+		map[string]interface{}{"center": []interface{}{float64(0), float64(0)}, "radius": float64(-1)},
+		map[string]interface{}{"center": []interface{}{float64(200), float64(0)}, "radius": float64(1)},
+		map[string]interface{}{"box": []interface{}{float64(0), float64(0), float64(1), float64(1)}, "radius": float64(1)},
+	} {
+		_, err := parseMatch(map[string]interface{}{
+			"location": map[string]interface{}{"$geoWithin": operand},
+		})
+		require.ErrorIs(t, err, ErrBadRequest, operand)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+)
+
+const maxGrantsPerProject = 1000
+
+// GrantUsage describes the usage of a single access grant.
+type GrantUsage struct {
+	Fingerprint string    `json:"fingerprint"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+	Requests    int64     `json:"requests"`
+}
+
+// ProjectGrants lists the access grants used for a project.
+type ProjectGrants struct {
+	ProjectID uuid.UUID    `json:"projectId"`
+	Grants    []GrantUsage `json:"grants"`
+}
+
+// GrantTracker records the fingerprints of the access grants used per
+// project, so that security teams can audit which credentials are actively
+// used. Fingerprints are not reversible: they are derived from the hash of the
+// credentials.
+type GrantTracker struct {
+	log      *zap.Logger
+	mutex    sync.Mutex
+	projects map[uuid.UUID]map[string]*GrantUsage
+}
+
+// NewGrantTracker creates a new GrantTracker.
+func NewGrantTracker(log *zap.Logger) *GrantTracker {
+	return &GrantTracker{
+		log:      log,
+		projects: make(map[uuid.UUID]map[string]*GrantUsage),
+	}
+}
+
+// grantFingerprint returns the fingerprint of the credentials in the
+// Authorization header of the request, or of the access grant of console
+// session requests.
+func grantFingerprint(r *http.Request) string {
+	hash := sha256.Sum256([]byte(grantCredentials(r)))
+	return hex.EncodeToString(hash[:16])
+}
+
+// grantCredentials returns the credentials identifying the access grant of
+// the request, or an empty string for anonymous requests, e.g. to public
+// buckets.
+func grantCredentials(r *http.Request) string {
+	credentials := r.Header.Get("Authorization")
+	if credentials == "" {
+		credentials = r.Header.Get(consoleAccessHeader)
+	}
+	return credentials
+}
+
+// Track records the usage of an access grant for a project.
+func (t *GrantTracker) Track(projectID uuid.UUID, fingerprint string) {
+	now := time.Now()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	grants, ok := t.projects[projectID]
+	if !ok {
+		grants = make(map[string]*GrantUsage)
+		t.projects[projectID] = grants
+	}
+
+	if usage, ok := grants[fingerprint]; ok {
+		usage.LastSeen = now
+		usage.Requests++
+		return
+	}
+
+	if len(grants) >= maxGrantsPerProject {
+		t.evictOldest(grants)
+	}
+
+	grants[fingerprint] = &GrantUsage{
+		Fingerprint: fingerprint,
+		FirstSeen:   now,
+		LastSeen:    now,
+		Requests:    1,
+	}
+
+	t.log.Info("new access grant used",
+		zap.Stringer("Project", projectID),
+		zap.String("Fingerprint", fingerprint),
+	)
+}
+
+// trackGrant records the usage of the access grant of a request, unless the
+// server is stateless.
+func (s *Server) trackGrant(projectID uuid.UUID, r *http.Request) {
+	if s.Config.Stateless {
+		return
+	}
+	s.Grants.Track(projectID, grantFingerprint(r))
+}
+
+// evictOldest removes the least recently used grant. Must be called while
+// t.mutex is locked.
+func (t *GrantTracker) evictOldest(grants map[string]*GrantUsage) {
+	var oldest *GrantUsage
+	for _, usage := range grants {
+		if oldest == nil || usage.LastSeen.Before(oldest.LastSeen) {
+			oldest = usage
+		}
+	}
+	if oldest != nil {
+		delete(grants, oldest.Fingerprint)
+	}
+}
+
+// Grants returns the grants used for a project, or for all projects if
+// projectID is zero. Grants are sorted by the time they were last seen.
+func (t *GrantTracker) Grants(projectID uuid.UUID) []ProjectGrants {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	result := make([]ProjectGrants, 0, len(t.projects))
+	for id, grants := range t.projects {
+		if !projectID.IsZero() && id != projectID {
+			continue
+		}
+
+		project := ProjectGrants{
+			ProjectID: id,
+			Grants:    make([]GrantUsage, 0, len(grants)),
+		}
+		for _, usage := range grants {
+			project.Grants = append(project.Grants, *usage)
+		}
+		sort.Slice(project.Grants, func(i, j int) bool {
+			return project.Grants[i].LastSeen.After(project.Grants[j].LastSeen)
+		})
+		result = append(result, project)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].ProjectID[:], result[j].ProjectID[:]) < 0
+	})
+	return result
+}
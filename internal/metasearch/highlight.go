@@ -0,0 +1,314 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// highlighter collects the metadata values that satisfied the match query and
+// the filter of a search. Highlights are keyed by the dot separated path of
+// the metadata key.
+type highlighter struct {
+	highlights map[string]interface{}
+
+	// object is the object whose system attributes are matched by the
+	// conditions on system attributes, if set.
+	object *ObjectInfo
+}
+
+// highlightMetadata returns the metadata values of a search result that
+// satisfied the match query and the filter of the request.
+func (s *Server) highlightMetadata(request *SearchRequest, metadata map[string]interface{}) map[string]interface{} {
+	h := &highlighter{highlights: make(map[string]interface{})}
+	if request.match != nil {
+		h.query(*request.match, metadata)
+	}
+	if request.Filter != "" {
+		h.filter(s, request, metadata)
+	}
+	if len(h.highlights) == 0 {
+		return nil
+	}
+	return h.highlights
+}
+
+// query highlights the values that satisfied a match query, and returns true
+// if the query matches the metadata. Conditions on system attributes are
+// assumed to match, unless the highlighter has an object.
+func (h *highlighter) query(query matchQuery, metadata map[string]interface{}) bool {
+	// Values are only highlighted if the whole query matches, so collect them
+	// separately first.
+	q := &highlighter{highlights: make(map[string]interface{}), object: h.object}
+	matched := q.contains(query, "", metadata, query.contains)
+
+	for _, c := range query.conditions {
+		path, value, ok := lookupPath(metadata, c.path, query.caseInsensitive)
+		if !ok || !c.matches(value, query.caseInsensitive) {
+			matched = false
+			continue
+		}
+		q.highlights[path] = value
+	}
+	for _, key := range query.exists {
+		path, value, ok := lookupPath(metadata, []string{key}, query.caseInsensitive)
+		if !ok {
+			matched = false
+			continue
+		}
+		q.highlights[path] = value
+	}
+	for _, key := range query.missing {
+		if _, _, ok := lookupPath(metadata, []string{key}, query.caseInsensitive); ok {
+			matched = false
+		}
+	}
+	if h.object != nil {
+		for _, c := range query.system {
+			if !c.matches(*h.object) {
+				matched = false
+			}
+		}
+	}
+
+	if len(query.anyOf) > 0 {
+		anyMatched := false
+		for _, alternative := range query.anyOf {
+			anyMatched = q.query(alternative, metadata) || anyMatched
+		}
+		matched = matched && anyMatched
+	}
+	for _, subquery := range query.allOf {
+		matched = q.query(subquery, metadata) && matched
+	}
+	if query.not != nil {
+		discarded := &highlighter{highlights: make(map[string]interface{}), object: h.object}
+		matched = matched && !discarded.query(*query.not, metadata)
+	}
+
+	if matched {
+		for k, v := range q.highlights {
+			h.highlights[k] = v
+		}
+	}
+	return matched
+}
+
+// contains highlights the values of the containment part of a query, and
+// returns true if the metadata contains all of them.
+func (h *highlighter) contains(query matchQuery, path string, metadata map[string]interface{}, contains map[string]interface{}) bool {
+	matched := true
+	for k, expected := range contains {
+		key, value, ok := lookupKey(metadata, k, query.caseInsensitive)
+		if !ok {
+			matched = false
+			continue
+		}
+		fieldPath := joinLintPath(path, key)
+
+		if child, ok := expected.(map[string]interface{}); ok && len(child) > 0 {
+			obj, ok := value.(map[string]interface{})
+			if !ok || !h.contains(query, fieldPath, obj, child) {
+				matched = false
+			}
+			continue
+		}
+		if !jsonContains(value, expected, query.caseInsensitive) {
+			matched = false
+			continue
+		}
+		h.highlights[fieldPath] = value
+	}
+	return matched
+}
+
+// filter highlights the top-level keys the filter depends on: the keys
+// without which the filter no longer matches.
+func (h *highlighter) filter(s *Server, request *SearchRequest, metadata map[string]interface{}) {
+	reduced := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		reduced[k] = v
+	}
+
+	for k, v := range metadata {
+		delete(reduced, k)
+		matched, err := s.filterMetadata(request, reduced)
+		reduced[k] = v
+		if err == nil && !matched {
+			h.highlights[k] = v
+		}
+	}
+}
+
+// lookupKey returns the key and value of a metadata key. With caseInsensitive,
+// the key of the query is lower-case, and it matches metadata keys in any
+// case.
+func lookupKey(metadata map[string]interface{}, key string, caseInsensitive bool) (string, interface{}, bool) {
+	if value, ok := metadata[key]; ok {
+		return key, value, true
+	}
+	if caseInsensitive {
+		for k, value := range metadata {
+			if strings.ToLower(k) == key {
+				return k, value, true
+			}
+		}
+	}
+	return "", nil, false
+}
+
+// lookupPath returns the dot separated path and the value of a nested
+// metadata key.
+func lookupPath(metadata map[string]interface{}, path []string, caseInsensitive bool) (string, interface{}, bool) {
+	var value interface{} = metadata
+	var fieldPath string
+	for _, key := range path {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return "", nil, false
+		}
+		var k string
+		k, value, ok = lookupKey(obj, key, caseInsensitive)
+		if !ok {
+			return "", nil, false
+		}
+		fieldPath = joinLintPath(fieldPath, k)
+	}
+	return fieldPath, value, true
+}
+
+// jsonContains returns true if a decoded JSON value contains another like the
+// JSONB @> operator: objects contain the keys and values of the other object,
+// arrays contain all elements of the other array, and scalars are equal.
+func jsonContains(value interface{}, contained interface{}, caseInsensitive bool) bool {
+	switch contained := contained.(type) {
+	case map[string]interface{}:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range contained {
+			_, child, ok := lookupKey(obj, k, caseInsensitive)
+			if !ok || !jsonContains(child, v, caseInsensitive) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		arr, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, v := range contained {
+			found := false
+			for _, element := range arr {
+				if jsonContains(element, v, caseInsensitive) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	case string:
+		s, ok := value.(string)
+		if ok && caseInsensitive {
+			s = strings.ToLower(s)
+		}
+		return ok && s == contained
+	case float64, json.Number:
+		// Numbers are compared by value, like in JSONB
+		n, ok := numberValue(value)
+		operand, operandOK := numberValue(contained)
+		return ok && operandOK && n.Cmp(operand) == 0
+	default:
+		return value == contained
+	}
+}
+
+// matches returns true if a metadata value satisfies the condition.
+func (c matchCondition) matches(value interface{}, caseInsensitive bool) bool {
+	switch operand := c.value.(type) {
+	case geoArea:
+		return operand.contains(value)
+	case time.Time:
+		s, ok := value.(string)
+		if !ok || !rfc3339Regexp.MatchString(strings.ToUpper(s)) {
+			return false
+		}
+		t, err := time.Parse(time.RFC3339, strings.ToUpper(s))
+		if err != nil {
+			return false
+		}
+		return compare(c.operator, t.Compare(operand))
+	case string:
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		if caseInsensitive {
+			s = strings.ToLower(s)
+		}
+		switch c.operator {
+		case "LIKE":
+			return likeToRegexp(operand).MatchString(s)
+		case "SUBTREE":
+			return s == operand || strings.HasPrefix(s, operand+"/")
+		}
+		return compare(c.operator, strings.Compare(s, operand))
+	case float64, json.Number:
+		n, ok := numberValue(value)
+		o, operandOK := numberValue(operand)
+		if !ok || !operandOK {
+			return false
+		}
+		return compare(c.operator, n.Cmp(o))
+	}
+	return false
+}
+
+// compare returns true if the result of a comparison satisfies a comparison
+// operator.
+func compare(operator string, cmp int) bool {
+	switch operator {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// likeToRegexp converts a LIKE pattern to an anchored regular expression.
+func likeToRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,237 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MetadataRevision is a change of the clear metadata of an object.
+type MetadataRevision struct {
+	Revision  int64
+	Version   int64
+	Actor     string
+	ChangedAt time.Time
+
+	// Watermark is the change watermark of the bucket after the change. It
+	// is zero for changes recorded before watermarks were tracked.
+	Watermark int64
+
+	OldMetadata map[string]interface{}
+	NewMetadata map[string]interface{}
+}
+
+// HistoryEntry is a metadata change in a history response.
+type HistoryEntry struct {
+	Revision    int64                  `json:"revision,string"`
+	Version     int64                  `json:"version"`
+	Actor       string                 `json:"actor"`
+	ChangedAt   time.Time              `json:"changedAt"`
+	Watermark   int64                  `json:"watermark,omitempty"`
+	OldMetadata map[string]interface{} `json:"oldMetadata"`
+	NewMetadata map[string]interface{} `json:"newMetadata"`
+}
+
+// RollbackRequest contains the revision to restore.
+type RollbackRequest struct {
+	Revision int64 `json:"revision,string"`
+}
+
+// HistoryResponse contains fields for a history response.
+type HistoryResponse struct {
+	Revisions []HistoryEntry `json:"revisions"`
+	PageToken string         `json:"pageToken,omitempty"`
+}
+
+type actorKey struct{}
+
+// WithActor returns a context that attributes metadata changes to the actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFromContext returns the actor of metadata changes made with ctx.
+func actorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// withActor attributes the metadata changes of a request to the fingerprint
+// of its access grant.
+func withActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithActor(r.Context(), grantFingerprint(r))))
+	})
+}
+
+func (r *MetabaseSearchRepository) GetMetadataHistory(ctx context.Context, loc ObjectLocation, before int64, limit int) ([]MetadataRevision, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT
+			revision, version, actor, changed_at, COALESCE(watermark, 0),
+			old_clear_metadata, new_clear_metadata
+		FROM metasearch_history
+		WHERE
+			(project_id, bucket_name, object_key) = ($1, $2, $3) AND
+			($4 = 0 OR revision < $4)
+		ORDER BY revision DESC
+		LIMIT $5
+		`,
+		loc.ProjectID, []byte(loc.BucketName), []byte(loc.ObjectKey), before, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	revisions := make([]MetadataRevision, 0, limit)
+	for rows.Next() {
+		var rev MetadataRevision
+		var oldMetadata, newMetadata *string
+		err = rows.Scan(
+			&rev.Revision, &rev.Version, &rev.Actor, &rev.ChangedAt, &rev.Watermark,
+			&oldMetadata, &newMetadata,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+
+		rev.OldMetadata, err = parseJSON(oldMetadata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		rev.NewMetadata, err = parseJSON(newMetadata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return revisions, nil
+}
+
+// HandleHistory lists the metadata changes of an object, newest first.
+func (s *Server) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request BaseRequest
+
+	err := s.validateRequest(ctx, r, &request, nil)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionReadMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := defaultBatchSize
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxBatchSize {
+			s.errorResponse(w, fmt.Errorf("%w: invalid limit", ErrBadRequest))
+			return
+		}
+	}
+
+	var before int64
+	if v := q.Get("pageToken"); v != "" {
+		before, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.errorResponse(w, fmt.Errorf("%w: invalid pageToken", ErrBadRequest))
+			return
+		}
+	}
+
+	revisions, err := s.Repo.GetMetadataHistory(ctx, request.EncryptedLocation, before, limit)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	response := HistoryResponse{
+		Revisions: make([]HistoryEntry, 0, len(revisions)),
+	}
+	for _, rev := range revisions {
+		response.Revisions = append(response.Revisions, HistoryEntry(rev))
+	}
+	if len(revisions) >= limit {
+		response.PageToken = strconv.FormatInt(revisions[len(revisions)-1].Revision, 10)
+	}
+
+	s.jsonResponse(w, http.StatusOK, response)
+}
+
+// HandleRollback restores the metadata of an object to the state after a
+// previous revision. The metadata is encrypted with the encryptor of the
+// caller, and the rollback is recorded as a new revision.
+func (s *Server) HandleRollback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request BaseRequest
+	var body RollbackRequest
+
+	err := s.validateRequest(ctx, r, &request, &body)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionWriteMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	if body.Revision <= 0 {
+		s.errorResponse(w, fmt.Errorf("%w: revision is required", ErrBadRequest))
+		return
+	}
+
+	revisions, err := s.Repo.GetMetadataHistory(ctx, request.EncryptedLocation, body.Revision+1, 1)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+	if len(revisions) == 0 || revisions[0].Revision != body.Revision {
+		s.errorResponse(w, fmt.Errorf("%w: revision not found", ErrNotFound))
+		return
+	}
+
+	// The metadata of the revision must be valid for the current
+	// vocabularies, like any other update
+	err = s.checkVocabularies(ctx, request.Location.ProjectID, revisions[0].NewMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	meta := ObjectMetadata{
+		ClearMetadata: revisions[0].NewMetadata,
+	}
+
+	err = request.Encryptor.EncryptMetadata(request.Location.BucketName, request.Location.ObjectKey, &meta)
+	if err != nil {
+		s.errorResponse(w, fmt.Errorf("%w: cannot encrypt metadata", ErrBadRequest))
+		return
+	}
+
+	err = s.updateMetadata(ctx, r, request.EncryptedLocation, request.Encryptor, meta)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", metadataETag(meta.ClearMetadata))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+)
+
+func TestHTTP2(t *testing.T) {
+	certFile, keyFile, cert := writeTestCertificate(t)
+
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+	})
+	require.NoError(t, err)
+
+	config, err := server.tlsConfig()
+	require.NoError(t, err)
+	httpServer, err := server.httpServer(config)
+	require.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = httpServer.ServeTLS(lis, "", "") }()
+	defer func() { _ = httpServer.Close() }()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	}}
+
+	resp, err := client.Get("https://" + lis.Addr().String() + "/slo")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 2, resp.ProtoMajor)
+}
+
+func TestH2C(t *testing.T) {
+	server := testServer()
+	server.Config.H2C = true
+
+	httpServer, err := server.httpServer(nil)
+	require.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = httpServer.Serve(lis) }()
+	defer func() { _ = httpServer.Close() }()
+
+	// Prior knowledge HTTP/2 over cleartext
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+
+	r := testRequest(http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	r.URL.Host = lis.Addr().String()
+	resp, err := client.Do(r)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, 2, resp.ProtoMajor)
+
+	// HTTP/1.1 clients are still served
+	resp, err = http.Get("http://" + lis.Addr().String() + "/slo")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 1, resp.ProtoMajor)
+}
@@ -0,0 +1,217 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	idempotencyKeyTTL       = 24 * time.Hour
+	maxIdempotencyKeys      = 100000
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyStore stores the responses of mutating requests with an
+// Idempotency-Key header, so that retried requests are not applied twice.
+type IdempotencyStore struct {
+	ttl time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]*idempotentResponse
+}
+
+type idempotentResponse struct {
+	requestHash string
+	done        bool
+	expires     time.Time
+
+	status int
+	header http.Header
+	body   []byte
+}
+
+// NewIdempotencyStore creates a new IdempotencyStore. Responses are kept for
+// the given duration.
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:     ttl,
+		entries: make(map[string]*idempotentResponse),
+	}
+}
+
+// begin registers a request with an idempotency key. It returns the stored
+// response if the request has already been completed, or nil if the request
+// must be executed.
+func (st *IdempotencyStore) begin(key string, requestHash string) (*idempotentResponse, error) {
+	now := time.Now()
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if entry, ok := st.entries[key]; ok && now.Before(entry.expires) {
+		if entry.requestHash != requestHash {
+			return nil, fmt.Errorf("%w: idempotency key was used for a different request", ErrUnprocessableEntity)
+		}
+		if !entry.done {
+			return nil, fmt.Errorf("%w: a request with the same idempotency key is in progress", ErrConflict)
+		}
+		return entry, nil
+	}
+
+	if len(st.entries) >= maxIdempotencyKeys {
+		st.cleanup(now)
+	}
+
+	st.entries[key] = &idempotentResponse{
+		requestHash: requestHash,
+		expires:     now.Add(st.ttl),
+	}
+	return nil, nil
+}
+
+// finish stores the response of a request. Server errors are not stored, so
+// that the request can be retried.
+func (st *IdempotencyStore) finish(key string, status int, header http.Header, body []byte) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	entry, ok := st.entries[key]
+	if !ok {
+		return
+	}
+
+	if status >= http.StatusInternalServerError {
+		delete(st.entries, key)
+		return
+	}
+
+	entry.done = true
+	entry.status = status
+	entry.header = header
+	entry.body = body
+}
+
+// cleanup removes expired entries. If no entry has expired, the entry that
+// expires first is removed. Must be called while st.mutex is locked.
+func (st *IdempotencyStore) cleanup(now time.Time) {
+	var oldestKey string
+	var oldest *idempotentResponse
+	for key, entry := range st.entries {
+		if now.After(entry.expires) {
+			delete(st.entries, key)
+			continue
+		}
+		if oldest == nil || entry.expires.Before(oldest.expires) {
+			oldestKey, oldest = key, entry
+		}
+	}
+
+	if len(st.entries) >= maxIdempotencyKeys && oldest != nil {
+		delete(st.entries, oldestKey)
+	}
+}
+
+// idempotent wraps a mutating handler. If the request has an Idempotency-Key
+// header, the response is stored, and retries of the same request get the
+// stored response without executing the request again. The request is
+// authenticated before a response is replayed or stored, and keys are scoped
+// to the project and credentials of the request. Responses are stored in
+// memory, so idempotency keys are rejected by stateless servers.
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if s.Config.Stateless {
+			// Retries may be sent to another server instance
+			s.errorResponse(w, fmt.Errorf("%w: idempotency keys are not supported by stateless servers", ErrBadRequest))
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			s.errorResponse(w, fmt.Errorf("%w: idempotency key is too long", ErrBadRequest))
+			return
+		}
+
+		projectID, _, _, err := s.Auth.Authenticate(r.Context(), r)
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+
+		var body []byte
+		if r.Body != nil {
+			body, err = io.ReadAll(r.Body)
+			if err != nil {
+				s.errorResponse(w, bodyError(err, "error reading request body"))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		storeKey := projectID.String() + ":" + grantFingerprint(r) + ":" + key
+		stored, err := s.Idempotency.begin(storeKey, requestHash(r, body))
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+
+		if stored != nil {
+			for k, v := range stored.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.status)
+			_, _ = w.Write(stored.body)
+			return
+		}
+
+		rec := &recordingResponseWriter{ResponseWriter: w}
+		next(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		s.Idempotency.finish(storeKey, rec.status, w.Header().Clone(), rec.body.Bytes())
+	}
+}
+
+// requestHash returns a hash of the parts of a request that affect its result.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", r.Method, r.URL.RequestURI(), r.Header.Get("If-Match"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingResponseWriter records the status and body of a response, while
+// writing it to the client.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,348 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	importFormatCSV         = "csv"
+	importFormatS3Inventory = "s3-inventory"
+
+	maxImportErrors = 100
+)
+
+// s3InventoryFields are the S3 inventory fields that describe the object
+// itself, and are not imported as metadata by default.
+var s3InventoryFields = map[string]bool{
+	"Bucket":         true,
+	"Key":            true,
+	"VersionId":      true,
+	"IsLatest":       true,
+	"IsDeleteMarker": true,
+}
+
+// ImportOptions describes how rows of a manifest are mapped to metadata.
+type ImportOptions struct {
+	// Format is "csv" or "s3-inventory".
+	Format string
+	// KeyColumn is the column that contains the object key.
+	KeyColumn string
+	// Schema contains the column names, if the manifest has no header row.
+	// S3 inventory reports have no header row: the schema is the fileSchema
+	// field of the inventory manifest.
+	Schema []string
+	// Columns maps column names to metadata keys. If empty, all columns are
+	// imported with their own name.
+	Columns map[string]string
+	// Merge merges the imported values into the existing metadata, instead
+	// of replacing it.
+	Merge bool
+}
+
+// ImportError describes a row that could not be imported.
+type ImportError struct {
+	Line  int    `json:"line"`
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportResponse contains fields for an import response.
+type ImportResponse struct {
+	Imported int           `json:"imported"`
+	Skipped  int           `json:"skipped"`
+	Failed   int           `json:"failed"`
+	Errors   []ImportError `json:"errors,omitempty"`
+}
+
+// parseImportOptions parses the import options from the URL query string.
+func parseImportOptions(q url.Values) (opts ImportOptions, err error) {
+	opts.Format = q.Get("format")
+	if opts.Format == "" {
+		opts.Format = importFormatCSV
+	}
+
+	switch opts.Format {
+	case importFormatCSV:
+		opts.KeyColumn = "key"
+	case importFormatS3Inventory:
+		opts.KeyColumn = "Key"
+	default:
+		return opts, fmt.Errorf("%w: invalid format '%s'", ErrBadRequest, opts.Format)
+	}
+
+	if keyColumn := q.Get("keyColumn"); keyColumn != "" {
+		opts.KeyColumn = keyColumn
+	}
+
+	if schema := q.Get("schema"); schema != "" {
+		for _, column := range strings.Split(schema, ",") {
+			opts.Schema = append(opts.Schema, strings.TrimSpace(column))
+		}
+	}
+	if opts.Format == importFormatS3Inventory && len(opts.Schema) == 0 {
+		return opts, fmt.Errorf("%w: schema is required for S3 inventory reports", ErrBadRequest)
+	}
+
+	if columns := q.Get("columns"); columns != "" {
+		opts.Columns = make(map[string]string)
+		for _, mapping := range strings.Split(columns, ",") {
+			column, key, ok := strings.Cut(mapping, ":")
+			if !ok {
+				key = column
+			}
+			column, key = strings.TrimSpace(column), strings.TrimSpace(key)
+			if column == "" || key == "" {
+				return opts, fmt.Errorf("%w: invalid column mapping '%s'", ErrBadRequest, mapping)
+			}
+			opts.Columns[column] = key
+		}
+	}
+
+	if merge := q.Get("merge"); merge != "" {
+		opts.Merge, err = strconv.ParseBool(merge)
+		if err != nil {
+			return opts, fmt.Errorf("%w: invalid merge", ErrBadRequest)
+		}
+	}
+
+	return opts, nil
+}
+
+// manifestReader reads object keys and metadata from a CSV manifest.
+type manifestReader struct {
+	opts   ImportOptions
+	csv    *csv.Reader
+	header []string
+	key    int
+}
+
+func newManifestReader(r io.Reader, opts ImportOptions) (*manifestReader, error) {
+	m := &manifestReader{
+		opts: opts,
+		csv:  csv.NewReader(r),
+	}
+	m.csv.FieldsPerRecord = -1
+	m.csv.ReuseRecord = true
+
+	m.header = opts.Schema
+	if len(m.header) == 0 {
+		header, err := m.csv.Read()
+		if err != nil {
+			return nil, fmt.Errorf("%w: cannot read header: %v", ErrBadRequest, err)
+		}
+		m.header = append([]string(nil), header...)
+	}
+
+	m.key = -1
+	for i, column := range m.header {
+		if column == opts.KeyColumn {
+			m.key = i
+		}
+	}
+	if m.key < 0 {
+		return nil, fmt.Errorf("%w: key column '%s' not found", ErrBadRequest, opts.KeyColumn)
+	}
+
+	return m, nil
+}
+
+// Next returns the object key and metadata of the next row. Metadata is nil
+// if the row must be skipped. It returns io.EOF at the end of the manifest.
+func (m *manifestReader) Next() (line int, key string, metadata map[string]interface{}, err error) {
+	record, err := m.csv.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			line = parseErr.Line
+		}
+		return line, "", nil, err
+	}
+	line, _ = m.csv.FieldPos(0)
+	if len(record) != len(m.header) {
+		return line, "", nil, fmt.Errorf("expected %d columns, got %d", len(m.header), len(record))
+	}
+
+	key = record[m.key]
+	if m.opts.Format == importFormatS3Inventory {
+		// S3 inventory reports contain URL encoded keys, and all versions
+		// of the objects.
+		key, err = url.QueryUnescape(key)
+		if err != nil {
+			return line, record[m.key], nil, fmt.Errorf("invalid key: %w", err)
+		}
+		if m.column(record, "IsLatest") == "false" || m.column(record, "IsDeleteMarker") == "true" {
+			return line, key, nil, nil
+		}
+	}
+
+	metadata = make(map[string]interface{})
+	for i, column := range m.header {
+		value := record[i]
+		if i == m.key || value == "" {
+			continue
+		}
+
+		if len(m.opts.Columns) > 0 {
+			name, ok := m.opts.Columns[column]
+			if !ok {
+				continue
+			}
+			metadata[name] = value
+			continue
+		}
+
+		if m.opts.Format == importFormatS3Inventory && s3InventoryFields[column] {
+			continue
+		}
+		metadata[column] = value
+	}
+
+	return line, key, metadata, nil
+}
+
+func (m *manifestReader) column(record []string, name string) string {
+	for i, column := range m.header {
+		if column == name {
+			return record[i]
+		}
+	}
+	return ""
+}
+
+// HandleImport imports metadata from a CSV manifest or an S3 inventory
+// report in the request body. Rows that cannot be imported, e.g. because the
+// object does not exist, are reported in the response.
+func (s *Server) HandleImport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request BaseRequest
+
+	err := s.validateRequest(ctx, r, &request, nil)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	opts, err := parseImportOptions(r.URL.Query())
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	manifest, err := newManifestReader(r.Body, opts)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	// Vocabularies are loaded once for all objects
+	vocabularies, err := s.Repo.GetVocabularies(ctx, request.Location.ProjectID)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	var response ImportResponse
+	for {
+		line, key, metadata, err := manifest.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err == nil && metadata == nil {
+			response.Skipped++
+			continue
+		}
+
+		if err == nil {
+			err = s.importObject(ctx, &request, vocabularies, key, metadata, opts.Merge)
+		}
+
+		if err != nil {
+			var csvErr *csv.ParseError
+			if errors.As(err, &csvErr) {
+				s.errorResponse(w, fmt.Errorf("%w: %v", ErrBadRequest, err))
+				return
+			}
+			if errors.Is(err, ErrMetadataIndexingInProgress) || errors.Is(err, ErrInternalError) || ctx.Err() != nil {
+				s.errorResponse(w, err)
+				return
+			}
+
+			response.Failed++
+			if len(response.Errors) < maxImportErrors {
+				response.Errors = append(response.Errors, ImportError{
+					Line:  line,
+					Key:   key,
+					Error: err.Error(),
+				})
+			}
+			continue
+		}
+
+		response.Imported++
+	}
+
+	s.jsonResponse(w, http.StatusOK, response)
+}
+
+// importObject sets the metadata of a single object, if it is valid in the
+// vocabularies of the project.
+func (s *Server) importObject(ctx context.Context, request *BaseRequest, vocabularies map[string]Vocabulary, key string, metadata map[string]interface{}, merge bool) error {
+	bucket := request.Location.BucketName
+
+	encKey, err := request.Encryptor.EncryptPath(bucket, key)
+	if err != nil {
+		return fmt.Errorf("%w: the access token does not have permission for path '%s'", ErrAuthorizationFailed, key)
+	}
+
+	loc := ObjectLocation{
+		ProjectID:  request.Location.ProjectID,
+		BucketName: bucket,
+		ObjectKey:  encKey,
+	}
+
+	err = request.Authorizer.Authorize(ctx, loc, ActionWriteMetadata)
+	if err != nil {
+		return err
+	}
+
+	if merge {
+		obj, err := s.Repo.GetMetadata(ctx, loc)
+		if err != nil {
+			return err
+		}
+		s.migrateObject(ctx, &obj, request.Encryptor)
+		for k, v := range obj.Metadata.ClearMetadata {
+			if _, ok := metadata[k]; !ok {
+				metadata[k] = v
+			}
+		}
+	}
+
+	err = validateVocabularies(vocabularies, metadata)
+	if err != nil {
+		return err
+	}
+
+	meta := ObjectMetadata{
+		ClearMetadata: metadata,
+	}
+
+	err = request.Encryptor.EncryptMetadata(bucket, key, &meta)
+	if err != nil {
+		return fmt.Errorf("%w: cannot encrypt metadata", ErrBadRequest)
+	}
+
+	return s.Repo.UpdateMetadata(ctx, loc, meta)
+}
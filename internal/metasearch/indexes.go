@@ -0,0 +1,502 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+)
+
+// Phases of an index rebuild.
+const (
+	IndexRebuildCreating   = "creating"
+	IndexRebuildValidating = "validating"
+	IndexRebuildSwapping   = "swapping"
+	IndexRebuildDone       = "done"
+	IndexRebuildFailed     = "failed"
+)
+
+const (
+	// indexRebuildSamples is the number of objects looked up in a rebuilt
+	// index before it replaces the old one.
+	indexRebuildSamples = 100
+
+	// indexRebuildPollInterval is the interval of the progress updates while
+	// an index is created.
+	indexRebuildPollInterval = 5 * time.Second
+
+	// indexRebuildLease is the name of the lease held by the server instance
+	// rebuilding an index, so that only one index is rebuilt at a time
+	// across instances.
+	indexRebuildLease = "index-rebuild"
+
+	// indexRebuildLeaseTTL is the duration of the lease, which is renewed
+	// every indexRebuildLeaseRenewal while the rebuild runs. The lease of an
+	// instance that stopped during a rebuild expires after it.
+	indexRebuildLeaseTTL     = 5 * time.Minute
+	indexRebuildLeaseRenewal = time.Minute
+)
+
+// lease is a lease held in memory by the repositories without database.
+type lease struct {
+	holder    uuid.UUID
+	expiresAt time.Time
+}
+
+// metadataIndex describes an index of the clear metadata.
+type metadataIndex struct {
+	// columns is the column definition of the index.
+	columns string
+	// expression is the indexed expression, as used in queries.
+	expression string
+}
+
+// metadataIndexes are the indexes that can be rebuilt with the admin API, by
+// their default names.
+var metadataIndexes = map[string]metadataIndex{
+	defaultMetadataIndex: {
+		columns:    "clear_metadata",
+		expression: metadata(false),
+	},
+	defaultMetadataLowerIndex: {
+		columns:    "(" + metadata(true) + ")",
+		expression: metadata(true),
+	},
+}
+
+// IndexRebuildProgressFunc is called by RebuildIndex when the rebuild enters
+// a new phase or makes progress. Fraction is the completed fraction of the
+// phase, between 0 and 1.
+type IndexRebuildProgressFunc func(phase string, fraction float64)
+
+func (r *MetabaseSearchRepository) RebuildIndex(ctx context.Context, name string, progress IndexRebuildProgressFunc) error {
+	index, ok := metadataIndexes[name]
+	if !ok {
+		return fmt.Errorf("%w: unknown index '%s'", ErrNotFound, name)
+	}
+	name = r.tables.index(name)
+	rebuilt := name + "_rebuild"
+	old := name + "_old"
+
+	// Finish the swap of a rebuild interrupted by a crash. The rebuilt
+	// index was validated before the swap started.
+	resumed, err := r.resumeIndexSwap(ctx, name, rebuilt, old, progress)
+	if err != nil || resumed {
+		return err
+	}
+
+	// Create the new index next to the old one, which is still used by
+	// searches in the meantime.
+	progress(IndexRebuildCreating, 0)
+	_, err = r.db.ExecContext(ctx, "DROP INDEX IF EXISTS "+r.dialect.index(r.tables.objects(), rebuilt))
+	if err != nil {
+		return fmt.Errorf("%w: unable to drop leftover index: %v", ErrInternalError, err)
+	}
+
+	done := make(chan struct{})
+	go r.pollIndexProgress(ctx, rebuilt, done, progress)
+	_, err = r.db.ExecContext(ctx, r.dialect.createIndex(r.tables.objects(), rebuilt, index.columns))
+	close(done)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create index: %v", ErrInternalError, err)
+	}
+
+	progress(IndexRebuildValidating, 0)
+	err = r.validateIndex(ctx, rebuilt, index.expression, progress)
+	if err != nil {
+		_, dropErr := r.db.ExecContext(ctx, "DROP INDEX IF EXISTS "+r.dialect.index(r.tables.objects(), rebuilt))
+		if dropErr != nil {
+			r.log.Warn("unable to drop invalid index", zap.String("Index", rebuilt), zap.Error(dropErr))
+		}
+		return err
+	}
+
+	// The old index is renamed out of the way before the new one takes its
+	// name, and only dropped once the new one is in place, so that a crash
+	// never loses both. Searches fall back to a sequential scan between the
+	// renames.
+	progress(IndexRebuildSwapping, 0)
+	if err = r.renameIndex(ctx, name, old); err != nil {
+		return err
+	}
+	return r.finishIndexSwap(ctx, name, rebuilt, old, progress)
+}
+
+// resumeIndexSwap finishes the swap of the indexes of a rebuild interrupted
+// by a crash, and returns whether there was one to finish.
+func (r *MetabaseSearchRepository) resumeIndexSwap(ctx context.Context, name, rebuilt, old string, progress IndexRebuildProgressFunc) (resumed bool, err error) {
+	oldExists, err := r.indexExists(ctx, old)
+	if err != nil || !oldExists {
+		return false, err
+	}
+	nameExists, err := r.indexExists(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	rebuiltExists, err := r.indexExists(ctx, rebuilt)
+	if err != nil {
+		return false, err
+	}
+
+	r.log.Info("resuming interrupted index swap", zap.String("Index", name))
+	progress(IndexRebuildSwapping, 0)
+	switch {
+	case nameExists:
+		// The new index is in place, only the old one is left to drop.
+		_, err = r.db.ExecContext(ctx, "DROP INDEX IF EXISTS "+r.dialect.index(r.tables.objects(), old))
+		if err != nil {
+			return true, fmt.Errorf("%w: unable to drop old index: %v", ErrInternalError, err)
+		}
+		progress(IndexRebuildDone, 1)
+		return true, nil
+	case rebuiltExists:
+		return true, r.finishIndexSwap(ctx, name, rebuilt, old, progress)
+	default:
+		// The new index is lost, restore the old one and rebuild.
+		return false, r.renameIndex(ctx, old, name)
+	}
+}
+
+// finishIndexSwap gives the name of the index to the rebuilt index, once the
+// old index was renamed, and drops the old index.
+func (r *MetabaseSearchRepository) finishIndexSwap(ctx context.Context, name, rebuilt, old string, progress IndexRebuildProgressFunc) error {
+	if err := r.renameIndex(ctx, rebuilt, name); err != nil {
+		return err
+	}
+	progress(IndexRebuildSwapping, 0.5)
+
+	_, err := r.db.ExecContext(ctx, "DROP INDEX IF EXISTS "+r.dialect.index(r.tables.objects(), old))
+	if err != nil {
+		return fmt.Errorf("%w: unable to drop old index: %v", ErrInternalError, err)
+	}
+
+	progress(IndexRebuildDone, 1)
+	return nil
+}
+
+// renameIndex renames an index of the objects table.
+func (r *MetabaseSearchRepository) renameIndex(ctx context.Context, from, to string) error {
+	_, err := r.db.ExecContext(ctx, fmt.Sprintf("ALTER INDEX %s RENAME TO %s", r.dialect.index(r.tables.objects(), from), to))
+	if err != nil {
+		return fmt.Errorf("%w: unable to rename index %s to %s: %v", ErrInternalError, from, to, err)
+	}
+	return nil
+}
+
+// indexExists returns whether the objects table has an index.
+func (r *MetabaseSearchRepository) indexExists(ctx context.Context, index string) (exists bool, err error) {
+	table := r.tables.objects()
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		table = table[i+1:]
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM pg_indexes WHERE tablename = $1 AND indexname = $2
+		)
+		`,
+		table, index,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	return exists, nil
+}
+
+func (r *MetabaseSearchRepository) AcquireLease(ctx context.Context, name string, holder uuid.UUID, now time.Time, expiresAt time.Time) error {
+	var acquired bool
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO metasearch_leases (name, holder, expires_at)
+		VALUES ($1, $2, $4)
+		ON CONFLICT (name) DO UPDATE
+		SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE
+			metasearch_leases.holder = excluded.holder OR
+			metasearch_leases.expires_at <= $3
+		RETURNING true
+		`,
+		name, holder, now, expiresAt,
+	).Scan(&acquired)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: lease '%s' is held by another server", ErrConflict, name)
+	} else if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	return nil
+}
+
+func (r *MetabaseSearchRepository) ReleaseLease(ctx context.Context, name string, holder uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM metasearch_leases WHERE name = $1 AND holder = $2
+		`,
+		name, holder,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	return nil
+}
+
+// pollIndexProgress reports the progress of the creation of an index, until
+// done is closed.
+func (r *MetabaseSearchRepository) pollIndexProgress(ctx context.Context, index string, done <-chan struct{}, progress IndexRebuildProgressFunc) {
+	ticker := time.NewTicker(indexRebuildPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		var fraction *float64
+		err := r.db.QueryRowContext(ctx, r.dialect.indexProgressQuery(), index).Scan(&fraction)
+		if err != nil {
+			r.log.Debug("unable to get index creation progress", zap.String("Index", index), zap.Error(err))
+			continue
+		}
+		if fraction != nil {
+			progress(IndexRebuildCreating, *fraction)
+		}
+	}
+}
+
+// validateIndex checks that a sample of objects with clear metadata are found
+// with the index.
+func (r *MetabaseSearchRepository) validateIndex(ctx context.Context, index string, expression string, progress IndexRebuildProgressFunc) error {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT project_id, bucket_name, object_key, version, (`+expression+`)::TEXT
+		FROM `+r.tables.objects()+`
+		WHERE clear_metadata IS NOT NULL
+		LIMIT $1
+		`,
+		indexRebuildSamples,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	type sample struct {
+		loc   ObjectLocation
+		value string
+	}
+	var samples []sample
+	for rows.Next() {
+		var s sample
+		if err := rows.Scan(&s.loc.ProjectID, &s.loc.BucketName, &s.loc.ObjectKey, &s.loc.Version, &s.value); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		samples = append(samples, s)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	for i, s := range samples {
+		var found bool
+		err := r.db.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1
+				FROM `+r.dialect.table(r.tables.objects(), index)+`
+				WHERE
+					`+expression+` @> $1::JSONB AND
+					(project_id, bucket_name, object_key, version) = ($2, $3, $4, $5)
+			)
+			`,
+			s.value, s.loc.ProjectID, []byte(s.loc.BucketName), []byte(s.loc.ObjectKey), s.loc.Version,
+		).Scan(&found)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		if !found {
+			return fmt.Errorf("%w: index validation failed: object '%s' is missing from the index", ErrInternalError, s.loc.ObjectKey)
+		}
+		progress(IndexRebuildValidating, float64(i+1)/float64(len(samples)))
+	}
+
+	return nil
+}
+
+// IndexRebuildStatus describes the progress of an index rebuild.
+type IndexRebuildStatus struct {
+	Index      string     `json:"index"`
+	Phase      string     `json:"phase"`
+	Progress   float64    `json:"progress"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// IndexRebuilder rebuilds metadata indexes in the background, and keeps the
+// status of the last rebuild of each index started by this server instance.
+// Only one index is rebuilt at a time across server instances: the rebuild
+// holds a lease in the database while it runs.
+type IndexRebuilder struct {
+	log  *zap.Logger
+	repo MetaSearchRepo
+
+	mutex    sync.Mutex
+	statuses map[string]*IndexRebuildStatus
+}
+
+// NewIndexRebuilder creates a new IndexRebuilder.
+func NewIndexRebuilder(log *zap.Logger, repo MetaSearchRepo) *IndexRebuilder {
+	return &IndexRebuilder{
+		log:      log,
+		repo:     repo,
+		statuses: make(map[string]*IndexRebuildStatus),
+	}
+}
+
+// Start starts the rebuild of an index in the background.
+func (b *IndexRebuilder) Start(ctx context.Context, name string) (IndexRebuildStatus, error) {
+	if _, ok := metadataIndexes[name]; !ok {
+		return IndexRebuildStatus{}, fmt.Errorf("%w: unknown index '%s'", ErrNotFound, name)
+	}
+
+	// Every rebuild has its own holder, so that a server instance does not
+	// renew the lease of its running rebuild.
+	holder, err := uuid.New()
+	if err != nil {
+		return IndexRebuildStatus{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	now := time.Now()
+	err = b.repo.AcquireLease(ctx, indexRebuildLease, holder, now, now.Add(indexRebuildLeaseTTL))
+	if errors.Is(err, ErrConflict) {
+		return IndexRebuildStatus{}, fmt.Errorf("%w: an index rebuild is already running", ErrConflict)
+	} else if err != nil {
+		return IndexRebuildStatus{}, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	status := &IndexRebuildStatus{
+		Index:     name,
+		Phase:     IndexRebuildCreating,
+		StartedAt: now,
+	}
+	b.statuses[name] = status
+
+	b.log.Info("rebuilding index", zap.String("Index", name))
+	go b.rebuild(status, holder)
+
+	return *status, nil
+}
+
+func (b *IndexRebuilder) rebuild(status *IndexRebuildStatus, holder uuid.UUID) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		b.renewLease(ctx, cancel, status.Index, holder)
+	}()
+
+	err := b.repo.RebuildIndex(ctx, status.Index, func(phase string, fraction float64) {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		status.Phase = phase
+		status.Progress = fraction
+	})
+
+	cancel()
+	<-renewed
+	if releaseErr := b.repo.ReleaseLease(context.Background(), indexRebuildLease, holder); releaseErr != nil {
+		b.log.Warn("unable to release index rebuild lease", zap.String("Index", status.Index), zap.Error(releaseErr))
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	status.FinishedAt = &now
+	if err != nil {
+		status.Phase = IndexRebuildFailed
+		status.Error = err.Error()
+		b.log.Error("index rebuild failed", zap.String("Index", status.Index), zap.Error(err))
+	} else {
+		b.log.Info("index rebuilt", zap.String("Index", status.Index), zap.Duration("Duration", now.Sub(status.StartedAt)))
+	}
+}
+
+// renewLease renews the lease of a rebuild until ctx is done. The rebuild is
+// cancelled if another server instance took over the lease, e.g. after the
+// renewals failed for longer than the lease.
+func (b *IndexRebuilder) renewLease(ctx context.Context, cancel context.CancelFunc, index string, holder uuid.UUID) {
+	ticker := time.NewTicker(indexRebuildLeaseRenewal)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		err := b.repo.AcquireLease(ctx, indexRebuildLease, holder, now, now.Add(indexRebuildLeaseTTL))
+		switch {
+		case errors.Is(err, ErrConflict):
+			b.log.Error("index rebuild lease lost", zap.String("Index", index))
+			cancel()
+			return
+		case err != nil && ctx.Err() == nil:
+			b.log.Warn("unable to renew index rebuild lease", zap.String("Index", index), zap.Error(err))
+		}
+	}
+}
+
+// Statuses returns the status of the last rebuild of each index.
+func (b *IndexRebuilder) Statuses() []IndexRebuildStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	result := make([]IndexRebuildStatus, 0, len(b.statuses))
+	for _, status := range b.statuses {
+		result = append(result, *status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Index < result[j].Index
+	})
+	return result
+}
+
+// HandleAdminRebuildIndex starts the rebuild of a metadata index.
+func (s *Server) HandleAdminRebuildIndex(w http.ResponseWriter, r *http.Request) {
+	status, err := s.Indexes.Start(r.Context(), mux.Vars(r)["index"])
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	s.jsonResponse(w, http.StatusAccepted, status)
+}
+
+// HandleAdminIndexes reports the progress of index rebuilds.
+func (s *Server) HandleAdminIndexes(w http.ResponseWriter, r *http.Request) {
+	s.jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"rebuilds": s.Indexes.Statuses(),
+	})
+}
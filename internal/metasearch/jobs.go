@@ -0,0 +1,776 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+)
+
+// States of search jobs.
+const (
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+)
+
+// jobLease is the duration a server instance holds a running job without
+// saving progress. It is longer than the latency budget of a search, so that
+// a slow page does not lose the lease. Jobs whose lease expired, e.g. after a
+// restart, are resumed when their status is polled.
+const jobLease = maxSearchTimeout
+
+// maxJobResults is the maximum number of results of a search job.
+const maxJobResults = 1000000
+
+// jobResultChunks is the number of result chunks read from the database at
+// once when downloading the results of a job.
+const jobResultChunks = 10
+
+const jobPurgeInterval = 1 * time.Hour
+
+// Job is an asynchronous search whose results are stored in the database.
+type Job struct {
+	ID         uuid.UUID
+	ProjectID  uuid.UUID
+	BucketName string
+
+	// Owner is the fingerprint of the access grant that submitted the job.
+	// Only the owner can read the job, because the results are decrypted
+	// with its encryption key.
+	Owner string
+
+	// Request is the search request, as submitted in JSON.
+	Request []byte
+
+	State string
+	// PageToken resumes the search after the last saved chunk of results.
+	PageToken string
+	// Chunks is the number of saved chunks of results, and Results the
+	// number of results in them.
+	Chunks  int64
+	Results int64
+	Error   string
+
+	// Attempt is incremented each time the job is claimed, so that only the
+	// latest runner can save progress.
+	Attempt        int64
+	LeaseExpiresAt *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// JobResponse is the status of a search job.
+type JobResponse struct {
+	ID        string    `json:"id"`
+	State     string    `json:"state"`
+	Results   int64     `json:"results"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func jobResponse(job Job) JobResponse {
+	return JobResponse{
+		ID:        job.ID.String(),
+		State:     job.State,
+		Results:   job.Results,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+		ExpiresAt: job.ExpiresAt,
+	}
+}
+
+// jobRunner tracks the jobs running on this server instance.
+type jobRunner struct {
+	mu      sync.Mutex
+	running map[uuid.UUID]context.CancelFunc
+}
+
+func (r *MetabaseSearchRepository) CreateJob(ctx context.Context, job Job) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO metasearch_jobs (
+			id, project_id, bucket_name, owner, request, state,
+			attempt, lease_expires_at,
+			created_at, updated_at, expires_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		`,
+		job.ID, job.ProjectID, []byte(job.BucketName), job.Owner, string(job.Request), job.State,
+		job.Attempt, job.LeaseExpiresAt,
+		job.CreatedAt, job.UpdatedAt, job.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create job: %v", ErrInternalError, err)
+	}
+	return nil
+}
+
+func (r *MetabaseSearchRepository) GetJob(ctx context.Context, id uuid.UUID) (Job, error) {
+	job := Job{ID: id}
+	var bucket []byte
+	var request string
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT
+			project_id, bucket_name, owner, request, state,
+			page_token, chunks, results, error,
+			attempt, lease_expires_at,
+			created_at, updated_at, expires_at
+		FROM metasearch_jobs
+		WHERE id = $1
+		`,
+		id,
+	).Scan(
+		&job.ProjectID, &bucket, &job.Owner, &request, &job.State,
+		&job.PageToken, &job.Chunks, &job.Results, &job.Error,
+		&job.Attempt, &job.LeaseExpiresAt,
+		&job.CreatedAt, &job.UpdatedAt, &job.ExpiresAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Job{}, fmt.Errorf("%w: job not found", ErrNotFound)
+	} else if err != nil {
+		return Job{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	job.BucketName = string(bucket)
+	job.Request = []byte(request)
+	return job, nil
+}
+
+func (r *MetabaseSearchRepository) ClaimJob(ctx context.Context, id uuid.UUID, now time.Time, leaseExpiresAt time.Time) (attempt int64, err error) {
+	err = r.db.QueryRowContext(ctx, `
+		UPDATE metasearch_jobs
+		SET attempt = attempt + 1, lease_expires_at = $3, updated_at = $2
+		WHERE
+			id = $1 AND
+			state = 'running' AND
+			(lease_expires_at IS NULL OR lease_expires_at <= $2)
+		RETURNING attempt
+		`,
+		id, now, leaseExpiresAt,
+	).Scan(&attempt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: job is not running or held by another server", ErrConflict)
+	} else if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	return attempt, nil
+}
+
+func (r *MetabaseSearchRepository) SaveJobProgress(ctx context.Context, job Job, results []byte) error {
+	// The chunk is only inserted if the job is still running with the same
+	// attempt, in the same statement as the progress.
+	var updated int64
+	err := r.db.QueryRowContext(ctx, `
+		WITH updated AS (
+			UPDATE metasearch_jobs
+			SET
+				state = $3, page_token = $4, chunks = $5, results = $6, error = $7,
+				lease_expires_at = $8, updated_at = $9
+			WHERE
+				id = $1 AND
+				attempt = $2 AND
+				state = 'running'
+			RETURNING id
+		), inserted AS (
+			INSERT INTO metasearch_job_results (job_id, chunk, results)
+			SELECT id, $5 - 1, $10 FROM updated
+			WHERE $10::BYTEA IS NOT NULL
+			RETURNING 1
+		)
+		SELECT count(*) FROM updated
+		`,
+		job.ID, job.Attempt,
+		job.State, job.PageToken, job.Chunks, job.Results, job.Error,
+		job.LeaseExpiresAt, job.UpdatedAt,
+		results,
+	).Scan(&updated)
+	if err != nil {
+		return fmt.Errorf("%w: unable to save job progress: %v", ErrInternalError, err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("%w: job was deleted or claimed by another server", ErrNotFound)
+	}
+	return nil
+}
+
+func (r *MetabaseSearchRepository) GetJobResults(ctx context.Context, id uuid.UUID, fromChunk int64, limit int) ([][]byte, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT results
+		FROM metasearch_job_results
+		WHERE job_id = $1 AND chunk >= $2
+		ORDER BY chunk
+		LIMIT $3
+		`,
+		id, fromChunk, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	var chunks [][]byte
+	for rows.Next() {
+		var chunk []byte
+		if err := rows.Scan(&chunk); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return chunks, nil
+}
+
+func (r *MetabaseSearchRepository) DeleteJob(ctx context.Context, id uuid.UUID) error {
+	// Delete the job first, so that its runner cannot save more results
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM metasearch_jobs
+		WHERE id = $1
+		`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to delete job: %v", ErrInternalError, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: unable to get rows affected: %v", ErrInternalError, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: job not found", ErrNotFound)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		DELETE FROM metasearch_job_results
+		WHERE job_id = $1
+		`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to delete job results: %v", ErrInternalError, err)
+	}
+	return nil
+}
+
+func (r *MetabaseSearchRepository) DeleteExpiredJobs(ctx context.Context, now time.Time) (int64, error) {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM metasearch_job_results
+		WHERE job_id IN (
+			SELECT id FROM metasearch_jobs
+			WHERE expires_at <= $1
+		)
+		`,
+		now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%w: unable to delete job results: %v", ErrInternalError, err)
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM metasearch_jobs
+		WHERE expires_at <= $1
+		`,
+		now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%w: unable to get rows affected: %v", ErrInternalError, err)
+	}
+
+	return affected, nil
+}
+
+// HandleSubmitJob submits an asynchronous search job, which stores all
+// results of the search in the database. The job runs in the background on
+// the server instance that accepted it.
+func (s *Server) HandleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request SearchRequest
+
+	err := s.validateRequest(ctx, r, &request.BaseRequest, &request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	if s.Config.JobRetention <= 0 {
+		s.errorResponse(w, fmt.Errorf("%w: search jobs are disabled", ErrNotFound))
+		return
+	}
+
+	// Jobs belong to the access grant that submitted them, so anonymous
+	// requests cannot own jobs
+	if grantCredentials(r) == "" {
+		s.errorResponse(w, fmt.Errorf("%w: search jobs require an access grant", ErrBadRequest))
+		return
+	}
+
+	err = validateJobRequest(&request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	// Keep the request as submitted, before validation expands it, so that
+	// it can be validated again when the job is resumed.
+	submitted, err := json.Marshal(&request)
+	if err != nil {
+		s.errorResponse(w, fmt.Errorf("%w: %v", ErrInternalError, err))
+		return
+	}
+
+	err = s.validateSearchRequest(&request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	id, err := uuid.New()
+	if err != nil {
+		s.errorResponse(w, fmt.Errorf("%w: %v", ErrInternalError, err))
+		return
+	}
+
+	now := time.Now()
+	lease := now.Add(jobLease)
+	job := Job{
+		ID:             id,
+		ProjectID:      request.Location.ProjectID,
+		BucketName:     request.Location.BucketName,
+		Owner:          grantFingerprint(r),
+		Request:        submitted,
+		State:          jobRunning,
+		LeaseExpiresAt: &lease,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ExpiresAt:      now.Add(s.Config.JobRetention),
+	}
+
+	jobCtx, ok := s.reserveJob(job.ID)
+	if !ok {
+		s.errorResponse(w, fmt.Errorf("%w: too many running search jobs", ErrTooManyRequests))
+		return
+	}
+
+	err = s.Repo.CreateJob(ctx, job)
+	if err != nil {
+		s.releaseJob(job.ID)
+		s.errorResponse(w, err)
+		return
+	}
+
+	go s.runJob(jobCtx, job, &request)
+
+	w.Header().Set("Location", apiPath(r, "/metasearch/"+url.PathEscape(job.BucketName)+"/jobs/"+job.ID.String()))
+	s.jsonResponse(w, http.StatusAccepted, jobResponse(job))
+}
+
+// validateJobRequest validates the options of a search request submitted as
+// a job.
+func validateJobRequest(request *SearchRequest) error {
+	if request.PageToken != "" || request.CountOnly || request.Delimiter != "" || request.SimilarTo != nil {
+		return fmt.Errorf("%w: search jobs cannot use pageToken, countOnly, delimiter or similarTo", ErrBadRequest)
+	}
+	if request.Timeout != "" || request.PartialResults {
+		return fmt.Errorf("%w: search jobs cannot use timeout or partialResults", ErrBadRequest)
+	}
+	return nil
+}
+
+// HandleGetJob returns the status of a search job.
+func (s *Server) HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, _, err := s.getJob(r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	s.jsonResponse(w, http.StatusOK, jobResponse(job))
+}
+
+// HandleDeleteJob cancels a search job and deletes its results.
+func (s *Server) HandleDeleteJob(w http.ResponseWriter, r *http.Request) {
+	job, _, err := s.getJob(r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	s.releaseJob(job.ID)
+	err = s.Repo.DeleteJob(r.Context(), job.ID)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleJobResults downloads the results of a finished search job, as
+// newline delimited JSON, or as CSV or Parquet with the columns of the
+// columns query parameter, depending on the Accept header.
+func (s *Server) HandleJobResults(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	job, _, err := s.getJob(r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	switch job.State {
+	case jobRunning:
+		s.errorResponse(w, fmt.Errorf("%w: the job is still running", ErrConflict))
+		return
+	case jobFailed:
+		s.errorResponse(w, fmt.Errorf("%w: the job failed: %s", ErrConflict, job.Error))
+		return
+	}
+
+	format := wantsExport(r)
+	var columns []string
+	if format != nil {
+		request := SearchRequest{}
+		if c := r.URL.Query().Get("columns"); c != "" {
+			request.Columns = strings.Split(c, ",")
+		}
+		if err = validateExport(&request); err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+		columns = request.Columns
+	} else {
+		format = &ndjsonExportFormat
+	}
+
+	s.extendWriteDeadline(w, r)
+	w.Header().Set("Content-Type", format.contentType)
+	setExportFilename(w, job.ID.String(), format)
+	w.Header().Set("Trailer", exportErrorTrailer)
+	w.WriteHeader(http.StatusOK)
+
+	fail := func(err error) {
+		s.Logger.Warn("error during job results download", zap.Stringer("Job", job.ID), zap.Error(err))
+
+		// The status is already sent, so report the error in the trailer.
+		var e *ErrorResponse
+		if !errors.As(err, &e) {
+			e = ErrInternalError
+		}
+		w.Header().Set(exportErrorTrailer, e.Message)
+	}
+
+	ew, err := format.newWriter(w, columns)
+	if err != nil {
+		fail(err)
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	for chunk := int64(0); chunk < job.Chunks; {
+		chunks, err := s.Repo.GetJobResults(ctx, job.ID, chunk, jobResultChunks)
+		if err != nil {
+			if ctx.Err() == nil {
+				fail(err)
+			}
+			return
+		}
+		if len(chunks) == 0 {
+			fail(fmt.Errorf("%w: job results are missing", ErrInternalError))
+			return
+		}
+
+		for _, data := range chunks {
+			results, err := parseJobResults(data)
+			if err != nil {
+				fail(err)
+				return
+			}
+			if err := ew.write(results); err != nil {
+				// The client has disconnected.
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		chunk += int64(len(chunks))
+	}
+
+	if err := ew.close(); err != nil {
+		fail(err)
+	}
+}
+
+// getJob returns the job of a job request, if it belongs to the project,
+// bucket and access grant of the request. A running job whose lease has
+// expired is resumed with the encryptor of the request.
+func (s *Server) getJob(r *http.Request) (job Job, request BaseRequest, err error) {
+	ctx := r.Context()
+
+	err = s.validateRequest(ctx, r, &request, nil)
+	if err != nil {
+		return Job{}, request, err
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		return Job{}, request, err
+	}
+
+	if s.Config.JobRetention <= 0 {
+		return Job{}, request, fmt.Errorf("%w: search jobs are disabled", ErrNotFound)
+	}
+
+	id, err := uuid.FromString(mux.Vars(r)["job"])
+	if err != nil {
+		return Job{}, request, fmt.Errorf("%w: job not found", ErrNotFound)
+	}
+
+	job, err = s.Repo.GetJob(ctx, id)
+	if err != nil {
+		return Job{}, request, err
+	}
+	if grantCredentials(r) == "" || job.ProjectID != request.Location.ProjectID || job.BucketName != request.Location.BucketName || job.Owner != grantFingerprint(r) {
+		return Job{}, request, fmt.Errorf("%w: job not found", ErrNotFound)
+	}
+
+	if job.State == jobRunning && (job.LeaseExpiresAt == nil || time.Now().After(*job.LeaseExpiresAt)) {
+		s.resumeJob(ctx, &job, request)
+	}
+
+	return job, request, nil
+}
+
+// resumeJob claims a running job whose lease has expired and runs it from
+// its last saved chunk of results.
+func (s *Server) resumeJob(ctx context.Context, job *Job, base BaseRequest) {
+	jobCtx, ok := s.reserveJob(job.ID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	lease := now.Add(jobLease)
+	attempt, err := s.Repo.ClaimJob(ctx, job.ID, now, lease)
+	if err != nil {
+		s.releaseJob(job.ID)
+		if !errors.Is(err, ErrConflict) {
+			s.Logger.Error("cannot claim search job", zap.Stringer("Job", job.ID), zap.Error(err))
+		}
+		return
+	}
+	job.Attempt = attempt
+	job.LeaseExpiresAt = &lease
+	job.UpdatedAt = now
+	s.Logger.Info("resuming search job", zap.Stringer("Job", job.ID), zap.Int64("Results", job.Results))
+
+	request := SearchRequest{BaseRequest: base}
+	if err := newJSONDecoder(bytes.NewReader(job.Request)).Decode(&request); err != nil {
+		err = fmt.Errorf("%w: %v", ErrInternalError, err)
+		s.failJob(jobCtx, job, err)
+		s.releaseJob(job.ID)
+		return
+	}
+	request.PageToken = job.PageToken
+	if err := s.validateSearchRequest(&request); err != nil {
+		s.failJob(jobCtx, job, err)
+		s.releaseJob(job.ID)
+		return
+	}
+
+	go s.runJob(jobCtx, *job, &request)
+}
+
+// reserveJob registers a job as running on this server instance, unless it
+// already runs or the maximum number of running jobs is reached. The returned
+// context is cancelled when the job is released.
+func (s *Server) reserveJob(id uuid.UUID) (context.Context, bool) {
+	s.jobs.mu.Lock()
+	defer s.jobs.mu.Unlock()
+
+	if s.jobs.running == nil {
+		s.jobs.running = make(map[uuid.UUID]context.CancelFunc)
+	}
+	if _, ok := s.jobs.running[id]; ok {
+		return nil, false
+	}
+	if s.Config.MaxJobs > 0 && len(s.jobs.running) >= s.Config.MaxJobs {
+		return nil, false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.jobs.running[id] = cancel
+	mon.Counter("metasearch_jobs_running").Inc(1)
+	return ctx, true
+}
+
+// releaseJob cancels a job running on this server instance.
+func (s *Server) releaseJob(id uuid.UUID) {
+	s.jobs.mu.Lock()
+	defer s.jobs.mu.Unlock()
+
+	if cancel, ok := s.jobs.running[id]; ok {
+		cancel()
+		delete(s.jobs.running, id)
+		mon.Counter("metasearch_jobs_running").Dec(1)
+	}
+}
+
+// runJob searches all pages of a job and saves each page as a chunk of
+// results, until the search is complete, fails or the job is released.
+func (s *Server) runJob(ctx context.Context, job Job, request *SearchRequest) {
+	defer s.releaseJob(job.ID)
+
+	request.BatchSize = maxBatchSize
+	for {
+		result, err := s.searchMetadata(ctx, request)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.failJob(ctx, &job, err)
+			}
+			return
+		}
+
+		if job.Results+int64(len(result.Results)) > maxJobResults {
+			s.failJob(ctx, &job, fmt.Errorf("%w: the search has more than %d results", ErrUnprocessableEntity, maxJobResults))
+			return
+		}
+
+		var chunk []byte
+		if len(result.Results) > 0 {
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			for _, res := range result.Results {
+				if err := enc.Encode(res); err != nil {
+					s.failJob(ctx, &job, fmt.Errorf("%w: %v", ErrInternalError, err))
+					return
+				}
+			}
+			chunk = buf.Bytes()
+			job.Chunks++
+			job.Results += int64(len(result.Results))
+		}
+
+		job.PageToken = result.PageToken
+		if job.PageToken == "" {
+			job.State = jobSucceeded
+		}
+		if err := s.saveJob(ctx, &job, chunk); err != nil {
+			return
+		}
+		if job.State != jobRunning {
+			s.Logger.Debug("search job succeeded", zap.Stringer("Job", job.ID), zap.Int64("Results", job.Results))
+			return
+		}
+
+		request.startAfter, request.asOf, request.sort, err = parseSortedPageToken(result.PageToken)
+		if err != nil {
+			s.failJob(ctx, &job, err)
+			return
+		}
+	}
+}
+
+// saveJob saves the progress of a job with a chunk of results, extending its
+// lease while it is running.
+func (s *Server) saveJob(ctx context.Context, job *Job, chunk []byte) error {
+	now := time.Now()
+	job.UpdatedAt = now
+	job.LeaseExpiresAt = nil
+	if job.State == jobRunning {
+		lease := now.Add(jobLease)
+		job.LeaseExpiresAt = &lease
+	}
+
+	err := s.Repo.SaveJobProgress(ctx, *job, chunk)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		s.Logger.Error("cannot save search job progress", zap.Stringer("Job", job.ID), zap.Error(err))
+	}
+	return err
+}
+
+// failJob marks a job as failed. Client errors are reported in the job
+// status, and internal errors only in the logs.
+func (s *Server) failJob(ctx context.Context, job *Job, err error) {
+	s.Logger.Warn("search job failed", zap.Stringer("Job", job.ID), zap.Error(err))
+
+	var e *ErrorResponse
+	if !errors.As(err, &e) || e.StatusCode >= http.StatusInternalServerError {
+		job.Error = ErrInternalError.Message
+	} else {
+		job.Error = err.Error()
+	}
+	job.State = jobFailed
+	_ = s.saveJob(ctx, job, nil)
+}
+
+// parseJobResults parses a chunk of results saved as newline delimited JSON.
+func parseJobResults(data []byte) ([]SearchResult, error) {
+	var results []SearchResult
+	dec := newJSONDecoder(bytes.NewReader(data))
+	for {
+		var res SearchResult
+		err := dec.Decode(&res)
+		if errors.Is(err, io.EOF) {
+			return results, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: invalid job results: %v", ErrInternalError, err)
+		}
+		results = append(results, res)
+	}
+}
+
+// purgeJobs periodically deletes search jobs and their results after the
+// retention period.
+func (s *Server) purgeJobs() {
+	ticker := time.NewTicker(jobPurgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if s.ReadOnly() {
+			continue
+		}
+		ctx := context.Background()
+		purged, err := s.Repo.DeleteExpiredJobs(ctx, time.Now())
+		if err != nil {
+			s.Logger.Error("cannot purge search jobs", zap.Error(err))
+			continue
+		}
+		s.Logger.Debug("purged search jobs", zap.Int64("Purged", purged))
+	}
+}
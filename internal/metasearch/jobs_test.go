@@ -0,0 +1,192 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+
+	"storj.io/common/uuid"
+)
+
+func jobsTestServer(t *testing.T) *Server {
+	server := testServer()
+	server.Config.JobRetention = time.Hour
+	testRepo(server).paginate = true
+
+	for key, metadata := range map[string]string{
+		"foo.txt": `{"type": "photo", "n": 1}`,
+		"bar.txt": `{"type": "photo", "n": 2}`,
+		"baz.txt": `{"type": "video", "n": 3}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+	return server
+}
+
+// waitForJob polls the status of a job until it is no longer running.
+func waitForJob(t *testing.T, server *Server, id string) JobResponse {
+	var status JobResponse
+	require.Eventually(t, func() bool {
+		rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket/jobs/"+id, "")
+		return rr.Code == http.StatusOK &&
+			json.Unmarshal(rr.Body.Bytes(), &status) == nil &&
+			status.State != jobRunning
+	}, 10*time.Second, 10*time.Millisecond)
+	return status
+}
+
+func submitJob(t *testing.T, server *Server, body string) JobResponse {
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket/jobs", body)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+
+	var status JobResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.Equal(t, rr.Header().Get("Location"), "/v1/metasearch/testbucket/jobs/"+status.ID)
+	return status
+}
+
+func TestSearchJobs(t *testing.T) {
+	server := jobsTestServer(t)
+
+	status := submitJob(t, server, `{"filter": "type == 'photo'"}`)
+	status = waitForJob(t, server, status.ID)
+	assert.Equal(t, status.State, jobSucceeded)
+	assert.Equal(t, status.Results, int64(2))
+
+	// NDJSON results
+	rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket/jobs/"+status.ID+"/results", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Type"), ndjsonContentType)
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	require.JSONEq(t, `{"path": "sj://testbucket/bar.txt", "metadata": {"type": "photo", "n": 2}}`, lines[0])
+	require.JSONEq(t, `{"path": "sj://testbucket/foo.txt", "metadata": {"type": "photo", "n": 1}}`, lines[1])
+
+	// CSV results
+	rr = httptest.NewRecorder()
+	r := testRequest(http.MethodGet, "/metasearch/testbucket/jobs/"+status.ID+"/results?columns=n", "")
+	r.Header.Set("Accept", csvContentType)
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Body.String(), "path,n\nsj://testbucket/bar.txt,2\nsj://testbucket/foo.txt,1\n")
+
+	// Jobs are only visible to the access grant that submitted them
+	rr = httptest.NewRecorder()
+	r = testRequest(http.MethodGet, "/metasearch/testbucket/jobs/"+status.ID, "")
+	r.Header.Set("Authorization", "Bearer othertoken")
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/otherbucket/jobs/"+status.ID, "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	// Delete
+	rr = handleRequest(server, http.MethodDelete, "/metasearch/testbucket/jobs/"+status.ID, "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/jobs/"+status.ID, "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
+
+func TestSearchJobRunning(t *testing.T) {
+	server := jobsTestServer(t)
+	testRepo(server).queryDelay = time.Hour
+
+	status := submitJob(t, server, `{"filter": "type == 'photo'"}`)
+	assert.Equal(t, status.State, jobRunning)
+
+	rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket/jobs/"+status.ID+"/results", "")
+	assert.Equal(t, rr.Code, http.StatusConflict)
+
+	// Deleting cancels the job
+	rr = handleRequest(server, http.MethodDelete, "/metasearch/testbucket/jobs/"+status.ID, "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	require.Eventually(t, func() bool {
+		server.jobs.mu.Lock()
+		defer server.jobs.mu.Unlock()
+		return len(server.jobs.running) == 0
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestSearchJobResume(t *testing.T) {
+	server := jobsTestServer(t)
+
+	// A job whose server stopped before finishing it
+	id, err := uuid.New()
+	require.NoError(t, err)
+	now := time.Now()
+	lease := now.Add(-time.Minute)
+	require.NoError(t, testRepo(server).CreateJob(context.Background(), Job{
+		ID:             id,
+		BucketName:     "testbucket",
+		Owner:          grantFingerprint(testRequest(http.MethodGet, "/", "")),
+		Request:        []byte(`{"filter": "type == 'video'"}`),
+		State:          jobRunning,
+		LeaseExpiresAt: &lease,
+		CreatedAt:      now.Add(-time.Hour),
+		UpdatedAt:      lease,
+		ExpiresAt:      now.Add(time.Hour),
+	}))
+
+	status := waitForJob(t, server, id.String())
+	assert.Equal(t, status.State, jobSucceeded)
+	assert.Equal(t, status.Results, int64(1))
+
+	rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket/jobs/"+id.String()+"/results", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	require.JSONEq(t, `{"path": "sj://testbucket/baz.txt", "metadata": {"type": "video", "n": 3}}`, rr.Body.String())
+}
+
+func TestSearchJobErrors(t *testing.T) {
+	server := jobsTestServer(t)
+
+	for _, body := range []string{
+		`{"pageToken": "abc"}`,
+		`{"countOnly": true}`,
+		`{"timeout": "1s"}`,
+		`{"delimiter": "/"}`,
+	} {
+		rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket/jobs", body)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+	}
+
+	rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket/jobs/invalid", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	// Disabled
+	server.Config.JobRetention = 0
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/jobs", `{}`)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+	server.Config.JobRetention = time.Hour
+
+	// Anonymous requests, e.g. to public buckets, cannot submit jobs
+	rr = httptest.NewRecorder()
+	r := testRequest(http.MethodPost, "/metasearch/testbucket/jobs", `{}`)
+	r.Header.Del("Authorization")
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Running jobs are limited
+	server.Config.MaxJobs = 1
+	testRepo(server).queryDelay = time.Hour
+	status := submitJob(t, server, `{}`)
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/jobs", `{}`)
+	assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+	server.releaseJob(mustParseUUID(t, status.ID))
+}
+
+func mustParseUUID(t *testing.T, s string) uuid.UUID {
+	id, err := uuid.FromString(s)
+	require.NoError(t, err)
+	return id
+}
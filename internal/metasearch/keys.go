@@ -0,0 +1,181 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxKeysSample is the number of objects whose metadata keys are listed.
+	maxKeysSample = 1000
+
+	// maxKeySamples is the maximum number of sample values per key.
+	maxKeySamples = 10
+)
+
+// MetadataKeyInfo describes a metadata key found in a bucket. Count is the
+// number of sampled objects that have the key.
+type MetadataKeyInfo struct {
+	Key     string        `json:"key"`
+	Count   int64         `json:"count"`
+	Samples []interface{} `json:"samples,omitempty"`
+}
+
+// KeysResponse contains the metadata keys of a bucket, the most frequent keys
+// first.
+type KeysResponse struct {
+	Keys []MetadataKeyInfo `json:"keys"`
+
+	// Truncated is true if there are more keys than the limit.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (r *MetabaseSearchRepository) SampleMetadata(ctx context.Context, loc ObjectLocation, limit int) ([]map[string]interface{}, error) {
+	b := &matchQueryBuilder{loc: loc, dialect: r.dialect, tables: r.tables}
+	filter, err := b.searchFilter(matchQuery{})
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT clear_metadata\nFROM " + b.table(defaultPrimaryKey) + " WHERE " + filter + "\nAND clear_metadata IS NOT NULL" + b.pageRange(loc, ObjectLocation{}) +
+		"\nLIMIT " + b.arg(limit)
+
+	rows, err := r.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	samples := make([]map[string]interface{}, 0, limit)
+	for rows.Next() {
+		var value *string
+		if err := rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		metadata, err := parseJSON(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		samples = append(samples, metadata)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return samples, nil
+}
+
+// HandleKeys lists the top-level metadata keys of the objects in a bucket,
+// optionally with sample values, so that query builders can offer
+// autocompletion. Keys are discovered in a sample of the objects. Parameters
+// are passed in the URL query string.
+func (s *Server) HandleKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request SearchRequest
+
+	err := s.validateRequest(ctx, r, &request.BaseRequest, nil)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	startsWith := q.Get("startsWith")
+	limit := defaultBatchSize
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxBatchSize {
+			s.errorResponse(w, fmt.Errorf("%w: invalid limit", ErrBadRequest))
+			return
+		}
+	}
+	var samples int
+	if v := q.Get("samples"); v != "" {
+		samples, err = strconv.Atoi(v)
+		if err != nil || samples < 0 || samples > maxKeySamples {
+			s.errorResponse(w, fmt.Errorf("%w: samples must be between 0 and %d", ErrBadRequest, maxKeySamples))
+			return
+		}
+	}
+
+	request.KeyPrefix = q.Get("prefix")
+	err = s.validateSearchRequest(&request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	metadata, err := s.Repo.SampleMetadata(ctx, request.EncryptedLocation, maxKeysSample)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	response := KeysResponse{
+		Keys: collectMetadataKeys(metadata, startsWith, samples),
+	}
+	if len(response.Keys) > limit {
+		response.Keys = response.Keys[:limit]
+		response.Truncated = true
+	}
+
+	s.jsonResponse(w, http.StatusOK, response)
+}
+
+// collectMetadataKeys counts the top-level keys starting with startsWith in
+// the metadata of several objects, and collects up to samples distinct values
+// per key. Keys are sorted by count, then by name.
+func collectMetadataKeys(metadata []map[string]interface{}, startsWith string, samples int) []MetadataKeyInfo {
+	infos := make(map[string]*MetadataKeyInfo)
+	seen := make(map[string]map[string]bool)
+	for _, m := range metadata {
+		for key, value := range m {
+			if !strings.HasPrefix(key, startsWith) {
+				continue
+			}
+
+			info, ok := infos[key]
+			if !ok {
+				info = &MetadataKeyInfo{Key: key}
+				infos[key] = info
+				seen[key] = make(map[string]bool)
+			}
+			info.Count++
+
+			if len(info.Samples) >= samples {
+				continue
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil || seen[key][string(encoded)] {
+				continue
+			}
+			seen[key][string(encoded)] = true
+			info.Samples = append(info.Samples, value)
+		}
+	}
+
+	keys := make([]MetadataKeyInfo, 0, len(infos))
+	for _, info := range infos {
+		keys = append(keys, *info)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	return keys
+}
@@ -0,0 +1,310 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// maxLintSuggestionDistance is the maximum edit distance between an unknown
+// key and the known key suggested instead.
+const maxLintSuggestionDistance = 2
+
+// LintWarning describes a part of a query that is unlikely to match the
+// metadata of a bucket.
+type LintWarning struct {
+	// Path is the dot separated path of the metadata key in the query.
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	// Suggestion is a known key with a similar name, if any.
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// LintResponse contains the warnings about a query. Sampled is the number of
+// objects whose metadata the query was checked against.
+type LintResponse struct {
+	Warnings []LintWarning `json:"warnings"`
+	Sampled  int           `json:"sampled"`
+}
+
+// metadataSchema contains the JSON types seen at each path of the metadata of
+// sampled objects. Paths are keys joined with dots.
+type metadataSchema map[string]map[string]bool
+
+// newMetadataSchema collects the paths and types of metadata.
+func newMetadataSchema(metadata []map[string]interface{}) metadataSchema {
+	schema := make(metadataSchema)
+	for _, m := range metadata {
+		schema.add("", m)
+	}
+	return schema
+}
+
+func (schema metadataSchema) add(path string, obj map[string]interface{}) {
+	for k, v := range obj {
+		fieldPath := joinLintPath(path, k)
+		if schema[fieldPath] == nil {
+			schema[fieldPath] = make(map[string]bool)
+		}
+		schema[fieldPath][jsonTypeOf(v)] = true
+
+		if child, ok := v.(map[string]interface{}); ok {
+			schema.add(fieldPath, child)
+		}
+	}
+}
+
+// suggest returns the known key at the same level as path with the closest
+// name, if it is close enough.
+func (schema metadataSchema) suggest(path string) string {
+	parent, name := "", path
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		parent, name = path[:i], path[i+1:]
+	}
+
+	best, bestDistance := "", maxLintSuggestionDistance+1
+	for known := range schema {
+		knownParent, knownName := "", known
+		if i := strings.LastIndexByte(known, '.'); i >= 0 {
+			knownParent, knownName = known[:i], known[i+1:]
+		}
+		if knownParent != parent {
+			continue
+		}
+		distance := editDistance(name, knownName)
+		if distance < bestDistance || (distance == bestDistance && known < best) {
+			best, bestDistance = known, distance
+		}
+	}
+	return best
+}
+
+// jsonTypeOf returns the JSONB type name of a decoded JSON value.
+func jsonTypeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func joinLintPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// queryLinter checks a match query against the metadata schema of a bucket.
+type queryLinter struct {
+	schema       metadataSchema
+	lowerSchema  metadataSchema
+	vocabularies map[string]Vocabulary
+	warnings     []LintWarning
+}
+
+func (l *queryLinter) warn(schema metadataSchema, path string, message string) {
+	warning := LintWarning{Path: path, Message: message}
+	if _, ok := schema[path]; !ok {
+		warning.Suggestion = schema.suggest(path)
+	}
+	l.warnings = append(l.warnings, warning)
+}
+
+// checkKey warns if a path does not exist in the schema. It returns the types
+// seen at the path.
+func (l *queryLinter) checkKey(schema metadataSchema, path string) map[string]bool {
+	types, ok := schema[path]
+	if !ok {
+		l.warn(schema, path, "key not found in the metadata of the bucket")
+	}
+	return types
+}
+
+// checkType warns if the types seen at a path do not include typ.
+func (l *queryLinter) checkType(schema metadataSchema, path string, typ string) {
+	types := l.checkKey(schema, path)
+	if types == nil || types[typ] {
+		return
+	}
+	l.warn(schema, path, fmt.Sprintf("query value is a %s, but the metadata values are %s", typ, joinTypes(types)))
+}
+
+func (l *queryLinter) lint(query matchQuery) {
+	schema := l.schema
+	if query.caseInsensitive {
+		schema = l.lowerSchema
+	}
+
+	l.lintContains(schema, "", query.contains, !query.caseInsensitive)
+	for _, condition := range query.conditions {
+		l.checkType(schema, strings.Join(condition.path, "."), condition.jsonType())
+	}
+	for _, key := range query.exists {
+		l.checkKey(schema, key)
+	}
+	for _, key := range query.missing {
+		if _, ok := schema[key]; !ok {
+			l.warn(schema, key, "key not found in the metadata of the bucket, missing matches all objects")
+		}
+	}
+	for _, alternative := range query.anyOf {
+		l.lint(alternative)
+	}
+	for _, subquery := range query.allOf {
+		l.lint(subquery)
+	}
+	if query.not != nil {
+		l.lint(*query.not)
+	}
+}
+
+// lintContains checks the containment part of a query. String values of
+// top-level keys are checked against their vocabulary if checkVocabularies is
+// true.
+func (l *queryLinter) lintContains(schema metadataSchema, path string, contains map[string]interface{}, checkVocabularies bool) {
+	keys := make([]string, 0, len(contains))
+	for k := range contains {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fieldPath := joinLintPath(path, k)
+		value := contains[k]
+		if child, ok := value.(map[string]interface{}); ok && len(child) > 0 {
+			if l.checkKey(schema, fieldPath) != nil {
+				l.lintContains(schema, fieldPath, child, false)
+			}
+			continue
+		}
+
+		l.checkType(schema, fieldPath, jsonTypeOf(value))
+
+		// Values outside of the vocabulary of a key cannot be set
+		if s, ok := value.(string); ok && checkVocabularies {
+			if vocabulary, ok := l.vocabularies[k]; ok && !vocabulary.allows(s) {
+				l.warn(schema, fieldPath, fmt.Sprintf("value '%s' is not in the vocabulary of the key", s))
+			}
+		}
+	}
+}
+
+func joinTypes(types map[string]bool) string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " or ")
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// HandleLint checks a search query against the metadata keys and types found
+// in a sample of the objects of the bucket, and the vocabularies of the
+// project. It warns about keys that do not exist and type mismatches, which
+// silently return no results. The query is not executed.
+func (s *Server) HandleLint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request SearchRequest
+
+	err := s.validateRequest(ctx, r, &request.BaseRequest, &request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = s.validateSearchRequest(&request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	query, err := parseMatch(request.Match)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	metadata, err := s.Repo.SampleMetadata(ctx, request.EncryptedLocation, maxKeysSample)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	vocabularies, err := s.Repo.GetVocabularies(ctx, request.EncryptedLocation.ProjectID)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	response := LintResponse{
+		Warnings: []LintWarning{},
+		Sampled:  len(metadata),
+	}
+	if len(metadata) > 0 {
+		lowered := make([]map[string]interface{}, 0, len(metadata))
+		for _, m := range metadata {
+			l, err := lowerMatch(m)
+			if err != nil {
+				// Keys differing only by case are checked as is
+				l = m
+			}
+			lowered = append(lowered, l.(map[string]interface{}))
+		}
+
+		linter := &queryLinter{
+			schema:       newMetadataSchema(metadata),
+			lowerSchema:  newMetadataSchema(lowered),
+			vocabularies: vocabularies,
+		}
+		linter.lint(query)
+		if request.sort != nil {
+			linter.checkKey(linter.schema, request.sort.Key)
+		}
+		response.Warnings = append(response.Warnings, linter.warnings...)
+	}
+
+	s.jsonResponse(w, http.StatusOK, response)
+}
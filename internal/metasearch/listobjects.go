@@ -0,0 +1,155 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListBucketResult is a page of search results in the shape of the S3
+// ListObjectsV2 response.
+type ListBucketResult struct {
+	XMLName xml.Name `xml:"ListBucketResult" json:"-"`
+	Xmlns   string   `xml:"xmlns,attr" json:"-"`
+
+	Name                  string
+	Prefix                string
+	Delimiter             string `xml:",omitempty" json:",omitempty"`
+	MaxKeys               int
+	KeyCount              int
+	IsTruncated           bool
+	EncodingType          string `xml:",omitempty" json:",omitempty"`
+	ContinuationToken     string `xml:",omitempty" json:",omitempty"`
+	NextContinuationToken string `xml:",omitempty" json:",omitempty"`
+
+	Contents       []ListBucketObject `json:",omitempty"`
+	CommonPrefixes []CommonPrefix     `json:",omitempty"`
+}
+
+// ListBucketObject is an object of a ListObjectsV2 response.
+type ListBucketObject struct {
+	Key          string
+	LastModified time.Time
+	Size         int64
+	StorageClass string
+}
+
+// CommonPrefix is a subdirectory of a ListObjectsV2 response.
+type CommonPrefix struct {
+	Prefix string
+}
+
+// wantsListObjectsV2 reports whether a search request asks for the response
+// shape of S3 ListObjectsV2, with the list-type=2 query parameter of S3.
+func wantsListObjectsV2(r *http.Request) bool {
+	return r.URL.Query().Get("list-type") == "2"
+}
+
+// parseListObjectsV2 applies the S3 ListObjectsV2 query parameters to a
+// search request, and returns the requested key prefix. Prefixes that do not
+// end at a folder are matched with a key regular expression.
+func parseListObjectsV2(r *http.Request, request *SearchRequest) (prefix string, err error) {
+	q := r.URL.Query()
+
+	if v, ok := q["prefix"]; ok {
+		request.KeyPrefix = v[0]
+	}
+	if delimiter, ok := q["delimiter"]; ok {
+		request.Delimiter = delimiter[0]
+	}
+	if token, ok := q["continuation-token"]; ok {
+		request.PageToken = token[0]
+	}
+	if maxKeys := q.Get("max-keys"); maxKeys != "" {
+		request.BatchSize, err = strconv.Atoi(maxKeys)
+		if err != nil || request.BatchSize < 0 {
+			return "", fmt.Errorf("%w: invalid max-keys", ErrBadRequest)
+		}
+	}
+	if q.Get("start-after") != "" {
+		return "", fmt.Errorf("%w: start-after is not supported, use continuation-token", ErrBadRequest)
+	}
+	if encodingType := q.Get("encoding-type"); encodingType != "" && encodingType != "url" {
+		return "", fmt.Errorf("%w: invalid encoding-type", ErrBadRequest)
+	}
+
+	if request.CountOnly || request.SimilarTo != nil {
+		return "", fmt.Errorf("%w: list-type cannot be used with countOnly or similarTo", ErrBadRequest)
+	}
+	if request.DecryptPaths != nil && !*request.DecryptPaths {
+		return "", fmt.Errorf("%w: list-type cannot be used without decrypting paths", ErrBadRequest)
+	}
+
+	prefix = request.KeyPrefix
+	if i := strings.LastIndex(prefix, "/"); i < len(prefix)-1 {
+		if request.KeyRegex != "" {
+			return "", fmt.Errorf("%w: keyRegex cannot be used with a prefix that does not end with '/'", ErrBadRequest)
+		}
+		request.KeyRegex = "^" + regexp.QuoteMeta(prefix)
+		request.KeyPrefix = prefix[:i+1]
+	}
+
+	request.IncludeSystemMetadata = true
+	return prefix, nil
+}
+
+// listObjectsV2 writes a page of search results in the shape of the S3
+// ListObjectsV2 response, as XML or as JSON if the client accepts it.
+func (s *Server) listObjectsV2(w http.ResponseWriter, r *http.Request, request *SearchRequest, prefix string) {
+	result, err := s.searchMetadata(r.Context(), request)
+	if err != nil {
+		s.s3ErrorResponse(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	encodeKey := func(key string) string { return key }
+	if q.Get("encoding-type") == "url" {
+		encodeKey = url.QueryEscape
+	}
+	pathPrefix := "sj://" + request.Location.BucketName + "/"
+
+	list := ListBucketResult{
+		Xmlns:                 s3Namespace,
+		Name:                  request.Location.BucketName,
+		Prefix:                encodeKey(prefix),
+		Delimiter:             encodeKey(request.Delimiter),
+		MaxKeys:               request.BatchSize,
+		IsTruncated:           result.PageToken != "",
+		EncodingType:          q.Get("encoding-type"),
+		ContinuationToken:     q.Get("continuation-token"),
+		NextContinuationToken: result.PageToken,
+	}
+	for _, obj := range result.Results {
+		object := ListBucketObject{
+			Key:          encodeKey(strings.TrimPrefix(obj.Path, pathPrefix)),
+			StorageClass: "STANDARD",
+		}
+		if obj.System != nil {
+			object.LastModified = obj.System.CreatedAt.UTC()
+			object.Size = obj.System.Size
+		}
+		list.Contents = append(list.Contents, object)
+	}
+	for _, dir := range result.CommonPrefixes {
+		list.CommonPrefixes = append(list.CommonPrefixes, CommonPrefix{
+			Prefix: encodeKey(strings.TrimPrefix(dir, pathPrefix)),
+		})
+	}
+	list.KeyCount = len(list.Contents) + len(list.CommonPrefixes)
+
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Accept")); err == nil && mediaType == "application/json" {
+		s.jsonResponse(w, http.StatusOK, list)
+		return
+	}
+	s.xmlResponse(w, http.StatusOK, list)
+}
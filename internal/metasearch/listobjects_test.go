@@ -0,0 +1,113 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestListObjectsV2(t *testing.T) {
+	server := testServer()
+	repo := testRepo(server)
+	repo.paginate = true
+
+	for key, metadata := range map[string]string{
+		"a.txt":             `{"type": "text"}`,
+		"photos/2024/a.jpg": `{"type": "photo"}`,
+		"photos/2025/b.jpg": `{"type": "photo"}`,
+		"photos/c.jpg":      `{"type": "photo"}`,
+		"photos/d.txt":      `{"type": "text"}`,
+		"z.txt":             `{"type": "text"}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+	repo.sizes["sj://testbucket/enc:a.txt"] = 42
+
+	list := func(r *http.Request) ListBucketResult {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rr, r)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var result ListBucketResult
+		if rr.Header().Get("Content-Type") == "application/json" {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+		} else {
+			assert.Equal(t, rr.Header().Get("Content-Type"), "application/xml")
+			require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &result))
+		}
+		return result
+	}
+
+	// Folders
+	result := list(testRequest(http.MethodGet, "/metasearch/testbucket?list-type=2&delimiter=%2F", ""))
+	assert.Equal(t, result.Name, "testbucket")
+	assert.Equal(t, result.KeyCount, 3)
+	require.Len(t, result.Contents, 2)
+	assert.Equal(t, result.Contents[0].Key, "a.txt")
+	assert.Equal(t, result.Contents[0].Size, int64(42))
+	assert.Equal(t, result.Contents[1].Key, "z.txt")
+	require.Equal(t, []CommonPrefix{{Prefix: "photos/"}}, result.CommonPrefixes)
+	assert.False(t, result.IsTruncated)
+
+	// Pages
+	result = list(testRequest(http.MethodGet, "/metasearch/testbucket?list-type=2&max-keys=2", ""))
+	assert.Equal(t, result.MaxKeys, 2)
+	require.Len(t, result.Contents, 2)
+	assert.True(t, result.IsTruncated)
+	require.NotEmpty(t, result.NextContinuationToken)
+
+	token := result.NextContinuationToken
+	result = list(testRequest(http.MethodGet, "/metasearch/testbucket?list-type=2&max-keys=2&continuation-token="+url.QueryEscape(token), ""))
+	assert.Equal(t, result.ContinuationToken, token)
+	require.Len(t, result.Contents, 2)
+	assert.Equal(t, result.Contents[0].Key, "photos/2025/b.jpg")
+
+	// Prefixes that do not end at a folder
+	result = list(testRequest(http.MethodGet, "/metasearch/testbucket?list-type=2&prefix=photos%2F2", ""))
+	assert.Equal(t, result.Prefix, "photos/2")
+	require.Len(t, result.Contents, 2)
+	assert.Equal(t, result.Contents[0].Key, "photos/2024/a.jpg")
+	assert.Equal(t, result.Contents[1].Key, "photos/2025/b.jpg")
+
+	// Searches filter the listing, and JSON is returned if accepted
+	r := testRequest(http.MethodPost, "/metasearch/testbucket?list-type=2&prefix=photos%2F", `{"filter": "type == 'photo'"}`)
+	r.Header.Set("Accept", "application/json")
+	result = list(r)
+	require.Len(t, result.Contents, 3)
+	for _, obj := range result.Contents {
+		require.NotEqual(t, "photos/d.txt", obj.Key)
+	}
+
+	// URL encoding
+	result = list(testRequest(http.MethodGet, "/metasearch/testbucket?list-type=2&prefix=photos%2F&delimiter=%2F&encoding-type=url", ""))
+	assert.Equal(t, result.EncodingType, "url")
+	assert.Equal(t, result.Prefix, "photos%2F")
+	require.Equal(t, []CommonPrefix{{Prefix: "photos%2F2024%2F"}, {Prefix: "photos%2F2025%2F"}}, result.CommonPrefixes)
+
+	// Errors are returned in the format of S3
+	for _, query := range []string{
+		"list-type=2&max-keys=-1",
+		"list-type=2&start-after=a.txt",
+		"list-type=2&encoding-type=base64",
+		"list-type=2&delimiter=-",
+	} {
+		rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket?"+query, "")
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+		var s3err s3Error
+		require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &s3err))
+		assert.Equal(t, s3err.Code, "InvalidArgument")
+	}
+
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket?list-type=2", `{"countOnly": true}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
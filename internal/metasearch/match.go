@@ -0,0 +1,918 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"storj.io/common/uuid"
+)
+
+const (
+	// anyOfKey is the match query key of alternative match queries, one of
+	// which must match.
+	anyOfKey = "$anyOf"
+	// notKey is the match query key of a match query that must not match.
+	notKey = "$not"
+	// andKey is the match query key of match queries that must all match.
+	andKey = "$and"
+	// orKey is the match query key of match queries, one of which must
+	// match. Unlike $anyOf, several $or clauses can be combined with $and.
+	orKey = "$or"
+	// existsKey is the match query key of metadata keys that must exist.
+	existsKey = "$exists"
+	// missingKey is the match query key of metadata keys that must not exist.
+	missingKey = "$missing"
+	// caseInsensitiveKey is the match query key that enables case-insensitive
+	// matching of keys and string values.
+	caseInsensitiveKey = "$caseInsensitive"
+)
+
+// comparisonOperators maps the operators of match queries to SQL operators.
+var comparisonOperators = map[string]string{
+	"$gt":  ">",
+	"$gte": ">=",
+	"$lt":  "<",
+	"$lte": "<=",
+}
+
+// globOperator is the operator of glob patterns, e.g.
+// {"filename": {"$glob": "report-*.pdf"}}.
+const globOperator = "$glob"
+
+// subtreeOperator is the operator of hierarchical values, which matches a
+// value and its descendants, e.g. {"region": {"$subtree": "eu"}} matches "eu"
+// and "eu/de".
+const subtreeOperator = "$subtree"
+
+const (
+	// beforeOperator and afterOperator compare RFC 3339 timestamps as times,
+	// e.g. {"capturedAt": {"$after": "2024-01-01T00:00:00Z"}}. Values that are
+	// not RFC 3339 timestamps do not match.
+	beforeOperator = "$before"
+	afterOperator  = "$after"
+)
+
+// rfc3339Pattern matches RFC 3339 timestamps with a time zone, in upper case.
+// Values are checked with it before they are cast to timestamps, which would
+// fail the query for other strings.
+const rfc3339Pattern = `^[0-9]{4}-(0[1-9]|1[0-2])-(0[1-9]|[12][0-9]|3[01])T([01][0-9]|2[0-3]):[0-5][0-9]:[0-5][0-9](\.[0-9]+)?(Z|[+-]([01][0-9]|2[0-3]):[0-5][0-9])$`
+
+var rfc3339Regexp = regexp.MustCompile(rfc3339Pattern)
+
+const (
+	// eqOperator matches a scalar value, e.g. {"type": {"$eq": "photo"}}. It
+	// is equivalent to the plain value.
+	eqOperator = "$eq"
+	// inOperator matches one of several scalar values, e.g.
+	// {"type": {"$in": ["photo", "video"]}}.
+	inOperator = "$in"
+)
+
+// matchQuery is a parsed match query of a search request.
+type matchQuery struct {
+	// contains is the part of the query that is matched with JSONB
+	// containment.
+	contains map[string]interface{}
+
+	// conditions are the operator conditions of the query.
+	conditions []matchCondition
+
+	// anyOf are alternative queries, one of which must match.
+	anyOf []matchQuery
+
+	// allOf are queries that must all match, from $and clauses, $or clauses
+	// and the $eq and $in operators.
+	allOf []matchQuery
+
+	// not is a query that must not match.
+	not *matchQuery
+
+	// exists are the top-level metadata keys that must exist.
+	exists []string
+
+	// missing are the top-level metadata keys that must not exist.
+	missing []string
+
+	// system are the conditions on system attributes.
+	system []systemCondition
+
+	// caseInsensitive is true if the query is matched against the lower-cased
+	// metadata. Keys and string values of the query are lower-cased already.
+	caseInsensitive bool
+}
+
+// matchCondition compares the value at a path of the metadata with an
+// operand.
+type matchCondition struct {
+	path     []string
+	operator string
+	value    interface{}
+}
+
+// parseMatch splits a match query into values matched by containment,
+// operator conditions such as {"size": {"$gt": 1000}}, and the $anyOf and $not
+// clauses.
+func parseMatch(match map[string]interface{}) (query matchQuery, err error) {
+	return parseMatchQuery(match, false)
+}
+
+// parseMatchQuery parses a match query. Queries nested in a case-insensitive
+// query are case-insensitive too.
+func parseMatchQuery(match map[string]interface{}, caseInsensitive bool) (query matchQuery, err error) {
+	if v, ok := match[caseInsensitiveKey]; ok {
+		enabled, ok := v.(bool)
+		if !ok {
+			return query, fmt.Errorf("%w: caseInsensitive must be a boolean", ErrBadRequest)
+		}
+		caseInsensitive = caseInsensitive || enabled
+	}
+	if caseInsensitive {
+		lowered, err := lowerMatch(match)
+		if err != nil {
+			return query, err
+		}
+		match = lowered.(map[string]interface{})
+	}
+	query.caseInsensitive = caseInsensitive
+
+	var and, or []matchQuery
+	fields := make(map[string]interface{}, len(match))
+	for k, v := range match {
+		switch k {
+		case caseInsensitiveKey:
+			// Handled above
+		case anyOfKey:
+			alternatives, ok := v.([]interface{})
+			if !ok || len(alternatives) == 0 {
+				return query, fmt.Errorf("%w: anyOf must be a non-empty array", ErrBadRequest)
+			}
+			for _, alternative := range alternatives {
+				obj, ok := alternative.(map[string]interface{})
+				if !ok {
+					return query, fmt.Errorf("%w: anyOf must contain match objects", ErrBadRequest)
+				}
+				subquery, err := parseMatchQuery(obj, caseInsensitive)
+				if err != nil {
+					return query, err
+				}
+				query.anyOf = append(query.anyOf, subquery)
+			}
+		case andKey:
+			and, err = parseMatchList(k, v, caseInsensitive)
+			if err != nil {
+				return query, err
+			}
+		case orKey:
+			or, err = parseMatchList(k, v, caseInsensitive)
+			if err != nil {
+				return query, err
+			}
+		case notKey:
+			obj, ok := v.(map[string]interface{})
+			if !ok || len(obj) == 0 {
+				return query, fmt.Errorf("%w: not must be a non-empty match object", ErrBadRequest)
+			}
+			subquery, err := parseMatchQuery(obj, caseInsensitive)
+			if err != nil {
+				return query, err
+			}
+			query.not = &subquery
+		case existsKey:
+			query.exists, err = parseMatchKeys(k, v)
+			if err != nil {
+				return query, err
+			}
+		case missingKey:
+			query.missing, err = parseMatchKeys(k, v)
+			if err != nil {
+				return query, err
+			}
+		case systemKey:
+			query.system, err = parseSystemConditions(v)
+			if err != nil {
+				return query, err
+			}
+		default:
+			fields[k] = v
+		}
+	}
+
+	// Add nested queries in a stable order, so that the generated SQL does
+	// not change
+	query.allOf = append(query.allOf, and...)
+	if len(or) > 0 {
+		query.allOf = append(query.allOf, matchQuery{anyOf: or, caseInsensitive: caseInsensitive})
+	}
+
+	query.contains, err = parseMatchObject(&query, nil, fields)
+	return query, err
+}
+
+// lowerMatch lower-cases the keys and string values of a match query. Clause
+// and operator names, and conditions on system attributes are left unchanged.
+func lowerMatch(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		lowered := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			if k == systemKey {
+				lowered[k] = child
+				continue
+			}
+			if !strings.HasPrefix(k, "$") {
+				k = strings.ToLower(k)
+			}
+			if _, ok := lowered[k]; ok {
+				return nil, fmt.Errorf("%w: keys of case-insensitive match query differ only by case: '%s'", ErrBadRequest, k)
+			}
+			loweredChild, err := lowerMatch(child)
+			if err != nil {
+				return nil, err
+			}
+			lowered[k] = loweredChild
+		}
+		return lowered, nil
+	case []interface{}:
+		lowered := make([]interface{}, 0, len(v))
+		for _, child := range v {
+			loweredChild, err := lowerMatch(child)
+			if err != nil {
+				return nil, err
+			}
+			lowered = append(lowered, loweredChild)
+		}
+		return lowered, nil
+	case string:
+		return strings.ToLower(v), nil
+	default:
+		return v, nil
+	}
+}
+
+// parseMatchList parses the match queries of an $and or $or clause.
+func parseMatchList(clause string, v interface{}, caseInsensitive bool) ([]matchQuery, error) {
+	values, ok := v.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("%w: %s must be a non-empty array of match objects", ErrBadRequest, clause)
+	}
+
+	queries := make([]matchQuery, 0, len(values))
+	for _, value := range values {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %s must be a non-empty array of match objects", ErrBadRequest, clause)
+		}
+		query, err := parseMatchQuery(obj, caseInsensitive)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
+	}
+	return queries, nil
+}
+
+// parseMatchKeys parses the metadata keys of an exists or missing clause.
+func parseMatchKeys(clause string, v interface{}) ([]string, error) {
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s must be an array of keys", ErrBadRequest, strings.TrimPrefix(clause, "$"))
+	}
+
+	keys := make([]string, 0, len(values))
+	for _, value := range values {
+		key, ok := value.(string)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%w: %s must be an array of keys", ErrBadRequest, strings.TrimPrefix(clause, "$"))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func parseMatchObject(query *matchQuery, path []string, obj map[string]interface{}) (map[string]interface{}, error) {
+	// Iterate in a stable order, so that the generated SQL does not change
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	contains := make(map[string]interface{})
+	for _, k := range keys {
+		v := obj[k]
+		fieldPath := append(append([]string(nil), path...), k)
+
+		child, ok := v.(map[string]interface{})
+		if !ok {
+			contains[k] = v
+			continue
+		}
+
+		isOperator, err := isOperatorObject(child)
+		if err != nil {
+			return nil, err
+		}
+		if isOperator {
+			err = parseOperators(query, fieldPath, child)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		childContains, err := parseMatchObject(query, fieldPath, child)
+		if err != nil {
+			return nil, err
+		}
+		if len(childContains) > 0 || len(child) == 0 {
+			contains[k] = childContains
+		}
+	}
+
+	return contains, nil
+}
+
+// isOperatorObject returns true if all keys of obj are operators.
+func isOperatorObject(obj map[string]interface{}) (bool, error) {
+	operators := 0
+	for k := range obj {
+		if strings.HasPrefix(k, "$") {
+			operators++
+		}
+	}
+	if operators > 0 && operators < len(obj) {
+		return false, fmt.Errorf("%w: operators cannot be mixed with fields in match query", ErrBadRequest)
+	}
+	return operators > 0, nil
+}
+
+func parseOperators(query *matchQuery, path []string, operators map[string]interface{}) error {
+	names := make([]string, 0, len(operators))
+	for name := range operators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := operators[name]
+		if name == eqOperator || name == inOperator {
+			values := []interface{}{value}
+			if name == inOperator {
+				var ok bool
+				values, ok = value.([]interface{})
+				if !ok || len(values) == 0 {
+					return fmt.Errorf("%w: operand of '%s' must be a non-empty array", ErrBadRequest, name)
+				}
+			}
+
+			// Values are matched by containment, which can use the GIN index
+			alternatives := make([]matchQuery, 0, len(values))
+			for _, v := range values {
+				switch v.(type) {
+				case string, float64, json.Number, bool, nil:
+				default:
+					return fmt.Errorf("%w: operands of '%s' must be strings, numbers, booleans or null", ErrBadRequest, name)
+				}
+				alternatives = append(alternatives, matchQuery{
+					contains:        nestedValue(path, v),
+					caseInsensitive: query.caseInsensitive,
+				})
+			}
+			if len(alternatives) == 1 {
+				query.allOf = append(query.allOf, alternatives[0])
+			} else {
+				query.allOf = append(query.allOf, matchQuery{anyOf: alternatives, caseInsensitive: query.caseInsensitive})
+			}
+			continue
+		}
+
+		if name == beforeOperator || name == afterOperator {
+			s, ok := value.(string)
+			t, err := time.Parse(time.RFC3339, s)
+			if !ok || err != nil {
+				return fmt.Errorf("%w: operand of '%s' must be an RFC 3339 time", ErrBadRequest, name)
+			}
+			operator := "<"
+			if name == afterOperator {
+				operator = ">"
+			}
+			query.conditions = append(query.conditions, matchCondition{
+				path:     path,
+				operator: operator,
+				value:    t,
+			})
+			continue
+		}
+
+		if name == geoWithinOperator {
+			area, err := parseGeoArea(value)
+			if err != nil {
+				return err
+			}
+			query.conditions = append(query.conditions, matchCondition{
+				path:     path,
+				operator: "GEO",
+				value:    area,
+			})
+			continue
+		}
+
+		if name == globOperator {
+			pattern, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%w: operand of '%s' must be a string", ErrBadRequest, name)
+			}
+			query.conditions = append(query.conditions, matchCondition{
+				path:     path,
+				operator: "LIKE",
+				value:    globToLike(pattern),
+			})
+			continue
+		}
+
+		if name == subtreeOperator {
+			value, ok := value.(string)
+			if !ok || value == "" {
+				return fmt.Errorf("%w: operand of '%s' must be a non-empty string", ErrBadRequest, name)
+			}
+			query.conditions = append(query.conditions, matchCondition{
+				path:     path,
+				operator: "SUBTREE",
+				value:    value,
+			})
+			continue
+		}
+
+		operator, ok := comparisonOperators[name]
+		if !ok {
+			return fmt.Errorf("%w: unknown operator '%s' in match query", ErrBadRequest, name)
+		}
+
+		switch value.(type) {
+		case float64, json.Number, string:
+		default:
+			return fmt.Errorf("%w: operand of '%s' must be a number or a string", ErrBadRequest, name)
+		}
+
+		query.conditions = append(query.conditions, matchCondition{
+			path:     path,
+			operator: operator,
+			value:    value,
+		})
+	}
+	return nil
+}
+
+// nestedValue returns the object that contains value at path.
+func nestedValue(path []string, value interface{}) map[string]interface{} {
+	obj := map[string]interface{}{path[len(path)-1]: value}
+	for i := len(path) - 2; i >= 0; i-- {
+		obj = map[string]interface{}{path[i]: obj}
+	}
+	return obj
+}
+
+// globToLike converts a glob pattern to a LIKE pattern. In glob patterns, *
+// matches any sequence of characters, ? matches a single character and a
+// backslash escapes the next character.
+func globToLike(pattern string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+			if r == '%' || r == '_' || r == '\\' {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			b.WriteRune('%')
+		case r == '?':
+			b.WriteRune('_')
+		case r == '%' || r == '_':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if escaped {
+		b.WriteString(`\\`)
+	}
+	return b.String()
+}
+
+// likeEscaper escapes the special characters of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// jsonType returns the JSONB type name of a condition operand. Times are
+// stored as strings, and locations as objects.
+func (c matchCondition) jsonType() string {
+	switch c.value.(type) {
+	case string, time.Time:
+		return "string"
+	case geoArea:
+		return "object"
+	}
+	return "number"
+}
+
+// matchesAll returns true if the query matches all objects.
+func (q matchQuery) matchesAll() bool {
+	leaves := 0
+	splitToLeafValues(q.contains, func(interface{}) { leaves++ })
+	if leaves > 0 || len(q.conditions) > 0 || len(q.exists) > 0 || len(q.missing) > 0 || len(q.system) > 0 || q.not != nil {
+		return false
+	}
+	for _, subquery := range q.allOf {
+		if !subquery.matchesAll() {
+			return false
+		}
+	}
+	if len(q.anyOf) == 0 {
+		return true
+	}
+	for _, alternative := range q.anyOf {
+		if alternative.matchesAll() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchQueryBuilder builds the SQL subqueries of match queries.
+type matchQueryBuilder struct {
+	// loc is the bucket of the query.
+	loc ObjectLocation
+	// projectIDs are the projects of a query across projects. If set, the
+	// query is limited to the bucket in loc only if its name is set.
+	projectIDs []uuid.UUID
+	// dialect is the SQL dialect of the metabase.
+	dialect Dialect
+	// tables are the names of the objects table and its indexes.
+	tables MetabaseTables
+
+	args   []interface{}
+	leaves int
+}
+
+// arg adds a query argument and returns its placeholder.
+func (b *matchQueryBuilder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// metadata returns the SQL expression of the metadata matched by a query.
+// Case-insensitive queries match the lower-cased metadata, which is covered by
+// an expression index.
+func metadata(caseInsensitive bool) string {
+	if caseInsensitive {
+		return "lower(clear_metadata::TEXT)::JSONB"
+	}
+	return "clear_metadata"
+}
+
+// containsSubqueries returns a subquery for each leaf value of the containment
+// part of a query.
+func (b *matchQueryBuilder) containsSubqueries(query matchQuery) ([]string, error) {
+	cq, err := json.Marshal(query.contains)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	parts, err := splitToJSONLeaves(string(cq))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	b.leaves += len(parts)
+	if b.leaves > MaxFindObjectsByClearMetadataQuerySize {
+		return nil, fmt.Errorf("%w: too many values in metadata query", ErrBadRequest)
+	}
+
+	index := defaultMetadataIndex
+	if query.caseInsensitive {
+		index = defaultMetadataLowerIndex
+	}
+
+	subqueries := make([]string, 0, len(parts))
+	for _, part := range parts {
+		subqueries = append(subqueries, fmt.Sprintf("(SELECT project_id, bucket_name, object_key, version FROM %s WHERE %s @> %s)\n", b.table(index), metadata(query.caseInsensitive), b.arg(part)))
+	}
+	return subqueries, nil
+}
+
+// condition returns the SQL expression of an operator condition.
+func (b *matchQueryBuilder) condition(c matchCondition, caseInsensitive bool) (string, error) {
+	column := metadata(caseInsensitive)
+	path := b.arg(c.path)
+	if c.operator == "LIKE" {
+		// Compare the text of the value, it must be a string
+		return fmt.Sprintf("jsonb_typeof(%s #> %s::TEXT[]) = %s AND (%s #>> %s::TEXT[]) LIKE %s",
+			column, path, b.arg(c.jsonType()), column, path, b.arg(c.value)), nil
+	}
+	if c.operator == "SUBTREE" {
+		// The value itself, or a value below it in the hierarchy
+		value := c.value.(string)
+		return fmt.Sprintf("jsonb_typeof(%s #> %s::TEXT[]) = %s AND ((%s #>> %s::TEXT[]) = %s OR (%s #>> %s::TEXT[]) LIKE %s)",
+			column, path, b.arg(c.jsonType()), column, path, b.arg(value), column, path, b.arg(likeEscaper.Replace(value)+"/%")), nil
+	}
+
+	if area, ok := c.value.(geoArea); ok {
+		return area.sql(b, column, c.path), nil
+	}
+	if t, ok := c.value.(time.Time); ok {
+		// Lower-cased timestamps are converted back to upper case. CASE
+		// makes sure that only timestamps are cast.
+		text := fmt.Sprintf("upper(%s #>> %s::TEXT[])", column, path)
+		return fmt.Sprintf("jsonb_typeof(%s #> %s::TEXT[]) = %s AND CASE WHEN %s ~ %s THEN %s::TIMESTAMPTZ %s %s::TIMESTAMPTZ ELSE false END",
+			column, path, b.arg(c.jsonType()), text, b.arg(rfc3339Pattern), text, c.operator, b.arg(t)), nil
+	}
+
+	value, err := json.Marshal(c.value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return fmt.Sprintf("jsonb_typeof(%s #> %s::TEXT[]) = %s AND (%s #> %s::TEXT[]) %s %s::JSONB",
+		column, path, b.arg(c.jsonType()), column, path, c.operator, b.arg(string(value))), nil
+}
+
+// conditions returns the SQL expressions of the operator conditions, the
+// exists and missing clauses and the system attribute conditions of a query.
+// They cannot use the GIN index.
+func (b *matchQueryBuilder) conditions(query matchQuery) ([]string, error) {
+	column := metadata(query.caseInsensitive)
+	conditions := make([]string, 0, len(query.conditions)+len(query.exists)+len(query.missing)+len(query.system))
+	for _, c := range query.conditions {
+		condition, err := b.condition(c, query.caseInsensitive)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	for _, key := range query.exists {
+		conditions = append(conditions, fmt.Sprintf("%s ? %s", column, b.arg(key)))
+	}
+	for _, key := range query.missing {
+		conditions = append(conditions, fmt.Sprintf("NOT COALESCE(%s ? %s, false)", column, b.arg(key)))
+	}
+	for _, c := range query.system {
+		conditions = append(conditions, c.sql(b))
+	}
+	return conditions, nil
+}
+
+// scope returns the SQL condition that limits the query to its bucket or
+// projects.
+func (b *matchQueryBuilder) scope() string {
+	if b.projectIDs == nil {
+		return fmt.Sprintf("project_id = %s AND bucket_name = %s", b.arg(b.loc.ProjectID), b.arg([]byte(b.loc.BucketName)))
+	}
+
+	projectIDs := make([][]byte, 0, len(b.projectIDs))
+	for _, projectID := range b.projectIDs {
+		projectIDs = append(projectIDs, projectID.Bytes())
+	}
+	scope := fmt.Sprintf("project_id = ANY(%s::BYTEA[])", b.arg(projectIDs))
+	if b.loc.BucketName != "" {
+		scope += fmt.Sprintf(" AND bucket_name = %s", b.arg([]byte(b.loc.BucketName)))
+	}
+	return scope
+}
+
+// table returns the reference of a query reading the objects table with the
+// index whose default name is index.
+func (b *matchQueryBuilder) table(index string) string {
+	return b.dialect.table(b.tables.objects(), b.tables.index(index))
+}
+
+// noObjectsSubquery returns a subquery that matches no objects.
+func (b *matchQueryBuilder) noObjectsSubquery() string {
+	return "(SELECT project_id, bucket_name, object_key, version FROM " + b.tables.objects() + " WHERE false)\n"
+}
+
+// bucketSubquery returns a subquery of the objects in the scope of the query
+// that match the SQL conditions.
+func (b *matchQueryBuilder) bucketSubquery(conditions []string) string {
+	conditions = append([]string{b.scope()}, conditions...)
+	return "(SELECT project_id, bucket_name, object_key, version FROM " + b.tables.objects() + " WHERE " + strings.Join(conditions, " AND ") + ")\n"
+}
+
+// anyOf returns the UNION of the alternative queries. It returns an empty
+// string if an alternative matches all objects.
+func (b *matchQueryBuilder) anyOf(alternatives []matchQuery) (string, error) {
+	for _, alternative := range alternatives {
+		if alternative.matchesAll() {
+			return "", nil
+		}
+	}
+
+	sets := make([]string, 0, len(alternatives))
+	for _, alternative := range alternatives {
+		set, err := b.matchSet(alternative)
+		if err != nil {
+			return "", err
+		}
+		sets = append(sets, set)
+	}
+	return "(" + strings.Join(sets, "UNION \n") + ")\n", nil
+}
+
+// matchSet returns a subquery of the objects that match a query. It returns
+// an empty string if the query matches all objects.
+func (b *matchQueryBuilder) matchSet(query matchQuery) (string, error) {
+	if query.not != nil && query.not.matchesAll() {
+		return b.noObjectsSubquery(), nil
+	}
+
+	sets, err := b.indexedSubqueries(query)
+	if err != nil {
+		return "", err
+	}
+
+	conditions, err := b.conditions(query)
+	if err != nil {
+		return "", err
+	}
+	if len(conditions) > 0 {
+		sets = append(sets, b.bucketSubquery(conditions))
+	}
+
+	if query.not == nil {
+		if len(sets) == 0 {
+			return "", nil
+		}
+		return "(" + strings.Join(sets, "INTERSECT \n") + ")\n", nil
+	}
+
+	not, err := b.matchSet(*query.not)
+	if err != nil {
+		return "", err
+	}
+	if len(sets) == 0 {
+		sets = append(sets, b.bucketSubquery(nil))
+	}
+	return "((" + strings.Join(sets, "INTERSECT \n") + ") EXCEPT " + not + ")\n", nil
+}
+
+// indexedSubqueries returns the subqueries of the parts of a query that can
+// use the GIN index: its contained values, and its alternative and nested
+// queries. Objects must be in all of them.
+func (b *matchQueryBuilder) indexedSubqueries(query matchQuery) ([]string, error) {
+	subqueries, err := b.containsSubqueries(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(query.anyOf) > 0 {
+		anyOf, err := b.anyOf(query.anyOf)
+		if err != nil {
+			return nil, err
+		}
+		if anyOf != "" {
+			subqueries = append(subqueries, anyOf)
+		}
+	}
+
+	for _, subquery := range query.allOf {
+		set, err := b.matchSet(subquery)
+		if err != nil {
+			return nil, err
+		}
+		if set != "" {
+			subqueries = append(subqueries, set)
+		}
+	}
+	return subqueries, nil
+}
+
+// searchFilter returns the SQL conditions that select the objects matched by
+// a query in the bucket of b.loc, regardless of the page.
+func (b *matchQueryBuilder) searchFilter(match matchQuery) (string, error) {
+	var query string
+
+	// We make a subquery for each clear_metadata part. This is optimized for
+	// CockroachDB whose optimizer is very unpredictable when querying with
+	// multiple JSONB values, and would often scan the full table instead of
+	// using the GIN index.
+	subqueries, err := b.indexedSubqueries(match)
+	if err != nil {
+		return "", err
+	}
+
+	if len(subqueries) > 0 {
+		query += `(project_id, bucket_name, object_key, version) IN (` + strings.Join(subqueries, "INTERSECT \n") + `) AND `
+	}
+
+	query += fmt.Sprintf("project_id = %s AND bucket_name = %s AND status <> %s AND (expires_at IS NULL OR expires_at > now())",
+		b.arg(b.loc.ProjectID), b.arg([]byte(b.loc.BucketName)), b.arg(statusPending))
+
+	// Operator conditions cannot use the GIN index, they filter the objects
+	// matched by the other conditions.
+	conditions, err := b.conditions(match)
+	if err != nil {
+		return "", err
+	}
+	for _, condition := range conditions {
+		query += "\nAND " + condition
+	}
+
+	if match.not != nil && match.not.matchesAll() {
+		query += "\nAND false"
+	} else if match.not != nil {
+		not, err := b.matchSet(*match.not)
+		if err != nil {
+			return "", err
+		}
+		query += "\nAND (project_id, bucket_name, object_key, version) NOT IN " + not
+	}
+
+	return query, nil
+}
+
+// predicate returns a boolean SQL expression that evaluates a query on a
+// single object, without using the GIN index.
+func (b *matchQueryBuilder) predicate(query matchQuery) (string, error) {
+	if query.matchesAll() {
+		return "true", nil
+	}
+
+	var predicates []string
+	if len(query.contains) > 0 {
+		value, err := json.Marshal(query.contains)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		predicates = append(predicates, fmt.Sprintf("%s @> %s::JSONB", metadata(query.caseInsensitive), b.arg(string(value))))
+	}
+
+	conditions, err := b.conditions(query)
+	if err != nil {
+		return "", err
+	}
+	predicates = append(predicates, conditions...)
+
+	if len(query.anyOf) > 0 {
+		alternatives := make([]string, 0, len(query.anyOf))
+		for _, alternative := range query.anyOf {
+			predicate, err := b.predicate(alternative)
+			if err != nil {
+				return "", err
+			}
+			alternatives = append(alternatives, predicate)
+		}
+		predicates = append(predicates, "("+strings.Join(alternatives, " OR ")+")")
+	}
+
+	for _, subquery := range query.allOf {
+		predicate, err := b.predicate(subquery)
+		if err != nil {
+			return "", err
+		}
+		predicates = append(predicates, predicate)
+	}
+
+	if query.not != nil {
+		not, err := b.predicate(*query.not)
+		if err != nil {
+			return "", err
+		}
+		predicates = append(predicates, "NOT COALESCE("+not+", false)")
+	}
+
+	return "(" + strings.Join(predicates, " AND ") + ")", nil
+}
+
+// pageRange returns the SQL conditions that limit a query to the objects of a
+// page: objects after startAfter, or from the start of the key prefix for the
+// first page, and before the end of the key prefix.
+func (b *matchQueryBuilder) pageRange(loc ObjectLocation, startAfter ObjectLocation) string {
+	var query string
+	if startAfter.ProjectID.IsZero() {
+		// first page => use key prefix
+		query += fmt.Sprintf("\nAND (project_id, bucket_name, object_key, version) >= (%s, %s, %s, %s)",
+			b.arg(loc.ProjectID), b.arg([]byte(loc.BucketName)), b.arg([]byte(loc.ObjectKey)), b.arg(0))
+	} else {
+		// subsequent pages => use startAfter
+		query += fmt.Sprintf("\nAND (project_id, bucket_name, object_key, version) > (%s, %s, %s, %s)",
+			b.arg(loc.ProjectID), b.arg([]byte(loc.BucketName)), b.arg([]byte(startAfter.ObjectKey)), b.arg(startAfter.Version))
+	}
+
+	if loc.ObjectKey != "" {
+		prefixLimit := prefixLimit(loc.ObjectKey)
+		query += fmt.Sprintf("\nAND (project_id, bucket_name, object_key, version) < (%s, %s, %s, %s)",
+			b.arg(loc.ProjectID), b.arg([]byte(loc.BucketName)), b.arg([]byte(prefixLimit)), b.arg(0))
+	}
+	return query
+}
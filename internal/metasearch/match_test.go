@@ -0,0 +1,461 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/uuid"
+)
+
+func TestParseMatch(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{
+		"foo": "bar",
+		"size": map[string]interface{}{
+			"$gt":  float64(1000),
+			"$lte": float64(2000),
+		},
+		"exif": map[string]interface{}{
+			"camera": "x100",
+			"date":   map[string]interface{}{"$gte": "2024-01-01"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"foo":  "bar",
+		"exif": map[string]interface{}{"camera": "x100"},
+	}, query.contains)
+	require.Equal(t, []matchCondition{
+		{path: []string{"exif", "date"}, operator: ">=", value: "2024-01-01"},
+		{path: []string{"size"}, operator: ">", value: float64(1000)},
+		{path: []string{"size"}, operator: "<=", value: float64(2000)},
+	}, query.conditions)
+	require.Equal(t, "string", query.conditions[0].jsonType())
+	require.Equal(t, "number", query.conditions[1].jsonType())
+
+	// Unknown operator
+	_, err = parseMatch(map[string]interface{}{
+		"size": map[string]interface{}{"$foo": float64(1)},
+	})
+	require.ErrorIs(t, err, ErrBadRequest)
+
+	// Operators mixed with fields
+	_, err = parseMatch(map[string]interface{}{
+		"size": map[string]interface{}{"$gt": float64(1), "foo": "bar"},
+	})
+	require.ErrorIs(t, err, ErrBadRequest)
+
+	// Invalid operand
+	_, err = parseMatch(map[string]interface{}{
+		"size": map[string]interface{}{"$gt": []interface{}{1}},
+	})
+	require.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestParseMatchGlob(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{
+		"filename": map[string]interface{}{"$glob": "report-*.pdf"},
+	})
+	require.NoError(t, err)
+	require.Empty(t, query.contains)
+	require.Equal(t, []matchCondition{
+		{path: []string{"filename"}, operator: "LIKE", value: "report-%.pdf"},
+	}, query.conditions)
+
+	b := &matchQueryBuilder{}
+	conditions, err := b.conditions(query)
+	require.NoError(t, err)
+	require.Equal(t, []string{"jsonb_typeof(clear_metadata #> $1::TEXT[]) = $2 AND (clear_metadata #>> $1::TEXT[]) LIKE $3"}, conditions)
+	require.Equal(t, []interface{}{[]string{"filename"}, "string", "report-%.pdf"}, b.args)
+
+	for pattern, expected := range map[string]string{
+		"build-??":    "build-__",
+		"100%_done*":  `100\%\_done%`,
+		`literal\*`:   "literal*",
+		`back\\slash`: `back\\slash`,
+		`trailing\`:   `trailing\\`,
+	} {
+		require.Equal(t, expected, globToLike(pattern), pattern)
+	}
+
+	// Invalid operand
+	_, err = parseMatch(map[string]interface{}{
+		"filename": map[string]interface{}{"$glob": float64(1)},
+	})
+	require.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestParseMatchSubtree(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{
+		"region": map[string]interface{}{"$subtree": "eu_west"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []matchCondition{
+		{path: []string{"region"}, operator: "SUBTREE", value: "eu_west"},
+	}, query.conditions)
+
+	b := &matchQueryBuilder{}
+	conditions, err := b.conditions(query)
+	require.NoError(t, err)
+	require.Equal(t, []string{"jsonb_typeof(clear_metadata #> $1::TEXT[]) = $2 AND ((clear_metadata #>> $1::TEXT[]) = $3 OR (clear_metadata #>> $1::TEXT[]) LIKE $4)"}, conditions)
+	require.Equal(t, []interface{}{[]string{"region"}, "string", "eu_west", `eu\_west/%`}, b.args)
+
+	// Invalid operands
+	for _, operand := range []interface{}{"", float64(1)} {
+		_, err = parseMatch(map[string]interface{}{
+			"region": map[string]interface{}{"$subtree": operand},
+		})
+		require.ErrorIs(t, err, ErrBadRequest)
+	}
+}
+
+func TestParseMatchTimes(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{
+		"capturedAt": map[string]interface{}{
+			"$after":  "2024-01-01T00:00:00Z",
+			"$before": "2024-02-01T00:00:00+01:00",
+		},
+	})
+	require.NoError(t, err)
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)
+	require.Len(t, query.conditions, 2)
+	require.Equal(t, ">", query.conditions[0].operator)
+	require.True(t, after.Equal(query.conditions[0].value.(time.Time)))
+	require.Equal(t, "<", query.conditions[1].operator)
+	require.True(t, before.Equal(query.conditions[1].value.(time.Time)))
+	require.Equal(t, "string", query.conditions[0].jsonType())
+
+	b := &matchQueryBuilder{}
+	condition, err := b.condition(query.conditions[0], false)
+	require.NoError(t, err)
+	require.Equal(t, "jsonb_typeof(clear_metadata #> $1::TEXT[]) = $2 AND "+
+		"CASE WHEN upper(clear_metadata #>> $1::TEXT[]) ~ $3 THEN upper(clear_metadata #>> $1::TEXT[])::TIMESTAMPTZ > $4::TIMESTAMPTZ ELSE false END", condition)
+	require.Equal(t, []interface{}{[]string{"capturedAt"}, "string", rfc3339Pattern, query.conditions[0].value}, b.args)
+
+	// Values are compared as times, in any time zone
+	for value, matches := range map[string]bool{
+		"2024-01-15T10:00:00Z":        true,
+		"2024-01-01T00:30:00.5+01:00": false,
+		"2024-01-01T00:00:00.5Z":      true,
+		"2024-01-15t10:00:00z":        true,
+		"2023-12-31T23:59:59Z":        false,
+		"2024-01-15":                  false,
+		"2024-02-30T00:00:00Z":        false,
+	} {
+		require.Equal(t, matches, query.conditions[0].matches(value, false), value)
+	}
+	require.False(t, query.conditions[0].matches(json.Number("1"), false))
+
+	// Invalid operands
+	for _, operand := range []interface{}{"2024-01-01", "yesterday", json.Number("1")} {
+		_, err = parseMatch(map[string]interface{}{
+			"capturedAt": map[string]interface{}{"$after": operand},
+		})
+		require.ErrorIs(t, err, ErrBadRequest)
+	}
+}
+
+func TestParseMatchAnyOfNot(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{
+		"foo": "bar",
+		"$anyOf": []interface{}{
+			map[string]interface{}{"tag": "a"},
+			map[string]interface{}{"tag": "b"},
+		},
+		"$not": map[string]interface{}{"archived": true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"foo": "bar"}, query.contains)
+	require.Len(t, query.anyOf, 2)
+	require.Equal(t, map[string]interface{}{"tag": "b"}, query.anyOf[1].contains)
+	require.NotNil(t, query.not)
+	require.Equal(t, map[string]interface{}{"archived": true}, query.not.contains)
+	require.False(t, query.matchesAll())
+
+	// An empty alternative matches all objects
+	query, err = parseMatch(map[string]interface{}{
+		"$anyOf": []interface{}{
+			map[string]interface{}{"tag": "a"},
+			map[string]interface{}{},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, query.matchesAll())
+
+	// Invalid clauses
+	_, err = parseMatch(map[string]interface{}{"$anyOf": []interface{}{}})
+	require.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = parseMatch(map[string]interface{}{"$anyOf": []interface{}{"foo"}})
+	require.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = parseMatch(map[string]interface{}{"$not": map[string]interface{}{}})
+	require.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestParseMatchOperators(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{
+		"exif": map[string]interface{}{
+			"iso": map[string]interface{}{"$eq": float64(400)},
+		},
+	})
+	require.NoError(t, err)
+	require.Empty(t, query.contains)
+	require.Len(t, query.allOf, 1)
+	require.Equal(t, map[string]interface{}{"exif": map[string]interface{}{"iso": float64(400)}}, query.allOf[0].contains)
+	require.False(t, query.matchesAll())
+
+	query, err = parseMatch(map[string]interface{}{
+		"type": map[string]interface{}{"$in": []interface{}{"photo", "video"}},
+		"$and": []interface{}{
+			map[string]interface{}{"a": float64(1)},
+		},
+		"$or": []interface{}{
+			map[string]interface{}{"tag": "x"},
+			map[string]interface{}{"tag": "y"},
+		},
+	})
+	require.NoError(t, err)
+
+	b := &matchQueryBuilder{}
+	predicate, err := b.predicate(query)
+	require.NoError(t, err)
+	require.Equal(t, "((clear_metadata @> $1::JSONB) AND "+
+		"(((clear_metadata @> $2::JSONB) OR (clear_metadata @> $3::JSONB))) AND "+
+		"(((clear_metadata @> $4::JSONB) OR (clear_metadata @> $5::JSONB))))", predicate)
+	require.Equal(t, []interface{}{`{"a":1}`, `{"tag":"x"}`, `{"tag":"y"}`, `{"type":"photo"}`, `{"type":"video"}`}, b.args)
+
+	// Nested queries use the GIN index
+	b = &matchQueryBuilder{}
+	filter, err := b.searchFilter(query)
+	require.NoError(t, err)
+	require.Contains(t, filter, "UNION")
+	require.NotContains(t, filter, "jsonb_typeof")
+
+	// Invalid operands and clauses
+	for _, invalid := range []map[string]interface{}{
+		{"type": map[string]interface{}{"$in": []interface{}{}}},
+		{"type": map[string]interface{}{"$in": "photo"}},
+		{"type": map[string]interface{}{"$in": []interface{}{map[string]interface{}{}}}},
+		{"type": map[string]interface{}{"$eq": []interface{}{"photo"}}},
+		{"$and": map[string]interface{}{"a": float64(1)}},
+		{"$or": []interface{}{}},
+		{"$or": []interface{}{"a"}},
+	} {
+		_, err = parseMatch(invalid)
+		require.ErrorIs(t, err, ErrBadRequest, invalid)
+	}
+}
+
+func TestMatchQueryBuilder(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{
+		"$anyOf": []interface{}{
+			map[string]interface{}{"tag": "a"},
+			map[string]interface{}{"size": map[string]interface{}{"$gt": float64(1)}},
+		},
+		"$not": map[string]interface{}{"archived": true},
+	})
+	require.NoError(t, err)
+
+	b := &matchQueryBuilder{}
+	anyOf, err := b.anyOf(query.anyOf)
+	require.NoError(t, err)
+	require.Contains(t, anyOf, "UNION")
+
+	not, err := b.matchSet(*query.not)
+	require.NoError(t, err)
+	require.Contains(t, not, "clear_metadata @> $7")
+
+	// Every argument is used by a placeholder
+	for i := range b.args {
+		require.Contains(t, anyOf+not, fmt.Sprintf("$%d", i+1))
+	}
+
+	// Too many values
+	values := make(map[string]interface{})
+	for i := 0; i <= MaxFindObjectsByClearMetadataQuerySize; i++ {
+		values[fmt.Sprint(i)] = i
+	}
+	_, err = (&matchQueryBuilder{}).matchSet(matchQuery{contains: values})
+	require.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestParseMatchExistsMissing(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{
+		"$exists":  []interface{}{"reviewedBy"},
+		"$missing": []interface{}{"approvedBy", "rejectedBy"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"reviewedBy"}, query.exists)
+	require.Equal(t, []string{"approvedBy", "rejectedBy"}, query.missing)
+	require.False(t, query.matchesAll())
+
+	b := &matchQueryBuilder{}
+	conditions, err := b.conditions(query)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"clear_metadata ? $1",
+		"NOT COALESCE(clear_metadata ? $2, false)",
+		"NOT COALESCE(clear_metadata ? $3, false)",
+	}, conditions)
+	require.Equal(t, []interface{}{"reviewedBy", "approvedBy", "rejectedBy"}, b.args)
+
+	// Invalid keys
+	_, err = parseMatch(map[string]interface{}{"$exists": "reviewedBy"})
+	require.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = parseMatch(map[string]interface{}{"$missing": []interface{}{1}})
+	require.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestParseMatchCaseInsensitive(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{
+		"$caseInsensitive": true,
+		"Tags":             []interface{}{"Holiday"},
+		"Size":             float64(1),
+		"Name":             map[string]interface{}{"$glob": "IMG_*"},
+		"$anyOf": []interface{}{
+			map[string]interface{}{"Camera": "X100"},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, query.caseInsensitive)
+	require.Equal(t, map[string]interface{}{
+		"tags": []interface{}{"holiday"},
+		"size": float64(1),
+	}, query.contains)
+	require.Equal(t, []matchCondition{
+		{path: []string{"name"}, operator: "LIKE", value: `img\_%`},
+	}, query.conditions)
+	require.Len(t, query.anyOf, 1)
+	require.True(t, query.anyOf[0].caseInsensitive)
+	require.Equal(t, map[string]interface{}{"camera": "x100"}, query.anyOf[0].contains)
+
+	b := &matchQueryBuilder{}
+	subqueries, err := b.containsSubqueries(query)
+	require.NoError(t, err)
+	require.Len(t, subqueries, 2)
+	require.Contains(t, subqueries[0], "objects@objects_clear_metadata_lower_idx WHERE lower(clear_metadata::TEXT)::JSONB @> $1")
+
+	// Keys that differ only by case
+	_, err = parseMatch(map[string]interface{}{
+		"$caseInsensitive": true,
+		"tag":              "a",
+		"Tag":              "b",
+	})
+	require.ErrorIs(t, err, ErrBadRequest)
+
+	// Invalid flag
+	_, err = parseMatch(map[string]interface{}{"$caseInsensitive": "yes"})
+	require.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestMatchPredicate(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{
+		"foo":  "bar",
+		"size": map[string]interface{}{"$gt": float64(1)},
+		"$anyOf": []interface{}{
+			map[string]interface{}{"tag": "a"},
+			map[string]interface{}{"tag": "b"},
+		},
+		"$not": map[string]interface{}{"archived": true},
+	})
+	require.NoError(t, err)
+
+	b := &matchQueryBuilder{}
+	predicate, err := b.predicate(query)
+	require.NoError(t, err)
+	require.Equal(t, "(clear_metadata @> $1::JSONB AND "+
+		"jsonb_typeof(clear_metadata #> $2::TEXT[]) = $3 AND (clear_metadata #> $2::TEXT[]) > $4::JSONB AND "+
+		"((clear_metadata @> $5::JSONB) OR (clear_metadata @> $6::JSONB)) AND "+
+		"NOT COALESCE((clear_metadata @> $7::JSONB), false))", predicate)
+	require.Len(t, b.args, 7)
+
+	predicate, err = (&matchQueryBuilder{}).predicate(matchQuery{})
+	require.NoError(t, err)
+	require.Equal(t, "true", predicate)
+}
+
+func TestSortedPageRange(t *testing.T) {
+	loc := ObjectLocation{ProjectID: uuid.UUID{1}, BucketName: "testbucket"}
+	sort := &MetadataSort{Key: "capturedAt", Descending: true}
+
+	b := &matchQueryBuilder{}
+	require.Equal(t, "\nAND (project_id, bucket_name, object_key, version) >= ($1, $2, $3, $4)", b.sortedPageRange(loc, sort, ObjectLocation{}))
+	require.Equal(t, "COALESCE(clear_metadata -> $5, 'null'::JSONB) DESC, project_id, bucket_name, object_key, version", b.sortedOrder(sort))
+
+	sort.StartAfter = `"2024-01"`
+	startAfter := ObjectLocation{ProjectID: uuid.UUID{1}, BucketName: "testbucket", ObjectKey: "foo", Version: 1}
+	b = &matchQueryBuilder{}
+	require.Equal(t, "\nAND (project_id, bucket_name, object_key, version) >= ($1, $2, $3, $4)"+
+		"\nAND (COALESCE(clear_metadata -> $5, 'null'::JSONB) < $6::JSONB OR "+
+		"(COALESCE(clear_metadata -> $5, 'null'::JSONB) = $6::JSONB AND (project_id, bucket_name, object_key, version) > ($7, $8, $9, $10)))",
+		b.sortedPageRange(loc, sort, startAfter))
+	require.Len(t, b.args, 10)
+}
+
+func TestIsMissingIndexError(t *testing.T) {
+	err := fmt.Errorf("query failed: %w", &pgconn.PgError{Code: "42704", Message: `index "objects_clear_metadata_idx" not found`})
+	require.True(t, isMissingIndexError(err))
+
+	require.False(t, isMissingIndexError(&pgconn.PgError{Code: "42P01", Message: `relation "objects" does not exist`}))
+	require.False(t, isMissingIndexError(errors.New("index not found")))
+	require.False(t, isMissingIndexError(nil))
+}
+
+func TestParseMatchSystem(t *testing.T) {
+	query, err := parseMatch(map[string]interface{}{
+		"$caseInsensitive": true,
+		"$system": map[string]interface{}{
+			"size":        map[string]interface{}{"$gt": float64(1 << 30)},
+			"createdAt":   map[string]interface{}{"$gte": "2025-03-01T00:00:00Z"},
+			"contentType": map[string]interface{}{"$glob": "video/*"},
+		},
+	})
+	require.NoError(t, err)
+	require.False(t, query.matchesAll())
+	require.Len(t, query.system, 3)
+
+	b := &matchQueryBuilder{}
+	conditions, err := b.conditions(query)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"(clear_metadata ->> 'content-type') LIKE $1::TEXT",
+		"created_at >= $2::TIMESTAMPTZ",
+		"total_plain_size > $3::FLOAT8",
+	}, conditions)
+	require.Equal(t, []interface{}{
+		"video/%",
+		time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		float64(1 << 30),
+	}, b.args)
+
+	// Plain values are compared for equality
+	query, err = parseMatch(map[string]interface{}{
+		"$system": map[string]interface{}{"size": float64(0)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []systemCondition{{attribute: systemAttributes["size"], operator: "=", value: float64(0)}}, query.system)
+
+	// Invalid conditions
+	for _, system := range []interface{}{
+		"size",
+		map[string]interface{}{"foo": float64(1)},
+		map[string]interface{}{"size": "1GB"},
+		map[string]interface{}{"size": map[string]interface{}{"$glob": "1*"}},
+		map[string]interface{}{"createdAt": "yesterday"},
+		map[string]interface{}{"contentType": map[string]interface{}{"$foo": "video/mp4"}},
+	} {
+		_, err = parseMatch(map[string]interface{}{"$system": system})
+		require.ErrorIs(t, err, ErrBadRequest, system)
+	}
+}
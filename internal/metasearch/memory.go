@@ -0,0 +1,937 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"storj.io/common/uuid"
+)
+
+// MemoryRepository is a MetaSearchRepo that keeps metadata in memory, for
+// local development without a metabase. There is no satellite to upload
+// objects: they are created when their metadata is first set, and nothing is
+// ever queued for migration. Everything is lost when the server stops.
+type MemoryRepository struct {
+	mu sync.Mutex
+
+	objects    map[ObjectLocation]ObjectInfo
+	history    map[ObjectLocation][]MetadataRevision
+	revision   int64
+	watermarks map[ObjectLocation]Watermark
+	tombstones map[ObjectLocation]memoryTombstone
+
+	vocabularies map[uuid.UUID]map[string]Vocabulary
+
+	jobs       map[uuid.UUID]Job
+	jobResults map[uuid.UUID][][]byte
+	schedules  map[uuid.UUID]Schedule
+	leases     map[string]lease
+}
+
+// memoryTombstone is the soft deleted metadata of an object.
+type memoryTombstone struct {
+	obj       ObjectInfo
+	deletedAt time.Time
+}
+
+// NewMemoryRepository creates an empty MemoryRepository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		objects:    make(map[ObjectLocation]ObjectInfo),
+		history:    make(map[ObjectLocation][]MetadataRevision),
+		watermarks: make(map[ObjectLocation]Watermark),
+		tombstones: make(map[ObjectLocation]memoryTombstone),
+
+		vocabularies: make(map[uuid.UUID]map[string]Vocabulary),
+
+		jobs:       make(map[uuid.UUID]Job),
+		jobResults: make(map[uuid.UUID][][]byte),
+		schedules:  make(map[uuid.UUID]Schedule),
+		leases:     make(map[string]lease),
+	}
+}
+
+// bucketKeyOf returns the key of the bucket of a location.
+func bucketKeyOf(loc ObjectLocation) ObjectLocation {
+	return ObjectLocation{ProjectID: loc.ProjectID, BucketName: loc.BucketName}
+}
+
+func (r *MemoryRepository) GetMetadata(ctx context.Context, loc ObjectLocation) (ObjectInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	obj, ok := r.objects[objectKeyOf(loc)]
+	if !ok || (loc.Version != 0 && loc.Version != obj.Version) {
+		return ObjectInfo{}, fmt.Errorf("%w: object not found", ErrNotFound)
+	}
+	return cloneObjectInfo(obj), nil
+}
+
+func (r *MemoryRepository) UpdateMetadata(ctx context.Context, loc ObjectLocation, meta ObjectMetadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.update(ctx, loc, meta)
+	return nil
+}
+
+func (r *MemoryRepository) UpdateMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}, meta ObjectMetadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	obj, ok := r.objects[objectKeyOf(loc)]
+	if !ok {
+		return fmt.Errorf("%w: object not found", ErrNotFound)
+	}
+	if obj.MetaSearchQueuedAt != nil || metadataETag(obj.Metadata.ClearMetadata) != metadataETag(expected) {
+		return fmt.Errorf("%w: metadata has been modified", ErrPreconditionFailed)
+	}
+	r.update(ctx, loc, meta)
+	return nil
+}
+
+// update sets the metadata of an object, creating the object if it does not
+// exist, and records the change in the metadata history. r.mu must be held.
+func (r *MemoryRepository) update(ctx context.Context, loc ObjectLocation, meta ObjectMetadata) {
+	key := objectKeyOf(loc)
+	now := time.Now()
+
+	obj, ok := r.objects[key]
+	if !ok {
+		obj = ObjectInfo{
+			ObjectLocation: key,
+			Status:         statusCommittedUnversioned,
+			CreatedAt:      now,
+		}
+		obj.Version = max(loc.Version, 1)
+	}
+	old := obj.Metadata.ClearMetadata
+
+	obj.Metadata = cloneObjectInfo(ObjectInfo{Metadata: meta}).Metadata
+	obj.MetaSearchQueuedAt = nil
+	obj.UpdatedAt = now
+	r.objects[key] = obj
+
+	bucket := bucketKeyOf(key)
+	watermark := r.watermarks[bucket]
+	watermark.Watermark++
+	watermark.UpdatedAt = &now
+	r.watermarks[bucket] = watermark
+
+	r.revision++
+	r.history[key] = append(r.history[key], MetadataRevision{
+		Revision:    r.revision,
+		Version:     obj.Version,
+		Actor:       actorFromContext(ctx),
+		ChangedAt:   now,
+		Watermark:   watermark.Watermark,
+		OldMetadata: old,
+		NewMetadata: cloneObjectInfo(obj).Metadata.ClearMetadata,
+	})
+}
+
+func (r *MemoryRepository) DeleteMetadata(ctx context.Context, loc ObjectLocation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.objects[objectKeyOf(loc)]; !ok {
+		return fmt.Errorf("%w: object not found", ErrNotFound)
+	}
+	r.update(ctx, loc, ObjectMetadata{})
+	return nil
+}
+
+func (r *MemoryRepository) SoftDeleteMetadata(ctx context.Context, loc ObjectLocation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := objectKeyOf(loc)
+	obj, ok := r.objects[key]
+	if !ok {
+		return fmt.Errorf("%w: object not found", ErrNotFound)
+	}
+	r.softDelete(ctx, loc, obj)
+	return nil
+}
+
+func (r *MemoryRepository) SoftDeleteMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := objectKeyOf(loc)
+	obj, ok := r.objects[key]
+	if !ok {
+		return fmt.Errorf("%w: object not found", ErrNotFound)
+	}
+	if metadataETag(obj.Metadata.ClearMetadata) != metadataETag(expected) {
+		return fmt.Errorf("%w: metadata has been modified", ErrPreconditionFailed)
+	}
+	r.softDelete(ctx, loc, obj)
+	return nil
+}
+
+// softDelete keeps a tombstone of the metadata of obj and deletes it. Objects
+// without metadata keep their previous tombstone. r.mu must be held.
+func (r *MemoryRepository) softDelete(ctx context.Context, loc ObjectLocation, obj ObjectInfo) {
+	if obj.Metadata.ClearMetadata != nil || obj.Metadata.EncryptedMetadata != nil {
+		r.tombstones[objectKeyOf(loc)] = memoryTombstone{obj: cloneObjectInfo(obj), deletedAt: time.Now()}
+	}
+	r.update(ctx, loc, ObjectMetadata{})
+}
+
+func (r *MemoryRepository) RestoreMetadata(ctx context.Context, loc ObjectLocation, deletedAfter time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := objectKeyOf(loc)
+	tombstone, ok := r.tombstones[key]
+	if !ok || !tombstone.deletedAt.After(deletedAfter) {
+		return fmt.Errorf("%w: no deleted metadata to restore", ErrNotFound)
+	}
+	if obj, ok := r.objects[key]; !ok || obj.Version != tombstone.obj.Version {
+		return fmt.Errorf("%w: object has been deleted or replaced", ErrNotFound)
+	}
+	r.update(ctx, loc, tombstone.obj.Metadata)
+	delete(r.tombstones, key)
+	return nil
+}
+
+func (r *MemoryRepository) PurgeTombstones(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for key, tombstone := range r.tombstones {
+		if tombstone.deletedAt.Before(deletedBefore) {
+			delete(r.tombstones, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *MemoryRepository) GetMetadataHistory(ctx context.Context, loc ObjectLocation, before int64, limit int) ([]MetadataRevision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := r.history[objectKeyOf(loc)]
+	var revisions []MetadataRevision
+	for i := len(history) - 1; i >= 0 && len(revisions) < limit; i-- {
+		if before == 0 || history[i].Revision < before {
+			revisions = append(revisions, history[i])
+		}
+	}
+	return revisions, nil
+}
+
+func (r *MemoryRepository) GetChanges(ctx context.Context, projectID uuid.UUID, bucket string, afterWatermark int64, afterRevision int64, limit int) ([]MetadataChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var changes []MetadataChange
+	for key, history := range r.history {
+		if key.ProjectID != projectID || key.BucketName != bucket {
+			continue
+		}
+		for _, rev := range history {
+			if rev.Watermark > afterWatermark || (rev.Watermark == afterWatermark && rev.Revision > afterRevision) {
+				changes = append(changes, MetadataChange{ObjectKey: key.ObjectKey, MetadataRevision: rev})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Watermark != changes[j].Watermark {
+			return changes[i].Watermark < changes[j].Watermark
+		}
+		return changes[i].Revision < changes[j].Revision
+	})
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
+func (r *MemoryRepository) DeleteExpiredMetadata(ctx context.Context, limit int) ([]ObjectLocation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx = WithActor(ctx, expirationActor)
+	now := time.Now()
+
+	var deleted []ObjectLocation
+	for key, obj := range r.objects {
+		if len(deleted) >= limit {
+			break
+		}
+		if obj.Metadata.ExpiresAt == nil || obj.Metadata.ExpiresAt.After(now) {
+			continue
+		}
+		r.update(ctx, key, ObjectMetadata{})
+		deleted = append(deleted, obj.ObjectLocation)
+	}
+	return deleted, nil
+}
+
+func (r *MemoryRepository) GetWatermark(ctx context.Context, projectID uuid.UUID, bucket string) (Watermark, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.watermarks[ObjectLocation{ProjectID: projectID, BucketName: bucket}], nil
+}
+
+// matching returns the objects of a bucket, optionally in a subdirectory,
+// that match a query, in key order. r.mu must be held.
+func (r *MemoryRepository) matching(loc ObjectLocation, match matchQuery) []ObjectInfo {
+	now := time.Now()
+
+	var objects []ObjectInfo
+	for key, obj := range r.objects {
+		if key.ProjectID != loc.ProjectID || key.BucketName != loc.BucketName || !strings.HasPrefix(key.ObjectKey, loc.ObjectKey) {
+			continue
+		}
+		if obj.ExpiresAt != nil && !obj.ExpiresAt.After(now) {
+			continue
+		}
+		if !memoryMatches(match, obj) {
+			continue
+		}
+		objects = append(objects, obj)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].ObjectKey < objects[j].ObjectKey
+	})
+	return objects
+}
+
+// memoryMatches returns true if an object is matched by a query.
+func memoryMatches(match matchQuery, obj ObjectInfo) bool {
+	h := &highlighter{highlights: make(map[string]interface{}), object: &obj}
+	return h.query(match, obj.Metadata.ClearMetadata)
+}
+
+func (r *MemoryRepository) QueryMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, order *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int) (QueryMetadataResult, error) {
+	match, err := parseMatch(containsQuery)
+	if err != nil {
+		return QueryMetadataResult{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Snapshots are not kept, later pages read the latest metadata
+	result := QueryMetadataResult{AsOf: asOf}
+	if result.AsOf.IsZero() {
+		result.AsOf = time.Now()
+	}
+
+	objects := r.matching(loc, match)
+	var after interface{}
+	if order != nil {
+		if order.StartAfter != "" {
+			if err := unmarshalJSON([]byte(order.StartAfter), &after); err != nil {
+				return QueryMetadataResult{}, fmt.Errorf("%w: invalid sort value: %v", ErrBadRequest, err)
+			}
+		}
+		sortValue := func(obj ObjectInfo) interface{} {
+			return obj.Metadata.ClearMetadata[order.Key]
+		}
+		sort.SliceStable(objects, func(i, j int) bool {
+			c := compareJSON(sortValue(objects[i]), sortValue(objects[j]))
+			if order.Descending {
+				c = -c
+			}
+			return c < 0
+		})
+	}
+
+	result.Objects = make([]ObjectInfo, 0, batchSize)
+	for _, obj := range objects {
+		if len(result.Objects) >= batchSize {
+			break
+		}
+		if !startAfter.ProjectID.IsZero() {
+			keyAfter := obj.ObjectKey > startAfter.ObjectKey || (obj.ObjectKey == startAfter.ObjectKey && obj.Version > startAfter.Version)
+			if order != nil && order.StartAfter != "" {
+				c := compareJSON(obj.Metadata.ClearMetadata[order.Key], after)
+				if order.Descending {
+					c = -c
+				}
+				keyAfter = c > 0 || (c == 0 && keyAfter)
+			}
+			if !keyAfter {
+				continue
+			}
+		}
+		result.Objects = append(result.Objects, cloneObjectInfo(obj))
+	}
+	return result, nil
+}
+
+func (r *MemoryRepository) QueryMetadataFunc(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, order *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int, fn ObjectFunc) (QueryMetadataResult, error) {
+	result, err := r.QueryMetadata(ctx, loc, containsQuery, order, startAfter, asOf, batchSize)
+	return forEachObject(result, err, fn)
+}
+
+// compareJSON compares decoded JSON values like JSONB: objects are greater
+// than arrays, then booleans, numbers, strings and null.
+func compareJSON(a, b interface{}) int {
+	rank := func(v interface{}) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case string:
+			return 1
+		case float64, json.Number:
+			return 2
+		case bool:
+			return 3
+		case []interface{}:
+			return 4
+		default:
+			return 5
+		}
+	}
+	if c := rank(a) - rank(b); c != 0 {
+		return c
+	}
+
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case float64, json.Number:
+		n, _ := numberValue(a)
+		m, _ := numberValue(b)
+		if n == nil || m == nil {
+			return 0
+		}
+		return n.Cmp(m)
+	case bool:
+		switch {
+		case a == b.(bool):
+			return 0
+		case a:
+			return 1
+		default:
+			return -1
+		}
+	case []interface{}:
+		arr := b.([]interface{})
+		if len(a) != len(arr) {
+			return len(a) - len(arr)
+		}
+		for i := range a {
+			if c := compareJSON(a[i], arr[i]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case nil:
+		return 0
+	default:
+		x, _ := json.Marshal(a)
+		y, _ := json.Marshal(b)
+		return strings.Compare(string(x), string(y))
+	}
+}
+
+func (r *MemoryRepository) CountMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}) (int64, error) {
+	match, err := parseMatch(containsQuery)
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return int64(len(r.matching(loc, match))), nil
+}
+
+func (r *MemoryRepository) AggregateMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, aggregation MetadataAggregation) ([]AggregateGroup, error) {
+	match, err := parseMatch(containsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Without a key, all objects are in a single group, even if there are
+	// none
+	groups := []AggregateGroup{}
+	index := make(map[string]int)
+	if aggregation.GroupBy == "" {
+		groups = append(groups, AggregateGroup{})
+		index["null"] = 0
+	}
+
+	for _, obj := range r.matching(loc, match) {
+		var value interface{}
+		if aggregation.GroupBy != "" {
+			value = obj.Metadata.ClearMetadata[aggregation.GroupBy]
+		}
+		id, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		i, ok := index[string(id)]
+		if !ok {
+			i = len(groups)
+			index[string(id)] = i
+			groups = append(groups, AggregateGroup{Value: cloneMetadataValue(value)})
+		}
+		group := &groups[i]
+		group.Count++
+
+		for _, f := range []struct {
+			keys   []string
+			result *map[string]float64
+			apply  func(current, value float64) float64
+		}{
+			{aggregation.Min, &group.Min, func(current, value float64) float64 { return min(current, value) }},
+			{aggregation.Max, &group.Max, func(current, value float64) float64 { return max(current, value) }},
+			{aggregation.Sum, &group.Sum, func(current, value float64) float64 { return current + value }},
+		} {
+			for _, key := range f.keys {
+				n, ok := numberValue(obj.Metadata.ClearMetadata[key])
+				if !ok {
+					continue
+				}
+				value, _ := n.Float64()
+				if *f.result == nil {
+					*f.result = make(map[string]float64)
+				}
+				if current, ok := (*f.result)[key]; ok {
+					value = f.apply(current, value)
+				}
+				(*f.result)[key] = value
+			}
+		}
+	}
+
+	if aggregation.GroupBy != "" {
+		sort.SliceStable(groups, func(i, j int) bool {
+			return groups[i].Count > groups[j].Count
+		})
+		if len(groups) > aggregation.Limit {
+			groups = groups[:aggregation.Limit]
+		}
+	}
+	return groups, nil
+}
+
+func (r *MemoryRepository) RollupMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, groupBy []string, limit int) ([]RollupRow, error) {
+	match, err := parseMatch(containsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rows []RollupRow
+	index := make(map[string]int)
+	for _, obj := range r.matching(loc, match) {
+		values := make(map[string]interface{}, len(groupBy))
+		for _, key := range groupBy {
+			values[key] = cloneMetadataValue(obj.Metadata.ClearMetadata[key])
+		}
+		id, err := json.Marshal(values)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		i, ok := index[string(id)]
+		if !ok {
+			i = len(rows)
+			index[string(id)] = i
+			rows = append(rows, RollupRow{Values: values})
+		}
+		rows[i].Count++
+		rows[i].Size += obj.Size
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Size > rows[j].Size
+	})
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (r *MemoryRepository) SampleMetadata(ctx context.Context, loc ObjectLocation, limit int) ([]map[string]interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	samples := make([]map[string]interface{}, 0, limit)
+	for _, obj := range r.matching(loc, matchQuery{}) {
+		if len(samples) >= limit {
+			break
+		}
+		if obj.Metadata.ClearMetadata != nil {
+			samples = append(samples, cloneObjectInfo(obj).Metadata.ClearMetadata)
+		}
+	}
+	return samples, nil
+}
+
+func (r *MemoryRepository) TopMetadataValues(ctx context.Context, loc ObjectLocation, key string, prefix string, sample int, limit int) ([]ValueCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int64)
+	sampled := 0
+	for _, obj := range r.matching(ObjectLocation{ProjectID: loc.ProjectID, BucketName: loc.BucketName}, matchQuery{}) {
+		value, ok := obj.Metadata.ClearMetadata[key]
+		if !ok {
+			continue
+		}
+		if sample > 0 && sampled >= sample {
+			break
+		}
+		sampled++
+
+		// Values of arrays, e.g. tags, are counted separately
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, value := range values {
+			if s, ok := value.(string); ok && strings.HasPrefix(s, prefix) {
+				counts[s]++
+			}
+		}
+	}
+
+	values := make([]ValueCount, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, ValueCount{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > limit {
+		values = values[:limit]
+	}
+	return values, nil
+}
+
+func (r *MemoryRepository) QueryProjectsMetadata(ctx context.Context, projectIDs []uuid.UUID, bucket string, containsQuery map[string]interface{}, limit int) ([]ObjectInfo, error) {
+	match, err := parseMatch(containsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var objects []ObjectInfo
+	for _, projectID := range projectIDs {
+		for key, obj := range r.objects {
+			if key.ProjectID != projectID || (bucket != "" && key.BucketName != bucket) {
+				continue
+			}
+			if memoryMatches(match, obj) {
+				objects = append(objects, cloneObjectInfo(obj))
+			}
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		a, b := objects[i], objects[j]
+		if a.ProjectID != b.ProjectID {
+			return a.ProjectID.Less(b.ProjectID)
+		}
+		if a.BucketName != b.BucketName {
+			return a.BucketName < b.BucketName
+		}
+		return a.ObjectKey < b.ObjectKey
+	})
+	if len(objects) > limit {
+		objects = objects[:limit]
+	}
+	return objects, nil
+}
+
+func (r *MemoryRepository) GetVocabularies(ctx context.Context, projectID uuid.UUID) (map[string]Vocabulary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	vocabularies := make(map[string]Vocabulary, len(r.vocabularies[projectID]))
+	for key, vocabulary := range r.vocabularies[projectID] {
+		vocabularies[key] = vocabulary
+	}
+	return vocabularies, nil
+}
+
+func (r *MemoryRepository) SetVocabulary(ctx context.Context, projectID uuid.UUID, key string, vocabulary Vocabulary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.vocabularies[projectID] == nil {
+		r.vocabularies[projectID] = make(map[string]Vocabulary)
+	}
+	r.vocabularies[projectID][key] = vocabulary
+	return nil
+}
+
+func (r *MemoryRepository) DeleteVocabulary(ctx context.Context, projectID uuid.UUID, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.vocabularies[projectID][key]; !ok {
+		return fmt.Errorf("%w: vocabulary not found", ErrNotFound)
+	}
+	delete(r.vocabularies[projectID], key)
+	return nil
+}
+
+func (r *MemoryRepository) CreateJob(ctx context.Context, job Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.jobs[job.ID] = job
+	return nil
+}
+
+func (r *MemoryRepository) GetJob(ctx context.Context, id uuid.UUID) (Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("%w: job not found", ErrNotFound)
+	}
+	return job, nil
+}
+
+func (r *MemoryRepository) ClaimJob(ctx context.Context, id uuid.UUID, now time.Time, leaseExpiresAt time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok || job.State != jobRunning || (job.LeaseExpiresAt != nil && job.LeaseExpiresAt.After(now)) {
+		return 0, fmt.Errorf("%w: job is not running or held by another server", ErrConflict)
+	}
+	job.Attempt++
+	job.LeaseExpiresAt = &leaseExpiresAt
+	job.UpdatedAt = now
+	r.jobs[id] = job
+	return job.Attempt, nil
+}
+
+func (r *MemoryRepository) SaveJobProgress(ctx context.Context, job Job, results []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.jobs[job.ID]
+	if !ok || current.Attempt != job.Attempt || current.State != jobRunning {
+		return fmt.Errorf("%w: job was deleted or claimed by another server", ErrNotFound)
+	}
+	if results != nil {
+		r.jobResults[job.ID] = append(r.jobResults[job.ID], results)
+	}
+	r.jobs[job.ID] = job
+	return nil
+}
+
+func (r *MemoryRepository) GetJobResults(ctx context.Context, id uuid.UUID, fromChunk int64, limit int) ([][]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chunks := r.jobResults[id]
+	if fromChunk >= int64(len(chunks)) {
+		return nil, nil
+	}
+	chunks = chunks[fromChunk:]
+	if len(chunks) > limit {
+		chunks = chunks[:limit]
+	}
+	return chunks, nil
+}
+
+func (r *MemoryRepository) DeleteJob(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.jobs[id]; !ok {
+		return fmt.Errorf("%w: job not found", ErrNotFound)
+	}
+	delete(r.jobs, id)
+	delete(r.jobResults, id)
+	return nil
+}
+
+func (r *MemoryRepository) DeleteExpiredJobs(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for id, job := range r.jobs {
+		if job.ExpiresAt.Before(now) {
+			delete(r.jobs, id)
+			delete(r.jobResults, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *MemoryRepository) CreateSchedule(ctx context.Context, schedule Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.schedules[schedule.ID] = schedule
+	return nil
+}
+
+func (r *MemoryRepository) GetSchedule(ctx context.Context, id uuid.UUID) (Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	schedule, ok := r.schedules[id]
+	if !ok {
+		return Schedule{}, fmt.Errorf("%w: schedule not found", ErrNotFound)
+	}
+	return schedule, nil
+}
+
+func (r *MemoryRepository) ListSchedules(ctx context.Context, projectID uuid.UUID, bucket string) ([]Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var schedules []Schedule
+	for _, schedule := range r.schedules {
+		if schedule.ProjectID == projectID && schedule.BucketName == bucket {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules, nil
+}
+
+func (r *MemoryRepository) ListProjectSchedules(ctx context.Context, projectID uuid.UUID) ([]Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var schedules []Schedule
+	for _, schedule := range r.schedules {
+		if schedule.ProjectID == projectID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		if schedules[i].BucketName != schedules[j].BucketName {
+			return schedules[i].BucketName < schedules[j].BucketName
+		}
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules, nil
+}
+
+func (r *MemoryRepository) DeleteSchedule(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.schedules[id]; !ok {
+		return fmt.Errorf("%w: schedule not found", ErrNotFound)
+	}
+	delete(r.schedules, id)
+	return nil
+}
+
+func (r *MemoryRepository) ClaimDueSchedules(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []Schedule
+	for _, schedule := range r.schedules {
+		if !schedule.NextRunAt.After(now) {
+			due = append(due, schedule)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRunAt.Before(due[j].NextRunAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		due[i].NextRunAt = leaseUntil
+		r.schedules[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+func (r *MemoryRepository) SaveScheduleRun(ctx context.Context, schedule Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.schedules[schedule.ID]
+	if !ok {
+		return nil
+	}
+	current.NextRunAt = schedule.NextRunAt
+	current.LastRunAt = schedule.LastRunAt
+	current.LastStatus = schedule.LastStatus
+	current.LastError = schedule.LastError
+	r.schedules[schedule.ID] = current
+	return nil
+}
+
+// MigrateMetadata always fails, as objects are never queued for migration.
+func (r *MemoryRepository) MigrateMetadata(ctx context.Context, obj ObjectInfo) error {
+	return fmt.Errorf("%w: object not found or has been already migrated", ErrNotFound)
+}
+
+func (r *MemoryRepository) GetObjectsForMigration(ctx context.Context, projectID uuid.UUID, startTime *time.Time, migrate ObjectMigrationFunc) error {
+	return nil
+}
+
+func (r *MemoryRepository) CountObjectsForMigration(ctx context.Context, projectID uuid.UUID, startTime *time.Time) (int64, error) {
+	return 0, nil
+}
+
+// RebuildIndex has nothing to rebuild, searches scan all objects.
+func (r *MemoryRepository) RebuildIndex(ctx context.Context, name string, progress IndexRebuildProgressFunc) error {
+	if _, ok := metadataIndexes[name]; !ok {
+		return fmt.Errorf("%w: unknown index '%s'", ErrNotFound, name)
+	}
+	progress(IndexRebuildDone, 1)
+	return nil
+}
+
+func (r *MemoryRepository) AcquireLease(ctx context.Context, name string, holder uuid.UUID, now time.Time, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l, ok := r.leases[name]; ok && l.holder != holder && l.expiresAt.After(now) {
+		return fmt.Errorf("%w: lease '%s' is held by another server", ErrConflict, name)
+	}
+	r.leases[name] = lease{holder: holder, expiresAt: expiresAt}
+	return nil
+}
+
+func (r *MemoryRepository) ReleaseLease(ctx context.Context, name string, holder uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l, ok := r.leases[name]; ok && l.holder == holder {
+		delete(r.leases, name)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+	"go.uber.org/zap"
+)
+
+func testDevServer(t *testing.T) *Server {
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, NewMemoryRepository(), NewDevAuth(), ServerConfig{})
+	require.NoError(t, err)
+	return server
+}
+
+func TestMemoryRepository(t *testing.T) {
+	server := testDevServer(t)
+
+	for key, metadata := range map[string]string{
+		"a.jpg":     `{"camera": "x100", "iso": 200, "content-type": "image/jpeg"}`,
+		"b.jpg":     `{"camera": "x100", "iso": 800, "content-type": "image/jpeg"}`,
+		"c.png":     `{"camera": "gr3", "iso": 400, "content-type": "image/png"}`,
+		"raw/d.dng": `{"camera": "x100", "iso": 100}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	rr := handleRequest(server, http.MethodGet, "/metadata/testbucket/a.jpg", "")
+	assertResponse(t, rr, http.StatusOK, `{"camera": "x100", "iso": 200, "content-type": "image/jpeg"}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/missing.jpg", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	// Match queries, operators and system attributes are evaluated in memory
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"camera": "x100", "iso": {"$gte": 200}}}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [
+		{"path": "sj://testbucket/a.jpg", "metadata": {"camera": "x100", "iso": 200, "content-type": "image/jpeg"}},
+		{"path": "sj://testbucket/b.jpg", "metadata": {"camera": "x100", "iso": 800, "content-type": "image/jpeg"}}
+	]}`)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"system": {"contentType": {"$glob": "image/p*"}}}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [
+		{"path": "sj://testbucket/c.png", "metadata": {"camera": "gr3", "iso": 400, "content-type": "image/png"}}
+	]}`)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/raw/", `{}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [
+		{"path": "sj://testbucket/raw/d.dng", "metadata": {"camera": "x100", "iso": 100}}
+	]}`)
+
+	// Results are sorted by metadata values and paginated
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"sort": {"key": "iso", "order": "desc"}, "batchSize": 2}`)
+	assertResponse(t, rr, http.StatusOK, "")
+
+	var resp SearchResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Results, 2)
+	require.Equal(t, "sj://testbucket/b.jpg", resp.Results[0].Path)
+	require.Equal(t, "sj://testbucket/c.png", resp.Results[1].Path)
+	require.NotEmpty(t, resp.PageToken)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"sort": {"key": "iso", "order": "desc"}, "batchSize": 2, "pageToken": "`+resp.PageToken+`"}`)
+	assertResponse(t, rr, http.StatusOK, "")
+
+	resp = SearchResponse{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Results, 2)
+	require.Equal(t, "sj://testbucket/a.jpg", resp.Results[0].Path)
+	require.Equal(t, "sj://testbucket/raw/d.dng", resp.Results[1].Path)
+
+	// Deleted metadata is not matched anymore
+	rr = handleRequest(server, http.MethodDelete, "/metadata/testbucket/b.jpg", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"camera": "x100"}}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [
+		{"path": "sj://testbucket/a.jpg", "metadata": {"camera": "x100", "iso": 200, "content-type": "image/jpeg"}},
+		{"path": "sj://testbucket/raw/d.dng", "metadata": {"camera": "x100", "iso": 100}}
+	]}`)
+}
+
+func TestMemoryRepositoryHistory(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	loc := ObjectLocation{ProjectID: DevProjectID, BucketName: "testbucket", ObjectKey: "foo.txt"}
+
+	require.NoError(t, repo.UpdateMetadata(ctx, loc, ObjectMetadata{ClearMetadata: map[string]interface{}{"n": 1}}))
+	require.NoError(t, repo.UpdateMetadata(WithActor(ctx, "test"), loc, ObjectMetadata{ClearMetadata: map[string]interface{}{"n": 2}}))
+
+	err := repo.UpdateMetadataIfMatch(ctx, loc, map[string]interface{}{"n": 1}, ObjectMetadata{})
+	require.ErrorIs(t, err, ErrPreconditionFailed)
+
+	history, err := repo.GetMetadataHistory(ctx, loc, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, history[0].Actor, "test")
+	assert.Equal(t, history[0].OldMetadata, map[string]interface{}{"n": 1})
+	assert.Equal(t, history[0].NewMetadata, map[string]interface{}{"n": 2})
+
+	watermark, err := repo.GetWatermark(ctx, loc.ProjectID, loc.BucketName)
+	require.NoError(t, err)
+	assert.Equal(t, watermark.Watermark, int64(2))
+
+	changes, err := repo.GetChanges(ctx, loc.ProjectID, loc.BucketName, 1, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, changes[0].ObjectKey, "foo.txt")
+}
+
+func TestDevAuth(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/metadata/testbucket/foo.txt", nil)
+	projectID, encryptor, _, err := NewDevAuth().Authenticate(context.Background(), r)
+	require.NoError(t, err)
+	assert.Equal(t, projectID, DevProjectID)
+
+	path, err := encryptor.EncryptPath("testbucket", "foo.txt")
+	require.NoError(t, err)
+	assert.Equal(t, path, "foo.txt")
+
+	r.Header.Set("X-Project-ID", "invalid")
+	_, _, _, err = NewDevAuth().Authenticate(context.Background(), r)
+	require.ErrorIs(t, err, ErrAuthorizationFailed)
+}
+
+func TestQueryMetadataFunc(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	bucket := ObjectLocation{ProjectID: DevProjectID, BucketName: "testbucket"}
+	for _, key := range []string{"a.txt", "b.txt", "c.txt"} {
+		loc := bucket
+		loc.ObjectKey = key
+		require.NoError(t, repo.UpdateMetadata(ctx, loc, ObjectMetadata{ClearMetadata: map[string]interface{}{"foo": "bar"}}))
+	}
+
+	// Objects are passed to the callback instead of being returned
+	var keys []string
+	result, err := repo.QueryMetadataFunc(ctx, bucket, nil, nil, ObjectLocation{}, time.Time{}, 10, func(obj ObjectInfo) error {
+		keys = append(keys, obj.ObjectKey)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Empty(t, result.Objects)
+	require.False(t, result.AsOf.IsZero())
+	require.Equal(t, []string{"a.txt", "b.txt", "c.txt"}, keys)
+
+	// Errors of the callback stop the query
+	keys = nil
+	_, err = repo.QueryMetadataFunc(ctx, bucket, nil, nil, ObjectLocation{}, time.Time{}, 10, func(obj ObjectInfo) error {
+		keys = append(keys, obj.ObjectKey)
+		return errPageFull
+	})
+	require.ErrorIs(t, err, errPageFull)
+	require.Equal(t, []string{"a.txt"}, keys)
+}
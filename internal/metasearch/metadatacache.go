@@ -0,0 +1,216 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// metadataCachingRepo caches the results of GetMetadata per encrypted
+// location, to absorb read storms on hot objects. Cached results are
+// invalidated by the metadata changes made through the repository. Changes
+// made by uplinks or other server instances are only visible once the cached
+// results expire.
+type metadataCachingRepo struct {
+	MetaSearchRepo
+
+	ttl        time.Duration
+	maxEntries int
+
+	mutex   sync.Mutex
+	entries map[ObjectLocation]map[int64]*cachedMetadata
+	size    int
+	// generation is incremented by every invalidation, so that results read
+	// before an invalidation are not cached after it.
+	generation uint64
+}
+
+type cachedMetadata struct {
+	expires time.Time
+	obj     ObjectInfo
+}
+
+func newMetadataCachingRepo(repo MetaSearchRepo, ttl time.Duration, maxEntries int) *metadataCachingRepo {
+	return &metadataCachingRepo{
+		MetaSearchRepo: repo,
+		ttl:            ttl,
+		maxEntries:     maxEntries,
+		entries:        make(map[ObjectLocation]map[int64]*cachedMetadata),
+	}
+}
+
+func (r *metadataCachingRepo) GetMetadata(ctx context.Context, loc ObjectLocation) (ObjectInfo, error) {
+	obj, generation, ok := r.get(loc)
+	if ok {
+		mon.Counter("metadata_cache_hits").Inc(1)
+		return obj, nil
+	}
+
+	mon.Counter("metadata_cache_misses").Inc(1)
+	obj, err := r.MetaSearchRepo.GetMetadata(ctx, loc)
+	if err != nil {
+		return obj, err
+	}
+	r.put(loc, obj, generation)
+	return obj, nil
+}
+
+func (r *metadataCachingRepo) UpdateMetadata(ctx context.Context, loc ObjectLocation, meta ObjectMetadata) error {
+	defer r.invalidate(loc)
+	return r.MetaSearchRepo.UpdateMetadata(ctx, loc, meta)
+}
+
+func (r *metadataCachingRepo) UpdateMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}, meta ObjectMetadata) error {
+	defer r.invalidate(loc)
+	return r.MetaSearchRepo.UpdateMetadataIfMatch(ctx, loc, expected, meta)
+}
+
+func (r *metadataCachingRepo) DeleteMetadata(ctx context.Context, loc ObjectLocation) error {
+	defer r.invalidate(loc)
+	return r.MetaSearchRepo.DeleteMetadata(ctx, loc)
+}
+
+func (r *metadataCachingRepo) SoftDeleteMetadata(ctx context.Context, loc ObjectLocation) error {
+	defer r.invalidate(loc)
+	return r.MetaSearchRepo.SoftDeleteMetadata(ctx, loc)
+}
+
+func (r *metadataCachingRepo) SoftDeleteMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}) error {
+	defer r.invalidate(loc)
+	return r.MetaSearchRepo.SoftDeleteMetadataIfMatch(ctx, loc, expected)
+}
+
+func (r *metadataCachingRepo) RestoreMetadata(ctx context.Context, loc ObjectLocation, deletedAfter time.Time) error {
+	defer r.invalidate(loc)
+	return r.MetaSearchRepo.RestoreMetadata(ctx, loc, deletedAfter)
+}
+
+func (r *metadataCachingRepo) MigrateMetadata(ctx context.Context, obj ObjectInfo) error {
+	defer r.invalidate(obj.ObjectLocation)
+	return r.MetaSearchRepo.MigrateMetadata(ctx, obj)
+}
+
+func (r *metadataCachingRepo) DeleteExpiredMetadata(ctx context.Context, limit int) ([]ObjectLocation, error) {
+	deleted, err := r.MetaSearchRepo.DeleteExpiredMetadata(ctx, limit)
+	for _, loc := range deleted {
+		r.invalidate(loc)
+	}
+	return deleted, err
+}
+
+// get returns a copy of the cached result of a location, or the current
+// generation of the cache if the result is not cached.
+func (r *metadataCachingRepo) get(loc ObjectLocation) (obj ObjectInfo, generation uint64, ok bool) {
+	now := time.Now()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	versions := r.entries[objectKeyOf(loc)]
+	entry, ok := versions[loc.Version]
+	if !ok || !now.Before(entry.expires) {
+		return ObjectInfo{}, r.generation, false
+	}
+	return cloneObjectInfo(entry.obj), 0, true
+}
+
+// put caches a copy of the result of a location, unless the cache was
+// invalidated since generation.
+func (r *metadataCachingRepo) put(loc ObjectLocation, obj ObjectInfo, generation uint64) {
+	now := time.Now()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if generation != r.generation {
+		return
+	}
+	if r.size >= r.maxEntries {
+		r.cleanup(now)
+		if r.size >= r.maxEntries {
+			return
+		}
+	}
+
+	key := objectKeyOf(loc)
+	versions, ok := r.entries[key]
+	if !ok {
+		versions = make(map[int64]*cachedMetadata)
+		r.entries[key] = versions
+	}
+	if _, ok := versions[loc.Version]; !ok {
+		r.size++
+	}
+	versions[loc.Version] = &cachedMetadata{
+		expires: now.Add(r.ttl),
+		obj:     cloneObjectInfo(obj),
+	}
+}
+
+// invalidate removes the cached results of all versions of an object.
+func (r *metadataCachingRepo) invalidate(loc ObjectLocation) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.generation++
+	key := objectKeyOf(loc)
+	r.size -= len(r.entries[key])
+	delete(r.entries, key)
+}
+
+// cleanup removes expired entries. Must be called while r.mutex is locked.
+func (r *metadataCachingRepo) cleanup(now time.Time) {
+	for key, versions := range r.entries {
+		for version, entry := range versions {
+			if !now.Before(entry.expires) {
+				delete(versions, version)
+				r.size--
+			}
+		}
+		if len(versions) == 0 {
+			delete(r.entries, key)
+		}
+	}
+}
+
+// objectKeyOf returns the location of an object without its version.
+func objectKeyOf(loc ObjectLocation) ObjectLocation {
+	loc.Version = 0
+	return loc
+}
+
+// cloneObjectInfo returns a deep copy of an object, so that callers can
+// modify the metadata of cached objects.
+func cloneObjectInfo(obj ObjectInfo) ObjectInfo {
+	obj.Metadata.EncryptedMetadataNonce = bytes.Clone(obj.Metadata.EncryptedMetadataNonce)
+	obj.Metadata.EncryptedMetadata = bytes.Clone(obj.Metadata.EncryptedMetadata)
+	obj.Metadata.EncryptedMetadataKey = bytes.Clone(obj.Metadata.EncryptedMetadataKey)
+	if obj.Metadata.ClearMetadata != nil {
+		obj.Metadata.ClearMetadata = cloneMetadataValue(obj.Metadata.ClearMetadata).(map[string]interface{})
+	}
+	return obj
+}
+
+// cloneMetadataValue returns a deep copy of a decoded JSON value.
+func cloneMetadataValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			clone[key] = cloneMetadataValue(item)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneMetadataValue(item)
+		}
+		return clone
+	default:
+		return v
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+	"go.uber.org/zap"
+)
+
+func TestMetadataCache(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		MetadataCacheTTL:  time.Hour,
+		MetadataCacheSize: 10,
+	})
+	require.NoError(t, err)
+	cache := server.Repo.(*metadataCachingRepo)
+	repo := cache.MetaSearchRepo.(*mockRepo)
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "bar"}`)
+
+	// Changes made outside of the server are hidden by the cache
+	err = repo.DeleteMetadata(context.Background(), ObjectLocation{BucketName: "testbucket", ObjectKey: "enc:foo.txt"})
+	require.NoError(t, err)
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "bar"}`)
+
+	// Changes made through the repository invalidate the cache
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "baz"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "baz"}`)
+
+	rr = handleRequest(server, http.MethodDelete, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
+
+func TestMetadataCacheCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := newMockRepo()
+	cache := newMetadataCachingRepo(repo, time.Hour, 1)
+
+	loc := ObjectLocation{BucketName: "testbucket", ObjectKey: "foo.txt"}
+	require.NoError(t, repo.UpdateMetadata(ctx, loc, ObjectMetadata{
+		ClearMetadata: map[string]interface{}{"tags": []interface{}{"a"}, "nested": map[string]interface{}{"x": 1.0}},
+	}))
+
+	// Cached objects cannot be modified by callers
+	obj, err := cache.GetMetadata(ctx, loc)
+	require.NoError(t, err)
+	obj.Metadata.ClearMetadata["tags"].([]interface{})[0] = "b"
+	obj.Metadata.ClearMetadata["nested"].(map[string]interface{})["x"] = 2.0
+
+	obj, err = cache.GetMetadata(ctx, loc)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"tags": []interface{}{"a"}, "nested": map[string]interface{}{"x": 1.0}}, obj.Metadata.ClearMetadata)
+
+	// Full caches are not filled beyond their size
+	other := ObjectLocation{BucketName: "testbucket", ObjectKey: "bar.txt"}
+	require.NoError(t, repo.UpdateMetadata(ctx, other, ObjectMetadata{ClearMetadata: map[string]interface{}{}}))
+	_, err = cache.GetMetadata(ctx, other)
+	require.NoError(t, err)
+	assert.Equal(t, cache.size, 1)
+
+	// Results read before an invalidation are not cached
+	_, generation, ok := cache.get(other)
+	assert.False(t, ok)
+	cache.invalidate(loc)
+	cache.put(other, obj, generation)
+	_, _, ok = cache.get(other)
+	assert.False(t, ok)
+	assert.Equal(t, cache.size, 0)
+}
@@ -0,0 +1,392 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+
+	"storj.io/common/uuid"
+)
+
+// MetricsRepository records the latency and the errors of every repository
+// call, broken down by method and project, to tell whether slowness comes
+// from searches, updates or the migration queue. Calls that are not bound to
+// a single project, like claiming jobs or purging tombstones, are recorded
+// with the project "none".
+type MetricsRepository struct {
+	MetaSearchRepo
+}
+
+// NewMetricsRepository creates a new MetricsRepository.
+func NewMetricsRepository(repo MetaSearchRepo) *MetricsRepository {
+	return &MetricsRepository{
+		MetaSearchRepo: repo,
+	}
+}
+
+// call runs a repository call, and records its latency and error.
+func (r *MetricsRepository) call(method string, projectID uuid.UUID, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	r.record(method, projectID, time.Since(start), err)
+	return err
+}
+
+func (r *MetricsRepository) record(method string, projectID uuid.UUID, latency time.Duration, err error) {
+	project := "none"
+	if !projectID.IsZero() {
+		project = projectID.String()
+	}
+	tags := []monkit.SeriesTag{
+		monkit.NewSeriesTag("method", method),
+		monkit.NewSeriesTag("project", project),
+	}
+
+	mon.DurationVal("repo_latency", tags...).Observe(latency)
+	mon.Counter("repo_calls", tags...).Inc(1)
+	if err != nil {
+		mon.Counter("repo_errors", tags...).Inc(1)
+	}
+}
+
+func (r *MetricsRepository) GetMetadata(ctx context.Context, loc ObjectLocation) (ObjectInfo, error) {
+	var obj ObjectInfo
+	err := r.call("GetMetadata", loc.ProjectID, func() (err error) {
+		obj, err = r.MetaSearchRepo.GetMetadata(ctx, loc)
+		return err
+	})
+	return obj, err
+}
+
+func (r *MetricsRepository) QueryMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int) (QueryMetadataResult, error) {
+	var result QueryMetadataResult
+	err := r.call("QueryMetadata", loc.ProjectID, func() (err error) {
+		result, err = r.MetaSearchRepo.QueryMetadata(ctx, loc, containsQuery, sort, startAfter, asOf, batchSize)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) QueryMetadataFunc(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int, fn ObjectFunc) (QueryMetadataResult, error) {
+	var result QueryMetadataResult
+	err := r.call("QueryMetadataFunc", loc.ProjectID, func() (err error) {
+		result, err = r.MetaSearchRepo.QueryMetadataFunc(ctx, loc, containsQuery, sort, startAfter, asOf, batchSize, fn)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) CountMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}) (int64, error) {
+	var n int64
+	err := r.call("CountMetadata", loc.ProjectID, func() (err error) {
+		n, err = r.MetaSearchRepo.CountMetadata(ctx, loc, containsQuery)
+		return err
+	})
+	return n, err
+}
+
+func (r *MetricsRepository) AggregateMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, aggregation MetadataAggregation) ([]AggregateGroup, error) {
+	var result []AggregateGroup
+	err := r.call("AggregateMetadata", loc.ProjectID, func() (err error) {
+		result, err = r.MetaSearchRepo.AggregateMetadata(ctx, loc, containsQuery, aggregation)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) RollupMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, groupBy []string, limit int) ([]RollupRow, error) {
+	var result []RollupRow
+	err := r.call("RollupMetadata", loc.ProjectID, func() (err error) {
+		result, err = r.MetaSearchRepo.RollupMetadata(ctx, loc, containsQuery, groupBy, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) SampleMetadata(ctx context.Context, loc ObjectLocation, limit int) ([]map[string]interface{}, error) {
+	var result []map[string]interface{}
+	err := r.call("SampleMetadata", loc.ProjectID, func() (err error) {
+		result, err = r.MetaSearchRepo.SampleMetadata(ctx, loc, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) TopMetadataValues(ctx context.Context, loc ObjectLocation, key string, prefix string, sample int, limit int) ([]ValueCount, error) {
+	var result []ValueCount
+	err := r.call("TopMetadataValues", loc.ProjectID, func() (err error) {
+		result, err = r.MetaSearchRepo.TopMetadataValues(ctx, loc, key, prefix, sample, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) QueryProjectsMetadata(ctx context.Context, projectIDs []uuid.UUID, bucket string, containsQuery map[string]interface{}, limit int) ([]ObjectInfo, error) {
+	var result []ObjectInfo
+	err := r.call("QueryProjectsMetadata", uuid.UUID{}, func() (err error) {
+		result, err = r.MetaSearchRepo.QueryProjectsMetadata(ctx, projectIDs, bucket, containsQuery, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) UpdateMetadata(ctx context.Context, loc ObjectLocation, meta ObjectMetadata) error {
+	return r.call("UpdateMetadata", loc.ProjectID, func() error {
+		return r.MetaSearchRepo.UpdateMetadata(ctx, loc, meta)
+	})
+}
+
+func (r *MetricsRepository) UpdateMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}, meta ObjectMetadata) error {
+	return r.call("UpdateMetadataIfMatch", loc.ProjectID, func() error {
+		return r.MetaSearchRepo.UpdateMetadataIfMatch(ctx, loc, expected, meta)
+	})
+}
+
+func (r *MetricsRepository) DeleteMetadata(ctx context.Context, loc ObjectLocation) error {
+	return r.call("DeleteMetadata", loc.ProjectID, func() error {
+		return r.MetaSearchRepo.DeleteMetadata(ctx, loc)
+	})
+}
+
+func (r *MetricsRepository) SoftDeleteMetadata(ctx context.Context, loc ObjectLocation) error {
+	return r.call("SoftDeleteMetadata", loc.ProjectID, func() error {
+		return r.MetaSearchRepo.SoftDeleteMetadata(ctx, loc)
+	})
+}
+
+func (r *MetricsRepository) SoftDeleteMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}) error {
+	return r.call("SoftDeleteMetadataIfMatch", loc.ProjectID, func() error {
+		return r.MetaSearchRepo.SoftDeleteMetadataIfMatch(ctx, loc, expected)
+	})
+}
+
+func (r *MetricsRepository) RestoreMetadata(ctx context.Context, loc ObjectLocation, deletedAfter time.Time) error {
+	return r.call("RestoreMetadata", loc.ProjectID, func() error {
+		return r.MetaSearchRepo.RestoreMetadata(ctx, loc, deletedAfter)
+	})
+}
+
+func (r *MetricsRepository) PurgeTombstones(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+	err := r.call("PurgeTombstones", uuid.UUID{}, func() (err error) {
+		n, err = r.MetaSearchRepo.PurgeTombstones(ctx, deletedBefore)
+		return err
+	})
+	return n, err
+}
+
+func (r *MetricsRepository) GetMetadataHistory(ctx context.Context, loc ObjectLocation, before int64, limit int) ([]MetadataRevision, error) {
+	var result []MetadataRevision
+	err := r.call("GetMetadataHistory", loc.ProjectID, func() (err error) {
+		result, err = r.MetaSearchRepo.GetMetadataHistory(ctx, loc, before, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) GetChanges(ctx context.Context, projectID uuid.UUID, bucket string, afterWatermark int64, afterRevision int64, limit int) ([]MetadataChange, error) {
+	var result []MetadataChange
+	err := r.call("GetChanges", projectID, func() (err error) {
+		result, err = r.MetaSearchRepo.GetChanges(ctx, projectID, bucket, afterWatermark, afterRevision, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) DeleteExpiredMetadata(ctx context.Context, limit int) ([]ObjectLocation, error) {
+	var result []ObjectLocation
+	err := r.call("DeleteExpiredMetadata", uuid.UUID{}, func() (err error) {
+		result, err = r.MetaSearchRepo.DeleteExpiredMetadata(ctx, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) GetWatermark(ctx context.Context, projectID uuid.UUID, bucket string) (Watermark, error) {
+	var watermark Watermark
+	err := r.call("GetWatermark", projectID, func() (err error) {
+		watermark, err = r.MetaSearchRepo.GetWatermark(ctx, projectID, bucket)
+		return err
+	})
+	return watermark, err
+}
+
+func (r *MetricsRepository) GetVocabularies(ctx context.Context, projectID uuid.UUID) (map[string]Vocabulary, error) {
+	var result map[string]Vocabulary
+	err := r.call("GetVocabularies", projectID, func() (err error) {
+		result, err = r.MetaSearchRepo.GetVocabularies(ctx, projectID)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) SetVocabulary(ctx context.Context, projectID uuid.UUID, key string, vocabulary Vocabulary) error {
+	return r.call("SetVocabulary", projectID, func() error {
+		return r.MetaSearchRepo.SetVocabulary(ctx, projectID, key, vocabulary)
+	})
+}
+
+func (r *MetricsRepository) DeleteVocabulary(ctx context.Context, projectID uuid.UUID, key string) error {
+	return r.call("DeleteVocabulary", projectID, func() error {
+		return r.MetaSearchRepo.DeleteVocabulary(ctx, projectID, key)
+	})
+}
+
+func (r *MetricsRepository) CreateJob(ctx context.Context, job Job) error {
+	return r.call("CreateJob", job.ProjectID, func() error {
+		return r.MetaSearchRepo.CreateJob(ctx, job)
+	})
+}
+
+func (r *MetricsRepository) GetJob(ctx context.Context, id uuid.UUID) (Job, error) {
+	var job Job
+	err := r.call("GetJob", uuid.UUID{}, func() (err error) {
+		job, err = r.MetaSearchRepo.GetJob(ctx, id)
+		return err
+	})
+	return job, err
+}
+
+func (r *MetricsRepository) ClaimJob(ctx context.Context, id uuid.UUID, now time.Time, leaseExpiresAt time.Time) (int64, error) {
+	var n int64
+	err := r.call("ClaimJob", uuid.UUID{}, func() (err error) {
+		n, err = r.MetaSearchRepo.ClaimJob(ctx, id, now, leaseExpiresAt)
+		return err
+	})
+	return n, err
+}
+
+func (r *MetricsRepository) SaveJobProgress(ctx context.Context, job Job, results []byte) error {
+	return r.call("SaveJobProgress", job.ProjectID, func() error {
+		return r.MetaSearchRepo.SaveJobProgress(ctx, job, results)
+	})
+}
+
+func (r *MetricsRepository) GetJobResults(ctx context.Context, id uuid.UUID, fromChunk int64, limit int) ([][]byte, error) {
+	var result [][]byte
+	err := r.call("GetJobResults", uuid.UUID{}, func() (err error) {
+		result, err = r.MetaSearchRepo.GetJobResults(ctx, id, fromChunk, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) DeleteJob(ctx context.Context, id uuid.UUID) error {
+	return r.call("DeleteJob", uuid.UUID{}, func() error {
+		return r.MetaSearchRepo.DeleteJob(ctx, id)
+	})
+}
+
+func (r *MetricsRepository) DeleteExpiredJobs(ctx context.Context, now time.Time) (int64, error) {
+	var n int64
+	err := r.call("DeleteExpiredJobs", uuid.UUID{}, func() (err error) {
+		n, err = r.MetaSearchRepo.DeleteExpiredJobs(ctx, now)
+		return err
+	})
+	return n, err
+}
+
+func (r *MetricsRepository) CreateSchedule(ctx context.Context, schedule Schedule) error {
+	return r.call("CreateSchedule", schedule.ProjectID, func() error {
+		return r.MetaSearchRepo.CreateSchedule(ctx, schedule)
+	})
+}
+
+func (r *MetricsRepository) GetSchedule(ctx context.Context, id uuid.UUID) (Schedule, error) {
+	var schedule Schedule
+	err := r.call("GetSchedule", uuid.UUID{}, func() (err error) {
+		schedule, err = r.MetaSearchRepo.GetSchedule(ctx, id)
+		return err
+	})
+	return schedule, err
+}
+
+func (r *MetricsRepository) ListSchedules(ctx context.Context, projectID uuid.UUID, bucket string) ([]Schedule, error) {
+	var result []Schedule
+	err := r.call("ListSchedules", projectID, func() (err error) {
+		result, err = r.MetaSearchRepo.ListSchedules(ctx, projectID, bucket)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) ListProjectSchedules(ctx context.Context, projectID uuid.UUID) ([]Schedule, error) {
+	var result []Schedule
+	err := r.call("ListProjectSchedules", projectID, func() (err error) {
+		result, err = r.MetaSearchRepo.ListProjectSchedules(ctx, projectID)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) DeleteSchedule(ctx context.Context, id uuid.UUID) error {
+	return r.call("DeleteSchedule", uuid.UUID{}, func() error {
+		return r.MetaSearchRepo.DeleteSchedule(ctx, id)
+	})
+}
+
+func (r *MetricsRepository) ClaimDueSchedules(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]Schedule, error) {
+	var result []Schedule
+	err := r.call("ClaimDueSchedules", uuid.UUID{}, func() (err error) {
+		result, err = r.MetaSearchRepo.ClaimDueSchedules(ctx, now, leaseUntil, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *MetricsRepository) SaveScheduleRun(ctx context.Context, schedule Schedule) error {
+	return r.call("SaveScheduleRun", schedule.ProjectID, func() error {
+		return r.MetaSearchRepo.SaveScheduleRun(ctx, schedule)
+	})
+}
+
+func (r *MetricsRepository) MigrateMetadata(ctx context.Context, obj ObjectInfo) error {
+	return r.call("MigrateMetadata", obj.ProjectID, func() error {
+		return r.MetaSearchRepo.MigrateMetadata(ctx, obj)
+	})
+}
+
+func (r *MetricsRepository) CountObjectsForMigration(ctx context.Context, projectID uuid.UUID, startTime *time.Time) (int64, error) {
+	var n int64
+	err := r.call("CountObjectsForMigration", projectID, func() (err error) {
+		n, err = r.MetaSearchRepo.CountObjectsForMigration(ctx, projectID, startTime)
+		return err
+	})
+	return n, err
+}
+
+// GetObjectsForMigration records the time spent reading the migration queue,
+// without the time spent migrating the objects.
+func (r *MetricsRepository) GetObjectsForMigration(ctx context.Context, projectID uuid.UUID, startTime *time.Time, migrate ObjectMigrationFunc) error {
+	start := time.Now()
+	var migrating time.Duration
+	err := r.MetaSearchRepo.GetObjectsForMigration(ctx, projectID, startTime, func(ctx context.Context, obj ObjectInfo) bool {
+		migrateStart := time.Now()
+		defer func() { migrating += time.Since(migrateStart) }()
+		return migrate(ctx, obj)
+	})
+	r.record("GetObjectsForMigration", projectID, time.Since(start)-migrating, err)
+	return err
+}
+
+func (r *MetricsRepository) RebuildIndex(ctx context.Context, name string, progress IndexRebuildProgressFunc) error {
+	return r.call("RebuildIndex", uuid.UUID{}, func() error {
+		return r.MetaSearchRepo.RebuildIndex(ctx, name, progress)
+	})
+}
+
+func (r *MetricsRepository) AcquireLease(ctx context.Context, name string, holder uuid.UUID, now time.Time, expiresAt time.Time) error {
+	return r.call("AcquireLease", uuid.UUID{}, func() error {
+		return r.MetaSearchRepo.AcquireLease(ctx, name, holder, now, expiresAt)
+	})
+}
+
+func (r *MetricsRepository) ReleaseLease(ctx context.Context, name string, holder uuid.UUID) error {
+	return r.call("ReleaseLease", uuid.UUID{}, func() error {
+		return r.MetaSearchRepo.ReleaseLease(ctx, name, holder)
+	})
+}
@@ -0,0 +1,39 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/uuid"
+)
+
+func TestMetricsRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMetricsRepository(newMockRepo())
+
+	projectID, err := uuid.New()
+	require.NoError(t, err)
+	loc := ObjectLocation{ProjectID: projectID, BucketName: "testbucket", ObjectKey: "foo.txt"}
+
+	require.NoError(t, repo.UpdateMetadata(ctx, loc, ObjectMetadata{ClearMetadata: map[string]interface{}{"n": 1}}))
+	obj, err := repo.GetMetadata(ctx, loc)
+	require.NoError(t, err)
+	require.Equal(t, 1, obj.Metadata.ClearMetadata["n"])
+
+	_, err = repo.GetMetadata(ctx, ObjectLocation{ProjectID: projectID, BucketName: "testbucket", ObjectKey: "missing.txt"})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Calls and errors are counted per method and project
+	stats := monkit.Collect(mon)
+	tags := ",method=GetMetadata,project=" + projectID.String()
+	require.Equal(t, 2.0, stats["repo_calls"+tags+" value"])
+	require.Equal(t, 1.0, stats["repo_errors"+tags+" value"])
+	require.Equal(t, 1.0, stats["repo_calls,method=UpdateMetadata,project="+projectID.String()+" value"])
+	require.Contains(t, stats, "repo_latency"+tags+" count")
+}
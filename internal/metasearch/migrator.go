@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -17,15 +18,20 @@ import (
 const migrationInterval = 1 * time.Second
 const maxEncryptorsPerProject = 100
 
+// migrationActor is the actor of metadata migrations in the history.
+const migrationActor = "migration"
+
 // ObjectMigrator manages encryptors and migrates the encrypted metadata to
 // clear metadata in the background.
 type ObjectMigrator struct {
-	log     *zap.Logger
-	repo    MetaSearchRepo
-	workers map[uuid.UUID]*ObjectMigratorWorker
-	mutex   *sync.Mutex
-	running bool
-	done    chan bool
+	log         *zap.Logger
+	repo        MetaSearchRepo
+	extractions *extractionQueue
+	workers     map[uuid.UUID]*ObjectMigratorWorker
+	mutex       *sync.Mutex
+	running     bool
+	paused      atomic.Bool
+	done        chan bool
 }
 
 // NewObjectMigrator creates an ObjectMigrator instance.
@@ -39,6 +45,16 @@ func NewObjectMigrator(log *zap.Logger, repo MetaSearchRepo) *ObjectMigrator {
 	}
 }
 
+// SetExtractor sets the extractor that derives extra metadata from the
+// content of migrated objects. Extractions run in the background, after the
+// migration of the object. It must be called before adding projects.
+func (m *ObjectMigrator) SetExtractor(extractor MetadataExtractor) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.extractions = newExtractionQueue(m.log, m.repo, extractor)
+}
+
 // AddProject starts a worker for the given project if it does not exist, and adds the encryptor to it.
 func (m *ObjectMigrator) AddProject(ctx context.Context, projectID uuid.UUID, encryptor Encryptor) {
 	m.mutex.Lock()
@@ -50,6 +66,7 @@ func (m *ObjectMigrator) AddProject(ctx context.Context, projectID uuid.UUID, en
 	}
 
 	worker := NewObjectMigratorWorker(m.log, m.repo, projectID)
+	worker.extractions = m.extractions
 	worker.AddEncryptor(encryptor)
 	m.workers[projectID] = worker
 }
@@ -60,6 +77,9 @@ func (m *ObjectMigrator) Start() {
 	go func() {
 		for m.running {
 			time.Sleep(migrationInterval)
+			if m.paused.Load() {
+				continue
+			}
 
 			m.mutex.Lock()
 			for _, worker := range m.workers {
@@ -71,6 +91,12 @@ func (m *ObjectMigrator) Start() {
 	}()
 }
 
+// SetPaused pauses or resumes the background migrations. Running migrations
+// are not interrupted.
+func (m *ObjectMigrator) SetPaused(paused bool) {
+	m.paused.Store(paused)
+}
+
 // Stop object migrator, wait until it finishes all pending migrations.
 func (m *ObjectMigrator) Stop() {
 	if !m.running {
@@ -110,17 +136,48 @@ func (m *ObjectMigrator) MigrateObject(ctx context.Context, obj *ObjectInfo) err
 	return worker.MigrateObject(ctx, obj)
 }
 
+// MigrateProjectWith migrates the queued objects of a project with a single
+// encryptor, without keeping a worker or the encryptor for later requests. It
+// returns true if the migration has completed before the timeout.
+func (m *ObjectMigrator) MigrateProjectWith(ctx context.Context, projectID uuid.UUID, encryptor Encryptor, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return m.newWorker(projectID, encryptor).MigrateProject(ctx) == nil
+}
+
+// MigrateObjectWith migrates a single object with the given encryptor,
+// without keeping it for later requests.
+func (m *ObjectMigrator) MigrateObjectWith(ctx context.Context, obj *ObjectInfo, encryptor Encryptor) error {
+	return m.newWorker(obj.ProjectID, encryptor).MigrateObject(ctx, obj)
+}
+
+// newWorker creates a worker that is not managed by the migrator.
+func (m *ObjectMigrator) newWorker(projectID uuid.UUID, encryptor Encryptor) *ObjectMigratorWorker {
+	m.mutex.Lock()
+	extractions := m.extractions
+	m.mutex.Unlock()
+
+	worker := NewObjectMigratorWorker(m.log, m.repo, projectID)
+	worker.extractions = extractions
+	worker.encryptors.AddEncryptor(encryptor)
+	return worker
+}
+
 // ObjectMigratorWorker migrates objects for a single ProjectID.
 type ObjectMigratorWorker struct {
 	log       *zap.Logger
 	repo      MetaSearchRepo
 	projectID uuid.UUID
 
+	extractions *extractionQueue
+
 	mutex       *sync.Mutex
 	running     bool
 	encryptors  *EncryptorRepository
 	subscribers []chan bool
 	startTime   *time.Time
+	progress    migrationProgress
 }
 
 // NewObjectMigratorWorker creates a new object migrator worker.
@@ -200,10 +257,19 @@ func (w *ObjectMigratorWorker) Start() {
 }
 
 func (w *ObjectMigratorWorker) MigrateProject(ctx context.Context) error {
-	err := w.repo.GetObjectsForMigration(ctx, w.projectID, w.startTime, func(ctx context.Context, obj ObjectInfo) bool {
-		_ = w.MigrateObject(ctx, &obj)
+	startTime := w.startTime
+	processed := false
+	err := w.repo.GetObjectsForMigration(ctx, w.projectID, startTime, func(ctx context.Context, obj ObjectInfo) bool {
+		if !processed {
+			w.startRun(ctx, startTime)
+			processed = true
+		}
+		w.recordMigration(w.MigrateObject(ctx, &obj))
 		return true
 	})
+	if processed {
+		w.finishRun(err == nil)
+	}
 
 	if err != nil {
 		w.log.Warn("error migrating project",
@@ -223,7 +289,7 @@ func (w *ObjectMigratorWorker) MigrateObject(ctx context.Context, obj *ObjectInf
 	}
 
 	// Decrypt path and metadata
-	clearObjectKey, meta, err := w.encryptors.DecryptMetadata(obj)
+	clearObjectKey, meta, encryptor, err := w.encryptors.DecryptMetadata(obj)
 	if err != nil {
 		w.log.Warn(err.Error(),
 			zap.Stringer("Project", obj.ProjectID),
@@ -259,6 +325,11 @@ func (w *ObjectMigratorWorker) MigrateObject(ctx context.Context, obj *ObjectInf
 		zap.String("ObjectKey", clearObjectKey),
 	)
 
+	// Extract metadata from the object content in the background
+	if w.extractions != nil {
+		w.extractions.enqueue(*obj, clearObjectKey, encryptor)
+	}
+
 	w.updateStartTime(obj)
 	return nil
 }
@@ -270,3 +341,18 @@ func (w *ObjectMigratorWorker) updateStartTime(obj *ObjectInfo) {
 	}
 	w.mutex.Unlock()
 }
+
+// migrateObject migrates an object read by a request if it is queued for
+// migration. Errors are ignored: the object is returned as is, and remains in
+// the queue.
+func (s *Server) migrateObject(ctx context.Context, obj *ObjectInfo, encryptor Encryptor) {
+	if obj.MetaSearchQueuedAt == nil || s.ReadOnly() {
+		return
+	}
+
+	if s.Config.Stateless {
+		_ = s.Migrator.MigrateObjectWith(ctx, obj, encryptor)
+		return
+	}
+	_ = s.Migrator.MigrateObject(ctx, obj)
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"io"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/arrow/go/v15/parquet"
+	"github.com/apache/arrow/go/v15/parquet/compress"
+	"github.com/apache/arrow/go/v15/parquet/pqarrow"
+)
+
+const parquetContentType = "application/vnd.apache.parquet"
+
+// parquetWriter exports search results as a Parquet file with a nullable
+// string column for the path and each metadata column. Each page of results
+// is written as a row group.
+type parquetWriter struct {
+	fw      *pqarrow.FileWriter
+	builder *array.RecordBuilder
+	paths   [][]string
+}
+
+func newParquetWriter(w io.Writer, columns []string) (exportWriter, error) {
+	fields := make([]arrow.Field, 0, len(columns)+1)
+	fields = append(fields, arrow.Field{Name: "path", Type: arrow.BinaryTypes.String, Nullable: true})
+	for _, column := range columns {
+		fields = append(fields, arrow.Field{Name: column, Type: arrow.BinaryTypes.String, Nullable: true})
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+	fw, err := pqarrow.NewFileWriter(schema, w, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		return nil, err
+	}
+	return &parquetWriter{
+		fw:      fw,
+		builder: array.NewRecordBuilder(memory.DefaultAllocator, schema),
+		paths:   columnPaths(columns),
+	}, nil
+}
+
+func (p *parquetWriter) write(results []SearchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	paths := p.builder.Field(0).(*array.StringBuilder)
+	for _, res := range results {
+		if res.Path == "" {
+			paths.AppendNull()
+		} else {
+			paths.Append(res.Path)
+		}
+
+		values, found := columnValues(res, p.paths)
+		for i, value := range values {
+			column := p.builder.Field(i + 1).(*array.StringBuilder)
+			s, ok := exportValue(value)
+			if !found[i] || !ok {
+				column.AppendNull()
+				continue
+			}
+			column.Append(s)
+		}
+	}
+
+	record := p.builder.NewRecord()
+	defer record.Release()
+	return p.fw.Write(record)
+}
+
+func (p *parquetWriter) close() error {
+	p.builder.Release()
+	return p.fw.Close()
+}
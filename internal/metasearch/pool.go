@@ -0,0 +1,65 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+
+	"storj.io/storj/shared/tagsql"
+)
+
+// PoolConfig configures the connection pool of a database.
+type PoolConfig struct {
+	// MaxOpenConns is the maximum number of open connections, unlimited if
+	// 0.
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections kept open, none
+	// if negative.
+	MaxIdleConns int
+	// ConnMaxLifetime is the duration after which connections are closed,
+	// unlimited if 0.
+	ConnMaxLifetime time.Duration
+	// DialTimeout is the timeout of opening a connection, unlimited if 0.
+	DialTimeout time.Duration
+}
+
+// Configure applies the limits of the pool to db.
+func (c PoolConfig) Configure(db tagsql.DB) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+}
+
+// DialURL returns the database URL with the dial timeout, as the
+// connect_timeout parameter understood by PostgreSQL and CockroachDB. URLs of
+// other databases, and URLs that already set a timeout, are left unchanged.
+func (c PoolConfig) DialURL(databaseURL string) (string, error) {
+	if c.DialTimeout <= 0 {
+		return databaseURL, nil
+	}
+
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid database URL: %w", err)
+	}
+	switch u.Scheme {
+	case "postgres", "postgresql", "cockroach":
+	default:
+		return databaseURL, nil
+	}
+
+	query := u.Query()
+	if query.Has("connect_timeout") {
+		return databaseURL, nil
+	}
+	// The timeout is in whole seconds
+	seconds := int64(math.Ceil(c.DialTimeout.Seconds()))
+	query.Set("connect_timeout", strconv.FormatInt(seconds, 10))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,38 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolConfigDialURL(t *testing.T) {
+	pool := PoolConfig{DialTimeout: 1500 * time.Millisecond}
+
+	// The timeout is rounded up to whole seconds
+	dialURL, err := pool.DialURL("postgres://user@localhost:5432/metabase?sslmode=disable")
+	require.NoError(t, err)
+	require.Equal(t, "postgres://user@localhost:5432/metabase?connect_timeout=2&sslmode=disable", dialURL)
+
+	dialURL, err = pool.DialURL("cockroach://root@localhost:26257/metabase")
+	require.NoError(t, err)
+	require.Equal(t, "cockroach://root@localhost:26257/metabase?connect_timeout=2", dialURL)
+
+	// Explicit timeouts are kept
+	dialURL, err = pool.DialURL("postgres://localhost/metabase?connect_timeout=30")
+	require.NoError(t, err)
+	require.Equal(t, "postgres://localhost/metabase?connect_timeout=30", dialURL)
+
+	// Other databases are left unchanged
+	dialURL, err = pool.DialURL("spanner://projects/p/instances/i/databases/d")
+	require.NoError(t, err)
+	require.Equal(t, "spanner://projects/p/instances/i/databases/d", dialURL)
+
+	dialURL, err = PoolConfig{}.DialURL("postgres://localhost/metabase")
+	require.NoError(t, err)
+	require.Equal(t, "postgres://localhost/metabase", dialURL)
+}
@@ -0,0 +1,228 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+)
+
+// MigrationProgress describes the progress of the metadata migration of a
+// project.
+type MigrationProgress struct {
+	ProjectID uuid.UUID `json:"projectId"`
+
+	// Running is true while the worker processes the migration queue.
+	Running bool `json:"running"`
+
+	// Remaining is the number of objects left in the migration queue,
+	// counted at the start of the current run.
+	Remaining int64 `json:"remaining"`
+
+	// Migrated and Failed are the number of objects processed since the
+	// worker was started.
+	Migrated int64 `json:"migrated"`
+	Failed   int64 `json:"failed"`
+
+	// Throughput is the number of objects processed per second in the
+	// current or last run.
+	Throughput float64 `json:"throughput"`
+
+	// EstimatedCompletion is the time at which the migration queue is
+	// expected to be empty. Only set while the worker is running.
+	EstimatedCompletion *time.Time `json:"estimatedCompletion,omitempty"`
+}
+
+// migrationProgress tracks the progress of a migration worker. It is
+// protected by the mutex of the worker.
+type migrationProgress struct {
+	remaining int64
+	migrated  int64
+	failed    int64
+
+	runStarted   time.Time
+	runFinished  time.Time
+	runProcessed int64
+}
+
+func (r *MetabaseSearchRepository) CountObjectsForMigration(ctx context.Context, projectID uuid.UUID, startTime *time.Time) (int64, error) {
+	query := `
+		SELECT count(*)
+		FROM ` + r.table(defaultQueuedAtIndex) + `
+		WHERE
+			project_id=$1 AND
+			metasearch_queued_at IS NOT NULL
+	`
+	args := []interface{}{projectID}
+
+	if startTime != nil {
+		query += " AND metasearch_queued_at >= $2 "
+		args = append(args, *startTime)
+	}
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	return count, nil
+}
+
+// startRun counts the objects in the migration queue when a run processes its
+// first object. Idle runs do not query the queue size.
+func (w *ObjectMigratorWorker) startRun(ctx context.Context, startTime *time.Time) {
+	remaining, err := w.repo.CountObjectsForMigration(ctx, w.projectID, startTime)
+	if err != nil {
+		w.log.Warn("cannot count objects for migration", zap.Stringer("Project", w.projectID), zap.Error(err))
+		remaining = 0
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.progress.remaining = remaining
+	w.progress.runStarted = time.Now()
+	w.progress.runFinished = time.Time{}
+	w.progress.runProcessed = 0
+	w.reportProgress()
+}
+
+// finishRun records the end of a run that processed at least one object. If
+// the run went through the whole queue, no objects are left to migrate.
+func (w *ObjectMigratorWorker) finishRun(completed bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if completed {
+		w.progress.remaining = 0
+	}
+	w.progress.runFinished = time.Now()
+	w.reportProgress()
+}
+
+// recordMigration records the result of the migration of an object in the
+// current run.
+func (w *ObjectMigratorWorker) recordMigration(err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.progress.runProcessed++
+	if w.progress.remaining > 0 {
+		w.progress.remaining--
+	}
+
+	tag := monkit.NewSeriesTag("project", w.projectID.String())
+	if err != nil {
+		w.progress.failed++
+		mon.Counter("migration_failed", tag).Inc(1)
+	} else {
+		w.progress.migrated++
+		mon.Counter("migration_migrated", tag).Inc(1)
+	}
+}
+
+// reportProgress publishes the queue size and throughput of the worker as
+// metrics. Must be called while w.mutex is locked.
+func (w *ObjectMigratorWorker) reportProgress() {
+	progress := w.getProgress()
+	tag := monkit.NewSeriesTag("project", w.projectID.String())
+	mon.IntVal("migration_remaining", tag).Observe(progress.Remaining)
+	mon.FloatVal("migration_throughput", tag).Observe(progress.Throughput)
+	if progress.EstimatedCompletion != nil {
+		mon.FloatVal("migration_eta_seconds", tag).Observe(time.Until(*progress.EstimatedCompletion).Seconds())
+	}
+}
+
+// Progress returns the migration progress of the worker.
+func (w *ObjectMigratorWorker) Progress() MigrationProgress {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.getProgress()
+}
+
+// getProgress computes the migration progress. Must be called while w.mutex
+// is locked.
+func (w *ObjectMigratorWorker) getProgress() MigrationProgress {
+	p := &w.progress
+	progress := MigrationProgress{
+		ProjectID: w.projectID,
+		Running:   w.running,
+		Remaining: p.remaining,
+		Migrated:  p.migrated,
+		Failed:    p.failed,
+	}
+
+	if !p.runStarted.IsZero() {
+		end := p.runFinished
+		if end.IsZero() {
+			end = time.Now()
+		}
+		if elapsed := end.Sub(p.runStarted).Seconds(); elapsed > 0 {
+			progress.Throughput = float64(p.runProcessed) / elapsed
+		}
+	}
+
+	if w.running && p.runFinished.IsZero() && progress.Throughput > 0 && p.remaining > 0 {
+		eta := time.Now().Add(time.Duration(float64(p.remaining) / progress.Throughput * float64(time.Second)))
+		progress.EstimatedCompletion = &eta
+	}
+
+	return progress
+}
+
+// Progress returns the migration progress of a project, or of all projects if
+// projectID is zero.
+func (m *ObjectMigrator) Progress(projectID uuid.UUID) []MigrationProgress {
+	m.mutex.Lock()
+	workers := make([]*ObjectMigratorWorker, 0, len(m.workers))
+	for id, worker := range m.workers {
+		if projectID.IsZero() || id == projectID {
+			workers = append(workers, worker)
+		}
+	}
+	m.mutex.Unlock()
+
+	result := make([]MigrationProgress, 0, len(workers))
+	for _, worker := range workers {
+		result = append(result, worker.Progress())
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].ProjectID[:], result[j].ProjectID[:]) < 0
+	})
+	return result
+}
+
+// HandleAdminMigrations reports the progress of the metadata migration per
+// project. The list can be filtered with the projectId query parameter.
+func (s *Server) HandleAdminMigrations(w http.ResponseWriter, r *http.Request) {
+	if s.Config.Stateless {
+		s.errorResponse(w, fmt.Errorf("%w: migration progress is not available in stateless mode", ErrNotFound))
+		return
+	}
+
+	var projectID uuid.UUID
+	if id := r.URL.Query().Get("projectId"); id != "" {
+		var err error
+		projectID, err = uuid.FromString(id)
+		if err != nil {
+			s.errorResponse(w, fmt.Errorf("%w: invalid projectId", ErrBadRequest))
+			return
+		}
+	}
+
+	s.jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"projects": s.Migrator.Progress(projectID),
+	})
+}
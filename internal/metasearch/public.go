@@ -0,0 +1,171 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
+
+	"storj.io/common/uuid"
+)
+
+const (
+	publicLimiterIdleTimeout = 10 * time.Minute
+	maxPublicLimiters        = 10000
+)
+
+// PublicBucketAuth allows anonymous, read-only access to the metadata of
+// designated public buckets, using an access grant held by the server.
+// Requests with an Authorization header, or requests to other buckets, are
+// authenticated by the wrapped Authenticator.
+type PublicBucketAuth struct {
+	auth    Authenticator
+	buckets map[string]string
+	limiter *clientRateLimiter
+}
+
+// NewPublicBucketAuth creates a new PublicBucketAuth. The buckets map contains
+// the access grant for each public bucket. Anonymous requests are rate
+// limited per client IP.
+func NewPublicBucketAuth(auth Authenticator, buckets map[string]string, limit rate.Limit, burst int) *PublicBucketAuth {
+	return &PublicBucketAuth{
+		auth:    auth,
+		buckets: buckets,
+		limiter: newClientRateLimiter(limit, burst),
+	}
+}
+
+// ParsePublicBuckets parses a comma separated list of bucket:access pairs.
+func ParsePublicBuckets(s string) (map[string]string, error) {
+	buckets := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		bucket, access, ok := strings.Cut(item, ":")
+		if !ok || bucket == "" || access == "" {
+			return nil, fmt.Errorf("invalid public bucket '%s': must be bucket:access", item)
+		}
+		buckets[bucket] = access
+	}
+	return buckets, nil
+}
+
+func (a *PublicBucketAuth) Authenticate(ctx context.Context, r *http.Request) (projectID uuid.UUID, encryptor Encryptor, authorizer Authorizer, err error) {
+	bucket := mux.Vars(r)["bucket"]
+	access, ok := a.buckets[bucket]
+	if r.Header.Get("Authorization") != "" || !ok {
+		return a.auth.Authenticate(ctx, r)
+	}
+
+	if !a.limiter.Allow(clientIP(r)) {
+		err = fmt.Errorf("%w: rate limit exceeded for public bucket", ErrTooManyRequests)
+		return
+	}
+
+	publicRequest := r.Clone(ctx)
+	publicRequest.Header.Set("Authorization", "Bearer "+access)
+
+	projectID, encryptor, authorizer, err = a.auth.Authenticate(ctx, publicRequest)
+	if err != nil {
+		return
+	}
+
+	authorizer = &readOnlyAuthorizer{
+		authorizer: authorizer,
+		bucket:     bucket,
+	}
+	return
+}
+
+// readOnlyAuthorizer restricts an authorizer to read operations in a single bucket.
+type readOnlyAuthorizer struct {
+	authorizer Authorizer
+	bucket     string
+}
+
+func (a *readOnlyAuthorizer) Authorize(ctx context.Context, encryptedLocation ObjectLocation, action Action) error {
+	if action != ActionReadMetadata && action != ActionQueryMetadata {
+		return fmt.Errorf("%w: public buckets are read-only", ErrForbidden)
+	}
+	if encryptedLocation.BucketName != a.bucket {
+		return fmt.Errorf("%w: access is restricted to the public bucket", ErrForbidden)
+	}
+	return a.authorizer.Authorize(ctx, encryptedLocation, action)
+}
+
+// clientRateLimiter rate limits requests per client. Limiters of idle clients
+// are evicted, and at most maxPublicLimiters clients are tracked, evicting the
+// least recently seen.
+type clientRateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mutex    sync.Mutex
+	limiters map[string]*list.Element
+	// recent contains the *clientLimiter values, most recently seen first.
+	recent *list.List
+}
+
+type clientLimiter struct {
+	client   string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newClientRateLimiter(limit rate.Limit, burst int) *clientRateLimiter {
+	return &clientRateLimiter{
+		limit:    limit,
+		burst:    burst,
+		limiters: make(map[string]*list.Element),
+		recent:   list.New(),
+	}
+}
+
+// Allow reports whether a request of the client may happen now.
+func (l *clientRateLimiter) Allow(client string) bool {
+	return l.allowAt(client, time.Now())
+}
+
+func (l *clientRateLimiter) allowAt(client string, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.evict(now)
+
+	var c *clientLimiter
+	if element, ok := l.limiters[client]; ok {
+		c = element.Value.(*clientLimiter)
+		l.recent.MoveToFront(element)
+	} else {
+		c = &clientLimiter{client: client, limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[client] = l.recent.PushFront(c)
+	}
+	c.lastSeen = now
+
+	return c.limiter.AllowN(now, 1)
+}
+
+// evict removes the limiters of idle clients, and of the least recently seen
+// clients if there are too many. Must be called while l.mutex is locked.
+func (l *clientRateLimiter) evict(now time.Time) {
+	for element := l.recent.Back(); element != nil; element = l.recent.Back() {
+		c := element.Value.(*clientLimiter)
+		if len(l.limiters) < maxPublicLimiters && now.Sub(c.lastSeen) <= publicLimiterIdleTimeout {
+			return
+		}
+		l.recent.Remove(element)
+		delete(l.limiters, c.client)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestParsePublicBuckets(t *testing.T) {
+	buckets, err := ParsePublicBuckets("open:access1, data:access2")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"open": "access1", "data": "access2"}, buckets)
+
+	_, err = ParsePublicBuckets("open")
+	require.Error(t, err)
+}
+
+func TestPublicBucketAuth(t *testing.T) {
+	server := testServer()
+	server.Auth = NewPublicBucketAuth(server.Auth, map[string]string{"testbucket": "publicaccess"}, 1, 2)
+
+	anonymousRequest := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(method, path, body)
+		r.Header.Del("Authorization")
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	// Authenticated requests are not restricted
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Anonymous read
+	rr = anonymousRequest(http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "bar"}`)
+
+	// Anonymous write is forbidden
+	rr = anonymousRequest(http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "baz"}`)
+	assert.Equal(t, rr.Code, http.StatusForbidden)
+
+	// Rate limit exceeded
+	rr = anonymousRequest(http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+}
+
+func TestClientRateLimiterEviction(t *testing.T) {
+	limiter := newClientRateLimiter(1, 1)
+	now := time.Now()
+
+	require.True(t, limiter.allowAt("idle", now))
+	require.False(t, limiter.allowAt("idle", now))
+
+	// Idle clients are evicted periodically, even if there are few clients
+	later := now.Add(publicLimiterIdleTimeout + time.Second)
+	require.True(t, limiter.allowAt("active", later))
+	require.NotContains(t, limiter.limiters, "idle")
+
+	// The number of clients is bounded, evicting the least recently seen
+	for i := 0; i < maxPublicLimiters+10; i++ {
+		limiter.allowAt(strconv.Itoa(i), later.Add(time.Duration(i)*time.Millisecond))
+	}
+	require.Len(t, limiter.limiters, maxPublicLimiters)
+	require.NotContains(t, limiter.limiters, "active")
+	require.NotContains(t, limiter.limiters, "0")
+	require.Contains(t, limiter.limiters, strconv.Itoa(maxPublicLimiters+9))
+}
@@ -0,0 +1,441 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxTextQueryDepth limits the nesting of parentheses and NOT in text
+// queries.
+const maxTextQueryDepth = 32
+
+// textQueryOperators maps the comparison operators of text queries to match
+// query operators.
+var textQueryOperators = map[string]string{
+	"=":  eqOperator,
+	"!=": eqOperator,
+	"<>": eqOperator,
+	">":  "$gt",
+	">=": "$gte",
+	"<":  "$lt",
+	"<=": "$lte",
+}
+
+// parseTextQuery parses a SQL-like text query, e.g.
+// `department = "hr" AND size > 100`, into a match query. The grammar is:
+//
+//	query      = and { OR and }
+//	and        = unary { AND unary }
+//	unary      = NOT unary | "(" query ")" | comparison
+//	comparison = path op value | path [NOT] IN "(" value { "," value } ")"
+//	           | path [NOT] (GLOB | BEFORE | AFTER) string
+//	op         = "=" | "!=" | "<>" | "<" | "<=" | ">" | ">="
+//	path       = key { "." key }
+//
+// Keys are identifiers or backquoted strings, values are double or single
+// quoted strings, numbers, true, false or null. Keywords are
+// case-insensitive.
+func parseTextQuery(query string) (map[string]interface{}, error) {
+	p := &textQueryParser{lexer: textQueryLexer{input: query}}
+	err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.token.kind != tokenEOF {
+		return nil, p.errorf("unexpected '%s'", p.token.text)
+	}
+	return match, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPath
+	tokenKeyword
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenPunct
+)
+
+type textQueryToken struct {
+	kind tokenKind
+	pos  int
+	text string
+	// path are the keys of a path token.
+	path []string
+	// value is the value of a string, number or keyword literal.
+	value interface{}
+}
+
+// textQueryLexer splits a text query into tokens.
+type textQueryLexer struct {
+	input string
+	pos   int
+}
+
+var textQueryKeywords = map[string]interface{}{
+	"AND":    nil,
+	"OR":     nil,
+	"NOT":    nil,
+	"IN":     nil,
+	"GLOB":   nil,
+	"BEFORE": nil,
+	"AFTER":  nil,
+	"TRUE":   true,
+	"FALSE":  false,
+	"NULL":   nil,
+}
+
+func (l *textQueryLexer) errorf(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: invalid query at offset %d: %s", ErrBadRequest, pos, fmt.Sprintf(format, args...))
+}
+
+func (l *textQueryLexer) next() (token textQueryToken, err error) {
+	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+	token.pos = l.pos
+	if l.pos >= len(l.input) {
+		token.kind = tokenEOF
+		return token, nil
+	}
+
+	c := l.input[l.pos]
+	switch {
+	case c == '(' || c == ')' || c == ',':
+		l.pos++
+		token.kind, token.text = tokenPunct, string(c)
+		return token, nil
+
+	case strings.ContainsRune("=!<>", rune(c)):
+		for _, op := range []string{"!=", "<>", "<=", ">=", "=", "<", ">"} {
+			if strings.HasPrefix(l.input[l.pos:], op) {
+				l.pos += len(op)
+				token.kind, token.text = tokenOperator, op
+				return token, nil
+			}
+		}
+		return token, l.errorf(token.pos, "unexpected '%c'", c)
+
+	case c == '"' || c == '\'':
+		s, err := l.readString(c)
+		if err != nil {
+			return token, err
+		}
+		token.kind, token.text, token.value = tokenString, l.input[token.pos:l.pos], s
+		return token, nil
+
+	case c == '-' || c >= '0' && c <= '9':
+		end := l.pos + 1
+		for end < len(l.input) && strings.ContainsRune("0123456789.eE+-", rune(l.input[end])) {
+			end++
+		}
+		// Numbers are kept as written, so that they are not rounded
+		n := l.input[l.pos:end]
+		if !json.Valid([]byte(n)) {
+			return token, l.errorf(token.pos, "invalid number '%s'", n)
+		}
+		l.pos = end
+		token.kind, token.text, token.value = tokenNumber, n, json.Number(n)
+		return token, nil
+
+	case c == '`' || isKeyStart(c):
+		token.kind = tokenPath
+		for {
+			key, err := l.readKey()
+			if err != nil {
+				return token, err
+			}
+			token.path = append(token.path, key)
+			if l.pos >= len(l.input) || l.input[l.pos] != '.' {
+				break
+			}
+			l.pos++
+		}
+		token.text = l.input[token.pos:l.pos]
+
+		if len(token.path) == 1 && token.text[0] != '`' {
+			keyword := strings.ToUpper(token.text)
+			if value, ok := textQueryKeywords[keyword]; ok {
+				token.kind, token.text, token.value = tokenKeyword, keyword, value
+			}
+		}
+		return token, nil
+	}
+
+	return token, l.errorf(token.pos, "unexpected '%c'", c)
+}
+
+// readString reads a string literal. Double quoted strings use JSON escapes,
+// and quotes are doubled in single quoted strings.
+func (l *textQueryLexer) readString(quote byte) (string, error) {
+	start := l.pos
+	for i := start + 1; i < len(l.input); i++ {
+		switch {
+		case quote == '"' && l.input[i] == '\\':
+			i++
+		case l.input[i] == quote && quote == '\'' && i+1 < len(l.input) && l.input[i+1] == '\'':
+			i++
+		case l.input[i] == quote:
+			l.pos = i + 1
+			if quote == '\'' {
+				return strings.ReplaceAll(l.input[start+1:i], "''", "'"), nil
+			}
+			var s string
+			if err := json.Unmarshal([]byte(l.input[start:l.pos]), &s); err != nil {
+				return "", l.errorf(start, "invalid string")
+			}
+			return s, nil
+		}
+	}
+	return "", l.errorf(start, "unterminated string")
+}
+
+// readKey reads a key of a path: an identifier or a backquoted string.
+func (l *textQueryLexer) readKey() (string, error) {
+	start := l.pos
+	if l.pos < len(l.input) && l.input[l.pos] == '`' {
+		end := strings.IndexByte(l.input[l.pos+1:], '`')
+		if end < 0 {
+			return "", l.errorf(start, "unterminated key")
+		}
+		l.pos += end + 2
+		key := l.input[start+1 : l.pos-1]
+		if key == "" || strings.HasPrefix(key, "$") {
+			return "", l.errorf(start, "keys cannot be empty or start with '$'")
+		}
+		return key, nil
+	}
+
+	if l.pos >= len(l.input) || !isKeyStart(l.input[l.pos]) {
+		return "", l.errorf(start, "expected key")
+	}
+	for l.pos < len(l.input) && (isKeyStart(l.input[l.pos]) || l.input[l.pos] == '-' || l.input[l.pos] >= '0' && l.input[l.pos] <= '9') {
+		l.pos++
+	}
+	return l.input[start:l.pos], nil
+}
+
+func isKeyStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// textQueryParser is a recursive descent parser of text queries.
+type textQueryParser struct {
+	lexer textQueryLexer
+	token textQueryToken
+}
+
+func (p *textQueryParser) next() (err error) {
+	p.token, err = p.lexer.next()
+	return err
+}
+
+func (p *textQueryParser) errorf(format string, args ...interface{}) error {
+	return p.lexer.errorf(p.token.pos, format, args...)
+}
+
+// isKeyword returns true if the current token is the keyword.
+func (p *textQueryParser) isKeyword(keyword string) bool {
+	return p.token.kind == tokenKeyword && p.token.text == keyword
+}
+
+// isPunct returns true if the current token is the punctuation character.
+func (p *textQueryParser) isPunct(punct string) bool {
+	return p.token.kind == tokenPunct && p.token.text == punct
+}
+
+func (p *textQueryParser) parseOr(depth int) (map[string]interface{}, error) {
+	return p.parseList(depth, "OR", orKey, p.parseAnd)
+}
+
+func (p *textQueryParser) parseAnd(depth int) (map[string]interface{}, error) {
+	return p.parseList(depth, "AND", andKey, p.parseUnary)
+}
+
+// parseList parses operands separated by a keyword into a list clause.
+func (p *textQueryParser) parseList(depth int, keyword, clause string, parseOperand func(int) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	operand, err := parseOperand(depth)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isKeyword(keyword) {
+		return operand, nil
+	}
+
+	operands := []interface{}{operand}
+	for p.isKeyword(keyword) {
+		err = p.next()
+		if err != nil {
+			return nil, err
+		}
+		operand, err = parseOperand(depth)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	return map[string]interface{}{clause: operands}, nil
+}
+
+func (p *textQueryParser) parseUnary(depth int) (map[string]interface{}, error) {
+	if depth >= maxTextQueryDepth {
+		return nil, p.errorf("query is nested too deeply")
+	}
+
+	switch {
+	case p.isKeyword("NOT"):
+		err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{notKey: operand}, nil
+
+	case p.isPunct("("):
+		err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		match, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if !p.isPunct(")") {
+			return nil, p.errorf("expected ')'")
+		}
+		return match, p.next()
+
+	case p.token.kind == tokenPath:
+		return p.parseComparison()
+	}
+	return nil, p.errorf("expected key, NOT or '('")
+}
+
+func (p *textQueryParser) parseComparison() (map[string]interface{}, error) {
+	path := p.token.path
+	err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	negate := false
+	if p.isKeyword("NOT") {
+		negate = true
+		err = p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("IN") && p.stringOperator() == "" {
+			return nil, p.errorf("expected IN, GLOB, BEFORE or AFTER")
+		}
+	}
+
+	var operator string
+	var operand interface{}
+	switch {
+	case p.isKeyword("IN"):
+		operator = inOperator
+		operand, err = p.parseValueList()
+	case p.stringOperator() != "":
+		operator = p.stringOperator()
+		err = p.next()
+		if err == nil && p.token.kind != tokenString {
+			err = p.errorf("expected string")
+		}
+		if err == nil {
+			operand = p.token.value
+			err = p.next()
+		}
+	case p.token.kind == tokenOperator:
+		operator = textQueryOperators[p.token.text]
+		negate = p.token.text == "!=" || p.token.text == "<>"
+		err = p.next()
+		if err == nil {
+			operand, err = p.parseValue()
+		}
+	default:
+		err = p.errorf("expected comparison operator")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	match := nestedValue(path, map[string]interface{}{operator: operand})
+	if negate {
+		return map[string]interface{}{notKey: match}, nil
+	}
+	return match, nil
+}
+
+// stringOperator returns the match query operator of a keyword whose operand
+// is a string, or an empty string if the current token is not one.
+func (p *textQueryParser) stringOperator() string {
+	switch {
+	case p.isKeyword("GLOB"):
+		return globOperator
+	case p.isKeyword("BEFORE"):
+		return beforeOperator
+	case p.isKeyword("AFTER"):
+		return afterOperator
+	}
+	return ""
+}
+
+// parseValueList parses the parenthesized values of IN.
+func (p *textQueryParser) parseValueList() ([]interface{}, error) {
+	err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isPunct("(") {
+		return nil, p.errorf("expected '('")
+	}
+
+	var values []interface{}
+	for {
+		err = p.next()
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		value, err = p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		if p.isPunct(")") {
+			return values, p.next()
+		}
+		if !p.isPunct(",") {
+			return nil, p.errorf("expected ',' or ')'")
+		}
+	}
+}
+
+// parseValue parses a literal value.
+func (p *textQueryParser) parseValue() (interface{}, error) {
+	switch {
+	case p.token.kind == tokenString, p.token.kind == tokenNumber,
+		p.isKeyword("TRUE"), p.isKeyword("FALSE"), p.isKeyword("NULL"):
+		value := p.token.value
+		return value, p.next()
+	}
+	return nil, p.errorf("expected value")
+}
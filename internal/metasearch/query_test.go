@@ -0,0 +1,119 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestParseTextQuery(t *testing.T) {
+	for _, tt := range []struct {
+		query    string
+		expected map[string]interface{}
+	}{
+		{
+			query:    `department = "hr"`,
+			expected: map[string]interface{}{"department": map[string]interface{}{"$eq": "hr"}},
+		},
+		{
+			query: `department = "hr" AND size > 100`,
+			expected: map[string]interface{}{"$and": []interface{}{
+				map[string]interface{}{"department": map[string]interface{}{"$eq": "hr"}},
+				map[string]interface{}{"size": map[string]interface{}{"$gt": json.Number("100")}},
+			}},
+		},
+		{
+			query: `a = 1 or b <= -2.5 AND c <> 'it''s'`,
+			expected: map[string]interface{}{"$or": []interface{}{
+				map[string]interface{}{"a": map[string]interface{}{"$eq": json.Number("1")}},
+				map[string]interface{}{"$and": []interface{}{
+					map[string]interface{}{"b": map[string]interface{}{"$lte": json.Number("-2.5")}},
+					map[string]interface{}{"$not": map[string]interface{}{"c": map[string]interface{}{"$eq": "it's"}}},
+				}},
+			}},
+		},
+		{
+			query: `NOT (exif.iso >= 400 OR ` + "`content type`" + ` GLOB "image/*")`,
+			expected: map[string]interface{}{"$not": map[string]interface{}{"$or": []interface{}{
+				map[string]interface{}{"exif": map[string]interface{}{"iso": map[string]interface{}{"$gte": json.Number("400")}}},
+				map[string]interface{}{"content type": map[string]interface{}{"$glob": "image/*"}},
+			}}},
+		},
+		{
+			query: `type IN ("photo", "video") AND reviewed NOT IN (true, null)`,
+			expected: map[string]interface{}{"$and": []interface{}{
+				map[string]interface{}{"type": map[string]interface{}{"$in": []interface{}{"photo", "video"}}},
+				map[string]interface{}{"$not": map[string]interface{}{"reviewed": map[string]interface{}{"$in": []interface{}{true, nil}}}},
+			}},
+		},
+		{
+			query: `capturedAt AFTER "2024-01-01T00:00:00Z" AND capturedAt NOT BEFORE '2023-01-01T00:00:00Z'`,
+			expected: map[string]interface{}{"$and": []interface{}{
+				map[string]interface{}{"capturedAt": map[string]interface{}{"$after": "2024-01-01T00:00:00Z"}},
+				map[string]interface{}{"$not": map[string]interface{}{"capturedAt": map[string]interface{}{"$before": "2023-01-01T00:00:00Z"}}},
+			}},
+		},
+	} {
+		match, err := parseTextQuery(tt.query)
+		require.NoError(t, err, tt.query)
+		require.Equal(t, tt.expected, match, tt.query)
+
+		_, err = parseMatch(match)
+		require.NoError(t, err, tt.query)
+	}
+
+	for _, invalid := range []string{
+		``,
+		`department`,
+		`department = `,
+		`department = "hr`,
+		`department = hr`,
+		`(size > 1`,
+		`size > 1)`,
+		`size IN ()`,
+		`size IN (1, 2`,
+		`size NOT = 1`,
+		`capturedAt AFTER 2024`,
+		`a = 1 AND`,
+		"`$and` = 1",
+		`a.`,
+		`a ! 1`,
+	} {
+		_, err := parseTextQuery(invalid)
+		require.ErrorIs(t, err, ErrBadRequest, invalid)
+	}
+}
+
+func TestSearchTextQuery(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"department": "hr", "size": 200}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"query": "department = \"hr\" AND size > 100", "highlight": true}`)
+	assertResponse(t, rr, http.StatusOK, `{
+		"results": [{
+			"path": "sj://testbucket/foo.txt",
+			"metadata": {"department": "hr", "size": 200},
+			"highlights": {"department": "hr", "size": 200}
+		}]
+	}`)
+
+	// Query parameter of listings
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?query="+url.QueryEscape(`department IN ("hr", "it")`), "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// Invalid queries and combinations
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"query": "department ="}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"query": "size > 100", "match": {"$and": {"a": 1}}}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// readOnlyRoutes are the routes of mutating methods that do not change any
+// state, and are served in read-only mode.
+var readOnlyRoutes = map[string]bool{
+	"search":             true,
+	"create-search-link": true,
+	"aggregate":          true,
+	"rollup":             true,
+	"lint":               true,
+}
+
+// ReadOnlyRequest is the body of a request to the read-only mode endpoint of
+// the admin API, and its response.
+type ReadOnlyRequest struct {
+	ReadOnly bool `json:"readOnly"`
+}
+
+// ReadOnly returns true if the server is in read-only maintenance mode.
+func (s *Server) ReadOnly() bool {
+	return s.readOnly.Load()
+}
+
+// SetReadOnly switches the read-only maintenance mode of the server, used
+// during metabase migrations and failovers. In read-only mode, mutating
+// requests are rejected, and metadata migrations and background cleanups are
+// paused.
+func (s *Server) SetReadOnly(readOnly bool) {
+	if s.readOnly.Swap(readOnly) != readOnly {
+		s.Logger.Info("read-only mode changed", zap.Bool("ReadOnly", readOnly))
+	}
+	s.Migrator.SetPaused(readOnly)
+}
+
+// mutatingRequest returns true if a request may change state.
+func mutatingRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	route := mux.CurrentRoute(r)
+	return route == nil || !readOnlyRoutes[route.GetName()]
+}
+
+// rejectWrites rejects mutating requests with 503 Service Unavailable in
+// read-only mode. The admin API is still available, to leave read-only mode.
+func (s *Server) rejectWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.ReadOnly() || unversionedPath(r.URL.Path) || !mutatingRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", "60")
+		s.errorResponse(w, fmt.Errorf("%w: server is in read-only maintenance mode", ErrServiceUnavailable))
+	})
+}
+
+// HandleAdminReadOnly returns whether the server is in read-only mode.
+func (s *Server) HandleAdminReadOnly(w http.ResponseWriter, r *http.Request) {
+	s.jsonResponse(w, http.StatusOK, ReadOnlyRequest{ReadOnly: s.ReadOnly()})
+}
+
+// HandleAdminSetReadOnly enters or leaves read-only mode.
+func (s *Server) HandleAdminSetReadOnly(w http.ResponseWriter, r *http.Request) {
+	var request ReadOnlyRequest
+	if err := newJSONDecoder(r.Body).Decode(&request); err != nil {
+		s.errorResponse(w, bodyError(err, "error decoding request body"))
+		return
+	}
+
+	s.SetReadOnly(request.ReadOnly)
+	s.jsonResponse(w, http.StatusOK, request)
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	server := testServer()
+
+	adminRequest := func(method, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(method, "/admin/read-only", body)
+		r.Header.Set("Authorization", "Bearer "+testAdminToken)
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = adminRequest(http.MethodPut, `{"readOnly": true}`)
+	assertResponse(t, rr, http.StatusOK, `{"readOnly": true}`)
+	rr = adminRequest(http.MethodGet, "")
+	assertResponse(t, rr, http.StatusOK, `{"readOnly": true}`)
+	assert.True(t, server.ReadOnly())
+	assert.True(t, server.Migrator.paused.Load())
+
+	// Mutating requests are rejected
+	for _, tt := range []struct{ method, path, body string }{
+		{http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "baz"}`},
+		{http.MethodDelete, "/metadata/testbucket/foo.txt", ""},
+		{http.MethodPost, "/restore/testbucket/foo.txt", ""},
+		{http.MethodPost, "/import/testbucket", "key,foo\nfoo.txt,baz\n"},
+		{http.MethodPut, "/vocabularies/foo", `{"values": ["bar"]}`},
+	} {
+		rr = handleRequest(server, tt.method, tt.path, tt.body)
+		assertResponse(t, rr, http.StatusServiceUnavailable, `{"error": "service unavailable"}`)
+	}
+
+	// Reads and searches are still served
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "bar"}`)
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"filter": "foo == 'bar'"}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	rr = adminRequest(http.MethodPut, `{"readOnly": false}`)
+	assertResponse(t, rr, http.StatusOK, `{"readOnly": false}`)
+	assert.False(t, server.Migrator.paused.Load())
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "baz"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = adminRequest(http.MethodPut, `{`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
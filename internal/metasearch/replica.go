@@ -0,0 +1,91 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+)
+
+// ReplicaSearchRepository serves searches and metadata reads from a
+// read-only replica of the metabase, e.g. a PostgreSQL standby or a
+// CockroachDB read-only cluster, to reduce the load on the primary. All other
+// operations go to the primary. Reads of requests that change metadata also
+// go to the primary, as they must not miss changes that the replica has not
+// applied yet.
+type ReplicaSearchRepository struct {
+	MetaSearchRepo
+
+	replica MetaSearchRepo
+}
+
+// NewReplicaSearchRepository creates a new ReplicaSearchRepository.
+func NewReplicaSearchRepository(primary MetaSearchRepo, replica MetaSearchRepo) *ReplicaSearchRepository {
+	return &ReplicaSearchRepository{
+		MetaSearchRepo: primary,
+		replica:        replica,
+	}
+}
+
+type primaryReadsKey struct{}
+
+// withPrimaryReads returns a context whose reads are served by the primary.
+func withPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey{}, true)
+}
+
+// routeReads serves the reads of mutating requests from the primary.
+func routeReads(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mutatingRequest(r) {
+			r = r.WithContext(withPrimaryReads(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reader returns the repository that serves the reads made with ctx.
+func (r *ReplicaSearchRepository) reader(ctx context.Context) MetaSearchRepo {
+	if primary, _ := ctx.Value(primaryReadsKey{}).(bool); primary {
+		mon.Counter("routed_reads", monkit.NewSeriesTag("target", "primary")).Inc(1)
+		return r.MetaSearchRepo
+	}
+	mon.Counter("routed_reads", monkit.NewSeriesTag("target", "replica")).Inc(1)
+	return r.replica
+}
+
+func (r *ReplicaSearchRepository) GetMetadata(ctx context.Context, loc ObjectLocation) (ObjectInfo, error) {
+	return r.reader(ctx).GetMetadata(ctx, loc)
+}
+
+func (r *ReplicaSearchRepository) QueryMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int) (QueryMetadataResult, error) {
+	return r.reader(ctx).QueryMetadata(ctx, loc, containsQuery, sort, startAfter, asOf, batchSize)
+}
+
+func (r *ReplicaSearchRepository) QueryMetadataFunc(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int, fn ObjectFunc) (QueryMetadataResult, error) {
+	return r.reader(ctx).QueryMetadataFunc(ctx, loc, containsQuery, sort, startAfter, asOf, batchSize, fn)
+}
+
+func (r *ReplicaSearchRepository) CountMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}) (int64, error) {
+	return r.reader(ctx).CountMetadata(ctx, loc, containsQuery)
+}
+
+func (r *ReplicaSearchRepository) AggregateMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, aggregation MetadataAggregation) ([]AggregateGroup, error) {
+	return r.reader(ctx).AggregateMetadata(ctx, loc, containsQuery, aggregation)
+}
+
+func (r *ReplicaSearchRepository) RollupMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, groupBy []string, limit int) ([]RollupRow, error) {
+	return r.reader(ctx).RollupMetadata(ctx, loc, containsQuery, groupBy, limit)
+}
+
+func (r *ReplicaSearchRepository) SampleMetadata(ctx context.Context, loc ObjectLocation, limit int) ([]map[string]interface{}, error) {
+	return r.reader(ctx).SampleMetadata(ctx, loc, limit)
+}
+
+func (r *ReplicaSearchRepository) TopMetadataValues(ctx context.Context, loc ObjectLocation, key string, prefix string, sample int, limit int) ([]ValueCount, error) {
+	return r.reader(ctx).TopMetadataValues(ctx, loc, key, prefix, sample, limit)
+}
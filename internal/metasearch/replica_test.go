@@ -0,0 +1,48 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+	"go.uber.org/zap"
+)
+
+func TestReplicaSearchRepository(t *testing.T) {
+	ctx := context.Background()
+	primary := newMockRepo()
+	replica := newMockRepo()
+
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, NewReplicaSearchRepository(primary, replica), &mockAuthenticator{}, ServerConfig{})
+	require.NoError(t, err)
+
+	// The replica has not applied the latest change yet
+	loc := ObjectLocation{BucketName: "testbucket", ObjectKey: "enc:foo.txt"}
+	require.NoError(t, primary.UpdateMetadata(ctx, loc, ObjectMetadata{ClearMetadata: map[string]interface{}{"n": 2}}))
+	require.NoError(t, replica.UpdateMetadata(ctx, loc, ObjectMetadata{ClearMetadata: map[string]interface{}{"n": 1}}))
+
+	// Reads and searches are served from the replica
+	rr := handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"n": 1}`)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [{"path": "sj://testbucket/foo.txt", "metadata": {"n": 1}}]}`)
+
+	// Conditional updates read the current metadata from the primary
+	r := testRequest(http.MethodPut, "/metadata/testbucket/foo.txt", `{"n": 3}`)
+	r.Header.Set("If-Match", metadataETag(map[string]interface{}{"n": 2}))
+	rr = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	require.Equal(t, 1, replica.objects["sj://testbucket/enc:foo.txt"].Metadata.ClearMetadata["n"])
+	require.Equal(t, json.Number("3"), primary.objects["sj://testbucket/enc:foo.txt"].Metadata.ClearMetadata["n"])
+}
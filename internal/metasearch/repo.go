@@ -21,6 +21,9 @@ const (
 	statusPending     = "1"
 	statusesCommitted = "(3,4)"
 
+	statusCommittedUnversioned = 3
+	statusCommittedVersioned   = 4
+
 	deleteMarkerUnversioned = 5
 	deleteMarkerVersioned   = 6
 
@@ -29,24 +32,177 @@ const (
 
 // MetaSearchRepo performs operations on object metadata.
 type MetaSearchRepo interface {
-	// Get metadata for an object.
+	// Get metadata for an object. If loc.Version is set, the metadata of
+	// that version is returned, otherwise the latest version.
 	GetMetadata(ctx context.Context, loc ObjectLocation) (obj ObjectInfo, err error)
 
 	// Query metadata in a bucket, optionally in a subdirectory.
 	// To search in a subdirectory, pass it in loc.ObjectKey, with a trailing /.
-	QueryMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, startAfter ObjectLocation, batchSize int) (QueryMetadataResult, error)
+	// Results are ordered by key, or by a metadata value if sort is set.
+	QueryMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int) (QueryMetadataResult, error)
+
+	// QueryMetadataFunc is like QueryMetadata, but calls fn for each object
+	// as it is read instead of returning them, so that the objects of large
+	// batches are not kept in memory. The result has no objects. If fn
+	// returns an error, the query stops and returns it, with the result of
+	// the objects read so far.
+	QueryMetadataFunc(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int, fn ObjectFunc) (QueryMetadataResult, error)
+
+	// CountMetadata returns the number of objects matched by a query, without
+	// fetching them.
+	CountMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}) (int64, error)
+
+	// AggregateMetadata groups the objects matched by a query by a metadata
+	// value, largest groups first, and aggregates their numeric values.
+	AggregateMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, aggregation MetadataAggregation) ([]AggregateGroup, error)
+
+	// RollupMetadata groups the objects matched by a query by the values of
+	// several metadata keys, largest total size first, and returns their
+	// number and total size.
+	RollupMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, groupBy []string, limit int) ([]RollupRow, error)
+
+	// SampleMetadata returns the clear metadata of up to limit objects in a
+	// bucket, optionally in a subdirectory.
+	SampleMetadata(ctx context.Context, loc ObjectLocation, limit int) ([]map[string]interface{}, error)
+
+	// TopMetadataValues returns the most frequent string values of a metadata
+	// key that start with prefix in a bucket. If sample is not zero, only the
+	// values of that many objects are counted. The elements of arrays are
+	// counted as separate values.
+	TopMetadataValues(ctx context.Context, loc ObjectLocation, key string, prefix string, sample int, limit int) ([]ValueCount, error)
+
+	// Query metadata across projects, optionally in a single bucket, without
+	// pagination. It is used by operators for support cases.
+	QueryProjectsMetadata(ctx context.Context, projectIDs []uuid.UUID, bucket string, containsQuery map[string]interface{}, limit int) ([]ObjectInfo, error)
 
 	// Set metadata for an object.
 	UpdateMetadata(ctx context.Context, loc ObjectLocation, meta ObjectMetadata) (err error)
 
+	// Set metadata for an object, if its current clear metadata is equal to
+	// expected. Returns ErrPreconditionFailed if the metadata has changed.
+	UpdateMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}, meta ObjectMetadata) (err error)
+
 	// Delete metadata for an object.
 	DeleteMetadata(ctx context.Context, loc ObjectLocation) (err error)
 
+	// Delete metadata for an object, keeping a copy that can be restored
+	// with RestoreMetadata.
+	SoftDeleteMetadata(ctx context.Context, loc ObjectLocation) (err error)
+
+	// Soft delete metadata for an object, if its current clear metadata is
+	// equal to expected. Returns ErrPreconditionFailed if the metadata has
+	// changed.
+	SoftDeleteMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}) (err error)
+
+	// Restore soft deleted metadata for an object, if it was deleted after
+	// deletedAfter.
+	RestoreMetadata(ctx context.Context, loc ObjectLocation, deletedAfter time.Time) (err error)
+
+	// PurgeTombstones removes the copies of metadata soft deleted before
+	// deletedBefore.
+	PurgeTombstones(ctx context.Context, deletedBefore time.Time) (purged int64, err error)
+
+	// GetMetadataHistory returns the metadata changes of an object, newest
+	// first. If before is not zero, only revisions before it are returned.
+	GetMetadataHistory(ctx context.Context, loc ObjectLocation, before int64, limit int) ([]MetadataRevision, error)
+
+	// GetChanges returns the metadata changes in a bucket after a watermark
+	// and revision, ordered by watermark and revision.
+	GetChanges(ctx context.Context, projectID uuid.UUID, bucket string, afterWatermark int64, afterRevision int64, limit int) ([]MetadataChange, error)
+
+	// DeleteExpiredMetadata deletes metadata whose expiration time has
+	// passed, up to limit objects. It returns the updated objects.
+	DeleteExpiredMetadata(ctx context.Context, limit int) ([]ObjectLocation, error)
+
+	// GetWatermark returns the change watermark of a bucket.
+	GetWatermark(ctx context.Context, projectID uuid.UUID, bucket string) (Watermark, error)
+
+	// GetVocabularies returns the vocabularies of a project by metadata key.
+	GetVocabularies(ctx context.Context, projectID uuid.UUID) (map[string]Vocabulary, error)
+
+	// SetVocabulary sets the vocabulary of a metadata key in a project.
+	SetVocabulary(ctx context.Context, projectID uuid.UUID, key string, vocabulary Vocabulary) error
+
+	// DeleteVocabulary deletes the vocabulary of a metadata key in a project.
+	DeleteVocabulary(ctx context.Context, projectID uuid.UUID, key string) error
+
+	// CreateJob stores a new search job.
+	CreateJob(ctx context.Context, job Job) error
+
+	// GetJob returns a search job without its results.
+	GetJob(ctx context.Context, id uuid.UUID) (Job, error)
+
+	// ClaimJob takes over a running job whose lease expired before now,
+	// until leaseExpiresAt. It returns the new attempt of the job, or
+	// ErrConflict if the job is not running or its lease is held.
+	ClaimJob(ctx context.Context, id uuid.UUID, now time.Time, leaseExpiresAt time.Time) (attempt int64, err error)
+
+	// SaveJobProgress updates a running job and appends a chunk of results
+	// with the index job.Chunks-1, if results is not nil. It returns
+	// ErrNotFound if the job was deleted, finished or claimed by another
+	// attempt.
+	SaveJobProgress(ctx context.Context, job Job, results []byte) error
+
+	// GetJobResults returns up to limit chunks of results of a job, starting
+	// from the chunk with the index fromChunk.
+	GetJobResults(ctx context.Context, id uuid.UUID, fromChunk int64, limit int) ([][]byte, error)
+
+	// DeleteJob deletes a search job and its results.
+	DeleteJob(ctx context.Context, id uuid.UUID) error
+
+	// DeleteExpiredJobs deletes the search jobs that expired before now and
+	// their results.
+	DeleteExpiredJobs(ctx context.Context, now time.Time) (deleted int64, err error)
+
+	// CreateSchedule stores a new scheduled search.
+	CreateSchedule(ctx context.Context, schedule Schedule) error
+
+	// GetSchedule returns a scheduled search.
+	GetSchedule(ctx context.Context, id uuid.UUID) (Schedule, error)
+
+	// ListSchedules returns the scheduled searches of a bucket, oldest
+	// first.
+	ListSchedules(ctx context.Context, projectID uuid.UUID, bucket string) ([]Schedule, error)
+
+	// ListProjectSchedules returns the scheduled searches of all buckets of
+	// a project, by bucket and oldest first.
+	ListProjectSchedules(ctx context.Context, projectID uuid.UUID) ([]Schedule, error)
+
+	// DeleteSchedule deletes a scheduled search.
+	DeleteSchedule(ctx context.Context, id uuid.UUID) error
+
+	// ClaimDueSchedules returns up to limit scheduled searches whose next
+	// run is due at now, and postpones their next run to leaseUntil, so that
+	// they are not run twice.
+	ClaimDueSchedules(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]Schedule, error)
+
+	// SaveScheduleRun saves the time of the next run of a scheduled search
+	// and the status of its last run.
+	SaveScheduleRun(ctx context.Context, schedule Schedule) error
+
 	// MigrateMetadata updates encrypted metadata for an object and removes it from the migration queue.
 	MigrateMetadata(ctx context.Context, obj ObjectInfo) (err error)
 
 	// GetObjectsForMigration fetches all objects to migrate and calls the callback function until it returns false.
 	GetObjectsForMigration(ctx context.Context, projectID uuid.UUID, startTime *time.Time, migrate ObjectMigrationFunc) error
+
+	// CountObjectsForMigration returns the number of objects that
+	// GetObjectsForMigration would fetch.
+	CountObjectsForMigration(ctx context.Context, projectID uuid.UUID, startTime *time.Time) (int64, error)
+
+	// RebuildIndex rebuilds a metadata index online: a new index is created
+	// and validated before it replaces the old one. A swap interrupted by a
+	// crash is finished instead.
+	RebuildIndex(ctx context.Context, name string, progress IndexRebuildProgressFunc) error
+
+	// AcquireLease acquires the lease called name for holder until
+	// expiresAt, or renews it if holder already holds it. It fails with
+	// ErrConflict while another holder has a lease that has not expired at
+	// now.
+	AcquireLease(ctx context.Context, name string, holder uuid.UUID, now time.Time, expiresAt time.Time) error
+
+	// ReleaseLease releases the lease called name, if holder holds it.
+	ReleaseLease(ctx context.Context, name string, holder uuid.UUID) error
 }
 
 // ObjectLocation specifies the location of an object.
@@ -65,6 +221,17 @@ type ObjectInfo struct {
 	Metadata ObjectMetadata
 
 	MetaSearchQueuedAt *time.Time
+
+	// UpdatedAt is the time of the last metadata change. It falls back to
+	// the object creation time if the metadata was never changed by
+	// metasearch. Only set by GetMetadata.
+	UpdatedAt time.Time
+
+	// Size, CreatedAt and ExpiresAt are the system attributes of the object.
+	// Only set by QueryMetadata.
+	Size      int64
+	CreatedAt time.Time
+	ExpiresAt *time.Time
 }
 
 // ObjectMetadata stores both clear and encrypted metadata for an object.
@@ -74,11 +241,44 @@ type ObjectMetadata struct {
 	EncryptedMetadataKey   []byte
 
 	ClearMetadata map[string]interface{}
+
+	// ExpiresAt is the time after which the metadata is deleted (optional).
+	ExpiresAt *time.Time
 }
 
 // QueryMetadataResult is the response of the QueryMetadata operation.
 type QueryMetadataResult struct {
 	Objects []ObjectInfo
+
+	// AsOf is the time of the snapshot the objects were read from.
+	AsOf time.Time
+
+	// ScannedUntil is set if the search stopped before finding batchSize
+	// objects, because it scanned too many objects. The next page starts
+	// after it.
+	ScannedUntil *ObjectLocation
+
+	// Warnings describe why the results may be incomplete or slow.
+	Warnings []string
+}
+
+// ObjectFunc is called by QueryMetadataFunc for each object found.
+type ObjectFunc func(obj ObjectInfo) error
+
+// forEachObject calls fn for each object of a QueryMetadata result, for the
+// repositories that read all objects of a batch at once.
+func forEachObject(result QueryMetadataResult, err error, fn ObjectFunc) (QueryMetadataResult, error) {
+	if err != nil {
+		return QueryMetadataResult{}, err
+	}
+	objects := result.Objects
+	result.Objects = nil
+	for _, obj := range objects {
+		if err := fn(obj); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
 }
 
 // ObjectMigrationFunc is called by GetObjectsForMigration. If the function returns false, the migration stops.
@@ -86,43 +286,44 @@ type ObjectMigrationFunc func(ctx context.Context, obj ObjectInfo) bool
 
 // MetabaseSearchRepository implements MetaSearchRepo using the metabase database.
 type MetabaseSearchRepository struct {
-	db  tagsql.DB
-	log *zap.Logger
+	db      tagsql.DB
+	dialect Dialect
+	tables  MetabaseTables
+	log     *zap.Logger
 }
 
-// NewMetabaseSearchRepository creates a new MetabaseSearchRepository.
-func NewMetabaseSearchRepository(db tagsql.DB, log *zap.Logger) *MetabaseSearchRepository {
+// NewMetabaseSearchRepository creates a new MetabaseSearchRepository on a
+// metabase database of the given dialect, whose objects table and indexes
+// have the given names.
+func NewMetabaseSearchRepository(db tagsql.DB, dialect Dialect, tables MetabaseTables, log *zap.Logger) *MetabaseSearchRepository {
 	return &MetabaseSearchRepository{
-		db:  db,
-		log: log,
+		db:      db,
+		dialect: dialect,
+		tables:  tables,
+		log:     log,
 	}
 }
 
+// table returns the reference of a query reading the objects table with the
+// index whose default name is index.
+func (r *MetabaseSearchRepository) table(index string) string {
+	return r.dialect.table(r.tables.objects(), r.tables.index(index))
+}
+
 func (r *MetabaseSearchRepository) GetMetadata(ctx context.Context, loc ObjectLocation) (obj ObjectInfo, err error) {
 	var clearMetadata *string
-	var status byte
 
-	err = r.db.QueryRowContext(ctx, `
-		SELECT
-			project_id, bucket_name, object_key, version, status,
-			encrypted_metadata_nonce, encrypted_metadata, encrypted_metadata_encrypted_key,
-			clear_metadata,
-			metasearch_queued_at
-		FROM objects
-		WHERE
-			(project_id, bucket_name, object_key) = ($1, $2, $3) AND
-			status <> `+statusPending+`
-		ORDER BY version DESC
-		LIMIT 1`,
-		loc.ProjectID, []byte(loc.BucketName), []byte(loc.ObjectKey),
-	).Scan(
+	query, args := r.getMetadataQuery(loc)
+	err = r.db.QueryRowContext(ctx, query, args...).Scan(
 		&obj.ProjectID, &obj.BucketName, &obj.ObjectKey, &obj.Version, &obj.Status,
 		&obj.Metadata.EncryptedMetadataNonce, &obj.Metadata.EncryptedMetadata, &obj.Metadata.EncryptedMetadataKey,
 		&clearMetadata,
+		&obj.Metadata.ExpiresAt,
 		&obj.MetaSearchQueuedAt,
+		&obj.UpdatedAt,
 	)
 
-	if errors.Is(err, sql.ErrNoRows) || status == deleteMarkerUnversioned || status == deleteMarkerVersioned {
+	if errors.Is(err, sql.ErrNoRows) || obj.Status == deleteMarkerUnversioned || obj.Status == deleteMarkerVersioned {
 		return ObjectInfo{}, fmt.Errorf("%w: object not found", ErrNotFound)
 	} else if err != nil {
 		return ObjectInfo{}, fmt.Errorf("%w: %v", ErrInternalError, err)
@@ -136,7 +337,105 @@ func (r *MetabaseSearchRepository) GetMetadata(ctx context.Context, loc ObjectLo
 	return obj, nil
 }
 
+// getMetadataQuery returns the query of GetMetadata and its arguments.
+func (r *MetabaseSearchRepository) getMetadataQuery(loc ObjectLocation) (query string, args []interface{}) {
+	query = `
+		SELECT
+			project_id, bucket_name, object_key, version, status,
+			encrypted_metadata_nonce, encrypted_metadata, encrypted_metadata_encrypted_key,
+			clear_metadata,
+			metasearch_metadata_expires_at,
+			metasearch_queued_at,
+			COALESCE(metasearch_updated_at, created_at)
+		FROM ` + r.tables.objects() + `
+		WHERE
+			(project_id, bucket_name, object_key) = ($1, $2, $3) AND
+			status <> ` + statusPending
+	args = []interface{}{loc.ProjectID, []byte(loc.BucketName), []byte(loc.ObjectKey)}
+
+	if loc.Version != 0 {
+		query += " AND version = $4"
+		args = append(args, loc.Version)
+	}
+
+	query += `
+		ORDER BY version DESC
+		LIMIT 1`
+	return query, args
+}
+
 func (r *MetabaseSearchRepository) UpdateMetadata(ctx context.Context, loc ObjectLocation, meta ObjectMetadata) (err error) {
+	return r.updateMetadata(ctx, loc, meta, false, "")
+}
+
+func (r *MetabaseSearchRepository) UpdateMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}, meta ObjectMetadata) (err error) {
+	return r.updateMetadataIfMatch(ctx, loc, expected, meta, false)
+}
+
+// updateMetadataIfMatch sets metadata for the latest version of an object if
+// its current clear metadata is equal to expected, and keeps a tombstone of
+// the current metadata if tombstone is true. Objects queued for migration do
+// not match, as their clear metadata is not up to date with the metadata set
+// by uplink.
+func (r *MetabaseSearchRepository) updateMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}, meta ObjectMetadata, tombstone bool) (err error) {
+	if expected == nil {
+		expected = map[string]interface{}{}
+	}
+	data, err := json.Marshal(expected)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+
+	err = r.updateMetadata(ctx, loc, meta, tombstone, "metasearch_queued_at IS NULL AND COALESCE(clear_metadata, '{}'::JSONB) = $10::JSONB", string(data))
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	// Distinguish a missing object from changed metadata
+	if _, err := r.GetMetadata(ctx, loc); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: metadata has been modified", ErrPreconditionFailed)
+}
+
+// updateMetadata sets metadata for the latest version of an object, and
+// records the change in the metadata history. If tombstone is true, the
+// current metadata is also copied to the tombstones in the same statement,
+// so that it can be restored. If condition is not empty, the object is only
+// updated if the condition holds. Condition arguments start at $10.
+func (r *MetabaseSearchRepository) updateMetadata(ctx context.Context, loc ObjectLocation, meta ObjectMetadata, tombstone bool, condition string, conditionArgs ...interface{}) (err error) {
+	if condition != "" {
+		condition = " AND " + condition
+	}
+
+	// Objects without metadata are not copied, so that deleting metadata
+	// twice keeps the tombstone of the first deletion.
+	var tombstoneCTE string
+	if tombstone {
+		tombstoneCTE = `, tombstone AS (
+			INSERT INTO metasearch_tombstones (
+				project_id, bucket_name, object_key, version,
+				encrypted_metadata_nonce, encrypted_metadata, encrypted_metadata_encrypted_key,
+				clear_metadata,
+				deleted_at
+			)
+			SELECT
+				project_id, bucket_name, object_key, version,
+				encrypted_metadata_nonce, encrypted_metadata, encrypted_metadata_encrypted_key,
+				clear_metadata,
+				now()
+			FROM old
+			WHERE clear_metadata IS NOT NULL OR encrypted_metadata IS NOT NULL
+			ON CONFLICT (project_id, bucket_name, object_key) DO UPDATE SET
+				version = excluded.version,
+				encrypted_metadata_nonce = excluded.encrypted_metadata_nonce,
+				encrypted_metadata = excluded.encrypted_metadata,
+				encrypted_metadata_encrypted_key = excluded.encrypted_metadata_encrypted_key,
+				clear_metadata = excluded.clear_metadata,
+				deleted_at = excluded.deleted_at
+		)`
+	}
+
 	// Marshal JSON metadata
 	var clearMetadata *string
 	if meta.ClearMetadata != nil {
@@ -149,31 +448,65 @@ func (r *MetabaseSearchRepository) UpdateMetadata(ctx context.Context, loc Objec
 	}
 
 	// Execute query
-	result, err := r.db.ExecContext(ctx, `
-		UPDATE objects
-		SET
-			encrypted_metadata_nonce=$4, encrypted_metadata=$5, encrypted_metadata_encrypted_key=$6,
-			clear_metadata = $7,
-			metasearch_queued_at=NULL
-		WHERE
-			(project_id, bucket_name, object_key) = ($1, $2, $3) AND
-			status IN `+statusesCommitted+` AND
-			version IN (
-				SELECT version
-				FROM objects
-				WHERE
-					(project_id, bucket_name, object_key) = ($1, $2, $3) AND
-					status <> `+statusPending+` AND
-					(expires_at IS NULL OR expires_at > now())
-				ORDER BY version DESC
-				LIMIT 1
-			)
+	result, err := r.execWithRetry(ctx, `
+		WITH old AS (
+			SELECT
+				project_id, bucket_name, object_key, version,
+				encrypted_metadata_nonce, encrypted_metadata, encrypted_metadata_encrypted_key,
+				clear_metadata
+			FROM `+r.tables.objects()+`
+			WHERE
+				(project_id, bucket_name, object_key) = ($1, $2, $3) AND
+				status IN `+statusesCommitted+` AND
+				version IN (
+					SELECT version
+					FROM `+r.tables.objects()+`
+					WHERE
+						(project_id, bucket_name, object_key) = ($1, $2, $3) AND
+						status <> `+statusPending+` AND
+						(expires_at IS NULL OR expires_at > now())
+					ORDER BY version DESC
+					LIMIT 1
+				)`+condition+`
+		)`+tombstoneCTE+`, updated AS (
+			UPDATE `+r.tables.objects()+`
+			SET
+				encrypted_metadata_nonce=$4, encrypted_metadata=$5, encrypted_metadata_encrypted_key=$6,
+				clear_metadata = $7,
+				metasearch_metadata_expires_at = $9,
+				metasearch_queued_at=NULL,
+				metasearch_updated_at=now()
+			WHERE
+				(project_id, bucket_name, object_key, version) IN (
+					SELECT project_id, bucket_name, object_key, version FROM old
+				)
+			RETURNING project_id, bucket_name, object_key, version, clear_metadata
+		), watermark AS (
+			`+incrementWatermark+`
+		)
+		INSERT INTO metasearch_history (
+			project_id, bucket_name, object_key, version,
+			actor, old_clear_metadata, new_clear_metadata, watermark
+		)
+		SELECT
+			updated.project_id, updated.bucket_name, updated.object_key, updated.version,
+			$8::TEXT, old.clear_metadata, updated.clear_metadata, watermark.watermark
+		FROM updated
+		JOIN old USING (project_id, bucket_name, object_key, version)
+		JOIN watermark USING (project_id, bucket_name)
 		`,
-		loc.ProjectID, []byte(loc.BucketName), []byte(loc.ObjectKey),
-		meta.EncryptedMetadataNonce, meta.EncryptedMetadata, meta.EncryptedMetadataKey,
-		clearMetadata,
+		append([]interface{}{
+			loc.ProjectID, []byte(loc.BucketName), []byte(loc.ObjectKey),
+			meta.EncryptedMetadataNonce, meta.EncryptedMetadata, meta.EncryptedMetadataKey,
+			clearMetadata,
+			actorFromContext(ctx),
+			meta.ExpiresAt,
+		}, conditionArgs...)...,
 	)
 
+	if isSerializationFailure(err) {
+		return fmt.Errorf("%w: object is being modified concurrently, retry later", ErrConflict)
+	}
 	if err != nil {
 		return fmt.Errorf("%w: unable update to object metadata: %v", ErrInternalError, err)
 	}
@@ -194,12 +527,24 @@ func (r *MetabaseSearchRepository) DeleteMetadata(ctx context.Context, loc Objec
 	return r.UpdateMetadata(ctx, loc, ObjectMetadata{})
 }
 
-func (r *MetabaseSearchRepository) QueryMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, startAfter ObjectLocation, batchSize int) (QueryMetadataResult, error) {
-	cq, err := json.Marshal(containsQuery)
+func (r *MetabaseSearchRepository) QueryMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int) (QueryMetadataResult, error) {
+	objects := make([]ObjectInfo, 0, batchSize)
+	result, err := r.QueryMetadataFunc(ctx, loc, containsQuery, sort, startAfter, asOf, batchSize, func(obj ObjectInfo) error {
+		objects = append(objects, obj)
+		return nil
+	})
 	if err != nil {
-		return QueryMetadataResult{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+		return QueryMetadataResult{}, err
+	}
+	result.Objects = objects
+	return result, nil
+}
+
+func (r *MetabaseSearchRepository) QueryMetadataFunc(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int, fn ObjectFunc) (QueryMetadataResult, error) {
+	match, err := parseMatch(containsQuery)
+	if err != nil {
+		return QueryMetadataResult{}, err
 	}
-	jsonContainsQuery := string(cq)
 
 	// Create query
 	query := `
@@ -207,81 +552,68 @@ func (r *MetabaseSearchRepository) QueryMetadata(ctx context.Context, loc Object
 			project_id, bucket_name, object_key, version, status,
 			encrypted_metadata_nonce, encrypted_metadata, encrypted_metadata_encrypted_key,
 			clear_metadata,
-			metasearch_queued_at
-		FROM objects@objects_pkey
-		WHERE
+			metasearch_queued_at,
+			total_plain_size, created_at, expires_at,
+			now()
+		FROM ` + r.table(defaultPrimaryKey) + `
 	`
 
-	// We make a subquery for each clear_metadata part. This is optimized for
-	// CockroachDB whose optimizer is very unpredictable when querying with
-	// multiple JSONB values, and would often scan the full table instead of
-	// using the GIN index.
-	args := make([]interface{}, 0)
-	containsQueryParts, err := splitToJSONLeaves(jsonContainsQuery)
-	if err != nil {
-		return QueryMetadataResult{}, fmt.Errorf("%w: %v", ErrInternalError, err)
-	}
-	if len(containsQueryParts) > MaxFindObjectsByClearMetadataQuerySize {
-		return QueryMetadataResult{}, fmt.Errorf("%s: too many values in metadata query", ErrBadRequest)
-	}
+	// Later pages of a search read the snapshot of the first page
+	query += r.dialect.asOf(asOf)
+	query += "WHERE "
 
-	if len(containsQueryParts) > 0 {
-		query += `(project_id, bucket_name, object_key, version) IN (`
-		for i, part := range containsQueryParts {
-			if i > 0 {
-				query += "INTERSECT \n"
-			}
-			query += fmt.Sprintf("(SELECT project_id, bucket_name, object_key, version FROM objects@objects_clear_metadata_idx WHERE clear_metadata @> $%d)\n", len(args)+1)
-			args = append(args, part)
-		}
-		query += `)`
-	}
-	if len(args) > 0 {
-		query += ` AND `
+	b := &matchQueryBuilder{loc: loc, dialect: r.dialect, tables: r.tables}
+	filter, err := b.searchFilter(match)
+	if err != nil {
+		return QueryMetadataResult{}, err
 	}
+	query += filter
 
-	query += fmt.Sprintf("project_id = $%d AND bucket_name = $%d AND status <> $%d AND (expires_at IS NULL OR expires_at > now())", len(args)+1, len(args)+2, len(args)+3)
-	args = append(args, loc.ProjectID, []byte(loc.BucketName), statusPending)
-
-	// Determine first and last object conditions
-	if startAfter.ProjectID.IsZero() {
-		// first page => use key prefix
-		query += fmt.Sprintf("\nAND (project_id, bucket_name, object_key, version) >= ($%d, $%d, $%d, $%d)", len(args)+1, len(args)+2, len(args)+3, len(args)+4)
-		args = append(args, loc.ProjectID, []byte(loc.BucketName), []byte(loc.ObjectKey), 0)
+	if sort != nil {
+		query += b.sortedPageRange(loc, sort, startAfter)
+		query += fmt.Sprintf("\nORDER BY %s LIMIT %s", b.sortedOrder(sort), b.arg(batchSize))
 	} else {
-		// subsequent pages => use startAfter
-		query += fmt.Sprintf("\nAND (project_id, bucket_name, object_key, version) > ($%d, $%d, $%d, $%d)", len(args)+1, len(args)+2, len(args)+3, len(args)+4)
-		args = append(args, loc.ProjectID, []byte(loc.BucketName), []byte(startAfter.ObjectKey), startAfter.Version)
+		query += b.pageRange(loc, startAfter)
+		query += fmt.Sprintf("\nORDER BY project_id, bucket_name, object_key, version LIMIT %s", b.arg(batchSize))
 	}
 
-	if loc.ObjectKey != "" {
-		prefixLimit := prefixLimit(loc.ObjectKey)
-		query += fmt.Sprintf("\nAND (project_id, bucket_name, object_key, version) < ($%d, $%d, $%d, $%d)", len(args)+1, len(args)+2, len(args)+3, len(args)+4)
-		args = append(args, loc.ProjectID, []byte(loc.BucketName), []byte(prefixLimit), 0)
-	}
-
-	query += fmt.Sprintf("\nORDER BY project_id, bucket_name, object_key, version LIMIT $%d", len(args)+1)
-	args = append(args, batchSize)
-
 	// Execute query
 	r.log.Debug("Querying objects by clear metadata",
 		zap.Stringer("Project", loc.ProjectID),
 		zap.String("Bucket", loc.BucketName),
 		zap.String("KeyPrefix", string(loc.ObjectKey)),
-		zap.String("ContainsQuery", jsonContainsQuery),
+		zap.Any("Match", containsQuery),
 		zap.Int("BatchSize", batchSize),
 		zap.String("StartAfterKey", string(startAfter.ObjectKey)),
+		zap.Time("AsOf", asOf),
 	)
 
 	var result QueryMetadataResult
-	result.Objects = make([]ObjectInfo, 0, batchSize)
+	result.AsOf = asOf
 
-	rows, err := r.db.QueryContext(ctx, query, args...)
+	rows, err := r.db.QueryContext(ctx, query, b.args...)
+	if isMissingIndexError(err) && !featureEnabled(ctx, FeatureIndexFallback) {
+		return QueryMetadataResult{}, fmt.Errorf("%w: searches are unavailable while the metadata index is rebuilt", ErrServiceUnavailable)
+	}
+	if isMissingIndexError(err) {
+		r.log.Warn("metadata index is unavailable, falling back to a sequential scan",
+			zap.Stringer("Project", loc.ProjectID),
+			zap.String("Bucket", loc.BucketName),
+			zap.Error(err),
+		)
+		mon.Counter("search_index_fallback").Inc(1)
+		if sort != nil {
+			// The bounded scan reads objects in key order
+			return QueryMetadataResult{}, fmt.Errorf("%w: sorted searches are unavailable while the metadata index is rebuilt", ErrServiceUnavailable)
+		}
+		return r.scanMetadata(ctx, loc, match, startAfter, asOf, batchSize, fn)
+	}
 	if err != nil {
 		return QueryMetadataResult{}, fmt.Errorf("%w: %v", ErrInternalError, err)
 	}
 	defer rows.Close()
 
+	var readTime time.Time
 	for rows.Next() {
 		var last ObjectInfo
 		var clearMetadata *string
@@ -290,17 +622,27 @@ func (r *MetabaseSearchRepository) QueryMetadata(ctx context.Context, loc Object
 			&last.Metadata.EncryptedMetadataNonce, &last.Metadata.EncryptedMetadata, &last.Metadata.EncryptedMetadataKey,
 			&clearMetadata,
 			&last.MetaSearchQueuedAt,
+			&last.Size, &last.CreatedAt, &last.ExpiresAt,
+			&readTime,
 		)
 		if err != nil {
 			return QueryMetadataResult{}, fmt.Errorf("%w: %v", ErrInternalError, err)
 		}
+		if result.AsOf.IsZero() {
+			result.AsOf = readTime
+		}
 
 		last.Metadata.ClearMetadata, err = parseJSON(clearMetadata)
 		if err != nil {
 			return QueryMetadataResult{}, fmt.Errorf("%w: %v", ErrInternalError, err)
 		}
 
-		result.Objects = append(result.Objects, last)
+		if err := fn(last); err != nil {
+			return result, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return QueryMetadataResult{}, fmt.Errorf("%w: %v", ErrInternalError, err)
 	}
 
 	return result, nil
@@ -318,22 +660,50 @@ func (r *MetabaseSearchRepository) MigrateMetadata(ctx context.Context, obj Obje
 		clearMetadata = &s
 	}
 
-	// Execute query
-	result, err := r.db.ExecContext(ctx, `
-		UPDATE objects
-		SET
-			encrypted_metadata_nonce=$6, encrypted_metadata=$7, encrypted_metadata_encrypted_key=$8,
-			clear_metadata = $9,
-			metasearch_queued_at=NULL
-		WHERE
-			(project_id, bucket_name, object_key, version) = ($1, $2, $3, $4) AND
-			metasearch_queued_at=$5
+	// Execute query. The migration is recorded in the metadata history, so
+	// that it is visible in the change feed of the bucket.
+	result, err := r.execWithRetry(ctx, `
+		WITH old AS (
+			SELECT project_id, bucket_name, object_key, version, clear_metadata
+			FROM `+r.tables.objects()+`
+			WHERE
+				(project_id, bucket_name, object_key, version) = ($1, $2, $3, $4) AND
+				metasearch_queued_at=$5
+		), updated AS (
+			UPDATE `+r.tables.objects()+`
+			SET
+				encrypted_metadata_nonce=$6, encrypted_metadata=$7, encrypted_metadata_encrypted_key=$8,
+				clear_metadata = $9,
+				metasearch_metadata_expires_at=NULL,
+				metasearch_queued_at=NULL,
+				metasearch_updated_at=now()
+			WHERE
+				(project_id, bucket_name, object_key, version) = ($1, $2, $3, $4) AND
+				metasearch_queued_at=$5
+			RETURNING project_id, bucket_name, object_key, version, clear_metadata
+		), watermark AS (
+			`+incrementWatermark+`
+		)
+		INSERT INTO metasearch_history (
+			project_id, bucket_name, object_key, version,
+			actor, old_clear_metadata, new_clear_metadata, watermark
+		)
+		SELECT
+			updated.project_id, updated.bucket_name, updated.object_key, updated.version,
+			$10::TEXT, old.clear_metadata, updated.clear_metadata, watermark.watermark
+		FROM updated
+		JOIN old USING (project_id, bucket_name, object_key, version)
+		JOIN watermark USING (project_id, bucket_name)
 		`,
 		obj.ProjectID, []byte(obj.BucketName), []byte(obj.ObjectKey), obj.Version, obj.MetaSearchQueuedAt,
 		obj.Metadata.EncryptedMetadataNonce, obj.Metadata.EncryptedMetadata, obj.Metadata.EncryptedMetadataKey,
 		clearMetadata,
+		migrationActor,
 	)
 
+	if isSerializationFailure(err) {
+		return fmt.Errorf("%w: object is being modified concurrently, retry later", ErrConflict)
+	}
 	if err != nil {
 		return fmt.Errorf("%w: unable update to object metadata: %v", ErrInternalError, err)
 	}
@@ -350,14 +720,44 @@ func (r *MetabaseSearchRepository) MigrateMetadata(ctx context.Context, obj Obje
 	return nil
 }
 
+// migrationPageSize is the number of queued objects read per query by
+// GetObjectsForMigration.
+const migrationPageSize = 500
+
+// GetObjectsForMigration reads the migration queue in pages, in the order of
+// metasearch_queued_at. The objects of a page are migrated after its query is
+// closed, so that no cursor is held open while they are migrated.
 func (r *MetabaseSearchRepository) GetObjectsForMigration(ctx context.Context, projectID uuid.UUID, startTime *time.Time, migrate ObjectMigrationFunc) error {
+	var after *ObjectInfo
+	for {
+		page, err := r.getMigrationPage(ctx, projectID, startTime, after, migrationPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range page {
+			if !migrate(ctx, obj) {
+				return nil
+			}
+		}
+
+		if len(page) < migrationPageSize {
+			return nil
+		}
+		after = &page[len(page)-1]
+	}
+}
+
+// getMigrationPage returns up to limit objects of the migration queue, after
+// the object after if it is set.
+func (r *MetabaseSearchRepository) getMigrationPage(ctx context.Context, projectID uuid.UUID, startTime *time.Time, after *ObjectInfo, limit int) ([]ObjectInfo, error) {
 	query := `
 		SELECT
 			project_id, bucket_name, object_key, version, status,
 			encrypted_metadata_nonce, encrypted_metadata, encrypted_metadata_encrypted_key,
 			clear_metadata,
 			metasearch_queued_at
-		FROM objects@objects_metasearch_queued_at_idx
+		FROM ` + r.table(defaultQueuedAtIndex) + `
 		WHERE
 			project_id=$1 AND
 			metasearch_queued_at IS NOT NULL
@@ -365,18 +765,25 @@ func (r *MetabaseSearchRepository) GetObjectsForMigration(ctx context.Context, p
 	args := []interface{}{projectID}
 
 	if startTime != nil {
-		query += " AND metasearch_queued_at >= $2 "
 		args = append(args, *startTime)
+		query += fmt.Sprintf(" AND metasearch_queued_at >= $%d ", len(args))
+	}
+	if after != nil {
+		// Objects with the same queue time are ordered by primary key
+		args = append(args, *after.MetaSearchQueuedAt, []byte(after.BucketName), []byte(after.ObjectKey), after.Version)
+		n := len(args)
+		query += fmt.Sprintf(" AND (metasearch_queued_at, bucket_name, object_key, version) > ($%d, $%d, $%d, $%d) ", n-3, n-2, n-1, n)
 	}
 
-	query += " ORDER BY metasearch_queued_at "
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY metasearch_queued_at, bucket_name, object_key, version LIMIT $%d", len(args))
 	rows, err := r.db.QueryContext(ctx, query, args...)
-
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInternalError, err)
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
 	}
 	defer rows.Close()
 
+	page := make([]ObjectInfo, 0, limit)
 	for rows.Next() {
 		var obj ObjectInfo
 		var clearMetadata *string
@@ -387,7 +794,7 @@ func (r *MetabaseSearchRepository) GetObjectsForMigration(ctx context.Context, p
 			&obj.MetaSearchQueuedAt,
 		)
 		if err != nil {
-			return fmt.Errorf("%w: %v", ErrInternalError, err)
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
 		}
 
 		obj.Metadata.ClearMetadata, err = parseJSON(clearMetadata)
@@ -401,9 +808,10 @@ func (r *MetabaseSearchRepository) GetObjectsForMigration(ctx context.Context, p
 			)
 		}
 
-		if !migrate(ctx, obj) {
-			break
-		}
+		page = append(page, obj)
 	}
-	return nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	return page, nil
 }
@@ -0,0 +1,62 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// maxSerializationRetries is the number of times a statement is retried
+	// after a serialization failure.
+	maxSerializationRetries = 5
+
+	// serializationRetryBackoff is the delay before the first retry. It
+	// doubles with every retry.
+	serializationRetryBackoff = 10 * time.Millisecond
+)
+
+// isSerializationFailure returns true if a statement failed because it
+// conflicted with a concurrent transaction, e.g. an uplink committing the same
+// object. CockroachDB reports these as retryable errors with the SQLSTATE
+// 40001, and the statement succeeds when it is retried.
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "40001"
+}
+
+// retrySerializationFailures calls fn, and calls it again with an
+// exponential backoff while it fails with a serialization failure.
+func retrySerializationFailures(ctx context.Context, fn func() error) error {
+	backoff := serializationRetryBackoff
+	for retries := 0; ; retries++ {
+		err := fn()
+		if err == nil || retries >= maxSerializationRetries || !isSerializationFailure(err) {
+			return err
+		}
+		mon.Counter("serialization_retries").Inc(1)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// execWithRetry executes a statement, retrying it on serialization failures.
+// The statement must be a single implicit transaction.
+func (r *MetabaseSearchRepository) execWithRetry(ctx context.Context, query string, args ...interface{}) (result sql.Result, err error) {
+	err = retrySerializationFailures(ctx, func() error {
+		result, err = r.db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
@@ -0,0 +1,66 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrySerializationFailures(t *testing.T) {
+	ctx := context.Background()
+	serializationFailure := &pgconn.PgError{Code: "40001", Message: "restart transaction: TransactionRetryWithProtoRefreshError"}
+
+	require.True(t, isSerializationFailure(fmt.Errorf("exec failed: %w", serializationFailure)))
+	require.False(t, isSerializationFailure(&pgconn.PgError{Code: "23505"}))
+	require.False(t, isSerializationFailure(errors.New("40001")))
+	require.False(t, isSerializationFailure(nil))
+
+	// Serialization failures are retried until the statement succeeds
+	calls := 0
+	err := retrySerializationFailures(ctx, func() error {
+		calls++
+		if calls < 3 {
+			return serializationFailure
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	// Other errors are not retried
+	calls = 0
+	other := errors.New("connection refused")
+	err = retrySerializationFailures(ctx, func() error {
+		calls++
+		return other
+	})
+	require.ErrorIs(t, err, other)
+	require.Equal(t, 1, calls)
+
+	// Retries are bounded
+	calls = 0
+	err = retrySerializationFailures(ctx, func() error {
+		calls++
+		return serializationFailure
+	})
+	require.True(t, isSerializationFailure(err))
+	require.Equal(t, maxSerializationRetries+1, calls)
+
+	// Retries stop when the context is canceled
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	calls = 0
+	err = retrySerializationFailures(canceled, func() error {
+		calls++
+		return serializationFailure
+	})
+	require.True(t, isSerializationFailure(err))
+	require.Equal(t, 1, calls)
+}
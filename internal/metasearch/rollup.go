@@ -0,0 +1,190 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxRollupKeys is the maximum number of metadata keys of a rollup.
+const maxRollupKeys = 5
+
+// RollupRequest contains fields for a rollup request. The objects are
+// selected like in a search.
+type RollupRequest struct {
+	SearchRequest
+
+	// GroupBy are the metadata keys whose values group the objects, e.g.
+	// ["project", "dataset"].
+	GroupBy []string `json:"groupBy"`
+
+	// Limit is the maximum number of rows, the largest total size first.
+	Limit int `json:"limit,omitempty"`
+}
+
+// RollupResponse contains fields for a rollup response.
+type RollupResponse struct {
+	Rows []RollupRow `json:"rows"`
+
+	// Truncated is true if there are more rows than the limit.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// RollupRow contains the number of objects and their total size for a
+// combination of metadata values. Values are null for the objects without
+// the key.
+type RollupRow struct {
+	Values map[string]interface{} `json:"values"`
+	Count  int64                  `json:"count"`
+	Size   int64                  `json:"size"`
+}
+
+func (r *MetabaseSearchRepository) RollupMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, groupBy []string, limit int) ([]RollupRow, error) {
+	match, err := parseMatch(containsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &matchQueryBuilder{loc: loc, dialect: r.dialect, tables: r.tables}
+	columns := make([]string, 0, len(groupBy)+2)
+	positions := make([]string, 0, len(groupBy))
+	for i, key := range groupBy {
+		columns = append(columns, fmt.Sprintf("(clear_metadata -> %s)::TEXT", b.arg(key)))
+		positions = append(positions, fmt.Sprint(i+1))
+	}
+	columns = append(columns, "count(*)", "COALESCE(sum(total_plain_size), 0)")
+
+	filter, err := b.searchFilter(match)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT " + strings.Join(columns, ", ") + "\nFROM " + b.table(defaultPrimaryKey) + " WHERE " + filter + b.pageRange(loc, ObjectLocation{}) +
+		fmt.Sprintf("\nGROUP BY %s ORDER BY %d DESC, %s LIMIT %s", strings.Join(positions, ", "), len(columns), strings.Join(positions, ", "), b.arg(limit))
+
+	rows, err := r.db.QueryContext(ctx, query, b.args...)
+	if isMissingIndexError(err) {
+		// Rolling up without the index would scan the whole bucket
+		return nil, fmt.Errorf("%w: rollups are unavailable while the metadata index is rebuilt", ErrServiceUnavailable)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	var result []RollupRow
+	for rows.Next() {
+		row := RollupRow{Values: make(map[string]interface{}, len(groupBy))}
+		values := make([]*string, len(groupBy))
+		dest := make([]interface{}, 0, len(columns))
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &row.Count, &row.Size)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+
+		for i, key := range groupBy {
+			var value interface{}
+			if values[i] != nil {
+				if err := unmarshalJSON([]byte(*values[i]), &value); err != nil {
+					return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+				}
+			}
+			row.Values[key] = value
+		}
+
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return result, nil
+}
+
+// HandleRollup reports the number and total size of the objects matched by a
+// search, grouped by metadata values, so that storage owners can attribute
+// their usage to logical datasets.
+func (s *Server) HandleRollup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request RollupRequest
+
+	err := s.validateRequest(ctx, r, &request.BaseRequest, &request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = s.validateRollupRequest(&request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	if request.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, request.timeout)
+		defer cancel()
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	// One more row is requested to detect truncated results
+	rows, err := s.Repo.RollupMetadata(ctx, request.EncryptedLocation, request.Match, request.GroupBy, request.Limit+1)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		s.errorResponse(w, fmt.Errorf("%w: rollup timed out", ErrServiceUnavailable))
+		return
+	}
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	response := RollupResponse{Rows: rows}
+	if response.Rows == nil {
+		response.Rows = make([]RollupRow, 0)
+	}
+	if len(response.Rows) > request.Limit {
+		response.Rows = response.Rows[:request.Limit]
+		response.Truncated = true
+	}
+
+	s.jsonResponse(w, http.StatusOK, response)
+}
+
+func (s *Server) validateRollupRequest(request *RollupRequest) error {
+	err := s.validateSearchRequest(&request.SearchRequest)
+	if err != nil {
+		return err
+	}
+	err = validateWithoutObjects(&request.SearchRequest, "rollup")
+	if err != nil {
+		return err
+	}
+
+	if len(request.GroupBy) == 0 || len(request.GroupBy) > maxRollupKeys {
+		return fmt.Errorf("%w: groupBy must have between 1 and %d keys", ErrBadRequest, maxRollupKeys)
+	}
+	seen := make(map[string]bool, len(request.GroupBy))
+	for _, key := range request.GroupBy {
+		if key == "" || seen[key] {
+			return fmt.Errorf("%w: groupBy keys must be unique and not empty", ErrBadRequest)
+		}
+		seen[key] = true
+	}
+
+	if request.Limit <= 0 || request.Limit > maxBatchSize {
+		request.Limit = defaultBatchSize
+	}
+	return nil
+}
@@ -0,0 +1,798 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+	"storj.io/metasearch/signature"
+)
+
+const (
+	scheduleCheckInterval = 1 * time.Minute
+
+	// scheduleLease is the time after which a run that did not finish, e.g.
+	// because the server stopped, is retried.
+	scheduleLease = 10 * time.Minute
+
+	// maxDueSchedules is the maximum number of schedules claimed at once by
+	// a server instance.
+	maxDueSchedules = 10
+
+	// maxSchedules is the maximum number of schedules in a bucket.
+	maxSchedules = 100
+
+	// maxScheduleResults is the maximum number of results posted to the
+	// webhook of a schedule.
+	maxScheduleResults = 10000
+
+	scheduleDeliveryTimeout = 30 * time.Second
+	maxScheduleNameLength   = 200
+)
+
+// Statuses of the last run of a schedule.
+const (
+	scheduleDelivered = "delivered"
+	scheduleFailed    = "failed"
+)
+
+// scheduleClient posts the results of scheduled searches to public
+// addresses, and privateScheduleClient to any address. Redirects are not
+// followed, so that webhooks cannot send results to other destinations.
+var (
+	scheduleClient        = newScheduleClient(publicAddressOnly)
+	privateScheduleClient = newScheduleClient(nil)
+)
+
+func newScheduleClient(control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: scheduleDeliveryTimeout,
+		Control: control,
+	}
+	return &http.Client{
+		Timeout: scheduleDeliveryTimeout,
+		// Webhooks are dialed directly, so that the address checked is the
+		// address of the webhook
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: scheduleDeliveryTimeout,
+			MaxIdleConnsPerHost: 1,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicAddressOnly fails to dial loopback, private, link-local and other
+// non-public addresses. It is called with the resolved address, so host
+// names resolving to internal addresses are rejected too.
+func publicAddressOnly(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddress(addr) {
+		return fmt.Errorf("webhook address %s is not public", addr)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddress returns true if addr is a public unicast address.
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// Schedule is a recurring search whose results are posted to a webhook.
+type Schedule struct {
+	ID         uuid.UUID
+	ProjectID  uuid.UUID
+	BucketName string
+
+	// Owner is the fingerprint of the access grant that created the
+	// schedule.
+	Owner string
+
+	Name string
+	Cron string
+	// Request is the search request, as submitted in JSON.
+	Request []byte
+	URL     string
+
+	// Credentials is the sealed Authorization header of the access grant
+	// that created the schedule. Runs authenticate with it, so that revoking
+	// the access grant stops the schedule.
+	Credentials []byte
+	// Secret is the key of the signature of webhook requests.
+	Secret string
+
+	NextRunAt  time.Time
+	LastRunAt  *time.Time
+	LastStatus string
+	LastError  string
+	CreatedAt  time.Time
+}
+
+// ScheduleRequest contains fields for a schedule creation request.
+type ScheduleRequest struct {
+	Name string `json:"name"`
+	// Cron is a cron expression in UTC, e.g. "0 2 * * *" for every night
+	// at 2:00.
+	Cron string `json:"cron"`
+	// URL is the webhook the results are posted to.
+	URL string `json:"url"`
+	// Search is the search request executed at each run.
+	Search json.RawMessage `json:"search"`
+}
+
+// ScheduleResponse is a schedule, as returned by the API.
+type ScheduleResponse struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Cron   string          `json:"cron"`
+	URL    string          `json:"url"`
+	Search json.RawMessage `json:"search"`
+
+	// Secret is the key of the signature of webhook requests. It is only
+	// returned when the schedule is created.
+	Secret string `json:"secret,omitempty"`
+
+	NextRunAt  time.Time  `json:"nextRunAt"`
+	LastRunAt  *time.Time `json:"lastRunAt,omitempty"`
+	LastStatus string     `json:"lastStatus,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// ScheduleDelivery is the body of the webhook requests of a schedule.
+type ScheduleDelivery struct {
+	Schedule string         `json:"schedule"`
+	Name     string         `json:"name"`
+	Bucket   string         `json:"bucket"`
+	RunAt    time.Time      `json:"runAt"`
+	Results  []SearchResult `json:"results"`
+
+	// Truncated is true if the search has more results than were posted.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func scheduleResponse(schedule Schedule) ScheduleResponse {
+	return ScheduleResponse{
+		ID:         schedule.ID.String(),
+		Name:       schedule.Name,
+		Cron:       schedule.Cron,
+		URL:        schedule.URL,
+		Search:     schedule.Request,
+		NextRunAt:  schedule.NextRunAt,
+		LastRunAt:  schedule.LastRunAt,
+		LastStatus: schedule.LastStatus,
+		LastError:  schedule.LastError,
+		CreatedAt:  schedule.CreatedAt,
+	}
+}
+
+const scheduleColumns = `
+	id, project_id, bucket_name, owner,
+	name, cron, request, url, credentials, secret,
+	next_run_at, last_run_at, last_status, last_error, created_at
+`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSchedule(row rowScanner) (Schedule, error) {
+	var schedule Schedule
+	var bucket []byte
+	var request string
+	err := row.Scan(
+		&schedule.ID, &schedule.ProjectID, &bucket, &schedule.Owner,
+		&schedule.Name, &schedule.Cron, &request, &schedule.URL, &schedule.Credentials, &schedule.Secret,
+		&schedule.NextRunAt, &schedule.LastRunAt, &schedule.LastStatus, &schedule.LastError, &schedule.CreatedAt,
+	)
+	schedule.BucketName = string(bucket)
+	schedule.Request = []byte(request)
+	return schedule, err
+}
+
+func (r *MetabaseSearchRepository) querySchedules(ctx context.Context, query string, args ...interface{}) ([]Schedule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	var schedules []Schedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return schedules, nil
+}
+
+func (r *MetabaseSearchRepository) CreateSchedule(ctx context.Context, schedule Schedule) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO metasearch_schedules (`+scheduleColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		`,
+		schedule.ID, schedule.ProjectID, []byte(schedule.BucketName), schedule.Owner,
+		schedule.Name, schedule.Cron, string(schedule.Request), schedule.URL, schedule.Credentials, schedule.Secret,
+		schedule.NextRunAt, schedule.LastRunAt, schedule.LastStatus, schedule.LastError, schedule.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create schedule: %v", ErrInternalError, err)
+	}
+	return nil
+}
+
+func (r *MetabaseSearchRepository) GetSchedule(ctx context.Context, id uuid.UUID) (Schedule, error) {
+	schedule, err := scanSchedule(r.db.QueryRowContext(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM metasearch_schedules
+		WHERE id = $1
+		`,
+		id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Schedule{}, fmt.Errorf("%w: schedule not found", ErrNotFound)
+	} else if err != nil {
+		return Schedule{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	return schedule, nil
+}
+
+func (r *MetabaseSearchRepository) ListSchedules(ctx context.Context, projectID uuid.UUID, bucket string) ([]Schedule, error) {
+	return r.querySchedules(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM metasearch_schedules
+		WHERE (project_id, bucket_name) = ($1, $2)
+		ORDER BY created_at, id
+		`,
+		projectID, []byte(bucket),
+	)
+}
+
+func (r *MetabaseSearchRepository) ListProjectSchedules(ctx context.Context, projectID uuid.UUID) ([]Schedule, error) {
+	return r.querySchedules(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM metasearch_schedules
+		WHERE project_id = $1
+		ORDER BY bucket_name, created_at, id
+		`,
+		projectID,
+	)
+}
+
+func (r *MetabaseSearchRepository) DeleteSchedule(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM metasearch_schedules
+		WHERE id = $1
+		`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to delete schedule: %v", ErrInternalError, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: unable to get rows affected: %v", ErrInternalError, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: schedule not found", ErrNotFound)
+	}
+	return nil
+}
+
+func (r *MetabaseSearchRepository) ClaimDueSchedules(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]Schedule, error) {
+	// Postponing the next run claims the schedules, so that other server
+	// instances do not run them at the same time. PostgreSQL has no UPDATE
+	// with LIMIT, and re-checks the outer condition of concurrently claimed
+	// schedules.
+	return r.querySchedules(ctx, `
+		UPDATE metasearch_schedules
+		SET next_run_at = $2
+		WHERE
+			next_run_at <= $1 AND
+			id IN (
+				SELECT id
+				FROM metasearch_schedules
+				WHERE next_run_at <= $1
+				ORDER BY next_run_at
+				LIMIT $3
+			)
+		RETURNING `+scheduleColumns,
+		now, leaseUntil, limit,
+	)
+}
+
+func (r *MetabaseSearchRepository) SaveScheduleRun(ctx context.Context, schedule Schedule) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE metasearch_schedules
+		SET next_run_at = $2, last_run_at = $3, last_status = $4, last_error = $5
+		WHERE id = $1
+		`,
+		schedule.ID, schedule.NextRunAt, schedule.LastRunAt, schedule.LastStatus, schedule.LastError,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to save schedule run: %v", ErrInternalError, err)
+	}
+	return nil
+}
+
+// HandleCreateSchedule creates a recurring search, whose results are posted
+// to a webhook. The schedule runs with the access grant of the request, which
+// is stored encrypted.
+func (s *Server) HandleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var base BaseRequest
+	var body ScheduleRequest
+
+	err := s.validateRequest(ctx, r, &base, &body)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = base.Authorizer.Authorize(ctx, base.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	if s.Config.ScheduleSecret == "" {
+		s.errorResponse(w, fmt.Errorf("%w: scheduled searches are disabled", ErrNotFound))
+		return
+	}
+
+	credentials := r.Header.Get("Authorization")
+	if !strings.HasPrefix(credentials, "Bearer ") {
+		s.errorResponse(w, fmt.Errorf("%w: scheduled searches require an access grant in the Authorization header", ErrBadRequest))
+		return
+	}
+
+	schedule, err := s.newSchedule(base, body, grantFingerprint(r), credentials)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	schedules, err := s.Repo.ListSchedules(ctx, base.Location.ProjectID, base.Location.BucketName)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+	if len(schedules) >= maxSchedules {
+		s.errorResponse(w, fmt.Errorf("%w: a bucket can have at most %d schedules", ErrUnprocessableEntity, maxSchedules))
+		return
+	}
+
+	err = s.Repo.CreateSchedule(ctx, schedule)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	response := scheduleResponse(schedule)
+	response.Secret = schedule.Secret
+	w.Header().Set("Location", apiPath(r, "/metasearch/"+url.PathEscape(schedule.BucketName)+"/schedules/"+schedule.ID.String()))
+	s.jsonResponse(w, http.StatusCreated, response)
+}
+
+// newSchedule validates a schedule request on the bucket of base, and returns
+// the new schedule, which runs with credentials, the Authorization header of
+// the access grant of owner.
+func (s *Server) newSchedule(base BaseRequest, body ScheduleRequest, owner string, credentials string) (Schedule, error) {
+	cron, err := validateScheduleRequest(&body)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	request := SearchRequest{BaseRequest: base}
+	if err = newJSONDecoder(bytes.NewReader(body.Search)).Decode(&request); err != nil {
+		return Schedule{}, fmt.Errorf("%w: error decoding search: %w", ErrBadRequest, err)
+	}
+	if err = validateJobRequest(&request); err != nil {
+		return Schedule{}, err
+	}
+	if err = s.validateSearchRequest(&request); err != nil {
+		return Schedule{}, err
+	}
+
+	sealed, err := sealCredentials(s.Config.ScheduleSecret, []byte(credentials))
+	if err != nil {
+		return Schedule{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	id, err := uuid.New()
+	if err != nil {
+		return Schedule{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return Schedule{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	now := time.Now()
+	return Schedule{
+		ID:          id,
+		ProjectID:   base.Location.ProjectID,
+		BucketName:  base.Location.BucketName,
+		Owner:       owner,
+		Name:        body.Name,
+		Cron:        body.Cron,
+		Request:     body.Search,
+		URL:         body.URL,
+		Credentials: sealed,
+		Secret:      hex.EncodeToString(secret),
+		NextRunAt:   cron.next(now),
+		CreatedAt:   now,
+	}, nil
+}
+
+// validateScheduleRequest validates the name, cron expression and webhook of
+// a schedule, and returns its parsed cron expression.
+func validateScheduleRequest(body *ScheduleRequest) (*cronSchedule, error) {
+	if body.Name == "" || len(body.Name) > maxScheduleNameLength {
+		return nil, fmt.Errorf("%w: name must have between 1 and %d characters", ErrBadRequest, maxScheduleNameLength)
+	}
+
+	cron, err := parseCron(body.Cron)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(body.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL", ErrBadRequest)
+	}
+
+	if len(body.Search) == 0 {
+		body.Search = json.RawMessage(`{}`)
+	}
+	return cron, nil
+}
+
+// HandleListSchedules lists the schedules of a bucket created by the access
+// grant of the request.
+func (s *Server) HandleListSchedules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var base BaseRequest
+
+	err := s.validateScheduleAccess(r, &base)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	schedules, err := s.Repo.ListSchedules(ctx, base.Location.ProjectID, base.Location.BucketName)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	owner := grantFingerprint(r)
+	response := struct {
+		Schedules []ScheduleResponse `json:"schedules"`
+	}{
+		Schedules: []ScheduleResponse{},
+	}
+	for _, schedule := range schedules {
+		if schedule.Owner == owner {
+			response.Schedules = append(response.Schedules, scheduleResponse(schedule))
+		}
+	}
+
+	s.jsonResponse(w, http.StatusOK, response)
+}
+
+// HandleGetSchedule returns a schedule and the status of its last run.
+func (s *Server) HandleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := s.getSchedule(r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	s.jsonResponse(w, http.StatusOK, scheduleResponse(schedule))
+}
+
+// HandleDeleteSchedule deletes a schedule.
+func (s *Server) HandleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := s.getSchedule(r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = s.Repo.DeleteSchedule(r.Context(), schedule.ID)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateScheduleAccess authenticates and authorizes a request on the
+// schedules of a bucket.
+func (s *Server) validateScheduleAccess(r *http.Request, base *BaseRequest) error {
+	ctx := r.Context()
+
+	err := s.validateRequest(ctx, r, base, nil)
+	if err != nil {
+		return err
+	}
+
+	err = base.Authorizer.Authorize(ctx, base.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		return err
+	}
+
+	if s.Config.ScheduleSecret == "" {
+		return fmt.Errorf("%w: scheduled searches are disabled", ErrNotFound)
+	}
+	return nil
+}
+
+// getSchedule returns the schedule of a schedule request, if it belongs to
+// the project, bucket and access grant of the request.
+func (s *Server) getSchedule(r *http.Request) (Schedule, error) {
+	var base BaseRequest
+	err := s.validateScheduleAccess(r, &base)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	id, err := uuid.FromString(mux.Vars(r)["schedule"])
+	if err != nil {
+		return Schedule{}, fmt.Errorf("%w: schedule not found", ErrNotFound)
+	}
+
+	schedule, err := s.Repo.GetSchedule(r.Context(), id)
+	if err != nil {
+		return Schedule{}, err
+	}
+	if schedule.ProjectID != base.Location.ProjectID || schedule.BucketName != base.Location.BucketName || schedule.Owner != grantFingerprint(r) {
+		return Schedule{}, fmt.Errorf("%w: schedule not found", ErrNotFound)
+	}
+	return schedule, nil
+}
+
+// runSchedules periodically runs the schedules that are due.
+func (s *Server) runSchedules() {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if s.ReadOnly() {
+			continue
+		}
+		s.runDueSchedules(context.Background(), time.Now())
+	}
+}
+
+// runDueSchedules claims and runs the schedules that are due at now.
+func (s *Server) runDueSchedules(ctx context.Context, now time.Time) {
+	schedules, err := s.Repo.ClaimDueSchedules(ctx, now, now.Add(scheduleLease), maxDueSchedules)
+	if err != nil {
+		s.Logger.Error("cannot claim due schedules", zap.Error(err))
+		return
+	}
+
+	for _, schedule := range schedules {
+		s.runSchedule(ctx, schedule, now)
+	}
+}
+
+// runSchedule runs a schedule, posts its results and saves the status of the
+// run and the time of the next run.
+func (s *Server) runSchedule(ctx context.Context, schedule Schedule, now time.Time) {
+	err := s.deliverSchedule(ctx, schedule, now)
+	schedule.LastRunAt = &now
+	if err != nil {
+		s.Logger.Warn("scheduled search failed", zap.Stringer("Schedule", schedule.ID), zap.Error(err))
+		schedule.LastStatus = scheduleFailed
+		schedule.LastError = err.Error()
+		var e *ErrorResponse
+		if errors.As(err, &e) && e.StatusCode >= http.StatusInternalServerError {
+			// Internal errors are only reported in the logs.
+			schedule.LastError = e.Message
+		}
+	} else {
+		schedule.LastStatus = scheduleDelivered
+		schedule.LastError = ""
+	}
+
+	cron, err := parseCron(schedule.Cron)
+	if err == nil {
+		schedule.NextRunAt = cron.next(now)
+	}
+	if schedule.NextRunAt.IsZero() || err != nil {
+		schedule.NextRunAt = now.Add(maxCronSearch)
+	}
+
+	err = s.Repo.SaveScheduleRun(ctx, schedule)
+	if err != nil {
+		s.Logger.Error("cannot save schedule run", zap.Stringer("Schedule", schedule.ID), zap.Error(err))
+	}
+}
+
+// deliverSchedule runs the search of a schedule with its access grant and
+// posts the results to its webhook.
+func (s *Server) deliverSchedule(ctx context.Context, schedule Schedule, now time.Time) error {
+	credentials, err := openCredentials(s.Config.ScheduleSecret, schedule.Credentials)
+	if err != nil {
+		return fmt.Errorf("%w: cannot open credentials: %v", ErrInternalError, err)
+	}
+
+	// Authenticate again, so that revoked access grants cannot search
+	authRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	authRequest.Header.Set("Authorization", string(credentials))
+	projectID, encryptor, authorizer, err := s.Auth.Authenticate(ctx, authRequest)
+	if err != nil {
+		return err
+	}
+	if projectID != schedule.ProjectID {
+		return fmt.Errorf("%w: the access grant no longer belongs to the project", ErrForbidden)
+	}
+
+	request := SearchRequest{BaseRequest: BaseRequest{ProjectID: projectID, Authorizer: authorizer}}
+	if err = setLocation(&request.BaseRequest, projectID, encryptor, schedule.BucketName, ""); err != nil {
+		return err
+	}
+	if err = authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata); err != nil {
+		return err
+	}
+	if err = newJSONDecoder(bytes.NewReader(schedule.Request)).Decode(&request); err != nil {
+		return fmt.Errorf("%w: error decoding search: %w", ErrBadRequest, err)
+	}
+	if err = s.validateSearchRequest(&request); err != nil {
+		return err
+	}
+
+	delivery := ScheduleDelivery{
+		Schedule: schedule.ID.String(),
+		Name:     schedule.Name,
+		Bucket:   schedule.BucketName,
+		RunAt:    now,
+		Results:  []SearchResult{},
+	}
+	request.BatchSize = maxBatchSize
+	for {
+		result, err := s.searchMetadata(ctx, &request)
+		if err != nil {
+			return err
+		}
+
+		delivery.Results = append(delivery.Results, result.Results...)
+		if len(delivery.Results) > maxScheduleResults {
+			delivery.Results = delivery.Results[:maxScheduleResults]
+			delivery.Truncated = true
+			break
+		}
+		if result.PageToken == "" {
+			break
+		}
+
+		request.startAfter, request.asOf, request.sort, err = parseSortedPageToken(result.PageToken)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.postSchedule(ctx, schedule, delivery)
+}
+
+// postSchedule posts the results of a schedule run to its webhook, signed
+// with the secret of the schedule.
+func (s *Server) postSchedule(ctx context.Context, schedule Schedule, delivery ScheduleDelivery) error {
+	body, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, schedule.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: invalid webhook: %v", ErrBadRequest, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "metasearch")
+	if err = signature.Sign(req, schedule.Secret, body, time.Now()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	client := scheduleClient
+	if s.Config.PrivateWebhooks {
+		client = privateScheduleClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// sealCredentials encrypts credentials with AES-GCM, with a key derived from
+// the secret. The nonce is prepended to the ciphertext.
+func sealCredentials(secret string, credentials []byte) ([]byte, error) {
+	aead, err := credentialsCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, credentials, nil), nil
+}
+
+// openCredentials decrypts credentials sealed with sealCredentials.
+func openCredentials(secret string, sealed []byte) ([]byte, error) {
+	aead, err := credentialsCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed credentials are too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func credentialsCipher(secret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,190 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+
+	"storj.io/metasearch/signature"
+)
+
+func TestSchedules(t *testing.T) {
+	server := testServer()
+	server.Config.ScheduleSecret = "testsecret"
+	server.Config.PrivateWebhooks = true
+
+	for key, metadata := range map[string]string{
+		"foo.txt": `{"retention": "1y"}`,
+		"bar.txt": `{"type": "photo"}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	type webhookRequest struct {
+		request *http.Request
+		body    []byte
+	}
+	requests := make(chan webhookRequest, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- webhookRequest{request: r, body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	// Create
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket/schedules", `{
+		"name": "missing retention",
+		"cron": "0 2 * * *",
+		"url": "`+webhook.URL+`",
+		"search": {"filter": "retention == null"}
+	}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var created ScheduleResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	assert.Equal(t, rr.Header().Get("Location"), "/v1/metasearch/testbucket/schedules/"+created.ID)
+	require.NotEmpty(t, created.Secret)
+	require.Equal(t, 2, created.NextRunAt.UTC().Hour())
+	verifier := signature.NewVerifier(created.Secret, signature.DefaultMaxAge)
+
+	// The access grant is not stored in clear
+	id := mustParseUUID(t, created.ID)
+	schedule := testRepo(server).schedules[id]
+	require.NotContains(t, string(schedule.Credentials), "testtoken")
+
+	// Nothing is due yet
+	server.runDueSchedules(context.Background(), time.Now())
+	require.Empty(t, requests)
+
+	// Run
+	server.runDueSchedules(context.Background(), created.NextRunAt.Add(time.Second))
+	require.Len(t, requests, 1)
+	received := <-requests
+	require.NoError(t, verifier.Verify(received.request, received.body, time.Now()))
+	var delivery ScheduleDelivery
+	require.NoError(t, json.Unmarshal(received.body, &delivery))
+	assert.Equal(t, delivery.Schedule, created.ID)
+	assert.Equal(t, delivery.Name, "missing retention")
+	require.Len(t, delivery.Results, 1)
+	assert.Equal(t, delivery.Results[0].Path, "sj://testbucket/bar.txt")
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/schedules/"+created.ID, "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var status ScheduleResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.Equal(t, status.LastStatus, scheduleDelivered)
+	assert.Equal(t, status.Secret, "")
+	require.Equal(t, created.NextRunAt.Add(24*time.Hour).Unix(), status.NextRunAt.Unix())
+
+	// Failed deliveries are reported in the status
+	webhook.Close()
+	server.runDueSchedules(context.Background(), status.NextRunAt.Add(time.Second))
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/schedules/"+created.ID, "")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.Equal(t, status.LastStatus, scheduleFailed)
+	require.Contains(t, status.LastError, "webhook request failed")
+
+	// Webhooks on internal addresses are not dialed unless they are allowed
+	server.Config.PrivateWebhooks = false
+	server.runDueSchedules(context.Background(), status.NextRunAt.Add(time.Second))
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/schedules/"+created.ID, "")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.Equal(t, status.LastStatus, scheduleFailed)
+	require.Contains(t, status.LastError, "is not public")
+
+	// List
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/schedules", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var list struct {
+		Schedules []ScheduleResponse `json:"schedules"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	require.Len(t, list.Schedules, 1)
+	assert.Equal(t, list.Schedules[0].ID, created.ID)
+
+	// Schedules are only visible to the access grant that created them
+	rr = httptest.NewRecorder()
+	r := testRequest(http.MethodGet, "/metasearch/testbucket/schedules/"+created.ID, "")
+	r.Header.Set("Authorization", "Bearer othertoken")
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	// Delete
+	rr = handleRequest(server, http.MethodDelete, "/metasearch/testbucket/schedules/"+created.ID, "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/schedules/"+created.ID, "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
+
+func TestScheduleErrors(t *testing.T) {
+	server := testServer()
+
+	body := `{"name": "report", "cron": "@daily", "url": "https://example.com/hook"}`
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket/schedules", body)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	server.Config.ScheduleSecret = "testsecret"
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/schedules", body)
+	assert.Equal(t, rr.Code, http.StatusCreated)
+
+	for _, body := range []string{
+		`{"cron": "@daily", "url": "https://example.com/hook"}`,
+		`{"name": "report", "cron": "daily", "url": "https://example.com/hook"}`,
+		`{"name": "report", "cron": "@daily", "url": "ftp://example.com/hook"}`,
+		`{"name": "report", "cron": "@daily", "url": "/hook"}`,
+		`{"name": "report", "cron": "@daily", "url": "https://example.com/hook", "search": {"pageToken": "abc"}}`,
+		`{"name": "report", "cron": "@daily", "url": "https://example.com/hook", "search": {"filter": "["}}`,
+	} {
+		rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket/schedules", body)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":          true,
+		"2606:2800:220:1::1":     true,
+		"127.0.0.1":              false,
+		"::1":                    false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.1":            false,
+		"169.254.169.254":        false,
+		"100.64.0.1":             false,
+		"0.0.0.0":                false,
+		"fd00::1":                false,
+		"fe80::1":                false,
+		"::ffff:169.254.169.254": false,
+	} {
+		require.Equal(t, public, publicAddress(netip.MustParseAddr(addr)), addr)
+	}
+
+	require.Error(t, publicAddressOnly("tcp", "169.254.169.254:80", nil))
+	require.NoError(t, publicAddressOnly("tcp", "93.184.216.34:443", nil))
+}
+
+func TestSealCredentials(t *testing.T) {
+	sealed, err := sealCredentials("secret", []byte("Bearer token"))
+	require.NoError(t, err)
+
+	opened, err := openCredentials("secret", sealed)
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token", string(opened))
+
+	_, err = openCredentials("other", sealed)
+	require.Error(t, err)
+	_, err = openCredentials("secret", sealed[:4])
+	require.Error(t, err)
+}
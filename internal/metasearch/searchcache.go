@@ -0,0 +1,143 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"storj.io/common/uuid"
+)
+
+// searchCacheHeader is the response header telling whether a search response
+// was served from the cache.
+const searchCacheHeader = "X-Metasearch-Cache"
+
+// SearchCache caches search responses for a short time, so that dashboards
+// re-issuing identical searches every few seconds do not reach the metabase.
+// Responses are keyed by the project and the entity tag of the search, which
+// is derived from the watermark of the bucket in the database. Cached
+// responses are not served after a metadata change made by any server
+// instance, or after objects uploaded by uplink are indexed. Objects deleted
+// by uplink without a metadata change are visible once the response expires.
+type SearchCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mutex   sync.Mutex
+	entries map[searchCacheKey]*cachedSearch
+}
+
+type searchCacheKey struct {
+	projectID uuid.UUID
+	etag      string
+}
+
+type cachedSearch struct {
+	expires  time.Time
+	response SearchResponse
+}
+
+// NewSearchCache creates a new SearchCache. Responses are kept for the given
+// duration, and at most maxEntries responses are kept.
+func NewSearchCache(ttl time.Duration, maxEntries int) *SearchCache {
+	return &SearchCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[searchCacheKey]*cachedSearch),
+	}
+}
+
+// Get returns the cached response of a search, if it has not expired.
+func (c *SearchCache) Get(projectID uuid.UUID, etag string) (SearchResponse, bool) {
+	now := time.Now()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := searchCacheKey{projectID, etag}
+	entry, ok := c.entries[key]
+	if !ok {
+		return SearchResponse{}, false
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return SearchResponse{}, false
+	}
+	return entry.response, true
+}
+
+// Put caches the response of a search. The response must not be modified
+// afterwards.
+func (c *SearchCache) Put(projectID uuid.UUID, etag string, response SearchResponse) {
+	now := time.Now()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		c.cleanup(now)
+	}
+
+	c.entries[searchCacheKey{projectID, etag}] = &cachedSearch{
+		expires:  now.Add(c.ttl),
+		response: response,
+	}
+}
+
+// cleanup removes expired entries. If no entry has expired, the entry that
+// expires first is removed. Must be called while c.mutex is locked.
+func (c *SearchCache) cleanup(now time.Time) {
+	var oldestKey searchCacheKey
+	var oldest *cachedSearch
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldest == nil || entry.expires.Before(oldest.expires) {
+			oldestKey, oldest = key, entry
+		}
+	}
+
+	if len(c.entries) >= c.maxEntries && oldest != nil {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// cachedSearchMetadata runs a search, or returns its cached response. Only
+// searches with a known entity tag are cached. Truncated responses, which
+// depend on timing, and stale reads, which may miss the changes counted by
+// the watermark of their entity tag, are not cached.
+func (s *Server) cachedSearchMetadata(w http.ResponseWriter, r *http.Request, request *SearchRequest, etag string) (SearchResponse, error) {
+	ctx := r.Context()
+	search := s.searchMetadata
+	if request.SimilarTo != nil {
+		search = s.searchSimilar
+	}
+
+	if s.SearchCache == nil || etag == "" || request.Staleness != "" {
+		return search(ctx, request)
+	}
+
+	projectID := request.EncryptedLocation.ProjectID
+	if response, ok := s.SearchCache.Get(projectID, etag); ok {
+		mon.Counter("search_cache_hits").Inc(1)
+		recordAccessResults(ctx, len(response.Results))
+		w.Header().Set(searchCacheHeader, "hit")
+		return response, nil
+	}
+
+	mon.Counter("search_cache_misses").Inc(1)
+	response, err := search(ctx, request)
+	if err != nil {
+		return response, err
+	}
+	if !response.Truncated {
+		s.SearchCache.Put(projectID, etag, response)
+	}
+	w.Header().Set(searchCacheHeader, "miss")
+	return response, nil
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+)
+
+func TestSearchCache(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		SearchCacheTTL:  time.Minute,
+		SearchCacheSize: 10,
+	})
+	require.NoError(t, err)
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"filter": "foo == 'bar'"}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get(searchCacheHeader), "miss")
+	first := rr.Body.String()
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"filter": "foo == 'bar'"}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get(searchCacheHeader), "hit")
+	require.JSONEq(t, first, rr.Body.String())
+
+	// Different searches are cached separately
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"filter": "foo == 'baz'"}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get(searchCacheHeader), "miss")
+
+	// Writes to the bucket invalidate the cached responses
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "baz"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"filter": "foo == 'bar'"}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get(searchCacheHeader), "miss")
+	assertResponse(t, rr, http.StatusOK, `{"results": []}`)
+
+	// Writes made by other server instances invalidate the cached responses
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"filter": "foo == 'bar'"}`)
+	assert.Equal(t, rr.Header().Get(searchCacheHeader), "hit")
+	testRepo(server).watermarks["testbucket"]++
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"filter": "foo == 'bar'"}`)
+	assert.Equal(t, rr.Header().Get(searchCacheHeader), "miss")
+
+	// Stale reads are not cached
+	for i := 0; i < 2; i++ {
+		rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"filter": "foo == 'bar'", "staleness": "10s"}`)
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.Equal(t, rr.Header().Get(searchCacheHeader), "")
+	}
+}
+
+func TestSearchCacheExpiration(t *testing.T) {
+	cache := NewSearchCache(time.Hour, 2)
+	projectID, err := uuid.FromString(testProjectID)
+	require.NoError(t, err)
+
+	cache.Put(projectID, `"a"`, SearchResponse{PageToken: "a"})
+	cache.Put(projectID, `"b"`, SearchResponse{PageToken: "b"})
+	cache.Put(projectID, `"c"`, SearchResponse{PageToken: "c"})
+
+	// The entry expiring first is evicted when the cache is full
+	_, ok := cache.Get(projectID, `"a"`)
+	assert.False(t, ok)
+	response, ok := cache.Get(projectID, `"c"`)
+	assert.True(t, ok)
+	assert.Equal(t, response.PageToken, "c")
+
+	// Responses of other projects are not shared
+	_, ok = cache.Get(uuid.UUID{}, `"c"`)
+	assert.False(t, ok)
+
+	cache = NewSearchCache(0, 2)
+	cache.Put(projectID, `"a"`, SearchResponse{})
+	_, ok = cache.Get(projectID, `"a"`)
+	assert.False(t, ok)
+}
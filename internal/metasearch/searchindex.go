@@ -0,0 +1,187 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const maxSearchIndexResponseSize = 16 << 20
+
+// NewSearchIndex creates the client of an external search index of a kind:
+// "elasticsearch" or "meilisearch".
+func NewSearchIndex(kind string, address string, index string, token string, timeout time.Duration) (SearchIndex, error) {
+	switch kind {
+	case "elasticsearch":
+		return NewElasticsearchIndex(address, index, token, timeout), nil
+	case "meilisearch":
+		return NewMeilisearchIndex(address, index, token, timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown search index '%s'", kind)
+	}
+}
+
+// ElasticsearchIndex mirrors metadata into an Elasticsearch (or OpenSearch)
+// index with the bulk API.
+type ElasticsearchIndex struct {
+	url    string
+	index  string
+	apiKey string
+	client *http.Client
+}
+
+// NewElasticsearchIndex creates a new ElasticsearchIndex. The API key is sent
+// in the Authorization header, if set.
+func NewElasticsearchIndex(address string, index string, apiKey string, timeout time.Duration) *ElasticsearchIndex {
+	return &ElasticsearchIndex{
+		url:    strings.TrimSuffix(address, "/"),
+		index:  index,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (e *ElasticsearchIndex) Upsert(ctx context.Context, docs []IndexDocument) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, doc := range docs {
+		action := map[string]interface{}{"index": map[string]string{"_index": e.index, "_id": doc.ID}}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+	return e.bulk(ctx, &body)
+}
+
+func (e *ElasticsearchIndex) Delete(ctx context.Context, ids []string) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, id := range ids {
+		action := map[string]interface{}{"delete": map[string]string{"_index": e.index, "_id": id}}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+	}
+	return e.bulk(ctx, &body)
+}
+
+// bulk sends a request to the bulk API. The bulk API responds with 200 even
+// if operations failed, so the result of each operation is checked.
+func (e *ElasticsearchIndex) bulk(ctx context.Context, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url+"/_bulk", body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("elasticsearch returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxSearchIndexResponseSize)).Decode(&result)
+	if err != nil {
+		return fmt.Errorf("invalid elasticsearch response: %w", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+	for _, item := range result.Items {
+		for op, r := range item {
+			// Deleting a missing document is not an error
+			if op == "delete" && r.Status == http.StatusNotFound {
+				continue
+			}
+			if len(r.Error) > 0 {
+				return fmt.Errorf("elasticsearch cannot %s document %s: %s", op, r.ID, r.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// MeilisearchIndex mirrors metadata into a Meilisearch index. Meilisearch
+// applies the changes of an index asynchronously, in the order they were
+// accepted.
+type MeilisearchIndex struct {
+	url    string
+	index  string
+	apiKey string
+	client *http.Client
+}
+
+// NewMeilisearchIndex creates a new MeilisearchIndex. The API key is sent as
+// a bearer token, if set.
+func NewMeilisearchIndex(address string, index string, apiKey string, timeout time.Duration) *MeilisearchIndex {
+	return &MeilisearchIndex{
+		url:    strings.TrimSuffix(address, "/"),
+		index:  index,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (m *MeilisearchIndex) Upsert(ctx context.Context, docs []IndexDocument) error {
+	return m.post(ctx, "/documents?primaryKey=id", docs)
+}
+
+func (m *MeilisearchIndex) Delete(ctx context.Context, ids []string) error {
+	return m.post(ctx, "/documents/delete-batch", ids)
+}
+
+// post sends a request to an endpoint of the index, which enqueues a task.
+func (m *MeilisearchIndex) post(ctx context.Context, endpoint string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url+"/indexes/"+url.PathEscape(m.index)+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("meilisearch returned status %d: %s", resp.StatusCode, message)
+	}
+	return nil
+}
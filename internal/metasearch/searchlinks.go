@@ -0,0 +1,229 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"storj.io/common/uuid"
+)
+
+// defaultSearchLinkTTL is the lifetime of search links created without
+// expiresIn.
+const defaultSearchLinkTTL = time.Hour
+
+// SearchLinkRequest is the body of a request creating a search link.
+type SearchLinkRequest struct {
+	// Search is the search request run by the link.
+	Search json.RawMessage `json:"search"`
+	// ExpiresIn is the lifetime of the link, e.g. "24h". Defaults to one
+	// hour.
+	ExpiresIn string `json:"expiresIn,omitempty"`
+}
+
+// SearchLinkResponse is the response to a request creating a search link.
+type SearchLinkResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// searchLink is the content of a search link token, sealed with the search
+// link secret. It contains the access grant of the creator, so that the link
+// is revoked with the grant.
+type searchLink struct {
+	ProjectID   uuid.UUID       `json:"projectId"`
+	Bucket      string          `json:"bucket"`
+	Search      json.RawMessage `json:"search"`
+	Credentials string          `json:"credentials"`
+	ExpiresAt   time.Time       `json:"expiresAt"`
+}
+
+// HandleCreateSearchLink creates a signed link running a search with the
+// access grant of the request, which can be shared with clients that do not
+// have an access grant.
+func (s *Server) HandleCreateSearchLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var base BaseRequest
+	var body SearchLinkRequest
+
+	err := s.validateRequest(ctx, r, &base, &body)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = base.Authorizer.Authorize(ctx, base.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	if s.Config.SearchLinkSecret == "" {
+		s.errorResponse(w, fmt.Errorf("%w: search links are disabled", ErrNotFound))
+		return
+	}
+
+	credentials := r.Header.Get("Authorization")
+	if !strings.HasPrefix(credentials, "Bearer ") {
+		s.errorResponse(w, fmt.Errorf("%w: search links require an access grant in the Authorization header", ErrBadRequest))
+		return
+	}
+
+	ttl := defaultSearchLinkTTL
+	if body.ExpiresIn != "" {
+		ttl, err = time.ParseDuration(body.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			s.errorResponse(w, fmt.Errorf("%w: invalid expiresIn '%s'", ErrBadRequest, body.ExpiresIn))
+			return
+		}
+	}
+	if ttl > s.Config.SearchLinkMaxTTL {
+		s.errorResponse(w, fmt.Errorf("%w: search links expire after at most %s", ErrBadRequest, s.Config.SearchLinkMaxTTL))
+		return
+	}
+
+	if len(body.Search) == 0 {
+		body.Search = json.RawMessage(`{}`)
+	}
+	request := SearchRequest{BaseRequest: base}
+	if err = newJSONDecoder(bytes.NewReader(body.Search)).Decode(&request); err != nil {
+		s.errorResponse(w, fmt.Errorf("%w: error decoding search: %w", ErrBadRequest, err))
+		return
+	}
+	if request.PageToken != "" {
+		s.errorResponse(w, fmt.Errorf("%w: the search of a link cannot have a pageToken", ErrBadRequest))
+		return
+	}
+	if err = s.validateSearchRequest(&request); err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	link := searchLink{
+		ProjectID:   base.Location.ProjectID,
+		Bucket:      base.Location.BucketName,
+		Search:      body.Search,
+		Credentials: credentials,
+		ExpiresAt:   time.Now().Add(ttl).Truncate(time.Second),
+	}
+	token, err := s.sealSearchLink(link)
+	if err != nil {
+		s.errorResponse(w, fmt.Errorf("%w: %v", ErrInternalError, err))
+		return
+	}
+
+	s.jsonResponse(w, http.StatusCreated, SearchLinkResponse{
+		URL:       apiPath(r, "/links/"+token),
+		ExpiresAt: link.ExpiresAt,
+	})
+}
+
+// HandleSearchLink runs the search of a link, with the access grant of its
+// creator. The next pages are requested with the pageToken query parameter.
+func (s *Server) HandleSearchLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if s.Config.SearchLinkSecret == "" {
+		s.errorResponse(w, fmt.Errorf("%w: search links are disabled", ErrNotFound))
+		return
+	}
+
+	link, err := s.openSearchLink(mux.Vars(r)["token"], time.Now())
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	// Only the page can be chosen by the holder of the link. Other query
+	// parameters, e.g. of ListObjectsV2, would change the search.
+	query := r.URL.Query()
+	for name := range query {
+		if name != "pageToken" {
+			s.errorResponse(w, fmt.Errorf("%w: search links only accept the pageToken query parameter", ErrBadRequest))
+			return
+		}
+	}
+
+	search := map[string]json.RawMessage{}
+	if err = json.Unmarshal(link.Search, &search); err != nil {
+		s.errorResponse(w, fmt.Errorf("%w: %v", ErrInternalError, err))
+		return
+	}
+	if pageToken := query.Get("pageToken"); pageToken != "" {
+		search["pageToken"], _ = json.Marshal(pageToken)
+	}
+	body, err := json.Marshal(search)
+	if err != nil {
+		s.errorResponse(w, fmt.Errorf("%w: %v", ErrInternalError, err))
+		return
+	}
+
+	// Authenticate again with the access grant of the link, so that revoked
+	// grants cannot search. The request is built from the link alone, without
+	// the headers of the holder.
+	linkRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, "/metasearch/"+url.PathEscape(link.Bucket), bytes.NewReader(body))
+	if err != nil {
+		s.errorResponse(w, fmt.Errorf("%w: %v", ErrInternalError, err))
+		return
+	}
+	linkRequest = mux.SetURLVars(linkRequest, map[string]string{"bucket": link.Bucket})
+	linkRequest.Header.Set("Authorization", link.Credentials)
+	linkRequest.Header.Set("Content-Type", "application/json")
+
+	var request SearchRequest
+	err = s.validateRequest(ctx, linkRequest, &request.BaseRequest, &request)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+	if request.Location.ProjectID != link.ProjectID {
+		s.errorResponse(w, fmt.Errorf("%w: the access grant of the link no longer belongs to the project", ErrForbidden))
+		return
+	}
+
+	s.handleSearch(w, linkRequest, &request)
+}
+
+// sealSearchLink encrypts and authenticates a search link into a URL-safe
+// token.
+func (s *Server) sealSearchLink(link searchLink) (string, error) {
+	content, err := json.Marshal(link)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := sealCredentials(s.Config.SearchLinkSecret, content)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openSearchLink opens a token sealed with sealSearchLink, and fails if the
+// link expired at now.
+func (s *Server) openSearchLink(token string, now time.Time) (link searchLink, err error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return link, fmt.Errorf("%w: invalid search link", ErrAuthorizationFailed)
+	}
+	content, err := openCredentials(s.Config.SearchLinkSecret, sealed)
+	if err != nil {
+		return link, fmt.Errorf("%w: invalid search link", ErrAuthorizationFailed)
+	}
+	if err = json.Unmarshal(content, &link); err != nil {
+		return link, fmt.Errorf("%w: invalid search link", ErrAuthorizationFailed)
+	}
+	if !now.Before(link.ExpiresAt) {
+		return link, fmt.Errorf("%w: the search link expired", ErrForbidden)
+	}
+	return link, nil
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestSearchLinks(t *testing.T) {
+	server := testServer()
+
+	for key, metadata := range map[string]string{
+		"foo.txt": `{"type": "photo"}`,
+		"bar.txt": `{"type": "photo"}`,
+		"baz.txt": `{"type": "video"}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	// Disabled without a secret
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket/links", `{"search": {}}`)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	server.Config.SearchLinkSecret = "linksecret"
+	server.Config.SearchLinkMaxTTL = 24 * time.Hour
+
+	// Invalid requests
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/links", `{"search": {}, "expiresIn": "48h"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/links", `{"search": {"pageToken": "abc"}}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/links", `{"search": {"filter": "type =="}}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Create
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/links", `{
+		"search": {"match": {"type": "photo"}, "batchSize": 1},
+		"expiresIn": "1h"
+	}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var created SearchLinkResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	require.Regexp(t, "^/v1/links/", created.URL)
+	require.WithinDuration(t, time.Now().Add(time.Hour), created.ExpiresAt, time.Minute)
+
+	// The access grant is not readable in the link
+	require.NotContains(t, created.URL, "testtoken")
+
+	// Search without an access grant, page by page
+	search := func(u string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://localhost"+u, nil))
+		return rr
+	}
+	var paths []string
+	pageURL := created.URL
+	for {
+		rr = search(pageURL)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var response SearchResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		require.LessOrEqual(t, len(response.Results), 1)
+		for _, result := range response.Results {
+			paths = append(paths, result.Path)
+		}
+		if response.PageToken == "" {
+			break
+		}
+		pageURL = created.URL + "?pageToken=" + url.QueryEscape(response.PageToken)
+	}
+	require.ElementsMatch(t, []string{"sj://testbucket/foo.txt", "sj://testbucket/bar.txt"}, paths)
+
+	// Query parameters other than pageToken cannot change the search
+	rr = search(created.URL + "?list-type=2&prefix=")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	rr = search(created.URL + "?keyPrefix=")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Tampered links
+	rr = search(created.URL + "x")
+	assert.Equal(t, rr.Code, http.StatusUnauthorized)
+	rr = search("/v1/links/not-a-link")
+	assert.Equal(t, rr.Code, http.StatusUnauthorized)
+
+	// Links sealed with another secret
+	server.Config.SearchLinkSecret = "othersecret"
+	rr = search(created.URL)
+	assert.Equal(t, rr.Code, http.StatusUnauthorized)
+	server.Config.SearchLinkSecret = "linksecret"
+
+	// Expired links
+	token := created.URL[len("/v1/links/"):]
+	_, err := server.openSearchLink(token, created.ExpiresAt)
+	require.ErrorIs(t, err, ErrForbidden)
+}
@@ -0,0 +1,284 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+	"storj.io/storj/shared/tagsql"
+)
+
+const (
+	seedInsertBatchSize = 100
+
+	// seedKeyDraws is the number of draws from the key distribution per key
+	// of an object, before the missing keys are picked uniformly.
+	seedKeyDraws = 10
+)
+
+// SeedConfig describes a generated test dataset.
+type SeedConfig struct {
+	ProjectID uuid.UUID
+	Bucket    string
+	Prefix    string
+
+	// Objects is the number of objects to generate.
+	Objects int
+	// Directories is the number of subdirectories the objects are spread in.
+	Directories int
+	// Keys is the number of distinct metadata keys.
+	Keys int
+	// KeysPerObject is the number of metadata keys set on each object.
+	KeysPerObject int
+	// Values is the number of distinct values per metadata key.
+	Values int
+	// Depth is the nesting depth of metadata values. Depth 1 means flat metadata.
+	Depth int
+	// Distribution of keys and values: "uniform" or "zipf".
+	Distribution string
+	// Seed of the random generator, the same seed generates the same dataset.
+	Seed int64
+}
+
+// Validate checks the seed configuration.
+func (c *SeedConfig) Validate() error {
+	if c.ProjectID.IsZero() {
+		return fmt.Errorf("project ID is required")
+	}
+	if c.Bucket == "" {
+		return fmt.Errorf("bucket is required")
+	}
+	if c.Objects <= 0 || c.Keys <= 0 || c.Values <= 0 || c.Depth <= 0 {
+		return fmt.Errorf("objects, keys, values and depth must be positive")
+	}
+	if c.KeysPerObject <= 0 || c.KeysPerObject > c.Keys {
+		return fmt.Errorf("keys per object must be between 1 and %d", c.Keys)
+	}
+	if c.Distribution != "uniform" && c.Distribution != "zipf" {
+		return fmt.Errorf("invalid distribution '%s'", c.Distribution)
+	}
+	return nil
+}
+
+// SeedGenerator generates realistic object metadata datasets.
+type SeedGenerator struct {
+	config SeedConfig
+	rnd    *rand.Rand
+	keys   func() int
+	values func() int
+}
+
+// NewSeedGenerator creates a new generator for the given configuration.
+func NewSeedGenerator(config SeedConfig) *SeedGenerator {
+	rnd := rand.New(rand.NewSource(config.Seed))
+	g := &SeedGenerator{
+		config: config,
+		rnd:    rnd,
+	}
+	g.keys = g.distribution(config.Keys)
+	g.values = g.distribution(config.Values)
+	return g
+}
+
+func (g *SeedGenerator) distribution(n int) func() int {
+	if g.config.Distribution == "zipf" && n > 1 {
+		zipf := rand.NewZipf(g.rnd, 1.1, 1, uint64(n-1))
+		return func() int { return int(zipf.Uint64()) }
+	}
+	return func() int { return g.rnd.Intn(n) }
+}
+
+// Generate generates the dataset and calls fn for each object.
+func (g *SeedGenerator) Generate(fn func(obj ObjectInfo) error) error {
+	for i := 0; i < g.config.Objects; i++ {
+		var key strings.Builder
+		key.WriteString(g.config.Prefix)
+		if g.config.Directories > 0 {
+			fmt.Fprintf(&key, "dir%04d/", g.rnd.Intn(g.config.Directories))
+		}
+		fmt.Fprintf(&key, "object%08d.dat", i)
+
+		obj := ObjectInfo{
+			ObjectLocation: ObjectLocation{
+				ProjectID:  g.config.ProjectID,
+				BucketName: g.config.Bucket,
+				ObjectKey:  key.String(),
+				Version:    1,
+			},
+			Status: statusCommittedUnversioned,
+			Metadata: ObjectMetadata{
+				ClearMetadata: g.metadata(),
+			},
+		}
+
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metadata generates the metadata of an object, with keys drawn from the
+// key distribution. Rare keys may take too many draws, e.g. with a zipf
+// distribution and almost as many keys per object as keys, so after
+// seedKeyDraws draws per key the missing keys are picked uniformly.
+func (g *SeedGenerator) metadata() map[string]interface{} {
+	meta := make(map[string]interface{}, g.config.KeysPerObject)
+	set := func(k int) {
+		meta[fmt.Sprintf("key%d", k)] = g.value(k, g.config.Depth)
+	}
+
+	for draws := 0; len(meta) < g.config.KeysPerObject && draws < seedKeyDraws*g.config.KeysPerObject; draws++ {
+		set(g.keys())
+	}
+	if len(meta) < g.config.KeysPerObject {
+		for _, k := range g.rnd.Perm(g.config.Keys) {
+			if len(meta) >= g.config.KeysPerObject {
+				break
+			}
+			if _, ok := meta[fmt.Sprintf("key%d", k)]; !ok {
+				set(k)
+			}
+		}
+	}
+	return meta
+}
+
+// value generates a value for the given key. The type of the value depends on
+// the key, so that a key always has values of the same type.
+func (g *SeedGenerator) value(key int, depth int) interface{} {
+	if depth > 1 {
+		return map[string]interface{}{
+			fmt.Sprintf("sub%d", g.rnd.Intn(2)): g.value(key, depth-1),
+		}
+	}
+
+	v := g.values()
+	switch key % 4 {
+	case 0:
+		return v
+	case 1:
+		return v%2 == 0
+	case 2:
+		return []interface{}{fmt.Sprintf("tag%d", v), fmt.Sprintf("tag%d", g.values())}
+	default:
+		return fmt.Sprintf("value%d", v)
+	}
+}
+
+// Seeder inserts generated objects directly into a test metabase.
+type Seeder struct {
+	db  tagsql.DB
+	log *zap.Logger
+}
+
+// NewSeeder creates a new Seeder.
+func NewSeeder(db tagsql.DB, log *zap.Logger) *Seeder {
+	return &Seeder{
+		db:  db,
+		log: log,
+	}
+}
+
+// Seed generates the dataset and inserts it into the objects table. If the
+// encryptor is not nil, paths and metadata are encrypted with it, so that
+// the objects can be accessed via the API. Existing objects are not modified.
+func (s *Seeder) Seed(ctx context.Context, config SeedConfig, encryptor Encryptor) (inserted int, err error) {
+	if err := config.Validate(); err != nil {
+		return 0, err
+	}
+
+	batch := make([]ObjectInfo, 0, seedInsertBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := s.insert(ctx, batch)
+		if err != nil {
+			return err
+		}
+		inserted += n
+		batch = batch[:0]
+		s.log.Debug("inserted seed objects", zap.Int("Inserted", inserted))
+		return nil
+	}
+
+	err = NewSeedGenerator(config).Generate(func(obj ObjectInfo) error {
+		if encryptor != nil {
+			err := encryptor.EncryptMetadata(obj.BucketName, obj.ObjectKey, &obj.Metadata)
+			if err != nil {
+				return fmt.Errorf("cannot encrypt metadata: %w", err)
+			}
+			obj.ObjectKey, err = encryptor.EncryptPath(obj.BucketName, obj.ObjectKey)
+			if err != nil {
+				return fmt.Errorf("cannot encrypt path: %w", err)
+			}
+		}
+
+		batch = append(batch, obj)
+		if len(batch) >= seedInsertBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return inserted, err
+	}
+
+	return inserted, flush()
+}
+
+func (s *Seeder) insert(ctx context.Context, objs []ObjectInfo) (int, error) {
+	query := `
+		INSERT INTO objects (
+			project_id, bucket_name, object_key, version, stream_id, status,
+			encrypted_metadata_nonce, encrypted_metadata, encrypted_metadata_encrypted_key,
+			clear_metadata,
+			metasearch_queued_at
+		) VALUES
+	`
+	args := make([]interface{}, 0, len(objs)*10)
+	for i, obj := range objs {
+		clearMetadata, err := json.Marshal(obj.Metadata.ClearMetadata)
+		if err != nil {
+			return 0, err
+		}
+
+		streamID, err := uuid.New()
+		if err != nil {
+			return 0, err
+		}
+
+		if i > 0 {
+			query += ",\n"
+		}
+		n := len(args)
+		query += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, NULL)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10)
+		args = append(args,
+			obj.ProjectID, []byte(obj.BucketName), []byte(obj.ObjectKey), obj.Version, streamID, obj.Status,
+			obj.Metadata.EncryptedMetadataNonce, obj.Metadata.EncryptedMetadata, obj.Metadata.EncryptedMetadataKey,
+			string(clearMetadata),
+		)
+	}
+	query += "\nON CONFLICT DO NOTHING"
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("cannot insert objects: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
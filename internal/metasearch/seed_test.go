@@ -0,0 +1,65 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/uuid"
+)
+
+func TestSeedGenerator(t *testing.T) {
+	projectID, err := uuid.FromString(testProjectID)
+	require.NoError(t, err)
+
+	config := SeedConfig{
+		ProjectID:     projectID,
+		Bucket:        "testbucket",
+		Objects:       50,
+		Directories:   3,
+		Keys:          10,
+		KeysPerObject: 4,
+		Values:        20,
+		Depth:         2,
+		Distribution:  "zipf",
+		Seed:          42,
+	}
+	require.NoError(t, config.Validate())
+
+	generate := func() []ObjectInfo {
+		var objs []ObjectInfo
+		err := NewSeedGenerator(config).Generate(func(obj ObjectInfo) error {
+			objs = append(objs, obj)
+			return nil
+		})
+		require.NoError(t, err)
+		return objs
+	}
+
+	objs := generate()
+	require.Len(t, objs, config.Objects)
+	for _, obj := range objs {
+		require.Len(t, obj.Metadata.ClearMetadata, config.KeysPerObject)
+		for _, v := range obj.Metadata.ClearMetadata {
+			require.IsType(t, map[string]interface{}{}, v)
+		}
+	}
+
+	// Same seed generates the same dataset
+	require.Equal(t, objs, generate())
+
+	// All keys on each object, which rare keys of the zipf distribution would
+	// take many draws to complete
+	config.Keys, config.KeysPerObject = 1000, 1000
+	config.Objects, config.Depth = 2, 1
+	require.NoError(t, config.Validate())
+	for _, obj := range generate() {
+		require.Len(t, obj.Metadata.ClearMetadata, config.Keys)
+	}
+
+	config.KeysPerObject = 1001
+	require.Error(t, config.Validate())
+}
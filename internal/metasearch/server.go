@@ -10,25 +10,231 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"net/url"
+	"path"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jmespath/go-jmespath"
+	"github.com/spacemonkeygo/monkit/v3"
 	"go.uber.org/zap"
 
 	"storj.io/common/uuid"
 )
 
+var mon = monkit.Package()
+
 // Server implements the REST API for metadata search.
 type Server struct {
 	Logger   *zap.Logger
 	Repo     MetaSearchRepo
 	Auth     Authenticator
-	Endpoint string
+	Config   ServerConfig
 	Handler  http.Handler
 	Migrator *ObjectMigrator
+	Grants   *GrantTracker
+	Indexes  *IndexRebuilder
+
+	Idempotency *IdempotencyStore
+	SearchCache *SearchCache
+	SLOs        *SLOTracker
+	Features    *FeatureFlags
+
+	// expensiveQueries limits the number of concurrent expensive searches.
+	expensiveQueries chan struct{}
+	// concurrency limits the number of concurrent requests of each handler
+	// class.
+	concurrency *concurrencyLimiter
+	// admission queues the requests over the capacity of the server.
+	admission *admissionController
+	// readOnly is set in read-only maintenance mode.
+	readOnly atomic.Bool
+	// watchers is the number of open watch connections.
+	watchers atomic.Int64
+	// jobs are the search jobs running on this server instance.
+	jobs jobRunner
+	// trustedProxies are the address ranges of the proxies whose forwarding
+	// headers are trusted.
+	trustedProxies []netip.Prefix
+}
+
+// ServerConfig contains the configuration of the metasearch server.
+type ServerConfig struct {
+	// Endpoint is the address the server listens on (IP + port).
+	Endpoint string
+	// DRPCEndpoint is the address the DRPC service listens on (IP + port).
+	// The DRPC service is disabled if it is empty.
+	DRPCEndpoint string
+
+	// TLSCertFile and TLSKeyFile are the PEM files of the TLS certificate
+	// and key of the server. The server serves plain HTTP if they are empty,
+	// unless ACME is enabled.
+	TLSCertFile string
+	TLSKeyFile  string
+	// ACMEDomains are the domains of the certificates obtained from an ACME
+	// certificate authority, like Let's Encrypt. ACME is disabled if it is
+	// empty. Certificates are stored in ACMECacheDir, and ACMEEmail is the
+	// contact address of the ACME account.
+	ACMEDomains  []string
+	ACMECacheDir string
+	ACMEEmail    string
+
+	// H2C accepts HTTP/2 over cleartext connections, for internal meshes
+	// without TLS. HTTP/2 is always negotiated over TLS. MaxStreams is the
+	// maximum number of concurrent streams of an HTTP/2 connection, the
+	// default of the HTTP/2 server if it is zero.
+	H2C        bool
+	MaxStreams uint32
+
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout are the
+	// timeouts of the HTTP server, disabled if they are zero. Streamed
+	// responses extend their write deadline to the request timeout.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// RequestTimeout is the deadline of the context of requests, except
+	// WebSocket connections. Requests are not bounded if it is zero.
+	RequestTimeout time.Duration
+	// StatementTimeout is the maximum duration of a repository call, e.g. a
+	// batch of a search. Calls are not bounded if it is zero.
+	StatementTimeout time.Duration
+	// AccessLogSampleRate is the fraction of the requests, between 0 and 1,
+	// logged to the access log. Server errors are always logged. The access
+	// log is disabled if it is zero.
+	AccessLogSampleRate float64
+	// TrustedProxies are the IP addresses and CIDR ranges of the proxies,
+	// like load balancers, whose X-Forwarded-For and X-Real-IP headers are
+	// honored to determine the client IP of requests.
+	TrustedProxies []string
+	// MaxBodySize is the maximum size of request bodies in bytes, except
+	// import manifests. Request bodies are not bounded if it is zero.
+	MaxBodySize int64
+
+	// ReadOnly starts the server in read-only maintenance mode. It can be
+	// switched at runtime with the admin API.
+	ReadOnly bool
+
+	// AdminToken is the bearer token of the admin API. The admin API is
+	// disabled if it is empty.
+	AdminToken string
+
+	// RequireIfMatch rejects metadata updates and deletes without an
+	// If-Match header, so that clients cannot overwrite concurrent changes.
+	RequireIfMatch bool
+
+	// SoftDeleteRetention is the duration deleted metadata is kept, so that
+	// it can be restored. Soft delete is disabled if it is zero.
+	SoftDeleteRetention time.Duration
+
+	// PageTokenRetention is the duration page tokens of a search remain
+	// valid. Later pages read the same database snapshot as the first page,
+	// so it must not exceed the garbage collection TTL of the objects table.
+	// Page tokens do not expire and do not use snapshots if it is zero.
+	PageTokenRetention time.Duration
+
+	// Stateless disables the state kept in memory across requests, so that
+	// requests can be sent to any server instance behind a load balancer.
+	// Queued objects are migrated within requests with their own encryptor,
+	// and search entity tags use the watermarks of the database.
+	Stateless bool
+
+	// SLOs are the service level objectives of the endpoints, identified by
+	// route name.
+	SLOs []SLO
+
+	// FeatureRollout contains the percentage of projects feature flags are
+	// enabled for, replacing their defaults.
+	FeatureRollout map[string]float64
+	// FeatureOverrides enables or disables feature flags for projects.
+	FeatureOverrides map[uuid.UUID]map[string]bool
+	// FeatureHeader allows overriding feature flags per request with the
+	// X-Metasearch-Features header, for internal testing.
+	FeatureHeader bool
+
+	// MaxQueryCost rejects searches whose estimated cost is higher. The
+	// cost is not limited if it is zero.
+	MaxQueryCost int
+	// ExpensiveQueryCost is the estimated cost from which searches count
+	// as expensive, and MaxExpensiveQueries the number of expensive
+	// searches that can run concurrently. Expensive searches are not
+	// throttled if MaxExpensiveQueries is zero.
+	ExpensiveQueryCost  int
+	MaxExpensiveQueries int
+
+	// SearchCacheTTL is the duration search responses are cached, and
+	// SearchCacheSize the maximum number of cached responses. Search
+	// responses are not cached if either is zero.
+	SearchCacheTTL  time.Duration
+	SearchCacheSize int
+
+	// MetadataCacheTTL is the duration the metadata of objects is cached,
+	// and MetadataCacheSize the maximum number of cached objects. Changes
+	// made by uplinks or other server instances are only visible once the
+	// cached metadata expires. Metadata is not cached if either is zero,
+	// and it cannot be cached in stateless mode.
+	MetadataCacheTTL  time.Duration
+	MetadataCacheSize int
+
+	// MaxRunningRequests is the maximum number of requests served
+	// concurrently, and MaxQueuedRequests the maximum number of requests
+	// waiting to be served. Requests are rejected with 429 Too Many Requests
+	// when the queue is full. Requests are not queued if MaxRunningRequests
+	// is zero.
+	MaxRunningRequests int
+	MaxQueuedRequests  int
+
+	// MaxCRUDRequests, MaxSearchRequests and MaxMigrations are the maximum
+	// numbers of concurrent metadata operations, searches and requests
+	// migrating the metadata of their project in stateless mode. The other
+	// requests are rejected with 429 Too Many Requests. Requests are not
+	// limited if they are zero.
+	MaxCRUDRequests   int
+	MaxSearchRequests int
+	MaxMigrations     int
+
+	// WatchInterval is the interval the change feed of watched buckets is
+	// polled at, and MaxWatchers the maximum number of open watch
+	// connections, unlimited if it is zero.
+	WatchInterval time.Duration
+	MaxWatchers   int
+
+	// Compression lists the content encodings of compressed responses, in
+	// order of preference. Responses are not compressed if it is empty.
+	Compression []string
+
+	// JobRetention is the duration search jobs and their results are kept
+	// after they are submitted. Search jobs are disabled if it is zero.
+	JobRetention time.Duration
+	// MaxJobs is the maximum number of search jobs running concurrently on
+	// a server instance, unlimited if it is zero.
+	MaxJobs int
+
+	// ScheduleSecret is the key encrypting the access grants of scheduled
+	// searches. Scheduled searches are disabled if it is empty.
+	ScheduleSecret string
+	// PrivateWebhooks allows the webhooks of scheduled searches on
+	// loopback, private and link-local addresses. Otherwise only public
+	// addresses are dialed, so that webhooks cannot reach internal services.
+	PrivateWebhooks bool
+
+	// SearchLinkSecret is the key sealing the access grants and searches of
+	// search links. Search links are disabled if it is empty.
+	SearchLinkSecret string
+
+	// SearchLinkMaxTTL is the maximum lifetime of search links.
+	SearchLinkMaxTTL time.Duration
+
+	// ConsoleOrigin is the origin of the satellite web app, e.g.
+	// https://us1.storj.io, which can send cross-origin requests with the
+	// session of the logged in user. Cross-origin requests are not allowed
+	// if it is empty.
+	ConsoleOrigin string
 }
 
 // BaseRequest contains common fields for all requests.
@@ -43,13 +249,44 @@ type BaseRequest struct {
 
 const defaultBatchSize = 100
 const maxBatchSize = 1000
+
+// maxRefillBatches is the maximum number of batches read from the database to
+// fill a page of a search whose results are filtered by key pattern.
+const maxRefillBatches = 10
+
+// maxSearchTimeout is the maximum latency budget of a search request.
+const maxSearchTimeout = 5 * time.Minute
+
+// maxPartialResultsMargin is the maximum time reserved to return partial
+// results before the deadline of a search.
+const maxPartialResultsMargin = 100 * time.Millisecond
 const migrationTimeout = 10 * time.Second
 
+// maxSnapshotClockSkew is the maximum time the snapshot of a page token can
+// be ahead of the server clock.
+const maxSnapshotClockSkew = 1 * time.Minute
+
+// errPageFull stops the query of a search whose page is full.
+var errPageFull = errors.New("page full")
+
+// maxStaleness is the maximum staleness of a search, which must not exceed
+// the garbage collection TTL of the objects table.
+const maxStaleness = 5 * time.Minute
+
 // GetRequest contains fields for a get request.
 type GetRequest struct {
 	BaseRequest
 }
 
+// EncryptedUpdateRequest contains metadata encrypted by the client, and the
+// clear metadata that should be searchable. Binary fields are base64 encoded.
+type EncryptedUpdateRequest struct {
+	EncryptedMetadataNonce []byte                 `json:"encryptedMetadataNonce,omitempty"`
+	EncryptedMetadata      []byte                 `json:"encryptedMetadata,omitempty"`
+	EncryptedMetadataKey   []byte                 `json:"encryptedMetadataKey,omitempty"`
+	ClearMetadata          map[string]interface{} `json:"clearMetadata,omitempty"`
+}
+
 // SearchRequest contains fields for a view or search request.
 type SearchRequest struct {
 	BaseRequest
@@ -59,55 +296,304 @@ type SearchRequest struct {
 	Filter     string                 `json:"filter,omitempty"`
 	Projection string                 `json:"projection,omitempty"`
 
+	// Query is a SQL-like text query, e.g. `department = "hr" AND size > 100`,
+	// which must match in addition to Match.
+	Query string `json:"query,omitempty"`
+
+	// AnyOf contains alternative match queries, one of which must match in
+	// addition to Match.
+	AnyOf []map[string]interface{} `json:"anyOf,omitempty"`
+	// Not is a match query that must not match.
+	Not map[string]interface{} `json:"not,omitempty"`
+	// Exists lists metadata keys that must exist.
+	Exists []string `json:"exists,omitempty"`
+	// Missing lists metadata keys that must not exist.
+	Missing []string `json:"missing,omitempty"`
+	// CaseInsensitive makes keys and string values of the match query
+	// match regardless of their case.
+	CaseInsensitive bool `json:"caseInsensitive,omitempty"`
+
+	// System contains conditions on the system attributes of objects, such
+	// as their size or creation time.
+	System map[string]interface{} `json:"system,omitempty"`
+
+	// KeyPattern is a glob pattern and KeyRegex a regular expression that
+	// the decrypted object keys must match.
+	KeyPattern string `json:"keyPattern,omitempty"`
+	KeyRegex   string `json:"keyRegex,omitempty"`
+
+	// Sort orders the results by a metadata value, e.g.
+	// {"key": "capturedAt", "order": "desc"}.
+	Sort *SearchSort `json:"sort,omitempty"`
+
+	// CountOnly returns the number of matching objects instead of the
+	// objects.
+	CountOnly bool `json:"countOnly,omitempty"`
+
 	BatchSize int    `json:"batchSize,omitempty"`
 	PageToken string `json:"pageToken,omitempty"`
 
+	// DecryptPaths can be set to false to omit object paths from the
+	// results. Defaults to true.
+	DecryptPaths *bool `json:"decryptPaths,omitempty"`
+
+	// IncludeSystemMetadata adds the system attributes of the objects, such
+	// as their size and creation time, to the results.
+	IncludeSystemMetadata bool `json:"includeSystemMetadata,omitempty"`
+
+	// Delimiter groups the results in subdirectories of the key prefix into
+	// common prefixes, like folders. Only "/" is supported.
+	Delimiter string `json:"delimiter,omitempty"`
+
+	// Highlight adds the metadata values that satisfied the match query and
+	// the filter to the results.
+	Highlight bool `json:"highlight,omitempty"`
+
+	// SimilarTo returns the objects whose embedding is the most similar to a
+	// vector, most similar first, instead of in key order.
+	SimilarTo *SimilarTo `json:"similarTo,omitempty"`
+
+	// Columns are the dot separated metadata paths exported as columns, if
+	// the client accepts CSV or Parquet.
+	Columns []string `json:"columns,omitempty"`
+
+	// Timeout is the latency budget of the request, e.g. "500ms".
+	Timeout string `json:"timeout,omitempty"`
+	// PartialResults returns the results found so far when the deadline of
+	// the request approaches, instead of failing the request.
+	PartialResults bool `json:"partialResults,omitempty"`
+	// Staleness reads the objects as they were the given duration ago, e.g.
+	// "10s", to avoid contention with concurrent writes.
+	Staleness string `json:"staleness,omitempty"`
+
 	startAfter     ObjectLocation
+	asOf           time.Time
+	sort           *MetadataSort
 	filterPath     *jmespath.JMESPath
 	projectionPath *jmespath.JMESPath
+	keyRegex       *regexp.Regexp
+	timeout        time.Duration
+	match          *matchQuery
+	cost           QueryCost
 }
 
 // SearchResponse contains fields for a view or search response.
 type SearchResponse struct {
 	Results   []SearchResult `json:"results"`
 	PageToken string         `json:"pageToken,omitempty"`
+
+	// CommonPrefixes are the paths of the subdirectories with matching
+	// objects, if the request has a delimiter.
+	CommonPrefixes []string `json:"commonPrefixes,omitempty"`
+
+	// Warnings describe why the results may be incomplete, e.g. when the
+	// metadata index is unavailable.
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Truncated is true if the search stopped early because the deadline of
+	// the request was reached. The page token resumes the search. Similarity
+	// searches are also truncated when there are more matching objects than
+	// they rank.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // SearchResult contains fields for a single search result.
 type SearchResult struct {
-	Path     string      `json:"path"`
-	Metadata interface{} `json:"metadata"`
+	Path     string          `json:"path,omitempty"`
+	Metadata interface{}     `json:"metadata"`
+	System   *SystemMetadata `json:"system,omitempty"`
+
+	// Highlights are the metadata values that satisfied the query, keyed by
+	// their dot separated path.
+	Highlights map[string]interface{} `json:"highlights,omitempty"`
+
+	// Score is the similarity of the object to the vector of a similarTo
+	// search.
+	Score *float64 `json:"score,omitempty"`
 }
 
 // NewServer creates a new metasearch server process.
-func NewServer(log *zap.Logger, repo MetaSearchRepo, auth Authenticator, endpoint string) (*Server, error) {
+func NewServer(log *zap.Logger, repo MetaSearchRepo, auth Authenticator, config ServerConfig) (*Server, error) {
+	if config.StatementTimeout > 0 {
+		repo = newStatementTimeoutRepo(repo, config.StatementTimeout)
+	}
+	if config.Stateless && config.MetadataCacheTTL > 0 {
+		// Changes made by other instances would not invalidate the cache
+		return nil, errors.New("the metadata cache cannot be used in stateless mode")
+	}
+	if config.MetadataCacheTTL > 0 && config.MetadataCacheSize > 0 {
+		repo = newMetadataCachingRepo(repo, config.MetadataCacheTTL, config.MetadataCacheSize)
+	}
+
 	s := &Server{
 		Logger:   log,
 		Repo:     repo,
 		Auth:     auth,
-		Endpoint: endpoint,
+		Config:   config,
 		Migrator: NewObjectMigrator(log, repo),
+		Grants:   NewGrantTracker(log),
+		Indexes:  NewIndexRebuilder(log, repo),
+
+		Idempotency: NewIdempotencyStore(idempotencyKeyTTL),
+		SLOs:        NewSLOTracker(config.SLOs),
+	}
+	if config.SearchCacheTTL > 0 && config.SearchCacheSize > 0 {
+		s.SearchCache = NewSearchCache(config.SearchCacheTTL, config.SearchCacheSize)
+	}
+	if config.MaxExpensiveQueries > 0 {
+		s.expensiveQueries = make(chan struct{}, config.MaxExpensiveQueries)
+	}
+	s.SetReadOnly(config.ReadOnly)
+	s.admission = newAdmissionController(config.MaxRunningRequests, config.MaxQueuedRequests)
+	s.concurrency = newConcurrencyLimiter(map[string]int{
+		crudClass:      config.MaxCRUDRequests,
+		searchClass:    config.MaxSearchRequests,
+		migrationClass: config.MaxMigrations,
+	})
+
+	if err := validateTLSConfig(config); err != nil {
+		return nil, err
 	}
 
-	router := mux.NewRouter()
+	var err error
+	s.trustedProxies, err = parseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
 
-	// CRUD operations
-	router.HandleFunc("/metadata/{bucket}/{key:.*}", s.HandleGet).Methods(http.MethodGet)
-	router.HandleFunc("/metadata/{bucket}/{key:.*}", s.HandleUpdate).Methods(http.MethodPut)
-	router.HandleFunc("/metadata/{bucket}/{key:.*}", s.HandleDelete).Methods(http.MethodDelete)
+	s.Features, err = NewFeatureFlags(config.FeatureRollout, config.FeatureOverrides, config.FeatureHeader)
+	if err != nil {
+		return nil, err
+	}
 
-	// Search
-	router.HandleFunc("/metasearch/{bucket}", s.HandleQuery).Methods(http.MethodPost)
+	router := mux.NewRouter()
+	router.Use(s.resolveClientIP)
+	router.Use(s.logAccess)
+	router.Use(s.limitRequestDuration)
+	router.Use(s.admitRequests)
+	router.Use(s.rejectWrites)
+	router.Use(s.limitRequestBody)
+	router.Use(withActor)
+	router.Use(routeReads)
+	router.Use(s.trackSLO)
+	router.Use(s.limitConcurrency)
+	router.Use(s.compressResponses)
+	router.Use(s.withFeatures)
+
+	for _, version := range apiVersions {
+		version.register(s, router.PathPrefix("/"+version.name).Subrouter())
+	}
+
+	// Service level objectives
+	router.HandleFunc("/slo", s.HandleSLO).Methods(http.MethodGet)
+
+	// Admin API
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(s.adminAuth)
+	admin.HandleFunc("/grants", s.HandleAdminGrants).Methods(http.MethodGet)
+	admin.HandleFunc("/migrations", s.HandleAdminMigrations).Methods(http.MethodGet)
+	admin.HandleFunc("/indexes", s.HandleAdminIndexes).Methods(http.MethodGet)
+	admin.HandleFunc("/indexes/{index}/rebuild", s.HandleAdminRebuildIndex).Methods(http.MethodPost)
+	admin.HandleFunc("/search", s.HandleSupportSearch).Methods(http.MethodPost)
+	admin.HandleFunc("/read-only", s.HandleAdminReadOnly).Methods(http.MethodGet)
+	admin.HandleFunc("/read-only", s.HandleAdminSetReadOnly).Methods(http.MethodPut)
+
+	for _, slo := range config.SLOs {
+		if router.Get(slo.Endpoint) == nil {
+			return nil, fmt.Errorf("invalid SLO: unknown endpoint '%s'", slo.Endpoint)
+		}
+	}
 
-	s.Handler = router
+	s.Handler = s.allowConsoleOrigin(s.negotiateVersion(router))
 
 	return s, nil
 }
 
+// registerV1Routes registers the routes of version 1 of the API.
+func (s *Server) registerV1Routes(router *mux.Router) {
+	// CRUD operations
+	router.HandleFunc("/metadata/{bucket}/{key:.*}", s.HandleGet).Methods(http.MethodGet).Name("get")
+	router.HandleFunc("/metadata/{bucket}/{key:.*}", s.HandleHead).Methods(http.MethodHead).Name("head")
+	router.HandleFunc("/metadata/{bucket}/{key:.*}", s.idempotent(s.HandleUpdate)).Methods(http.MethodPut).Name("update")
+	router.HandleFunc("/metadata/{bucket}/{key:.*}", s.idempotent(s.HandleDelete)).Methods(http.MethodDelete).Name("delete")
+	router.HandleFunc("/encrypted-metadata/{bucket}/{key:.*}", s.idempotent(s.HandleUpdateEncrypted)).Methods(http.MethodPut).Name("update-encrypted")
+	router.HandleFunc("/history/{bucket}/{key:.*}", s.HandleHistory).Methods(http.MethodGet).Name("history")
+	router.HandleFunc("/history/{bucket}/{key:.*}", s.idempotent(s.HandleRollback)).Methods(http.MethodPost).Name("rollback")
+	router.HandleFunc("/restore/{bucket}/{key:.*}", s.idempotent(s.HandleRestore)).Methods(http.MethodPost).Name("restore")
+	router.HandleFunc("/s3/{bucket}/{key:.*}", s.HandleGetObjectTagging).Methods(http.MethodGet).MatcherFunc(hasQuery("tagging")).Name("get-object-tagging")
+	router.HandleFunc("/s3/{bucket}/{key:.*}", s.HandlePutObjectTagging).Methods(http.MethodPut).MatcherFunc(hasQuery("tagging")).Name("put-object-tagging")
+	router.HandleFunc("/s3/{bucket}/{key:.*}", s.HandleDeleteObjectTagging).Methods(http.MethodDelete).MatcherFunc(hasQuery("tagging")).Name("delete-object-tagging")
+
+	// Search
+	router.HandleFunc("/metasearch/{bucket}", s.HandleList).Methods(http.MethodGet).Name("list")
+	router.HandleFunc("/metasearch/{bucket}", s.HandleQuery).Methods(http.MethodPost).Name("search")
+	router.HandleFunc("/metasearch/{bucket}/watermark", s.HandleWatermark).Methods(http.MethodGet).Name("watermark")
+	router.HandleFunc("/metasearch/{bucket}/changes", s.HandleChanges).Methods(http.MethodGet).Name("changes")
+	router.HandleFunc("/metasearch/{bucket}/watch", s.HandleWatch).Methods(http.MethodGet).Name("watch")
+	router.HandleFunc("/metasearch/{bucket}/jobs", s.HandleSubmitJob).Methods(http.MethodPost).Name("submit-job")
+	router.HandleFunc("/metasearch/{bucket}/jobs/{job}", s.HandleGetJob).Methods(http.MethodGet).Name("get-job")
+	router.HandleFunc("/metasearch/{bucket}/jobs/{job}", s.HandleDeleteJob).Methods(http.MethodDelete).Name("delete-job")
+	router.HandleFunc("/metasearch/{bucket}/jobs/{job}/results", s.HandleJobResults).Methods(http.MethodGet).Name("job-results")
+	router.HandleFunc("/metasearch/{bucket}/schedules", s.HandleListSchedules).Methods(http.MethodGet).Name("list-schedules")
+	router.HandleFunc("/metasearch/{bucket}/schedules", s.HandleCreateSchedule).Methods(http.MethodPost).Name("create-schedule")
+	router.HandleFunc("/metasearch/{bucket}/schedules/{schedule}", s.HandleGetSchedule).Methods(http.MethodGet).Name("get-schedule")
+	router.HandleFunc("/metasearch/{bucket}/schedules/{schedule}", s.HandleDeleteSchedule).Methods(http.MethodDelete).Name("delete-schedule")
+	router.HandleFunc("/metasearch/{bucket}/links", s.HandleCreateSearchLink).Methods(http.MethodPost).Name("create-search-link")
+	router.HandleFunc("/metasearch/{bucket}/aggregate", s.HandleAggregate).Methods(http.MethodPost).Name("aggregate")
+	router.HandleFunc("/metasearch/{bucket}/rollup", s.HandleRollup).Methods(http.MethodPost).Name("rollup")
+	router.HandleFunc("/metasearch/{bucket}/distinct", s.HandleDistinct).Methods(http.MethodGet).Name("distinct")
+	router.HandleFunc("/metasearch/{bucket}/suggest", s.HandleSuggest).Methods(http.MethodGet).Name("suggest")
+	router.HandleFunc("/metasearch/{bucket}/top", s.HandleTopValues).Methods(http.MethodGet).Name("top")
+	router.HandleFunc("/metasearch/{bucket}/keys", s.HandleKeys).Methods(http.MethodGet).Name("keys")
+	router.HandleFunc("/metasearch/{bucket}/lint", s.HandleLint).Methods(http.MethodPost).Name("lint")
+
+	// Search links
+	router.HandleFunc("/links/{token}", s.HandleSearchLink).Methods(http.MethodGet).Name("search-link")
+
+	// Import
+	router.HandleFunc("/import/{bucket}", s.HandleImport).Methods(http.MethodPost).Name("import")
+
+	// Vocabularies
+	router.HandleFunc("/vocabularies", s.HandleVocabularies).Methods(http.MethodGet).Name("vocabularies")
+	router.HandleFunc("/vocabularies/{key}", s.HandleSetVocabulary).Methods(http.MethodPut).Name("set-vocabulary")
+	router.HandleFunc("/vocabularies/{key}", s.HandleDeleteVocabulary).Methods(http.MethodDelete).Name("delete-vocabulary")
+
+	// Configuration bundles
+	router.HandleFunc("/configuration", s.HandleExportConfiguration).Methods(http.MethodGet).Name("export-configuration")
+	router.HandleFunc("/configuration", s.HandleImportConfiguration).Methods(http.MethodPut).Name("import-configuration")
+}
+
 // Run starts the metasearch server.
 func (s *Server) Run() error {
-	s.Migrator.Start()
-	return http.ListenAndServe(s.Endpoint, s.Handler)
+	if !s.Config.Stateless {
+		s.Migrator.Start()
+	}
+	if s.Config.SoftDeleteRetention > 0 {
+		go s.purgeTombstones()
+	}
+	go s.expireMetadata()
+	if s.Config.JobRetention > 0 {
+		go s.purgeJobs()
+	}
+	if s.Config.ScheduleSecret != "" {
+		go s.runSchedules()
+	}
+	if len(s.Config.SLOs) > 0 {
+		go s.reportSLOs()
+	}
+
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+	if s.Config.DRPCEndpoint != "" {
+		go func() {
+			if err := s.serveDRPC(context.Background(), tlsConfig); err != nil {
+				s.Logger.Error("DRPC server failed", zap.Error(err))
+			}
+		}()
+	}
+	return s.listenAndServe(tlsConfig)
 }
 
 func (s *Server) validateRequest(ctx context.Context, r *http.Request, baseRequest *BaseRequest, body interface{}) error {
@@ -117,23 +603,47 @@ func (s *Server) validateRequest(ctx context.Context, r *http.Request, baseReque
 		return err
 	}
 	baseRequest.Authorizer = authorizer
-
-	s.Migrator.AddProject(ctx, projectID, encryptor)
-	if !s.Migrator.WaitForProject(ctx, projectID, migrationTimeout) {
-		return ErrMetadataIndexingInProgress
+	s.trackGrant(projectID, r)
+	s.resolveFeatures(ctx, projectID, r)
+	recordAccessProject(ctx, projectID)
+
+	switch {
+	case s.ReadOnly():
+		// Migrations write to the metabase, they resume when read-only mode
+		// ends, and clear metadata may be stale in the meantime
+		if !s.Config.Stateless {
+			s.Migrator.AddProject(ctx, projectID, encryptor)
+		}
+	case s.Config.Stateless:
+		release, err := s.concurrency.acquire(migrationClass)
+		if err != nil {
+			return err
+		}
+		migrated := s.Migrator.MigrateProjectWith(ctx, projectID, encryptor, migrationTimeout)
+		release()
+		if !migrated {
+			return ErrMetadataIndexingInProgress
+		}
+	default:
+		s.Migrator.AddProject(ctx, projectID, encryptor)
+		if !s.Migrator.WaitForProject(ctx, projectID, migrationTimeout) {
+			return ErrMetadataIndexingInProgress
+		}
 	}
 
 	// Decode request body
 	if body != nil && r.Body != nil {
-		if err = json.NewDecoder(r.Body).Decode(body); err != nil {
-			return fmt.Errorf("%w: error decoding request body: %w", ErrBadRequest, err)
+		if err = newJSONDecoder(r.Body).Decode(body); err != nil {
+			return bodyError(err, "error decoding request body")
 		}
 	}
 
-	// Set location
 	vars := mux.Vars(r)
-	bucket := vars["bucket"]
-	key := vars["key"]
+	return setLocation(baseRequest, projectID, encryptor, vars["bucket"], vars["key"])
+}
+
+// setLocation sets the location of a request and its encrypted location.
+func setLocation(baseRequest *BaseRequest, projectID uuid.UUID, encryptor Encryptor, bucket string, key string) error {
 	baseRequest.Encryptor = encryptor
 	baseRequest.Location = ObjectLocation{
 		ProjectID:  projectID,
@@ -156,85 +666,461 @@ func (s *Server) validateRequest(ctx context.Context, r *http.Request, baseReque
 	return nil
 }
 
+// HandleGet handles a metadata get request.
 func (s *Server) HandleGet(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	var request BaseRequest
+	obj, request, err := s.getObject(r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
 
-	err := s.validateRequest(ctx, r, &request, nil)
+	etag := metadataETag(obj.Metadata.ClearMetadata)
+	if watch := r.URL.Query().Get("watch"); watch != "" && notModified(r, etag) {
+		// Long-poll until the metadata changes
+		obj, err = s.waitForChange(r, request, obj, watch)
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+		etag = metadataETag(obj.Metadata.ClearMetadata)
+	}
+	w.Header().Set("ETag", etag)
+	if obj.Metadata.ExpiresAt != nil {
+		w.Header().Set("Metadata-Expires", obj.Metadata.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	metadata, err := selectMetadata(r, obj.Metadata.ClearMetadata)
 	if err != nil {
 		s.errorResponse(w, err)
 		return
 	}
 
-	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionReadMetadata)
+	s.jsonResponse(w, http.StatusOK, metadata)
+}
+
+// HandleHead handles a metadata head request. It returns the same headers as
+// HandleGet, without the response body.
+func (s *Server) HandleHead(w http.ResponseWriter, r *http.Request) {
+	obj, _, err := s.getObject(r)
 	if err != nil {
 		s.errorResponse(w, err)
 		return
 	}
 
-	obj, err := s.Repo.GetMetadata(ctx, request.EncryptedLocation)
+	etag := metadataETag(obj.Metadata.ClearMetadata)
+	w.Header().Set("ETag", etag)
+	if obj.Metadata.ExpiresAt != nil {
+		w.Header().Set("Metadata-Expires", obj.Metadata.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	metadata, err := selectMetadata(r, obj.Metadata.ClearMetadata)
 	if err != nil {
 		s.errorResponse(w, err)
 		return
 	}
 
-	if obj.MetaSearchQueuedAt != nil {
-		_ = s.Migrator.MigrateObject(ctx, &obj)
+	jsonBytes, err := json.Marshal(metadata)
+	if err != nil {
+		s.errorResponse(w, fmt.Errorf("%w: %v", ErrInternalError, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(jsonBytes)))
+	if !obj.UpdatedAt.IsZero() {
+		w.Header().Set("Last-Modified", obj.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// getObject fetches the object for a get or head request, and migrates its
+// metadata if it is queued for migration.
+func (s *Server) getObject(r *http.Request) (obj ObjectInfo, request BaseRequest, err error) {
+	ctx := r.Context()
+
+	err = s.validateRequest(ctx, r, &request, nil)
+	if err != nil {
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionReadMetadata)
+	if err != nil {
+		return
+	}
+
+	if versionID := r.URL.Query().Get("versionId"); versionID != "" {
+		request.EncryptedLocation.Version, err = parseVersionID(versionID)
+		if err != nil {
+			return obj, request, fmt.Errorf("%w: %v", ErrBadRequest, err)
+		}
+	}
+
+	obj, err = s.Repo.GetMetadata(ctx, request.EncryptedLocation)
+	if err != nil {
+		return
+	}
+
+	s.migrateObject(ctx, &obj, request.Encryptor)
+	return obj, request, nil
+}
+
+// selectMetadata returns the part of the metadata selected by the fields or
+// projection query parameters of a get request. Fields are comma separated
+// paths of keys joined with dots, missing keys are omitted.
+func selectMetadata(r *http.Request, metadata map[string]interface{}) (interface{}, error) {
+	q := r.URL.Query()
+	fields, projection := q.Get("fields"), q.Get("projection")
+	switch {
+	case fields != "" && projection != "":
+		return nil, fmt.Errorf("%w: fields and projection cannot be combined", ErrBadRequest)
+	case projection != "":
+		projectionPath, err := jmespath.Compile(projection)
+		if err != nil {
+			return nil, jmespathError("invalid projection expression", err)
+		}
+		projected, err := projectionPath.Search(floatNumbers(metadata))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadRequest, err)
+		}
+		return projected, nil
+	case fields != "":
+		selected := make(map[string]interface{})
+		for _, field := range strings.Split(fields, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				return nil, fmt.Errorf("%w: invalid fields", ErrBadRequest)
+			}
+			selectField(selected, metadata, strings.Split(field, "."))
+		}
+		return selected, nil
+	default:
+		return metadata, nil
+	}
+}
+
+// selectField copies the value at path from metadata to selected, creating
+// the parent objects of the value.
+func selectField(selected map[string]interface{}, metadata map[string]interface{}, path []string) {
+	value, ok := metadata[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		selected[path[0]] = value
+		return
+	}
+
+	child, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	selectedChild, ok := selected[path[0]].(map[string]interface{})
+	if !ok {
+		selectedChild = make(map[string]interface{})
+	}
+	selectField(selectedChild, child, path[1:])
+	if len(selectedChild) > 0 {
+		selected[path[0]] = selectedChild
 	}
-	s.jsonResponse(w, http.StatusOK, obj.Metadata.ClearMetadata)
 }
 
 // HandleQuery handles a metadata view or search request.
 func (s *Server) HandleQuery(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	var request SearchRequest
-	var result SearchResponse
 
-	err := s.validateSearchRequest(ctx, r, &request)
+	err := s.validateRequest(ctx, r, &request.BaseRequest, &request)
 	if err != nil {
 		s.errorResponse(w, err)
 		return
 	}
 
-	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	s.handleSearch(w, r, &request)
+}
+
+// HandleList handles a metadata listing request. It is a read-only
+// alternative of HandleQuery, without a JSON match query. Parameters are
+// passed in the URL query string.
+func (s *Server) HandleList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request SearchRequest
+
+	err := s.validateRequest(ctx, r, &request.BaseRequest, nil)
 	if err != nil {
 		s.errorResponse(w, err)
 		return
 	}
 
-	result, err = s.searchMetadata(ctx, &request)
+	q := r.URL.Query()
+	request.KeyPrefix = q.Get("prefix")
+	request.KeyPattern = q.Get("keyPattern")
+	request.KeyRegex = q.Get("keyRegex")
+	request.Query = q.Get("query")
+	request.Delimiter = q.Get("delimiter")
+	if columns := q.Get("columns"); columns != "" {
+		request.Columns = strings.Split(columns, ",")
+	}
+	if sortKey := q.Get("sort"); sortKey != "" {
+		request.Sort = &SearchSort{Key: sortKey, Order: q.Get("order")}
+	}
+	request.Timeout = q.Get("timeout")
+	request.Staleness = q.Get("staleness")
+	if partialResults := q.Get("partialResults"); partialResults != "" {
+		request.PartialResults, err = strconv.ParseBool(partialResults)
+		if err != nil {
+			s.errorResponse(w, fmt.Errorf("%w: invalid partialResults", ErrBadRequest))
+			return
+		}
+	}
+	if countOnly := q.Get("countOnly"); countOnly != "" {
+		request.CountOnly, err = strconv.ParseBool(countOnly)
+		if err != nil {
+			s.errorResponse(w, fmt.Errorf("%w: invalid countOnly", ErrBadRequest))
+			return
+		}
+	}
+	request.PageToken = q.Get("pageToken")
+	if batchSize := q.Get("batchSize"); batchSize != "" {
+		request.BatchSize, err = strconv.Atoi(batchSize)
+		if err != nil {
+			s.errorResponse(w, fmt.Errorf("%w: invalid batchSize", ErrBadRequest))
+			return
+		}
+	}
+	if decryptPaths := q.Get("decryptPaths"); decryptPaths != "" {
+		v, err := strconv.ParseBool(decryptPaths)
+		if err != nil {
+			s.errorResponse(w, fmt.Errorf("%w: invalid decryptPaths", ErrBadRequest))
+			return
+		}
+		request.DecryptPaths = &v
+	}
+	if includeSystemMetadata := q.Get("includeSystemMetadata"); includeSystemMetadata != "" {
+		request.IncludeSystemMetadata, err = strconv.ParseBool(includeSystemMetadata)
+		if err != nil {
+			s.errorResponse(w, fmt.Errorf("%w: invalid includeSystemMetadata", ErrBadRequest))
+			return
+		}
+	}
+
+	s.handleSearch(w, r, &request)
+}
+
+// handleSearch validates, authorizes and executes a parsed search request.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request, request *SearchRequest) {
+	ctx := r.Context()
+
+	// ListObjectsV2 responses report errors in the format of S3
+	errorResponse := s.errorResponse
+	var listPrefix string
+	listObjects := wantsListObjectsV2(r)
+	if listObjects {
+		errorResponse = s.s3ErrorResponse
+		var err error
+		listPrefix, err = parseListObjectsV2(r, request)
+		if err != nil {
+			errorResponse(w, err)
+			return
+		}
+	}
+
+	err := s.validateSearchRequest(request)
 	if err != nil {
-		s.errorResponse(w, err)
+		errorResponse(w, err)
 		return
 	}
 
-	s.jsonResponse(w, http.StatusOK, result)
-}
+	if request.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, request.timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
 
-func (s *Server) validateSearchRequest(ctx context.Context, r *http.Request, request *SearchRequest) error {
-	err := s.validateRequest(ctx, r, &request.BaseRequest, request)
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
 	if err != nil {
-		return err
+		errorResponse(w, err)
+		return
+	}
+
+	release, err := s.throttleQuery(request.cost)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	defer release()
+	w.Header().Set("X-Metasearch-Query-Cost", strconv.Itoa(request.cost.Total))
+
+	if listObjects {
+		s.listObjectsV2(w, r, request, listPrefix)
+		return
+	}
+
+	if format := wantsExport(r); format != nil {
+		if err = validateExport(request); err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+		s.exportSearch(w, r, request, format)
+		return
+	}
+
+	if wantsNDJSON(r) && !request.CountOnly && request.SimilarTo == nil {
+		s.streamSearch(w, r, request)
+		return
+	}
+
+	etag := s.searchETag(ctx, request, grantFingerprint(r))
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+		if notModified(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	if request.CountOnly {
+		count, err := s.countMetadata(ctx, request)
+		if err != nil {
+			s.errorResponse(w, err)
+			return
+		}
+		s.jsonResponse(w, http.StatusOK, count)
+		return
+	}
+
+	result, err := s.cachedSearchMetadata(w, r, request, etag)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+	if result.Truncated {
+		// Truncated results depend on timing, not only on the data
+		w.Header().Del("ETag")
 	}
 
+	s.jsonResponse(w, http.StatusOK, result)
+}
+
+func (s *Server) validateSearchRequest(request *SearchRequest) (err error) {
 	// Validate match query
 	if request.Match == nil {
 		request.Match = make(map[string]interface{})
 	}
+	if request.Query != "" {
+		query, err := parseTextQuery(request.Query)
+		if err != nil {
+			return err
+		}
+		clauses, ok := request.Match[andKey].([]interface{})
+		if _, exists := request.Match[andKey]; exists && !ok {
+			return fmt.Errorf("%w: %s must be a non-empty array of match objects", ErrBadRequest, andKey)
+		}
+		request.Match[andKey] = append(clauses, query)
+	}
+	if len(request.AnyOf) > 0 {
+		if _, ok := request.Match[anyOfKey]; ok {
+			return fmt.Errorf("%w: anyOf cannot be set in both the request and the match query", ErrBadRequest)
+		}
+		alternatives := make([]interface{}, 0, len(request.AnyOf))
+		for _, alternative := range request.AnyOf {
+			alternatives = append(alternatives, alternative)
+		}
+		request.Match[anyOfKey] = alternatives
+	}
+	if request.Not != nil {
+		if _, ok := request.Match[notKey]; ok {
+			return fmt.Errorf("%w: not cannot be set in both the request and the match query", ErrBadRequest)
+		}
+		request.Match[notKey] = request.Not
+	}
+	if request.System != nil {
+		if _, ok := request.Match[systemKey]; ok {
+			return fmt.Errorf("%w: system cannot be set in both the request and the match query", ErrBadRequest)
+		}
+		request.Match[systemKey] = request.System
+	}
+	for clause, keys := range map[string][]string{existsKey: request.Exists, missingKey: request.Missing} {
+		if len(keys) == 0 {
+			continue
+		}
+		if _, ok := request.Match[clause]; ok {
+			return fmt.Errorf("%w: %s cannot be set in both the request and the match query", ErrBadRequest, strings.TrimPrefix(clause, "$"))
+		}
+		values := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			values = append(values, key)
+		}
+		request.Match[clause] = values
+	}
+	if request.CaseInsensitive {
+		request.Match[caseInsensitiveKey] = true
+	}
+	match, err := parseMatch(request.Match)
+	if err != nil {
+		return err
+	}
+	if request.Highlight {
+		request.match = &match
+	}
 
 	// Validate batch size
 	if request.BatchSize <= 0 || request.BatchSize > maxBatchSize {
 		request.BatchSize = defaultBatchSize
 	}
 
+	if request.CountOnly {
+		err = validateWithoutObjects(request, "countOnly")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Validate sort order
+	request.sort, err = request.Sort.metadataSort()
+	if err != nil {
+		return err
+	}
+
 	// Validate pageToken
 	if request.PageToken != "" {
-		request.startAfter, err = parsePageToken(request.PageToken)
+		var sort *MetadataSort
+		request.startAfter, request.asOf, sort, err = parseSortedPageToken(request.PageToken)
+		if err != nil {
+			return err
+		}
+		if !sort.sameOrder(request.sort) {
+			return fmt.Errorf("%w: the page token does not match the sort order", ErrBadRequest)
+		}
+		if sort != nil {
+			request.sort = sort
+		}
+		err = s.checkSnapshot(request)
 		if err != nil {
 			return err
 		}
 	}
 
+	// Validate staleness. Later pages read the snapshot of the first page.
+	if request.Staleness != "" {
+		var staleness time.Duration
+		staleness, err = time.ParseDuration(request.Staleness)
+		if err != nil || staleness <= 0 || staleness > maxStaleness {
+			return fmt.Errorf("%w: invalid staleness", ErrBadRequest)
+		}
+		if request.asOf.IsZero() {
+			request.asOf = time.Now().Add(-staleness)
+		}
+	}
+
 	// Override key by KeyPrefix parameter
 	keyPrefix := normalizeKeyPrefix(request.KeyPrefix)
 	if keyPrefix != "" {
@@ -254,6 +1140,55 @@ func (s *Server) validateSearchRequest(ctx context.Context, r *http.Request, req
 		}
 	}
 
+	// Validate delimiter, which requires decrypted paths in key order
+	if request.Delimiter != "" {
+		if request.Delimiter != "/" {
+			return fmt.Errorf("%w: only '/' is supported as delimiter", ErrBadRequest)
+		}
+		if request.DecryptPaths != nil && !*request.DecryptPaths {
+			return fmt.Errorf("%w: delimiter cannot be used without decrypting paths", ErrBadRequest)
+		}
+		if request.Sort != nil || request.CountOnly {
+			return fmt.Errorf("%w: delimiter cannot be used with sort or countOnly", ErrBadRequest)
+		}
+	}
+
+	// Validate similarity search, whose results are ranked as a whole
+	if request.SimilarTo != nil {
+		if err = request.SimilarTo.validate(); err != nil {
+			return err
+		}
+		if request.Sort != nil || request.Delimiter != "" || request.CountOnly || request.PageToken != "" {
+			return fmt.Errorf("%w: similarTo cannot be used with sort, delimiter, countOnly or pageToken", ErrBadRequest)
+		}
+	}
+
+	// Validate key patterns, which require decrypted paths
+	if request.KeyPattern != "" || request.KeyRegex != "" {
+		if request.DecryptPaths != nil && !*request.DecryptPaths {
+			return fmt.Errorf("%w: key patterns cannot be used without decrypting paths", ErrBadRequest)
+		}
+	}
+	if request.KeyPattern != "" {
+		if _, err := path.Match(request.KeyPattern, ""); err != nil {
+			return fmt.Errorf("%w: invalid keyPattern", ErrBadRequest)
+		}
+	}
+	if request.KeyRegex != "" {
+		request.keyRegex, err = regexp.Compile(request.KeyRegex)
+		if err != nil {
+			return fmt.Errorf("%w: invalid keyRegex: %v", ErrBadRequest, err)
+		}
+	}
+
+	// Validate timeout
+	if request.Timeout != "" {
+		request.timeout, err = time.ParseDuration(request.Timeout)
+		if err != nil || request.timeout <= 0 || request.timeout > maxSearchTimeout {
+			return fmt.Errorf("%w: invalid timeout", ErrBadRequest)
+		}
+	}
+
 	// Validate projection
 	if request.Projection != "" {
 		request.projectionPath, err = jmespath.Compile(request.Projection)
@@ -262,61 +1197,233 @@ func (s *Server) validateSearchRequest(ctx context.Context, r *http.Request, req
 		}
 	}
 
-	return nil
+	// Estimate the cost of the search before it reaches the metabase
+	request.cost = estimateQueryCost(request, match)
+	return s.checkQueryCost(request.cost)
 }
 
 func (s *Server) searchMetadata(ctx context.Context, request *SearchRequest) (response SearchResponse, err error) {
-	searchResult, err := s.Repo.QueryMetadata(ctx, request.EncryptedLocation, request.Match, request.startAfter, request.BatchSize)
-	if err != nil {
-		return
+	defer func() {
+		if err == nil {
+			recordAccessResults(ctx, len(response.Results))
+		}
+	}()
+
+	// Pages filtered by key pattern or grouped by delimiter are refilled
+	// from the following batches, so that they are not mostly empty.
+	refill := request.KeyPattern != "" || request.KeyRegex != "" || request.Delimiter != ""
+
+	// With partial results, queries are cancelled shortly before the
+	// deadline of the request, so that the results found so far can still be
+	// returned.
+	queryCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok && request.PartialResults {
+		margin := min(time.Until(deadline)/10, maxPartialResultsMargin)
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithDeadline(ctx, deadline.Add(-margin))
+		defer cancel()
 	}
 
-	// Extract keys
-	var metadata map[string]interface{}
-	var projectedMetadata interface{}
-
-	var shouldInclude bool
+	startAfter, sort, asOf := request.startAfter, request.sort, request.asOf
 	response.Results = make([]SearchResult, 0)
-	for _, obj := range searchResult.Objects {
-		// Decode path
-		decodedPath, encryptorErr := request.Encryptor.DecryptPath(request.Location.BucketName, string(obj.ObjectKey))
-		if encryptorErr != nil {
-			continue
-		}
 
-		// Apply filter
-		metadata = obj.Metadata.ClearMetadata
-		shouldInclude, err = s.filterMetadata(request, metadata)
+	// skipPrefix is the encrypted key prefix of the last common prefix,
+	// whose other objects are skipped.
+	var skipPrefix string
+	for batch := 1; ; batch++ {
+		// Objects are processed as they are read, so that the objects of
+		// large batches are not kept in memory.
+		var fetched int
+		var lastFetched ObjectLocation
+		var searchResult QueryMetadataResult
+		searchResult, err = s.Repo.QueryMetadataFunc(queryCtx, request.EncryptedLocation, request.Match, sort, startAfter, asOf, request.BatchSize, func(obj ObjectInfo) (err error) {
+			fetched++
+			lastFetched = obj.ObjectLocation
+			if skipPrefix != "" && strings.HasPrefix(obj.ObjectKey, skipPrefix) {
+				// The subdirectory is already in the common prefixes
+				return nil
+			}
+
+			skipPrefix, err = s.appendSearchResult(&response, request, obj)
+			if err != nil {
+				return err
+			}
+
+			sort, err = sort.after(obj)
+			if err != nil {
+				return err
+			}
+			startAfter = skipLocation(obj.ObjectLocation, skipPrefix)
+			if refill && len(response.Results)+len(response.CommonPrefixes) >= request.BatchSize {
+				return errPageFull
+			}
+			return nil
+		})
+		if s.Config.PageTokenRetention > 0 && !searchResult.AsOf.IsZero() {
+			asOf = searchResult.AsOf
+		}
+		if errors.Is(err, errPageFull) {
+			response.Warnings = appendWarnings(response.Warnings, searchResult.Warnings)
+			response.PageToken = getSortedPageToken(startAfter, sort, asOf)
+			return response, nil
+		}
 		if err != nil {
+			if queryCtx.Err() != nil && ctx.Err() == nil {
+				// Return the results found before the deadline, and resume
+				// after the last processed object.
+				response.Truncated = true
+				if !startAfter.ProjectID.IsZero() {
+					response.PageToken = getSortedPageToken(startAfter, sort, asOf)
+				}
+				return response, nil
+			}
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return response, fmt.Errorf("%w: search timed out", ErrServiceUnavailable)
+			}
 			return
 		}
-		if !shouldInclude {
-			continue
+		response.Warnings = appendWarnings(response.Warnings, searchResult.Warnings)
+
+		// Determine the start of the next batch
+		var next *ObjectLocation
+		if fetched >= request.BatchSize {
+			last := skipLocation(lastFetched, skipPrefix)
+			next = &last
+		} else if searchResult.ScannedUntil != nil {
+			next = searchResult.ScannedUntil
+		}
+		if next == nil {
+			return
 		}
 
-		// Apply projection
-		if request.projectionPath != nil {
-			projectedMetadata, err = request.projectionPath.Search(metadata)
-		} else {
-			projectedMetadata = metadata
+		if !refill || batch >= maxRefillBatches || queryCtx.Err() != nil {
+			response.Truncated = request.PartialResults && queryCtx.Err() != nil
+			response.PageToken = getSortedPageToken(*next, sort, asOf)
+			return
 		}
+		startAfter = *next
+	}
+}
+
+// appendSearchResult adds an object to the results of a search, unless its
+// path or metadata are filtered out. If the object is grouped into a new
+// common prefix, it returns the encrypted key prefix of its subdirectory.
+func (s *Server) appendSearchResult(response *SearchResponse, request *SearchRequest, obj ObjectInfo) (skipPrefix string, err error) {
+	// Decode path. Objects whose path cannot be decrypted with the access
+	// grant are skipped, also when paths are omitted from the results.
+	decodedPath, err := request.Encryptor.DecryptPath(request.Location.BucketName, string(obj.ObjectKey))
+	if err != nil {
+		return "", nil
+	}
+	if !matchKey(request, decodedPath) {
+		return "", nil
+	}
+	var path string
+	if request.DecryptPaths == nil || *request.DecryptPaths {
+		path = fmt.Sprintf("sj://%s/%s", obj.BucketName, decodedPath)
+	}
+
+	// Apply filter
+	metadata := obj.Metadata.ClearMetadata
+	shouldInclude, err := s.filterMetadata(request, metadata)
+	if err != nil || !shouldInclude {
+		return "", err
+	}
+
+	// Group objects in subdirectories
+	if request.Delimiter != "" {
+		if prefix, encPrefix, ok := commonPrefix(request.Location.ObjectKey, decodedPath, obj.ObjectKey); ok {
+			response.CommonPrefixes = append(response.CommonPrefixes, fmt.Sprintf("sj://%s/%s", obj.BucketName, prefix))
+			return encPrefix, nil
+		}
+	}
+
+	// Apply projection
+	var projectedMetadata interface{} = metadata
+	if request.projectionPath != nil {
+		projectedMetadata, err = request.projectionPath.Search(floatNumbers(metadata))
 		if err != nil {
-			return
+			return "", err
 		}
+	}
 
-		response.Results = append(response.Results, SearchResult{
-			Path:     fmt.Sprintf("sj://%s/%s", obj.BucketName, decodedPath),
-			Metadata: projectedMetadata,
-		})
+	result := SearchResult{
+		Path:     path,
+		Metadata: projectedMetadata,
 	}
+	if request.IncludeSystemMetadata {
+		result.System = systemMetadata(obj)
+	}
+	if request.Highlight {
+		result.Highlights = s.highlightMetadata(request, metadata)
+	}
+	response.Results = append(response.Results, result)
+	return "", nil
+}
 
-	// Determine page token
-	if len(searchResult.Objects) >= request.BatchSize {
-		last := searchResult.Objects[len(searchResult.Objects)-1]
-		response.PageToken = getPageToken(last.ObjectLocation)
+// matchKey returns true if a decrypted object key matches the key patterns of
+// a search request.
+func matchKey(request *SearchRequest, key string) bool {
+	if request.KeyPattern != "" {
+		if ok, _ := path.Match(request.KeyPattern, key); !ok {
+			return false
+		}
 	}
+	return request.keyRegex == nil || request.keyRegex.MatchString(key)
+}
 
-	return
+// commonPrefix returns the clear and encrypted key prefixes of the
+// subdirectory of keyPrefix that contains an object, if any. Paths are
+// encrypted per segment, so the encrypted prefix consists of as many segments
+// of the encrypted key as the clear prefix.
+func commonPrefix(keyPrefix string, key string, encKey string) (prefix string, encPrefix string, ok bool) {
+	rel, found := strings.CutPrefix(key, keyPrefix)
+	if !found {
+		return "", "", false
+	}
+	i := strings.Index(rel, "/")
+	if i < 0 {
+		return "", "", false
+	}
+	prefix = keyPrefix + rel[:i+1]
+
+	segments := strings.SplitAfter(encKey, "/")
+	n := strings.Count(prefix, "/")
+	if n >= len(segments) {
+		return "", "", false
+	}
+	return prefix, strings.Join(segments[:n], ""), true
+}
+
+// skipLocation returns the location to continue a search after an object. If
+// the object is in a grouped subdirectory, the search continues after the
+// subdirectory.
+func skipLocation(loc ObjectLocation, skipPrefix string) ObjectLocation {
+	if skipPrefix == "" {
+		return loc
+	}
+	// Versions start at 1, so no version of the key succeeding the
+	// subdirectory is skipped.
+	loc.ObjectKey = prefixLimit(skipPrefix)
+	loc.Version = 0
+	return loc
+}
+
+// appendWarnings appends the warnings that are not in the list yet.
+func appendWarnings(warnings []string, added []string) []string {
+	for _, warning := range added {
+		found := false
+		for _, w := range warnings {
+			if w == warning {
+				found = true
+				break
+			}
+		}
+		if !found {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
 }
 
 func (s *Server) filterMetadata(request *SearchRequest, metadata map[string]interface{}) (bool, error) {
@@ -325,7 +1432,7 @@ func (s *Server) filterMetadata(request *SearchRequest, metadata map[string]inte
 	}
 
 	// Evaluate JMESPath filter
-	result, err := request.filterPath.Search(metadata)
+	result, err := request.filterPath.Search(floatNumbers(metadata))
 	if err != nil {
 		return false, fmt.Errorf("%w: %v", ErrBadRequest, err)
 	}
@@ -362,8 +1469,21 @@ func (s *Server) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expiresAt, err := parseMetadataExpiration(r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = s.checkVocabularies(ctx, request.Location.ProjectID, metadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
 	meta := ObjectMetadata{
 		ClearMetadata: metadata,
+		ExpiresAt:     expiresAt,
 	}
 
 	err = request.Encryptor.EncryptMetadata(request.Location.BucketName, request.Location.ObjectKey, &meta)
@@ -372,12 +1492,72 @@ func (s *Server) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.Repo.UpdateMetadata(ctx, request.EncryptedLocation, meta)
+	err = s.updateMetadata(ctx, r, request.EncryptedLocation, request.Encryptor, meta)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", metadataETag(meta.ClearMetadata))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleUpdateEncrypted handles a metadata update request with metadata that
+// is encrypted by the client. The server-side encryptor is not used: the
+// encrypted metadata is stored as is, and only the clear metadata sent by the
+// client is searchable.
+func (s *Server) HandleUpdateEncrypted(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request BaseRequest
+	var body EncryptedUpdateRequest
+
+	err := s.validateRequest(ctx, r, &request, &body)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionWriteMetadata)
 	if err != nil {
 		s.errorResponse(w, err)
 		return
 	}
 
+	hasNonce := len(body.EncryptedMetadataNonce) > 0
+	hasMetadata := len(body.EncryptedMetadata) > 0
+	hasKey := len(body.EncryptedMetadataKey) > 0
+	if hasNonce != hasMetadata || hasNonce != hasKey {
+		s.errorResponse(w, fmt.Errorf("%w: encrypted metadata, nonce and key must be set together", ErrBadRequest))
+		return
+	}
+
+	expiresAt, err := parseMetadataExpiration(r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = s.checkVocabularies(ctx, request.Location.ProjectID, body.ClearMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	meta := ObjectMetadata{
+		EncryptedMetadataNonce: body.EncryptedMetadataNonce,
+		EncryptedMetadata:      body.EncryptedMetadata,
+		EncryptedMetadataKey:   body.EncryptedMetadataKey,
+		ClearMetadata:          body.ClearMetadata,
+		ExpiresAt:              expiresAt,
+	}
+
+	err = s.updateMetadata(ctx, r, request.EncryptedLocation, request.Encryptor, meta)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", metadataETag(meta.ClearMetadata))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -398,7 +1578,22 @@ func (s *Server) HandleDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.Repo.DeleteMetadata(ctx, request.EncryptedLocation)
+	expected, conditional, err := s.checkIfMatch(ctx, r, request.EncryptedLocation, request.Encryptor)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	switch {
+	case s.Config.SoftDeleteRetention > 0 && conditional:
+		err = s.Repo.SoftDeleteMetadataIfMatch(ctx, request.EncryptedLocation, expected)
+	case s.Config.SoftDeleteRetention > 0:
+		err = s.Repo.SoftDeleteMetadata(ctx, request.EncryptedLocation)
+	case conditional:
+		err = s.Repo.UpdateMetadataIfMatch(ctx, request.EncryptedLocation, expected, ObjectMetadata{})
+	default:
+		err = s.Repo.DeleteMetadata(ctx, request.EncryptedLocation)
+	}
 	if err != nil {
 		s.errorResponse(w, err)
 		return
@@ -434,45 +1629,90 @@ func (s *Server) errorResponse(w http.ResponseWriter, err error) {
 	w.Write([]byte(resp))
 }
 
-func getPageToken(obj ObjectLocation) string {
+// getPageToken returns the page token of the next page after obj. Page tokens
+// are stateless, so that they remain valid across server instances and
+// restarts. If asOf is not zero, later pages read the database snapshot at
+// asOf.
+func getPageToken(obj ObjectLocation, asOf time.Time) string {
+	return getSortedPageToken(obj, nil, asOf)
+}
+
+// getSortedPageToken returns the page token of the next page after obj in a
+// search sorted by a metadata value. The token contains the sort value of
+// obj.
+func getSortedPageToken(obj ObjectLocation, sort *MetadataSort, asOf time.Time) string {
 	q := url.Values{}
 	q.Set("projectID", obj.ProjectID.String())
 	q.Set("bucketName", obj.BucketName)
 	q.Set("objectKey", obj.ObjectKey)
 	q.Set("version", strconv.FormatInt(obj.Version, 10))
+	if !asOf.IsZero() {
+		q.Set("asOf", strconv.FormatInt(asOf.UnixNano(), 10))
+	}
+	if sort != nil {
+		q.Set("sortKey", sort.Key)
+		if sort.Descending {
+			q.Set("sortOrder", SortDescending)
+		}
+		q.Set("sortAfter", sort.StartAfter)
+	}
 
 	return base64.StdEncoding.EncodeToString([]byte(q.Encode()))
 }
 
-func parsePageToken(s string) (ObjectLocation, error) {
+func parsePageToken(s string) (startAfter ObjectLocation, asOf time.Time, err error) {
+	startAfter, asOf, _, err = parseSortedPageToken(s)
+	return startAfter, asOf, err
+}
+
+func parseSortedPageToken(s string) (startAfter ObjectLocation, asOf time.Time, sort *MetadataSort, err error) {
 	b, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
-		return ObjectLocation{}, fmt.Errorf("invalid page token: %w", ErrBadRequest)
+		return ObjectLocation{}, time.Time{}, nil, fmt.Errorf("invalid page token: %w", ErrBadRequest)
 	}
 
 	q, err := url.ParseQuery(string(b))
 	if err != nil {
-		return ObjectLocation{}, fmt.Errorf("invalid params in page token: %w", ErrBadRequest)
+		return ObjectLocation{}, time.Time{}, nil, fmt.Errorf("invalid params in page token: %w", ErrBadRequest)
 	}
 
 	projectID, err := uuid.FromString(q.Get("projectID"))
 	if err != nil {
-		return ObjectLocation{}, fmt.Errorf("invalid projectID in page token: %w", ErrBadRequest)
+		return ObjectLocation{}, time.Time{}, nil, fmt.Errorf("invalid projectID in page token: %w", ErrBadRequest)
 	}
 
 	bucketName := q.Get("bucketName")
 	if bucketName == "" {
-		return ObjectLocation{}, fmt.Errorf("invalid bucketName in page token: %w", ErrBadRequest)
+		return ObjectLocation{}, time.Time{}, nil, fmt.Errorf("invalid bucketName in page token: %w", ErrBadRequest)
 	}
 
 	objectKey := q.Get("objectKey")
 	if objectKey == "" {
-		return ObjectLocation{}, fmt.Errorf("invalid objectKey in page token: %w", ErrBadRequest)
+		return ObjectLocation{}, time.Time{}, nil, fmt.Errorf("invalid objectKey in page token: %w", ErrBadRequest)
 	}
 
 	version, err := strconv.ParseInt(q.Get("version"), 10, 64)
 	if err != nil {
-		return ObjectLocation{}, fmt.Errorf("invalid version in page token: %w", ErrBadRequest)
+		return ObjectLocation{}, time.Time{}, nil, fmt.Errorf("invalid version in page token: %w", ErrBadRequest)
+	}
+
+	if v := q.Get("asOf"); v != "" {
+		nanos, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || nanos <= 0 {
+			return ObjectLocation{}, time.Time{}, nil, fmt.Errorf("invalid asOf in page token: %w", ErrBadRequest)
+		}
+		asOf = time.Unix(0, nanos)
+	}
+
+	if q.Has("sortKey") {
+		sort = &MetadataSort{
+			Key:        q.Get("sortKey"),
+			Descending: q.Get("sortOrder") == SortDescending,
+			StartAfter: q.Get("sortAfter"),
+		}
+		if !json.Valid([]byte(sort.StartAfter)) {
+			return ObjectLocation{}, time.Time{}, nil, fmt.Errorf("invalid sortAfter in page token: %w", ErrBadRequest)
+		}
 	}
 
 	return ObjectLocation{
@@ -480,7 +1720,28 @@ func parsePageToken(s string) (ObjectLocation, error) {
 		BucketName: bucketName,
 		ObjectKey:  objectKey,
 		Version:    version,
-	}, nil
+	}, asOf, sort, nil
+}
+
+// checkSnapshot checks that the snapshot of a page token is within the
+// retention period. Snapshots are ignored if page tokens do not expire.
+func (s *Server) checkSnapshot(request *SearchRequest) error {
+	if request.asOf.IsZero() {
+		return nil
+	}
+	if s.Config.PageTokenRetention <= 0 {
+		request.asOf = time.Time{}
+		return nil
+	}
+
+	age := time.Since(request.asOf)
+	if age > s.Config.PageTokenRetention {
+		return fmt.Errorf("%w: restart the search from the first page", ErrPageTokenExpired)
+	}
+	if age < -maxSnapshotClockSkew {
+		return fmt.Errorf("invalid asOf in page token: %w", ErrBadRequest)
+	}
+	return nil
 }
 
 func jmespathError(msg string, err error) error {
@@ -10,7 +10,10 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -19,24 +22,72 @@ import (
 	"go.uber.org/zap"
 
 	"storj.io/common/uuid"
+	"storj.io/metasearch/signature"
 )
 
 // Mock repository
 
 type mockRepo struct {
-	objects map[string]ObjectInfo
+	objects    map[string]ObjectInfo
+	history    map[string][]MetadataRevision
+	revision   int64
+	watermarks map[string]int64
+	tombstones map[string]mockTombstone
+
+	vocabularies map[string]Vocabulary
+
+	// sizes are the plain sizes of the objects by path.
+	sizes map[string]int64
+
+	// indexUnavailable simulates searches without the metadata index,
+	// which scan a single object per page.
+	indexUnavailable bool
+
+	// paginate makes searches return objects in key order, after startAfter
+	// and up to batchSize. Otherwise all objects are returned.
+	paginate bool
+
+	// queryDelay is the duration of searches.
+	queryDelay time.Duration
+
+	// jobs are accessed by job runners in the background.
+	jobsMu     sync.Mutex
+	jobs       map[uuid.UUID]Job
+	jobResults map[uuid.UUID][][]byte
+
+	schedules map[uuid.UUID]Schedule
+
+	// leases are released by index rebuilds in the background.
+	leasesMu sync.Mutex
+	leases   map[string]lease
+}
+
+type mockTombstone struct {
+	obj       ObjectInfo
+	deletedAt time.Time
 }
 
 func newMockRepo() *mockRepo {
 	return &mockRepo{
-		objects: make(map[string]ObjectInfo),
+		objects:    make(map[string]ObjectInfo),
+		history:    make(map[string][]MetadataRevision),
+		watermarks: make(map[string]int64),
+		tombstones: make(map[string]mockTombstone),
+
+		vocabularies: make(map[string]Vocabulary),
+		sizes:        make(map[string]int64),
+
+		jobs:       make(map[uuid.UUID]Job),
+		jobResults: make(map[uuid.UUID][][]byte),
+		schedules:  make(map[uuid.UUID]Schedule),
+		leases:     make(map[string]lease),
 	}
 }
 
 func (r *mockRepo) GetMetadata(ctx context.Context, loc ObjectLocation) (ObjectInfo, error) {
 	path := fmt.Sprintf("sj://%s/%s", loc.BucketName, loc.ObjectKey)
 	obj, ok := r.objects[path]
-	if !ok {
+	if !ok || (loc.Version != 0 && loc.Version != obj.Version) {
 		return ObjectInfo{}, ErrNotFound
 	}
 
@@ -45,21 +96,297 @@ func (r *mockRepo) GetMetadata(ctx context.Context, loc ObjectLocation) (ObjectI
 
 func (r *mockRepo) UpdateMetadata(ctx context.Context, loc ObjectLocation, meta ObjectMetadata) error {
 	path := fmt.Sprintf("sj://%s/%s", loc.BucketName, loc.ObjectKey)
+	r.watermarks[loc.BucketName]++
+	r.revision++
+	r.history[path] = append(r.history[path], MetadataRevision{
+		Revision:    r.revision,
+		Version:     loc.Version,
+		Actor:       actorFromContext(ctx),
+		ChangedAt:   time.Now(),
+		Watermark:   r.watermarks[loc.BucketName],
+		OldMetadata: r.objects[path].Metadata.ClearMetadata,
+		NewMetadata: meta.ClearMetadata,
+	})
 	r.objects[path] = ObjectInfo{
 		ObjectLocation: loc,
 		Metadata:       meta,
+		UpdatedAt:      time.Now(),
 	}
 	return nil
 }
 
+func (r *mockRepo) UpdateMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}, meta ObjectMetadata) error {
+	obj, err := r.GetMetadata(ctx, loc)
+	if err != nil {
+		return err
+	}
+	if obj.MetaSearchQueuedAt != nil || metadataETag(obj.Metadata.ClearMetadata) != metadataETag(expected) {
+		return ErrPreconditionFailed
+	}
+	return r.UpdateMetadata(ctx, loc, meta)
+}
+
 func (r *mockRepo) DeleteMetadata(ctx context.Context, loc ObjectLocation) error {
 	path := fmt.Sprintf("sj://%s/%s", loc.BucketName, loc.ObjectKey)
 	delete(r.objects, path)
+	r.watermarks[loc.BucketName]++
+	return nil
+}
+
+func (r *mockRepo) SoftDeleteMetadata(ctx context.Context, loc ObjectLocation) error {
+	obj, err := r.GetMetadata(ctx, loc)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("sj://%s/%s", loc.BucketName, loc.ObjectKey)
+	if obj.Metadata.ClearMetadata != nil {
+		r.tombstones[path] = mockTombstone{obj: obj, deletedAt: time.Now()}
+	}
+	return r.UpdateMetadata(ctx, loc, ObjectMetadata{})
+}
+
+func (r *mockRepo) SoftDeleteMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}) error {
+	obj, err := r.GetMetadata(ctx, loc)
+	if err != nil {
+		return err
+	}
+	if metadataETag(obj.Metadata.ClearMetadata) != metadataETag(expected) {
+		return ErrPreconditionFailed
+	}
+	return r.SoftDeleteMetadata(ctx, loc)
+}
+
+func (r *mockRepo) RestoreMetadata(ctx context.Context, loc ObjectLocation, deletedAfter time.Time) error {
+	path := fmt.Sprintf("sj://%s/%s", loc.BucketName, loc.ObjectKey)
+	tombstone, ok := r.tombstones[path]
+	if !ok || !tombstone.deletedAt.After(deletedAfter) {
+		return ErrNotFound
+	}
+	delete(r.tombstones, path)
+	return r.UpdateMetadata(ctx, loc, tombstone.obj.Metadata)
+}
+
+func (r *mockRepo) PurgeTombstones(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	for path, tombstone := range r.tombstones {
+		if !tombstone.deletedAt.After(deletedBefore) {
+			delete(r.tombstones, path)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *mockRepo) DeleteExpiredMetadata(ctx context.Context, limit int) ([]ObjectLocation, error) {
+	var deleted []ObjectLocation
+	for _, obj := range r.objects {
+		if len(deleted) >= limit {
+			break
+		}
+		expiresAt := obj.Metadata.ExpiresAt
+		if expiresAt == nil || expiresAt.After(time.Now()) {
+			continue
+		}
+		err := r.UpdateMetadata(WithActor(ctx, expirationActor), obj.ObjectLocation, ObjectMetadata{})
+		if err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, obj.ObjectLocation)
+	}
+	return deleted, nil
+}
+
+func (r *mockRepo) GetWatermark(ctx context.Context, projectID uuid.UUID, bucket string) (Watermark, error) {
+	return Watermark{Watermark: r.watermarks[bucket]}, nil
+}
+
+func (r *mockRepo) GetVocabularies(ctx context.Context, projectID uuid.UUID) (map[string]Vocabulary, error) {
+	vocabularies := make(map[string]Vocabulary, len(r.vocabularies))
+	for key, vocabulary := range r.vocabularies {
+		vocabularies[key] = vocabulary
+	}
+	return vocabularies, nil
+}
+
+func (r *mockRepo) SetVocabulary(ctx context.Context, projectID uuid.UUID, key string, vocabulary Vocabulary) error {
+	r.vocabularies[key] = vocabulary
+	return nil
+}
+
+func (r *mockRepo) DeleteVocabulary(ctx context.Context, projectID uuid.UUID, key string) error {
+	if _, ok := r.vocabularies[key]; !ok {
+		return fmt.Errorf("%w: vocabulary not found", ErrNotFound)
+	}
+	delete(r.vocabularies, key)
+	return nil
+}
+
+func (r *mockRepo) CreateJob(ctx context.Context, job Job) error {
+	r.jobsMu.Lock()
+	defer r.jobsMu.Unlock()
+	r.jobs[job.ID] = job
+	return nil
+}
+
+func (r *mockRepo) GetJob(ctx context.Context, id uuid.UUID) (Job, error) {
+	r.jobsMu.Lock()
+	defer r.jobsMu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("%w: job not found", ErrNotFound)
+	}
+	return job, nil
+}
+
+func (r *mockRepo) ClaimJob(ctx context.Context, id uuid.UUID, now time.Time, leaseExpiresAt time.Time) (int64, error) {
+	r.jobsMu.Lock()
+	defer r.jobsMu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok || job.State != jobRunning || job.LeaseExpiresAt != nil && job.LeaseExpiresAt.After(now) {
+		return 0, fmt.Errorf("%w: job is not running or held by another server", ErrConflict)
+	}
+	job.Attempt++
+	job.LeaseExpiresAt = &leaseExpiresAt
+	job.UpdatedAt = now
+	r.jobs[id] = job
+	return job.Attempt, nil
+}
+
+func (r *mockRepo) SaveJobProgress(ctx context.Context, job Job, results []byte) error {
+	r.jobsMu.Lock()
+	defer r.jobsMu.Unlock()
+	current, ok := r.jobs[job.ID]
+	if !ok || current.Attempt != job.Attempt || current.State != jobRunning {
+		return fmt.Errorf("%w: job was deleted or claimed by another server", ErrNotFound)
+	}
+	if results != nil {
+		r.jobResults[job.ID] = append(r.jobResults[job.ID], results)
+	}
+	r.jobs[job.ID] = job
+	return nil
+}
+
+func (r *mockRepo) GetJobResults(ctx context.Context, id uuid.UUID, fromChunk int64, limit int) ([][]byte, error) {
+	r.jobsMu.Lock()
+	defer r.jobsMu.Unlock()
+	chunks := r.jobResults[id]
+	if fromChunk >= int64(len(chunks)) {
+		return nil, nil
+	}
+	chunks = chunks[fromChunk:]
+	if len(chunks) > limit {
+		chunks = chunks[:limit]
+	}
+	return chunks, nil
+}
+
+func (r *mockRepo) DeleteJob(ctx context.Context, id uuid.UUID) error {
+	r.jobsMu.Lock()
+	defer r.jobsMu.Unlock()
+	if _, ok := r.jobs[id]; !ok {
+		return fmt.Errorf("%w: job not found", ErrNotFound)
+	}
+	delete(r.jobs, id)
+	delete(r.jobResults, id)
+	return nil
+}
+
+func (r *mockRepo) DeleteExpiredJobs(ctx context.Context, now time.Time) (int64, error) {
+	r.jobsMu.Lock()
+	defer r.jobsMu.Unlock()
+	var deleted int64
+	for id, job := range r.jobs {
+		if !job.ExpiresAt.After(now) {
+			delete(r.jobs, id)
+			delete(r.jobResults, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *mockRepo) CreateSchedule(ctx context.Context, schedule Schedule) error {
+	r.schedules[schedule.ID] = schedule
+	return nil
+}
+
+func (r *mockRepo) GetSchedule(ctx context.Context, id uuid.UUID) (Schedule, error) {
+	schedule, ok := r.schedules[id]
+	if !ok {
+		return Schedule{}, fmt.Errorf("%w: schedule not found", ErrNotFound)
+	}
+	return schedule, nil
+}
+
+func (r *mockRepo) ListSchedules(ctx context.Context, projectID uuid.UUID, bucket string) ([]Schedule, error) {
+	var schedules []Schedule
+	for _, schedule := range r.schedules {
+		if schedule.ProjectID == projectID && schedule.BucketName == bucket {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules, nil
+}
+
+func (r *mockRepo) ListProjectSchedules(ctx context.Context, projectID uuid.UUID) ([]Schedule, error) {
+	var schedules []Schedule
+	for _, schedule := range r.schedules {
+		if schedule.ProjectID == projectID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		if schedules[i].BucketName != schedules[j].BucketName {
+			return schedules[i].BucketName < schedules[j].BucketName
+		}
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules, nil
+}
+
+func (r *mockRepo) DeleteSchedule(ctx context.Context, id uuid.UUID) error {
+	if _, ok := r.schedules[id]; !ok {
+		return fmt.Errorf("%w: schedule not found", ErrNotFound)
+	}
+	delete(r.schedules, id)
+	return nil
+}
+
+func (r *mockRepo) ClaimDueSchedules(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]Schedule, error) {
+	var schedules []Schedule
+	for id, schedule := range r.schedules {
+		if len(schedules) < limit && !schedule.NextRunAt.After(now) {
+			schedule.NextRunAt = leaseUntil
+			r.schedules[id] = schedule
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+func (r *mockRepo) SaveScheduleRun(ctx context.Context, schedule Schedule) error {
+	if _, ok := r.schedules[schedule.ID]; ok {
+		r.schedules[schedule.ID] = schedule
+	}
 	return nil
 }
 
-func (r *mockRepo) QueryMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, startAfter ObjectLocation, batchSize int) (QueryMetadataResult, error) {
-	results := QueryMetadataResult{}
+func (r *mockRepo) QueryMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, order *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int) (QueryMetadataResult, error) {
+	if r.queryDelay > 0 {
+		select {
+		case <-time.After(r.queryDelay):
+		case <-ctx.Done():
+			return QueryMetadataResult{}, fmt.Errorf("%w: %v", ErrInternalError, ctx.Err())
+		}
+	}
+
+	results := QueryMetadataResult{AsOf: asOf}
+	if asOf.IsZero() {
+		results.AsOf = time.Now()
+	}
 	path := fmt.Sprintf("sj://%s/%s", loc.BucketName, loc.ObjectKey)
 
 	// return all objects whose path starts with the `loc`
@@ -68,12 +395,252 @@ func (r *mockRepo) QueryMetadata(ctx context.Context, loc ObjectLocation, contai
 			continue
 		}
 
+		obj.Size = r.sizes[k]
 		results.Objects = append(results.Objects, obj)
+	}
+
+	if r.paginate {
+		// Objects are compared by their JSON encoded sort value, which
+		// orders plain strings like CockroachDB.
+		sortValue := func(obj ObjectInfo) string {
+			if order == nil {
+				return ""
+			}
+			value, _ := json.Marshal(obj.Metadata.ClearMetadata[order.Key])
+			return string(value)
+		}
+		less := func(value1, key1, value2, key2 string) bool {
+			if value1 != value2 {
+				return (value1 < value2) != order.Descending
+			}
+			return key1 < key2
+		}
+
+		sort.Slice(results.Objects, func(i, j int) bool {
+			obj1, obj2 := results.Objects[i], results.Objects[j]
+			return less(sortValue(obj1), obj1.ObjectKey, sortValue(obj2), obj2.ObjectKey)
+		})
+		var after string
+		if order != nil {
+			after = order.StartAfter
+		}
+		objects := results.Objects[:0]
+		for _, obj := range results.Objects {
+			if (startAfter.ObjectKey == "" || less(after, startAfter.ObjectKey, sortValue(obj), obj.ObjectKey)) && len(objects) < batchSize {
+				objects = append(objects, obj)
+			}
+		}
+		results.Objects = objects
+	}
 
+	if r.indexUnavailable && !featureEnabled(ctx, FeatureIndexFallback) {
+		return QueryMetadataResult{}, fmt.Errorf("%w: searches are unavailable while the metadata index is rebuilt", ErrServiceUnavailable)
+	}
+	if r.indexUnavailable && len(results.Objects) > 0 {
+		results.ScannedUntil = &results.Objects[0].ObjectLocation
+		results.Objects = nil
+		results.Warnings = []string{fallbackWarning}
 	}
 	return results, nil
 }
 
+func (r *mockRepo) QueryMetadataFunc(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, order *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int, fn ObjectFunc) (QueryMetadataResult, error) {
+	result, err := r.QueryMetadata(ctx, loc, containsQuery, order, startAfter, asOf, batchSize)
+	return forEachObject(result, err, fn)
+}
+
+func (r *mockRepo) CountMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}) (int64, error) {
+	results, err := r.QueryMetadata(ctx, loc, containsQuery, nil, ObjectLocation{}, time.Time{}, len(r.objects))
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(results.Objects)), nil
+}
+
+func (r *mockRepo) AggregateMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, aggregation MetadataAggregation) ([]AggregateGroup, error) {
+	results, err := r.QueryMetadata(ctx, loc, containsQuery, nil, ObjectLocation{}, time.Time{}, len(r.objects))
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []AggregateGroup
+	index := make(map[interface{}]int)
+	for _, obj := range results.Objects {
+		value := obj.Metadata.ClearMetadata[aggregation.GroupBy]
+		i, ok := index[value]
+		if !ok {
+			i = len(groups)
+			index[value] = i
+			groups = append(groups, AggregateGroup{Value: value})
+		}
+		group := &groups[i]
+		group.Count++
+
+		for _, key := range aggregation.Sum {
+			if n, ok := numberValue(obj.Metadata.ClearMetadata[key]); ok {
+				if group.Sum == nil {
+					group.Sum = make(map[string]float64)
+				}
+				f, _ := n.Float64()
+				group.Sum[key] += f
+			}
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Count > groups[j].Count
+	})
+	if len(groups) > aggregation.Limit {
+		groups = groups[:aggregation.Limit]
+	}
+	return groups, nil
+}
+
+func (r *mockRepo) RollupMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, groupBy []string, limit int) ([]RollupRow, error) {
+	results, err := r.QueryMetadata(ctx, loc, containsQuery, nil, ObjectLocation{}, time.Time{}, len(r.objects))
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []RollupRow
+	index := make(map[string]int)
+	for _, obj := range results.Objects {
+		values := make(map[string]interface{}, len(groupBy))
+		for _, key := range groupBy {
+			values[key] = obj.Metadata.ClearMetadata[key]
+		}
+		id, _ := json.Marshal(values)
+		i, ok := index[string(id)]
+		if !ok {
+			i = len(rows)
+			index[string(id)] = i
+			rows = append(rows, RollupRow{Values: values})
+		}
+		rows[i].Count++
+		rows[i].Size += r.sizes[fmt.Sprintf("sj://%s/%s", obj.BucketName, obj.ObjectKey)]
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Size > rows[j].Size
+	})
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (r *mockRepo) SampleMetadata(ctx context.Context, loc ObjectLocation, limit int) ([]map[string]interface{}, error) {
+	results, err := r.QueryMetadata(ctx, loc, nil, nil, ObjectLocation{}, time.Time{}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata []map[string]interface{}
+	for _, obj := range results.Objects {
+		if obj.Metadata.ClearMetadata != nil && len(metadata) < limit {
+			metadata = append(metadata, obj.Metadata.ClearMetadata)
+		}
+	}
+	return metadata, nil
+}
+
+func (r *mockRepo) TopMetadataValues(ctx context.Context, loc ObjectLocation, key string, prefix string, sample int, limit int) ([]ValueCount, error) {
+	bucket := fmt.Sprintf("sj://%s/", loc.BucketName)
+
+	counts := make(map[string]int64)
+	for path, obj := range r.objects {
+		if !strings.HasPrefix(path, bucket) {
+			continue
+		}
+		values, ok := obj.Metadata.ClearMetadata[key].([]interface{})
+		if !ok {
+			values = []interface{}{obj.Metadata.ClearMetadata[key]}
+		}
+		for _, value := range values {
+			if s, ok := value.(string); ok && strings.HasPrefix(s, prefix) {
+				counts[s]++
+			}
+		}
+	}
+
+	values := make([]ValueCount, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, ValueCount{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > limit {
+		values = values[:limit]
+	}
+	return values, nil
+}
+
+func (r *mockRepo) QueryProjectsMetadata(ctx context.Context, projectIDs []uuid.UUID, bucket string, containsQuery map[string]interface{}, limit int) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for _, obj := range r.objects {
+		if len(objects) >= limit {
+			break
+		}
+		if bucket != "" && obj.BucketName != bucket {
+			continue
+		}
+		for _, projectID := range projectIDs {
+			if obj.ProjectID == projectID {
+				objects = append(objects, obj)
+				break
+			}
+		}
+	}
+	return objects, nil
+}
+
+func (r *mockRepo) GetMetadataHistory(ctx context.Context, loc ObjectLocation, before int64, limit int) ([]MetadataRevision, error) {
+	path := fmt.Sprintf("sj://%s/%s", loc.BucketName, loc.ObjectKey)
+	history := r.history[path]
+
+	var revisions []MetadataRevision
+	for i := len(history) - 1; i >= 0 && len(revisions) < limit; i-- {
+		if before == 0 || history[i].Revision < before {
+			revisions = append(revisions, history[i])
+		}
+	}
+	return revisions, nil
+}
+
+func (r *mockRepo) GetChanges(ctx context.Context, projectID uuid.UUID, bucket string, afterWatermark int64, afterRevision int64, limit int) ([]MetadataChange, error) {
+	prefix := fmt.Sprintf("sj://%s/", bucket)
+
+	var changes []MetadataChange
+	for path, history := range r.history {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		for _, rev := range history {
+			if rev.Watermark > afterWatermark || (rev.Watermark == afterWatermark && rev.Revision > afterRevision) {
+				changes = append(changes, MetadataChange{
+					ObjectKey:        strings.TrimPrefix(path, prefix),
+					MetadataRevision: rev,
+				})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Watermark != changes[j].Watermark {
+			return changes[i].Watermark < changes[j].Watermark
+		}
+		return changes[i].Revision < changes[j].Revision
+	})
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
 func (r *mockRepo) MigrateMetadata(ctx context.Context, obj ObjectInfo) (err error) {
 	path := fmt.Sprintf("sj://%s/%s", obj.BucketName, obj.ObjectKey)
 
@@ -84,6 +651,7 @@ func (r *mockRepo) MigrateMetadata(ctx context.Context, obj ObjectInfo) (err err
 
 	obj.MetaSearchQueuedAt = nil
 	r.objects[path] = obj
+	r.watermarks[obj.BucketName]++
 	return nil
 }
 
@@ -96,6 +664,47 @@ func (r *mockRepo) GetObjectsForMigration(ctx context.Context, projectID uuid.UU
 	return nil
 }
 
+func (r *mockRepo) CountObjectsForMigration(ctx context.Context, projectID uuid.UUID, startTime *time.Time) (int64, error) {
+	var count int64
+	for _, obj := range r.objects {
+		if obj.MetaSearchQueuedAt != nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *mockRepo) RebuildIndex(ctx context.Context, name string, progress IndexRebuildProgressFunc) error {
+	if _, ok := metadataIndexes[name]; !ok {
+		return ErrNotFound
+	}
+	for _, phase := range []string{IndexRebuildCreating, IndexRebuildValidating, IndexRebuildSwapping} {
+		progress(phase, 0)
+		progress(phase, 1)
+	}
+	progress(IndexRebuildDone, 1)
+	return nil
+}
+
+func (r *mockRepo) AcquireLease(ctx context.Context, name string, holder uuid.UUID, now time.Time, expiresAt time.Time) error {
+	r.leasesMu.Lock()
+	defer r.leasesMu.Unlock()
+	if l, ok := r.leases[name]; ok && l.holder != holder && l.expiresAt.After(now) {
+		return fmt.Errorf("%w: lease '%s' is held by another server", ErrConflict, name)
+	}
+	r.leases[name] = lease{holder: holder, expiresAt: expiresAt}
+	return nil
+}
+
+func (r *mockRepo) ReleaseLease(ctx context.Context, name string, holder uuid.UUID) error {
+	r.leasesMu.Lock()
+	defer r.leasesMu.Unlock()
+	if l, ok := r.leases[name]; ok && l.holder == holder {
+		delete(r.leases, name)
+	}
+	return nil
+}
+
 func (r *mockRepo) updateFromUplink(bucket string, key string, encryptedMetadata string) error {
 	path := fmt.Sprintf("sj://%s/enc:%s", bucket, key)
 
@@ -158,7 +767,7 @@ func (e *mockEncryptor) EncryptMetadata(bucket string, path string, meta *Object
 
 func (e *mockEncryptor) DecryptMetadata(bucket string, path string, meta *ObjectMetadata) error {
 	var obj map[string]interface{}
-	err := json.Unmarshal(meta.EncryptedMetadata, &obj)
+	err := unmarshalJSON(meta.EncryptedMetadata, &obj)
 	meta.ClearMetadata = obj
 	return err
 }
@@ -178,15 +787,22 @@ func (e *mockEncryptor) Compare(other Encryptor) EncryptorComparisonResult {
 // Utility functions
 
 const testProjectID = "12345678-1234-5678-9999-1234567890ab"
+const testAdminToken = "admintoken"
 
 func testServer() *Server {
 	repo := newMockRepo()
 	auth := &mockAuthenticator{}
 	logger, _ := zap.NewDevelopment()
-	server, _ := NewServer(logger, repo, auth, "")
+	server, _ := NewServer(logger, repo, auth, ServerConfig{
+		AdminToken: testAdminToken,
+	})
 	return server
 }
 
+func testRepo(server *Server) *mockRepo {
+	return server.Repo.(*mockRepo)
+}
+
 func testRequest(method, path, body string) *http.Request {
 	var r *http.Request
 	url := "http://localhost" + path
@@ -226,135 +842,1730 @@ func TestPageToken(t *testing.T) {
 		BucketName: "testbucket",
 		ObjectKey:  "foo.txt",
 	}
-	generatedToken := getPageToken(startAfter)
-	parsedToken, err := parsePageToken(generatedToken)
+	generatedToken := getPageToken(startAfter, time.Time{})
+	parsedToken, asOf, err := parsePageToken(generatedToken)
 	assert.Nil(t, err)
 	assert.Equal(t, parsedToken, startAfter)
-}
+	assert.True(t, asOf.IsZero())
 
-// Test server
+	// Page token with snapshot
+	snapshot := time.Now()
+	generatedToken = getPageToken(startAfter, snapshot)
+	parsedToken, asOf, err = parsePageToken(generatedToken)
+	assert.Nil(t, err)
+	assert.Equal(t, parsedToken, startAfter)
+	assert.True(t, asOf.Equal(snapshot))
+}
 
-func TestMetaSearchCRUD(t *testing.T) {
+func TestSearchIndexUnavailable(t *testing.T) {
 	server := testServer()
+	testRepo(server).indexUnavailable = true
 
-	// Insert metadata
-	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{
-		"foo": "456",
-		"n": 2,
-		"tags": [
-			"tag1",
-			"tag3"
-		]
-	}`)
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
 	assert.Equal(t, rr.Code, http.StatusNoContent)
 
-	// Get metadata
-	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
-	assertResponse(t, rr, http.StatusOK, `{
-		"foo": "456",
-		"n": 2,
-		"tags": [
-			"tag1",
-			"tag3"
-		]
-	}`)
+	// A page without results continues after the scanned objects
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"foo": "123"}}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
 
-	// Delete metadata
-	rr = handleRequest(server, http.MethodDelete, "/metadata/testbucket/foo.txt", "")
-	assert.Equal(t, rr.Code, http.StatusNoContent)
+	var resp SearchResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Empty(t, resp.Results)
+	require.Equal(t, []string{fallbackWarning}, resp.Warnings)
 
-	// Get metadata again
-	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
-	assertResponse(t, rr, http.StatusNotFound, `{
-		"error": "not found"
-	}`)
+	startAfter, _, err := parsePageToken(resp.PageToken)
+	require.NoError(t, err)
+	require.Equal(t, "enc:foo.txt", startAfter.ObjectKey)
 }
 
-func TestMetaSearchQuery(t *testing.T) {
+func TestSearchKeyPattern(t *testing.T) {
 	server := testServer()
+	testRepo(server).paginate = true
 
-	// Insert metadata
-	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{
-		"foo": "456",
-		"n": 1
-	}`)
+	for _, key := range []string{"a.txt", "b.pdf", "c.txt", "d.txt", "e.pdf", "f.txt", "g.pdf"} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, `{"foo": "bar"}`)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	// Pages are refilled from the following batches
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"keyPattern": "*.pdf", "batchSize": 2}`)
+	assertResponse(t, rr, http.StatusOK, "")
+
+	var resp SearchResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Results, 2)
+	require.Equal(t, "sj://testbucket/b.pdf", resp.Results[0].Path)
+	require.Equal(t, "sj://testbucket/e.pdf", resp.Results[1].Path)
+	require.NotEmpty(t, resp.PageToken)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"keyPattern": "*.pdf", "batchSize": 2, "pageToken": "`+resp.PageToken+`"}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [{"path": "sj://testbucket/g.pdf", "metadata": {"foo": "bar"}}]}`)
+
+	// Regular expressions
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?keyRegex=%5E%5Bab%5D", "")
+	assertResponse(t, rr, http.StatusOK, `{"results": [
+		{"path": "sj://testbucket/a.txt", "metadata": {"foo": "bar"}},
+		{"path": "sj://testbucket/b.pdf", "metadata": {"foo": "bar"}}
+	]}`)
+
+	// Invalid patterns
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"keyPattern": "["}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"keyRegex": "("}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"keyPattern": "*.pdf", "decryptPaths": false}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestSearchDelimiter(t *testing.T) {
+	server := testServer()
+	testRepo(server).paginate = true
+
+	for _, key := range []string{"a.txt", "photos/2024/a.jpg", "photos/2024/b.jpg", "photos/2025/c.jpg", "photos/d.jpg", "videos/e.mp4", "z.txt"} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, `{"foo": "bar"}`)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	// Subdirectories are grouped once, even across batches
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"delimiter": "/", "batchSize": 3}`)
+	assertResponse(t, rr, http.StatusOK, "")
+
+	var resp SearchResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Results, 1)
+	require.Equal(t, "sj://testbucket/a.txt", resp.Results[0].Path)
+	require.Equal(t, []string{"sj://testbucket/photos/", "sj://testbucket/videos/"}, resp.CommonPrefixes)
+	require.NotEmpty(t, resp.PageToken)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"delimiter": "/", "batchSize": 3, "pageToken": "`+resp.PageToken+`"}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [{"path": "sj://testbucket/z.txt", "metadata": {"foo": "bar"}}]}`)
+
+	// Subdirectories of the key prefix
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?prefix=photos&delimiter=%2F", "")
+	assertResponse(t, rr, http.StatusOK, `{
+		"results": [{"path": "sj://testbucket/photos/d.jpg", "metadata": {"foo": "bar"}}],
+		"commonPrefixes": ["sj://testbucket/photos/2024/", "sj://testbucket/photos/2025/"]
+	}`)
+
+	// Invalid delimiters
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"delimiter": "-"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"delimiter": "/", "decryptPaths": false}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestSearchSort(t *testing.T) {
+	server := testServer()
+	testRepo(server).paginate = true
+
+	for key, capturedAt := range map[string]string{"a.jpg": "2024-03", "b.jpg": "2024-01", "c.jpg": "2024-02", "d.jpg": "2024-01"} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, `{"capturedAt": "`+capturedAt+`"}`)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	// Objects with the same value are ordered by key
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"sort": {"key": "capturedAt", "order": "desc"}, "batchSize": 2}`)
+	assertResponse(t, rr, http.StatusOK, "")
+
+	var resp SearchResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Results, 2)
+	require.Equal(t, "sj://testbucket/a.jpg", resp.Results[0].Path)
+	require.Equal(t, "sj://testbucket/c.jpg", resp.Results[1].Path)
+	require.NotEmpty(t, resp.PageToken)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"sort": {"key": "capturedAt", "order": "desc"}, "batchSize": 2, "pageToken": "`+resp.PageToken+`"}`)
+	assertResponse(t, rr, http.StatusOK, "")
+
+	resp = SearchResponse{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Results, 2)
+	require.Equal(t, "sj://testbucket/b.jpg", resp.Results[0].Path)
+	require.Equal(t, "sj://testbucket/d.jpg", resp.Results[1].Path)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?sort=capturedAt", "")
+	assertResponse(t, rr, http.StatusOK, `{"results": [
+		{"path": "sj://testbucket/b.jpg", "metadata": {"capturedAt": "2024-01"}},
+		{"path": "sj://testbucket/d.jpg", "metadata": {"capturedAt": "2024-01"}},
+		{"path": "sj://testbucket/c.jpg", "metadata": {"capturedAt": "2024-02"}},
+		{"path": "sj://testbucket/a.jpg", "metadata": {"capturedAt": "2024-03"}}
+	]}`)
+
+	// The page token is bound to the sort order
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"sort": {"key": "capturedAt"}, "pageToken": "`+resp.PageToken+`"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"pageToken": "`+resp.PageToken+`"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Invalid sort orders
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"sort": {"key": "capturedAt", "order": "up"}}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"sort": {"order": "desc"}}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestSearchCountOnly(t *testing.T) {
+	server := testServer()
+
+	for _, key := range []string{"a.txt", "b.txt", "dir/c.txt"} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, `{"foo": "bar"}`)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"foo": "bar"}, "countOnly": true}`)
+	assertResponse(t, rr, http.StatusOK, `{"count": 3}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?prefix=dir&countOnly=true", "")
+	assertResponse(t, rr, http.StatusOK, `{"count": 1}`)
+
+	// Counts and results have different entity tags
+	etag := rr.Header().Get("ETag")
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?prefix=dir", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	require.NotEqual(t, etag, rr.Header().Get("ETag"))
+
+	// Options that require fetching the objects are rejected
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"filter": "foo == 'bar'", "countOnly": true}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"keyPattern": "*.txt", "countOnly": true}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?countOnly=maybe", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestSearchAggregate(t *testing.T) {
+	server := testServer()
+
+	for key, metadata := range map[string]string{
+		"a.txt": `{"department": "sales", "size": 1}`,
+		"b.txt": `{"department": "sales", "size": 2}`,
+		"c.txt": `{"department": "sales", "size": "large"}`,
+		"d.txt": `{"department": "engineering", "size": 5}`,
+		"e.txt": `{"department": "engineering"}`,
+		"f.txt": `{"size": 10}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket/aggregate", `{"groupBy": "department", "sum": ["size"]}`)
+	assertResponse(t, rr, http.StatusOK, `{"groups": [
+		{"value": "sales", "count": 3, "sum": {"size": 3}},
+		{"value": "engineering", "count": 2, "sum": {"size": 5}},
+		{"value": null, "count": 1, "sum": {"size": 10}}
+	]}`)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/aggregate", `{"groupBy": "department", "limit": 1}`)
+	assertResponse(t, rr, http.StatusOK, `{"groups": [{"value": "sales", "count": 3}], "truncated": true}`)
+
+	// Options that require fetching the objects are rejected
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/aggregate", `{"groupBy": "department", "filter": "size > `+"`1`"+`"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/aggregate", `{"sum": [""]}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestSearchSystemMetadata(t *testing.T) {
+	server := testServer()
+	repo := testRepo(server)
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
 	assert.Equal(t, rr.Code, http.StatusNoContent)
 
-	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/subdir/bar.txt", `{
-		"foo": "456",
-		"n": 2
+	obj := repo.objects["sj://testbucket/enc:foo.txt"]
+	obj.Status = statusCommittedUnversioned
+	obj.CreatedAt = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	repo.objects["sj://testbucket/enc:foo.txt"] = obj
+	repo.sizes["sj://testbucket/enc:foo.txt"] = 1234
+
+	// System metadata is omitted by default
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{}`)
+	assertResponse(t, rr, http.StatusOK, `{
+		"results": [{"path": "sj://testbucket/foo.txt", "metadata": {"foo": "bar"}}]
+	}`)
+
+	expected := `{
+		"results": [{
+			"path": "sj://testbucket/foo.txt",
+			"metadata": {"foo": "bar"},
+			"system": {"size": 1234, "createdAt": "2025-01-02T03:04:05Z", "status": "committedUnversioned"}
+		}]
+	}`
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"includeSystemMetadata": true}`)
+	assertResponse(t, rr, http.StatusOK, expected)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?includeSystemMetadata=true", "")
+	assertResponse(t, rr, http.StatusOK, expected)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?includeSystemMetadata=maybe", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestSearchHighlight(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.jpg", `{"camera": {"model": "X100", "iso": 400}, "year": 2024, "tags": ["a", "b"], "title": "Beach"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Values of the match query and the keys of the filter
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"match": {
+			"camera": {"model": "X100", "iso": {"$gte": 200}},
+			"tags": ["a"],
+			"$anyOf": [{"year": 2023}, {"title": {"$glob": "Be*"}}]
+		},
+		"filter": "year",
+		"projection": "title",
+		"highlight": true
+	}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [{
+		"path": "sj://testbucket/foo.jpg",
+		"metadata": "Beach",
+		"highlights": {"camera.model": "X100", "camera.iso": 400, "tags": ["a", "b"], "title": "Beach", "year": 2024}
+	}]}`)
+
+	// Case-insensitive matches highlight the original keys and values
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"TITLE": "beach"}, "caseInsensitive": true, "projection": "year", "highlight": true}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [{"path": "sj://testbucket/foo.jpg", "metadata": 2024, "highlights": {"title": "Beach"}}]}`)
+
+	// Highlights are omitted by default
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"year": 2024}, "projection": "year"}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [{"path": "sj://testbucket/foo.jpg", "metadata": 2024}]}`)
+}
+
+func TestNumericPrecision(t *testing.T) {
+	server := testServer()
+	testRepo(server).paginate = true
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/a.txt", `{"id": 12345678901234567891, "price": 19.99}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/b.txt", `{"id": 12345678901234567890, "price": 19.990}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Numbers are returned as they were stored
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/a.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	require.Contains(t, rr.Body.String(), "12345678901234567891")
+
+	// Numbers that differ beyond the float64 precision are matched exactly.
+	// The mock repository does not evaluate queries, so check the highlights.
+	for _, body := range []string{
+		`{"match": {"id": 12345678901234567891}, "highlight": true}`,
+		`{"match": {"id": {"$gt": 12345678901234567890}}, "highlight": true}`,
+		`{"query": "id >= 12345678901234567891", "highlight": true}`,
+	} {
+		rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", body)
+		assert.Equal(t, rr.Code, http.StatusOK)
+
+		var resp SearchResponse
+		require.NoError(t, newJSONDecoder(rr.Body).Decode(&resp))
+		highlights := make(map[string]interface{})
+		for _, result := range resp.Results {
+			highlights[result.Path] = result.Highlights["id"]
+		}
+		require.Equal(t, map[string]interface{}{
+			"sj://testbucket/a.txt": json.Number("12345678901234567891"),
+			"sj://testbucket/b.txt": nil,
+		}, highlights, body)
+	}
+
+	// Decimals are compared by value
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match": {"price": 19.99}, "highlight": true, "projection": "price"}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [
+		{"path": "sj://testbucket/a.txt", "metadata": 19.99, "highlights": {"price": 19.99}},
+		{"path": "sj://testbucket/b.txt", "metadata": 19.99, "highlights": {"price": 19.990}}
+	]}`)
+}
+
+func TestSearchRollup(t *testing.T) {
+	server := testServer()
+	repo := testRepo(server)
+
+	for key, metadata := range map[string]string{
+		"a.bin": `{"project": "alpha", "dataset": "raw"}`,
+		"b.bin": `{"project": "alpha", "dataset": "raw"}`,
+		"c.bin": `{"project": "alpha", "dataset": "clean"}`,
+		"d.bin": `{"project": "beta"}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+	repo.sizes["sj://testbucket/enc:a.bin"] = 100
+	repo.sizes["sj://testbucket/enc:b.bin"] = 200
+	repo.sizes["sj://testbucket/enc:c.bin"] = 50
+	repo.sizes["sj://testbucket/enc:d.bin"] = 1000
+
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket/rollup", `{"groupBy": ["project", "dataset"]}`)
+	assertResponse(t, rr, http.StatusOK, `{"rows": [
+		{"values": {"project": "beta", "dataset": null}, "count": 1, "size": 1000},
+		{"values": {"project": "alpha", "dataset": "raw"}, "count": 2, "size": 300},
+		{"values": {"project": "alpha", "dataset": "clean"}, "count": 1, "size": 50}
+	]}`)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/rollup", `{"groupBy": ["project"], "limit": 1}`)
+	assertResponse(t, rr, http.StatusOK, `{"rows": [{"values": {"project": "beta"}, "count": 1, "size": 1000}], "truncated": true}`)
+
+	for _, body := range []string{`{}`, `{"groupBy": [""]}`, `{"groupBy": ["project", "project"]}`, `{"groupBy": ["project"], "keyRegex": "a"}`} {
+		rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/rollup", body)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestSearchDistinct(t *testing.T) {
+	server := testServer()
+
+	for key, metadata := range map[string]string{
+		"photos/a.jpg": `{"color": "red"}`,
+		"photos/b.jpg": `{"color": "red"}`,
+		"photos/c.jpg": `{"color": "blue"}`,
+		"other.txt":    `{"color": "green"}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket/distinct?key=color&prefix=photos/", "")
+	assertResponse(t, rr, http.StatusOK, `{"values": ["red", "blue"]}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/distinct?key=color&prefix=photos/&limit=1", "")
+	assertResponse(t, rr, http.StatusOK, `{"values": ["red"], "truncated": true}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/distinct", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/distinct?key=color&limit=0", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestSearchSuggest(t *testing.T) {
+	server := testServer()
+
+	for key, metadata := range map[string]string{
+		"a.jpg": `{"tag": ["sunset", "beach"]}`,
+		"b.jpg": `{"tag": ["sunset", "sunflower"]}`,
+		"c.jpg": `{"tag": "sunrise"}`,
+		"d.jpg": `{"tag": 1}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket/suggest?key=tag&prefix=sun", "")
+	assertResponse(t, rr, http.StatusOK, `{"suggestions": [
+		{"value": "sunset", "count": 2},
+		{"value": "sunflower", "count": 1},
+		{"value": "sunrise", "count": 1}
+	]}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/suggest?key=tag&limit=1", "")
+	assertResponse(t, rr, http.StatusOK, `{"suggestions": [{"value": "sunset", "count": 2}]}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/suggest?key=other", "")
+	assertResponse(t, rr, http.StatusOK, `{"suggestions": []}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/suggest?prefix=sun", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestSearchTopValues(t *testing.T) {
+	server := testServer()
+
+	for key, metadata := range map[string]string{
+		"a.jpg": `{"tag": ["cat", "dog"]}`,
+		"b.jpg": `{"tag": ["cat", "bird"]}`,
+		"c.jpg": `{"tag": "cat"}`,
+		"d.jpg": `{"tag": "dog"}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket/top?key=tag", "")
+	assertResponse(t, rr, http.StatusOK, `{"values": [
+		{"value": "cat", "count": 3},
+		{"value": "dog", "count": 2},
+		{"value": "bird", "count": 1}
+	]}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/top?key=tag&limit=2", "")
+	assertResponse(t, rr, http.StatusOK, `{"values": [{"value": "cat", "count": 3}, {"value": "dog", "count": 2}]}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/top?key=tag&limit=x", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestSearchKeys(t *testing.T) {
+	server := testServer()
+
+	for key, metadata := range map[string]string{
+		"photos/a.jpg": `{"camera": "x100", "iso": 200}`,
+		"photos/b.jpg": `{"camera": "x100", "iso": 400}`,
+		"photos/c.jpg": `{"camera": "gr3", "city": "Berlin"}`,
+		"docs/d.txt":   `{"author": "alice"}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket/keys?prefix=photos/", "")
+	assertResponse(t, rr, http.StatusOK, `{"keys": [
+		{"key": "camera", "count": 3},
+		{"key": "iso", "count": 2},
+		{"key": "city", "count": 1}
+	]}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/keys?startsWith=ci&samples=2", "")
+	assertResponse(t, rr, http.StatusOK, `{"keys": [{"key": "city", "count": 1, "samples": ["Berlin"]}]}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/keys?limit=1", "")
+	assertResponse(t, rr, http.StatusOK, `{"keys": [{"key": "camera", "count": 3}], "truncated": true}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/keys?samples=11", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestSearchLint(t *testing.T) {
+	server := testServer()
+	testRepo(server).vocabularies["type"] = Vocabulary{Values: []string{"photo", "video"}}
+
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket/lint", `{"match": {"type": "photo"}}`)
+	assertResponse(t, rr, http.StatusOK, `{"warnings": [], "sampled": 0}`)
+
+	for key, metadata := range map[string]string{
+		"a.jpg": `{"type": "photo", "size": 1, "camera": {"model": "x100"}, "tags": ["a"]}`,
+		"b.mp4": `{"type": "video", "size": 2}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/lint", `{
+		"match": {"type": "photo", "size": {"$gt": 1}, "camera": {"model": "x100"}, "tags": ["a"]},
+		"caseInsensitive": true,
+		"exists": ["camera"]
+	}`)
+	assertResponse(t, rr, http.StatusOK, `{"warnings": [], "sampled": 2}`)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/lint", `{
+		"match": {"typ": "photo", "size": "1", "camera": {"modle": "x100"}},
+		"anyOf": [{"type": "audio"}],
+		"missing": ["reviewer"],
+		"sort": {"key": "tpye"}
+	}`)
+	assertResponse(t, rr, http.StatusOK, `{"warnings": [
+		{"path": "camera.modle", "message": "key not found in the metadata of the bucket", "suggestion": "camera.model"},
+		{"path": "size", "message": "query value is a string, but the metadata values are number"},
+		{"path": "typ", "message": "key not found in the metadata of the bucket", "suggestion": "type"},
+		{"path": "reviewer", "message": "key not found in the metadata of the bucket, missing matches all objects"},
+		{"path": "type", "message": "value 'audio' is not in the vocabulary of the key"},
+		{"path": "tpye", "message": "key not found in the metadata of the bucket", "suggestion": "type"}
+	], "sampled": 2}`)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket/lint", `{"match": {"size": {"$foo": 1}}}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestSearchPartialResults(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	testRepo(server).queryDelay = time.Second
+
+	// The search fails when the deadline is reached
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"timeout": "50ms"}`)
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+
+	// With partial results, the results found so far are returned
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"timeout": "50ms", "partialResults": true}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [], "truncated": true}`)
+	assert.Equal(t, rr.Header().Get("ETag"), "")
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?timeout=50ms&partialResults=true", "")
+	assertResponse(t, rr, http.StatusOK, `{"results": [], "truncated": true}`)
+
+	// Invalid timeouts
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"timeout": "foo"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"timeout": "-1s"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestPageTokenRetention(t *testing.T) {
+	server := testServer()
+	server.Config.PageTokenRetention = time.Hour
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/bar.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Page tokens contain the snapshot of the first page
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"batchSize": 1}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var resp SearchResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	_, asOf, err := parsePageToken(resp.PageToken)
+	require.NoError(t, err)
+	require.False(t, asOf.IsZero())
+
+	// Page tokens are valid within the retention period, also on other
+	// server instances
+	other := testServer()
+	other.Config.PageTokenRetention = time.Hour
+	rr = handleRequest(other, http.MethodPost, "/metasearch/testbucket", `{"pageToken": "`+resp.PageToken+`"}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// Expired page tokens are rejected
+	projectID, _ := uuid.FromString(testProjectID)
+	expired := getPageToken(ObjectLocation{
+		ProjectID:  projectID,
+		BucketName: "testbucket",
+		ObjectKey:  "bar.txt",
+	}, time.Now().Add(-2*time.Hour))
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"pageToken": "`+expired+`"}`)
+	assert.Equal(t, rr.Code, http.StatusGone)
+
+	// Snapshots are ignored if page tokens do not expire
+	server.Config.PageTokenRetention = 0
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"pageToken": "`+expired+`"}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+}
+
+func TestSearchStaleness(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/bar.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Stale searches read an older snapshot
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"batchSize": 1, "staleness": "10s"}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var resp SearchResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	_, asOf, err := parsePageToken(resp.PageToken)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(-10*time.Second), asOf, 5*time.Second)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?staleness=10s", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// Stale searches have no entity tags, and ignore If-None-Match
+	require.Empty(t, rr.Header().Get("ETag"))
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", "{}")
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	r := testRequest(http.MethodPost, "/metasearch/testbucket", `{"staleness": "10s"}`)
+	r.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// Invalid staleness
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"staleness": "foo"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"staleness": "-1s"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"staleness": "1h"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+// Test server
+
+func TestMetaSearchCRUD(t *testing.T) {
+	server := testServer()
+
+	// Insert metadata
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{
+		"foo": "456",
+		"n": 2,
+		"tags": [
+			"tag1",
+			"tag3"
+		]
+	}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Get metadata
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{
+		"foo": "456",
+		"n": 2,
+		"tags": [
+			"tag1",
+			"tag3"
+		]
+	}`)
+
+	// Get metadata of a missing version
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt?versionId=5", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	// Get metadata with invalid version
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt?versionId=foo", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Delete metadata
+	rr = handleRequest(server, http.MethodDelete, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Get metadata again
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusNotFound, `{
+		"error": "not found"
+	}`)
+}
+
+func TestMetaSearchSoftDelete(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Restore is disabled without soft delete
+	rr = handleRequest(server, http.MethodPost, "/restore/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	server.Config.SoftDeleteRetention = time.Hour
+
+	rr = handleRequest(server, http.MethodDelete, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `null`)
+
+	rr = handleRequest(server, http.MethodPost, "/restore/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "456"}`)
+
+	// Deleted metadata can only be restored once
+	rr = handleRequest(server, http.MethodPost, "/restore/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	// Deleting twice keeps the deleted metadata
+	for i := 0; i < 2; i++ {
+		rr = handleRequest(server, http.MethodDelete, "/metadata/testbucket/foo.txt", "")
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+	rr = handleRequest(server, http.MethodPost, "/restore/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Conditional deletes check the current metadata
+	r := testRequest(http.MethodDelete, "/metadata/testbucket/foo.txt", "")
+	r.Header.Set("If-Match", metadataETag(map[string]interface{}{"foo": "789"}))
+	rr = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusPreconditionFailed)
+
+	r = testRequest(http.MethodDelete, "/metadata/testbucket/foo.txt", "")
+	r.Header.Set("If-Match", metadataETag(map[string]interface{}{"foo": "456"}))
+	rr = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPost, "/restore/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Expired deleted metadata cannot be restored
+	rr = handleRequest(server, http.MethodDelete, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	purged, err := server.Repo.PurgeTombstones(context.Background(), time.Now())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), purged)
+
+	rr = handleRequest(server, http.MethodPost, "/restore/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
+
+func TestMetaSearchMetadataExpiration(t *testing.T) {
+	server := testServer()
+	repo := testRepo(server)
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt?ttl=1h", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "456"}`)
+	require.NotEmpty(t, rr.Header().Get("Metadata-Expires"))
+
+	// Invalid expiration times are rejected
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt?ttl=-1h", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt?expiresAt=2020-01-01T00:00:00Z", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt?ttl=1h&expiresAt=2999-01-01T00:00:00Z", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Metadata is not deleted before it expires
+	deleted, err := server.Repo.DeleteExpiredMetadata(context.Background(), 10)
+	require.NoError(t, err)
+	require.Empty(t, deleted)
+
+	obj := repo.objects["sj://testbucket/enc:foo.txt"]
+	past := time.Now().Add(-time.Minute)
+	obj.Metadata.ExpiresAt = &past
+	repo.objects["sj://testbucket/enc:foo.txt"] = obj
+
+	deleted, err = server.Repo.DeleteExpiredMetadata(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `null`)
+	require.Empty(t, rr.Header().Get("Metadata-Expires"))
+
+	revisions, err := repo.GetMetadataHistory(context.Background(), obj.ObjectLocation, 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, expirationActor, revisions[0].Actor)
+}
+
+func TestMetaSearchUpdateEncrypted(t *testing.T) {
+	server := testServer()
+	repo := testRepo(server)
+
+	// Metadata is stored without server-side encryption
+	rr := handleRequest(server, http.MethodPut, "/encrypted-metadata/testbucket/foo.txt", `{
+		"encryptedMetadataNonce": "bm9uY2U=",
+		"encryptedMetadata": "ZW5jcnlwdGVk",
+		"encryptedMetadataKey": "a2V5",
+		"clearMetadata": {"foo": "456"}
+	}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	obj := repo.objects["sj://testbucket/enc:foo.txt"]
+	assert.Equal(t, string(obj.Metadata.EncryptedMetadata), "encrypted")
+	assert.Equal(t, string(obj.Metadata.EncryptedMetadataKey), "key")
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "456"}`)
+
+	// Encrypted fields must be set together
+	rr = handleRequest(server, http.MethodPut, "/encrypted-metadata/testbucket/foo.txt", `{
+		"encryptedMetadata": "ZW5jcnlwdGVk",
+		"clearMetadata": {"foo": "456"}
+	}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestMetaSearchHead(t *testing.T) {
+	server := testServer()
+
+	// Head of missing object
+	rr := handleRequest(server, http.MethodHead, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	// Insert metadata
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Head returns size and last modified time without body
+	rr = handleRequest(server, http.MethodHead, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Length"), "13")
+	assert.NotEqual(t, rr.Header().Get("Last-Modified"), "")
+	assert.NotEqual(t, rr.Header().Get("ETag"), "")
+	assert.Equal(t, rr.Body.Len(), 0)
+}
+
+func TestMetaSearchGetFields(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"a": 1, "b": {"c": 2, "d": 3}, "e": [4]}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Selected fields, missing fields are omitted
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt?fields=a,b.c,b.x,y.z", "")
+	assertResponse(t, rr, http.StatusOK, `{"a": 1, "b": {"c": 2}}`)
+
+	// JMESPath projection
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt?projection=b.d", "")
+	assertResponse(t, rr, http.StatusOK, `3`)
+
+	// Head returns the size of the selected fields
+	rr = handleRequest(server, http.MethodHead, "/metadata/testbucket/foo.txt?fields=a", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Length"), "7")
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt?fields=a&projection=b", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt?projection=%5B", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestMetaSearchIfMatch(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	putETag := rr.Header().Get("ETag")
+
+	// GET returns the same ETag as the update
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	etag := rr.Header().Get("ETag")
+	assert.NotEqual(t, etag, "")
+	assert.Equal(t, etag, putETag)
+
+	conditionalRequest := func(method, body, ifMatch string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(method, "/metadata/testbucket/foo.txt", body)
+		r.Header.Set("If-Match", ifMatch)
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	// Update with matching ETag
+	rr = conditionalRequest(http.MethodPut, `{"foo": "789"}`, etag)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	newETag := rr.Header().Get("ETag")
+	assert.NotEqual(t, newETag, etag)
+
+	// Update with stale ETag
+	rr = conditionalRequest(http.MethodPut, `{"foo": "000"}`, etag)
+	assert.Equal(t, rr.Code, http.StatusPreconditionFailed)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "789"}`)
+
+	// Delete with stale ETag
+	rr = conditionalRequest(http.MethodDelete, "", etag)
+	assert.Equal(t, rr.Code, http.StatusPreconditionFailed)
+
+	// Delete with matching ETag
+	rr = conditionalRequest(http.MethodDelete, "", newETag)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// If-Match can be required
+	server.Config.RequireIfMatch = true
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusPreconditionRequired)
+
+	rr = conditionalRequest(http.MethodPut, `{"foo": "456"}`, "*")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+}
+
+func TestMetaSearchHistory(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "789"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodGet, "/history/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	var resp HistoryResponse
+	err := json.NewDecoder(rr.Body).Decode(&resp)
+	require.NoError(t, err)
+	require.Len(t, resp.Revisions, 2)
+	require.Equal(t, map[string]interface{}{"foo": "456"}, resp.Revisions[0].OldMetadata)
+	require.Equal(t, map[string]interface{}{"foo": "789"}, resp.Revisions[0].NewMetadata)
+	require.Nil(t, resp.Revisions[1].OldMetadata)
+
+	// The actor is the fingerprint of the access grant
+	require.Equal(t, grantFingerprint(testRequest(http.MethodGet, "/", "")), resp.Revisions[0].Actor)
+
+	// Paging
+	rr = handleRequest(server, http.MethodGet, "/history/testbucket/foo.txt?limit=1", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	require.NoError(t, err)
+	require.Len(t, resp.Revisions, 1)
+	require.NotEqual(t, "", resp.PageToken)
+
+	rr = handleRequest(server, http.MethodGet, "/history/testbucket/foo.txt?limit=1&pageToken="+resp.PageToken, "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	resp = HistoryResponse{}
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	require.NoError(t, err)
+	require.Len(t, resp.Revisions, 1)
+	require.Equal(t, map[string]interface{}{"foo": "456"}, resp.Revisions[0].NewMetadata)
+}
+
+func TestMetaSearchRollback(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "789"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodGet, "/history/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var resp HistoryResponse
+	err := json.NewDecoder(rr.Body).Decode(&resp)
+	require.NoError(t, err)
+	require.Len(t, resp.Revisions, 2)
+	first := resp.Revisions[1].Revision
+
+	// Restore the first revision
+	rr = handleRequest(server, http.MethodPost, "/history/testbucket/foo.txt", fmt.Sprintf(`{"revision": "%d"}`, first))
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "456"}`)
+
+	// The rollback is recorded as a new revision
+	rr = handleRequest(server, http.MethodGet, "/history/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	require.NoError(t, err)
+	require.Len(t, resp.Revisions, 3)
+
+	// Unknown revision
+	rr = handleRequest(server, http.MethodPost, "/history/testbucket/foo.txt", `{"revision": "12345"}`)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
+
+func TestMetaSearchIfNoneMatch(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	etag := rr.Header().Get("ETag")
+
+	cachedRequest := func(method, ifNoneMatch string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(method, "/metadata/testbucket/foo.txt", "")
+		r.Header.Set("If-None-Match", ifNoneMatch)
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	// Unchanged metadata is not sent again
+	rr = cachedRequest(http.MethodGet, etag)
+	assert.Equal(t, rr.Code, http.StatusNotModified)
+	assert.Equal(t, rr.Header().Get("ETag"), etag)
+	assert.Equal(t, rr.Body.Len(), 0)
+
+	rr = cachedRequest(http.MethodHead, `"other", `+etag)
+	assert.Equal(t, rr.Code, http.StatusNotModified)
+
+	// Changed metadata is sent
+	rr = cachedRequest(http.MethodGet, `"other"`)
+	assertResponse(t, rr, http.StatusOK, `{"foo": "456"}`)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	server := testServer()
+
+	idempotentRequest := func(body, key string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(http.MethodPut, "/metadata/testbucket/foo.txt", body)
+		r.Header.Set("Idempotency-Key", key)
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	rr := idempotentRequest(`{"foo": "456"}`, "key1")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	assert.Equal(t, rr.Header().Get("Idempotent-Replayed"), "")
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "789"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Retried request is not applied again
+	rr = idempotentRequest(`{"foo": "456"}`, "key1")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	assert.Equal(t, rr.Header().Get("Idempotent-Replayed"), "true")
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "789"}`)
+
+	// Key cannot be reused for a different request
+	rr = idempotentRequest(`{"foo": "000"}`, "key1")
+	assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+
+	// Keys are scoped to the credentials
+	rr = httptest.NewRecorder()
+	r := testRequest(http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "000"}`)
+	r.Header.Set("Authorization", "Bearer othertoken")
+	r.Header.Set("Idempotency-Key", "key1")
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	assert.Equal(t, rr.Header().Get("Idempotent-Replayed"), "")
+
+	// Keys are scoped to the project
+	server.Auth = &projectAuthenticator{}
+	projectRequest := func(projectID string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+		r.Header.Set("X-Project-ID", projectID)
+		r.Header.Set("Idempotency-Key", "key2")
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+	rr = projectRequest(testProjectID)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	assert.Equal(t, rr.Header().Get("Idempotent-Replayed"), "")
+	rr = projectRequest(testProjectID)
+	assert.Equal(t, rr.Header().Get("Idempotent-Replayed"), "true")
+	rr = projectRequest(testrandUUID(t).String())
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	assert.Equal(t, rr.Header().Get("Idempotent-Replayed"), "")
+
+	// Unauthenticated requests do not get stored responses
+	rr = httptest.NewRecorder()
+	r = testRequest(http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	r.Header.Del("Authorization")
+	r.Header.Set("Idempotency-Key", "key2")
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusUnauthorized)
+	assert.Equal(t, rr.Header().Get("Idempotent-Replayed"), "")
+}
+
+// projectAuthenticator authenticates requests with an Authorization header
+// to the project of the X-Project-ID header.
+type projectAuthenticator struct{}
+
+func (a *projectAuthenticator) Authenticate(ctx context.Context, r *http.Request) (uuid.UUID, Encryptor, Authorizer, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.UUID{}, nil, nil, fmt.Errorf("%w: missing authorization header", ErrAuthorizationFailed)
+	}
+	projectID, err := uuid.FromString(r.Header.Get("X-Project-ID"))
+	if err != nil {
+		return uuid.UUID{}, nil, nil, fmt.Errorf("%w: invalid project ID", ErrAuthorizationFailed)
+	}
+	return projectID, &mockEncryptor{}, &mockAuthorizer{}, nil
+}
+
+func TestMetaSearchQuery(t *testing.T) {
+	server := testServer()
+
+	// Insert metadata
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{
+		"foo": "456",
+		"n": 1
+	}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/subdir/bar.txt", `{
+		"foo": "456",
+		"n": 2
+	}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Query without match => return all results
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", ``)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var resp map[string]interface{}
+	err := json.NewDecoder(rr.Body).Decode(&resp)
+	require.Nil(t, err)
+	require.Len(t, resp["results"], 2)
+
+	// Query with key prefix
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"keyPrefix": "subdir"
+	}`)
+	assertResponse(t, rr, http.StatusOK, `{
+		"results": [{
+			"path": "sj://testbucket/subdir/bar.txt",
+			"metadata": {
+				"foo": "456",
+				"n": 2
+			}
+		}]
+	}`)
+
+	// Query with match only
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"match": {
+			"foo": "456"
+		}
+	}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	require.Nil(t, err)
+	require.Len(t, resp["results"], 2)
+
+	// Query with match and filter
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"match": {
+			"foo": "456"
+		},
+		"filter": "n > `+"`1`"+`"
+	}`)
+	assertResponse(t, rr, http.StatusOK, `{
+		"results": [{
+			"path": "sj://testbucket/subdir/bar.txt",
+			"metadata": {
+				"foo": "456",
+				"n": 2
+			}
+		}]
+	}`)
+
+	// Query with match, filter and projection
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"match": {
+			"foo": "456"
+		},
+		"filter": "n > `+"`1`"+`",
+		"projection": "n"
+	}`)
+	assertResponse(t, rr, http.StatusOK, `{
+		"results": [{
+			"path": "sj://testbucket/subdir/bar.txt",
+			"metadata": 2
+		}]
+	}`)
+
+	// Query without path decryption
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"keyPrefix": "subdir",
+		"projection": "n",
+		"decryptPaths": false
+	}`)
+	assertResponse(t, rr, http.StatusOK, `{
+		"results": [{
+			"metadata": 2
+		}]
+	}`)
+
+	// Objects whose path cannot be decrypted are skipped without path
+	// decryption too
+	decryptPaths := false
+	request := &SearchRequest{
+		BaseRequest:  BaseRequest{Encryptor: &mockEncryptor{restrictPrefix: "subdir/"}},
+		DecryptPaths: &decryptPaths,
+	}
+	require.NoError(t, server.validateSearchRequest(request))
+	var response SearchResponse
+	for _, key := range []string{"enc:foo.txt", "enc:subdir/bar.txt"} {
+		_, err := server.appendSearchResult(&response, request, ObjectInfo{
+			ObjectLocation: ObjectLocation{BucketName: "testbucket", ObjectKey: key},
+			Metadata:       ObjectMetadata{ClearMetadata: map[string]interface{}{"n": 2}},
+		})
+		require.NoError(t, err)
+	}
+	require.Len(t, response.Results, 1)
+	require.Empty(t, response.Results[0].Path)
+
+	// Query with unknown match operator
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"match": {"n": {"$foo": 1}}
+	}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Query with invalid anyOf and not clauses
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"anyOf": [{"n": {"$foo": 1}}]
+	}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"match": {"$not": {"n": 1}},
+		"not": {"n": 2}
+	}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Query with exists and missing keys
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"exists": ["n"],
+		"missing": ["reviewedBy"]
+	}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"match": {"$exists": "n"}
+	}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Query with system attributes
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"system": {"size": {"$gt": 1000}, "createdAt": {"$gte": "2025-03-01T00:00:00Z"}}
+	}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"system": {"size": "large"}
 	}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestMetaSearchList(t *testing.T) {
+	server := testServer()
+
+	// Insert metadata
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
 	assert.Equal(t, rr.Code, http.StatusNoContent)
 
-	// Query without match => return all results
-	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", ``)
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/subdir/bar.txt", `{"foo": "789"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// List without prefix => return all results
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket", "")
 	assert.Equal(t, rr.Code, http.StatusOK)
 	var resp map[string]interface{}
 	err := json.NewDecoder(rr.Body).Decode(&resp)
 	require.Nil(t, err)
 	require.Len(t, resp["results"], 2)
 
-	// Query with key prefix
-	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
-		"keyPrefix": "subdir"
-	}`)
+	// List with prefix
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?prefix=subdir", "")
 	assertResponse(t, rr, http.StatusOK, `{
 		"results": [{
 			"path": "sj://testbucket/subdir/bar.txt",
 			"metadata": {
-				"foo": "456",
-				"n": 2
+				"foo": "789"
 			}
 		}]
 	}`)
 
-	// Query with match only
-	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
-		"match": {
-			"foo": "456"
-		}
-	}`)
+	// Invalid batch size
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket?batchSize=foo", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestMetaSearchIfNoneMatchQuery(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	query := `{"match": {"foo": "456"}}`
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", query)
 	assert.Equal(t, rr.Code, http.StatusOK)
-	err = json.NewDecoder(rr.Body).Decode(&resp)
-	require.Nil(t, err)
-	require.Len(t, resp["results"], 2)
+	etag := rr.Header().Get("ETag")
+	assert.NotEqual(t, etag, "")
+
+	cachedRequest := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(http.MethodPost, "/metasearch/testbucket", body)
+		r.Header.Set("If-None-Match", etag)
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
 
-	// Query with match and filter
-	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
-		"match": {
-			"foo": "456"
-		},
-		"filter": "n > `+"`1`"+`"
-	}`)
+	// Nothing changed
+	rr = cachedRequest(query)
+	assert.Equal(t, rr.Code, http.StatusNotModified)
+
+	// Different query
+	rr = cachedRequest(`{"match": {"foo": "789"}}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// Changes in other buckets do not affect the query
+	rr = handleRequest(server, http.MethodPut, "/metadata/otherbucket/bar.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = cachedRequest(query)
+	assert.Equal(t, rr.Code, http.StatusNotModified)
+
+	// Different staleness, timeout or partial results
+	rr = cachedRequest(`{"match": {"foo": "456"}, "staleness": "10s"}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	rr = cachedRequest(`{"match": {"foo": "456"}, "timeout": "5s", "partialResults": true}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// Changes made by other server instances
+	testRepo(server).watermarks["testbucket"]++
+	rr = cachedRequest(query)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// Metadata changed in the bucket
+	etag = rr.Header().Get("ETag")
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/bar.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = cachedRequest(query)
+	assert.Equal(t, rr.Code, http.StatusOK)
+}
+
+func TestMetaSearchWatermark(t *testing.T) {
+	server := testServer()
+
+	getWatermark := func() int64 {
+		rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket/watermark", "")
+		assert.Equal(t, rr.Code, http.StatusOK)
+
+		var resp Watermark
+		err := json.NewDecoder(rr.Body).Decode(&resp)
+		require.NoError(t, err)
+		return resp.Watermark
+	}
+
+	require.Equal(t, int64(0), getWatermark())
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	require.Equal(t, int64(1), getWatermark())
+
+	rr = handleRequest(server, http.MethodDelete, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	require.Equal(t, int64(2), getWatermark())
+
+	// Changes in other buckets
+	rr = handleRequest(server, http.MethodPut, "/metadata/otherbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	require.Equal(t, int64(2), getWatermark())
+}
+
+func TestMetaSearchChanges(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/bar.txt", `{"bar": "123"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodPut, "/metadata/otherbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "789"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	getChanges := func(query string) ChangesResponse {
+		rr := handleRequest(server, http.MethodGet, "/metasearch/testbucket/changes?"+query, "")
+		assert.Equal(t, rr.Code, http.StatusOK)
+
+		var resp ChangesResponse
+		err := json.NewDecoder(rr.Body).Decode(&resp)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := getChanges("since=1")
+	require.Len(t, resp.Changes, 2)
+	require.Equal(t, "sj://testbucket/bar.txt", resp.Changes[0].Path)
+	require.Equal(t, int64(2), resp.Changes[0].Watermark)
+	require.Equal(t, "sj://testbucket/foo.txt", resp.Changes[1].Path)
+	require.Equal(t, map[string]interface{}{"foo": "789"}, resp.Changes[1].Metadata)
+	require.Equal(t, int64(3), resp.Watermark)
+	require.Empty(t, resp.PageToken)
+
+	// Pagination
+	resp = getChanges("limit=2")
+	require.Len(t, resp.Changes, 2)
+	require.NotEmpty(t, resp.PageToken)
+
+	resp = getChanges("limit=2&pageToken=" + resp.PageToken)
+	require.Len(t, resp.Changes, 1)
+	require.Equal(t, "sj://testbucket/foo.txt", resp.Changes[0].Path)
+	require.Equal(t, int64(3), resp.Watermark)
+
+	// No changes after the latest watermark
+	resp = getChanges("since=3")
+	require.Empty(t, resp.Changes)
+	require.Equal(t, int64(3), resp.Watermark)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/changes?since=abc", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket/changes?pageToken=abc", "")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestMetaSearchImport(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// CSV manifest with header
+	rr = handleRequest(server, http.MethodPost, "/import/testbucket?merge=true", "key,color,size\nfoo.txt,red,\nmissing.txt,blue,2\n")
 	assertResponse(t, rr, http.StatusOK, `{
-		"results": [{
-			"path": "sj://testbucket/subdir/bar.txt",
-			"metadata": {
-				"foo": "456",
-				"n": 2
-			}
-		}]
+		"imported": 1,
+		"skipped": 0,
+		"failed": 1,
+		"errors": [{"line": 3, "key": "missing.txt", "error": "not found"}]
 	}`)
 
-	// Query with match, filter and projection
-	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
-		"match": {
-			"foo": "456"
-		},
-		"filter": "n > `+"`1`"+`",
-		"projection": "n"
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"foo": "456", "color": "red"}`)
+
+	// S3 inventory report with column mapping
+	schema := "Bucket, Key, VersionId, IsLatest, Size, StorageClass"
+	report := "awsbucket,dir%2Fbar.txt,v2,true,123,STANDARD\nawsbucket,dir%2Fbar.txt,v1,false,100,STANDARD\n"
+	rr = handleRequest(server, http.MethodPost, "/import/testbucket?format=s3-inventory&columns=Size:size,StorageClass&schema="+url.QueryEscape(schema), report)
+	assertResponse(t, rr, http.StatusOK, `{"imported": 1, "skipped": 1, "failed": 0}`)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/dir/bar.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"size": "123", "StorageClass": "STANDARD"}`)
+
+	// Missing key column
+	rr = handleRequest(server, http.MethodPost, "/import/testbucket", "name,color\nfoo.txt,red\n")
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestVocabularies(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/vocabularies/region", `{"values": ["eu/de", "eu/fr", "us"]}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodGet, "/vocabularies", "")
+	assertResponse(t, rr, http.StatusOK, `{"vocabularies": {"region": {"values": ["eu/de", "eu/fr", "us"]}}}`)
+
+	// Values and their ancestors are allowed
+	for _, metadata := range []string{`{"region": "eu/de"}`, `{"region": "eu"}`, `{"region": ["us", "eu/fr"]}`, `{"other": "any"}`} {
+		rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	for _, metadata := range []string{`{"region": "eu/it"}`, `{"region": "e"}`, `{"region": 1}`, `{"region": ["us", "asia"]}`} {
+		rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", metadata)
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+	}
+
+	rr = handleRequest(server, http.MethodPut, "/encrypted-metadata/testbucket/bar.txt", `{"clearMetadata": {"region": "asia"}}`)
+	assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+
+	var response ImportResponse
+	rr = handleRequest(server, http.MethodPost, "/import/testbucket", "key,region\na.txt,eu/fr\nb.txt,asia\n")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, response.Imported, 1)
+	assert.Equal(t, response.Failed, 1)
+
+	// Invalid vocabularies
+	for _, vocabulary := range []string{`{"values": []}`, `{"values": ["eu//de"]}`, `{"values": ["/eu"]}`} {
+		rr = handleRequest(server, http.MethodPut, "/vocabularies/region", vocabulary)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+	}
+
+	rr = handleRequest(server, http.MethodDelete, "/vocabularies/region", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodDelete, "/vocabularies/region", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"region": "asia"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Rollbacks cannot restore values rejected by the current vocabulary
+	rr = handleRequest(server, http.MethodGet, "/history/testbucket/foo.txt?limit=1", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var history HistoryResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &history))
+	require.Len(t, history.Revisions, 1)
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"region": "us"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodPut, "/vocabularies/region", `{"values": ["eu/de", "eu/fr", "us"]}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPost, "/history/testbucket/foo.txt", fmt.Sprintf(`{"revision": "%d"}`, history.Revisions[0].Revision))
+	assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"region": "us"}`)
+}
+
+func TestMetaSearchStream(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/subdir/bar.txt", `{"foo": "789"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Results are streamed as newline delimited JSON
+	rr = httptest.NewRecorder()
+	r := testRequest(http.MethodPost, "/metasearch/testbucket", `{"keyPrefix": "subdir"}`)
+	r.Header.Set("Accept", "application/x-ndjson")
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Type"), "application/x-ndjson")
+	require.True(t, rr.Flushed)
+
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	require.Len(t, lines, 1)
+	require.JSONEq(t, `{"path": "sj://testbucket/subdir/bar.txt", "metadata": {"foo": "789"}}`, lines[0])
+}
+
+func TestAdminGrants(t *testing.T) {
+	server := testServer()
+
+	// Admin API requires the admin token
+	rr := handleRequest(server, http.MethodGet, "/admin/grants", "")
+	assert.Equal(t, rr.Code, http.StatusUnauthorized)
+
+	// Use a grant
+	rr = handleRequest(server, http.MethodGet, "/metasearch/testbucket", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	rr = httptest.NewRecorder()
+	r := testRequest(http.MethodGet, "/admin/grants", "")
+	r.Header.Set("Authorization", "Bearer "+testAdminToken)
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	var resp struct {
+		Projects []ProjectGrants `json:"projects"`
+	}
+	err := json.NewDecoder(rr.Body).Decode(&resp)
+	require.NoError(t, err)
+	require.Len(t, resp.Projects, 1)
+	require.Len(t, resp.Projects[0].Grants, 1)
+	require.NotContains(t, resp.Projects[0].Grants[0].Fingerprint, "testtoken")
+	require.Equal(t, int64(1), resp.Projects[0].Grants[0].Requests)
+}
+
+func TestAdminSupportSearch(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	adminRequest := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(http.MethodPost, "/admin/search", body)
+		r.Header.Set("Authorization", "Bearer "+testAdminToken)
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	// Justification and projects are required
+	rr = adminRequest(`{"projectIds": ["` + testProjectID + `"]}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	rr = adminRequest(`{"justification": "abuse report #123"}`)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Paths are not decrypted
+	rr = adminRequest(`{
+		"projectIds": ["` + testProjectID + `"],
+		"match": {"foo": "456"},
+		"justification": "abuse report #123"
 	}`)
 	assertResponse(t, rr, http.StatusOK, `{
 		"results": [{
-			"path": "sj://testbucket/subdir/bar.txt",
-			"metadata": 2
+			"projectId": "`+testProjectID+`",
+			"bucket": "testbucket",
+			"encryptedKey": "ZW5jOmZvby50eHQ=",
+			"version": 0,
+			"metadata": {"foo": "456"}
 		}]
 	}`)
+
+	// Other projects are not returned
+	rr = adminRequest(`{
+		"projectIds": ["00000000-0000-0000-0000-000000000001"],
+		"justification": "abuse report #123"
+	}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": []}`)
+}
+
+func TestAdminMigrations(t *testing.T) {
+	server := testServer()
+	repo := testRepo(server)
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": 1}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/bar.txt", `{"foo": 1}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Migrate objects updated from uplink
+	assert.NoError(t, repo.updateFromUplink("testbucket", "foo.txt", `{"foo":2}`))
+	assert.NoError(t, repo.updateFromUplink("testbucket", "bar.txt", `{"foo":3}`))
+
+	projectID, err := uuid.FromString(testProjectID)
+	require.NoError(t, err)
+	require.True(t, server.Migrator.WaitForProject(context.Background(), projectID, time.Second))
+
+	rr = httptest.NewRecorder()
+	r := testRequest(http.MethodGet, "/admin/migrations?projectId="+testProjectID, "")
+	r.Header.Set("Authorization", "Bearer "+testAdminToken)
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	var resp struct {
+		Projects []MigrationProgress `json:"projects"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	require.NoError(t, err)
+	require.Len(t, resp.Projects, 1)
+	require.Equal(t, projectID, resp.Projects[0].ProjectID)
+	require.Equal(t, int64(0), resp.Projects[0].Remaining)
+	require.Equal(t, int64(2), resp.Projects[0].Migrated)
+	require.Equal(t, int64(0), resp.Projects[0].Failed)
+	require.Nil(t, resp.Projects[0].EstimatedCompletion)
+
+	// Invalid project ID
+	rr = httptest.NewRecorder()
+	r = testRequest(http.MethodGet, "/admin/migrations?projectId=foo", "")
+	r.Header.Set("Authorization", "Bearer "+testAdminToken)
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestAdminRebuildIndex(t *testing.T) {
+	server := testServer()
+
+	adminRequest := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(method, path, "")
+		r.Header.Set("Authorization", "Bearer "+testAdminToken)
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	rr := adminRequest(http.MethodPost, "/admin/indexes/foo/rebuild")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	// Another server instance is rebuilding an index
+	repo := testRepo(server)
+	otherHolder := testrandUUID(t)
+	require.NoError(t, repo.AcquireLease(context.Background(), indexRebuildLease, otherHolder, time.Now(), time.Now().Add(time.Hour)))
+	rr = adminRequest(http.MethodPost, "/admin/indexes/objects_clear_metadata_idx/rebuild")
+	assert.Equal(t, rr.Code, http.StatusConflict)
+	require.NoError(t, repo.ReleaseLease(context.Background(), indexRebuildLease, otherHolder))
+
+	rr = adminRequest(http.MethodPost, "/admin/indexes/objects_clear_metadata_idx/rebuild")
+	assert.Equal(t, rr.Code, http.StatusAccepted)
+
+	// Wait for the rebuild to finish in the background
+	var statuses []IndexRebuildStatus
+	require.Eventually(t, func() bool {
+		statuses = server.Indexes.Statuses()
+		return len(statuses) == 1 && statuses[0].FinishedAt != nil
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, IndexRebuildDone, statuses[0].Phase)
+	require.Equal(t, float64(1), statuses[0].Progress)
+	require.Empty(t, statuses[0].Error)
+
+	// The lease is released when the rebuild finishes
+	repo.leasesMu.Lock()
+	require.Empty(t, repo.leases)
+	repo.leasesMu.Unlock()
+
+	rr = adminRequest(http.MethodGet, "/admin/indexes")
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	var resp struct {
+		Rebuilds []IndexRebuildStatus `json:"rebuilds"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Rebuilds, 1)
+	require.Equal(t, "objects_clear_metadata_idx", resp.Rebuilds[0].Index)
+	require.Equal(t, IndexRebuildDone, resp.Rebuilds[0].Phase)
 }
 
 func TestMigrationOnGet(t *testing.T) {
 	server := testServer()
-	repo := server.Repo.(*mockRepo)
+	repo := testRepo(server)
 
 	// Insert metadata via HTTP
 	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": 1}`)
@@ -371,9 +2582,96 @@ func TestMigrationOnGet(t *testing.T) {
 	assert.False(t, repo.queuedForMigration("testbucket", "foo.txt"))
 }
 
+func TestMigrationExtractor(t *testing.T) {
+	server := testServer()
+	repo := testRepo(server)
+
+	var extracted ExtractRequest
+	verifier := signature.NewVerifier("extractorsecret", signature.DefaultMaxAge)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Header.Get("Authorization"), "Bearer extractortoken")
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, verifier.Verify(r, body, time.Now()))
+		assert.NoError(t, json.Unmarshal(body, &extracted))
+		_, _ = w.Write([]byte(`{"width": 640, "foo": 3}`))
+	}))
+	defer webhook.Close()
+
+	server.Migrator.SetExtractor(NewWebhookExtractor(webhook.URL, "extractortoken", "extractorsecret", []string{"image/*"}, time.Second))
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.jpg", `{"foo": 1}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Extracted metadata is added after migration, user metadata takes
+	// precedence
+	err := repo.updateFromUplink("testbucket", "foo.jpg", `{"content-type":"image/jpeg","foo":2}`)
+	assert.NoError(t, err)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.jpg", "")
+	assertResponse(t, rr, http.StatusOK, `{"content-type": "image/jpeg", "foo": 2}`)
+	server.Migrator.extractions.wait()
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.jpg", "")
+	assertResponse(t, rr, http.StatusOK, `{"content-type": "image/jpeg", "foo": 2, "width": 640}`)
+	assert.Equal(t, extracted.ObjectKey, "foo.jpg")
+	assert.Equal(t, extracted.ContentType, "image/jpeg")
+
+	// The mock encryptor cannot share a download grant
+	assert.Equal(t, extracted.Access, "")
+
+	// Extracted metadata is written by the extractor
+	history := repo.history["sj://testbucket/enc:foo.jpg"]
+	require.NotEmpty(t, history)
+	assert.Equal(t, history[len(history)-1].Actor, extractorActor)
+
+	// Other content types are not extracted
+	rr = handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": 1}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	err = repo.updateFromUplink("testbucket", "foo.txt", `{"content-type":"text/plain","foo":2}`)
+	assert.NoError(t, err)
+
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	server.Migrator.extractions.wait()
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assertResponse(t, rr, http.StatusOK, `{"content-type": "text/plain", "foo": 2}`)
+}
+
+func TestExtractionAfterUplinkUpdate(t *testing.T) {
+	server := testServer()
+	repo := testRepo(server)
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.jpg", `{"foo": 1}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	obj := repo.objects["sj://testbucket/enc:foo.jpg"]
+
+	// The metadata is updated by uplink while the extractor runs
+	err := repo.updateFromUplink("testbucket", "foo.jpg", `{"foo":2}`)
+	require.NoError(t, err)
+
+	queue := &extractionQueue{log: zap.NewNop(), repo: repo, extractor: staticExtractor{"width": 640}}
+	err = queue.extract(context.Background(), extractionJob{obj: obj, clearObjectKey: "foo.jpg"})
+	require.NoError(t, err)
+
+	// The object is still migrated, with the metadata of uplink
+	assert.True(t, repo.queuedForMigration("testbucket", "foo.jpg"))
+	assert.Equal(t, string(repo.objects["sj://testbucket/enc:foo.jpg"].Metadata.EncryptedMetadata), `{"foo":2}`)
+	require.NotContains(t, repo.objects["sj://testbucket/enc:foo.jpg"].Metadata.ClearMetadata, "width")
+}
+
+// staticExtractor extracts the same metadata from all objects.
+type staticExtractor map[string]interface{}
+
+func (e staticExtractor) Matches(contentType string) bool { return true }
+
+func (e staticExtractor) Extract(ctx context.Context, request ExtractRequest) (map[string]interface{}, error) {
+	return e, nil
+}
+
 func TestMigrationOnSearch(t *testing.T) {
 	server := testServer()
-	repo := server.Repo.(*mockRepo)
+	repo := testRepo(server)
 
 	// Insert metadata via HTTP
 	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": 1}`)
@@ -396,3 +2694,71 @@ func TestMigrationOnSearch(t *testing.T) {
 	}`)
 	assert.False(t, repo.queuedForMigration("testbucket", "foo.txt"))
 }
+
+func TestStatelessServer(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		AdminToken: testAdminToken,
+		Stateless:  true,
+	})
+	require.NoError(t, err)
+	repo := testRepo(server)
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": 1}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Objects are migrated within the request, without a migration worker
+	err = repo.updateFromUplink("testbucket", "foo.txt", `{"foo":2}`)
+	assert.NoError(t, err)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"match":{"foo":2}}`)
+	assertResponse(t, rr, http.StatusOK, `{
+		"results": [{
+			"path": "sj://testbucket/foo.txt",
+			"metadata": {
+				"foo": 2
+			}
+		}]
+	}`)
+	assert.False(t, repo.queuedForMigration("testbucket", "foo.txt"))
+	require.Empty(t, server.Migrator.Progress(uuid.UUID{}))
+
+	// Entity tags change with changes made by other instances
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	search := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(http.MethodPost, "/metasearch/testbucket", `{"match":{"foo":2}}`)
+		r.Header.Set("If-None-Match", etag)
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+	assert.Equal(t, search().Code, http.StatusNotModified)
+	repo.watermarks["testbucket"]++
+	assert.Equal(t, search().Code, http.StatusOK)
+
+	// Idempotency keys are rejected
+	r := testRequest(http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": 3}`)
+	r.Header.Set("Idempotency-Key", "key1")
+	rr = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Grants are not tracked
+	r = testRequest(http.MethodGet, "/admin/grants", "")
+	r.Header.Set("Authorization", "Bearer "+testAdminToken)
+	rr = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
+
+func TestStatelessMetadataCache(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	_, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		AdminToken:        testAdminToken,
+		Stateless:         true,
+		MetadataCacheTTL:  time.Minute,
+		MetadataCacheSize: 100,
+	})
+	require.Error(t, err)
+}
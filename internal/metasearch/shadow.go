@@ -0,0 +1,139 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	shadowQueryTimeout       = 30 * time.Second
+	maxConcurrentShadowReads = 10
+)
+
+// ShadowSearchRepository runs read operations against both a primary and a
+// shadow repository, e.g. an alternative search backend before cutover.
+// Results are always returned from the primary repository; shadow results are
+// compared asynchronously, and mismatches are reported as metrics. Write
+// operations only go to the primary repository.
+type ShadowSearchRepository struct {
+	MetaSearchRepo
+
+	shadow MetaSearchRepo
+	log    *zap.Logger
+	limit  chan struct{}
+}
+
+// NewShadowSearchRepository creates a new ShadowSearchRepository.
+func NewShadowSearchRepository(primary MetaSearchRepo, shadow MetaSearchRepo, log *zap.Logger) *ShadowSearchRepository {
+	return &ShadowSearchRepository{
+		MetaSearchRepo: primary,
+		shadow:         shadow,
+		log:            log,
+		limit:          make(chan struct{}, maxConcurrentShadowReads),
+	}
+}
+
+func (r *ShadowSearchRepository) GetMetadata(ctx context.Context, loc ObjectLocation) (ObjectInfo, error) {
+	obj, err := r.MetaSearchRepo.GetMetadata(ctx, loc)
+
+	// The caller may modify the object while the shadow read runs
+	primaryObj := cloneObjectInfo(obj)
+	r.compare(ctx, "GetMetadata", func(ctx context.Context) bool {
+		shadowObj, shadowErr := r.shadow.GetMetadata(ctx, loc)
+		if err != nil || shadowErr != nil {
+			return (err == nil) == (shadowErr == nil)
+		}
+		return sameObjects([]ObjectInfo{primaryObj}, []ObjectInfo{shadowObj})
+	})
+	return obj, err
+}
+
+func (r *ShadowSearchRepository) QueryMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int) (QueryMetadataResult, error) {
+	result, err := r.MetaSearchRepo.QueryMetadata(ctx, loc, containsQuery, sort, startAfter, asOf, batchSize)
+
+	// The query and the results belong to the request, which may modify
+	// them while the shadow query runs
+	shadowQuery, _ := cloneMetadataValue(containsQuery).(map[string]interface{})
+	var shadowSort *MetadataSort
+	if sort != nil {
+		sortCopy := *sort
+		shadowSort = &sortCopy
+	}
+	primaryObjects := make([]ObjectInfo, len(result.Objects))
+	for i, obj := range result.Objects {
+		primaryObjects[i] = cloneObjectInfo(obj)
+	}
+
+	r.compare(ctx, "QueryMetadata", func(ctx context.Context) bool {
+		shadowResult, shadowErr := r.shadow.QueryMetadata(ctx, loc, shadowQuery, shadowSort, startAfter, asOf, batchSize)
+		if err != nil || shadowErr != nil {
+			return (err == nil) == (shadowErr == nil)
+		}
+		return sameObjects(primaryObjects, shadowResult.Objects)
+	})
+	return result, err
+}
+
+// QueryMetadataFunc reads all objects of the batch, to compare them with the
+// shadow results.
+func (r *ShadowSearchRepository) QueryMetadataFunc(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int, fn ObjectFunc) (QueryMetadataResult, error) {
+	result, err := r.QueryMetadata(ctx, loc, containsQuery, sort, startAfter, asOf, batchSize)
+	return forEachObject(result, err, fn)
+}
+
+// compare runs the shadow operation in the background. Shadow operations are
+// skipped if too many of them are already running, so that a slow shadow
+// backend cannot slow down the primary.
+func (r *ShadowSearchRepository) compare(ctx context.Context, method string, shadowMatches func(ctx context.Context) bool) {
+	tag := monkit.NewSeriesTag("method", method)
+
+	select {
+	case r.limit <- struct{}{}:
+	default:
+		mon.Counter("shadow_skipped", tag).Inc(1)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowQueryTimeout)
+	go func() {
+		defer func() { <-r.limit }()
+		defer cancel()
+
+		if shadowMatches(ctx) {
+			mon.Counter("shadow_match", tag).Inc(1)
+			return
+		}
+
+		mon.Counter("shadow_mismatch", tag).Inc(1)
+		r.log.Warn("shadow result mismatch", zap.String("Method", method))
+	}()
+}
+
+// sameObjects compares the location and clear metadata of two object lists.
+func sameObjects(a, b []ObjectInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].ObjectLocation != b[i].ObjectLocation {
+			return false
+		}
+
+		// Compare the JSON representation, as numbers may have different
+		// types depending on the backend.
+		ma, errA := json.Marshal(a[i].Metadata.ClearMetadata)
+		mb, errB := json.Marshal(b[i].Metadata.ClearMetadata)
+		if errA != nil || errB != nil || string(ma) != string(mb) {
+			return false
+		}
+	}
+	return true
+}
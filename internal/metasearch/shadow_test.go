@@ -0,0 +1,58 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestShadowSearchRepository(t *testing.T) {
+	ctx := context.Background()
+	primary := newMockRepo()
+	shadow := newMockRepo()
+	repo := NewShadowSearchRepository(primary, shadow, zap.NewNop())
+
+	loc := ObjectLocation{BucketName: "testbucket", ObjectKey: "foo.txt"}
+	meta := ObjectMetadata{ClearMetadata: map[string]interface{}{"foo": "bar"}}
+
+	// Writes only go to the primary
+	require.NoError(t, repo.UpdateMetadata(ctx, loc, meta))
+	require.Len(t, primary.objects, 1)
+	require.Len(t, shadow.objects, 0)
+
+	// Reads are served from the primary
+	obj, err := repo.GetMetadata(ctx, loc)
+	require.NoError(t, err)
+	require.Equal(t, "bar", obj.Metadata.ClearMetadata["foo"])
+
+	// Requests may modify their query and results while shadow reads run
+	query := map[string]interface{}{"foo": "bar"}
+	result, err := repo.QueryMetadata(ctx, ObjectLocation{BucketName: "testbucket"}, query, &MetadataSort{Key: "foo"}, ObjectLocation{}, time.Time{}, 10)
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 1)
+	query["foo"] = "baz"
+	result.Objects[0].Metadata.ClearMetadata["foo"] = "baz"
+	obj.Metadata.ClearMetadata["foo"] = "baz"
+}
+
+func TestSameObjects(t *testing.T) {
+	a := []ObjectInfo{{
+		ObjectLocation: ObjectLocation{BucketName: "testbucket", ObjectKey: "foo.txt"},
+		Metadata:       ObjectMetadata{ClearMetadata: map[string]interface{}{"n": 1}},
+	}}
+	b := []ObjectInfo{{
+		ObjectLocation: ObjectLocation{BucketName: "testbucket", ObjectKey: "foo.txt"},
+		Metadata:       ObjectMetadata{ClearMetadata: map[string]interface{}{"n": float64(1)}},
+	}}
+	require.True(t, sameObjects(a, b))
+
+	b[0].Metadata.ClearMetadata["n"] = 2
+	require.False(t, sameObjects(a, b))
+	require.False(t, sameObjects(a, nil))
+}
@@ -0,0 +1,163 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// maxVectorDimensions is the maximum number of dimensions of the vector of a
+// similarity search.
+const maxVectorDimensions = 4096
+
+// maxSimilarityCandidates is the maximum number of objects ranked by a
+// similarity search.
+const maxSimilarityCandidates = 10000
+
+// SimilarTo ranks the objects of a search by the cosine similarity of an
+// embedding vector stored in their metadata to a query vector.
+type SimilarTo struct {
+	// Key is the dot separated path of the embedding in the metadata.
+	Key    string    `json:"key"`
+	Vector []float64 `json:"vector"`
+	// MinScore excludes objects whose similarity is lower.
+	MinScore *float64 `json:"minScore,omitempty"`
+}
+
+// validate validates a similarity query.
+func (q *SimilarTo) validate() error {
+	if q.Key == "" {
+		return fmt.Errorf("%w: similarTo requires a key", ErrBadRequest)
+	}
+	if len(q.Vector) == 0 || len(q.Vector) > maxVectorDimensions {
+		return fmt.Errorf("%w: similarTo vector must have between 1 and %d dimensions", ErrBadRequest, maxVectorDimensions)
+	}
+	norm := vectorNorm(q.Vector)
+	if norm == 0 || math.IsInf(norm, 0) {
+		return fmt.Errorf("%w: similarTo vector must be non-zero and finite", ErrBadRequest)
+	}
+	return nil
+}
+
+// vectorNorm returns the euclidean norm of a vector.
+func vectorNorm(v []float64) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+// cosineSimilarity returns the cosine similarity of the embedding in the
+// metadata to the query vector, whose norm is given. It returns false if the
+// metadata has no embedding with the same number of dimensions.
+func cosineSimilarity(metadata map[string]interface{}, key string, vector []float64, norm float64) (float64, bool) {
+	_, value, ok := lookupPath(metadata, strings.Split(key, "."), false)
+	if !ok {
+		return 0, false
+	}
+	embedding, ok := floatArray(value, len(vector))
+	if !ok {
+		return 0, false
+	}
+
+	var dot float64
+	for i := range vector {
+		dot += vector[i] * embedding[i]
+	}
+	embeddingNorm := vectorNorm(embedding)
+	if embeddingNorm == 0 {
+		return 0, false
+	}
+	return dot / (norm * embeddingNorm), true
+}
+
+// searchSimilar returns the objects of a search whose embedding is the most
+// similar to the vector of the request, most similar first. Up to
+// maxSimilarityCandidates matching objects are ranked in memory, so the
+// search should be narrowed down by the match query or key prefix. The
+// response is truncated if there are more.
+func (s *Server) searchSimilar(ctx context.Context, request *SearchRequest) (response SearchResponse, err error) {
+	defer func() {
+		if err == nil {
+			recordAccessResults(ctx, len(response.Results))
+		}
+	}()
+
+	similarTo := request.SimilarTo
+	norm := vectorNorm(similarTo.Vector)
+
+	// The best results so far, most similar first
+	results := make([]SearchResult, 0)
+	startAfter, asOf := ObjectLocation{}, request.asOf
+	scanned := 0
+	for {
+		var searchResult QueryMetadataResult
+		searchResult, err = s.Repo.QueryMetadata(ctx, request.EncryptedLocation, request.Match, nil, startAfter, asOf, request.BatchSize)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return response, fmt.Errorf("%w: search timed out", ErrServiceUnavailable)
+			}
+			return response, err
+		}
+		response.Warnings = appendWarnings(response.Warnings, searchResult.Warnings)
+		if s.Config.PageTokenRetention > 0 {
+			asOf = searchResult.AsOf
+		}
+
+		for _, obj := range searchResult.Objects {
+			scanned++
+			score, ok := cosineSimilarity(obj.Metadata.ClearMetadata, similarTo.Key, similarTo.Vector, norm)
+			if !ok || similarTo.MinScore != nil && score < *similarTo.MinScore {
+				continue
+			}
+			if len(results) >= request.BatchSize && score <= *results[len(results)-1].Score {
+				continue
+			}
+
+			// Filter and project the object like in other searches
+			var candidate SearchResponse
+			_, err = s.appendSearchResult(&candidate, request, obj)
+			if err != nil {
+				return response, err
+			}
+			if len(candidate.Results) == 0 {
+				continue
+			}
+			result := candidate.Results[0]
+			result.Score = &score
+
+			i := sort.Search(len(results), func(i int) bool { return *results[i].Score < score })
+			results = append(results, SearchResult{})
+			copy(results[i+1:], results[i:])
+			results[i] = result
+			if len(results) > request.BatchSize {
+				results = results[:request.BatchSize]
+			}
+		}
+
+		// Determine the start of the next batch
+		if len(searchResult.Objects) >= request.BatchSize {
+			startAfter = searchResult.Objects[len(searchResult.Objects)-1].ObjectLocation
+		} else if searchResult.ScannedUntil != nil {
+			startAfter = *searchResult.ScannedUntil
+		} else {
+			break
+		}
+
+		if scanned >= maxSimilarityCandidates {
+			response.Warnings = append(response.Warnings, fmt.Sprintf("only the first %d matching objects were ranked by similarity, narrow down the search to rank all of them", maxSimilarityCandidates))
+			response.Truncated = true
+			break
+		}
+	}
+
+	response.Results = results
+	return response, nil
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestSearchSimilar(t *testing.T) {
+	server := testServer()
+	testRepo(server).paginate = true
+
+	for key, metadata := range map[string]string{
+		"a.jpg": `{"name": "a", "ml": {"embedding": [1, 0]}}`,
+		"b.jpg": `{"name": "b", "ml": {"embedding": [3, 4]}}`,
+		"c.jpg": `{"name": "c", "ml": {"embedding": [0, 2]}}`,
+		"d.jpg": `{"name": "d"}`,
+		"e.jpg": `{"name": "e", "ml": {"embedding": [1, 0, 0]}}`,
+		"f.jpg": `{"name": "f", "ml": {"embedding": ["1", "0"]}}`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+key, metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	// The most similar objects first, across all batches
+	rr := handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"similarTo": {"key": "ml.embedding", "vector": [2, 0]},
+		"projection": "name",
+		"batchSize": 2
+	}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [
+		{"path": "sj://testbucket/a.jpg", "metadata": "a", "score": 1},
+		{"path": "sj://testbucket/b.jpg", "metadata": "b", "score": 0.6}
+	]}`)
+
+	// Minimum score and filter
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"similarTo": {"key": "ml.embedding", "vector": [0, 1], "minScore": 0.5},
+		"filter": "name != 'c'",
+		"projection": "name"
+	}`)
+	assertResponse(t, rr, http.StatusOK, `{"results": [
+		{"path": "sj://testbucket/b.jpg", "metadata": "b", "score": 0.8}
+	]}`)
+
+	// Only the first candidates are ranked
+	repo := testRepo(server)
+	repo.paginate = false
+	embedding := `{"ml": {"embedding": [1, 0]}}`
+	for i := 0; i <= maxSimilarityCandidates; i++ {
+		loc := ObjectLocation{BucketName: "testbucket", ObjectKey: fmt.Sprintf("enc:many/%05d.jpg", i)}
+		err := repo.UpdateMetadata(context.Background(), loc, ObjectMetadata{
+			ClearMetadata:     map[string]interface{}{"ml": map[string]interface{}{"embedding": []interface{}{float64(1), float64(0)}}},
+			EncryptedMetadata: []byte(embedding),
+		})
+		require.NoError(t, err)
+	}
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{
+		"similarTo": {"key": "ml.embedding", "vector": [1, 0]},
+		"keyPrefix": "many/",
+		"batchSize": 1
+	}`)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var response SearchResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.True(t, response.Truncated)
+	require.Len(t, response.Results, 1)
+	require.NotEmpty(t, response.Warnings)
+	require.Empty(t, rr.Header().Get("ETag"))
+
+	// Invalid queries
+	for _, body := range []string{
+		`{"similarTo": {"vector": [1, 0]}}`,
+		`{"similarTo": {"key": "ml.embedding", "vector": []}}`,
+		`{"similarTo": {"key": "ml.embedding", "vector": [0, 0]}}`,
+		`{"similarTo": {"key": "ml.embedding", "vector": [1, 0]}, "sort": {"key": "name"}}`,
+		`{"similarTo": {"key": "ml.embedding", "vector": [1, 0]}, "countOnly": true}`,
+	} {
+		rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", body)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+	}
+}
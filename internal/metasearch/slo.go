@@ -0,0 +1,353 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spacemonkeygo/monkit/v3"
+)
+
+const (
+	// sloBucketDuration is the resolution of the SLO request counters.
+	sloBucketDuration = time.Minute
+
+	// sloRetention is the longest SLO window.
+	sloRetention = 24 * time.Hour
+
+	// sloReportInterval is the interval of the burn rate metrics.
+	sloReportInterval = time.Minute
+)
+
+// sloWindows are the windows burn rates are computed over.
+var sloWindows = []time.Duration{
+	5 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+// sloAlertRules are multiwindow burn rate alerts: an alert fires if the burn
+// rates over both the long and the short window exceed the threshold. A burn
+// rate of 1 consumes the error budget in exactly 30 days.
+var sloAlertRules = []struct {
+	severity  string
+	long      time.Duration
+	short     time.Duration
+	threshold float64
+}{
+	{"page", time.Hour, 5 * time.Minute, 14.4},
+	{"page", 6 * time.Hour, 30 * time.Minute, 6},
+	{"ticket", 24 * time.Hour, time.Hour, 3},
+}
+
+// SLO is the service level objective of an API endpoint. A request is good if
+// it does not fail with a server error, and completes within Latency.
+type SLO struct {
+	// Endpoint is the name of the endpoint, e.g. "search".
+	Endpoint string
+	// Latency is the maximum latency of good requests.
+	Latency time.Duration
+	// Objective is the target percentage of good requests, e.g. 99.9.
+	Objective float64
+}
+
+// ParseSLOs parses a comma separated list of endpoint:latency:objective
+// triples, e.g. "search:500ms:99.9,get:100ms:99.95".
+func ParseSLOs(s string) ([]SLO, error) {
+	var slos []SLO
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid SLO '%s': must be endpoint:latency:objective", item)
+		}
+		latency, err := time.ParseDuration(parts[1])
+		if err != nil || latency <= 0 {
+			return nil, fmt.Errorf("invalid SLO '%s': invalid latency", item)
+		}
+		objective, err := strconv.ParseFloat(strings.TrimSuffix(parts[2], "%"), 64)
+		if err != nil || objective <= 0 || objective >= 100 {
+			return nil, fmt.Errorf("invalid SLO '%s': objective must be a percentage between 0 and 100", item)
+		}
+
+		slos = append(slos, SLO{Endpoint: parts[0], Latency: latency, Objective: objective})
+	}
+	return slos, nil
+}
+
+// SLOTracker counts the good and total requests of the endpoints with an SLO,
+// and computes their burn rates.
+type SLOTracker struct {
+	endpoints map[string]*sloEndpoint
+}
+
+type sloEndpoint struct {
+	slo SLO
+
+	mutex   sync.Mutex
+	buckets []sloBucket
+}
+
+type sloBucket struct {
+	start time.Time
+	total int64
+	good  int64
+}
+
+// NewSLOTracker creates a new SLOTracker for the given SLOs.
+func NewSLOTracker(slos []SLO) *SLOTracker {
+	t := &SLOTracker{
+		endpoints: make(map[string]*sloEndpoint, len(slos)),
+	}
+	for _, slo := range slos {
+		t.endpoints[slo.Endpoint] = &sloEndpoint{
+			slo:     slo,
+			buckets: make([]sloBucket, sloRetention/sloBucketDuration),
+		}
+	}
+	return t
+}
+
+// Record records a request of an endpoint. Endpoints without an SLO are
+// ignored.
+func (t *SLOTracker) Record(endpoint string, now time.Time, status int, latency time.Duration) {
+	e, ok := t.endpoints[endpoint]
+	if !ok {
+		return
+	}
+
+	good := status < http.StatusInternalServerError && latency <= e.slo.Latency
+	e.record(now, good)
+
+	tag := monkit.NewSeriesTag("endpoint", endpoint)
+	mon.Counter("slo_requests", tag).Inc(1)
+	if good {
+		mon.Counter("slo_good_requests", tag).Inc(1)
+	}
+}
+
+func (e *sloEndpoint) record(now time.Time, good bool) {
+	start := now.Truncate(sloBucketDuration)
+	i := int(start.Unix()/int64(sloBucketDuration/time.Second)) % len(e.buckets)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	bucket := &e.buckets[i]
+	if !bucket.start.Equal(start) {
+		*bucket = sloBucket{start: start}
+	}
+	bucket.total++
+	if good {
+		bucket.good++
+	}
+}
+
+// window returns the number of total and good requests in the window ending
+// at now.
+func (e *sloEndpoint) window(now time.Time, window time.Duration) (total int64, good int64) {
+	since := now.Truncate(sloBucketDuration).Add(-window)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, bucket := range e.buckets {
+		if bucket.start.After(since) && !bucket.start.After(now) {
+			total += bucket.total
+			good += bucket.good
+		}
+	}
+	return total, good
+}
+
+// burnRate returns the rate the error budget is consumed at: the error rate
+// divided by the error rate allowed by the objective.
+func (e *sloEndpoint) burnRate(total, good int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	errorRate := float64(total-good) / float64(total)
+	return errorRate / (1 - e.slo.Objective/100)
+}
+
+// SLOSummary is the state of the SLO of an endpoint.
+type SLOSummary struct {
+	Endpoint  string      `json:"endpoint"`
+	Latency   string      `json:"latency"`
+	Objective float64     `json:"objective"`
+	Windows   []SLOWindow `json:"windows"`
+	Alerts    []SLOAlert  `json:"alerts"`
+}
+
+// SLOWindow contains the requests of an endpoint in a window.
+type SLOWindow struct {
+	Window   string  `json:"window"`
+	Requests int64   `json:"requests"`
+	Good     int64   `json:"good"`
+	BurnRate float64 `json:"burnRate"`
+}
+
+// SLOAlert is a firing burn rate alert.
+type SLOAlert struct {
+	Severity  string  `json:"severity"`
+	Window    string  `json:"window"`
+	BurnRate  float64 `json:"burnRate"`
+	Threshold float64 `json:"threshold"`
+}
+
+// SLOResponse contains the SLO summaries of all endpoints with an SLO.
+type SLOResponse struct {
+	Endpoints []SLOSummary `json:"endpoints"`
+}
+
+// Summary returns the SLO summaries of all endpoints, sorted by endpoint.
+func (t *SLOTracker) Summary(now time.Time) []SLOSummary {
+	summaries := make([]SLOSummary, 0, len(t.endpoints))
+	for _, e := range t.endpoints {
+		summary := SLOSummary{
+			Endpoint:  e.slo.Endpoint,
+			Latency:   e.slo.Latency.String(),
+			Objective: e.slo.Objective,
+			Windows:   make([]SLOWindow, 0, len(sloWindows)),
+			Alerts:    []SLOAlert{},
+		}
+
+		burnRates := make(map[time.Duration]float64, len(sloWindows))
+		for _, window := range sloWindows {
+			total, good := e.window(now, window)
+			burnRates[window] = e.burnRate(total, good)
+			summary.Windows = append(summary.Windows, SLOWindow{
+				Window:   window.String(),
+				Requests: total,
+				Good:     good,
+				BurnRate: burnRates[window],
+			})
+		}
+
+		for _, rule := range sloAlertRules {
+			if burnRates[rule.long] > rule.threshold && burnRates[rule.short] > rule.threshold {
+				summary.Alerts = append(summary.Alerts, SLOAlert{
+					Severity:  rule.severity,
+					Window:    rule.long.String(),
+					BurnRate:  burnRates[rule.long],
+					Threshold: rule.threshold,
+				})
+			}
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Endpoint < summaries[j].Endpoint
+	})
+	return summaries
+}
+
+// report publishes the burn rates of all endpoints as metrics.
+func (t *SLOTracker) report(now time.Time) {
+	for _, summary := range t.Summary(now) {
+		endpoint := monkit.NewSeriesTag("endpoint", summary.Endpoint)
+		for _, window := range summary.Windows {
+			mon.FloatVal("slo_burn_rate", endpoint, monkit.NewSeriesTag("window", window.Window)).Observe(window.BurnRate)
+		}
+		mon.IntVal("slo_alerts", endpoint).Observe(int64(len(summary.Alerts)))
+	}
+}
+
+// reportSLOs publishes the burn rates periodically.
+func (s *Server) reportSLOs() {
+	for {
+		time.Sleep(sloReportInterval)
+		s.SLOs.report(time.Now())
+	}
+}
+
+// trackSLO records the status and latency of the requests of the endpoints
+// with an SLO. Endpoints are identified by route name.
+func (s *Server) trackSLO(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil || s.SLOs.endpoints[route.GetName()] == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &statusResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		s.SLOs.Record(route.GetName(), time.Now(), rec.status, time.Since(start))
+	})
+}
+
+// HandleSLO returns the SLO summaries of the endpoints, with their burn rates
+// and firing alerts.
+func (s *Server) HandleSLO(w http.ResponseWriter, r *http.Request) {
+	s.jsonResponse(w, http.StatusOK, SLOResponse{Endpoints: s.SLOs.Summary(time.Now())})
+}
+
+// statusResponseWriter records the status and size of a response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hijacks the connection of WebSocket upgrades, whose status is
+// recorded as 101 Switching Protocols.
+func (w *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
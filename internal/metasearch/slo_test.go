@@ -0,0 +1,102 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseSLOs(t *testing.T) {
+	slos, err := ParseSLOs("search:500ms:99.9, get:100ms:99.95%")
+	require.NoError(t, err)
+	require.Equal(t, []SLO{
+		{Endpoint: "search", Latency: 500 * time.Millisecond, Objective: 99.9},
+		{Endpoint: "get", Latency: 100 * time.Millisecond, Objective: 99.95},
+	}, slos)
+
+	for _, invalid := range []string{"search", "search:fast:99", "search:1s:100", ":1s:99"} {
+		_, err = ParseSLOs(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestSLOTracker(t *testing.T) {
+	tracker := NewSLOTracker([]SLO{{Endpoint: "search", Latency: time.Second, Objective: 99}})
+	now := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	// 2% errors in the last 5 minutes, 1% over the last hour
+	for i := 0; i < 100; i++ {
+		tracker.Record("search", now.Add(-10*time.Minute), http.StatusOK, time.Millisecond)
+	}
+	for i := 0; i < 98; i++ {
+		tracker.Record("search", now, http.StatusOK, time.Millisecond)
+	}
+	tracker.Record("search", now, http.StatusInternalServerError, time.Millisecond)
+	tracker.Record("search", now, http.StatusOK, 2*time.Second)
+	tracker.Record("search", now, http.StatusNotFound, time.Millisecond)
+	tracker.Record("other", now, http.StatusInternalServerError, time.Millisecond)
+
+	summaries := tracker.Summary(now)
+	require.Len(t, summaries, 1)
+	require.Equal(t, "search", summaries[0].Endpoint)
+	require.Equal(t, SLOWindow{Window: "5m0s", Requests: 101, Good: 99, BurnRate: 1.98}, roundBurnRate(summaries[0].Windows[0]))
+	require.Equal(t, int64(201), summaries[0].Windows[2].Requests)
+	require.Empty(t, summaries[0].Alerts)
+
+	// Sustained errors fire alerts
+	for i := 0; i < 100; i++ {
+		tracker.Record("search", now, http.StatusServiceUnavailable, time.Millisecond)
+	}
+	summaries = tracker.Summary(now)
+	require.Len(t, summaries[0].Alerts, 3)
+	require.Equal(t, "page", summaries[0].Alerts[0].Severity)
+	require.Equal(t, "1h0m0s", summaries[0].Alerts[0].Window)
+
+	// Requests older than a day are forgotten
+	summaries = tracker.Summary(now.Add(25 * time.Hour))
+	require.Zero(t, summaries[0].Windows[4].Requests)
+}
+
+func roundBurnRate(window SLOWindow) SLOWindow {
+	window.BurnRate = float64(int(window.BurnRate*1000)) / 1000
+	return window
+}
+
+func TestSLOEndpoint(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	_, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		SLOs: []SLO{{Endpoint: "unknown", Latency: time.Second, Objective: 99}},
+	})
+	require.Error(t, err)
+
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		SLOs: []SLO{{Endpoint: "get", Latency: time.Minute, Objective: 99.9}},
+	})
+	require.NoError(t, err)
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": 1}`)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/missing.txt", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = handleRequest(server, http.MethodGet, "/slo", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp SLOResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Endpoints, 1)
+	require.Equal(t, "get", resp.Endpoints[0].Endpoint)
+	require.Equal(t, "1m0s", resp.Endpoints[0].Latency)
+	require.Equal(t, int64(2), resp.Endpoints[0].Windows[0].Requests)
+	require.Equal(t, int64(2), resp.Endpoints[0].Windows[0].Good)
+	require.Empty(t, resp.Endpoints[0].Alerts)
+}
@@ -0,0 +1,209 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"storj.io/storj/shared/tagsql"
+)
+
+// explainTimeout bounds the EXPLAIN ANALYZE capture of a slow query.
+const explainTimeout = time.Minute
+
+// SlowQueryConfig configures the logging of slow metabase queries.
+type SlowQueryConfig struct {
+	// Threshold is the duration above which queries are logged, disabled if
+	// 0.
+	Threshold time.Duration
+	// Explain captures the plan of slow SELECT queries with EXPLAIN ANALYZE.
+	// The capture runs the query again in the background, one at a time.
+	Explain bool
+}
+
+// slowQueryDB logs the queries that take longer than the threshold, with
+// their redacted bind parameters and optionally their plan, to diagnose
+// unexpected query plans.
+type slowQueryDB struct {
+	tagsql.DB
+
+	log    *zap.Logger
+	config SlowQueryConfig
+
+	// explaining limits the number of concurrent plan captures.
+	explaining chan struct{}
+}
+
+// LogSlowQueries returns db, logging the queries slower than the threshold of
+// config. db is returned as is if the threshold is 0.
+func LogSlowQueries(db tagsql.DB, log *zap.Logger, config SlowQueryConfig) tagsql.DB {
+	if config.Threshold <= 0 {
+		return db
+	}
+	return &slowQueryDB{
+		DB:         db,
+		log:        log,
+		config:     config,
+		explaining: make(chan struct{}, 1),
+	}
+}
+
+func (db *slowQueryDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	db.check(ctx, query, args, time.Since(start))
+	return result, err
+}
+
+// QueryContext measures the duration of the query until its rows are closed,
+// as the rows are streamed while they are read.
+func (db *slowQueryDB) QueryContext(ctx context.Context, query string, args ...interface{}) (tagsql.Rows, error) {
+	start := time.Now()
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		db.check(ctx, query, args, time.Since(start))
+		return nil, err
+	}
+	return &slowQueryRows{
+		Rows:  rows,
+		db:    db,
+		ctx:   ctx,
+		query: query,
+		args:  args,
+		start: start,
+	}, nil
+}
+
+func (db *slowQueryDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	db.check(ctx, query, args, time.Since(start))
+	return row
+}
+
+// check logs the query if it is slower than the threshold.
+func (db *slowQueryDB) check(ctx context.Context, query string, args []interface{}, duration time.Duration) {
+	if duration < db.config.Threshold {
+		return
+	}
+	mon.Counter("slow_queries").Inc(1)
+
+	fields := []zap.Field{
+		zap.Duration("Duration", duration),
+		zap.String("Query", strings.Join(strings.Fields(query), " ")),
+		zap.Strings("Args", redactArgs(args)),
+	}
+	if !db.config.Explain || !isSelectQuery(query) {
+		db.log.Warn("slow query", fields...)
+		return
+	}
+
+	// Plans are captured in the background, so that the request is not
+	// slowed down further. Captures are skipped while another one runs.
+	select {
+	case db.explaining <- struct{}{}:
+	default:
+		db.log.Warn("slow query", fields...)
+		return
+	}
+	go func() {
+		defer func() { <-db.explaining }()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), explainTimeout)
+		defer cancel()
+
+		plan, err := db.explain(ctx, query, args)
+		if err != nil {
+			fields = append(fields, zap.NamedError("ExplainError", err))
+		} else {
+			fields = append(fields, zap.String("Plan", plan))
+		}
+		db.log.Warn("slow query", fields...)
+	}()
+}
+
+// explain runs the query with EXPLAIN ANALYZE, and returns its plan.
+func (db *slowQueryDB) explain(ctx context.Context, query string, args []interface{}) (_ string, err error) {
+	rows, err := db.DB.QueryContext(ctx, "EXPLAIN ANALYZE "+query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// slowQueryRows checks the duration of a query when its rows are closed.
+type slowQueryRows struct {
+	tagsql.Rows
+
+	db     *slowQueryDB
+	ctx    context.Context
+	query  string
+	args   []interface{}
+	start  time.Time
+	closed bool
+}
+
+func (rows *slowQueryRows) Close() error {
+	err := rows.Rows.Close()
+	if !rows.closed {
+		rows.closed = true
+		rows.db.check(rows.ctx, rows.query, rows.args, time.Since(rows.start))
+	}
+	return err
+}
+
+// isSelectQuery returns true if the query only reads data, so that running it
+// again with EXPLAIN ANALYZE has no side effects.
+func isSelectQuery(query string) bool {
+	fields := strings.Fields(query)
+	return len(fields) > 0 && strings.EqualFold(fields[0], "SELECT")
+}
+
+// redactArgs formats bind parameters for logging. Numbers, booleans and
+// times are kept, as they are limits, statuses and cursors. Other values may
+// contain object keys or metadata, and are replaced with their type and
+// size.
+func redactArgs(args []interface{}) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case nil:
+			redacted[i] = "NULL"
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			redacted[i] = fmt.Sprint(arg)
+		case time.Time:
+			redacted[i] = arg.Format(time.RFC3339Nano)
+		case []byte:
+			redacted[i] = fmt.Sprintf("<%d bytes>", len(arg))
+		case string:
+			redacted[i] = fmt.Sprintf("<%d bytes>", len(arg))
+		default:
+			redacted[i] = fmt.Sprintf("<%T>", arg)
+		}
+	}
+	return redacted
+}
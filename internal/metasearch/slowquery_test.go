@@ -0,0 +1,75 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"storj.io/storj/shared/tagsql"
+)
+
+// delayedDB is a database whose statements take a fixed duration.
+type delayedDB struct {
+	tagsql.DB
+
+	delay time.Duration
+}
+
+func (db *delayedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	time.Sleep(db.delay)
+	return nil, nil
+}
+
+func TestLogSlowQueries(t *testing.T) {
+	ctx := context.Background()
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	// Slow query logging is disabled with a zero threshold
+	inner := &delayedDB{}
+	require.Same(t, inner, LogSlowQueries(inner, zap.New(core), SlowQueryConfig{}))
+
+	db := LogSlowQueries(inner, zap.New(core), SlowQueryConfig{Threshold: 20 * time.Millisecond})
+	_, err := db.ExecContext(ctx, "UPDATE objects SET clear_metadata = $1 WHERE object_key = $2", `{"secret": 1}`, []byte("foo.txt"))
+	require.NoError(t, err)
+	require.Empty(t, logs.TakeAll())
+
+	inner.delay = 30 * time.Millisecond
+	_, err = db.ExecContext(ctx, `
+		UPDATE objects
+		SET clear_metadata = $1
+		WHERE object_key = $2 AND version = $3`, `{"secret": 1}`, []byte("foo.txt"), 5)
+	require.NoError(t, err)
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	require.Equal(t, "slow query", entries[0].Message)
+	fields := entries[0].ContextMap()
+	require.Equal(t, "UPDATE objects SET clear_metadata = $1 WHERE object_key = $2 AND version = $3", fields["Query"])
+	require.Equal(t, []interface{}{"<13 bytes>", "<7 bytes>", "5"}, fields["Args"])
+	require.NotContains(t, fields, "Plan")
+}
+
+func TestRedactArgs(t *testing.T) {
+	queuedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var expiresAt *time.Time
+	require.Equal(t,
+		[]string{"NULL", "true", "42", "2025-03-01T12:00:00Z", "<3 bytes>", "<6 bytes>", "<*time.Time>"},
+		redactArgs([]interface{}{nil, true, int64(42), queuedAt, []byte("foo"), "secret", expiresAt}))
+}
+
+func TestIsSelectQuery(t *testing.T) {
+	require.True(t, isSelectQuery("\n\t\tSELECT project_id FROM objects"))
+	require.True(t, isSelectQuery("select 1"))
+	require.False(t, isSelectQuery("WITH updated AS (UPDATE objects SET clear_metadata = NULL RETURNING *) SELECT * FROM updated"))
+	require.False(t, isSelectQuery("UPDATE objects SET clear_metadata = NULL"))
+	require.False(t, isSelectQuery(""))
+}
@@ -0,0 +1,111 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Sort orders of search results.
+const (
+	SortAscending  = "asc"
+	SortDescending = "desc"
+)
+
+// SearchSort orders search results by a clear metadata value instead of by
+// encrypted object key.
+type SearchSort struct {
+	Key   string `json:"key"`
+	Order string `json:"order,omitempty"`
+}
+
+// MetadataSort orders the results of QueryMetadata by a clear metadata value.
+// Objects without the key are sorted as JSON null, i.e. first in ascending
+// order. Objects with the same value remain ordered by key.
+type MetadataSort struct {
+	Key        string
+	Descending bool
+
+	// StartAfter is the JSON encoded sort value of the startAfter object of
+	// QueryMetadata. It is empty on the first page.
+	StartAfter string
+}
+
+// metadataSort validates the sort order of a search request.
+func (s *SearchSort) metadataSort() (*MetadataSort, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if s.Key == "" {
+		return nil, fmt.Errorf("%w: sort key is missing", ErrBadRequest)
+	}
+
+	sort := &MetadataSort{Key: s.Key}
+	switch s.Order {
+	case "", SortAscending:
+	case SortDescending:
+		sort.Descending = true
+	default:
+		return nil, fmt.Errorf("%w: sort order must be '%s' or '%s'", ErrBadRequest, SortAscending, SortDescending)
+	}
+	return sort, nil
+}
+
+// after returns the sort order of the objects following obj.
+func (s *MetadataSort) after(obj ObjectInfo) (*MetadataSort, error) {
+	if s == nil {
+		return nil, nil
+	}
+	value, err := json.Marshal(obj.Metadata.ClearMetadata[s.Key])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	next := *s
+	next.StartAfter = string(value)
+	return &next, nil
+}
+
+// sameOrder returns true if both sort orders are the same, regardless of
+// their position.
+func (s *MetadataSort) sameOrder(other *MetadataSort) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return s.Key == other.Key && s.Descending == other.Descending
+}
+
+// sortValue returns the SQL expression of the sort value.
+func (b *matchQueryBuilder) sortValue(sort *MetadataSort) string {
+	return fmt.Sprintf("COALESCE(clear_metadata -> %s, 'null'::JSONB)", b.arg(sort.Key))
+}
+
+// sortedPageRange returns the conditions that restrict a sorted search to the
+// key prefix and to the objects after the previous page.
+func (b *matchQueryBuilder) sortedPageRange(loc ObjectLocation, sort *MetadataSort, startAfter ObjectLocation) string {
+	query := b.pageRange(loc, ObjectLocation{})
+	if sort.StartAfter == "" || startAfter.ProjectID.IsZero() {
+		return query
+	}
+
+	value := b.sortValue(sort)
+	operator := ">"
+	if sort.Descending {
+		operator = "<"
+	}
+	after := b.arg(sort.StartAfter) + "::JSONB"
+	query += fmt.Sprintf("\nAND (%s %s %s OR (%s = %s AND (project_id, bucket_name, object_key, version) > (%s, %s, %s, %s)))",
+		value, operator, after, value, after,
+		b.arg(loc.ProjectID), b.arg([]byte(loc.BucketName)), b.arg([]byte(startAfter.ObjectKey)), b.arg(startAfter.Version))
+	return query
+}
+
+// sortedOrder returns the ORDER BY expressions of a sorted search.
+func (b *matchQueryBuilder) sortedOrder(sort *MetadataSort) string {
+	order := b.sortValue(sort)
+	if sort.Descending {
+		order += " DESC"
+	}
+	return order + ", project_id, bucket_name, object_key, version"
+}
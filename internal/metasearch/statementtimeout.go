@@ -0,0 +1,389 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"storj.io/common/uuid"
+)
+
+// errStatementTimeout is the cause of the cancellation of repository calls
+// that exceed the statement timeout.
+var errStatementTimeout = errors.New("statement timeout")
+
+// statementTimeoutRepo bounds the duration of every repository call, so that
+// a pathological query cannot hold a metabase connection for minutes. Calls
+// that exceed the timeout fail with ErrServiceUnavailable. Reading the
+// migration queue and rebuilding indexes are long-running by design, and not
+// bounded.
+type statementTimeoutRepo struct {
+	MetaSearchRepo
+
+	timeout time.Duration
+}
+
+func newStatementTimeoutRepo(repo MetaSearchRepo, timeout time.Duration) *statementTimeoutRepo {
+	return &statementTimeoutRepo{
+		MetaSearchRepo: repo,
+		timeout:        timeout,
+	}
+}
+
+// call runs a repository call with the statement timeout.
+func (r *statementTimeoutRepo) call(ctx context.Context, method string, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeoutCause(ctx, r.timeout, errStatementTimeout)
+	defer cancel()
+
+	return r.timeoutError(ctx, method, fn(ctx))
+}
+
+// timeoutError returns ErrServiceUnavailable if a call failed because it
+// exceeded the statement timeout.
+func (r *statementTimeoutRepo) timeoutError(ctx context.Context, method string, err error) error {
+	if err != nil && errors.Is(context.Cause(ctx), errStatementTimeout) {
+		mon.Counter("statement_timeouts").Inc(1)
+		return fmt.Errorf("%w: %s exceeded the statement timeout of %s", ErrServiceUnavailable, method, r.timeout)
+	}
+	return err
+}
+
+func (r *statementTimeoutRepo) GetMetadata(ctx context.Context, loc ObjectLocation) (ObjectInfo, error) {
+	var obj ObjectInfo
+	err := r.call(ctx, "GetMetadata", func(ctx context.Context) (err error) {
+		obj, err = r.MetaSearchRepo.GetMetadata(ctx, loc)
+		return err
+	})
+	return obj, err
+}
+
+func (r *statementTimeoutRepo) QueryMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int) (QueryMetadataResult, error) {
+	var result QueryMetadataResult
+	err := r.call(ctx, "QueryMetadata", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.QueryMetadata(ctx, loc, containsQuery, sort, startAfter, asOf, batchSize)
+		return err
+	})
+	return result, err
+}
+
+// QueryMetadataFunc bounds the time spent reading the objects from the
+// database. The time spent in fn, e.g. streaming the results to a slow
+// client, does not count towards the statement timeout.
+func (r *statementTimeoutRepo) QueryMetadataFunc(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, sort *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int, fn ObjectFunc) (QueryMetadataResult, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	remaining := r.timeout
+	resumed := time.Now()
+	timer := time.AfterFunc(remaining, func() { cancel(errStatementTimeout) })
+	defer timer.Stop()
+
+	result, err := r.MetaSearchRepo.QueryMetadataFunc(ctx, loc, containsQuery, sort, startAfter, asOf, batchSize, func(obj ObjectInfo) error {
+		// Pause the timeout while fn runs
+		if !timer.Stop() {
+			return context.Cause(ctx)
+		}
+		remaining -= time.Since(resumed)
+		defer func() {
+			resumed = time.Now()
+			timer.Reset(remaining)
+		}()
+		return fn(obj)
+	})
+	return result, r.timeoutError(ctx, "QueryMetadataFunc", err)
+}
+
+func (r *statementTimeoutRepo) CountMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}) (int64, error) {
+	var n int64
+	err := r.call(ctx, "CountMetadata", func(ctx context.Context) (err error) {
+		n, err = r.MetaSearchRepo.CountMetadata(ctx, loc, containsQuery)
+		return err
+	})
+	return n, err
+}
+
+func (r *statementTimeoutRepo) AggregateMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, aggregation MetadataAggregation) ([]AggregateGroup, error) {
+	var result []AggregateGroup
+	err := r.call(ctx, "AggregateMetadata", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.AggregateMetadata(ctx, loc, containsQuery, aggregation)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) RollupMetadata(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, groupBy []string, limit int) ([]RollupRow, error) {
+	var result []RollupRow
+	err := r.call(ctx, "RollupMetadata", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.RollupMetadata(ctx, loc, containsQuery, groupBy, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) SampleMetadata(ctx context.Context, loc ObjectLocation, limit int) ([]map[string]interface{}, error) {
+	var result []map[string]interface{}
+	err := r.call(ctx, "SampleMetadata", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.SampleMetadata(ctx, loc, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) TopMetadataValues(ctx context.Context, loc ObjectLocation, key string, prefix string, sample int, limit int) ([]ValueCount, error) {
+	var result []ValueCount
+	err := r.call(ctx, "TopMetadataValues", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.TopMetadataValues(ctx, loc, key, prefix, sample, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) QueryProjectsMetadata(ctx context.Context, projectIDs []uuid.UUID, bucket string, containsQuery map[string]interface{}, limit int) ([]ObjectInfo, error) {
+	var result []ObjectInfo
+	err := r.call(ctx, "QueryProjectsMetadata", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.QueryProjectsMetadata(ctx, projectIDs, bucket, containsQuery, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) UpdateMetadata(ctx context.Context, loc ObjectLocation, meta ObjectMetadata) error {
+	return r.call(ctx, "UpdateMetadata", func(ctx context.Context) error {
+		return r.MetaSearchRepo.UpdateMetadata(ctx, loc, meta)
+	})
+}
+
+func (r *statementTimeoutRepo) UpdateMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}, meta ObjectMetadata) error {
+	return r.call(ctx, "UpdateMetadataIfMatch", func(ctx context.Context) error {
+		return r.MetaSearchRepo.UpdateMetadataIfMatch(ctx, loc, expected, meta)
+	})
+}
+
+func (r *statementTimeoutRepo) DeleteMetadata(ctx context.Context, loc ObjectLocation) error {
+	return r.call(ctx, "DeleteMetadata", func(ctx context.Context) error {
+		return r.MetaSearchRepo.DeleteMetadata(ctx, loc)
+	})
+}
+
+func (r *statementTimeoutRepo) SoftDeleteMetadata(ctx context.Context, loc ObjectLocation) error {
+	return r.call(ctx, "SoftDeleteMetadata", func(ctx context.Context) error {
+		return r.MetaSearchRepo.SoftDeleteMetadata(ctx, loc)
+	})
+}
+
+func (r *statementTimeoutRepo) SoftDeleteMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}) error {
+	return r.call(ctx, "SoftDeleteMetadataIfMatch", func(ctx context.Context) error {
+		return r.MetaSearchRepo.SoftDeleteMetadataIfMatch(ctx, loc, expected)
+	})
+}
+
+func (r *statementTimeoutRepo) RestoreMetadata(ctx context.Context, loc ObjectLocation, deletedAfter time.Time) error {
+	return r.call(ctx, "RestoreMetadata", func(ctx context.Context) error {
+		return r.MetaSearchRepo.RestoreMetadata(ctx, loc, deletedAfter)
+	})
+}
+
+func (r *statementTimeoutRepo) PurgeTombstones(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+	err := r.call(ctx, "PurgeTombstones", func(ctx context.Context) (err error) {
+		n, err = r.MetaSearchRepo.PurgeTombstones(ctx, deletedBefore)
+		return err
+	})
+	return n, err
+}
+
+func (r *statementTimeoutRepo) GetMetadataHistory(ctx context.Context, loc ObjectLocation, before int64, limit int) ([]MetadataRevision, error) {
+	var result []MetadataRevision
+	err := r.call(ctx, "GetMetadataHistory", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.GetMetadataHistory(ctx, loc, before, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) GetChanges(ctx context.Context, projectID uuid.UUID, bucket string, afterWatermark int64, afterRevision int64, limit int) ([]MetadataChange, error) {
+	var result []MetadataChange
+	err := r.call(ctx, "GetChanges", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.GetChanges(ctx, projectID, bucket, afterWatermark, afterRevision, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) DeleteExpiredMetadata(ctx context.Context, limit int) ([]ObjectLocation, error) {
+	var result []ObjectLocation
+	err := r.call(ctx, "DeleteExpiredMetadata", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.DeleteExpiredMetadata(ctx, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) GetWatermark(ctx context.Context, projectID uuid.UUID, bucket string) (Watermark, error) {
+	var watermark Watermark
+	err := r.call(ctx, "GetWatermark", func(ctx context.Context) (err error) {
+		watermark, err = r.MetaSearchRepo.GetWatermark(ctx, projectID, bucket)
+		return err
+	})
+	return watermark, err
+}
+
+func (r *statementTimeoutRepo) GetVocabularies(ctx context.Context, projectID uuid.UUID) (map[string]Vocabulary, error) {
+	var result map[string]Vocabulary
+	err := r.call(ctx, "GetVocabularies", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.GetVocabularies(ctx, projectID)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) SetVocabulary(ctx context.Context, projectID uuid.UUID, key string, vocabulary Vocabulary) error {
+	return r.call(ctx, "SetVocabulary", func(ctx context.Context) error {
+		return r.MetaSearchRepo.SetVocabulary(ctx, projectID, key, vocabulary)
+	})
+}
+
+func (r *statementTimeoutRepo) DeleteVocabulary(ctx context.Context, projectID uuid.UUID, key string) error {
+	return r.call(ctx, "DeleteVocabulary", func(ctx context.Context) error {
+		return r.MetaSearchRepo.DeleteVocabulary(ctx, projectID, key)
+	})
+}
+
+func (r *statementTimeoutRepo) CreateJob(ctx context.Context, job Job) error {
+	return r.call(ctx, "CreateJob", func(ctx context.Context) error {
+		return r.MetaSearchRepo.CreateJob(ctx, job)
+	})
+}
+
+func (r *statementTimeoutRepo) GetJob(ctx context.Context, id uuid.UUID) (Job, error) {
+	var job Job
+	err := r.call(ctx, "GetJob", func(ctx context.Context) (err error) {
+		job, err = r.MetaSearchRepo.GetJob(ctx, id)
+		return err
+	})
+	return job, err
+}
+
+func (r *statementTimeoutRepo) ClaimJob(ctx context.Context, id uuid.UUID, now time.Time, leaseExpiresAt time.Time) (int64, error) {
+	var n int64
+	err := r.call(ctx, "ClaimJob", func(ctx context.Context) (err error) {
+		n, err = r.MetaSearchRepo.ClaimJob(ctx, id, now, leaseExpiresAt)
+		return err
+	})
+	return n, err
+}
+
+func (r *statementTimeoutRepo) SaveJobProgress(ctx context.Context, job Job, results []byte) error {
+	return r.call(ctx, "SaveJobProgress", func(ctx context.Context) error {
+		return r.MetaSearchRepo.SaveJobProgress(ctx, job, results)
+	})
+}
+
+func (r *statementTimeoutRepo) GetJobResults(ctx context.Context, id uuid.UUID, fromChunk int64, limit int) ([][]byte, error) {
+	var result [][]byte
+	err := r.call(ctx, "GetJobResults", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.GetJobResults(ctx, id, fromChunk, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) DeleteJob(ctx context.Context, id uuid.UUID) error {
+	return r.call(ctx, "DeleteJob", func(ctx context.Context) error {
+		return r.MetaSearchRepo.DeleteJob(ctx, id)
+	})
+}
+
+func (r *statementTimeoutRepo) DeleteExpiredJobs(ctx context.Context, now time.Time) (int64, error) {
+	var n int64
+	err := r.call(ctx, "DeleteExpiredJobs", func(ctx context.Context) (err error) {
+		n, err = r.MetaSearchRepo.DeleteExpiredJobs(ctx, now)
+		return err
+	})
+	return n, err
+}
+
+func (r *statementTimeoutRepo) CreateSchedule(ctx context.Context, schedule Schedule) error {
+	return r.call(ctx, "CreateSchedule", func(ctx context.Context) error {
+		return r.MetaSearchRepo.CreateSchedule(ctx, schedule)
+	})
+}
+
+func (r *statementTimeoutRepo) GetSchedule(ctx context.Context, id uuid.UUID) (Schedule, error) {
+	var schedule Schedule
+	err := r.call(ctx, "GetSchedule", func(ctx context.Context) (err error) {
+		schedule, err = r.MetaSearchRepo.GetSchedule(ctx, id)
+		return err
+	})
+	return schedule, err
+}
+
+func (r *statementTimeoutRepo) ListSchedules(ctx context.Context, projectID uuid.UUID, bucket string) ([]Schedule, error) {
+	var result []Schedule
+	err := r.call(ctx, "ListSchedules", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.ListSchedules(ctx, projectID, bucket)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) ListProjectSchedules(ctx context.Context, projectID uuid.UUID) ([]Schedule, error) {
+	var result []Schedule
+	err := r.call(ctx, "ListProjectSchedules", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.ListProjectSchedules(ctx, projectID)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) DeleteSchedule(ctx context.Context, id uuid.UUID) error {
+	return r.call(ctx, "DeleteSchedule", func(ctx context.Context) error {
+		return r.MetaSearchRepo.DeleteSchedule(ctx, id)
+	})
+}
+
+func (r *statementTimeoutRepo) ClaimDueSchedules(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]Schedule, error) {
+	var result []Schedule
+	err := r.call(ctx, "ClaimDueSchedules", func(ctx context.Context) (err error) {
+		result, err = r.MetaSearchRepo.ClaimDueSchedules(ctx, now, leaseUntil, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *statementTimeoutRepo) SaveScheduleRun(ctx context.Context, schedule Schedule) error {
+	return r.call(ctx, "SaveScheduleRun", func(ctx context.Context) error {
+		return r.MetaSearchRepo.SaveScheduleRun(ctx, schedule)
+	})
+}
+
+func (r *statementTimeoutRepo) MigrateMetadata(ctx context.Context, obj ObjectInfo) error {
+	return r.call(ctx, "MigrateMetadata", func(ctx context.Context) error {
+		return r.MetaSearchRepo.MigrateMetadata(ctx, obj)
+	})
+}
+
+func (r *statementTimeoutRepo) CountObjectsForMigration(ctx context.Context, projectID uuid.UUID, startTime *time.Time) (int64, error) {
+	var n int64
+	err := r.call(ctx, "CountObjectsForMigration", func(ctx context.Context) (err error) {
+		n, err = r.MetaSearchRepo.CountObjectsForMigration(ctx, projectID, startTime)
+		return err
+	})
+	return n, err
+}
+
+func (r *statementTimeoutRepo) AcquireLease(ctx context.Context, name string, holder uuid.UUID, now time.Time, expiresAt time.Time) error {
+	return r.call(ctx, "AcquireLease", func(ctx context.Context) error {
+		return r.MetaSearchRepo.AcquireLease(ctx, name, holder, now, expiresAt)
+	})
+}
+
+func (r *statementTimeoutRepo) ReleaseLease(ctx context.Context, name string, holder uuid.UUID) error {
+	return r.call(ctx, "ReleaseLease", func(ctx context.Context) error {
+		return r.MetaSearchRepo.ReleaseLease(ctx, name, holder)
+	})
+}
@@ -0,0 +1,89 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// wantsNDJSON reports whether the client accepts a newline delimited JSON
+// response.
+func wantsNDJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Accept"))
+	return err == nil && mediaType == ndjsonContentType
+}
+
+// streamSearch writes all pages of the search results as newline delimited
+// JSON. Each page is flushed to the client before the next one is fetched,
+// and the search stops as soon as the client disconnects.
+func (s *Server) streamSearch(w http.ResponseWriter, r *http.Request, request *SearchRequest) {
+	ctx := r.Context()
+	flusher, _ := w.(http.Flusher)
+	s.extendWriteDeadline(w, r)
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	for {
+		result, err := s.searchMetadata(ctx, request)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.Logger.Warn("error during streaming search", zap.Error(err))
+
+			// The status is already sent, so report the error in the stream.
+			var e *ErrorResponse
+			if !errors.As(err, &e) {
+				e = ErrInternalError
+			}
+			_ = enc.Encode(e)
+			return
+		}
+
+		for _, res := range result.Results {
+			if err := enc.Encode(res); err != nil {
+				// The client has disconnected.
+				return
+			}
+		}
+		for _, prefix := range result.CommonPrefixes {
+			// Common prefixes are not repeated across pages
+			if err := enc.Encode(struct {
+				Prefix string `json:"prefix"`
+			}{prefix}); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if result.Truncated {
+			// Report where the search can be resumed
+			_ = enc.Encode(struct {
+				Truncated bool   `json:"truncated"`
+				PageToken string `json:"pageToken,omitempty"`
+			}{true, result.PageToken})
+			return
+		}
+		if result.PageToken == "" || ctx.Err() != nil {
+			return
+		}
+
+		request.startAfter, request.asOf, request.sort, err = parseSortedPageToken(result.PageToken)
+		if err != nil {
+			_ = enc.Encode(ErrInternalError)
+			return
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// maxSuggestSample is the maximum number of objects whose values are
+	// counted for suggestions.
+	maxSuggestSample = 10000
+
+	// defaultSuggestLimit is the default number of suggestions.
+	defaultSuggestLimit = 10
+)
+
+// SuggestResponse contains the suggested values of a metadata key, the most
+// frequent values first.
+type SuggestResponse struct {
+	Suggestions []ValueCount `json:"suggestions"`
+}
+
+// HandleSuggest returns the most frequent string values of a metadata key
+// that start with a prefix, for type-ahead in tagging UIs. Parameters are
+// passed in the URL query string.
+func (s *Server) HandleSuggest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request BaseRequest
+
+	err := s.validateRequest(ctx, r, &request, nil)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	key := q.Get("key")
+	if key == "" {
+		s.errorResponse(w, fmt.Errorf("%w: key is missing", ErrBadRequest))
+		return
+	}
+	limit := defaultSuggestLimit
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxBatchSize {
+			s.errorResponse(w, fmt.Errorf("%w: invalid limit", ErrBadRequest))
+			return
+		}
+	}
+
+	suggestions, err := s.Repo.TopMetadataValues(ctx, request.EncryptedLocation, key, q.Get("prefix"), maxSuggestSample, limit)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	s.jsonResponse(w, http.StatusOK, SuggestResponse{Suggestions: suggestions})
+}
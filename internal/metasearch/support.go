@@ -0,0 +1,203 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+)
+
+const (
+	maxSupportSearchProjects  = 100
+	defaultSupportSearchLimit = 100
+)
+
+// SupportSearchRequest contains fields for a search across projects by an
+// operator.
+type SupportSearchRequest struct {
+	ProjectIDs    []uuid.UUID            `json:"projectIds"`
+	Bucket        string                 `json:"bucket,omitempty"`
+	Match         map[string]interface{} `json:"match,omitempty"`
+	Limit         int                    `json:"limit,omitempty"`
+	Justification string                 `json:"justification"`
+}
+
+// SupportSearchResult contains fields for a single result of a search across
+// projects. Object keys are not decrypted.
+type SupportSearchResult struct {
+	ProjectID    uuid.UUID              `json:"projectId"`
+	Bucket       string                 `json:"bucket"`
+	EncryptedKey []byte                 `json:"encryptedKey"`
+	Version      int64                  `json:"version"`
+	Metadata     map[string]interface{} `json:"metadata"`
+}
+
+// SupportSearchResponse contains fields for a search response across
+// projects.
+type SupportSearchResponse struct {
+	Results []SupportSearchResult `json:"results"`
+}
+
+func (r *MetabaseSearchRepository) QueryProjectsMetadata(ctx context.Context, projectIDs []uuid.UUID, bucket string, containsQuery map[string]interface{}, limit int) ([]ObjectInfo, error) {
+	match, err := parseMatch(containsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &matchQueryBuilder{
+		loc:        ObjectLocation{BucketName: bucket},
+		projectIDs: projectIDs,
+		dialect:    r.dialect,
+		tables:     r.tables,
+	}
+	query := `
+		SELECT
+			project_id, bucket_name, object_key, version, status,
+			clear_metadata
+		FROM ` + r.tables.objects() + `
+		WHERE `
+
+	subqueries, err := b.indexedSubqueries(match)
+	if err != nil {
+		return nil, err
+	}
+	if len(subqueries) > 0 {
+		query += `(project_id, bucket_name, object_key, version) IN (` + strings.Join(subqueries, "INTERSECT \n") + `) AND `
+	}
+
+	query += b.scope() + fmt.Sprintf(" AND status <> %s AND (expires_at IS NULL OR expires_at > now())", b.arg(statusPending))
+
+	conditions, err := b.conditions(match)
+	if err != nil {
+		return nil, err
+	}
+	for _, condition := range conditions {
+		query += "\nAND " + condition
+	}
+
+	if match.not != nil && match.not.matchesAll() {
+		query += "\nAND false"
+	} else if match.not != nil {
+		not, err := b.matchSet(*match.not)
+		if err != nil {
+			return nil, err
+		}
+		query += "\nAND (project_id, bucket_name, object_key, version) NOT IN " + not
+	}
+
+	query += fmt.Sprintf("\nORDER BY project_id, bucket_name, object_key, version LIMIT %s", b.arg(limit))
+
+	rows, err := r.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	objects := make([]ObjectInfo, 0, limit)
+	for rows.Next() {
+		var obj ObjectInfo
+		var clearMetadata *string
+		err = rows.Scan(
+			&obj.ProjectID, &obj.BucketName, &obj.ObjectKey, &obj.Version, &obj.Status,
+			&clearMetadata,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+
+		obj.Metadata.ClearMetadata, err = parseJSON(clearMetadata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+
+		objects = append(objects, obj)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return objects, nil
+}
+
+// HandleSupportSearch searches the clear metadata of several projects for
+// abuse investigations and support cases. Paths and metadata are not
+// decrypted. Every search is logged to the audit log with its justification.
+func (s *Server) HandleSupportSearch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request SupportSearchRequest
+
+	err := newJSONDecoder(r.Body).Decode(&request)
+	if err != nil {
+		if tooLarge := bodyTooLarge(err); tooLarge != nil {
+			s.errorResponse(w, tooLarge)
+			return
+		}
+		s.errorResponse(w, fmt.Errorf("%w: invalid request body", ErrBadRequest))
+		return
+	}
+
+	request.Justification = strings.TrimSpace(request.Justification)
+	switch {
+	case request.Justification == "":
+		s.errorResponse(w, fmt.Errorf("%w: justification is required", ErrBadRequest))
+		return
+	case len(request.ProjectIDs) == 0:
+		s.errorResponse(w, fmt.Errorf("%w: projectIds is required", ErrBadRequest))
+		return
+	case len(request.ProjectIDs) > maxSupportSearchProjects:
+		s.errorResponse(w, fmt.Errorf("%w: too many projects (max %d)", ErrBadRequest, maxSupportSearchProjects))
+		return
+	}
+
+	if request.Limit <= 0 {
+		request.Limit = defaultSupportSearchLimit
+	}
+	if request.Limit > maxBatchSize {
+		request.Limit = maxBatchSize
+	}
+
+	if request.Match == nil {
+		request.Match = make(map[string]interface{})
+	}
+	if _, err := parseMatch(request.Match); err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	s.Logger.Named("audit").Info("support search across projects",
+		zap.Stringers("Projects", request.ProjectIDs),
+		zap.String("Bucket", request.Bucket),
+		zap.Any("Match", request.Match),
+		zap.Int("Limit", request.Limit),
+		zap.String("Justification", request.Justification),
+		zap.String("ClientIP", clientIP(r)),
+	)
+
+	objects, err := s.Repo.QueryProjectsMetadata(ctx, request.ProjectIDs, request.Bucket, request.Match, request.Limit)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	response := SupportSearchResponse{
+		Results: make([]SupportSearchResult, 0, len(objects)),
+	}
+	for _, obj := range objects {
+		response.Results = append(response.Results, SupportSearchResult{
+			ProjectID:    obj.ProjectID,
+			Bucket:       obj.BucketName,
+			EncryptedKey: []byte(obj.ObjectKey),
+			Version:      obj.Version,
+			Metadata:     obj.Metadata.ClearMetadata,
+		})
+	}
+
+	s.jsonResponse(w, http.StatusOK, response)
+}
@@ -0,0 +1,286 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+)
+
+// SyncCursor is the position of an index sync in the change feed of a
+// bucket: the last change mirrored into the index.
+type SyncCursor struct {
+	ProjectID  uuid.UUID
+	BucketName string
+	Watermark  int64
+	Revision   int64
+}
+
+// SyncRepository is the part of the metabase an IndexSyncer reads the change
+// feed from.
+type SyncRepository interface {
+	// GetChangedBuckets returns the cursors of up to limit buckets of a
+	// sync, whose watermark is past the cursor. Buckets that were never
+	// synced have a zero cursor.
+	GetChangedBuckets(ctx context.Context, sync string, limit int) ([]SyncCursor, error)
+
+	// GetChanges returns the metadata changes in a bucket after a watermark
+	// and revision, ordered by watermark and revision.
+	GetChanges(ctx context.Context, projectID uuid.UUID, bucket string, afterWatermark int64, afterRevision int64, limit int) ([]MetadataChange, error)
+
+	// SaveSyncCursor saves the cursor of a bucket of a sync.
+	SaveSyncCursor(ctx context.Context, sync string, cursor SyncCursor) error
+}
+
+// IndexDocument is the clear metadata of an object in an external index.
+// Object keys are encrypted, like in the metabase, and base64 encoded.
+type IndexDocument struct {
+	ID         string                 `json:"id"`
+	ProjectID  uuid.UUID              `json:"projectId"`
+	BucketName string                 `json:"bucket"`
+	ObjectKey  string                 `json:"encryptedKey"`
+	Version    int64                  `json:"version"`
+	Watermark  int64                  `json:"watermark"`
+	ChangedAt  time.Time              `json:"changedAt"`
+	Metadata   map[string]interface{} `json:"metadata"`
+}
+
+// SearchIndex is an external search index, e.g. Elasticsearch or
+// Meilisearch, that mirrors the clear metadata of the metabase.
+type SearchIndex interface {
+	// Upsert adds or replaces documents in the index.
+	Upsert(ctx context.Context, docs []IndexDocument) error
+
+	// Delete removes documents from the index. Missing documents are
+	// ignored.
+	Delete(ctx context.Context, ids []string) error
+}
+
+// indexDocumentID returns the ID of the document of an object. IDs only
+// contain characters allowed by all indexes.
+func indexDocumentID(projectID uuid.UUID, bucket string, key string) string {
+	h := sha256.New()
+	h.Write(projectID.Bytes())
+	h.Write([]byte(bucket))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// IndexSyncConfig configures an IndexSyncer.
+type IndexSyncConfig struct {
+	// Name identifies the sync in the metabase, so that several indexes can
+	// be synced independently. Changing it syncs the index from scratch.
+	Name string
+	// Interval is the interval the change feed is polled at.
+	Interval time.Duration
+	// BatchSize is the number of changes mirrored per index request.
+	BatchSize int
+	// Buckets is the number of changed buckets synced per poll.
+	Buckets int
+}
+
+// IndexSyncer tails the change feed of all buckets, which records every
+// change of clear metadata in the same transaction as the change, and mirrors
+// it into an external search index. Changes are applied in order in each
+// bucket, and the cursor of a bucket is only saved after the index accepted
+// them, so a restarted sync resumes without losing changes. Changes may be
+// mirrored twice after a failure, which indexes handle as replacements.
+type IndexSyncer struct {
+	repo   SyncRepository
+	index  SearchIndex
+	log    *zap.Logger
+	config IndexSyncConfig
+}
+
+// NewIndexSyncer creates a new IndexSyncer.
+func NewIndexSyncer(repo SyncRepository, index SearchIndex, log *zap.Logger, config IndexSyncConfig) *IndexSyncer {
+	return &IndexSyncer{
+		repo:   repo,
+		index:  index,
+		log:    log,
+		config: config,
+	}
+}
+
+// Run syncs the index until ctx is canceled. Failures are logged and retried
+// at the next poll.
+func (s *IndexSyncer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		synced, more, err := s.Sync(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			s.log.Error("index sync failed", zap.Error(err))
+		}
+		if synced > 0 {
+			s.log.Debug("synced changes", zap.Int("Changes", synced))
+		}
+
+		// Keep up without waiting while changed buckets are left
+		if more {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync mirrors the pending changes of up to config.Buckets buckets into the
+// index. It returns the number of mirrored changes, and whether more buckets
+// may have changes. A bucket that cannot be synced does not hold back the
+// others.
+func (s *IndexSyncer) Sync(ctx context.Context) (synced int, more bool, err error) {
+	cursors, err := s.repo.GetChangedBuckets(ctx, s.config.Name, s.config.Buckets)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var errs []error
+	for _, cursor := range cursors {
+		n, err := s.syncBucket(ctx, cursor)
+		synced += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot sync bucket %s of project %s: %w", cursor.BucketName, cursor.ProjectID, err))
+		}
+	}
+	return synced, len(errs) == 0 && len(cursors) >= s.config.Buckets, errors.Join(errs...)
+}
+
+// syncBucket mirrors the changes of a bucket after its cursor.
+func (s *IndexSyncer) syncBucket(ctx context.Context, cursor SyncCursor) (synced int, err error) {
+	for {
+		changes, err := s.repo.GetChanges(ctx, cursor.ProjectID, cursor.BucketName, cursor.Watermark, cursor.Revision, s.config.BatchSize)
+		if err != nil {
+			return synced, err
+		}
+		if len(changes) == 0 {
+			return synced, nil
+		}
+
+		if err := s.apply(ctx, cursor, changes); err != nil {
+			return synced, err
+		}
+		synced += len(changes)
+
+		last := changes[len(changes)-1]
+		cursor.Watermark, cursor.Revision = last.Watermark, last.Revision
+		if err := s.repo.SaveSyncCursor(ctx, s.config.Name, cursor); err != nil {
+			return synced, err
+		}
+
+		if len(changes) < s.config.BatchSize {
+			return synced, nil
+		}
+	}
+}
+
+// apply mirrors a batch of changes of a bucket into the index. Only the last
+// change of each object is applied.
+func (s *IndexSyncer) apply(ctx context.Context, cursor SyncCursor, changes []MetadataChange) error {
+	latest := make(map[string]MetadataChange, len(changes))
+	for _, change := range changes {
+		latest[change.ObjectKey] = change
+	}
+
+	var upserts []IndexDocument
+	var deletes []string
+	for _, change := range changes {
+		if latest[change.ObjectKey].Revision != change.Revision {
+			continue
+		}
+
+		id := indexDocumentID(cursor.ProjectID, cursor.BucketName, change.ObjectKey)
+		if change.NewMetadata == nil {
+			deletes = append(deletes, id)
+			continue
+		}
+		upserts = append(upserts, IndexDocument{
+			ID:         id,
+			ProjectID:  cursor.ProjectID,
+			BucketName: cursor.BucketName,
+			ObjectKey:  base64.StdEncoding.EncodeToString([]byte(change.ObjectKey)),
+			Version:    change.Version,
+			Watermark:  change.Watermark,
+			ChangedAt:  change.ChangedAt,
+			Metadata:   change.NewMetadata,
+		})
+	}
+
+	if len(upserts) > 0 {
+		if err := s.index.Upsert(ctx, upserts); err != nil {
+			return err
+		}
+	}
+	if len(deletes) > 0 {
+		if err := s.index.Delete(ctx, deletes); err != nil {
+			return err
+		}
+	}
+	mon.Counter("index_sync_changes").Inc(int64(len(changes)))
+	return nil
+}
+
+func (r *MetabaseSearchRepository) GetChangedBuckets(ctx context.Context, sync string, limit int) ([]SyncCursor, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT w.project_id, w.bucket_name, COALESCE(c.watermark, 0), COALESCE(c.revision, 0)
+		FROM metasearch_watermarks w
+		LEFT JOIN metasearch_sync_cursors c ON
+			c.sync_name = $1 AND
+			(c.project_id, c.bucket_name) = (w.project_id, w.bucket_name)
+		WHERE c.watermark IS NULL OR w.watermark > c.watermark
+		LIMIT $2
+		`,
+		sync, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	var cursors []SyncCursor
+	for rows.Next() {
+		var cursor SyncCursor
+		var bucket []byte
+		if err := rows.Scan(&cursor.ProjectID, &bucket, &cursor.Watermark, &cursor.Revision); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		cursor.BucketName = string(bucket)
+		cursors = append(cursors, cursor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	return cursors, nil
+}
+
+func (r *MetabaseSearchRepository) SaveSyncCursor(ctx context.Context, sync string, cursor SyncCursor) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO metasearch_sync_cursors (sync_name, project_id, bucket_name, watermark, revision, synced_at)
+		VALUES ($1, $2, $3, $4, $5, now())
+		ON CONFLICT (sync_name, project_id, bucket_name) DO UPDATE
+		SET watermark = excluded.watermark, revision = excluded.revision, synced_at = excluded.synced_at
+		`,
+		sync, cursor.ProjectID, []byte(cursor.BucketName), cursor.Watermark, cursor.Revision,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+)
+
+// mockSyncRepo is a change feed backed by a MemoryRepository.
+type mockSyncRepo struct {
+	*MemoryRepository
+	cursors map[ObjectLocation]SyncCursor
+}
+
+func (r *mockSyncRepo) GetChangedBuckets(ctx context.Context, sync string, limit int) ([]SyncCursor, error) {
+	var cursors []SyncCursor
+	for bucket, watermark := range r.watermarks {
+		cursor, ok := r.cursors[bucket]
+		if !ok {
+			cursor = SyncCursor{ProjectID: bucket.ProjectID, BucketName: bucket.BucketName}
+		}
+		if watermark.Watermark > cursor.Watermark && len(cursors) < limit {
+			cursors = append(cursors, cursor)
+		}
+	}
+	return cursors, nil
+}
+
+func (r *mockSyncRepo) SaveSyncCursor(ctx context.Context, sync string, cursor SyncCursor) error {
+	r.cursors[ObjectLocation{ProjectID: cursor.ProjectID, BucketName: cursor.BucketName}] = cursor
+	return nil
+}
+
+// mockSearchIndex keeps the documents of an index in memory.
+type mockSearchIndex struct {
+	docs map[string]IndexDocument
+	err  error
+}
+
+func (m *mockSearchIndex) Upsert(ctx context.Context, docs []IndexDocument) error {
+	if m.err != nil {
+		return m.err
+	}
+	for _, doc := range docs {
+		m.docs[doc.ID] = doc
+	}
+	return nil
+}
+
+func (m *mockSearchIndex) Delete(ctx context.Context, ids []string) error {
+	if m.err != nil {
+		return m.err
+	}
+	for _, id := range ids {
+		delete(m.docs, id)
+	}
+	return nil
+}
+
+func TestIndexSyncer(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockSyncRepo{MemoryRepository: NewMemoryRepository(), cursors: make(map[ObjectLocation]SyncCursor)}
+	index := &mockSearchIndex{docs: make(map[string]IndexDocument)}
+	syncer := NewIndexSyncer(repo, index, zap.NewNop(), IndexSyncConfig{Name: "test", Interval: time.Second, BatchSize: 2, Buckets: 10})
+
+	projectID := uuid.UUID{1}
+	loc := func(key string) ObjectLocation {
+		return ObjectLocation{ProjectID: projectID, BucketName: "testbucket", ObjectKey: key}
+	}
+	update := func(key string, metadata map[string]interface{}) {
+		require.NoError(t, repo.UpdateMetadata(ctx, loc(key), ObjectMetadata{ClearMetadata: metadata}))
+	}
+
+	update("a", map[string]interface{}{"n": 1})
+	update("b", map[string]interface{}{"n": 2})
+	update("a", map[string]interface{}{"n": 3})
+
+	synced, more, err := syncer.Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, synced, 3)
+	assert.False(t, more)
+	require.Len(t, index.docs, 2)
+
+	doc := index.docs[indexDocumentID(projectID, "testbucket", "a")]
+	assert.Equal(t, doc.ObjectKey, "YQ==")
+	assert.Equal(t, doc.Metadata, map[string]interface{}{"n": 3})
+	assert.Equal(t, doc.Watermark, int64(3))
+
+	// Only the changes after the cursor are mirrored
+	require.NoError(t, repo.DeleteMetadata(ctx, loc("b")))
+	synced, _, err = syncer.Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, synced, 1)
+	require.Len(t, index.docs, 1)
+
+	synced, _, err = syncer.Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, synced, 0)
+
+	// The cursor is not saved if the index rejects the changes
+	update("c", map[string]interface{}{"n": 4})
+	index.err = errors.New("unavailable")
+	_, more, err = syncer.Sync(ctx)
+	require.Error(t, err)
+	assert.False(t, more)
+
+	index.err = nil
+	synced, _, err = syncer.Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, synced, 1)
+	require.Len(t, index.docs, 2)
+}
+
+func TestElasticsearchIndex(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/_bulk")
+		assert.Equal(t, r.Header.Get("Authorization"), "ApiKey secret")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			requests = append(requests, scanner.Text())
+		}
+
+		if len(requests) > 2 {
+			_, _ = io.WriteString(w, `{"errors": true, "items": [
+				{"delete": {"_id": "x", "status": 404}},
+				{"delete": {"_id": "y", "status": 429, "error": {"type": "es_rejected_execution_exception"}}}
+			]}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"errors": false, "items": [{"index": {"_id": "x", "status": 201}}]}`)
+	}))
+	defer server.Close()
+
+	index := NewElasticsearchIndex(server.URL+"/", "metasearch", "secret", time.Second)
+	err := index.Upsert(context.Background(), []IndexDocument{{ID: "x", Metadata: map[string]interface{}{"n": 1}}})
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	require.JSONEq(t, `{"index": {"_index": "metasearch", "_id": "x"}}`, requests[0])
+
+	var doc IndexDocument
+	require.NoError(t, json.Unmarshal([]byte(requests[1]), &doc))
+	assert.Equal(t, doc.ID, "x")
+
+	err = index.Delete(context.Background(), []string{"x", "y"})
+	require.ErrorContains(t, err, "cannot delete document y")
+	require.JSONEq(t, `{"delete": {"_index": "metasearch", "_id": "x"}}`, requests[2])
+}
+
+func TestMeilisearchIndex(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Header.Get("Authorization"), "Bearer secret")
+		paths = append(paths, r.URL.RequestURI())
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	index := NewMeilisearchIndex(server.URL, "metasearch", "secret", time.Second)
+	require.NoError(t, index.Upsert(context.Background(), []IndexDocument{{ID: "x"}}))
+	require.NoError(t, index.Delete(context.Background(), []string{"x"}))
+	assert.DeepEqual(t, paths, []string{
+		"/indexes/metasearch/documents?primaryKey=id",
+		"/indexes/metasearch/documents/delete-batch",
+	})
+
+	_, err := NewSearchIndex("solr", server.URL, "metasearch", "", time.Second)
+	require.Error(t, err)
+}
@@ -0,0 +1,224 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// systemKey is the match query key of conditions on the system attributes of
+// objects, e.g. {"$system": {"size": {"$gt": 1000}}}.
+const systemKey = "$system"
+
+// systemAttribute is an object attribute stored by the satellite, which can
+// be searched like metadata.
+type systemAttribute struct {
+	// column is the SQL expression of the attribute.
+	column string
+	// kind is the type of the operands: "number", "time" or "string".
+	kind string
+}
+
+// systemAttributes are the searchable system attributes by name.
+var systemAttributes = map[string]systemAttribute{
+	"size":        {column: "total_plain_size", kind: "number"},
+	"createdAt":   {column: "created_at", kind: "time"},
+	"expiresAt":   {column: "expires_at", kind: "time"},
+	"contentType": {column: "(clear_metadata ->> 'content-type')", kind: "string"},
+}
+
+// SystemMetadata contains the system attributes of an object in search
+// results.
+type SystemMetadata struct {
+	Size      int64      `json:"size"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Status    string     `json:"status"`
+}
+
+// objectStatuses are the names of the object statuses of the metabase.
+var objectStatuses = map[byte]string{
+	1:                          "pending",
+	statusCommittedUnversioned: "committedUnversioned",
+	statusCommittedVersioned:   "committedVersioned",
+	deleteMarkerUnversioned:    "deleteMarkerUnversioned",
+	deleteMarkerVersioned:      "deleteMarkerVersioned",
+}
+
+// systemMetadata returns the system attributes of an object.
+func systemMetadata(obj ObjectInfo) *SystemMetadata {
+	status, ok := objectStatuses[obj.Status]
+	if !ok {
+		status = "unknown"
+	}
+	return &SystemMetadata{
+		Size:      obj.Size,
+		CreatedAt: obj.CreatedAt,
+		ExpiresAt: obj.ExpiresAt,
+		Status:    status,
+	}
+}
+
+// systemCondition compares a system attribute with an operand.
+type systemCondition struct {
+	attribute systemAttribute
+	operator  string
+	value     interface{}
+}
+
+// parseSystemConditions parses the conditions on system attributes of a
+// match query. Attributes are compared with plain values for equality, or
+// with operator objects.
+func parseSystemConditions(v interface{}) ([]systemCondition, error) {
+	attributes, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: system must be an object", ErrBadRequest)
+	}
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var conditions []systemCondition
+	for _, name := range names {
+		attribute, ok := systemAttributes[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown system attribute '%s'", ErrBadRequest, name)
+		}
+
+		operators, ok := attributes[name].(map[string]interface{})
+		if !ok {
+			operators = map[string]interface{}{"": attributes[name]}
+		}
+
+		operatorNames := make([]string, 0, len(operators))
+		for operatorName := range operators {
+			operatorNames = append(operatorNames, operatorName)
+		}
+		sort.Strings(operatorNames)
+
+		for _, operatorName := range operatorNames {
+			condition, err := parseSystemCondition(name, attribute, operatorName, operators[operatorName])
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions, nil
+}
+
+func parseSystemCondition(name string, attribute systemAttribute, operatorName string, operand interface{}) (systemCondition, error) {
+	condition := systemCondition{attribute: attribute}
+
+	switch operatorName {
+	case "":
+		condition.operator = "="
+	case globOperator:
+		pattern, ok := operand.(string)
+		if !ok || attribute.kind != "string" {
+			return condition, fmt.Errorf("%w: '%s' is not supported for system attribute '%s'", ErrBadRequest, operatorName, name)
+		}
+		condition.operator = "LIKE"
+		condition.value = globToLike(pattern)
+		return condition, nil
+	default:
+		operator, ok := comparisonOperators[operatorName]
+		if !ok {
+			return condition, fmt.Errorf("%w: unknown operator '%s' in match query", ErrBadRequest, operatorName)
+		}
+		condition.operator = operator
+	}
+
+	switch attribute.kind {
+	case "number":
+		var n float64
+		switch operand := operand.(type) {
+		case float64:
+			n = operand
+		case json.Number:
+			var err error
+			n, err = operand.Float64()
+			if err != nil {
+				return condition, fmt.Errorf("%w: system attribute '%s' must be compared with a number", ErrBadRequest, name)
+			}
+		default:
+			return condition, fmt.Errorf("%w: system attribute '%s' must be compared with a number", ErrBadRequest, name)
+		}
+		condition.value = n
+	case "time":
+		s, ok := operand.(string)
+		if !ok {
+			return condition, fmt.Errorf("%w: system attribute '%s' must be compared with an RFC 3339 time", ErrBadRequest, name)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return condition, fmt.Errorf("%w: system attribute '%s' must be compared with an RFC 3339 time", ErrBadRequest, name)
+		}
+		condition.value = t
+	default:
+		s, ok := operand.(string)
+		if !ok {
+			return condition, fmt.Errorf("%w: system attribute '%s' must be compared with a string", ErrBadRequest, name)
+		}
+		condition.value = s
+	}
+	return condition, nil
+}
+
+// sql returns the SQL expression of a condition on a system attribute.
+func (c systemCondition) sql(b *matchQueryBuilder) string {
+	var cast string
+	switch c.attribute.kind {
+	case "number":
+		cast = "::FLOAT8"
+	case "time":
+		cast = "::TIMESTAMPTZ"
+	default:
+		cast = "::TEXT"
+	}
+	return fmt.Sprintf("%s %s %s%s", c.attribute.column, c.operator, b.arg(c.value), cast)
+}
+
+// matches returns true if the system attribute of an object satisfies the
+// condition, like its SQL expression.
+func (c systemCondition) matches(obj ObjectInfo) bool {
+	var result int
+	switch operand := c.value.(type) {
+	case float64:
+		result = cmp.Compare(float64(obj.Size), operand)
+	case time.Time:
+		t := &obj.CreatedAt
+		if c.attribute.column == systemAttributes["expiresAt"].column {
+			t = obj.ExpiresAt
+		}
+		if t == nil {
+			return false
+		}
+		result = t.Compare(operand)
+	case string:
+		s, ok := obj.Metadata.ClearMetadata["content-type"].(string)
+		if !ok {
+			return false
+		}
+		if c.operator == "LIKE" {
+			return likeToRegexp(operand).MatchString(s)
+		}
+		result = strings.Compare(s, operand)
+	default:
+		return false
+	}
+
+	if c.operator == "=" {
+		return result == 0
+	}
+	return compare(c.operator, result)
+}
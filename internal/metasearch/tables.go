@@ -0,0 +1,79 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+)
+
+// Default names of the objects table and its indexes in the metabase.
+const (
+	defaultObjectsTable       = "objects"
+	defaultPrimaryKey         = "objects_pkey"
+	defaultMetadataIndex      = "objects_clear_metadata_idx"
+	defaultMetadataLowerIndex = "objects_clear_metadata_lower_idx"
+	defaultQueuedAtIndex      = "objects_metasearch_queued_at_idx"
+)
+
+// identifierPattern matches unquoted SQL identifiers.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// qualifiedIdentifierPattern matches unquoted SQL identifiers, optionally
+// qualified with a schema.
+var qualifiedIdentifierPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*\.)?[A-Za-z_][A-Za-z0-9_]*$`)
+
+// MetabaseTables are the names of the objects table and its indexes used by
+// MetabaseSearchRepository. Empty names are the defaults of the satellite
+// metabase.
+type MetabaseTables struct {
+	// Objects is the objects table, optionally qualified with its schema,
+	// e.g. "metabase.objects".
+	Objects string
+	// PrimaryKey is the primary key index of the objects table.
+	PrimaryKey string
+	// MetadataIndex is the GIN index of the clear metadata, and
+	// MetadataLowerIndex the GIN index of the lower-cased clear metadata.
+	MetadataIndex      string
+	MetadataLowerIndex string
+	// QueuedAtIndex is the index of the objects queued for migration.
+	QueuedAtIndex string
+}
+
+// Validate checks that the names are valid SQL identifiers, as they are
+// inserted in queries as is.
+func (t MetabaseTables) Validate() error {
+	if t.Objects != "" && !qualifiedIdentifierPattern.MatchString(t.Objects) {
+		return fmt.Errorf("invalid objects table name '%s'", t.Objects)
+	}
+	for _, index := range []string{t.PrimaryKey, t.MetadataIndex, t.MetadataLowerIndex, t.QueuedAtIndex} {
+		if index != "" && !identifierPattern.MatchString(index) {
+			return fmt.Errorf("invalid index name '%s'", index)
+		}
+	}
+	return nil
+}
+
+// objects returns the name of the objects table.
+func (t MetabaseTables) objects() string {
+	return cmp.Or(t.Objects, defaultObjectsTable)
+}
+
+// index returns the configured name of the index whose default name is
+// name.
+func (t MetabaseTables) index(name string) string {
+	var configured string
+	switch name {
+	case defaultPrimaryKey:
+		configured = t.PrimaryKey
+	case defaultMetadataIndex:
+		configured = t.MetadataIndex
+	case defaultMetadataLowerIndex:
+		configured = t.MetadataLowerIndex
+	case defaultQueuedAtIndex:
+		configured = t.QueuedAtIndex
+	}
+	return cmp.Or(configured, name)
+}
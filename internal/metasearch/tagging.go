@@ -0,0 +1,259 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// tagsKey is the metadata key of the tag set of S3 tagging requests. Tags
+// are stored as an object of strings, e.g. {"tags": {"project": "apollo"}},
+// so that they can be searched like other metadata.
+const tagsKey = "tags"
+
+// Limits of S3 object tags.
+const (
+	maxObjectTags      = 10
+	maxTagKeyLength    = 128
+	maxTagValueLength  = 256
+	maxTaggingBodySize = 64 * 1024
+)
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// Tagging is the XML body of S3 object tagging requests and responses.
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	TagSet  []Tag    `xml:"TagSet>Tag"`
+}
+
+// Tag is a key-value pair of an S3 tag set.
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// s3Error is the XML body of S3 error responses.
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+// hasQuery matches requests with a query parameter, with or without a value,
+// like the ?tagging subresource of S3.
+func hasQuery(name string) mux.MatcherFunc {
+	return func(r *http.Request, _ *mux.RouteMatch) bool {
+		_, ok := r.URL.Query()[name]
+		return ok
+	}
+}
+
+// objectTags returns the tag set stored in metadata, sorted by key. Values
+// that are not strings are not tags and are omitted.
+func objectTags(metadata map[string]interface{}) []Tag {
+	tags, _ := metadata[tagsKey].(map[string]interface{})
+	tagSet := make([]Tag, 0, len(tags))
+	for key, value := range tags {
+		if s, ok := value.(string); ok {
+			tagSet = append(tagSet, Tag{Key: key, Value: s})
+		}
+	}
+	sort.Slice(tagSet, func(i, j int) bool {
+		return tagSet[i].Key < tagSet[j].Key
+	})
+	return tagSet
+}
+
+// parseTagging decodes and validates the tag set of a put tagging request.
+func parseTagging(r *http.Request) (map[string]interface{}, error) {
+	var tagging Tagging
+	err := xml.NewDecoder(io.LimitReader(r.Body, maxTaggingBodySize)).Decode(&tagging)
+	if err != nil {
+		if tooLarge := bodyTooLarge(err); tooLarge != nil {
+			return nil, tooLarge
+		}
+		return nil, fmt.Errorf("%w: error decoding tagging: %v", ErrBadRequest, err)
+	}
+
+	if len(tagging.TagSet) > maxObjectTags {
+		return nil, fmt.Errorf("%w: object tags cannot be greater than %d", ErrBadRequest, maxObjectTags)
+	}
+
+	tags := make(map[string]interface{}, len(tagging.TagSet))
+	for _, tag := range tagging.TagSet {
+		if tag.Key == "" || utf8.RuneCountInString(tag.Key) > maxTagKeyLength {
+			return nil, fmt.Errorf("%w: tag keys must be between 1 and %d characters", ErrBadRequest, maxTagKeyLength)
+		}
+		if utf8.RuneCountInString(tag.Value) > maxTagValueLength {
+			return nil, fmt.Errorf("%w: tag values cannot be longer than %d characters", ErrBadRequest, maxTagValueLength)
+		}
+		if _, ok := tags[tag.Key]; ok {
+			return nil, fmt.Errorf("%w: duplicate tag key '%s'", ErrBadRequest, tag.Key)
+		}
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+// HandleGetObjectTagging returns the tags of an object in the format of the
+// S3 GetObjectTagging API.
+func (s *Server) HandleGetObjectTagging(w http.ResponseWriter, r *http.Request) {
+	obj, _, err := s.getObject(r)
+	if err != nil {
+		s.s3ErrorResponse(w, err)
+		return
+	}
+
+	s.xmlResponse(w, http.StatusOK, Tagging{
+		Xmlns:  s3Namespace,
+		TagSet: objectTags(obj.Metadata.ClearMetadata),
+	})
+}
+
+// HandlePutObjectTagging replaces the tags of an object in the format of the
+// S3 PutObjectTagging API. Other metadata of the object is kept.
+func (s *Server) HandlePutObjectTagging(w http.ResponseWriter, r *http.Request) {
+	tags, err := parseTagging(r)
+	if err != nil {
+		s.s3ErrorResponse(w, err)
+		return
+	}
+
+	err = s.setObjectTags(r, tags)
+	if err != nil {
+		s.s3ErrorResponse(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleDeleteObjectTagging removes the tags of an object in the format of
+// the S3 DeleteObjectTagging API.
+func (s *Server) HandleDeleteObjectTagging(w http.ResponseWriter, r *http.Request) {
+	err := s.setObjectTags(r, nil)
+	if err != nil {
+		s.s3ErrorResponse(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setObjectTags replaces the tag set in the metadata of an object, or removes
+// it if tags is empty. The metadata is updated only if it did not change since
+// it was read, so that concurrent updates of other keys are not lost.
+func (s *Server) setObjectTags(r *http.Request, tags map[string]interface{}) error {
+	ctx := r.Context()
+	var request BaseRequest
+
+	if r.URL.Query().Get("versionId") != "" {
+		return fmt.Errorf("%w: only the tags of the latest version can be changed", ErrBadRequest)
+	}
+
+	err := s.validateRequest(ctx, r, &request, nil)
+	if err != nil {
+		return err
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionWriteMetadata)
+	if err != nil {
+		return err
+	}
+
+	obj, err := s.Repo.GetMetadata(ctx, request.EncryptedLocation)
+	if err != nil {
+		return err
+	}
+	s.migrateObject(ctx, &obj, request.Encryptor)
+
+	metadata := make(map[string]interface{}, len(obj.Metadata.ClearMetadata)+1)
+	for key, value := range obj.Metadata.ClearMetadata {
+		metadata[key] = value
+	}
+	if len(tags) > 0 {
+		metadata[tagsKey] = tags
+	} else {
+		delete(metadata, tagsKey)
+	}
+
+	err = s.checkVocabularies(ctx, request.Location.ProjectID, metadata)
+	if err != nil {
+		return err
+	}
+
+	meta := ObjectMetadata{
+		ClearMetadata: metadata,
+		ExpiresAt:     obj.Metadata.ExpiresAt,
+	}
+
+	err = request.Encryptor.EncryptMetadata(request.Location.BucketName, request.Location.ObjectKey, &meta)
+	if err != nil {
+		return fmt.Errorf("%w: cannot encrypt metadata", ErrBadRequest)
+	}
+
+	return s.Repo.UpdateMetadataIfMatch(ctx, request.EncryptedLocation, obj.Metadata.ClearMetadata, meta)
+}
+
+func (s *Server) xmlResponse(w http.ResponseWriter, status int, body interface{}) {
+	xmlBytes, err := xml.Marshal(body)
+	if err != nil {
+		s.s3ErrorResponse(w, fmt.Errorf("%w: %v", ErrInternalError, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(xmlBytes)
+}
+
+// s3ErrorResponse sends an error in the XML format of S3, with the S3 error
+// code closest to the status of the error.
+func (s *Server) s3ErrorResponse(w http.ResponseWriter, err error) {
+	s.Logger.Warn("error during S3 API request", zap.Error(err))
+
+	var e *ErrorResponse
+	if !errors.As(err, &e) {
+		e = ErrInternalError
+	}
+
+	code := "InternalError"
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		code = "InvalidArgument"
+	case http.StatusUnauthorized, http.StatusForbidden:
+		code = "AccessDenied"
+	case http.StatusNotFound:
+		code = "NoSuchKey"
+	case http.StatusConflict:
+		code = "OperationAborted"
+	case http.StatusPreconditionFailed:
+		code = "PreconditionFailed"
+	case http.StatusRequestEntityTooLarge:
+		code = "EntityTooLarge"
+	case http.StatusTooManyRequests:
+		code = "SlowDown"
+	case http.StatusServiceUnavailable:
+		code = "ServiceUnavailable"
+	}
+
+	resp, _ := xml.Marshal(s3Error{Code: code, Message: e.Message})
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(e.StatusCode)
+	w.Write([]byte(xml.Header))
+	w.Write(resp)
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+)
+
+func TestObjectTagging(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// No tags
+	rr = handleRequest(server, http.MethodGet, "/s3/testbucket/foo.txt?tagging", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, rr.Header().Get("Content-Type"), "application/xml")
+	var tagging Tagging
+	require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &tagging))
+	require.Empty(t, tagging.TagSet)
+
+	// Put
+	rr = handleRequest(server, http.MethodPut, "/s3/testbucket/foo.txt?tagging", `
+		<Tagging xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+			<TagSet>
+				<Tag><Key>project</Key><Value>apollo</Value></Tag>
+				<Tag><Key>env</Key><Value>prod</Value></Tag>
+			</TagSet>
+		</Tagging>`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = handleRequest(server, http.MethodGet, "/v1/s3/testbucket/foo.txt?tagging", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &tagging))
+	require.Equal(t, []Tag{{Key: "env", Value: "prod"}, {Key: "project", Value: "apollo"}}, tagging.TagSet)
+
+	// Tags are stored in the metadata, next to other keys
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &metadata))
+	require.Equal(t, map[string]interface{}{
+		"foo":  "bar",
+		"tags": map[string]interface{}{"env": "prod", "project": "apollo"},
+	}, metadata)
+
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{"filter": "tags.project == 'apollo'"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var search SearchResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &search))
+	require.Len(t, search.Results, 1)
+
+	// Put replaces the tag set
+	rr = handleRequest(server, http.MethodPut, "/s3/testbucket/foo.txt?tagging",
+		`<Tagging><TagSet><Tag><Key>env</Key><Value>dev</Value></Tag></TagSet></Tagging>`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = handleRequest(server, http.MethodGet, "/s3/testbucket/foo.txt?tagging", "")
+	require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &tagging))
+	require.Equal(t, []Tag{{Key: "env", Value: "dev"}}, tagging.TagSet)
+
+	// Delete
+	rr = handleRequest(server, http.MethodDelete, "/s3/testbucket/foo.txt?tagging", "")
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &metadata))
+	require.Equal(t, map[string]interface{}{"foo": "bar"}, metadata)
+
+	// The tagging subresource is required
+	rr = handleRequest(server, http.MethodGet, "/s3/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
+
+func TestObjectTaggingErrors(t *testing.T) {
+	server := testServer()
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	var tooMany strings.Builder
+	for i := 0; i <= maxObjectTags; i++ {
+		fmt.Fprintf(&tooMany, "<Tag><Key>k%d</Key><Value>v</Value></Tag>", i)
+	}
+
+	for _, body := range []string{
+		`<Tagging><TagSet>`,
+		`<Tagging><TagSet>` + tooMany.String() + `</TagSet></Tagging>`,
+		`<Tagging><TagSet><Tag><Key></Key><Value>v</Value></Tag></TagSet></Tagging>`,
+		`<Tagging><TagSet><Tag><Key>` + strings.Repeat("k", maxTagKeyLength+1) + `</Key><Value>v</Value></Tag></TagSet></Tagging>`,
+		`<Tagging><TagSet><Tag><Key>k</Key><Value>` + strings.Repeat("v", maxTagValueLength+1) + `</Value></Tag></TagSet></Tagging>`,
+		`<Tagging><TagSet><Tag><Key>k</Key><Value>1</Value></Tag><Tag><Key>k</Key><Value>2</Value></Tag></TagSet></Tagging>`,
+	} {
+		rr := handleRequest(server, http.MethodPut, "/s3/testbucket/foo.txt?tagging", body)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+		var s3err s3Error
+		require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &s3err))
+		assert.Equal(t, s3err.Code, "InvalidArgument")
+	}
+
+	// Missing object
+	rr = handleRequest(server, http.MethodGet, "/s3/testbucket/missing.txt?tagging", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+	var s3err s3Error
+	require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &s3err))
+	assert.Equal(t, s3err.Code, "NoSuchKey")
+
+	rr = handleRequest(server, http.MethodPut, "/s3/testbucket/missing.txt?tagging", `<Tagging><TagSet></TagSet></Tagging>`)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
@@ -0,0 +1,44 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// limitRequestDuration bounds the context of requests by the request timeout
+// of the server, so that searches stop when it is reached. WebSocket
+// connections are long-lived and not bounded.
+func (s *Server) limitRequestDuration(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Config.RequestTimeout <= 0 || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.Config.RequestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// extendWriteDeadline extends the write deadline of a streamed response, which
+// can take longer than the write timeout of the server, to the deadline of
+// the request.
+func (s *Server) extendWriteDeadline(w http.ResponseWriter, r *http.Request) {
+	if s.Config.WriteTimeout <= 0 {
+		return
+	}
+
+	// Without a request deadline, the write deadline is removed
+	deadline, _ := r.Context().Deadline()
+	err := http.NewResponseController(w).SetWriteDeadline(deadline)
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.Logger.Debug("unable to extend write deadline", zap.Error(err))
+	}
+}
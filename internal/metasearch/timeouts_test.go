@@ -0,0 +1,134 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+	"go.uber.org/zap"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	server := testServer()
+	server.Config.RequestTimeout = 50 * time.Millisecond
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	testRepo(server).queryDelay = time.Hour
+	start := time.Now()
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{}`)
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+	require.Less(t, time.Since(start), time.Minute)
+}
+
+func TestStatementTimeout(t *testing.T) {
+	repo := newMockRepo()
+	server, err := NewServer(zap.NewNop(), repo, &mockAuthenticator{}, ServerConfig{
+		StatementTimeout: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+
+	// Slow queries are canceled, even without a request timeout
+	repo.queryDelay = time.Hour
+	start := time.Now()
+	rr = handleRequest(server, http.MethodPost, "/metasearch/testbucket", `{}`)
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+	require.Less(t, time.Since(start), time.Minute)
+}
+
+// streamingRepo streams objects like a database cursor, reading each row in
+// rowDelay and failing once its context is canceled.
+type streamingRepo struct {
+	*mockRepo
+
+	rows     int
+	rowDelay time.Duration
+}
+
+func (r *streamingRepo) QueryMetadataFunc(ctx context.Context, loc ObjectLocation, containsQuery map[string]interface{}, order *MetadataSort, startAfter ObjectLocation, asOf time.Time, batchSize int, fn ObjectFunc) (QueryMetadataResult, error) {
+	for i := 0; i < r.rows; i++ {
+		select {
+		case <-time.After(r.rowDelay):
+		case <-ctx.Done():
+			return QueryMetadataResult{}, ctx.Err()
+		}
+		if err := fn(ObjectInfo{ObjectLocation: loc}); err != nil {
+			return QueryMetadataResult{}, err
+		}
+	}
+	return QueryMetadataResult{}, nil
+}
+
+func TestStatementTimeoutStreaming(t *testing.T) {
+	ctx := context.Background()
+	repo := &streamingRepo{mockRepo: newMockRepo(), rows: 5}
+	timeoutRepo := newStatementTimeoutRepo(repo, 100*time.Millisecond)
+
+	// Time spent in the callback is not counted
+	_, err := timeoutRepo.QueryMetadataFunc(ctx, ObjectLocation{}, nil, nil, ObjectLocation{}, time.Time{}, 0, func(obj ObjectInfo) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Time spent reading rows is counted across rows
+	repo.rowDelay = 40 * time.Millisecond
+	_, err = timeoutRepo.QueryMetadataFunc(ctx, ObjectLocation{}, nil, nil, ObjectLocation{}, time.Time{}, 0, func(obj ObjectInfo) error {
+		return nil
+	})
+	require.ErrorIs(t, err, ErrServiceUnavailable)
+}
+
+func TestWriteTimeout(t *testing.T) {
+	server := testServer()
+	server.Config.WriteTimeout = 200 * time.Millisecond
+	server.Config.RequestTimeout = 10 * time.Second
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	testRepo(server).queryDelay = 400 * time.Millisecond
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	httpServer, err := server.httpServer(nil)
+	require.NoError(t, err)
+	go func() { _ = httpServer.Serve(lis) }()
+	defer func() { _ = httpServer.Close() }()
+
+	search := func(accept string) (*http.Response, error) {
+		r := testRequest(http.MethodPost, "/metasearch/testbucket", `{}`)
+		r.URL.Host = lis.Addr().String()
+		r.Header.Set("Accept", accept)
+		return http.DefaultClient.Do(r)
+	}
+
+	// Responses that are not written before the write timeout fail
+	resp, err := search("application/json")
+	if err == nil {
+		_, err = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+	}
+	require.Error(t, err)
+
+	// Streamed responses are bounded by the request timeout
+	resp, err = search(ndjsonContentType)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, strings.Contains(string(body), "sj://testbucket/foo.txt"), string(body))
+}
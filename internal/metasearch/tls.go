@@ -0,0 +1,106 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// validateTLSConfig checks that the TLS settings of a server configuration
+// are consistent.
+func validateTLSConfig(config ServerConfig) error {
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return errors.New("TLS certificate and key files must be set together")
+	}
+	if len(config.ACMEDomains) > 0 {
+		if config.TLSCertFile != "" {
+			return errors.New("ACME cannot be used with TLS certificate files")
+		}
+		if config.ACMECacheDir == "" {
+			return errors.New("ACME requires a cache directory")
+		}
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS configuration of the server, or nil if the server
+// serves plain HTTP. Certificates are either loaded from files, or obtained
+// from an ACME certificate authority, like Let's Encrypt, with the TLS-ALPN-01
+// challenge on the server endpoint.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	switch {
+	case len(s.Config.ACMEDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(s.Config.ACMEDomains...),
+			Cache:      autocert.DirCache(s.Config.ACMECacheDir),
+			Email:      s.Config.ACMEEmail,
+		}
+		config := manager.TLSConfig()
+		config.MinVersion = tls.VersionTLS12
+		return config, nil
+
+	case s.Config.TLSCertFile != "":
+		cert, err := tls.LoadX509KeyPair(s.Config.TLSCertFile, s.Config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load TLS certificate: %w", err)
+		}
+		return &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// listenAndServe serves the HTTP API on the server endpoint, over HTTPS if
+// config is not nil.
+func (s *Server) listenAndServe(config *tls.Config) error {
+	server, err := s.httpServer(config)
+	if err != nil {
+		return err
+	}
+	if config != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// httpServer returns the HTTP server of the API, with the timeouts of the
+// server configuration. HTTP/2 is negotiated over TLS, and accepted over
+// cleartext connections (h2c) if enabled.
+func (s *Server) httpServer(config *tls.Config) (*http.Server, error) {
+	server := &http.Server{
+		Addr:              s.Config.Endpoint,
+		Handler:           s.Handler,
+		TLSConfig:         config,
+		ReadHeaderTimeout: s.Config.ReadHeaderTimeout,
+		ReadTimeout:       s.Config.ReadTimeout,
+		WriteTimeout:      s.Config.WriteTimeout,
+		IdleTimeout:       s.Config.IdleTimeout,
+	}
+
+	http2Server := &http2.Server{
+		MaxConcurrentStreams: s.Config.MaxStreams,
+		IdleTimeout:          s.Config.IdleTimeout,
+	}
+	switch {
+	case config != nil:
+		if err := http2.ConfigureServer(server, http2Server); err != nil {
+			return nil, fmt.Errorf("unable to configure HTTP/2: %w", err)
+		}
+	case s.Config.H2C:
+		server.Handler = h2c.NewHandler(s.Handler, http2Server)
+	}
+	return server, nil
+}
@@ -0,0 +1,125 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and its
+// key to PEM files.
+func writeTestCertificate(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "metasearch"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile, cert
+}
+
+func TestTLS(t *testing.T) {
+	certFile, keyFile, cert := writeTestCertificate(t)
+
+	logger, _ := zap.NewDevelopment()
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+	})
+	require.NoError(t, err)
+
+	config, err := server.tlsConfig()
+	require.NoError(t, err)
+	require.NotNil(t, config)
+
+	ts := httptest.NewUnstartedServer(server.Handler)
+	ts.TLS = config
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	resp, err := client.Get(ts.URL + "/slo")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []*x509.Certificate{cert}, resp.TLS.PeerCertificates)
+
+	// Plain HTTP without certificates
+	server = testServer()
+	config, err = server.tlsConfig()
+	require.NoError(t, err)
+	require.Nil(t, config)
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	certFile, keyFile, _ := writeTestCertificate(t)
+	logger, _ := zap.NewDevelopment()
+
+	for _, config := range []ServerConfig{
+		{TLSCertFile: certFile},
+		{TLSKeyFile: keyFile},
+		{TLSCertFile: certFile, TLSKeyFile: keyFile, ACMEDomains: []string{"example.com"}, ACMECacheDir: t.TempDir()},
+		{ACMEDomains: []string{"example.com"}},
+	} {
+		_, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, config)
+		require.Error(t, err)
+	}
+
+	// ACME
+	server, err := NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		ACMEDomains:  []string{"example.com"},
+		ACMECacheDir: t.TempDir(),
+	})
+	require.NoError(t, err)
+	config, err := server.tlsConfig()
+	require.NoError(t, err)
+	require.Contains(t, config.NextProtos, "acme-tls/1")
+
+	// Unreadable certificates fail when the server starts
+	server, err = NewServer(logger, newMockRepo(), &mockAuthenticator{}, ServerConfig{
+		TLSCertFile: keyFile,
+		TLSKeyFile:  certFile,
+	})
+	require.NoError(t, err)
+	_, err = server.tlsConfig()
+	require.Error(t, err)
+}
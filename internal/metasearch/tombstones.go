@@ -0,0 +1,148 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const tombstonePurgeInterval = 1 * time.Hour
+
+func (r *MetabaseSearchRepository) SoftDeleteMetadata(ctx context.Context, loc ObjectLocation) (err error) {
+	return r.updateMetadata(ctx, loc, ObjectMetadata{}, true, "")
+}
+
+func (r *MetabaseSearchRepository) SoftDeleteMetadataIfMatch(ctx context.Context, loc ObjectLocation, expected map[string]interface{}) (err error) {
+	return r.updateMetadataIfMatch(ctx, loc, expected, ObjectMetadata{}, true)
+}
+
+func (r *MetabaseSearchRepository) RestoreMetadata(ctx context.Context, loc ObjectLocation, deletedAfter time.Time) (err error) {
+	var version int64
+	var meta ObjectMetadata
+	var clearMetadata *string
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT
+			version,
+			encrypted_metadata_nonce, encrypted_metadata, encrypted_metadata_encrypted_key,
+			clear_metadata
+		FROM metasearch_tombstones
+		WHERE
+			(project_id, bucket_name, object_key) = ($1, $2, $3) AND
+			deleted_at > $4
+		`,
+		loc.ProjectID, []byte(loc.BucketName), []byte(loc.ObjectKey), deletedAfter,
+	).Scan(
+		&version,
+		&meta.EncryptedMetadataNonce, &meta.EncryptedMetadata, &meta.EncryptedMetadataKey,
+		&clearMetadata,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: no deleted metadata to restore", ErrNotFound)
+	} else if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	meta.ClearMetadata, err = parseJSON(clearMetadata)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	// Only restore if the object has not been replaced by a new version
+	err = r.updateMetadata(ctx, loc, meta, false, "version = $10", version)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: object has been deleted or replaced", ErrNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		DELETE FROM metasearch_tombstones
+		WHERE (project_id, bucket_name, object_key) = ($1, $2, $3)
+		`,
+		loc.ProjectID, []byte(loc.BucketName), []byte(loc.ObjectKey),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to remove restored metadata: %v", ErrInternalError, err)
+	}
+
+	return nil
+}
+
+func (r *MetabaseSearchRepository) PurgeTombstones(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM metasearch_tombstones
+		WHERE deleted_at <= $1
+		`,
+		deletedBefore,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%w: unable to get rows affected: %v", ErrInternalError, err)
+	}
+
+	return affected, nil
+}
+
+// HandleRestore restores soft deleted metadata of an object.
+func (s *Server) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request BaseRequest
+
+	err := s.validateRequest(ctx, r, &request, nil)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionWriteMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	if s.Config.SoftDeleteRetention <= 0 {
+		s.errorResponse(w, fmt.Errorf("%w: soft delete is disabled", ErrNotFound))
+		return
+	}
+
+	err = s.Repo.RestoreMetadata(ctx, request.EncryptedLocation, time.Now().Add(-s.Config.SoftDeleteRetention))
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeTombstones periodically removes soft deleted metadata after the
+// retention period.
+func (s *Server) purgeTombstones() {
+	ticker := time.NewTicker(tombstonePurgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if s.ReadOnly() {
+			continue
+		}
+		ctx := context.Background()
+		purged, err := s.Repo.PurgeTombstones(ctx, time.Now().Add(-s.Config.SoftDeleteRetention))
+		if err != nil {
+			s.Logger.Error("cannot purge deleted metadata", zap.Error(err))
+			continue
+		}
+		s.Logger.Debug("purged deleted metadata", zap.Int64("Purged", purged))
+	}
+}
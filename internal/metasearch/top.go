@@ -0,0 +1,117 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ValueCount is a metadata value with the number of objects that have it.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// TopValuesResponse contains the most frequent values of a metadata key, with
+// their number of objects.
+type TopValuesResponse struct {
+	Values []ValueCount `json:"values"`
+}
+
+func (r *MetabaseSearchRepository) TopMetadataValues(ctx context.Context, loc ObjectLocation, key string, prefix string, sample int, limit int) ([]ValueCount, error) {
+	args := []interface{}{loc.ProjectID, []byte(loc.BucketName), key, likeEscaper.Replace(prefix) + "%", limit}
+	sampleLimit := ""
+	if sample > 0 {
+		args = append(args, sample)
+		sampleLimit = "LIMIT $6"
+	}
+
+	// Values of arrays, e.g. tags, are counted separately
+	rows, err := r.db.QueryContext(ctx, `
+		WITH sample AS (
+			SELECT clear_metadata -> $3 AS value
+			FROM `+r.tables.objects()+`
+			WHERE
+				(project_id, bucket_name) = ($1, $2) AND
+				clear_metadata ? $3
+			`+sampleLimit+`
+		)
+		SELECT element #>> '{}', count(*)
+		FROM sample, jsonb_array_elements(
+			CASE WHEN jsonb_typeof(value) = 'array' THEN value ELSE jsonb_build_array(value) END
+		) AS element
+		WHERE
+			jsonb_typeof(element) = 'string' AND
+			(element #>> '{}') LIKE $4
+		GROUP BY 1
+		ORDER BY 2 DESC, 1
+		LIMIT $5
+		`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	values := make([]ValueCount, 0, limit)
+	for rows.Next() {
+		var value ValueCount
+		if err := rows.Scan(&value.Value, &value.Count); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return values, nil
+}
+
+// HandleTopValues returns the most frequent string values of a metadata key
+// in a bucket with their exact counts, e.g. for a tag cloud. Parameters are
+// passed in the URL query string.
+func (s *Server) HandleTopValues(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request BaseRequest
+
+	err := s.validateRequest(ctx, r, &request, nil)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	key := q.Get("key")
+	if key == "" {
+		s.errorResponse(w, fmt.Errorf("%w: key is missing", ErrBadRequest))
+		return
+	}
+	limit := defaultSuggestLimit
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxBatchSize {
+			s.errorResponse(w, fmt.Errorf("%w: invalid limit", ErrBadRequest))
+			return
+		}
+	}
+
+	values, err := s.Repo.TopMetadataValues(ctx, request.EncryptedLocation, key, "", 0, limit)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	s.jsonResponse(w, http.StatusOK, TopValuesResponse{Values: values})
+}
@@ -4,7 +4,15 @@
 package metasearch
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -13,13 +21,95 @@ func parseJSON(data *string) (map[string]interface{}, error) {
 		return nil, nil
 	}
 	var meta map[string]interface{}
-	err := json.Unmarshal([]byte(*data), &meta)
+	err := unmarshalJSON([]byte(*data), &meta)
 	if err != nil {
 		return nil, err
 	}
 	return meta, nil
 }
 
+// newJSONDecoder returns a JSON decoder that decodes numbers as json.Number,
+// so that large integers and decimals are not rounded to float64.
+func newJSONDecoder(r io.Reader) *json.Decoder {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	return d
+}
+
+// unmarshalJSON is json.Unmarshal with numbers decoded as json.Number.
+func unmarshalJSON(data []byte, v interface{}) error {
+	d := newJSONDecoder(bytes.NewReader(data))
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if _, err := d.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
+}
+
+// numberValue returns the exact value of a decoded JSON number.
+func numberValue(v interface{}) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		return new(big.Rat).SetString(v.String())
+	case float64:
+		// Returns nil for infinity and NaN
+		n := new(big.Rat).SetFloat64(v)
+		return n, n != nil
+	}
+	return nil, false
+}
+
+// floatNumbers returns a copy of a decoded JSON value with json.Number values
+// converted to float64, which JMESPath expressions require.
+func floatNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		// Out of range numbers are converted to infinity
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			obj[k] = floatNumbers(child)
+		}
+		return obj
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, child := range v {
+			arr[i] = floatNumbers(child)
+		}
+		return arr
+	}
+	return v
+}
+
+// parseVersionID parses an object version. It accepts the hex encoded stream
+// version ID used by uplink and the S3 gateway (the first 8 bytes contain the
+// version), or a plain version number. 32 character IDs are always parsed as
+// stream version IDs, even if they only contain decimal digits.
+func parseVersionID(s string) (int64, error) {
+	if len(s) == 32 {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid version ID '%s'", s)
+		}
+
+		version := int64(binary.BigEndian.Uint64(b[:8]))
+		if version <= 0 {
+			return 0, fmt.Errorf("invalid version ID '%s'", s)
+		}
+		return version, nil
+	}
+
+	version, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid version ID '%s'", s)
+	}
+	return version, nil
+}
+
 func normalizeKeyPrefix(prefix string) string {
 	for strings.HasPrefix(prefix, "/") {
 		prefix = prefix[1:]
@@ -63,7 +153,7 @@ func toDeepMetadata(meta map[string]string) (map[string]interface{}, error) {
 	for k, v := range meta {
 		if strings.HasPrefix(k, "json:") {
 			var j interface{}
-			err := json.Unmarshal([]byte(v), &j)
+			err := unmarshalJSON([]byte(v), &j)
 			if err != nil {
 				return nil, err
 			}
@@ -77,7 +167,7 @@ func toDeepMetadata(meta map[string]string) (map[string]interface{}, error) {
 
 func splitToJSONLeaves(j string) ([]string, error) {
 	var obj interface{}
-	if err := json.Unmarshal([]byte(j), &obj); err != nil {
+	if err := unmarshalJSON([]byte(j), &obj); err != nil {
 		return nil, err
 	}
 
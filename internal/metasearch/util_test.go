@@ -32,6 +32,33 @@ func TestParseJSON(t *testing.T) {
 	require.Equal(t, "bar", o["foo"])
 }
 
+func TestParseJSONNumbers(t *testing.T) {
+	s := `{"id": 12345678901234567891, "price": 0.10000000000000000555, "n": [1e400]}`
+	o, err := parseJSON(&s)
+	require.NoError(t, err)
+	require.Equal(t, json.Number("12345678901234567891"), o["id"])
+
+	buf, err := json.Marshal(o)
+	require.NoError(t, err)
+	require.JSONEq(t, s, string(buf))
+	require.Contains(t, string(buf), "12345678901234567891")
+	require.Contains(t, string(buf), "0.10000000000000000555")
+
+	// Numbers are compared exactly
+	a, ok := numberValue(json.Number("12345678901234567891"))
+	require.True(t, ok)
+	b, ok := numberValue(json.Number("12345678901234567890"))
+	require.True(t, ok)
+	require.Equal(t, 1, a.Cmp(b))
+
+	// JMESPath expressions get float64 values
+	require.Equal(t, map[string]interface{}{"n": []interface{}{float64(1)}}, floatNumbers(map[string]interface{}{"n": []interface{}{json.Number("1")}}))
+
+	s = `{"foo": 1} {}`
+	_, err = parseJSON(&s)
+	require.Error(t, err)
+}
+
 func TestNormalizePrefix(t *testing.T) {
 	require.Equal(t, "", normalizeKeyPrefix(""))
 	require.Equal(t, "", normalizeKeyPrefix("/"))
@@ -39,6 +66,33 @@ func TestNormalizePrefix(t *testing.T) {
 	require.Equal(t, "foo/bar", normalizeKeyPrefix("/foo/bar//"))
 }
 
+func TestParseVersionID(t *testing.T) {
+	v, err := parseVersionID("42")
+	require.NoError(t, err)
+	require.Equal(t, int64(42), v)
+
+	v, err = parseVersionID("000000000000002a0123456789abcdef")
+	require.NoError(t, err)
+	require.Equal(t, int64(42), v)
+
+	// Stream version IDs with only decimal digits are parsed as hex
+	v, err = parseVersionID("00000000000000420000000000000000")
+	require.NoError(t, err)
+	require.Equal(t, int64(0x42), v)
+
+	_, err = parseVersionID("0000000000000000000000000000002a")
+	require.Error(t, err)
+
+	_, err = parseVersionID("0")
+	require.Error(t, err)
+
+	_, err = parseVersionID("foo")
+	require.Error(t, err)
+
+	_, err = parseVersionID("000000000000002a")
+	require.Error(t, err)
+}
+
 func TestShallowDeepMetadata(t *testing.T) {
 	deepMeta := map[string]interface{}{
 		"stringValue": "foo",
@@ -0,0 +1,113 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// apiVersionHeader selects the API version of requests without a version
+// prefix, and reports the API version of responses.
+const apiVersionHeader = "X-Metasearch-API-Version"
+
+// defaultAPIVersion is the API version of requests without a version prefix
+// or header, so that existing clients keep working.
+const defaultAPIVersion = "v1"
+
+// apiVersion is a version of the API. Breaking changes ship as a new
+// version, while the routes of older versions remain stable.
+type apiVersion struct {
+	name     string
+	register func(s *Server, router *mux.Router)
+}
+
+var apiVersions = []apiVersion{
+	{name: "v1", register: (*Server).registerV1Routes},
+}
+
+// versionPrefix matches the version prefix of a path, e.g. /v1/.
+var versionPrefix = regexp.MustCompile(`^/(v[0-9]+)(/|$)`)
+
+// unversionedPath reports whether a path is an operator endpoint outside of
+// the versioned API.
+func unversionedPath(path string) bool {
+	return path == "/slo" || strings.HasPrefix(path, "/admin/")
+}
+
+// supportedAPIVersion reports whether a version of the API exists.
+func supportedAPIVersion(name string) bool {
+	for _, version := range apiVersions {
+		if version.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateVersion routes requests without a version prefix to the API
+// version of the X-Metasearch-API-Version header, or to the default version.
+// The version of the response is reported in the same header.
+func (s *Server) negotiateVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if match := versionPrefix.FindStringSubmatch(r.URL.Path); match != nil {
+			// Unknown versions are not found by the router
+			if supportedAPIVersion(match[1]) {
+				w.Header().Set(apiVersionHeader, match[1])
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if unversionedPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		version := r.Header.Get(apiVersionHeader)
+		if version == "" {
+			version = defaultAPIVersion
+		}
+		if !supportedAPIVersion(version) {
+			names := make([]string, 0, len(apiVersions))
+			for _, v := range apiVersions {
+				names = append(names, v.name)
+			}
+			s.errorResponse(w, fmt.Errorf("%w: unsupported API version '%s', supported versions are %s", ErrBadRequest, version, strings.Join(names, ", ")))
+			return
+		}
+
+		w.Header().Set(apiVersionHeader, version)
+		next.ServeHTTP(w, withPathPrefix(r, "/"+version))
+	})
+}
+
+// withPathPrefix returns a shallow copy of a request with a prefix added to
+// its path.
+func withPathPrefix(r *http.Request, prefix string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	u := new(url.URL)
+	*u = *r.URL
+	u.Path = prefix + u.Path
+	if u.RawPath != "" {
+		u.RawPath = prefix + u.RawPath
+	}
+	r2.URL = u
+	return r2
+}
+
+// apiPath returns a path in the API version of a request, e.g. for the
+// Location header of created resources.
+func apiPath(r *http.Request, path string) string {
+	if match := versionPrefix.FindStringSubmatch(r.URL.Path); match != nil {
+		return "/" + match[1] + path
+	}
+	return path
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestAPIVersions(t *testing.T) {
+	server := testServer()
+
+	// Versioned paths
+	rr := handleRequest(server, http.MethodPut, "/v1/metadata/testbucket/foo.txt", `{"foo": "bar"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	assert.Equal(t, rr.Header().Get(apiVersionHeader), "v1")
+
+	rr = handleRequest(server, http.MethodGet, "/v1/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get(apiVersionHeader), "v1")
+
+	// Paths without a prefix use the default version
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get(apiVersionHeader), "v1")
+
+	// Keys that look like a version are not rewritten
+	rr = handleRequest(server, http.MethodPut, "/v1/metadata/testbucket/v2/foo.txt", `{"foo": "baz"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	rr = handleRequest(server, http.MethodGet, "/metadata/testbucket/v2/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// Version header
+	r := testRequest(http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	r.Header.Set(apiVersionHeader, "v1")
+	rr = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	r = testRequest(http.MethodGet, "/metadata/testbucket/foo.txt", "")
+	r.Header.Set(apiVersionHeader, "v9")
+	rr = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	// Unknown version prefix
+	rr = handleRequest(server, http.MethodGet, "/v9/metadata/testbucket/foo.txt", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+	assert.Equal(t, rr.Header().Get(apiVersionHeader), "")
+
+	// Operator endpoints are not versioned
+	rr = handleRequest(server, http.MethodGet, "/slo", "")
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get(apiVersionHeader), "")
+
+	rr = handleRequest(server, http.MethodGet, "/v1/slo", "")
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
@@ -0,0 +1,276 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"storj.io/common/uuid"
+)
+
+// maxVocabularyValues is the maximum number of values of a vocabulary.
+const maxVocabularyValues = 1000
+
+// Vocabulary is a controlled vocabulary of a metadata key. Values can be
+// hierarchical, with levels separated by slashes, e.g. "eu/de". The ancestors
+// of a value are allowed too, e.g. "eu".
+type Vocabulary struct {
+	Values []string `json:"values"`
+}
+
+// VocabulariesResponse contains the vocabularies of a project by metadata
+// key.
+type VocabulariesResponse struct {
+	Vocabularies map[string]Vocabulary `json:"vocabularies"`
+}
+
+// validate checks that the values of a vocabulary are well-formed.
+func (v Vocabulary) validate() error {
+	if len(v.Values) == 0 {
+		return fmt.Errorf("%w: vocabulary values are missing", ErrBadRequest)
+	}
+	if len(v.Values) > maxVocabularyValues {
+		return fmt.Errorf("%w: a vocabulary can have at most %d values", ErrBadRequest, maxVocabularyValues)
+	}
+	for _, value := range v.Values {
+		for _, level := range strings.Split(value, "/") {
+			if level == "" {
+				return fmt.Errorf("%w: invalid vocabulary value '%s'", ErrBadRequest, value)
+			}
+		}
+	}
+	return nil
+}
+
+// allows returns true if value is in the vocabulary, or is the ancestor of a
+// value in the vocabulary.
+func (v Vocabulary) allows(value string) bool {
+	for _, allowed := range v.Values {
+		if allowed == value || strings.HasPrefix(allowed, value+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateVocabularies checks that the metadata values of the keys with a
+// vocabulary are strings, or arrays of strings, in the vocabulary. Keys that
+// are not set are not checked.
+func validateVocabularies(vocabularies map[string]Vocabulary, metadata map[string]interface{}) error {
+	keys := make([]string, 0, len(vocabularies))
+	for key := range vocabularies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, ok := metadata[key]
+		if !ok {
+			continue
+		}
+
+		values := []interface{}{value}
+		if array, ok := value.([]interface{}); ok {
+			values = array
+		}
+		for _, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%w: values of '%s' must be strings of its vocabulary", ErrUnprocessableEntity, key)
+			}
+			if !vocabularies[key].allows(s) {
+				return fmt.Errorf("%w: value '%s' of '%s' is not in its vocabulary", ErrUnprocessableEntity, s, key)
+			}
+		}
+	}
+	return nil
+}
+
+// checkVocabularies validates metadata against the vocabularies of a
+// project.
+func (s *Server) checkVocabularies(ctx context.Context, projectID uuid.UUID, metadata map[string]interface{}) error {
+	vocabularies, err := s.Repo.GetVocabularies(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	return validateVocabularies(vocabularies, metadata)
+}
+
+// getVocabulariesQuery is the query of GetVocabularies.
+const getVocabulariesQuery = `
+	SELECT metadata_key, vocabulary
+	FROM metasearch_vocabularies
+	WHERE project_id = $1
+`
+
+func (r *MetabaseSearchRepository) GetVocabularies(ctx context.Context, projectID uuid.UUID) (map[string]Vocabulary, error) {
+	rows, err := r.db.QueryContext(ctx, getVocabulariesQuery, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+	defer rows.Close()
+
+	vocabularies := make(map[string]Vocabulary)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+
+		var vocabulary Vocabulary
+		if err := json.Unmarshal([]byte(value), &vocabulary); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+		}
+		vocabularies[key] = vocabulary
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return vocabularies, nil
+}
+
+func (r *MetabaseSearchRepository) SetVocabulary(ctx context.Context, projectID uuid.UUID, key string, vocabulary Vocabulary) error {
+	value, err := json.Marshal(vocabulary)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO metasearch_vocabularies (project_id, metadata_key, vocabulary, updated_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (project_id, metadata_key) DO UPDATE SET
+			vocabulary = excluded.vocabulary,
+			updated_at = excluded.updated_at
+		`,
+		projectID, key, string(value),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to set vocabulary: %v", ErrInternalError, err)
+	}
+	return nil
+}
+
+func (r *MetabaseSearchRepository) DeleteVocabulary(ctx context.Context, projectID uuid.UUID, key string) error {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM metasearch_vocabularies
+		WHERE (project_id, metadata_key) = ($1, $2)
+		`,
+		projectID, key,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to delete vocabulary: %v", ErrInternalError, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: unable to get rows affected: %v", ErrInternalError, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: vocabulary not found", ErrNotFound)
+	}
+	return nil
+}
+
+// authenticateProject authenticates a request on a whole project rather than
+// on a bucket.
+func (s *Server) authenticateProject(ctx context.Context, r *http.Request) (projectID uuid.UUID, encryptor Encryptor, authorizer Authorizer, err error) {
+	projectID, encryptor, authorizer, err = s.Auth.Authenticate(ctx, r)
+	if err != nil {
+		return projectID, nil, nil, err
+	}
+	s.trackGrant(projectID, r)
+	recordAccessProject(ctx, projectID)
+	return projectID, encryptor, authorizer, nil
+}
+
+// HandleVocabularies returns the vocabularies of the project of the access
+// grant.
+func (s *Server) HandleVocabularies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID, _, _, err := s.authenticateProject(ctx, r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	vocabularies, err := s.Repo.GetVocabularies(ctx, projectID)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	s.jsonResponse(w, http.StatusOK, VocabulariesResponse{Vocabularies: vocabularies})
+}
+
+// HandleSetVocabulary sets the vocabulary of a metadata key. Vocabularies
+// apply to all buckets of a project, so they can only be changed with an
+// access grant that can write to the whole project.
+func (s *Server) HandleSetVocabulary(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID, _, authorizer, err := s.authenticateProject(ctx, r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = authorizer.Authorize(ctx, ObjectLocation{ProjectID: projectID}, ActionWriteMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	var vocabulary Vocabulary
+	if err := json.NewDecoder(r.Body).Decode(&vocabulary); err != nil {
+		s.errorResponse(w, bodyError(err, "error decoding request body"))
+		return
+	}
+	if err := vocabulary.validate(); err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = s.Repo.SetVocabulary(ctx, projectID, mux.Vars(r)["key"], vocabulary)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleDeleteVocabulary deletes the vocabulary of a metadata key.
+func (s *Server) HandleDeleteVocabulary(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID, _, authorizer, err := s.authenticateProject(ctx, r)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = authorizer.Authorize(ctx, ObjectLocation{ProjectID: projectID}, ActionWriteMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = s.Repo.DeleteVocabulary(ctx, projectID, mux.Vars(r)["key"])
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
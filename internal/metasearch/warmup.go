@@ -0,0 +1,290 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/uuid"
+	"storj.io/storj/satellite/console"
+	"storj.io/storj/shared/tagsql"
+)
+
+const (
+	// apiKeyCacheTTL is the duration API key lookups are cached. Revocations
+	// are checked on every request regardless.
+	apiKeyCacheTTL = time.Minute
+
+	// maxCachedAPIKeys is the maximum number of cached API keys.
+	maxCachedAPIKeys = 10000
+
+	// maxRecentAPIKeys is the maximum number of recently used API keys saved
+	// for the warm-up of the next start.
+	maxRecentAPIKeys = 1000
+)
+
+// apiKeyCache caches the API key lookups in the satellite database by key
+// head.
+type apiKeyCache struct {
+	fetch func(ctx context.Context, head []byte) (*console.APIKeyInfo, error)
+
+	mutex sync.Mutex
+	keys  map[string]*cachedAPIKey
+}
+
+type cachedAPIKey struct {
+	head      []byte
+	info      *console.APIKeyInfo
+	fetchedAt time.Time
+	usedAt    time.Time
+}
+
+func newAPIKeyCache(fetch func(ctx context.Context, head []byte) (*console.APIKeyInfo, error)) *apiKeyCache {
+	return &apiKeyCache{
+		fetch: fetch,
+		keys:  make(map[string]*cachedAPIKey),
+	}
+}
+
+// Get returns the API key with the given head, from the cache if it was
+// fetched recently.
+func (c *apiKeyCache) Get(ctx context.Context, head []byte) (*console.APIKeyInfo, error) {
+	now := time.Now()
+
+	c.mutex.Lock()
+	if key, ok := c.keys[string(head)]; ok && now.Sub(key.fetchedAt) < apiKeyCacheTTL {
+		key.usedAt = now
+		c.mutex.Unlock()
+		return key.info, nil
+	}
+	c.mutex.Unlock()
+
+	info, err := c.fetch(ctx, head)
+	if err != nil {
+		return nil, err
+	}
+	c.add(head, info, now, now)
+	return info, nil
+}
+
+// Preload fetches API keys into the cache, without marking them as used.
+func (c *apiKeyCache) Preload(ctx context.Context, heads [][]byte) (loaded int, err error) {
+	var errs []error
+	for _, head := range heads {
+		info, err := c.fetch(ctx, head)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c.add(head, info, time.Now(), time.Time{})
+		loaded++
+	}
+	return loaded, errors.Join(errs...)
+}
+
+func (c *apiKeyCache) add(head []byte, info *console.APIKeyInfo, fetchedAt time.Time, usedAt time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if key, ok := c.keys[string(head)]; ok {
+		key.info = info
+		key.fetchedAt = fetchedAt
+		if usedAt.After(key.usedAt) {
+			key.usedAt = usedAt
+		}
+		return
+	}
+
+	if len(c.keys) >= maxCachedAPIKeys {
+		c.evictOldest()
+	}
+	c.keys[string(head)] = &cachedAPIKey{
+		head:      head,
+		info:      info,
+		fetchedAt: fetchedAt,
+		usedAt:    usedAt,
+	}
+}
+
+// evictOldest removes the least recently used key. Must be called while
+// c.mutex is locked.
+func (c *apiKeyCache) evictOldest() {
+	var oldest *cachedAPIKey
+	for _, key := range c.keys {
+		if oldest == nil || key.usedAt.Before(oldest.usedAt) {
+			oldest = key
+		}
+	}
+	if oldest != nil {
+		delete(c.keys, string(oldest.head))
+	}
+}
+
+// Recent returns the heads of the cached API keys, most recently used first.
+func (c *apiKeyCache) Recent(limit int) [][]byte {
+	c.mutex.Lock()
+	keys := make([]*cachedAPIKey, 0, len(c.keys))
+	for _, key := range c.keys {
+		keys = append(keys, key)
+	}
+	c.mutex.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].usedAt.After(keys[j].usedAt)
+	})
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	heads := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		heads = append(heads, key.head)
+	}
+	return heads
+}
+
+// saveAPIKeyHeads writes API key heads to a file, one hex encoded head per
+// line. The file is replaced atomically.
+func saveAPIKeyHeads(path string, heads [][]byte) error {
+	var b strings.Builder
+	for _, head := range heads {
+		b.WriteString(hex.EncodeToString(head))
+		b.WriteByte('\n')
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.WriteString(b.String())
+	if err = errors.Join(err, tmp.Close()); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadAPIKeyHeads reads the API key heads saved by saveAPIKeyHeads. A missing
+// file contains no heads.
+func loadAPIKeyHeads(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var heads [][]byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		head, err := hex.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API key head %q: %w", line, err)
+		}
+		heads = append(heads, head)
+	}
+	return heads, scanner.Err()
+}
+
+// PreloadAPIKeys loads the API keys saved in a file by SaveRecentAPIKeys into
+// the cache, so that the first requests after a start do not wait for the
+// satellite database.
+func (a *HeaderAuth) PreloadAPIKeys(ctx context.Context, path string) (loaded int, err error) {
+	heads, err := loadAPIKeyHeads(path)
+	if err != nil {
+		return 0, err
+	}
+	return a.keys.Preload(ctx, heads)
+}
+
+// SaveRecentAPIKeys saves the heads of the recently used API keys to a file
+// at every interval, until ctx is canceled. API key heads are not secret:
+// the keys cannot be used without their secret.
+func (a *HeaderAuth) SaveRecentAPIKeys(ctx context.Context, log *zap.Logger, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := saveAPIKeyHeads(path, a.keys.Recent(maxRecentAPIKeys)); err != nil {
+			log.Warn("cannot save recently used API keys", zap.String("Path", path), zap.Error(err))
+		}
+	}
+}
+
+// Warmup opens connections to the metabase, and prepares the statements of
+// the most frequent queries on each of them, so that the first requests after
+// a start do not wait for them. The connections are returned to the pool,
+// which must keep enough idle connections.
+func (r *MetabaseSearchRepository) Warmup(ctx context.Context, connections int) error {
+	// All connections are held at the same time, so that they are distinct
+	conns := make([]tagsql.Conn, connections)
+	errs := make([]error, connections)
+
+	var wg sync.WaitGroup
+	for i := range conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conns[i], errs[i] = r.warmupConn(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, conn := range conns {
+		if conn != nil {
+			errs = append(errs, conn.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// warmupConn opens a connection and runs the frequent queries on it.
+func (r *MetabaseSearchRepository) warmupConn(ctx context.Context) (tagsql.Conn, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataQuery, metadataArgs := r.getMetadataQuery(ObjectLocation{})
+	for _, q := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{metadataQuery, metadataArgs},
+		{getWatermarkQuery, []interface{}{uuid.UUID{}, []byte{}}},
+		{getVocabulariesQuery, []interface{}{uuid.UUID{}}},
+	} {
+		rows, err := conn.QueryContext(ctx, q.query, q.args...)
+		if err != nil {
+			return conn, err
+		}
+		if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+			return conn, err
+		}
+	}
+	return conn, nil
+}
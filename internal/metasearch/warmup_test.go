@@ -0,0 +1,69 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/uuid"
+	"storj.io/storj/satellite/console"
+)
+
+func TestAPIKeyCache(t *testing.T) {
+	ctx := context.Background()
+
+	fetched := make(map[string]int)
+	cache := newAPIKeyCache(func(ctx context.Context, head []byte) (*console.APIKeyInfo, error) {
+		fetched[string(head)]++
+		if string(head) == "unknown" {
+			return nil, errors.New("not found")
+		}
+		return &console.APIKeyInfo{ProjectID: uuid.UUID{head[0]}}, nil
+	})
+
+	// Lookups are cached
+	for i := 0; i < 2; i++ {
+		info, err := cache.Get(ctx, []byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, uuid.UUID{'a'}, info.ProjectID)
+	}
+	require.Equal(t, 1, fetched["a"])
+
+	_, err := cache.Get(ctx, []byte("unknown"))
+	require.Error(t, err)
+
+	// Preloaded keys are listed after the used keys
+	loaded, err := cache.Preload(ctx, [][]byte{[]byte("b"), []byte("unknown")})
+	require.Error(t, err)
+	require.Equal(t, 1, loaded)
+
+	_, err = cache.Get(ctx, []byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, 1, fetched["b"])
+
+	_, err = cache.Get(ctx, []byte("c"))
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("c"), []byte("b"), []byte("a")}, cache.Recent(10))
+	require.Equal(t, [][]byte{[]byte("c")}, cache.Recent(1))
+}
+
+func TestAPIKeyHeadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+
+	heads, err := loadAPIKeyHeads(path)
+	require.NoError(t, err)
+	require.Empty(t, heads)
+
+	saved := [][]byte{{1, 2, 3}, {4, 5}}
+	require.NoError(t, saveAPIKeyHeads(path, saved))
+
+	heads, err = loadAPIKeyHeads(path)
+	require.NoError(t, err)
+	require.Equal(t, saved, heads)
+}
@@ -0,0 +1,390 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// defaultWatchInterval is the interval the change feed of a watched bucket is
+// polled at, if it is not configured.
+const defaultWatchInterval = time.Second
+
+const (
+	// watchRequestTimeout is the time the client has to send the watch
+	// request after the connection is upgraded.
+	watchRequestTimeout = 10 * time.Second
+	// watchPingInterval is the interval of ping messages, which detect
+	// broken connections.
+	watchPingInterval = 30 * time.Second
+	// watchWriteTimeout is the timeout of writing a message to the client.
+	watchWriteTimeout = 10 * time.Second
+	// maxWatchRequestSize is the maximum size of a watch request.
+	maxWatchRequestSize = 1 << 20
+	// maxLongPollDuration is the maximum duration a get request with a
+	// watch parameter waits for changes.
+	maxLongPollDuration = time.Minute
+)
+
+// WatchRequest is the first message of a watch connection. It selects the
+// objects like a search, and Since is the watermark after which changes are
+// sent, which defaults to the current watermark of the bucket.
+type WatchRequest struct {
+	SearchRequest
+	Since *int64 `json:"since,omitempty"`
+}
+
+// WatchEvent is a message sent to a watch client. A "ready" event is sent once
+// the request is accepted. "match" events are sent when the metadata of an
+// object is created or updated and matches the request, and "unmatch" events
+// when the metadata of an object that matched is updated and no longer
+// matches, or deleted. An "error" event is sent before the server closes the
+// connection because of an error.
+type WatchEvent struct {
+	Type      string      `json:"type"`
+	Path      string      `json:"path,omitempty"`
+	Version   int64       `json:"version,omitempty"`
+	Watermark int64       `json:"watermark"`
+	ChangedAt *time.Time  `json:"changedAt,omitempty"`
+	Metadata  interface{} `json:"metadata,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+var watchUpgrader = websocket.Upgrader{
+	// Requests are authenticated like other API requests, and the match
+	// query is only sent by the client after the upgrade.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// watcher is the state of a watch connection: the request, and the position
+// in the change feed of the bucket.
+type watcher struct {
+	request        *SearchRequest
+	match          matchQuery
+	afterWatermark int64
+	afterRevision  int64
+}
+
+// HandleWatch upgrades the connection to a WebSocket, reads a watch request,
+// and sends the objects whose metadata is created or updated and matches it,
+// so that dashboards stay live without polling searches. Changes are read
+// from the change feed of the bucket, so that changes through all server
+// instances are sent.
+func (s *Server) HandleWatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request WatchRequest
+
+	err := s.validateRequest(ctx, r, &request.BaseRequest, nil)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	if s.Config.MaxWatchers > 0 {
+		if s.watchers.Add(1) > int64(s.Config.MaxWatchers) {
+			s.watchers.Add(-1)
+			s.errorResponse(w, fmt.Errorf("%w: too many watch connections", ErrTooManyRequests))
+			return
+		}
+		defer s.watchers.Add(-1)
+	}
+
+	conn, err := watchUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already sent an error response
+		s.Logger.Debug("error upgrading watch connection", zap.Error(err))
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sendError := func(err error) {
+		var e *ErrorResponse
+		if !errors.As(err, &e) {
+			s.Logger.Warn("error during watch", zap.Error(err))
+			err = ErrInternalError
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(watchWriteTimeout))
+		_ = conn.WriteJSON(WatchEvent{Type: "error", Error: err.Error()})
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, ""), time.Now().Add(watchWriteTimeout))
+	}
+
+	wa, err := s.readWatchRequest(ctx, conn, &request)
+	if err != nil {
+		sendError(err)
+		return
+	}
+
+	// Read control messages, and stop when the client closes the connection
+	conn.SetReadLimit(maxWatchRequestSize)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * watchPingInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * watchPingInterval))
+	})
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(event WatchEvent) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(watchWriteTimeout))
+		return conn.WriteJSON(event)
+	}
+	if err := send(WatchEvent{Type: "ready", Watermark: wa.afterWatermark}); err != nil {
+		return
+	}
+
+	interval := s.Config.WatchInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	poll := time.NewTicker(interval)
+	defer poll.Stop()
+	ping := time.NewTicker(watchPingInterval)
+	defer ping.Stop()
+
+	mon.Counter("watch_connections").Inc(1)
+	defer mon.Counter("watch_connections").Dec(1)
+
+	for {
+		events, err := s.watchChanges(ctx, wa)
+		if err != nil {
+			if ctx.Err() == nil {
+				sendError(err)
+			}
+			return
+		}
+		for _, event := range events {
+			if err := send(event); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(watchWriteTimeout)); err != nil {
+				return
+			}
+		case <-poll.C:
+		}
+	}
+}
+
+// readWatchRequest reads and validates the watch request sent by the client.
+func (s *Server) readWatchRequest(ctx context.Context, conn *websocket.Conn, request *WatchRequest) (*watcher, error) {
+	conn.SetReadLimit(maxWatchRequestSize)
+	_ = conn.SetReadDeadline(time.Now().Add(watchRequestTimeout))
+
+	_, reader, err := conn.NextReader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: no watch request received", ErrBadRequest)
+	}
+	if err := newJSONDecoder(reader).Decode(request); err != nil {
+		return nil, fmt.Errorf("%w: error decoding watch request: %w", ErrBadRequest, err)
+	}
+
+	if request.Sort != nil || request.CountOnly || request.Delimiter != "" || request.SimilarTo != nil || request.PageToken != "" {
+		return nil, fmt.Errorf("%w: watch requests cannot use sort, countOnly, delimiter, similarTo or pageToken", ErrBadRequest)
+	}
+	if err := s.validateSearchRequest(&request.SearchRequest); err != nil {
+		return nil, err
+	}
+	match, err := parseMatch(request.Match)
+	if err != nil {
+		return nil, err
+	}
+	if hasSystemConditions(match) {
+		return nil, fmt.Errorf("%w: watch requests cannot have conditions on system attributes", ErrBadRequest)
+	}
+
+	wa := &watcher{
+		request:       &request.SearchRequest,
+		match:         match,
+		afterRevision: math.MaxInt64,
+	}
+	if request.Since != nil {
+		if *request.Since < 0 {
+			return nil, fmt.Errorf("%w: invalid since", ErrBadRequest)
+		}
+		wa.afterWatermark = *request.Since
+	} else {
+		watermark, err := s.Repo.GetWatermark(ctx, request.EncryptedLocation.ProjectID, request.EncryptedLocation.BucketName)
+		if err != nil {
+			return nil, err
+		}
+		wa.afterWatermark = watermark.Watermark
+	}
+	return wa, nil
+}
+
+// hasSystemConditions returns true if a match query has conditions on the
+// system attributes of objects, which are not part of the change feed.
+func hasSystemConditions(q matchQuery) bool {
+	if len(q.system) > 0 || q.not != nil && hasSystemConditions(*q.not) {
+		return true
+	}
+	for _, subquery := range append(append([]matchQuery(nil), q.allOf...), q.anyOf...) {
+		if hasSystemConditions(subquery) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchChanges reads the changes of the watched bucket since the last call,
+// and returns the events of the objects that match or no longer match.
+func (s *Server) watchChanges(ctx context.Context, wa *watcher) ([]WatchEvent, error) {
+	request := wa.request
+
+	var events []WatchEvent
+	for {
+		changes, err := s.Repo.GetChanges(ctx, request.EncryptedLocation.ProjectID, request.EncryptedLocation.BucketName, wa.afterWatermark, wa.afterRevision, maxBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, change := range changes {
+			wa.afterWatermark, wa.afterRevision = change.Watermark, change.Revision
+
+			// Skip the objects whose path cannot be decrypted with the
+			// grant, or that are not selected by the request
+			key, err := request.Encryptor.DecryptPath(request.Location.BucketName, change.ObjectKey)
+			if err != nil || !strings.HasPrefix(key, request.Location.ObjectKey) || !matchKey(request, key) {
+				continue
+			}
+
+			event := WatchEvent{
+				Path:      fmt.Sprintf("sj://%s/%s", request.Location.BucketName, key),
+				Version:   change.Version,
+				Watermark: change.Watermark,
+				ChangedAt: &change.ChangedAt,
+			}
+			switch {
+			case s.watchMatches(wa, change.NewMetadata):
+				event.Type = "match"
+				event.Metadata = change.NewMetadata
+				if request.projectionPath != nil {
+					event.Metadata, err = request.projectionPath.Search(floatNumbers(change.NewMetadata))
+					if err != nil {
+						return nil, fmt.Errorf("%w: %v", ErrBadRequest, err)
+					}
+				}
+			case s.watchMatches(wa, change.OldMetadata):
+				event.Type = "unmatch"
+			default:
+				continue
+			}
+			events = append(events, event)
+		}
+
+		if len(changes) < maxBatchSize {
+			return events, nil
+		}
+	}
+}
+
+// watchMatches returns true if metadata matches the query and filter of a
+// watch request. Deleted metadata does not match.
+func (s *Server) watchMatches(wa *watcher, metadata map[string]interface{}) bool {
+	if metadata == nil {
+		return false
+	}
+	h := &highlighter{highlights: make(map[string]interface{})}
+	if !h.query(wa.match, metadata) {
+		return false
+	}
+	matched, err := s.filterMetadata(wa.request, metadata)
+	return err == nil && matched
+}
+
+// waitForChange blocks a get request with a watch parameter until the
+// metadata of the object changes, or the number of seconds of the parameter
+// passes, and returns the latest object. The watermark of the bucket is
+// polled, and the object is only read again when it changes, so that
+// changes through all server instances are detected cheaply.
+func (s *Server) waitForChange(r *http.Request, request BaseRequest, obj ObjectInfo, watch string) (ObjectInfo, error) {
+	seconds, err := strconv.Atoi(watch)
+	if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > maxLongPollDuration {
+		return obj, fmt.Errorf("%w: watch must be between 1 and %d seconds", ErrBadRequest, int(maxLongPollDuration/time.Second))
+	}
+
+	if s.Config.MaxWatchers > 0 {
+		if s.watchers.Add(1) > int64(s.Config.MaxWatchers) {
+			s.watchers.Add(-1)
+			return obj, fmt.Errorf("%w: too many watch connections", ErrTooManyRequests)
+		}
+		defer s.watchers.Add(-1)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(seconds)*time.Second)
+	defer cancel()
+
+	interval := s.Config.WatchInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// The object is read again on the first tick, in case it changed before
+	// the watermark was first read.
+	etag := metadataETag(obj.Metadata.ClearMetadata)
+	loc := request.EncryptedLocation
+	lastWatermark := int64(-1)
+	for {
+		select {
+		case <-ctx.Done():
+			return obj, nil
+		case <-ticker.C:
+		}
+
+		watermark, err := s.Repo.GetWatermark(ctx, loc.ProjectID, loc.BucketName)
+		if err != nil {
+			if ctx.Err() != nil {
+				return obj, nil
+			}
+			return obj, err
+		}
+		if watermark.Watermark == lastWatermark {
+			continue
+		}
+		lastWatermark = watermark.Watermark
+
+		changed, err := s.Repo.GetMetadata(ctx, loc)
+		if err != nil {
+			if ctx.Err() != nil {
+				return obj, nil
+			}
+			return obj, err
+		}
+		s.migrateObject(ctx, &changed, request.Encryptor)
+		if metadataETag(changed.Metadata.ClearMetadata) != etag {
+			return changed, nil
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/assert"
+
+	"storj.io/common/uuid"
+)
+
+func TestWatch(t *testing.T) {
+	server := testServer()
+	server.Config.WatchInterval = 10 * time.Millisecond
+
+	for _, update := range []struct{ key, metadata string }{
+		{"foo.txt", `{"type": "photo"}`},
+		{"bar.txt", `{"type": "video", "n": 1}`},
+		{"foo.txt", `{"type": "video", "n": 2}`},
+		{"bar.txt", `{"type": "photo"}`},
+	} {
+		rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/"+update.key, update.metadata)
+		assert.Equal(t, rr.Code, http.StatusNoContent)
+	}
+
+	httpServer := httptest.NewServer(server.Handler)
+	defer httpServer.Close()
+
+	dial := func() *websocket.Conn {
+		header := http.Header{}
+		header.Set("Authorization", "Bearer testtoken")
+		header.Set("X-Project-ID", testProjectID)
+		conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(httpServer.URL, "http", "ws", 1)+"/metasearch/testbucket/watch", header)
+		require.NoError(t, err)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+		return conn
+	}
+
+	// Changes after the since watermark
+	conn := dial()
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"match":      map[string]interface{}{"type": "video"},
+		"projection": "n",
+		"since":      0,
+	}))
+
+	var event WatchEvent
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, WatchEvent{Type: "ready"}, event)
+
+	for _, expected := range []struct {
+		typ       string
+		path      string
+		watermark int64
+		metadata  interface{}
+	}{
+		{"match", "sj://testbucket/bar.txt", 2, float64(1)},
+		{"match", "sj://testbucket/foo.txt", 3, float64(2)},
+		{"unmatch", "sj://testbucket/bar.txt", 4, nil},
+	} {
+		event = WatchEvent{}
+		require.NoError(t, conn.ReadJSON(&event))
+		require.Equal(t, expected.typ, event.Type)
+		require.Equal(t, expected.path, event.Path)
+		require.Equal(t, expected.watermark, event.Watermark)
+		require.Equal(t, expected.metadata, event.Metadata)
+	}
+
+	// Invalid requests are rejected with an error event
+	invalid := dial()
+	defer func() { _ = invalid.Close() }()
+	require.NoError(t, invalid.WriteJSON(map[string]interface{}{"sort": map[string]interface{}{"key": "n"}}))
+	event = WatchEvent{}
+	require.NoError(t, invalid.ReadJSON(&event))
+	require.Equal(t, "error", event.Type)
+	require.Contains(t, event.Error, "watch requests cannot use sort")
+}
+
+// watermarkHookRepo calls a hook when the watermark of a bucket is read.
+type watermarkHookRepo struct {
+	MetaSearchRepo
+	hook func()
+}
+
+func (r *watermarkHookRepo) GetWatermark(ctx context.Context, projectID uuid.UUID, bucket string) (Watermark, error) {
+	if r.hook != nil {
+		r.hook()
+	}
+	return r.MetaSearchRepo.GetWatermark(ctx, projectID, bucket)
+}
+
+func TestLongPollGet(t *testing.T) {
+	server := testServer()
+	server.Config.WatchInterval = 10 * time.Millisecond
+
+	rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "123"}`)
+	assert.Equal(t, rr.Code, http.StatusNoContent)
+	etag := metadataETag(map[string]interface{}{"foo": "123"})
+
+	get := func(watch string, ifNoneMatch string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := testRequest(http.MethodGet, "/metadata/testbucket/foo.txt?watch="+watch, "")
+		r.Header.Set("If-None-Match", ifNoneMatch)
+		server.Handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	// Unchanged metadata
+	start := time.Now()
+	rr = get("1", etag)
+	assert.Equal(t, rr.Code, http.StatusNotModified)
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	// Metadata that has already changed is returned immediately
+	rr = get("1", `"outdated"`)
+	assertResponse(t, rr, http.StatusOK, `{"foo": "123"}`)
+
+	// Metadata that changes while waiting
+	reads := 0
+	server.Repo = &watermarkHookRepo{MetaSearchRepo: server.Repo, hook: func() {
+		reads++
+		if reads == 3 {
+			rr := handleRequest(server, http.MethodPut, "/metadata/testbucket/foo.txt", `{"foo": "456"}`)
+			assert.Equal(t, rr.Code, http.StatusNoContent)
+		}
+	}}
+	rr = get("10", etag)
+	assertResponse(t, rr, http.StatusOK, `{"foo": "456"}`)
+	assert.Equal(t, rr.Header().Get("ETag"), metadataETag(map[string]interface{}{"foo": "456"}))
+
+	// Invalid durations
+	for _, watch := range []string{"0", "-1", "61", "soon"} {
+		rr = get(watch, etag)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metasearch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"storj.io/common/uuid"
+)
+
+// incrementWatermark increments the watermark of the buckets of the rows
+// returned by the "updated" common table expression, and returns the new
+// watermarks.
+const incrementWatermark = `
+	INSERT INTO metasearch_watermarks (project_id, bucket_name, watermark, updated_at)
+	SELECT DISTINCT project_id, bucket_name, 1, now() FROM updated
+	ON CONFLICT (project_id, bucket_name) DO UPDATE
+	SET watermark = metasearch_watermarks.watermark + 1, updated_at = now()
+	RETURNING project_id, bucket_name, watermark
+`
+
+// Watermark is a monotonically increasing counter of the metadata changes in
+// a bucket.
+type Watermark struct {
+	Watermark int64      `json:"watermark"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// getWatermarkQuery is the query of GetWatermark.
+const getWatermarkQuery = `
+	SELECT watermark, updated_at
+	FROM metasearch_watermarks
+	WHERE (project_id, bucket_name) = ($1, $2)
+`
+
+func (r *MetabaseSearchRepository) GetWatermark(ctx context.Context, projectID uuid.UUID, bucket string) (watermark Watermark, err error) {
+	err = r.db.QueryRowContext(ctx, getWatermarkQuery, projectID, []byte(bucket)).Scan(&watermark.Watermark, &watermark.UpdatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		// No changes yet
+		return Watermark{}, nil
+	} else if err != nil {
+		return Watermark{}, fmt.Errorf("%w: %v", ErrInternalError, err)
+	}
+
+	return watermark, nil
+}
+
+// HandleWatermark returns the change watermark of a bucket. Sync tools can
+// compare it with the watermark of their last run to detect changes, before
+// running expensive queries.
+func (s *Server) HandleWatermark(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var request BaseRequest
+
+	err := s.validateRequest(ctx, r, &request, nil)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	err = request.Authorizer.Authorize(ctx, request.EncryptedLocation, ActionQueryMetadata)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	watermark, err := s.Repo.GetWatermark(ctx, request.EncryptedLocation.ProjectID, request.EncryptedLocation.BucketName)
+	if err != nil {
+		s.errorResponse(w, err)
+		return
+	}
+
+	s.jsonResponse(w, http.StatusOK, watermark)
+}
@@ -0,0 +1,153 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package signature signs the webhook requests of metasearch, such as the
+// requests of the metadata extractor and of scheduled searches, and verifies
+// them on the receiving side.
+//
+// Requests are signed with a secret shared between metasearch and the
+// receiver, which is never sent with the request. Receivers verify them with a
+// Verifier, which also rejects expired and replayed requests.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Headers of signed requests. The signature is the hex encoded
+	// HMAC-SHA256 of the timestamp, nonce and body, joined with dots.
+	TimestampHeader = "X-Metasearch-Timestamp"
+	NonceHeader     = "X-Metasearch-Nonce"
+	SignatureHeader = "X-Metasearch-Signature"
+
+	// DefaultMaxAge is the default maximum age of signed requests.
+	DefaultMaxAge = 5 * time.Minute
+
+	// maxNonces is the maximum number of nonces remembered by a Verifier.
+	// Requests are rejected while the cache is full.
+	maxNonces = 100000
+)
+
+var (
+	// ErrUnsigned is returned when a request is not signed.
+	ErrUnsigned = errors.New("request is not signed")
+
+	// ErrInvalid is returned when the signature of a request does not match
+	// its body, or was not made with the secret of the verifier.
+	ErrInvalid = errors.New("invalid request signature")
+
+	// ErrExpired is returned when a request was signed too long ago.
+	ErrExpired = errors.New("signed request expired")
+
+	// ErrReplayed is returned when the nonce of a request was already seen.
+	ErrReplayed = errors.New("signed request replayed")
+
+	// ErrTooManyRequests is returned when the nonce cache of a verifier is
+	// full.
+	ErrTooManyRequests = errors.New("too many signed requests")
+)
+
+// Sign adds a timestamp, a random nonce and the signature of the body to a
+// request.
+func Sign(req *http.Request, secret string, body []byte, now time.Time) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	nonceHex := hex.EncodeToString(nonce)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, nonceHex)
+	req.Header.Set(SignatureHeader, compute(secret, timestamp, nonceHex, body))
+	return nil
+}
+
+func compute(secret string, timestamp string, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + nonce + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verifier verifies signed requests. A request is rejected if its timestamp
+// is older than maxAge, or if its nonce was already seen, so that captured
+// requests cannot be replayed. Nonces are remembered until their request
+// expires.
+type Verifier struct {
+	secret string
+	maxAge time.Duration
+
+	mutex  sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewVerifier creates a new Verifier for requests signed with secret.
+func NewVerifier(secret string, maxAge time.Duration) *Verifier {
+	return &Verifier{
+		secret: secret,
+		maxAge: maxAge,
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// Verify checks the signature, timestamp and nonce of a request with the
+// given body.
+func (v *Verifier) Verify(r *http.Request, body []byte, now time.Time) error {
+	timestamp := r.Header.Get(TimestampHeader)
+	nonce := r.Header.Get(NonceHeader)
+	signature := r.Header.Get(SignatureHeader)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return ErrUnsigned
+	}
+
+	expected := compute(v.secret, timestamp, nonce, body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalid
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalid
+	}
+	signedAt := time.Unix(unix, 0)
+	if now.Sub(signedAt) > v.maxAge || signedAt.Sub(now) > v.maxAge {
+		return ErrExpired
+	}
+
+	return v.useNonce(nonce, signedAt.Add(v.maxAge), now)
+}
+
+// useNonce records a nonce until it expires, and fails if it is already
+// recorded.
+func (v *Verifier) useNonce(nonce string, expiresAt time.Time, now time.Time) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if _, ok := v.nonces[nonce]; ok {
+		return ErrReplayed
+	}
+
+	if len(v.nonces) >= maxNonces {
+		for n, expiry := range v.nonces {
+			if !expiry.After(now) {
+				delete(v.nonces, n)
+			}
+		}
+		if len(v.nonces) >= maxNonces {
+			return ErrTooManyRequests
+		}
+	}
+
+	v.nonces[nonce] = expiresAt
+	return nil
+}
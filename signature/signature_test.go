@@ -0,0 +1,46 @@
+// Copyright (C) 2025 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package signature_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/metasearch/signature"
+)
+
+func TestVerifier(t *testing.T) {
+	verifier := signature.NewVerifier("secret", time.Minute)
+	now := time.Now()
+	body := []byte(`{"foo":"bar"}`)
+
+	signed := func(secret string, signedAt time.Time) *http.Request {
+		r, err := http.NewRequest(http.MethodPost, "http://localhost/extract", nil)
+		require.NoError(t, err)
+		require.NoError(t, signature.Sign(r, secret, body, signedAt))
+		return r
+	}
+
+	r := signed("secret", now)
+	require.NoError(t, verifier.Verify(r, body, now))
+
+	// Replayed requests are rejected
+	require.ErrorIs(t, verifier.Verify(r, body, now.Add(time.Second)), signature.ErrReplayed)
+
+	// Other secrets and bodies
+	require.ErrorIs(t, verifier.Verify(signed("other", now), body, now), signature.ErrInvalid)
+	require.ErrorIs(t, verifier.Verify(signed("secret", now), []byte(`{"foo":"baz"}`), now), signature.ErrInvalid)
+
+	// Expired requests
+	require.ErrorIs(t, verifier.Verify(signed("secret", now.Add(-2*time.Minute)), body, now), signature.ErrExpired)
+	require.ErrorIs(t, verifier.Verify(signed("secret", now), body, now.Add(2*time.Minute)), signature.ErrExpired)
+
+	// Unsigned requests
+	unsigned, err := http.NewRequest(http.MethodPost, "http://localhost/extract", nil)
+	require.NoError(t, err)
+	require.ErrorIs(t, verifier.Verify(unsigned, body, now), signature.ErrUnsigned)
+}